@@ -0,0 +1,110 @@
+package agent
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"time"
+)
+
+const clientTimeout = 2 * time.Second
+
+// Client talks to the agent on SocketPath.
+type Client struct {
+	SocketPath string
+}
+
+func NewClient(socketPath string) *Client {
+	return &Client{SocketPath: socketPath}
+}
+
+// Get returns the cached key for keyFile when its fingerprint still
+// matches the key file on disk.
+func (c *Client) Get(keyFile, fingerprint string) ([]byte, error) {
+	resp, err := c.call(request{Op: opGet, KeyFile: keyFile, Fingerprint: fingerprint})
+	if err != nil {
+		return nil, err
+	}
+	return resp.Key, nil
+}
+
+func (c *Client) Put(keyFile, fingerprint string, key []byte) error {
+	_, err := c.call(request{Op: opPut, KeyFile: keyFile, Fingerprint: fingerprint, Key: key})
+	return err
+}
+
+// Forget drops the cached key for keyFile.
+func (c *Client) Forget(keyFile string) error {
+	_, err := c.call(request{Op: opForget, KeyFile: keyFile})
+	return err
+}
+
+// Lock wipes every cached key; the agent keeps running.
+func (c *Client) Lock() error {
+	_, err := c.call(request{Op: opLock})
+	return err
+}
+
+func (c *Client) Status() (Status, error) {
+	resp, err := c.call(request{Op: opStatus})
+	if err != nil {
+		return Status{}, err
+	}
+	if resp.Status == nil {
+		return Status{}, errors.New("agent returned no status")
+	}
+	return *resp.Status, nil
+}
+
+// Stop wipes every cached key and shuts the agent down.
+func (c *Client) Stop() error {
+	_, err := c.call(request{Op: opStop})
+	return err
+}
+
+func (c *Client) call(req request) (response, error) {
+	conn, err := net.DialTimeout("unix", c.SocketPath, clientTimeout)
+	if err != nil {
+		return response{}, fmt.Errorf("%w: %v", ErrNotRunning, err)
+	}
+	defer conn.Close()
+	_ = conn.SetDeadline(time.Now().Add(clientTimeout))
+
+	if err := json.NewEncoder(conn).Encode(req); err != nil {
+		return response{}, fmt.Errorf("failed to send agent request: %w", err)
+	}
+	var resp response
+	if err := json.NewDecoder(conn).Decode(&resp); err != nil {
+		return response{}, fmt.Errorf("failed to read agent response: %w", err)
+	}
+	if resp.Error != "" {
+		if resp.Error == ErrNotCached.Error() {
+			return response{}, ErrNotCached
+		}
+		return response{}, errors.New(resp.Error)
+	}
+	return resp, nil
+}
+
+// KeyCache adapts a Client to cryptoutil.KeyCache. Agent errors only mean
+// the key has to be derived again, so they are not reported.
+type KeyCache struct {
+	Client *Client
+}
+
+func (k KeyCache) Get(keyFile, fingerprint string) ([]byte, bool) {
+	key, err := k.Client.Get(keyFile, fingerprint)
+	if err != nil || len(key) == 0 {
+		return nil, false
+	}
+	return key, true
+}
+
+func (k KeyCache) Put(keyFile, fingerprint string, key []byte) {
+	_ = k.Client.Put(keyFile, fingerprint, key)
+}
+
+func (k KeyCache) Forget(keyFile string) {
+	_ = k.Client.Forget(keyFile)
+}
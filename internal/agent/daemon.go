@@ -0,0 +1,38 @@
+package agent
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"time"
+)
+
+// StartDaemon runs executable with args as a detached background process
+// and waits until an agent answers on socketPath.
+func StartDaemon(executable string, args []string, socketPath string) error {
+	devNull, err := os.OpenFile(os.DevNull, os.O_RDWR, 0)
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %w", os.DevNull, err)
+	}
+	defer devNull.Close()
+
+	cmd := exec.Command(executable, args...)
+	cmd.Stdin = devNull
+	cmd.Stdout = devNull
+	cmd.Stderr = devNull
+	cmd.SysProcAttr = detachedProcAttr()
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("failed to start agent: %w", err)
+	}
+	_ = cmd.Process.Release()
+
+	client := NewClient(socketPath)
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		if _, err := client.Status(); err == nil {
+			return nil
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	return fmt.Errorf("agent did not come up on %s", socketPath)
+}
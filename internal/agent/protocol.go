@@ -0,0 +1,65 @@
+// Package agent implements the unlock agent: a small daemon that keeps
+// derived passphrase keys in memory behind a Unix socket so each
+// sshmanager run does not have to prompt and re-run the KDF.
+package agent
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const (
+	// SocketEnvVar overrides the agent socket location.
+	SocketEnvVar = "SSHMANAGER_AGENT_SOCK"
+
+	// DefaultIdleTimeout is how long the agent keeps keys without any
+	// request before wiping them and exiting.
+	DefaultIdleTimeout = 15 * time.Minute
+
+	socketFileName = "agent.sock"
+
+	opGet    = "get"
+	opPut    = "put"
+	opForget = "forget"
+	opLock   = "lock"
+	opStatus = "status"
+	opStop   = "stop"
+)
+
+var (
+	ErrNotRunning = errors.New("agent is not running")
+	ErrNotCached  = errors.New("key is not cached")
+)
+
+// SocketPath returns the agent socket for the data directory dataDir,
+// honouring SSHMANAGER_AGENT_SOCK.
+func SocketPath(dataDir string) string {
+	if path := strings.TrimSpace(os.Getenv(SocketEnvVar)); path != "" {
+		return path
+	}
+	return filepath.Join(dataDir, socketFileName)
+}
+
+// Status describes a running agent.
+type Status struct {
+	PID         int           `json:"pid"`
+	Keys        int           `json:"keys"`
+	IdleTimeout time.Duration `json:"idleTimeout"`
+	IdleFor     time.Duration `json:"idleFor"`
+}
+
+type request struct {
+	Op          string `json:"op"`
+	KeyFile     string `json:"keyFile,omitempty"`
+	Fingerprint string `json:"fingerprint,omitempty"`
+	Key         []byte `json:"key,omitempty"`
+}
+
+type response struct {
+	Error  string  `json:"error,omitempty"`
+	Key    []byte  `json:"key,omitempty"`
+	Status *Status `json:"status,omitempty"`
+}
@@ -0,0 +1,220 @@
+package agent
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+type cachedKey struct {
+	fingerprint string
+	key         []byte
+}
+
+// Server holds keys in memory and answers requests on a Unix socket until
+// it is stopped or stays idle for its idle timeout.
+type Server struct {
+	socketPath  string
+	idleTimeout time.Duration
+	listener    net.Listener
+
+	mu         sync.Mutex
+	keys       map[string]cachedKey
+	lastActive time.Time
+	closed     bool
+	done       chan struct{}
+}
+
+// Listen creates the agent socket with mode 0600 inside a 0700 directory.
+// A socket left behind by a dead agent is replaced; a live one is an error.
+func Listen(socketPath string, idleTimeout time.Duration) (*Server, error) {
+	if idleTimeout <= 0 {
+		return nil, errors.New("idle timeout must be positive")
+	}
+	if err := os.MkdirAll(filepath.Dir(socketPath), 0o700); err != nil {
+		return nil, fmt.Errorf("failed to create agent directory: %w", err)
+	}
+	if _, err := os.Stat(socketPath); err == nil {
+		if _, statusErr := NewClient(socketPath).Status(); statusErr == nil {
+			return nil, fmt.Errorf("agent already running on %s", socketPath)
+		}
+		if err := os.Remove(socketPath); err != nil {
+			return nil, fmt.Errorf("failed to remove stale agent socket: %w", err)
+		}
+	}
+
+	listener, err := net.Listen("unix", socketPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen on %s: %w", socketPath, err)
+	}
+	if err := os.Chmod(socketPath, 0o600); err != nil {
+		_ = listener.Close()
+		return nil, fmt.Errorf("failed to restrict agent socket: %w", err)
+	}
+
+	return &Server{
+		socketPath:  socketPath,
+		idleTimeout: idleTimeout,
+		listener:    listener,
+		keys:        map[string]cachedKey{},
+		lastActive:  time.Now(),
+		done:        make(chan struct{}),
+	}, nil
+}
+
+// Put caches key directly, e.g. to seed a foreground agent.
+func (s *Server) Put(keyFile, fingerprint string, key []byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.putLocked(keyFile, fingerprint, key)
+}
+
+// Serve answers requests until Close, a stop request or the idle timeout.
+// Keys are wiped and the socket removed on return.
+func (s *Server) Serve() error {
+	go s.watchIdle()
+
+	var wg sync.WaitGroup
+	defer func() {
+		wg.Wait()
+		s.wipe()
+		_ = os.Remove(s.socketPath)
+	}()
+
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			select {
+			case <-s.done:
+				return nil
+			default:
+				return fmt.Errorf("agent accept failed: %w", err)
+			}
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s.handle(conn)
+		}()
+	}
+}
+
+// Close stops Serve.
+func (s *Server) Close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return
+	}
+	s.closed = true
+	close(s.done)
+	_ = s.listener.Close()
+}
+
+func (s *Server) watchIdle() {
+	ticker := time.NewTicker(s.idleTimeout / 10)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.done:
+			return
+		case <-ticker.C:
+			s.mu.Lock()
+			idle := time.Since(s.lastActive) >= s.idleTimeout
+			s.mu.Unlock()
+			if idle {
+				s.Close()
+				return
+			}
+		}
+	}
+}
+
+func (s *Server) handle(conn net.Conn) {
+	defer conn.Close()
+	_ = conn.SetDeadline(time.Now().Add(5 * time.Second))
+
+	var req request
+	if err := json.NewDecoder(bufio.NewReader(conn)).Decode(&req); err != nil {
+		_ = json.NewEncoder(conn).Encode(response{Error: "invalid request"})
+		return
+	}
+
+	resp, stop := s.dispatch(req)
+	_ = json.NewEncoder(conn).Encode(resp)
+	if stop {
+		s.Close()
+	}
+}
+
+func (s *Server) dispatch(req request) (response, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	idleFor := time.Since(s.lastActive)
+	if req.Op == opGet || req.Op == opPut {
+		s.lastActive = time.Now()
+	}
+
+	switch req.Op {
+	case opGet:
+		cached, ok := s.keys[req.KeyFile]
+		if !ok || cached.fingerprint != req.Fingerprint {
+			return response{Error: ErrNotCached.Error()}, false
+		}
+		return response{Key: cached.key}, false
+	case opPut:
+		if req.KeyFile == "" || len(req.Key) == 0 {
+			return response{Error: "put needs a key file and key"}, false
+		}
+		s.putLocked(req.KeyFile, req.Fingerprint, req.Key)
+		return response{}, false
+	case opForget:
+		if cached, ok := s.keys[req.KeyFile]; ok {
+			clear(cached.key)
+			delete(s.keys, req.KeyFile)
+		}
+		return response{}, false
+	case opLock:
+		s.wipeLocked()
+		return response{}, false
+	case opStatus:
+		return response{Status: &Status{
+			PID:         os.Getpid(),
+			Keys:        len(s.keys),
+			IdleTimeout: s.idleTimeout,
+			IdleFor:     idleFor,
+		}}, false
+	case opStop:
+		s.wipeLocked()
+		return response{}, true
+	default:
+		return response{Error: fmt.Sprintf("unknown op %q", req.Op)}, false
+	}
+}
+
+func (s *Server) putLocked(keyFile, fingerprint string, key []byte) {
+	if previous, ok := s.keys[keyFile]; ok {
+		clear(previous.key)
+	}
+	s.keys[keyFile] = cachedKey{fingerprint: fingerprint, key: append([]byte(nil), key...)}
+}
+
+func (s *Server) wipe() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.wipeLocked()
+}
+
+func (s *Server) wipeLocked() {
+	for name, cached := range s.keys {
+		clear(cached.key)
+		delete(s.keys, name)
+	}
+}
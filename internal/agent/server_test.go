@@ -0,0 +1,135 @@
+package agent
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func startTestServer(t *testing.T, idleTimeout time.Duration) (string, chan error) {
+	t.Helper()
+	socketPath := filepath.Join(t.TempDir(), "agent.sock")
+	server, err := Listen(socketPath, idleTimeout)
+	if err != nil {
+		t.Fatalf("Listen failed: %v", err)
+	}
+	done := make(chan error, 1)
+	go func() { done <- server.Serve() }()
+	t.Cleanup(func() {
+		server.Close()
+		<-done
+	})
+	return socketPath, done
+}
+
+func TestAgentCachesKeysByFingerprint(t *testing.T) {
+	socketPath, _ := startTestServer(t, time.Minute)
+	client := NewClient(socketPath)
+
+	info, err := os.Stat(socketPath)
+	if err != nil {
+		t.Fatalf("Stat(socket) failed: %v", err)
+	}
+	if perms := info.Mode().Perm(); perms != 0o600 {
+		t.Fatalf("socket mode = %o, want 600", perms)
+	}
+
+	if _, err := client.Get("/k", "fp1"); !errors.Is(err, ErrNotCached) {
+		t.Fatalf("expected ErrNotCached on empty agent, got %v", err)
+	}
+	key := bytes.Repeat([]byte{9}, 32)
+	if err := client.Put("/k", "fp1", key); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+	got, err := client.Get("/k", "fp1")
+	if err != nil || !bytes.Equal(got, key) {
+		t.Fatalf("Get = %v, %v", got, err)
+	}
+	if _, err := client.Get("/k", "fp2"); !errors.Is(err, ErrNotCached) {
+		t.Fatalf("expected fingerprint mismatch to miss, got %v", err)
+	}
+
+	status, err := client.Status()
+	if err != nil || status.Keys != 1 || status.IdleTimeout != time.Minute {
+		t.Fatalf("unexpected status %+v, %v", status, err)
+	}
+
+	if err := client.Forget("/k"); err != nil {
+		t.Fatalf("Forget failed: %v", err)
+	}
+	if _, err := client.Get("/k", "fp1"); !errors.Is(err, ErrNotCached) {
+		t.Fatalf("expected forgotten key to miss, got %v", err)
+	}
+
+	_ = client.Put("/a", "fp", key)
+	_ = client.Put("/b", "fp", key)
+	if err := client.Lock(); err != nil {
+		t.Fatalf("Lock failed: %v", err)
+	}
+	if status, _ := client.Status(); status.Keys != 0 {
+		t.Fatalf("expected lock to wipe keys, got %d", status.Keys)
+	}
+}
+
+func TestAgentStopRemovesSocket(t *testing.T) {
+	socketPath, done := startTestServer(t, time.Minute)
+	if err := NewClient(socketPath).Stop(); err != nil {
+		t.Fatalf("Stop failed: %v", err)
+	}
+	select {
+	case err := <-done:
+		done <- err
+	case <-time.After(2 * time.Second):
+		t.Fatal("agent did not stop")
+	}
+	if _, err := os.Stat(socketPath); !os.IsNotExist(err) {
+		t.Fatalf("expected socket to be removed, stat err=%v", err)
+	}
+	if _, err := NewClient(socketPath).Status(); !errors.Is(err, ErrNotRunning) {
+		t.Fatalf("expected ErrNotRunning, got %v", err)
+	}
+}
+
+func TestAgentExitsAfterIdleTimeout(t *testing.T) {
+	socketPath, done := startTestServer(t, 100*time.Millisecond)
+	if err := NewClient(socketPath).Put("/k", "fp", []byte("key")); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+	select {
+	case err := <-done:
+		done <- err
+	case <-time.After(2 * time.Second):
+		t.Fatal("agent did not exit after idle timeout")
+	}
+}
+
+func TestListenReplacesStaleSocketButNotLiveAgent(t *testing.T) {
+	socketPath, _ := startTestServer(t, time.Minute)
+	if _, err := Listen(socketPath, time.Minute); err == nil {
+		t.Fatal("expected Listen to refuse a live agent socket")
+	}
+
+	stale := filepath.Join(t.TempDir(), "agent.sock")
+	if err := os.WriteFile(stale, nil, 0o600); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+	server, err := Listen(stale, time.Minute)
+	if err != nil {
+		t.Fatalf("Listen over stale socket failed: %v", err)
+	}
+	server.Close()
+}
+
+func TestSocketPathHonoursEnvVar(t *testing.T) {
+	t.Setenv(SocketEnvVar, "")
+	if got := SocketPath("/data"); got != filepath.Join("/data", "agent.sock") {
+		t.Fatalf("SocketPath = %q", got)
+	}
+	t.Setenv(SocketEnvVar, "/run/custom.sock")
+	if got := SocketPath("/data"); got != "/run/custom.sock" {
+		t.Fatalf("SocketPath with env = %q", got)
+	}
+}
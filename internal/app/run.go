@@ -1,15 +1,19 @@
 package app
 
 import (
+	"errors"
 	"fmt"
 	"os"
-	"path/filepath"
 	"strings"
 
+	"github.com/emirhangumus/sshmanager/internal/agent"
 	"github.com/emirhangumus/sshmanager/internal/cli"
 	"github.com/emirhangumus/sshmanager/internal/cli/commands"
 	"github.com/emirhangumus/sshmanager/internal/cli/flags"
+	cryptoutil "github.com/emirhangumus/sshmanager/internal/crypto"
 	"github.com/emirhangumus/sshmanager/internal/startup"
+	prompttext "github.com/emirhangumus/sshmanager/internal/ui/prompt"
+	"github.com/emirhangumus/sshmanager/internal/vault"
 )
 
 type BuildInfo struct {
@@ -27,20 +31,25 @@ func (b BuildInfo) VersionString() string {
 }
 
 func Run(args []string, build BuildInfo) error {
-	homeDir, err := os.UserHomeDir()
+	vaultFlag, args, err := extractVaultFlag(args)
 	if err != nil {
-		return fmt.Errorf("could not determine home directory: %w", err)
+		return err
 	}
 
-	connectionFilePath := filepath.Join(homeDir, ".sshmanager", "conn")
-	secretKeyFilePath := filepath.Join(homeDir, ".sshmanager", "secret.key")
-	configFilePath := filepath.Join(homeDir, ".sshmanager", "config.yaml")
+	homeDir, err := vault.HomeDir()
+	if err != nil {
+		return err
+	}
+	agentSocketPath := agent.SocketPath(homeDir)
 
 	normalizedArgs := normalizeLegacyCommandArgs(args)
+	cryptoutil.SetPassphrasePrompt(promptMasterPassphrase)
+	cryptoutil.SetKeyCache(agent.KeyCache{Client: agent.NewClient(agentSocketPath)})
 
+	// Commands that do not touch a vault run before it is resolved, so they
+	// work even when the selected vault does not exist (yet).
 	if len(normalizedArgs) >= 2 {
-		cmd := strings.TrimSpace(normalizedArgs[1])
-		switch cmd {
+		switch strings.TrimSpace(normalizedArgs[1]) {
 		case "-h", "--help", "help":
 			flags.PrintUsage(os.Stdout)
 			return nil
@@ -49,14 +58,36 @@ func Run(args []string, build BuildInfo) error {
 			return nil
 		case "completion":
 			return flags.HandleCompletion(normalizedArgs[2:])
+		case "vault":
+			return commands.HandleVault(homeDir, vaultFlag, normalizedArgs[2:])
+		case "lock":
+			return commands.HandleLock(agentSocketPath, normalizedArgs[2:])
+		}
+	}
+
+	paths, err := vault.Resolve(homeDir, vaultFlag)
+	if err != nil {
+		return err
+	}
+	connectionFilePath := paths.ConnectionFile
+	secretKeyFilePath := paths.SecretKeyFile
+	configFilePath := paths.ConfigFile
+
+	if len(normalizedArgs) >= 2 {
+		cmd := strings.TrimSpace(normalizedArgs[1])
+		switch cmd {
 		case "doctor":
-			return commands.HandleDoctor(connectionFilePath, secretKeyFilePath, configFilePath, normalizedArgs[2:])
+			return commands.HandleDoctor(paths.Name, connectionFilePath, secretKeyFilePath, configFilePath, normalizedArgs[2:])
 		case "clean":
 			return flags.CleanSSHFiles(connectionFilePath, secretKeyFilePath)
 		case "set":
 			return flags.HandleSet(configFilePath, normalizedArgs[2:])
 		case "complete":
 			return flags.HandleComplete(connectionFilePath, secretKeyFilePath, normalizedArgs[2:])
+		case "agent":
+			return commands.HandleAgent(connectionFilePath, secretKeyFilePath, agentSocketPath, normalizedArgs[2:])
+		case "tunnel":
+			return commands.HandleTunnel(connectionFilePath, secretKeyFilePath, configFilePath, normalizedArgs[2:])
 		default:
 			if strings.HasPrefix(cmd, "-") {
 				return fmt.Errorf("unknown option %q (use 'sshmanager help')", cmd)
@@ -80,6 +111,14 @@ func Run(args []string, build BuildInfo) error {
 			return commands.HandleRenameArgs(connectionFilePath, secretKeyFilePath, normalizedArgs[2:])
 		case "connect":
 			return commands.HandleConnectArgs(connectionFilePath, secretKeyFilePath, configFilePath, normalizedArgs[2:])
+		case "cp":
+			return commands.HandleCopy(connectionFilePath, secretKeyFilePath, normalizedArgs[2:])
+		case "exec":
+			return commands.HandleExec(connectionFilePath, secretKeyFilePath, configFilePath, normalizedArgs[2:])
+		case "history":
+			return commands.HandleHistory(connectionFilePath, secretKeyFilePath, normalizedArgs[2:])
+		case "profile":
+			return commands.HandleProfile(connectionFilePath, secretKeyFilePath, normalizedArgs[2:])
 		case "list":
 			return commands.HandleList(connectionFilePath, secretKeyFilePath, normalizedArgs[2:])
 		case "export":
@@ -90,6 +129,20 @@ func Run(args []string, build BuildInfo) error {
 			return commands.HandleBackup(connectionFilePath, secretKeyFilePath, configFilePath, normalizedArgs[2:])
 		case "restore":
 			return commands.HandleRestore(connectionFilePath, secretKeyFilePath, configFilePath, normalizedArgs[2:])
+		case "rekey":
+			return commands.HandleRekey(connectionFilePath, secretKeyFilePath, normalizedArgs[2:])
+		case "sync":
+			return commands.HandleSync(connectionFilePath, secretKeyFilePath, normalizedArgs[2:])
+		case "undo":
+			return commands.HandleUndo(connectionFilePath, secretKeyFilePath, normalizedArgs[2:])
+		case "redo":
+			return commands.HandleRedo(connectionFilePath, secretKeyFilePath, normalizedArgs[2:])
+		case "log":
+			return commands.HandleLog(connectionFilePath, secretKeyFilePath, normalizedArgs[2:])
+		case "trust":
+			return commands.HandleTrust(connectionFilePath, secretKeyFilePath, normalizedArgs[2:])
+		case "known-hosts":
+			return commands.HandleKnownHosts(connectionFilePath, secretKeyFilePath, normalizedArgs[2:])
 		default:
 			if len(normalizedArgs) == 2 {
 				if err := commands.FindAndConnect(connectionFilePath, secretKeyFilePath, configFilePath, normalizedArgs[1]); err != nil {
@@ -103,6 +156,39 @@ func Run(args []string, build BuildInfo) error {
 	return cli.ShowMainMenu(connectionFilePath, secretKeyFilePath, configFilePath, build.VersionString())
 }
 
+func promptMasterPassphrase(keyFilePath string) (string, error) {
+	return prompttext.MasterPassphrasePrompt(fmt.Sprintf("%s (%s)", prompttext.DefaultPromptTexts.EnterMasterPassphrase, keyFilePath))
+}
+
+// extractVaultFlag removes a leading global --vault <name> (or
+// --vault=<name>) from args.
+func extractVaultFlag(args []string) (string, []string, error) {
+	if len(args) < 2 {
+		return "", args, nil
+	}
+
+	name := ""
+	rest := args[1:]
+	for len(rest) > 0 {
+		token := strings.TrimSpace(rest[0])
+		switch {
+		case token == "--vault" || token == "-vault":
+			if len(rest) < 2 || strings.TrimSpace(rest[1]) == "" {
+				return "", nil, errors.New("--vault requires a vault name")
+			}
+			name, rest = strings.TrimSpace(rest[1]), rest[2:]
+		case strings.HasPrefix(token, "--vault="):
+			name, rest = strings.TrimSpace(strings.TrimPrefix(token, "--vault=")), rest[1:]
+			if name == "" {
+				return "", nil, errors.New("--vault requires a vault name")
+			}
+		default:
+			return name, append([]string{args[0]}, rest...), nil
+		}
+	}
+	return name, []string{args[0]}, nil
+}
+
 func normalizeLegacyCommandArgs(args []string) []string {
 	if len(args) < 2 {
 		return args
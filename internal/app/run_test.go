@@ -291,6 +291,8 @@ func setHomeEnv(t *testing.T, home string) {
 
 	t.Setenv("HOME", home)
 	t.Setenv("USERPROFILE", home)
+	t.Setenv("SSHMANAGER_HOME", "")
+	t.Setenv("SSHMANAGER_VAULT", "")
 }
 
 func captureStdout(t *testing.T, fn func()) string {
@@ -319,3 +321,77 @@ func captureStdout(t *testing.T, fn func()) string {
 	}
 	return string(b)
 }
+
+func TestRunVaultsAreIsolated(t *testing.T) {
+	home := t.TempDir()
+	setHomeEnv(t, home)
+
+	if err := Run([]string{"sshmanager", "vault", "create", "team"}, BuildInfo{}); err != nil {
+		t.Fatalf("Run(vault create) returned error: %v", err)
+	}
+	if err := Run([]string{
+		"sshmanager", "--vault", "team", "add",
+		"--host", "team.internal", "--username", "ubuntu", "--auth-mode", model.AuthModeAgent, "--alias", "team-api",
+	}, BuildInfo{}); err != nil {
+		t.Fatalf("Run(--vault team add) returned error: %v", err)
+	}
+
+	personal := captureStdout(t, func() {
+		if err := Run([]string{"sshmanager", "list"}, BuildInfo{}); err != nil {
+			t.Fatalf("Run(list) returned error: %v", err)
+		}
+	})
+	if !strings.Contains(personal, "No SSH connections found.") {
+		t.Fatalf("expected default vault to be empty, got %q", personal)
+	}
+
+	t.Setenv("SSHMANAGER_VAULT", "team")
+	completed := captureStdout(t, func() {
+		if err := Run([]string{"sshmanager", "complete", "team"}, BuildInfo{}); err != nil {
+			t.Fatalf("Run(complete) returned error: %v", err)
+		}
+	})
+	if !strings.Contains(completed, "team-api") {
+		t.Fatalf("expected completion from the team vault, got %q", completed)
+	}
+
+	teamDir := filepath.Join(home, ".sshmanager", "vaults", "team")
+	for _, name := range []string{"conn", "secret.key", "config.yaml"} {
+		if _, err := os.Stat(filepath.Join(teamDir, name)); err != nil {
+			t.Fatalf("expected %s in the team vault: %v", name, err)
+		}
+	}
+
+	doctor := captureStdout(t, func() {
+		_ = Run([]string{"sshmanager", "--vault=team", "doctor"}, BuildInfo{})
+	})
+	if !strings.Contains(doctor, "team ("+teamDir+")") {
+		t.Fatalf("expected doctor to report the team vault, got %q", doctor)
+	}
+}
+
+func TestRunHonoursHomeEnvAndRejectsUnknownVault(t *testing.T) {
+	home := t.TempDir()
+	setHomeEnv(t, home)
+	dataHome := filepath.Join(t.TempDir(), "data")
+	t.Setenv("SSHMANAGER_HOME", dataHome)
+
+	if err := Run([]string{"sshmanager", "--vault", "missing", "list"}, BuildInfo{}); err == nil || !strings.Contains(err.Error(), "does not exist") {
+		t.Fatalf("expected unknown vault error, got %v", err)
+	}
+	if err := Run([]string{"sshmanager", "--vault"}, BuildInfo{}); err == nil {
+		t.Fatal("expected error for --vault without a name")
+	}
+
+	_ = captureStdout(t, func() {
+		if err := Run([]string{"sshmanager", "list"}, BuildInfo{}); err != nil {
+			t.Fatalf("Run(list) returned error: %v", err)
+		}
+	})
+	if _, err := os.Stat(filepath.Join(dataHome, "secret.key")); err != nil {
+		t.Fatalf("expected key in SSHMANAGER_HOME: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(home, ".sshmanager")); !os.IsNotExist(err) {
+		t.Fatalf("expected ~/.sshmanager to stay untouched, stat err: %v", err)
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"path/filepath"
 	"strings"
 
 	"github.com/emirhangumus/sshmanager/internal/model"
@@ -22,7 +23,7 @@ func HandleAdd(connectionFilePath, secretKeyFilePath string) error {
 		return err
 	}
 
-	connStore := store.NewConnectionStore(connectionFilePath, secretKeyFilePath)
+	connStore := store.NewConnectionStore(connectionFilePath, secretKeyFilePath, store.WithJournalCommand("add"))
 	if err := connStore.Update(func(connFile *model.ConnectionFile) error {
 		return connFile.AddConnection(conn)
 	}); err != nil {
@@ -50,17 +51,21 @@ func handleAddArgs(connectionFilePath, secretKeyFilePath string, args []string,
 	port := fs.Int("port", 0, "SSH port (default 22)")
 	authMode := fs.String("auth-mode", "", "Auth mode: password|key|agent")
 	password := fs.String("password", "", "SSH password (password mode)")
+	passwordSource := fs.String("password-source", "", "Password source: inline|command:<cmd>|keyring:[<service>/]<account>")
 	identityFile := fs.String("identity-file", "", "Identity file path (key mode)")
 	proxyJump := fs.String("proxy-jump", "", "ProxyJump spec ([user@]host[:port][,[user@]host[:port]...])")
 	group := fs.String("group", "", "Connection group name")
 	alias := fs.String("alias", "", "Connection alias")
 	description := fs.String("description", "", "Connection description")
+	extends := fs.String("extends", "", "Profile to inherit unset settings from")
 	var localForwards stringListFlag
 	var remoteForwards stringListFlag
+	var dynamicForwards stringListFlag
 	var extraSSHArgs stringListFlag
 	var tags stringListFlag
 	fs.Var(&localForwards, "local-forward", "Local forwarding spec [bind_address:]port:host:hostport (repeatable)")
 	fs.Var(&remoteForwards, "remote-forward", "Remote forwarding spec [bind_address:]port:host:hostport (repeatable)")
+	fs.Var(&dynamicForwards, "dynamic-forward", "Dynamic (SOCKS) forwarding spec [bind_address:]port (repeatable)")
 	fs.Var(&extraSSHArgs, "extra-ssh-arg", "Extra ssh argument token (repeatable, controlled)")
 	fs.Var(&tags, "tag", "Connection tag (repeatable)")
 
@@ -72,20 +77,23 @@ func handleAddArgs(connectionFilePath, secretKeyFilePath string, args []string,
 	}
 
 	conn := model.SSHConnection{
-		Host:           strings.TrimSpace(*host),
-		Username:       strings.TrimSpace(*username),
-		Port:           *port,
-		AuthMode:       strings.TrimSpace(*authMode),
-		Password:       *password,
-		IdentityFile:   strings.TrimSpace(*identityFile),
-		ProxyJump:      strings.TrimSpace(*proxyJump),
-		LocalForwards:  localForwards.Values(),
-		RemoteForwards: remoteForwards.Values(),
-		ExtraSSHArgs:   extraSSHArgs.Values(),
-		Group:          strings.TrimSpace(*group),
-		Tags:           tags.Values(),
-		Alias:          strings.TrimSpace(*alias),
-		Description:    strings.TrimSpace(*description),
+		Host:            strings.TrimSpace(*host),
+		Username:        strings.TrimSpace(*username),
+		Port:            *port,
+		AuthMode:        strings.TrimSpace(*authMode),
+		Password:        *password,
+		PasswordSource:  strings.TrimSpace(*passwordSource),
+		IdentityFile:    strings.TrimSpace(*identityFile),
+		ProxyJump:       strings.TrimSpace(*proxyJump),
+		LocalForwards:   localForwards.Values(),
+		RemoteForwards:  remoteForwards.Values(),
+		DynamicForwards: dynamicForwards.Values(),
+		ExtraSSHArgs:    extraSSHArgs.Values(),
+		Group:           strings.TrimSpace(*group),
+		Tags:            tags.Values(),
+		Alias:           strings.TrimSpace(*alias),
+		Description:     strings.TrimSpace(*description),
+		Extends:         strings.TrimSpace(*extends),
 	}
 
 	normalized, err := normalizeImportedConnection(conn)
@@ -93,12 +101,18 @@ func handleAddArgs(connectionFilePath, secretKeyFilePath string, args []string,
 		return err
 	}
 
-	connStore := store.NewConnectionStore(connectionFilePath, secretKeyFilePath)
+	connStore := store.NewConnectionStore(connectionFilePath, secretKeyFilePath, store.WithJournalCommand("add"))
 	if err := connStore.Update(func(connFile *model.ConnectionFile) error {
+		if err := validateResolvedConnection(connFile, normalized); err != nil {
+			return err
+		}
 		return connFile.AddConnection(normalized)
 	}); err != nil {
 		return err
 	}
+	if err := trustLocalPasswordSource(filepath.Dir(connectionFilePath), normalized.PasswordSource); err != nil {
+		return err
+	}
 
 	_, _ = fmt.Fprintln(out, prompttext.DefaultPromptTexts.SuccessMessages.SSHConnectionSaved)
 	return nil
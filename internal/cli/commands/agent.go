@@ -0,0 +1,218 @@
+package commands
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/emirhangumus/sshmanager/internal/agent"
+	cryptoutil "github.com/emirhangumus/sshmanager/internal/crypto"
+	"github.com/emirhangumus/sshmanager/internal/store"
+)
+
+type agentStatusOutput struct {
+	Running     bool   `json:"running"`
+	Socket      string `json:"socket"`
+	PID         int    `json:"pid,omitempty"`
+	Keys        int    `json:"keys"`
+	IdleTimeout string `json:"idleTimeout,omitempty"`
+	IdleFor     string `json:"idleFor,omitempty"`
+}
+
+func HandleAgent(connectionFilePath, secretKeyFilePath, socketPath string, args []string) error {
+	return handleAgent(connectionFilePath, secretKeyFilePath, socketPath, args, os.Stdout)
+}
+
+func handleAgent(connectionFilePath, secretKeyFilePath, socketPath string, args []string, out io.Writer) error {
+	if len(args) == 0 {
+		return errors.New("missing agent subcommand: usage: sshmanager agent start|stop|status")
+	}
+
+	switch strings.ToLower(strings.TrimSpace(args[0])) {
+	case "start":
+		return handleAgentStart(connectionFilePath, secretKeyFilePath, socketPath, args[1:], out)
+	case "serve":
+		return handleAgentServe(args[1:])
+	case "stop":
+		return handleAgentStop(socketPath, args[1:], out)
+	case "status":
+		return handleAgentStatus(socketPath, args[1:], out)
+	default:
+		return fmt.Errorf("unknown agent subcommand %q (use start, stop or status)", args[0])
+	}
+}
+
+func handleAgentStart(connectionFilePath, secretKeyFilePath, socketPath string, args []string, out io.Writer) error {
+	fs := flag.NewFlagSet("agent start", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+
+	idleTimeout := fs.Duration("idle-timeout", agent.DefaultIdleTimeout, "Wipe keys and exit after this long without use")
+	foreground := fs.Bool("foreground", false, "Run the agent in this process")
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() > 0 {
+		return fmt.Errorf("unexpected arguments for agent start: %s", strings.Join(fs.Args(), " "))
+	}
+	if *idleTimeout <= 0 {
+		return errors.New("--idle-timeout must be positive")
+	}
+
+	info, err := cryptoutil.InspectKeyFile(secretKeyFilePath)
+	if err != nil {
+		return err
+	}
+	if info.Mode != cryptoutil.KeyFileModePassphrase {
+		return errors.New("the key file is not passphrase protected; there is nothing for the agent to cache")
+	}
+
+	// Ask for the passphrase before the agent detaches from the terminal.
+	if _, err := cryptoutil.MasterPassphrase(secretKeyFilePath); err != nil {
+		return err
+	}
+
+	client := agent.NewClient(socketPath)
+	cryptoutil.SetKeyCache(agent.KeyCache{Client: client})
+	serveErr := make(chan error, 1)
+	if status, err := client.Status(); err == nil {
+		_, _ = fmt.Fprintf(out, "Agent already running (pid %d).\n", status.PID)
+		close(serveErr)
+	} else if *foreground {
+		server, err := agent.Listen(socketPath, *idleTimeout)
+		if err != nil {
+			return err
+		}
+		go func() { serveErr <- server.Serve() }()
+	} else {
+		executable, err := os.Executable()
+		if err != nil {
+			return fmt.Errorf("failed to locate sshmanager executable: %w", err)
+		}
+		serveArgs := []string{"agent", "serve", "--socket", socketPath, "--idle-timeout", idleTimeout.String()}
+		if err := agent.StartDaemon(executable, serveArgs, socketPath); err != nil {
+			return err
+		}
+		close(serveErr)
+	}
+
+	// Loading the store derives the key, hands it to the agent and proves
+	// the passphrase is right; a wrong one is dropped from the agent again.
+	if _, err := store.NewConnectionStore(connectionFilePath, secretKeyFilePath).Load(); err != nil {
+		if *foreground {
+			_ = client.Stop()
+			<-serveErr
+		}
+		return fmt.Errorf("failed to unlock connection store: %w", err)
+	}
+
+	_, _ = fmt.Fprintf(out, "Agent unlocked on %s (idle timeout %s). Run 'sshmanager lock' to wipe the key.\n", socketPath, *idleTimeout)
+	if err, ok := <-serveErr; ok {
+		return err
+	}
+	return nil
+}
+
+// handleAgentServe is the detached daemon started by agent start.
+func handleAgentServe(args []string) error {
+	fs := flag.NewFlagSet("agent serve", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+
+	socketPath := fs.String("socket", "", "Agent socket path")
+	idleTimeout := fs.Duration("idle-timeout", agent.DefaultIdleTimeout, "Idle timeout")
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if strings.TrimSpace(*socketPath) == "" {
+		return errors.New("missing required --socket")
+	}
+
+	server, err := agent.Listen(*socketPath, *idleTimeout)
+	if err != nil {
+		return err
+	}
+	return server.Serve()
+}
+
+func handleAgentStop(socketPath string, args []string, out io.Writer) error {
+	if len(args) > 0 {
+		return fmt.Errorf("unexpected arguments for agent stop: %s", strings.Join(args, " "))
+	}
+	if err := agent.NewClient(socketPath).Stop(); err != nil {
+		if errors.Is(err, agent.ErrNotRunning) {
+			_, _ = fmt.Fprintln(out, "Agent is not running.")
+			return nil
+		}
+		return err
+	}
+	_, _ = fmt.Fprintln(out, "Agent stopped; cached keys wiped.")
+	return nil
+}
+
+func handleAgentStatus(socketPath string, args []string, out io.Writer) error {
+	fs := flag.NewFlagSet("agent status", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+
+	jsonOutput := fs.Bool("json", false, "Output JSON")
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() > 0 {
+		return fmt.Errorf("unexpected arguments for agent status: %s", strings.Join(fs.Args(), " "))
+	}
+
+	output := agentStatusOutput{Socket: socketPath}
+	status, err := agent.NewClient(socketPath).Status()
+	switch {
+	case err == nil:
+		output.Running = true
+		output.PID = status.PID
+		output.Keys = status.Keys
+		output.IdleTimeout = status.IdleTimeout.String()
+		output.IdleFor = status.IdleFor.Round(time.Second).String()
+	case !errors.Is(err, agent.ErrNotRunning):
+		return err
+	}
+
+	if *jsonOutput {
+		enc := json.NewEncoder(out)
+		enc.SetIndent("", "  ")
+		return enc.Encode(output)
+	}
+	if !output.Running {
+		_, _ = fmt.Fprintf(out, "Agent is not running (%s).\n", socketPath)
+		return nil
+	}
+	state := "locked"
+	if output.Keys > 0 {
+		state = fmt.Sprintf("unlocked, %d key(s) cached", output.Keys)
+	}
+	_, _ = fmt.Fprintf(out, "Agent running (pid %d) on %s: %s, idle %s of %s.\n", output.PID, socketPath, state, output.IdleFor, output.IdleTimeout)
+	return nil
+}
+
+func HandleLock(socketPath string, args []string) error {
+	return handleLock(socketPath, args, os.Stdout)
+}
+
+func handleLock(socketPath string, args []string, out io.Writer) error {
+	if len(args) > 0 {
+		return fmt.Errorf("unexpected arguments for lock: %s", strings.Join(args, " "))
+	}
+	if err := agent.NewClient(socketPath).Lock(); err != nil {
+		if errors.Is(err, agent.ErrNotRunning) {
+			_, _ = fmt.Fprintln(out, "Agent is not running; nothing to lock.")
+			return nil
+		}
+		return err
+	}
+	_, _ = fmt.Fprintln(out, "Locked: cached keys wiped from the agent.")
+	return nil
+}
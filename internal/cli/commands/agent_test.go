@@ -0,0 +1,98 @@
+package commands
+
+import (
+	"bytes"
+	"encoding/json"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/emirhangumus/sshmanager/internal/agent"
+	cryptoutil "github.com/emirhangumus/sshmanager/internal/crypto"
+	"github.com/emirhangumus/sshmanager/internal/model"
+)
+
+func TestAgentStartLockAndStop(t *testing.T) {
+	t.Setenv(cryptoutil.PassphraseEnvVar, "")
+	connPath, keyPath := prepareTransferFixture(t, []model.SSHConnection{
+		{Username: "u", Host: "h", Password: "p", Alias: "web"},
+	})
+	if err := handleRekey(connPath, keyPath, []string{"--mode", "passphrase", "--kdf", "pbkdf2", "--iterations", "100000"}, ioDiscard(), fixedPassphrase("agent-pass")); err != nil {
+		t.Fatalf("handleRekey failed: %v", err)
+	}
+	cryptoutil.ForgetPassphrases()
+	t.Cleanup(func() {
+		cryptoutil.SetKeyCache(nil)
+		cryptoutil.ForgetPassphrases()
+	})
+
+	socketPath := filepath.Join(t.TempDir(), "agent.sock")
+	t.Setenv(cryptoutil.PassphraseEnvVar, "agent-pass")
+	done := make(chan error, 1)
+	go func() {
+		done <- handleAgent(connPath, keyPath, socketPath, []string{"start", "--foreground", "--idle-timeout", "1m"}, ioDiscard())
+	}()
+
+	client := agent.NewClient(socketPath)
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if status, err := client.Status(); err == nil && status.Keys == 1 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("agent did not cache the key")
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+
+	// With the key cached no passphrase is needed.
+	t.Setenv(cryptoutil.PassphraseEnvVar, "")
+	cryptoutil.ForgetPassphrases()
+	if loaded := loadTransferConnections(t, connPath, keyPath); loaded.GetConnectionByAlias("web") == nil {
+		t.Fatal("expected store to load through the agent")
+	}
+
+	var out bytes.Buffer
+	if err := handleAgent(connPath, keyPath, socketPath, []string{"status", "--json"}, &out); err != nil {
+		t.Fatalf("agent status failed: %v", err)
+	}
+	var status agentStatusOutput
+	if err := json.Unmarshal(out.Bytes(), &status); err != nil || !status.Running || status.Keys != 1 {
+		t.Fatalf("unexpected status %s (%v)", out.String(), err)
+	}
+
+	out.Reset()
+	if err := handleLock(socketPath, nil, &out); err != nil || !strings.Contains(out.String(), "Locked") {
+		t.Fatalf("lock failed: %v, %q", err, out.String())
+	}
+	if _, err := cryptoutil.LoadKey(keyPath); err == nil {
+		t.Fatal("expected LoadKey to need a passphrase after lock")
+	}
+
+	if err := handleAgent(connPath, keyPath, socketPath, []string{"stop"}, ioDiscard()); err != nil {
+		t.Fatalf("agent stop failed: %v", err)
+	}
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("foreground agent returned error: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("foreground agent did not exit")
+	}
+
+	out.Reset()
+	if err := handleLock(socketPath, nil, &out); err != nil || !strings.Contains(out.String(), "not running") {
+		t.Fatalf("expected lock on stopped agent to report not running, got %v, %q", err, out.String())
+	}
+}
+
+func TestAgentStartRefusesRawKey(t *testing.T) {
+	t.Setenv(cryptoutil.PassphraseEnvVar, "")
+	connPath, keyPath := prepareTransferFixture(t, nil)
+	err := handleAgent(connPath, keyPath, filepath.Join(t.TempDir(), "agent.sock"), []string{"start", "--foreground"}, ioDiscard())
+	if err == nil || !strings.Contains(err.Error(), "not passphrase protected") {
+		t.Fatalf("expected raw key refusal, got %v", err)
+	}
+}
@@ -0,0 +1,183 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	cryptoutil "github.com/emirhangumus/sshmanager/internal/crypto"
+	"github.com/emirhangumus/sshmanager/internal/model"
+	prompttext "github.com/emirhangumus/sshmanager/internal/ui/prompt"
+)
+
+const backupPassphraseEnvVar = "SSHMANAGER_BACKUP_PASSPHRASE" //nolint:gosec // env var name, not a credential value
+
+// backupEncryption selects how backup --encrypt seals the snapshot.
+type backupEncryption struct {
+	recipients    []string
+	passphraseEnv string
+}
+
+// seal encrypts an encoded snapshot into a backup archive and describes
+// the encryption for the summary line.
+func (e backupEncryption) seal(encoded []byte, format string) ([]byte, string, error) {
+	if len(e.recipients) > 0 {
+		archive, err := cryptoutil.SealRecipientArchive(string(encoded), format, e.recipients)
+		if err != nil {
+			return nil, "", err
+		}
+		return archive, fmt.Sprintf("encrypted for %d recipient(s)", len(e.recipients)), nil
+	}
+
+	passphrase, err := readBackupPassphrase(e.passphraseEnv, true)
+	if err != nil {
+		return nil, "", err
+	}
+	archive, err := cryptoutil.SealPassphraseArchive(string(encoded), format, passphrase, cryptoutil.DefaultArgon2idParams())
+	if err != nil {
+		return nil, "", err
+	}
+	return archive, "encrypted with a passphrase", nil
+}
+
+// backupArchiveOpener decrypts a backup archive for restore.
+type backupArchiveOpener func(data []byte) (string, cryptoutil.ArchiveHeader, error)
+
+// newBackupArchiveOpener opens passphrase archives with the passphrase from
+// passphraseEnv (or a prompt) and recipient archives with the age identity
+// at identityPath, falling back to the vault key file of a team vault.
+func newBackupArchiveOpener(secretKeyFilePath, identityPath, passphraseEnv string) backupArchiveOpener {
+	return func(data []byte) (string, cryptoutil.ArchiveHeader, error) {
+		header, err := cryptoutil.InspectArchive(data)
+		if err != nil {
+			return "", header, err
+		}
+		if header.IsPassphrase() {
+			passphrase, err := readBackupPassphrase(passphraseEnv, false)
+			if err != nil {
+				return "", header, err
+			}
+			return cryptoutil.OpenPassphraseArchive(data, passphrase)
+		}
+
+		path := strings.TrimSpace(identityPath)
+		if path == "" {
+			keyData, err := os.ReadFile(secretKeyFilePath)
+			if err != nil || !cryptoutil.IsIdentityFile(keyData) {
+				return "", header, errors.New("this backup is encrypted for age recipients; pass --identity <file> with the matching private key")
+			}
+			path = secretKeyFilePath
+		}
+		id, err := cryptoutil.LoadIdentity(path)
+		if err != nil {
+			return "", header, err
+		}
+		return cryptoutil.OpenRecipientArchive(data, id)
+	}
+}
+
+// readBackupPassphrase reads the backup passphrase from envVar, or asks for
+// it on a terminal (twice when confirm is set).
+func readBackupPassphrase(envVar string, confirm bool) (string, error) {
+	if envVar != "" {
+		if passphrase := strings.TrimSpace(os.Getenv(envVar)); passphrase != "" {
+			return passphrase, nil
+		}
+	}
+	if !cryptoutil.CanPrompt() {
+		return "", fmt.Errorf("no backup passphrase: set %s or run in a terminal", envVar)
+	}
+
+	var (
+		passphrase string
+		err        error
+	)
+	if confirm {
+		passphrase, err = prompttext.NewBackupPassphrasePrompt()
+	} else {
+		passphrase, err = prompttext.MasterPassphrasePrompt(prompttext.DefaultPromptTexts.EnterBackupPassphrase)
+	}
+	if err != nil {
+		return "", err
+	}
+	passphrase = strings.TrimSpace(passphrase)
+	if passphrase == "" {
+		return "", errors.New("backup passphrase must not be empty")
+	}
+	return passphrase, nil
+}
+
+// redactSecrets removes inline passwords from connections and profiles and
+// returns how many were removed. Password auth stays selected explicitly,
+// so restore knows which entries need their password back.
+func redactSecrets(connFile *model.ConnectionFile) int {
+	redacted := 0
+	for i := range connFile.Connections {
+		conn := &connFile.Connections[i]
+		if conn.Password == "" {
+			continue
+		}
+		if conn.EffectiveAuthMode() == model.AuthModePassword {
+			conn.AuthMode = model.AuthModePassword
+		}
+		conn.Password = ""
+		redacted++
+	}
+	for i := range connFile.Profiles {
+		profile := &connFile.Profiles[i]
+		if profile.Password == "" {
+			continue
+		}
+		if profileAuthMode(*profile) == model.AuthModePassword {
+			profile.AuthMode = model.AuthModePassword
+		}
+		profile.Password = ""
+		redacted++
+	}
+	return redacted
+}
+
+// fillRedactedPasswords gives password auth entries of a redacted backup
+// the password of the matching existing connection (by ID, then alias) or
+// profile (by name). It fails when a password cannot be found.
+func fillRedactedPasswords(current *model.ConnectionFile, restored *model.ConnectionFile) error {
+	var missing []string
+	for i := range restored.Connections {
+		conn := &restored.Connections[i]
+		if !needsRedactedPassword(conn.AuthMode, conn.Password, conn.PasswordSource) {
+			continue
+		}
+		existing := current.GetConnectionByID(conn.ID)
+		if existing == nil && strings.TrimSpace(conn.Alias) != "" {
+			existing = current.GetConnectionByAlias(conn.Alias)
+		}
+		if existing == nil || existing.Password == "" {
+			missing = append(missing, connectionLabel(*conn))
+			continue
+		}
+		conn.Password = existing.Password
+	}
+	for i := range restored.Profiles {
+		profile := &restored.Profiles[i]
+		if !needsRedactedPassword(profile.AuthMode, profile.Password, profile.PasswordSource) {
+			continue
+		}
+		existing := current.GetProfile(profile.Name)
+		if existing == nil || existing.Password == "" {
+			missing = append(missing, fmt.Sprintf("profile %q", profile.Name))
+			continue
+		}
+		profile.Password = existing.Password
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("restore: the backup has redacted passwords and none is stored for %s; restore it into a vault that has them, or give those entries a passwordSource", strings.Join(missing, ", "))
+	}
+	return nil
+}
+
+func needsRedactedPassword(authMode, password, passwordSource string) bool {
+	return model.NormalizeAuthMode(authMode) == model.AuthModePassword &&
+		strings.TrimSpace(password) == "" &&
+		!model.IsExternalPasswordSource(passwordSource)
+}
@@ -0,0 +1,102 @@
+package commands
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/emirhangumus/sshmanager/internal/store"
+)
+
+type backupGenerationOutput struct {
+	Generation  int       `json:"generation"`
+	Path        string    `json:"path"`
+	SavedAt     time.Time `json:"savedAt"`
+	Size        int64     `json:"size"`
+	Connections int       `json:"connections"`
+	Error       string    `json:"error,omitempty"`
+}
+
+// handleBackupList lists the generations kept automatically on every save
+// (config key backup.generations).
+func handleBackupList(connectionFilePath, secretKeyFilePath string, args []string, out io.Writer) error {
+	fs := flag.NewFlagSet("backup list", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+
+	jsonOutput := fs.Bool("json", false, "Output JSON")
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() > 0 {
+		return fmt.Errorf("unexpected arguments for backup list: %s", strings.Join(fs.Args(), " "))
+	}
+
+	generations, err := store.NewConnectionStore(connectionFilePath, secretKeyFilePath).BackupGenerations()
+	if err != nil {
+		return err
+	}
+
+	items := make([]backupGenerationOutput, 0, len(generations))
+	for _, generation := range generations {
+		item := backupGenerationOutput{
+			Generation:  generation.Generation,
+			Path:        generation.Path,
+			SavedAt:     generation.SavedAt,
+			Size:        generation.Size,
+			Connections: generation.Connections,
+		}
+		if generation.Err != nil {
+			item.Error = generation.Err.Error()
+		}
+		items = append(items, item)
+	}
+
+	if *jsonOutput {
+		enc := json.NewEncoder(out)
+		enc.SetIndent("", "  ")
+		return enc.Encode(items)
+	}
+
+	if len(items) == 0 {
+		_, _ = fmt.Fprintln(out, "No backup generations yet (set backup.generations to keep them).")
+		return nil
+	}
+
+	tw := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(tw, "GEN\tSAVED\tCONNECTIONS\tSIZE")
+	for _, item := range items {
+		connections := fmt.Sprintf("%d", item.Connections)
+		if item.Error != "" {
+			connections = "unreadable: " + item.Error
+		}
+		_, _ = fmt.Fprintf(tw, "%d\t%s\t%s\t%d\n",
+			item.Generation,
+			item.SavedAt.Local().Format("2006-01-02 15:04:05"),
+			connections,
+			item.Size,
+		)
+	}
+	return tw.Flush()
+}
+
+// restoreBackupGeneration rolls the connections back to a kept generation.
+// The restore is an ordinary change: it is journaled and the replaced
+// connections become generation 1.
+func restoreBackupGeneration(connStore *store.ConnectionStore, generation int, mode string, out io.Writer) error {
+	snapshot, err := connStore.LoadBackupGeneration(generation)
+	if err != nil {
+		return err
+	}
+
+	if err := restoreConnections(connStore, mode, snapshot, false); err != nil {
+		return err
+	}
+
+	_, _ = fmt.Fprintf(out, "Restored backup generation %d using %s mode (%d connections)\n", generation, mode, len(snapshot.Connections))
+	return nil
+}
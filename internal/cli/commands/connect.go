@@ -7,13 +7,22 @@ import (
 	"io"
 	"os"
 	"os/exec"
+	"path/filepath"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/emirhangumus/sshmanager/internal/config"
 	"github.com/emirhangumus/sshmanager/internal/model"
+	"github.com/emirhangumus/sshmanager/internal/nativessh"
 	"github.com/emirhangumus/sshmanager/internal/store"
 	prompttext "github.com/emirhangumus/sshmanager/internal/ui/prompt"
+	"golang.org/x/crypto/ssh"
+)
+
+const (
+	recentConnectionsInPicker = 5
+	recentPickerPrefix        = "[recent] "
 )
 
 // HandleConnect returns true when caller should exit app after SSH command exits.
@@ -28,12 +37,19 @@ func HandleConnect(connectionFilePath, secretKeyFilePath string, cfg *config.SSH
 		return false, nil
 	}
 
-	items := connFile.SelectItems()
+	// History only orders the picker; an unreadable history file must not
+	// block connecting.
+	history, err := store.NewHistoryStore(connectionFilePath, secretKeyFilePath).Load()
+	if err != nil {
+		history = model.NewHistoryFile()
+	}
+
+	items, recency := connectPickerItems(connFile.SelectItems(), &history)
 	labels := make([]string, len(items))
 	for i := range items {
 		labels[i] = items[i].Label
 	}
-	idx, _, err := prompttext.SelectPrompt(prompttext.DefaultPromptTexts.SelectAnSSHConnection, labels)
+	idx, _, err := prompttext.SelectPromptRanked(prompttext.DefaultPromptTexts.SelectAnSSHConnection, labels, recency)
 	if err != nil {
 		if prompttext.IsCancelError(err) {
 			fmt.Println(prompttext.DefaultPromptTexts.SuccessMessages.OperationCancelled)
@@ -47,10 +63,15 @@ func HandleConnect(connectionFilePath, secretKeyFilePath string, cfg *config.SSH
 		fmt.Println(prompttext.DefaultPromptTexts.ErrorMessages.NoSSHConnectionsFound)
 		return false, nil
 	}
+	resolved, err := connFile.ResolveConnection(*conn)
+	if err != nil {
+		return false, err
+	}
+	conn = &resolved
 
 	printCredentialsIfEnabled(conn, cfg)
 
-	if err := connect(conn); err != nil {
+	if err := connectAndRecord(connectionFilePath, secretKeyFilePath, conn, cfg); err != nil {
 		fmt.Printf(prompttext.DefaultPromptTexts.ErrorMessages.ConnectionToXFailedX+"\n", fmt.Sprintf("%s@%s", conn.Username, conn.Host), err)
 		return false, nil
 	}
@@ -93,8 +114,94 @@ func handleConnectArgs(connectionFilePath, secretKeyFilePath, configFilePath str
 	return FindAndConnect(connectionFilePath, secretKeyFilePath, configFilePath, selectedAlias)
 }
 
-func connect(conn *model.SSHConnection) error {
-	bin, args, envAdd, err := buildConnectInvocation(conn)
+// connectPickerItems moves the most recently used connections to the top of
+// the picker, marked as recent, and returns a usage rank per item for
+// ordering equally good filter matches.
+func connectPickerItems(items []model.ConnectionSelectItem, history *model.HistoryFile) ([]model.ConnectionSelectItem, []int) {
+	usage := history.Usage()
+	recentIDs := history.RecentConnectionIDs(recentConnectionsInPicker)
+
+	byID := make(map[string]model.ConnectionSelectItem, len(items))
+	for _, item := range items {
+		byID[item.ConnectionID] = item
+	}
+
+	ordered := make([]model.ConnectionSelectItem, 0, len(items))
+	seen := make(map[string]struct{}, len(recentIDs))
+	for _, id := range recentIDs {
+		item, ok := byID[id]
+		if !ok {
+			continue
+		}
+		item.Label = recentPickerPrefix + item.Label
+		ordered = append(ordered, item)
+		seen[id] = struct{}{}
+	}
+	for _, item := range items {
+		if _, ok := seen[item.ConnectionID]; !ok {
+			ordered = append(ordered, item)
+		}
+	}
+
+	recency := make([]int, len(ordered))
+	for i, item := range ordered {
+		recency[i] = usage[item.ConnectionID].UseCount
+	}
+	return ordered, recency
+}
+
+// connectAndRecord runs connect and appends the outcome to the connection
+// history. Failing to record history is reported but never fails the session.
+func connectAndRecord(connectionFilePath, secretKeyFilePath string, conn *model.SSHConnection, cfg *config.SSHManagerConfig) error {
+	backend := config.ConnectBackendOpenSSH
+	if cfg != nil {
+		backend = cfg.Connect.EffectiveBackend()
+	}
+
+	startedAt := time.Now().UTC()
+	err := connect(filepath.Dir(connectionFilePath), conn, cfg)
+	entry := model.HistoryEntry{
+		ConnectionID: conn.ID,
+		Alias:        strings.TrimSpace(conn.Alias),
+		Target:       fmt.Sprintf("%s@%s", conn.Username, conn.Host),
+		StartedAt:    startedAt,
+		EndedAt:      time.Now().UTC(),
+		ExitCode:     exitStatusFromError(err),
+		Backend:      backend,
+	}
+	if err != nil {
+		entry.Error = err.Error()
+	}
+
+	if histErr := store.NewHistoryStore(connectionFilePath, secretKeyFilePath).Append(entry); histErr != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to record connection history: %v\n", histErr)
+	}
+	return err
+}
+
+// exitStatusFromError maps a connect error to the session exit status, or -1
+// when the session never ran.
+func exitStatusFromError(err error) int {
+	if err == nil {
+		return 0
+	}
+	var execErr *exec.ExitError
+	if errors.As(err, &execErr) {
+		return execErr.ExitCode()
+	}
+	var sshErr *ssh.ExitError
+	if errors.As(err, &sshErr) {
+		return sshErr.ExitStatus()
+	}
+	return -1
+}
+
+func connect(vaultDir string, conn *model.SSHConnection, cfg *config.SSHManagerConfig) error {
+	if cfg != nil && cfg.Connect.EffectiveBackend() == config.ConnectBackendNative {
+		return connectNative(vaultDir, conn, os.Stderr)
+	}
+
+	bin, args, envAdd, err := buildConnectInvocation(vaultDir, conn)
 	if err != nil {
 		return err
 	}
@@ -116,7 +223,39 @@ func connect(conn *model.SSHConnection) error {
 	return cmd.Run()
 }
 
-func buildConnectInvocation(conn *model.SSHConnection) (string, []string, []string, error) {
+// connectNative opens an interactive session with the in-process SSH client
+// instead of exec'ing ssh/sshpass. Raw extra ssh args cannot be honoured
+// without the OpenSSH binary, so they are reported and skipped.
+func connectNative(vaultDir string, conn *model.SSHConnection, warnOut io.Writer) error {
+	if extraArgs := model.NormalizeStringList(conn.ExtraSSHArgs); len(extraArgs) > 0 {
+		_, _ = fmt.Fprintf(warnOut, "Warning: native backend ignores extra ssh args: %s\n", strings.Join(extraArgs, " "))
+	}
+	if dynamicForwards := model.NormalizeStringList(conn.DynamicForwards); len(dynamicForwards) > 0 {
+		_, _ = fmt.Fprintf(warnOut, "Warning: native backend ignores dynamic forwards: %s\n", strings.Join(dynamicForwards, ","))
+	}
+
+	err := nativessh.Connect(conn, nativessh.Options{
+		KnownHostsFile: nativeKnownHostsFile(vaultDir),
+		Password: func() (string, error) {
+			return resolveConnectionPassword(vaultDir, conn)
+		},
+		PassphrasePrompt: func(identityFile string) ([]byte, error) {
+			passphrase, err := prompttext.InputPrompt(fmt.Sprintf("Passphrase for %s", identityFile), "", true, nil)
+			if err != nil {
+				return nil, err
+			}
+			return []byte(passphrase), nil
+		},
+	})
+	if errors.Is(err, nativessh.ErrHostKeyMismatch) {
+		return fmt.Errorf("%w\nIf the server key was changed on purpose, run 'sshmanager trust --replace %s'", err, trustSelector(conn))
+	}
+	return err
+}
+
+// buildConnectInvocation derives the ssh (or sshpass) command line for conn.
+// vaultDir selects the vault's known_hosts files; it may be empty.
+func buildConnectInvocation(vaultDir string, conn *model.SSHConnection) (string, []string, []string, error) {
 	username := strings.TrimSpace(conn.Username)
 	host := strings.TrimSpace(conn.Host)
 	if username == "" || host == "" {
@@ -130,10 +269,18 @@ func buildConnectInvocation(conn *model.SSHConnection) (string, []string, []stri
 	if err != nil {
 		return "", nil, nil, err
 	}
+	hostKeyArgs, err := hostKeyArgs(vaultDir, conn)
+	if err != nil {
+		return "", nil, nil, err
+	}
+	advancedArgs = append(hostKeyArgs, advancedArgs...)
 
 	switch authMode {
 	case model.AuthModePassword:
-		password := conn.Password
+		password, err := resolveConnectionPassword(vaultDir, conn)
+		if err != nil {
+			return "", nil, nil, err
+		}
 		if password == "" {
 			return "", nil, nil, fmt.Errorf("password is required when auth mode is %q", model.AuthModePassword)
 		}
@@ -167,10 +314,20 @@ func buildConnectInvocation(conn *model.SSHConnection) (string, []string, []stri
 	}
 }
 
+// withoutForwards returns conn with its local, remote and dynamic forwards
+// removed, for invocations that only need a session.
+func withoutForwards(conn model.SSHConnection) model.SSHConnection {
+	conn.LocalForwards = nil
+	conn.RemoteForwards = nil
+	conn.DynamicForwards = nil
+	return conn
+}
+
 func buildAdvancedSSHArgs(conn *model.SSHConnection) ([]string, error) {
 	proxyJump := strings.TrimSpace(conn.ProxyJump)
 	localForwards := model.NormalizeStringList(conn.LocalForwards)
 	remoteForwards := model.NormalizeStringList(conn.RemoteForwards)
+	dynamicForwards := model.NormalizeStringList(conn.DynamicForwards)
 	extraArgs := model.NormalizeStringList(conn.ExtraSSHArgs)
 
 	if err := model.ValidateProxyJump(proxyJump); err != nil {
@@ -182,11 +339,14 @@ func buildAdvancedSSHArgs(conn *model.SSHConnection) ([]string, error) {
 	if err := model.ValidateForwardSpecs(remoteForwards); err != nil {
 		return nil, fmt.Errorf("invalid remote forwards: %w", err)
 	}
+	if err := model.ValidateDynamicForwardSpecs(dynamicForwards); err != nil {
+		return nil, fmt.Errorf("invalid dynamic forwards: %w", err)
+	}
 	if err := model.ValidateExtraSSHArgs(extraArgs); err != nil {
 		return nil, fmt.Errorf("invalid extra ssh args: %w", err)
 	}
 
-	args := make([]string, 0, 2+2*len(localForwards)+2*len(remoteForwards)+len(extraArgs))
+	args := make([]string, 0, 2+2*len(localForwards)+2*len(remoteForwards)+2*len(dynamicForwards)+len(extraArgs))
 	if proxyJump != "" {
 		args = append(args, "-J", proxyJump)
 	}
@@ -196,6 +356,9 @@ func buildAdvancedSSHArgs(conn *model.SSHConnection) ([]string, error) {
 	for _, spec := range remoteForwards {
 		args = append(args, "-R", spec)
 	}
+	for _, spec := range dynamicForwards {
+		args = append(args, "-D", spec)
+	}
 	args = append(args, extraArgs...)
 	return args, nil
 }
@@ -207,5 +370,10 @@ func printCredentialsIfEnabled(conn *model.SSHConnection, cfg *config.SSHManager
 
 	fmt.Println("Warning: printing credentials to terminal (showCredentialsOnConnect=true)")
 	fmt.Printf("Username: %s\n", conn.Username)
+	if conn.HasExternalPasswordSource() {
+		// External secrets are only resolved for the session itself.
+		fmt.Printf("Password: (from %s)\n", conn.PasswordSource)
+		return
+	}
 	fmt.Printf("Password: %s\n", conn.Password)
 }
@@ -38,10 +38,15 @@ func findAndConnect(connectionFilePath, secretKeyFilePath, configFilePath, alias
 		fmt.Println(notFoundMessage(alias, id))
 		return nil
 	}
+	resolved, err := connFile.ResolveConnection(*conn)
+	if err != nil {
+		return err
+	}
+	conn = &resolved
 
 	fmt.Printf("Connecting to %s@%s...\n", conn.Username, conn.Host)
 	printCredentialsIfEnabled(conn, &cfg)
-	if err := connect(conn); err != nil {
+	if err := connectAndRecord(connectionFilePath, secretKeyFilePath, conn, &cfg); err != nil {
 		fmt.Printf(prompttext.DefaultPromptTexts.ErrorMessages.ConnectionToXFailedX+"\n", fmt.Sprintf("%s@%s", conn.Username, conn.Host), err)
 		return nil
 	}
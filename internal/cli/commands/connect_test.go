@@ -3,6 +3,7 @@ package commands
 import (
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/emirhangumus/sshmanager/internal/model"
@@ -25,7 +26,7 @@ func TestBuildConnectInvocationPasswordMode(t *testing.T) {
 		AuthMode: model.AuthModePassword,
 	}
 
-	bin, args, env, err := buildConnectInvocation(conn)
+	bin, args, env, err := buildConnectInvocation("", conn)
 	if err != nil {
 		t.Fatalf("buildConnectInvocation failed: %v", err)
 	}
@@ -47,7 +48,7 @@ func TestBuildConnectInvocationKeyMode(t *testing.T) {
 		IdentityFile: identityFile,
 	}
 
-	bin, args, env, err := buildConnectInvocation(conn)
+	bin, args, env, err := buildConnectInvocation("", conn)
 	if err != nil {
 		t.Fatalf("buildConnectInvocation failed: %v", err)
 	}
@@ -64,17 +65,18 @@ func TestBuildConnectInvocationKeyMode(t *testing.T) {
 func TestBuildConnectInvocationWithAdvancedOptions(t *testing.T) {
 	identityFile := writeTestIdentityFile(t)
 	conn := &model.SSHConnection{
-		Username:       "ubuntu",
-		Host:           "example.com",
-		AuthMode:       model.AuthModeKey,
-		IdentityFile:   identityFile,
-		ProxyJump:      "jump.internal:2222",
-		LocalForwards:  []string{"8080:127.0.0.1:80"},
-		RemoteForwards: []string{"9000:127.0.0.1:9000"},
-		ExtraSSHArgs:   []string{"-vv", "-o", "ServerAliveInterval=30"},
+		Username:        "ubuntu",
+		Host:            "example.com",
+		AuthMode:        model.AuthModeKey,
+		IdentityFile:    identityFile,
+		ProxyJump:       "jump.internal:2222",
+		LocalForwards:   []string{"8080:127.0.0.1:80"},
+		RemoteForwards:  []string{"9000:127.0.0.1:9000"},
+		DynamicForwards: []string{"1080", "[::1]:1081"},
+		ExtraSSHArgs:    []string{"-vv", "-o", "ServerAliveInterval=30"},
 	}
 
-	bin, args, env, err := buildConnectInvocation(conn)
+	bin, args, env, err := buildConnectInvocation("", conn)
 	if err != nil {
 		t.Fatalf("buildConnectInvocation failed: %v", err)
 	}
@@ -87,6 +89,8 @@ func TestBuildConnectInvocationWithAdvancedOptions(t *testing.T) {
 		"-J", "jump.internal:2222",
 		"-L", "8080:127.0.0.1:80",
 		"-R", "9000:127.0.0.1:9000",
+		"-D", "1080",
+		"-D", "[::1]:1081",
 		"-vv",
 		"-o", "ServerAliveInterval=30",
 		"ubuntu@example.com",
@@ -104,7 +108,7 @@ func TestBuildConnectInvocationAgentMode(t *testing.T) {
 		AuthMode: model.AuthModeAgent,
 	}
 
-	bin, args, env, err := buildConnectInvocation(conn)
+	bin, args, env, err := buildConnectInvocation("", conn)
 	if err != nil {
 		t.Fatalf("buildConnectInvocation failed: %v", err)
 	}
@@ -125,7 +129,7 @@ func TestBuildConnectInvocationLegacyFallback(t *testing.T) {
 		Password: "secret",
 	}
 
-	bin, args, _, err := buildConnectInvocation(conn)
+	bin, args, _, err := buildConnectInvocation("", conn)
 	if err != nil {
 		t.Fatalf("buildConnectInvocation failed: %v", err)
 	}
@@ -143,7 +147,7 @@ func TestBuildConnectInvocationRejectsMissingIdentityForKeyMode(t *testing.T) {
 		AuthMode: model.AuthModeKey,
 	}
 
-	if _, _, _, err := buildConnectInvocation(conn); err == nil {
+	if _, _, _, err := buildConnectInvocation("", conn); err == nil {
 		t.Fatal("expected error for missing identity file in key mode, got nil")
 	}
 }
@@ -156,7 +160,7 @@ func TestBuildConnectInvocationRejectsNonexistentIdentityFile(t *testing.T) {
 		IdentityFile: filepath.Join(t.TempDir(), "does-not-exist"),
 	}
 
-	if _, _, _, err := buildConnectInvocation(conn); err == nil {
+	if _, _, _, err := buildConnectInvocation("", conn); err == nil {
 		t.Fatal("expected error for nonexistent identity file, got nil")
 	}
 }
@@ -169,7 +173,7 @@ func TestBuildConnectInvocationRejectsDirectoryIdentityFile(t *testing.T) {
 		IdentityFile: t.TempDir(),
 	}
 
-	if _, _, _, err := buildConnectInvocation(conn); err == nil {
+	if _, _, _, err := buildConnectInvocation("", conn); err == nil {
 		t.Fatal("expected error for directory identity file, got nil")
 	}
 }
@@ -211,7 +215,7 @@ func TestBuildConnectInvocationRejectsInvalidAdvancedOptions(t *testing.T) {
 	for _, tc := range tests {
 		tc := tc
 		t.Run(tc.name, func(t *testing.T) {
-			if _, _, _, err := buildConnectInvocation(tc.conn); err == nil {
+			if _, _, _, err := buildConnectInvocation("", tc.conn); err == nil {
 				t.Fatal("expected validation error, got nil")
 			}
 		})
@@ -229,3 +233,31 @@ func assertStringSliceEqual(t *testing.T, got, want []string) {
 		}
 	}
 }
+
+func TestConnectNativeWarnsAboutIgnoredExtraArgs(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	if err := os.MkdirAll(filepath.Join(home, ".ssh"), 0o700); err != nil {
+		t.Fatalf("MkdirAll failed: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(home, ".ssh", "known_hosts"), nil, 0o600); err != nil {
+		t.Fatalf("failed to write known_hosts: %v", err)
+	}
+
+	conn := &model.SSHConnection{
+		Username:     "ubuntu",
+		Host:         "example.com",
+		AuthMode:     model.AuthModeKey,
+		IdentityFile: filepath.Join(t.TempDir(), "missing"),
+		ExtraSSHArgs: []string{"-A", "-o", "ServerAliveInterval=30"},
+	}
+
+	var warn strings.Builder
+	err := connectNative(t.TempDir(), conn, &warn)
+	if err == nil || !strings.Contains(err.Error(), "is not accessible") {
+		t.Fatalf("expected identity file error, got %v", err)
+	}
+	if !strings.Contains(warn.String(), "native backend ignores extra ssh args: -A -o ServerAliveInterval=30") {
+		t.Fatalf("unexpected warning output: %q", warn.String())
+	}
+}
@@ -0,0 +1,252 @@
+package commands
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"github.com/emirhangumus/sshmanager/internal/model"
+	"github.com/emirhangumus/sshmanager/internal/store"
+	prompttext "github.com/emirhangumus/sshmanager/internal/ui/prompt"
+)
+
+// scpCompatibleExtraArgs are the standalone extra ssh args scp accepts with
+// the same meaning. Session-only flags (-N, -t, -X, ...) are dropped.
+var scpCompatibleExtraArgs = map[string]struct{}{
+	"-4":   {},
+	"-6":   {},
+	"-A":   {},
+	"-C":   {},
+	"-q":   {},
+	"-v":   {},
+	"-vv":  {},
+	"-vvv": {},
+}
+
+// copyRunner runs a prepared copy invocation and returns its exit code.
+type copyRunner func(bin string, args, envAdd []string, stdout, stderr io.Writer) (int, error)
+
+// copyEndpoint is one side of a cp command: either a local path or
+// "<alias>:<path>" on a saved connection.
+type copyEndpoint struct {
+	Alias  string
+	Path   string
+	Remote bool
+}
+
+func HandleCopy(connectionFilePath, secretKeyFilePath string, args []string) error {
+	return handleCopy(connectionFilePath, secretKeyFilePath, args, os.Stdout, func(bin string, args, envAdd []string, stdout, stderr io.Writer) (int, error) {
+		return runInvocation(bin, args, envAdd, nil, stdout, stderr)
+	})
+}
+
+func handleCopy(connectionFilePath, secretKeyFilePath string, args []string, out io.Writer, run copyRunner) error {
+	fs := flag.NewFlagSet("cp", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	recursive := fs.Bool("recursive", false, "Copy directories recursively")
+	fs.BoolVar(recursive, "r", false, "Copy directories recursively")
+	useSFTP := fs.Bool("sftp", false, "Use the SFTP protocol for the transfer")
+	groupFilter := fs.String("group", "", "Copy to/from every connection in group")
+	var tagFilters stringListFlag
+	fs.Var(&tagFilters, "tag", "Copy to/from every connection with tag (repeatable)")
+	parallel := fs.Int("parallel", defaultExecParallel, "Maximum number of hosts to copy with concurrently")
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 2 {
+		return errors.New("cp: expected <alias>:<remote> <local> or <local> <alias>:<remote>")
+	}
+	if *parallel < 1 {
+		return errors.New("cp: --parallel must be at least 1")
+	}
+
+	src := parseCopyEndpoint(fs.Arg(0))
+	dst := parseCopyEndpoint(fs.Arg(1))
+	if src.Remote == dst.Remote {
+		return errors.New("cp: exactly one of source and destination must be <alias>:<path>")
+	}
+	remote := src
+	if dst.Remote {
+		remote = dst
+	}
+
+	filtering := strings.TrimSpace(*groupFilter) != "" || len(tagFilters.Values()) > 0
+	switch {
+	case filtering && remote.Alias != "":
+		return errors.New("cp: with --group/--tag write the remote side as :<path> without an alias")
+	case !filtering && remote.Alias == "":
+		return errors.New("cp: remote side needs an alias (<alias>:<path>) or --group/--tag")
+	}
+
+	connStore := store.NewConnectionStore(connectionFilePath, secretKeyFilePath)
+	connFile, err := connStore.Load()
+	if err != nil {
+		return err
+	}
+
+	if !filtering {
+		conn := connFile.GetConnectionByAlias(remote.Alias)
+		if conn == nil {
+			return errors.New(notFoundMessage(remote.Alias, ""))
+		}
+		resolved, err := connFile.ResolveConnection(*conn)
+		if err != nil {
+			return err
+		}
+		bin, copyArgs, envAdd, err := buildCopyInvocation(filepath.Dir(connectionFilePath), &resolved, src, dst, *recursive, *useSFTP)
+		if err != nil {
+			return err
+		}
+		code, err := run(bin, copyArgs, envAdd, out, os.Stderr)
+		if err != nil {
+			return err
+		}
+		if code != 0 {
+			return fmt.Errorf("cp: %s exited with status %d", bin, code)
+		}
+		return nil
+	}
+
+	var targets []model.SSHConnection
+	for _, conn := range connFile.Connections {
+		if matchesListFilters(conn, *groupFilter, tagFilters.Values()) {
+			targets = append(targets, conn)
+		}
+	}
+	if len(targets) == 0 {
+		return errors.New(prompttext.DefaultPromptTexts.ErrorMessages.NoSSHConnectionsFound)
+	}
+	targets, err = resolveConnections(&connFile, targets)
+	if err != nil {
+		return err
+	}
+
+	// Downloads from several hosts land in one sub-directory per host so
+	// files with the same name do not overwrite each other.
+	labels := execHostLabels(targets)
+	hostDirs := make(map[string]string, len(targets))
+	if src.Remote {
+		for i := range targets {
+			dir := filepath.Join(dst.Path, labels[i])
+			if err := os.MkdirAll(dir, 0o755); err != nil {
+				return fmt.Errorf("cp: failed to create %s: %w", dir, err)
+			}
+			hostDirs[targets[i].ID] = dir
+		}
+	}
+
+	results := runOnTargets(targets, *parallel, out, false, func(conn *model.SSHConnection, stdout, stderr io.Writer) (int, error) {
+		hostDst := dst
+		if src.Remote {
+			hostDst = copyEndpoint{Path: hostDirs[conn.ID]}
+		}
+		bin, copyArgs, envAdd, err := buildCopyInvocation(filepath.Dir(connectionFilePath), conn, src, hostDst, *recursive, *useSFTP)
+		if err != nil {
+			return 0, err
+		}
+		return run(bin, copyArgs, envAdd, stdout, stderr)
+	})
+	if err := printExecSummary(out, results); err != nil {
+		return err
+	}
+
+	failed := 0
+	for _, result := range results {
+		if result.ExitCode != 0 || result.Error != "" {
+			failed++
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("cp: copy failed on %d of %d hosts", failed, len(results))
+	}
+	return nil
+}
+
+// parseCopyEndpoint treats "alias:path" as remote when the part before the
+// first colon contains no path separator, like scp does. Prefix local paths
+// containing a colon with "./" to keep them local.
+func parseCopyEndpoint(arg string) copyEndpoint {
+	idx := strings.Index(arg, ":")
+	if idx < 0 || strings.ContainsAny(arg[:idx], `/\`) {
+		return copyEndpoint{Path: arg}
+	}
+	if runtime.GOOS == "windows" && idx == 1 {
+		// Drive letter, e.g. C:\Users.
+		return copyEndpoint{Path: arg}
+	}
+	return copyEndpoint{Alias: strings.TrimSpace(arg[:idx]), Path: arg[idx+1:], Remote: true}
+}
+
+// buildCopyInvocation derives an scp invocation for conn from the same
+// arguments connect uses: -p becomes -P, forwards are dropped and only extra
+// args scp understands are kept.
+func buildCopyInvocation(vaultDir string, conn *model.SSHConnection, src, dst copyEndpoint, recursive, useSFTP bool) (string, []string, []string, error) {
+	bin, sshArgs, envAdd, err := buildConnectInvocation(vaultDir, conn)
+	if err != nil {
+		return "", nil, nil, err
+	}
+
+	var prefix []string
+	if bin == "sshpass" {
+		// sshpass -e ssh ... -> sshpass -e scp ...
+		prefix = []string{"-e", "scp"}
+		sshArgs = sshArgs[2:]
+	}
+	// The last connect argument is the user@host target.
+	sshArgs = sshArgs[:len(sshArgs)-1]
+
+	scpArgs := make([]string, 0, len(sshArgs)+5)
+	if recursive {
+		scpArgs = append(scpArgs, "-r")
+	}
+	if useSFTP {
+		scpArgs = append(scpArgs, "-s")
+	}
+	for i := 0; i < len(sshArgs); i++ {
+		arg := sshArgs[i]
+		switch {
+		case arg == "-p":
+			scpArgs = append(scpArgs, "-P", sshArgs[i+1])
+			i++
+		case arg == "-i", arg == "-J", arg == "-o":
+			scpArgs = append(scpArgs, arg, sshArgs[i+1])
+			i++
+		case arg == "-L", arg == "-R", arg == "-D":
+			i++
+		case strings.HasPrefix(arg, "-o"):
+			scpArgs = append(scpArgs, arg)
+		default:
+			if _, ok := scpCompatibleExtraArgs[arg]; ok {
+				scpArgs = append(scpArgs, arg)
+			}
+		}
+	}
+
+	remotePrefix := fmt.Sprintf("%s@%s:", strings.TrimSpace(conn.Username), scpHost(strings.TrimSpace(conn.Host)))
+	for _, endpoint := range []copyEndpoint{src, dst} {
+		if endpoint.Remote {
+			scpArgs = append(scpArgs, remotePrefix+endpoint.Path)
+		} else {
+			scpArgs = append(scpArgs, endpoint.Path)
+		}
+	}
+
+	if bin == "sshpass" {
+		return bin, append(prefix, scpArgs...), envAdd, nil
+	}
+	return "scp", scpArgs, envAdd, nil
+}
+
+// scpHost brackets IPv6 literals so scp does not split them at the colon.
+func scpHost(host string) string {
+	if strings.Contains(host, ":") && !strings.HasPrefix(host, "[") {
+		return "[" + host + "]"
+	}
+	return host
+}
@@ -0,0 +1,193 @@
+package commands
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/emirhangumus/sshmanager/internal/model"
+)
+
+type recordedCopy struct {
+	bin  string
+	args []string
+	env  []string
+}
+
+type copyRecorder struct {
+	mu    sync.Mutex
+	calls []recordedCopy
+}
+
+func (r *copyRecorder) run(bin string, args, envAdd []string, stdout, stderr io.Writer) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.calls = append(r.calls, recordedCopy{bin: bin, args: args, env: envAdd})
+	return 0, nil
+}
+
+func TestBuildCopyInvocationPasswordMode(t *testing.T) {
+	conn := &model.SSHConnection{
+		Username:       "ubuntu",
+		Host:           "example.com",
+		Port:           2222,
+		Password:       "secret",
+		AuthMode:       model.AuthModePassword,
+		ProxyJump:      "bastion:2200",
+		LocalForwards:  []string{"8080:127.0.0.1:80"},
+		RemoteForwards: []string{"9000:localhost:9000"},
+		ExtraSSHArgs:   []string{"-C", "-N", "-o", "ServerAliveInterval=30", "-oCompression=yes", "-t"},
+	}
+
+	bin, args, env, err := buildCopyInvocation("", conn, copyEndpoint{Alias: "prod", Path: "/var/log/app.log", Remote: true}, copyEndpoint{Path: "./app.log"}, true, false)
+	if err != nil {
+		t.Fatalf("buildCopyInvocation failed: %v", err)
+	}
+	if bin != "sshpass" {
+		t.Fatalf("unexpected binary: %q", bin)
+	}
+	wantArgs := []string{
+		"-e", "scp", "-r",
+		"-P", "2222",
+		"-J", "bastion:2200",
+		"-C", "-o", "ServerAliveInterval=30", "-oCompression=yes",
+		"ubuntu@example.com:/var/log/app.log", "./app.log",
+	}
+	assertStringSliceEqual(t, args, wantArgs)
+	assertStringSliceEqual(t, env, []string{"SSHPASS=secret"})
+}
+
+func TestBuildCopyInvocationKeyModeUpload(t *testing.T) {
+	identity := writeTestIdentityFile(t)
+	conn := &model.SSHConnection{
+		Username:     "deploy",
+		Host:         "2001:db8::10",
+		AuthMode:     model.AuthModeKey,
+		IdentityFile: identity,
+	}
+
+	bin, args, env, err := buildCopyInvocation("", conn, copyEndpoint{Path: "dist"}, copyEndpoint{Alias: "edge", Path: "/srv/app", Remote: true}, false, true)
+	if err != nil {
+		t.Fatalf("buildCopyInvocation failed: %v", err)
+	}
+	if bin != "scp" {
+		t.Fatalf("unexpected binary: %q", bin)
+	}
+	assertStringSliceEqual(t, args, []string{"-s", "-P", "22", "-i", identity, "dist", "deploy@[2001:db8::10]:/srv/app"})
+	if len(env) != 0 {
+		t.Fatalf("expected no extra env, got %v", env)
+	}
+}
+
+func TestBuildCopyInvocationDropsDynamicForwards(t *testing.T) {
+	identity := writeTestIdentityFile(t)
+	conn := &model.SSHConnection{
+		Username:        "deploy",
+		Host:            "example.com",
+		AuthMode:        model.AuthModeKey,
+		IdentityFile:    identity,
+		LocalForwards:   []string{"8080:127.0.0.1:80"},
+		DynamicForwards: []string{"1080", "127.0.0.1:1081"},
+	}
+
+	_, args, _, err := buildCopyInvocation("", conn, copyEndpoint{Path: "notes.txt"}, copyEndpoint{Alias: "edge", Path: "/tmp/", Remote: true}, false, false)
+	if err != nil {
+		t.Fatalf("buildCopyInvocation failed: %v", err)
+	}
+	assertStringSliceEqual(t, args, []string{"-P", "22", "-i", identity, "notes.txt", "deploy@example.com:/tmp/"})
+}
+
+func TestParseCopyEndpoint(t *testing.T) {
+	tests := []struct {
+		arg  string
+		want copyEndpoint
+	}{
+		{arg: "prod:/etc/hosts", want: copyEndpoint{Alias: "prod", Path: "/etc/hosts", Remote: true}},
+		{arg: ":/etc/hosts", want: copyEndpoint{Path: "/etc/hosts", Remote: true}},
+		{arg: "prod:", want: copyEndpoint{Alias: "prod", Path: "", Remote: true}},
+		{arg: "./odd:name", want: copyEndpoint{Path: "./odd:name"}},
+		{arg: "/tmp/file", want: copyEndpoint{Path: "/tmp/file"}},
+	}
+	for _, tc := range tests {
+		if got := parseCopyEndpoint(tc.arg); got != tc.want {
+			t.Fatalf("parseCopyEndpoint(%q) = %+v, want %+v", tc.arg, got, tc.want)
+		}
+	}
+}
+
+func TestHandleCopySingleHost(t *testing.T) {
+	connPath, keyPath := prepareListFixture(t, execFixtureConnections())
+	recorder := &copyRecorder{}
+
+	if err := handleCopy(connPath, keyPath, []string{"-r", "db:/var/lib/backups", "./backups"}, ioDiscard(), recorder.run); err != nil {
+		t.Fatalf("handleCopy failed: %v", err)
+	}
+	if len(recorder.calls) != 1 {
+		t.Fatalf("expected one scp call, got %d", len(recorder.calls))
+	}
+	assertStringSliceEqual(t, recorder.calls[0].args, []string{
+		"-r", "-P", "22",
+		"-o", "UserKnownHostsFile=" + managedKnownHostsPath(filepath.Dir(connPath)),
+		"-o", "HashKnownHosts=no",
+		"postgres@db.internal:/var/lib/backups", "./backups",
+	})
+}
+
+func TestHandleCopyGroupDownloadUsesPerHostDirectories(t *testing.T) {
+	connPath, keyPath := prepareListFixture(t, execFixtureConnections())
+	recorder := &copyRecorder{}
+	localDir := filepath.Join(t.TempDir(), "logs")
+
+	var out strings.Builder
+	if err := handleCopy(connPath, keyPath, []string{"--tag", "web", "--group", "production", ":/var/log/syslog", localDir}, &out, recorder.run); err != nil {
+		t.Fatalf("handleCopy failed: %v", err)
+	}
+
+	var got []string
+	for _, call := range recorder.calls {
+		got = append(got, strings.Join(call.args[len(call.args)-2:], " "))
+	}
+	sort.Strings(got)
+	assertStringSliceEqual(t, got, []string{
+		"ubuntu@web1.internal:/var/log/syslog " + filepath.Join(localDir, "web1"),
+		"ubuntu@web2.internal:/var/log/syslog " + filepath.Join(localDir, "web2"),
+	})
+	for _, host := range []string{"web1", "web2"} {
+		if info, err := os.Stat(filepath.Join(localDir, host)); err != nil || !info.IsDir() {
+			t.Fatalf("expected per-host directory for %s: %v", host, err)
+		}
+	}
+	if !strings.Contains(out.String(), "HOST") || !strings.Contains(out.String(), "web2") {
+		t.Fatalf("expected summary table, got %q", out.String())
+	}
+}
+
+func TestHandleCopyRejectsInvalidArguments(t *testing.T) {
+	connPath, keyPath := prepareListFixture(t, execFixtureConnections())
+	recorder := &copyRecorder{}
+
+	tests := []struct {
+		args []string
+		want string
+	}{
+		{args: []string{"db:/a"}, want: "expected"},
+		{args: []string{"./a", "./b"}, want: "exactly one"},
+		{args: []string{"db:/a", "web1:/b"}, want: "exactly one"},
+		{args: []string{"--group", "production", "db:/a", "./b"}, want: "without an alias"},
+		{args: []string{":/a", "./b"}, want: "needs an alias"},
+		{args: []string{"missing:/a", "./b"}, want: "missing"},
+	}
+	for _, tc := range tests {
+		err := handleCopy(connPath, keyPath, tc.args, ioDiscard(), recorder.run)
+		if err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Fatalf("handleCopy(%v) error = %v, want containing %q", tc.args, err, tc.want)
+		}
+	}
+	if len(recorder.calls) != 0 {
+		t.Fatalf("expected no scp calls, got %d", len(recorder.calls))
+	}
+}
@@ -20,6 +20,7 @@ func TestHandleAddArgsAddsConnection(t *testing.T) {
 		"--proxy-jump", "jump.internal:2222",
 		"--local-forward", "8080:127.0.0.1:80",
 		"--remote-forward", "9000:127.0.0.1:9000",
+		"--dynamic-forward", "127.0.0.1:1080",
 		"--extra-ssh-arg", "-vv",
 		"--extra-ssh-arg", "-o",
 		"--extra-ssh-arg", "ServerAliveInterval=30",
@@ -57,6 +58,9 @@ func TestHandleAddArgsAddsConnection(t *testing.T) {
 	if len(conn.RemoteForwards) != 1 || conn.RemoteForwards[0] != "9000:127.0.0.1:9000" {
 		t.Fatalf("unexpected remote forwards: %v", conn.RemoteForwards)
 	}
+	if len(conn.DynamicForwards) != 1 || conn.DynamicForwards[0] != "127.0.0.1:1080" {
+		t.Fatalf("unexpected dynamic forwards: %v", conn.DynamicForwards)
+	}
 	if len(conn.ExtraSSHArgs) != 3 {
 		t.Fatalf("unexpected extra ssh args: %v", conn.ExtraSSHArgs)
 	}
@@ -101,6 +105,7 @@ func TestHandleEditArgsUpdatesConnection(t *testing.T) {
 		"--new-proxy-jump", "jump.internal:2200",
 		"--new-local-forward", "8080:127.0.0.1:80",
 		"--new-remote-forward", "9000:127.0.0.1:9000",
+		"--new-dynamic-forward", "1080",
 		"--new-extra-ssh-arg", "-vv",
 		"--new-extra-ssh-arg", "-o",
 		"--new-extra-ssh-arg", "ServerAliveInterval=30",
@@ -145,6 +150,9 @@ func TestHandleEditArgsUpdatesConnection(t *testing.T) {
 	if len(updated.RemoteForwards) != 1 || updated.RemoteForwards[0] != "9000:127.0.0.1:9000" {
 		t.Fatalf("unexpected remote forwards: %v", updated.RemoteForwards)
 	}
+	if len(updated.DynamicForwards) != 1 || updated.DynamicForwards[0] != "1080" {
+		t.Fatalf("unexpected dynamic forwards: %v", updated.DynamicForwards)
+	}
 	if len(updated.ExtraSSHArgs) != 3 {
 		t.Fatalf("unexpected extra ssh args: %v", updated.ExtraSSHArgs)
 	}
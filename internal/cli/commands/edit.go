@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"path/filepath"
 	"strings"
 
 	"github.com/emirhangumus/sshmanager/internal/model"
@@ -13,7 +14,7 @@ import (
 )
 
 func HandleEdit(connectionFilePath, secretKeyFilePath string) error {
-	connStore := store.NewConnectionStore(connectionFilePath, secretKeyFilePath)
+	connStore := store.NewConnectionStore(connectionFilePath, secretKeyFilePath, store.WithJournalCommand("edit"))
 	connFile, err := connStore.Load()
 	if err != nil {
 		return err
@@ -89,25 +90,31 @@ func handleEditArgs(connectionFilePath, secretKeyFilePath string, args []string,
 	newPort := fs.Int("new-port", -1, "New SSH port (use 22 for default)")
 	newAuthMode := fs.String("new-auth-mode", "", "New auth mode: password|key|agent")
 	newPassword := fs.String("new-password", "", "New password")
+	newPasswordSource := fs.String("new-password-source", "", "New password source: inline|command:<cmd>|keyring:[<service>/]<account>")
 	newIdentityFile := fs.String("new-identity-file", "", "New identity file path")
 	newProxyJump := fs.String("new-proxy-jump", "", "New ProxyJump spec")
 	newGroup := fs.String("new-group", "", "New connection group")
 	newAlias := fs.String("new-alias", "", "New alias")
 	newDescription := fs.String("new-description", "", "New description")
+	newExtends := fs.String("new-extends", "", "New profile to inherit from")
 	clearAlias := fs.Bool("clear-alias", false, "Clear alias")
 	clearDescription := fs.Bool("clear-description", false, "Clear description")
 	clearProxyJump := fs.Bool("clear-proxy-jump", false, "Clear proxy jump")
 	clearGroup := fs.Bool("clear-group", false, "Clear group")
 	clearLocalForwards := fs.Bool("clear-local-forwards", false, "Clear local forward specs")
 	clearRemoteForwards := fs.Bool("clear-remote-forwards", false, "Clear remote forward specs")
+	clearDynamicForwards := fs.Bool("clear-dynamic-forwards", false, "Clear dynamic forward specs")
 	clearExtraSSHArgs := fs.Bool("clear-extra-ssh-args", false, "Clear extra ssh args")
 	clearTags := fs.Bool("clear-tags", false, "Clear tags")
+	clearExtends := fs.Bool("clear-extends", false, "Stop inheriting from a profile")
 	var newLocalForwards stringListFlag
 	var newRemoteForwards stringListFlag
+	var newDynamicForwards stringListFlag
 	var newExtraSSHArgs stringListFlag
 	var newTags stringListFlag
 	fs.Var(&newLocalForwards, "new-local-forward", "Replace local forward list with provided values (repeatable)")
 	fs.Var(&newRemoteForwards, "new-remote-forward", "Replace remote forward list with provided values (repeatable)")
+	fs.Var(&newDynamicForwards, "new-dynamic-forward", "Replace dynamic forward list with provided values (repeatable)")
 	fs.Var(&newExtraSSHArgs, "new-extra-ssh-arg", "Replace extra ssh args with provided values (repeatable)")
 	fs.Var(&newTags, "new-tag", "Replace tags with provided values (repeatable)")
 
@@ -140,40 +147,51 @@ func handleEditArgs(connectionFilePath, secretKeyFilePath string, args []string,
 	if *clearRemoteForwards && len(newRemoteForwards) > 0 {
 		return fmt.Errorf("edit: use either --new-remote-forward or --clear-remote-forwards, not both")
 	}
+	if *clearDynamicForwards && len(newDynamicForwards) > 0 {
+		return fmt.Errorf("edit: use either --new-dynamic-forward or --clear-dynamic-forwards, not both")
+	}
 	if *clearExtraSSHArgs && len(newExtraSSHArgs) > 0 {
 		return fmt.Errorf("edit: use either --new-extra-ssh-arg or --clear-extra-ssh-args, not both")
 	}
 	if *clearTags && len(newTags) > 0 {
 		return fmt.Errorf("edit: use either --new-tag or --clear-tags, not both")
 	}
+	if *clearExtends && strings.TrimSpace(*newExtends) != "" {
+		return fmt.Errorf("edit: use either --new-extends or --clear-extends, not both")
+	}
 
 	hasUpdate := strings.TrimSpace(*newHost) != "" ||
 		strings.TrimSpace(*newUsername) != "" ||
 		*newPort >= 0 ||
 		strings.TrimSpace(*newAuthMode) != "" ||
 		strings.TrimSpace(*newPassword) != "" ||
+		strings.TrimSpace(*newPasswordSource) != "" ||
 		strings.TrimSpace(*newIdentityFile) != "" ||
 		strings.TrimSpace(*newProxyJump) != "" ||
 		strings.TrimSpace(*newGroup) != "" ||
 		len(newLocalForwards) > 0 ||
 		len(newRemoteForwards) > 0 ||
+		len(newDynamicForwards) > 0 ||
 		len(newExtraSSHArgs) > 0 ||
 		len(newTags) > 0 ||
 		strings.TrimSpace(*newAlias) != "" ||
 		strings.TrimSpace(*newDescription) != "" ||
+		strings.TrimSpace(*newExtends) != "" ||
 		*clearAlias ||
 		*clearDescription ||
 		*clearProxyJump ||
 		*clearGroup ||
 		*clearLocalForwards ||
 		*clearRemoteForwards ||
+		*clearDynamicForwards ||
 		*clearExtraSSHArgs ||
-		*clearTags
+		*clearTags ||
+		*clearExtends
 	if !hasUpdate {
 		return fmt.Errorf("edit: no update fields provided")
 	}
 
-	connStore := store.NewConnectionStore(connectionFilePath, secretKeyFilePath)
+	connStore := store.NewConnectionStore(connectionFilePath, secretKeyFilePath, store.WithJournalCommand("edit"))
 	connFile, err := connStore.Load()
 	if err != nil {
 		return err
@@ -204,6 +222,10 @@ func handleEditArgs(connectionFilePath, secretKeyFilePath string, args []string,
 	}
 	if v := strings.TrimSpace(*newPassword); v != "" {
 		updated.Password = v
+		updated.PasswordSource = ""
+	}
+	if v := strings.TrimSpace(*newPasswordSource); v != "" {
+		updated.PasswordSource = v
 	}
 	if v := strings.TrimSpace(*newIdentityFile); v != "" {
 		updated.IdentityFile = v
@@ -228,6 +250,11 @@ func handleEditArgs(connectionFilePath, secretKeyFilePath string, args []string,
 	} else if len(newRemoteForwards) > 0 {
 		updated.RemoteForwards = newRemoteForwards.Values()
 	}
+	if *clearDynamicForwards {
+		updated.DynamicForwards = nil
+	} else if len(newDynamicForwards) > 0 {
+		updated.DynamicForwards = newDynamicForwards.Values()
+	}
 	if *clearExtraSSHArgs {
 		updated.ExtraSSHArgs = nil
 	} else if len(newExtraSSHArgs) > 0 {
@@ -248,6 +275,11 @@ func handleEditArgs(connectionFilePath, secretKeyFilePath string, args []string,
 	} else if v := strings.TrimSpace(*newDescription); v != "" {
 		updated.Description = v
 	}
+	if *clearExtends {
+		updated.Extends = ""
+	} else if v := strings.TrimSpace(*newExtends); v != "" {
+		updated.Extends = v
+	}
 
 	normalized, err := normalizeImportedConnection(updated)
 	if err != nil {
@@ -256,6 +288,9 @@ func handleEditArgs(connectionFilePath, secretKeyFilePath string, args []string,
 
 	wasUpdated := false
 	if err := connStore.Update(func(liveConnFile *model.ConnectionFile) error {
+		if err := validateResolvedConnection(liveConnFile, normalized); err != nil {
+			return err
+		}
 		var updateErr error
 		wasUpdated, updateErr = liveConnFile.UpdateConnectionByID(current.ID, normalized)
 		return updateErr
@@ -266,6 +301,9 @@ func handleEditArgs(connectionFilePath, secretKeyFilePath string, args []string,
 		_, _ = fmt.Fprintln(out, notFoundMessage(selectedAlias, selectedID))
 		return nil
 	}
+	if err := trustLocalPasswordSource(filepath.Dir(connectionFilePath), *newPasswordSource); err != nil {
+		return err
+	}
 
 	_, _ = fmt.Fprintln(out, prompttext.DefaultPromptTexts.SuccessMessages.SSHConnectionUpdated)
 	return nil
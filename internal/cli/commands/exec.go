@@ -0,0 +1,365 @@
+package commands
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"github.com/emirhangumus/sshmanager/internal/config"
+	"github.com/emirhangumus/sshmanager/internal/model"
+	"github.com/emirhangumus/sshmanager/internal/nativessh"
+	"github.com/emirhangumus/sshmanager/internal/store"
+	prompttext "github.com/emirhangumus/sshmanager/internal/ui/prompt"
+)
+
+const defaultExecParallel = 8
+
+// execRunner runs command on conn and returns the remote exit code. A non-nil
+// error means the command could not be started at all.
+type execRunner func(conn *model.SSHConnection, command string, stdout, stderr io.Writer) (int, error)
+
+type execResult struct {
+	ID         string `json:"id"`
+	Alias      string `json:"alias,omitempty"`
+	Target     string `json:"target"`
+	ExitCode   int    `json:"exitCode"`
+	DurationMS int64  `json:"durationMs"`
+	Stdout     string `json:"stdout,omitempty"`
+	Stderr     string `json:"stderr,omitempty"`
+	Error      string `json:"error,omitempty"`
+}
+
+func HandleExec(connectionFilePath, secretKeyFilePath, configFilePath string, args []string) error {
+	return handleExec(connectionFilePath, secretKeyFilePath, configFilePath, args, os.Stdout, nil)
+}
+
+// handleExec runs a remote command on every selected connection. When runner
+// is nil it is chosen from the configured connect backend.
+func handleExec(connectionFilePath, secretKeyFilePath, configFilePath string, args []string, out io.Writer, runner execRunner) error {
+	flagArgs, commandArgs, hasSeparator := splitExecArgs(args)
+
+	fs := flag.NewFlagSet("exec", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	alias := fs.String("alias", "", "Connection alias")
+	id := fs.String("id", "", "Connection ID")
+	groupFilter := fs.String("group", "", "Run on every connection in group")
+	var tagFilters stringListFlag
+	fs.Var(&tagFilters, "tag", "Run on every connection with tag (repeatable)")
+	parallel := fs.Int("parallel", defaultExecParallel, "Maximum number of hosts to run on concurrently")
+	jsonOutput := fs.Bool("json", false, "Output machine-readable JSON results")
+
+	if err := fs.Parse(flagArgs); err != nil {
+		return err
+	}
+	if *parallel < 1 {
+		return errors.New("exec: --parallel must be at least 1")
+	}
+
+	positional := fs.Args()
+	filtering := strings.TrimSpace(*groupFilter) != "" || len(tagFilters.Values()) > 0
+	if !hasSeparator {
+		// Without "--" the first positional is an alias unless a selector
+		// flag was given; everything else is the remote command.
+		if strings.TrimSpace(*alias) == "" && strings.TrimSpace(*id) == "" && !filtering && len(positional) > 0 {
+			commandArgs = positional[1:]
+			positional = positional[:1]
+		} else {
+			commandArgs = positional
+			positional = nil
+		}
+	}
+
+	selectedAlias, selectedID, err := resolveSelector(*alias, *id, positional, "exec")
+	if err != nil {
+		return err
+	}
+	if (selectedAlias != "" || selectedID != "") && filtering {
+		return errors.New("exec: use either a connection selector or --group/--tag, not both")
+	}
+	if selectedAlias == "" && selectedID == "" && !filtering {
+		return errors.New("exec: select connections with <alias>, --alias, --id, --group or --tag")
+	}
+
+	command := strings.TrimSpace(strings.Join(commandArgs, " "))
+	if command == "" {
+		return errors.New("exec: remote command is required (e.g. sshmanager exec web -- uptime)")
+	}
+
+	connStore := store.NewConnectionStore(connectionFilePath, secretKeyFilePath)
+	connFile, err := connStore.Load()
+	if err != nil {
+		return err
+	}
+
+	var targets []model.SSHConnection
+	if selectedAlias != "" || selectedID != "" {
+		conn := findConnectionBySelector(&connFile, selectedAlias, selectedID)
+		if conn == nil {
+			return errors.New(notFoundMessage(selectedAlias, selectedID))
+		}
+		targets = append(targets, *conn)
+	} else {
+		for _, conn := range connFile.Connections {
+			if matchesListFilters(conn, *groupFilter, tagFilters.Values()) {
+				targets = append(targets, conn)
+			}
+		}
+	}
+	if len(targets) == 0 {
+		return errors.New(prompttext.DefaultPromptTexts.ErrorMessages.NoSSHConnectionsFound)
+	}
+	targets, err = resolveConnections(&connFile, targets)
+	if err != nil {
+		return err
+	}
+
+	if runner == nil {
+		cfg, err := config.LoadConfig(configFilePath)
+		if err != nil {
+			return err
+		}
+		vaultDir := filepath.Dir(connectionFilePath)
+		runner = func(conn *model.SSHConnection, command string, stdout, stderr io.Writer) (int, error) {
+			return runExecOpenSSH(vaultDir, conn, command, stdout, stderr)
+		}
+		if cfg.Connect.EffectiveBackend() == config.ConnectBackendNative {
+			runner = func(conn *model.SSHConnection, command string, stdout, stderr io.Writer) (int, error) {
+				return runExecNative(vaultDir, conn, command, stdout, stderr)
+			}
+		}
+	}
+
+	results := runOnTargets(targets, *parallel, out, *jsonOutput, func(conn *model.SSHConnection, stdout, stderr io.Writer) (int, error) {
+		return runner(conn, command, stdout, stderr)
+	})
+
+	if *jsonOutput {
+		enc := json.NewEncoder(out)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(results); err != nil {
+			return err
+		}
+	} else {
+		if err := printExecSummary(out, results); err != nil {
+			return err
+		}
+	}
+
+	failed := 0
+	for _, result := range results {
+		if result.ExitCode != 0 || result.Error != "" {
+			failed++
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("exec: command failed on %d of %d hosts", failed, len(results))
+	}
+	return nil
+}
+
+// splitExecArgs splits args at the first "--" into flag/selector arguments
+// and the remote command.
+func splitExecArgs(args []string) ([]string, []string, bool) {
+	for i, arg := range args {
+		if arg == "--" {
+			return args[:i], args[i+1:], true
+		}
+	}
+	return args, nil, false
+}
+
+// runOnTargets calls run for every target with at most parallel hosts in
+// flight. Results keep the order of targets. Unless capture is set, output is
+// streamed line by line with a per-host prefix.
+func runOnTargets(targets []model.SSHConnection, parallel int, out io.Writer, capture bool, run func(conn *model.SSHConnection, stdout, stderr io.Writer) (int, error)) []execResult {
+	results := make([]execResult, len(targets))
+	label := execHostLabels(targets)
+
+	var outMu sync.Mutex
+	sem := make(chan struct{}, parallel)
+	var wg sync.WaitGroup
+	for i := range targets {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			conn := &targets[i]
+			result := execResult{
+				ID:     conn.ID,
+				Alias:  strings.TrimSpace(conn.Alias),
+				Target: fmt.Sprintf("%s@%s", conn.Username, conn.Host),
+			}
+
+			var stdoutBuf, stderrBuf bytes.Buffer
+			var stdout, stderr io.Writer = &stdoutBuf, &stderrBuf
+			var stdoutPrefix, stderrPrefix *prefixWriter
+			if !capture {
+				stdoutPrefix = newPrefixWriter(out, &outMu, "["+label[i]+"] ")
+				stderrPrefix = newPrefixWriter(out, &outMu, "["+label[i]+"] ")
+				stdout, stderr = stdoutPrefix, stderrPrefix
+			}
+
+			start := time.Now()
+			code, err := run(conn, stdout, stderr)
+			result.DurationMS = time.Since(start).Milliseconds()
+			result.ExitCode = code
+			if err != nil {
+				result.Error = err.Error()
+				if result.ExitCode == 0 {
+					result.ExitCode = -1
+				}
+			}
+
+			if capture {
+				result.Stdout = stdoutBuf.String()
+				result.Stderr = stderrBuf.String()
+			} else {
+				stdoutPrefix.Flush()
+				stderrPrefix.Flush()
+			}
+			results[i] = result
+		}(i)
+	}
+	wg.Wait()
+	return results
+}
+
+func execHostLabels(targets []model.SSHConnection) []string {
+	labels := make([]string, len(targets))
+	for i, conn := range targets {
+		if alias := strings.TrimSpace(conn.Alias); alias != "" {
+			labels[i] = alias
+		} else {
+			labels[i] = fmt.Sprintf("%s@%s", conn.Username, conn.Host)
+		}
+	}
+	return labels
+}
+
+func printExecSummary(out io.Writer, results []execResult) error {
+	_, _ = fmt.Fprintln(out)
+	tw := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(tw, "HOST\tTARGET\tEXIT\tDURATION\tERROR")
+	for _, result := range results {
+		host := result.Alias
+		if host == "" {
+			host = "-"
+		}
+		_, _ = fmt.Fprintf(tw, "%s\t%s\t%d\t%s\t%s\n",
+			host,
+			result.Target,
+			result.ExitCode,
+			(time.Duration(result.DurationMS) * time.Millisecond).String(),
+			result.Error,
+		)
+	}
+	return tw.Flush()
+}
+
+// runExecOpenSSH runs command with ssh. Port forwards are left out: exec
+// only needs the session, and hosts sharing a forward port would otherwise
+// fail to bind when run in parallel.
+func runExecOpenSSH(vaultDir string, conn *model.SSHConnection, command string, stdout, stderr io.Writer) (int, error) {
+	execConn := withoutForwards(*conn)
+	bin, args, envAdd, err := buildConnectInvocation(vaultDir, &execConn)
+	if err != nil {
+		return 0, err
+	}
+	return runInvocation(bin, append(args, command), envAdd, nil, stdout, stderr)
+}
+
+// runInvocation runs an ssh/scp/sshpass invocation and returns its exit code.
+// A non-nil error means the binary could not be found or started.
+func runInvocation(bin string, args, envAdd []string, stdin io.Reader, stdout, stderr io.Writer) (int, error) {
+	binPath, err := exec.LookPath(bin)
+	if err != nil {
+		if bin == "sshpass" {
+			return 0, errors.New(prompttext.DefaultPromptTexts.ErrorMessages.SSHPassNotFound)
+		}
+		return 0, fmt.Errorf("required command %q not found in PATH", bin)
+	}
+
+	cmd := exec.Command(binPath, args...)
+	cmd.Stdin = stdin
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+	cmd.Env = append(os.Environ(), envAdd...)
+
+	if err := cmd.Run(); err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			return exitErr.ExitCode(), nil
+		}
+		return 0, err
+	}
+	return 0, nil
+}
+
+func runExecNative(vaultDir string, conn *model.SSHConnection, command string, stdout, stderr io.Writer) (int, error) {
+	client, err := nativessh.Dial(conn, nativessh.Options{
+		KnownHostsFile: nativeKnownHostsFile(vaultDir),
+		Password: func() (string, error) {
+			return resolveConnectionPassword(vaultDir, conn)
+		},
+	})
+	if err != nil {
+		return 0, err
+	}
+	defer client.Close()
+
+	return client.Run(command, nil, stdout, stderr)
+}
+
+// prefixWriter writes complete lines to out, each preceded by prefix, so
+// output from concurrently running hosts never interleaves mid-line.
+type prefixWriter struct {
+	out    io.Writer
+	mu     *sync.Mutex
+	prefix string
+	buf    []byte
+}
+
+func newPrefixWriter(out io.Writer, mu *sync.Mutex, prefix string) *prefixWriter {
+	return &prefixWriter{out: out, mu: mu, prefix: prefix}
+}
+
+func (w *prefixWriter) Write(p []byte) (int, error) {
+	w.buf = append(w.buf, p...)
+	for {
+		idx := bytes.IndexByte(w.buf, '\n')
+		if idx < 0 {
+			break
+		}
+		w.writeLine(w.buf[:idx+1])
+		w.buf = w.buf[idx+1:]
+	}
+	return len(p), nil
+}
+
+// Flush writes any trailing output that did not end in a newline.
+func (w *prefixWriter) Flush() {
+	if len(w.buf) == 0 {
+		return
+	}
+	w.writeLine(append(w.buf, '\n'))
+	w.buf = nil
+}
+
+func (w *prefixWriter) writeLine(line []byte) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	_, _ = io.WriteString(w.out, w.prefix)
+	_, _ = w.out.Write(line)
+}
@@ -0,0 +1,226 @@
+package commands
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/emirhangumus/sshmanager/internal/model"
+)
+
+func execFixtureConnections() []model.SSHConnection {
+	return []model.SSHConnection{
+		{Username: "ubuntu", Host: "web1.internal", Alias: "web1", Group: "production", Tags: []string{"web"}},
+		{Username: "ubuntu", Host: "web2.internal", Alias: "web2", Group: "production", Tags: []string{"web"}},
+		{Username: "postgres", Host: "db.internal", Alias: "db", Group: "production", Tags: []string{"db"}},
+		{Username: "dev", Host: "dev.internal", Alias: "dev", Group: "staging", Tags: []string{"web"}},
+	}
+}
+
+type recordingRunner struct {
+	mu    sync.Mutex
+	calls []string
+	codes map[string]int
+}
+
+func (r *recordingRunner) run(conn *model.SSHConnection, command string, stdout, stderr io.Writer) (int, error) {
+	r.mu.Lock()
+	r.calls = append(r.calls, conn.Alias+": "+command)
+	r.mu.Unlock()
+
+	_, _ = fmt.Fprintf(stdout, "hello from %s\npartial", conn.Host)
+	if code := r.codes[conn.Alias]; code != 0 {
+		_, _ = fmt.Fprintf(stderr, "boom %d\n", code)
+		return code, nil
+	}
+	return 0, nil
+}
+
+func (r *recordingRunner) sortedCalls() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	calls := append([]string(nil), r.calls...)
+	sort.Strings(calls)
+	return calls
+}
+
+func TestHandleExecGroupPrefixesOutputAndPrintsSummary(t *testing.T) {
+	connPath, keyPath := prepareListFixture(t, execFixtureConnections())
+	runner := &recordingRunner{}
+
+	var out strings.Builder
+	err := handleExec(connPath, keyPath, "", []string{"--group", "production", "--tag", "web", "--parallel", "2", "--", "uptime", "-p"}, &out, runner.run)
+	if err != nil {
+		t.Fatalf("handleExec failed: %v", err)
+	}
+
+	assertStringSliceEqual(t, runner.sortedCalls(), []string{"web1: uptime -p", "web2: uptime -p"})
+	for _, snippet := range []string{
+		"[web1] hello from web1.internal\n",
+		"[web1] partial\n",
+		"[web2] hello from web2.internal\n",
+	} {
+		if !strings.Contains(out.String(), snippet) {
+			t.Fatalf("expected output to include %q, got %q", snippet, out.String())
+		}
+	}
+
+	lines := strings.Split(strings.TrimRight(out.String(), "\n"), "\n")
+	summary := lines[len(lines)-3:]
+	if fields := strings.Fields(summary[0]); !slicesEqual(fields, []string{"HOST", "TARGET", "EXIT", "DURATION", "ERROR"}) {
+		t.Fatalf("unexpected summary header: %q", summary[0])
+	}
+	if fields := strings.Fields(summary[1]); len(fields) < 3 || fields[0] != "web1" || fields[1] != "ubuntu@web1.internal" || fields[2] != "0" {
+		t.Fatalf("unexpected summary row: %q", summary[1])
+	}
+}
+
+func TestHandleExecJSONReportsFailures(t *testing.T) {
+	connPath, keyPath := prepareListFixture(t, execFixtureConnections())
+	runner := &recordingRunner{codes: map[string]int{"db": 3}}
+
+	var out strings.Builder
+	err := handleExec(connPath, keyPath, "", []string{"--group", "production", "--json", "--", "hostname"}, &out, runner.run)
+	if err == nil || !strings.Contains(err.Error(), "failed on 1 of 3 hosts") {
+		t.Fatalf("expected failure summary error, got %v", err)
+	}
+
+	var results []execResult
+	if err := json.Unmarshal([]byte(out.String()), &results); err != nil {
+		t.Fatalf("failed to decode JSON output %q: %v", out.String(), err)
+	}
+	if len(results) != 3 {
+		t.Fatalf("expected 3 results, got %d", len(results))
+	}
+	if results[2].Alias != "db" || results[2].ExitCode != 3 || results[2].Stderr != "boom 3\n" {
+		t.Fatalf("unexpected db result: %+v", results[2])
+	}
+	if results[0].Alias != "web1" || results[0].ExitCode != 0 || results[0].Stdout != "hello from web1.internal\npartial" {
+		t.Fatalf("unexpected web1 result: %+v", results[0])
+	}
+}
+
+func TestHandleExecAliasWithoutSeparator(t *testing.T) {
+	connPath, keyPath := prepareListFixture(t, execFixtureConnections())
+	runner := &recordingRunner{}
+
+	if err := handleExec(connPath, keyPath, "", []string{"db", "systemctl", "status", "postgresql"}, ioDiscard(), runner.run); err != nil {
+		t.Fatalf("handleExec failed: %v", err)
+	}
+	assertStringSliceEqual(t, runner.sortedCalls(), []string{"db: systemctl status postgresql"})
+}
+
+func TestHandleExecRejectsInvalidArguments(t *testing.T) {
+	connPath, keyPath := prepareListFixture(t, execFixtureConnections())
+	runner := &recordingRunner{}
+
+	tests := []struct {
+		args []string
+		want string
+	}{
+		{args: []string{"--", "uptime"}, want: "select connections"},
+		{args: []string{"db"}, want: "remote command is required"},
+		{args: []string{"--alias", "db", "--group", "production", "--", "uptime"}, want: "not both"},
+		{args: []string{"--group", "production", "--parallel", "0", "--", "uptime"}, want: "--parallel"},
+		{args: []string{"missing", "--", "uptime"}, want: "missing"},
+		{args: []string{"--group", "nope", "--", "uptime"}, want: "No SSH connections"},
+	}
+	for _, tc := range tests {
+		err := handleExec(connPath, keyPath, "", tc.args, ioDiscard(), runner.run)
+		if err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Fatalf("handleExec(%v) error = %v, want containing %q", tc.args, err, tc.want)
+		}
+	}
+	if calls := runner.sortedCalls(); len(calls) != 0 {
+		t.Fatalf("expected no remote runs, got %v", calls)
+	}
+}
+
+func TestRunExecOpenSSHUsesConnectInvocation(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("fake ssh binary is a shell script")
+	}
+
+	binDir := t.TempDir()
+	script := "#!/bin/sh\necho \"args: $*\"\necho oops >&2\nexit 7\n"
+	if err := os.WriteFile(filepath.Join(binDir, "ssh"), []byte(script), 0o755); err != nil {
+		t.Fatalf("failed to write fake ssh: %v", err)
+	}
+	t.Setenv("PATH", binDir)
+
+	conn := &model.SSHConnection{
+		Username:  "ubuntu",
+		Host:      "example.com",
+		Port:      2222,
+		AuthMode:  model.AuthModeAgent,
+		ProxyJump: "bastion",
+	}
+
+	var stdout, stderr strings.Builder
+	code, err := runExecOpenSSH("", conn, "uptime -p", &stdout, &stderr)
+	if err != nil {
+		t.Fatalf("runExecOpenSSH failed: %v", err)
+	}
+	if code != 7 {
+		t.Fatalf("expected exit code 7, got %d", code)
+	}
+	if stdout.String() != "args: -p 2222 -J bastion ubuntu@example.com uptime -p\n" {
+		t.Fatalf("unexpected ssh args: %q", stdout.String())
+	}
+	if stderr.String() != "oops\n" {
+		t.Fatalf("unexpected stderr: %q", stderr.String())
+	}
+}
+
+func TestHandleExecOpenSSHSkipsForwards(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("fake ssh binary is a shell script")
+	}
+
+	binDir := t.TempDir()
+	script := "#!/bin/sh\nfor arg in \"$@\"; do case \"$arg\" in -L|-R|-D) echo \"bind: Address already in use\" >&2; exit 255;; esac; done\necho ok\n"
+	if err := os.WriteFile(filepath.Join(binDir, "ssh"), []byte(script), 0o755); err != nil {
+		t.Fatalf("failed to write fake ssh: %v", err)
+	}
+	t.Setenv("PATH", binDir)
+
+	connPath, keyPath := prepareListFixture(t, []model.SSHConnection{
+		{Username: "ubuntu", Host: "web1.internal", Alias: "web1", AuthMode: model.AuthModeAgent, Group: "web", LocalForwards: []string{"8080:localhost:80"}, DynamicForwards: []string{"1080"}},
+		{Username: "ubuntu", Host: "web2.internal", Alias: "web2", AuthMode: model.AuthModeAgent, Group: "web", LocalForwards: []string{"8080:localhost:80"}, RemoteForwards: []string{"9000:localhost:9000"}},
+	})
+
+	var out strings.Builder
+	if err := handleExec(connPath, keyPath, "", []string{"--group", "web", "--parallel", "2", "--json", "--", "true"}, &out, nil); err != nil {
+		t.Fatalf("handleExec failed: %v\n%s", err, out.String())
+	}
+	var results []execResult
+	if err := json.Unmarshal([]byte(out.String()), &results); err != nil {
+		t.Fatalf("invalid exec JSON: %v", err)
+	}
+	if len(results) != 2 || results[0].ExitCode != 0 || results[1].ExitCode != 0 {
+		t.Fatalf("expected both hosts to succeed without forwards, got %+v", results)
+	}
+}
+
+func TestPrefixWriterBuffersPartialLines(t *testing.T) {
+	var out strings.Builder
+	var mu sync.Mutex
+	w := newPrefixWriter(&out, &mu, "[a] ")
+
+	_, _ = w.Write([]byte("one\ntw"))
+	_, _ = w.Write([]byte("o\nthree"))
+	if out.String() != "[a] one\n[a] two\n" {
+		t.Fatalf("unexpected output before flush: %q", out.String())
+	}
+	w.Flush()
+	if out.String() != "[a] one\n[a] two\n[a] three\n" {
+		t.Fatalf("unexpected output after flush: %q", out.String())
+	}
+}
@@ -0,0 +1,174 @@
+package commands
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/emirhangumus/sshmanager/internal/config"
+	"github.com/emirhangumus/sshmanager/internal/model"
+	"github.com/emirhangumus/sshmanager/internal/store"
+)
+
+const defaultHistoryLimit = 50
+
+type historyOutputItem struct {
+	ConnectionID string    `json:"connectionId"`
+	Alias        string    `json:"alias,omitempty"`
+	Target       string    `json:"target"`
+	StartedAt    time.Time `json:"startedAt"`
+	EndedAt      time.Time `json:"endedAt"`
+	DurationMS   int64     `json:"durationMs"`
+	ExitCode     int       `json:"exitCode"`
+	Backend      string    `json:"backend"`
+	Error        string    `json:"error,omitempty"`
+}
+
+func HandleHistory(connectionFilePath, secretKeyFilePath string, args []string) error {
+	return handleHistory(connectionFilePath, secretKeyFilePath, args, os.Stdout, time.Now())
+}
+
+func handleHistory(connectionFilePath, secretKeyFilePath string, args []string, out io.Writer, now time.Time) error {
+	fs := flag.NewFlagSet("history", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	alias := fs.String("alias", "", "Only show entries for connection alias")
+	id := fs.String("id", "", "Only show entries for connection ID")
+	since := fs.String("since", "", "Only show entries newer than a duration (e.g. 24h) or RFC3339/YYYY-MM-DD time")
+	backend := fs.String("backend", "", "Only show entries for backend (openssh|native)")
+	failed := fs.Bool("failed", false, "Only show sessions that exited non-zero or failed to start")
+	limit := fs.Int("limit", defaultHistoryLimit, "Maximum number of entries to show (0 = all)")
+	jsonOutput := fs.Bool("json", false, "Output JSON")
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	selectedAlias, selectedID, err := resolveSelector(*alias, *id, fs.Args(), "history")
+	if err != nil {
+		return err
+	}
+	if *limit < 0 {
+		return errors.New("history: --limit must not be negative")
+	}
+
+	var sinceTime time.Time
+	if strings.TrimSpace(*since) != "" {
+		sinceTime, err = parseHistorySince(*since, now)
+		if err != nil {
+			return err
+		}
+	}
+	backendFilter := strings.ToLower(strings.TrimSpace(*backend))
+	if backendFilter != "" && backendFilter != config.ConnectBackendOpenSSH && backendFilter != config.ConnectBackendNative {
+		return fmt.Errorf("history: invalid --backend %q, expected 'openssh' or 'native'", *backend)
+	}
+
+	// Alias filters resolve to the current connection ID so history follows
+	// renames; entries of removed connections still match their old alias.
+	if selectedAlias != "" {
+		connFile, err := store.NewConnectionStore(connectionFilePath, secretKeyFilePath).Load()
+		if err != nil {
+			return err
+		}
+		if conn := connFile.GetConnectionByAlias(selectedAlias); conn != nil {
+			selectedID = conn.ID
+		}
+	}
+
+	history, err := store.NewHistoryStore(connectionFilePath, secretKeyFilePath).Load()
+	if err != nil {
+		return err
+	}
+
+	items := make([]historyOutputItem, 0, len(history.Entries))
+	for i := len(history.Entries) - 1; i >= 0; i-- {
+		entry := history.Entries[i]
+		if selectedID != "" && entry.ConnectionID != selectedID {
+			continue
+		}
+		if selectedID == "" && selectedAlias != "" && !strings.EqualFold(entry.Alias, selectedAlias) {
+			continue
+		}
+		if !sinceTime.IsZero() && entry.StartedAt.Before(sinceTime) {
+			continue
+		}
+		if backendFilter != "" && entry.Backend != backendFilter {
+			continue
+		}
+		if *failed && entry.ExitCode == 0 && entry.Error == "" {
+			continue
+		}
+		items = append(items, historyEntryOutput(entry))
+		if *limit > 0 && len(items) >= *limit {
+			break
+		}
+	}
+
+	if *jsonOutput {
+		enc := json.NewEncoder(out)
+		enc.SetIndent("", "  ")
+		return enc.Encode(items)
+	}
+
+	if len(items) == 0 {
+		_, _ = fmt.Fprintln(out, "No connection history found.")
+		return nil
+	}
+
+	tw := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(tw, "STARTED\tALIAS\tTARGET\tDURATION\tEXIT\tBACKEND")
+	for _, item := range items {
+		aliasValue := item.Alias
+		if aliasValue == "" {
+			aliasValue = "-"
+		}
+		_, _ = fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%d\t%s\n",
+			item.StartedAt.Local().Format("2006-01-02 15:04:05"),
+			aliasValue,
+			item.Target,
+			(time.Duration(item.DurationMS) * time.Millisecond).Round(time.Second).String(),
+			item.ExitCode,
+			item.Backend,
+		)
+	}
+	return tw.Flush()
+}
+
+func historyEntryOutput(entry model.HistoryEntry) historyOutputItem {
+	duration := entry.EndedAt.Sub(entry.StartedAt)
+	if duration < 0 {
+		duration = 0
+	}
+	return historyOutputItem{
+		ConnectionID: entry.ConnectionID,
+		Alias:        entry.Alias,
+		Target:       entry.Target,
+		StartedAt:    entry.StartedAt,
+		EndedAt:      entry.EndedAt,
+		DurationMS:   duration.Milliseconds(),
+		ExitCode:     entry.ExitCode,
+		Backend:      entry.Backend,
+		Error:        entry.Error,
+	}
+}
+
+// parseHistorySince accepts a Go duration relative to now, an RFC3339
+// timestamp or a YYYY-MM-DD date in local time.
+func parseHistorySince(value string, now time.Time) (time.Time, error) {
+	trimmed := strings.TrimSpace(value)
+	if d, err := time.ParseDuration(trimmed); err == nil {
+		return now.Add(-d), nil
+	}
+	if t, err := time.Parse(time.RFC3339, trimmed); err == nil {
+		return t, nil
+	}
+	if t, err := time.ParseInLocation("2006-01-02", trimmed, time.Local); err == nil {
+		return t, nil
+	}
+	return time.Time{}, fmt.Errorf("history: invalid --since %q, expected a duration (e.g. 24h), RFC3339 time or YYYY-MM-DD", value)
+}
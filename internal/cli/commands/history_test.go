@@ -0,0 +1,206 @@
+package commands
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/emirhangumus/sshmanager/internal/config"
+	"github.com/emirhangumus/sshmanager/internal/model"
+	"github.com/emirhangumus/sshmanager/internal/store"
+)
+
+func seedHistory(t *testing.T, connPath, keyPath string, entries []model.HistoryEntry) {
+	t.Helper()
+	historyStore := store.NewHistoryStore(connPath, keyPath)
+	for _, entry := range entries {
+		if err := historyStore.Append(entry); err != nil {
+			t.Fatalf("Append failed: %v", err)
+		}
+	}
+}
+
+func historyFixture(t *testing.T) (string, string, model.ConnectionFile, time.Time) {
+	t.Helper()
+	connPath, keyPath := prepareListFixture(t, execFixtureConnections())
+	connFile := loadTransferConnections(t, connPath, keyPath)
+	now := time.Date(2026, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	web1 := connFile.GetConnectionByAlias("web1").ID
+	db := connFile.GetConnectionByAlias("db").ID
+	seedHistory(t, connPath, keyPath, []model.HistoryEntry{
+		{ConnectionID: web1, Alias: "web1", Target: "ubuntu@web1.internal", StartedAt: now.Add(-72 * time.Hour), EndedAt: now.Add(-71 * time.Hour), Backend: "openssh"},
+		{ConnectionID: db, Alias: "db", Target: "postgres@db.internal", StartedAt: now.Add(-2 * time.Hour), EndedAt: now.Add(-2*time.Hour + time.Minute), ExitCode: 255, Backend: "openssh"},
+		{ConnectionID: web1, Alias: "web1", Target: "ubuntu@web1.internal", StartedAt: now.Add(-time.Hour), EndedAt: now.Add(-time.Hour + 90*time.Second), Backend: "native"},
+		{ConnectionID: web1, Alias: "web1", Target: "ubuntu@web1.internal", StartedAt: now.Add(-30 * time.Minute), EndedAt: now.Add(-29 * time.Minute), Backend: "openssh"},
+	})
+	return connPath, keyPath, connFile, now
+}
+
+func TestHandleHistoryTextOutput(t *testing.T) {
+	connPath, keyPath, _, now := historyFixture(t)
+
+	var out strings.Builder
+	if err := handleHistory(connPath, keyPath, []string{"--since", "3h"}, &out, now); err != nil {
+		t.Fatalf("handleHistory failed: %v", err)
+	}
+
+	lines := strings.Split(strings.TrimRight(out.String(), "\n"), "\n")
+	if len(lines) != 4 {
+		t.Fatalf("expected header + 3 rows, got %q", out.String())
+	}
+	if fields := strings.Fields(lines[0]); !slicesEqual(fields, []string{"STARTED", "ALIAS", "TARGET", "DURATION", "EXIT", "BACKEND"}) {
+		t.Fatalf("unexpected header: %q", lines[0])
+	}
+	if fields := strings.Fields(lines[1]); fields[2] != "web1" || fields[4] != "1m0s" || fields[5] != "0" {
+		t.Fatalf("expected newest entry first, got %q", lines[1])
+	}
+	if fields := strings.Fields(lines[3]); fields[2] != "db" || fields[5] != "255" {
+		t.Fatalf("unexpected oldest row: %q", lines[3])
+	}
+}
+
+func TestHandleHistoryFiltersAndJSON(t *testing.T) {
+	connPath, keyPath, connFile, now := historyFixture(t)
+
+	tests := []struct {
+		args      []string
+		wantCount int
+	}{
+		{args: []string{"web1"}, wantCount: 3},
+		{args: []string{"--id", connFile.GetConnectionByAlias("db").ID}, wantCount: 1},
+		{args: []string{"--failed"}, wantCount: 1},
+		{args: []string{"--backend", "native"}, wantCount: 1},
+		{args: []string{"--limit", "2"}, wantCount: 2},
+		{args: []string{"--since", "2026-04-01"}, wantCount: 4},
+		{args: []string{"dev"}, wantCount: 0},
+	}
+	for _, tc := range tests {
+		var out strings.Builder
+		if err := handleHistory(connPath, keyPath, append([]string{"--json"}, tc.args...), &out, now); err != nil {
+			t.Fatalf("handleHistory(%v) failed: %v", tc.args, err)
+		}
+		var items []historyOutputItem
+		if err := json.Unmarshal([]byte(out.String()), &items); err != nil {
+			t.Fatalf("failed to decode JSON for %v: %v", tc.args, err)
+		}
+		if len(items) != tc.wantCount {
+			t.Fatalf("handleHistory(%v) returned %d items, want %d", tc.args, len(items), tc.wantCount)
+		}
+	}
+
+	if err := handleHistory(connPath, keyPath, []string{"--since", "yesterday"}, ioDiscard(), now); err == nil {
+		t.Fatal("expected invalid --since error")
+	}
+	if err := handleHistory(connPath, keyPath, []string{"--backend", "putty"}, ioDiscard(), now); err == nil {
+		t.Fatal("expected invalid --backend error")
+	}
+}
+
+func TestHandleListSortByUsage(t *testing.T) {
+	connPath, keyPath, _, _ := historyFixture(t)
+
+	var out strings.Builder
+	if err := handleList(connPath, keyPath, []string{"--sort", "use-count", "--field", "alias"}, &out); err != nil {
+		t.Fatalf("handleList failed: %v", err)
+	}
+	assertStringSliceEqual(t, strings.Fields(out.String()), []string{"web1", "db", "web2", "dev"})
+
+	out.Reset()
+	if err := handleList(connPath, keyPath, []string{"--sort", "last-used", "--json"}, &out); err != nil {
+		t.Fatalf("handleList failed: %v", err)
+	}
+	var items []listOutputItem
+	if err := json.Unmarshal([]byte(out.String()), &items); err != nil {
+		t.Fatalf("failed to decode JSON: %v", err)
+	}
+	if items[0].Alias != "web1" || items[0].UseCount != 3 || items[0].LastUsed == nil {
+		t.Fatalf("unexpected first item: %+v", items[0])
+	}
+	if items[2].LastUsed != nil || items[2].UseCount != 0 {
+		t.Fatalf("expected never-used connection without usage, got %+v", items[2])
+	}
+
+	if err := handleList(connPath, keyPath, []string{"--sort", "name"}, ioDiscard()); err == nil {
+		t.Fatal("expected unknown sort error")
+	}
+}
+
+func TestConnectPickerItemsPutsRecentFirst(t *testing.T) {
+	_, _, connFile, now := historyFixture(t)
+	history := model.NewHistoryFile()
+	history.Append(model.HistoryEntry{ConnectionID: connFile.GetConnectionByAlias("dev").ID, StartedAt: now})
+	history.Append(model.HistoryEntry{ConnectionID: connFile.GetConnectionByAlias("db").ID, StartedAt: now.Add(time.Minute)})
+	history.Append(model.HistoryEntry{ConnectionID: connFile.GetConnectionByAlias("db").ID, StartedAt: now.Add(2 * time.Minute)})
+	history.Append(model.HistoryEntry{ConnectionID: "removed", StartedAt: now.Add(3 * time.Minute)})
+
+	items, recency := connectPickerItems(connFile.SelectItems(), &history)
+	if len(items) != 4 {
+		t.Fatalf("expected every connection exactly once, got %d items", len(items))
+	}
+	if !strings.HasPrefix(items[0].Label, recentPickerPrefix) || !strings.Contains(items[0].Label, "(db)") {
+		t.Fatalf("expected db first as recent, got %q", items[0].Label)
+	}
+	if !strings.HasPrefix(items[1].Label, recentPickerPrefix) || !strings.Contains(items[1].Label, "(dev)") {
+		t.Fatalf("expected dev second as recent, got %q", items[1].Label)
+	}
+	if strings.HasPrefix(items[2].Label, recentPickerPrefix) || !strings.Contains(items[2].Label, "(web1)") {
+		t.Fatalf("expected remaining items in stored order, got %q", items[2].Label)
+	}
+	assertIntSliceEqual(t, recency, []int{2, 1, 0, 0})
+}
+
+func TestConnectAndRecordAppendsHistory(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("fake ssh binary is a shell script")
+	}
+
+	binDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(binDir, "ssh"), []byte("#!/bin/sh\nexit 5\n"), 0o755); err != nil {
+		t.Fatalf("failed to write fake ssh: %v", err)
+	}
+	t.Setenv("PATH", binDir)
+
+	connPath, keyPath := prepareListFixture(t, execFixtureConnections())
+	connFile := loadTransferConnections(t, connPath, keyPath)
+	conn := connFile.GetConnectionByAlias("web2")
+
+	cfg := config.Default()
+	if err := connectAndRecord(connPath, keyPath, conn, &cfg); err == nil {
+		t.Fatal("expected non-zero ssh exit to be reported")
+	}
+
+	history, err := store.NewHistoryStore(connPath, keyPath).Load()
+	if err != nil {
+		t.Fatalf("Load history failed: %v", err)
+	}
+	if len(history.Entries) != 1 {
+		t.Fatalf("expected one history entry, got %d", len(history.Entries))
+	}
+	entry := history.Entries[0]
+	if entry.ConnectionID != conn.ID || entry.Alias != "web2" || entry.Target != "ubuntu@web2.internal" {
+		t.Fatalf("unexpected entry identity: %+v", entry)
+	}
+	if entry.ExitCode != 5 || entry.Backend != config.ConnectBackendOpenSSH {
+		t.Fatalf("unexpected entry outcome: %+v", entry)
+	}
+	if entry.StartedAt.IsZero() || entry.EndedAt.Before(entry.StartedAt) {
+		t.Fatalf("unexpected entry times: %+v", entry)
+	}
+}
+
+func assertIntSliceEqual(t *testing.T, got, want []int) {
+	t.Helper()
+	if len(got) != len(want) {
+		t.Fatalf("slice length mismatch: got=%v want=%v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("slice mismatch at %d: got=%v want=%v", i, got, want)
+		}
+	}
+}
@@ -0,0 +1,114 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/emirhangumus/sshmanager/internal/model"
+	"github.com/emirhangumus/sshmanager/internal/storage"
+)
+
+// managedKnownHostsPath is the sshmanager-owned known_hosts file of a vault.
+func managedKnownHostsPath(vaultDir string) string {
+	return filepath.Join(vaultDir, "known_hosts")
+}
+
+// nativeKnownHostsFile is the known_hosts file the native backend checks
+// targets without pinned host keys against. Without a vault it is empty and
+// the native client falls back to ~/.ssh/known_hosts.
+func nativeKnownHostsFile(vaultDir string) string {
+	if strings.TrimSpace(vaultDir) == "" {
+		return ""
+	}
+	return managedKnownHostsPath(vaultDir)
+}
+
+// pinnedKnownHostsPath is the known_hosts file generated for the pinned
+// host keys of conn.
+func pinnedKnownHostsPath(vaultDir string, conn *model.SSHConnection) string {
+	return filepath.Join(vaultDir, "known_hosts.d", pinnedKnownHostsFileName(conn))
+}
+
+// hostKeyArgs returns the ssh options that select the known_hosts file for
+// conn in the vault in vaultDir: the generated file of its pinned keys, or
+// the managed file. Without a vault ssh keeps its own known_hosts. The
+// options come before any extra ssh args because ssh keeps the first value
+// it sees for an option.
+func hostKeyArgs(vaultDir string, conn *model.SSHConnection) ([]string, error) {
+	if len(model.NormalizeStringList(conn.HostKeys)) > 0 {
+		return pinnedHostKeyArgs(vaultDir, conn)
+	}
+	if strings.TrimSpace(vaultDir) == "" {
+		return nil, nil
+	}
+	path := managedKnownHostsPath(vaultDir)
+	return []string{
+		"-o", sshPathOption("UserKnownHostsFile", path),
+		"-o", "HashKnownHosts=no",
+	}, nil
+}
+
+// pinnedHostKeyArgs writes the known_hosts file for conn's pinned host keys
+// and returns the ssh options that make ssh trust those keys and nothing
+// else.
+func pinnedHostKeyArgs(vaultDir string, conn *model.SSHConnection) ([]string, error) {
+	hostKeys := model.NormalizeStringList(conn.HostKeys)
+	if err := model.ValidateHostKeys(hostKeys); err != nil {
+		return nil, fmt.Errorf("invalid host keys: %w", err)
+	}
+	if strings.TrimSpace(vaultDir) == "" {
+		return nil, errors.New("no vault is configured for the known_hosts files of pinned host keys")
+	}
+
+	path := pinnedKnownHostsPath(vaultDir, conn)
+	if err := storage.WriteFileAtomic(path, []byte(pinnedKnownHosts(conn, hostKeys)), 0o600); err != nil {
+		return nil, fmt.Errorf("failed to write known_hosts for pinned host keys: %w", err)
+	}
+	return []string{
+		"-o", sshPathOption("UserKnownHostsFile", path),
+		"-o", "GlobalKnownHostsFile=" + os.DevNull,
+		"-o", "StrictHostKeyChecking=yes",
+		"-o", "UpdateHostKeys=no",
+	}, nil
+}
+
+// pinnedKnownHosts renders one known_hosts line per pinned key for the
+// host pattern ssh looks up for conn.
+func pinnedKnownHosts(conn *model.SSHConnection, hostKeys []string) string {
+	pattern := model.KnownHostsPattern(conn.Host, conn.EffectivePort())
+	var b strings.Builder
+	for _, key := range hostKeys {
+		normalized, _ := model.NormalizeHostKey(key)
+		b.WriteString(pattern + " " + normalized + "\n")
+	}
+	return b.String()
+}
+
+// pinnedKnownHostsFileName names the generated file after the connection
+// ID, reduced to characters that are safe in a file name.
+func pinnedKnownHostsFileName(conn *model.SSHConnection) string {
+	name := strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '-', r == '_', r == '.':
+			return r
+		default:
+			return '_'
+		}
+	}, strings.TrimSpace(conn.ID))
+	if strings.Trim(name, "._") == "" {
+		name = "connection"
+	}
+	return name
+}
+
+// sshPathOption formats a path-valued -o option, quoting paths with spaces
+// so ssh does not split them into several files.
+func sshPathOption(option, path string) string {
+	if strings.ContainsAny(path, " \t") {
+		return option + `="` + path + `"`
+	}
+	return option + "=" + path
+}
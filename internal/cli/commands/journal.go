@@ -0,0 +1,272 @@
+package commands
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/emirhangumus/sshmanager/internal/model"
+	"github.com/emirhangumus/sshmanager/internal/store"
+)
+
+const defaultLogLimit = 20
+
+type logChangeOutput struct {
+	Kind    string   `json:"kind"`
+	Key     string   `json:"key"`
+	Label   string   `json:"label"`
+	Action  string   `json:"action"`
+	Fields  []string `json:"fields,omitempty"`
+	Summary string   `json:"summary"`
+}
+
+type logOutputItem struct {
+	Revision int               `json:"revision"`
+	Time     time.Time         `json:"time"`
+	Actor    string            `json:"actor"`
+	Command  string            `json:"command,omitempty"`
+	Undone   bool              `json:"undone"`
+	Changes  []logChangeOutput `json:"changes"`
+}
+
+func HandleLog(connectionFilePath, secretKeyFilePath string, args []string) error {
+	return handleLog(connectionFilePath, secretKeyFilePath, args, os.Stdout)
+}
+
+func handleLog(connectionFilePath, secretKeyFilePath string, args []string, out io.Writer) error {
+	fs := flag.NewFlagSet("log", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	alias := fs.String("alias", "", "Only show changes to connection alias")
+	id := fs.String("id", "", "Only show changes to connection ID")
+	limit := fs.Int("limit", defaultLogLimit, "Maximum number of revisions to show (0 = all)")
+	jsonOutput := fs.Bool("json", false, "Output JSON")
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	selectedAlias, selectedID, err := resolveSelector(*alias, *id, fs.Args(), "log")
+	if err != nil {
+		return err
+	}
+	if *limit < 0 {
+		return errors.New("log: --limit must not be negative")
+	}
+
+	connStore := store.NewConnectionStore(connectionFilePath, secretKeyFilePath)
+	journal, err := connStore.Journal()
+	if err != nil {
+		return err
+	}
+
+	entries := journal.Entries
+	if selectedAlias != "" || selectedID != "" {
+		connID, err := journalConnectionID(connStore, &journal, selectedAlias, selectedID)
+		if err != nil {
+			return err
+		}
+		entries = journal.ForConnection(connID)
+	}
+
+	items := make([]logOutputItem, 0, len(entries))
+	for i := len(entries) - 1; i >= 0; i-- {
+		items = append(items, logEntryOutput(entries[i], journal.IsUndone(entries[i].Revision)))
+		if *limit > 0 && len(items) >= *limit {
+			break
+		}
+	}
+
+	if *jsonOutput {
+		enc := json.NewEncoder(out)
+		enc.SetIndent("", "  ")
+		return enc.Encode(items)
+	}
+
+	if len(items) == 0 {
+		_, _ = fmt.Fprintln(out, "No changes recorded.")
+		return nil
+	}
+	for _, item := range items {
+		header := fmt.Sprintf("r%d  %s  %s", item.Revision, item.Time.Local().Format("2006-01-02 15:04:05"), item.Actor)
+		if item.Command != "" {
+			header += "  " + item.Command
+		}
+		if item.Undone {
+			header += "  (undone)"
+		}
+		_, _ = fmt.Fprintln(out, header)
+		for _, change := range item.Changes {
+			_, _ = fmt.Fprintf(out, "    %s\n", change.Summary)
+		}
+	}
+	return nil
+}
+
+func logEntryOutput(entry model.JournalEntry, undone bool) logOutputItem {
+	changes := make([]logChangeOutput, 0, len(entry.Changes))
+	for _, change := range entry.Changes {
+		changes = append(changes, logChangeOutput{
+			Kind:    change.Kind,
+			Key:     change.Key,
+			Label:   change.Label,
+			Action:  change.Action(),
+			Fields:  change.Fields,
+			Summary: change.Summary(),
+		})
+	}
+	return logOutputItem{
+		Revision: entry.Revision,
+		Time:     entry.Time,
+		Actor:    entry.Actor,
+		Command:  entry.Command,
+		Undone:   undone,
+		Changes:  changes,
+	}
+}
+
+func HandleUndo(connectionFilePath, secretKeyFilePath string, args []string) error {
+	return handleUndo(connectionFilePath, secretKeyFilePath, args, os.Stdout)
+}
+
+func handleUndo(connectionFilePath, secretKeyFilePath string, args []string, out io.Writer) error {
+	fs := flag.NewFlagSet("undo", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	alias := fs.String("alias", "", "Only revert connection alias")
+	id := fs.String("id", "", "Only revert connection ID")
+	to := fs.Int("to", 0, "Restore the connection to its state after this revision")
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	selectedAlias, selectedID, err := resolveSelector(*alias, *id, fs.Args(), "undo")
+	if err != nil {
+		return err
+	}
+
+	connStore := store.NewConnectionStore(connectionFilePath, secretKeyFilePath, store.WithJournalCommand("undo"))
+	if selectedAlias == "" && selectedID == "" {
+		if *to != 0 {
+			return errors.New("undo: --to requires a connection (<alias> or --id)")
+		}
+		entry, err := connStore.Undo()
+		if err != nil {
+			return err
+		}
+		_, _ = fmt.Fprintf(out, "Undid revision %d (redo with 'sshmanager redo'):\n", entry.Revision)
+		printJournalChanges(out, entry)
+		return nil
+	}
+	return revertConnection(connStore, selectedAlias, selectedID, *to, out)
+}
+
+// revertConnection restores one connection to an earlier revision without
+// touching the others. The restore is a new change, so it can be undone too.
+func revertConnection(connStore *store.ConnectionStore, alias, id string, revision int, out io.Writer) error {
+	journal, err := connStore.Journal()
+	if err != nil {
+		return err
+	}
+	connID, err := journalConnectionID(connStore, &journal, alias, id)
+	if err != nil {
+		return err
+	}
+
+	var applied []model.JournalEntry
+	for _, entry := range journal.ForConnection(connID) {
+		if !journal.IsUndone(entry.Revision) {
+			applied = append(applied, entry)
+		}
+	}
+	if len(applied) == 0 {
+		return fmt.Errorf("undo: no recorded changes for %s", journalSelectorLabel(alias, id))
+	}
+
+	var (
+		state   *model.SSHConnection
+		message string
+	)
+	if revision == 0 {
+		last := applied[len(applied)-1]
+		state = last.Changes[0].BeforeConnection
+		message = fmt.Sprintf("Reverted revision %d of %s.", last.Revision, last.Changes[0].Label)
+	} else {
+		found := false
+		for _, entry := range applied {
+			if entry.Revision == revision {
+				state = entry.Changes[0].AfterConnection
+				message = fmt.Sprintf("Restored %s to revision %d.", entry.Changes[0].Label, revision)
+				found = true
+				break
+			}
+		}
+		if !found {
+			return fmt.Errorf("undo: revision %d did not change %s (see 'sshmanager log %s')", revision, journalSelectorLabel(alias, id), journalSelectorLabel(alias, id))
+		}
+	}
+
+	if err := connStore.Update(func(connFile *model.ConnectionFile) error {
+		return connFile.SetConnectionState(connID, state)
+	}); err != nil {
+		return err
+	}
+	if state == nil {
+		message = strings.TrimSuffix(message, ".") + " (the connection is removed)."
+	}
+	_, _ = fmt.Fprintln(out, message)
+	return nil
+}
+
+func HandleRedo(connectionFilePath, secretKeyFilePath string, args []string) error {
+	return handleRedo(connectionFilePath, secretKeyFilePath, args, os.Stdout)
+}
+
+func handleRedo(connectionFilePath, secretKeyFilePath string, args []string, out io.Writer) error {
+	if len(args) > 0 {
+		return fmt.Errorf("unexpected arguments for redo: %s", strings.Join(args, " "))
+	}
+
+	entry, err := store.NewConnectionStore(connectionFilePath, secretKeyFilePath, store.WithJournalCommand("redo")).Redo()
+	if err != nil {
+		return err
+	}
+	_, _ = fmt.Fprintf(out, "Redid revision %d:\n", entry.Revision)
+	printJournalChanges(out, entry)
+	return nil
+}
+
+func printJournalChanges(out io.Writer, entry model.JournalEntry) {
+	for _, change := range entry.Changes {
+		_, _ = fmt.Fprintf(out, "    %s\n", change.Summary())
+	}
+}
+
+// journalConnectionID resolves a selector to a connection ID. Aliases are
+// looked up in the current file first and then in the journal, so removed
+// and renamed connections can still be found.
+func journalConnectionID(connStore *store.ConnectionStore, journal *model.JournalFile, alias, id string) (string, error) {
+	if id != "" {
+		return id, nil
+	}
+	connFile, err := connStore.Load()
+	if err != nil {
+		return "", err
+	}
+	if conn := connFile.GetConnectionByAlias(alias); conn != nil {
+		return conn.ID, nil
+	}
+	if connID := journal.ConnectionIDByAlias(alias); connID != "" {
+		return connID, nil
+	}
+	return "", errors.New(notFoundMessage(alias, id))
+}
+
+func journalSelectorLabel(alias, id string) string {
+	if alias != "" {
+		return alias
+	}
+	return "--id " + id
+}
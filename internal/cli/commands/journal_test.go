@@ -0,0 +1,130 @@
+package commands
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/emirhangumus/sshmanager/internal/model"
+)
+
+func TestHandleUndoRestoresSingleConnection(t *testing.T) {
+	connPath, keyPath := prepareTransferFixture(t, []model.SSHConnection{
+		{Username: "ops", Host: "web.internal", ProxyJump: "bastion", Alias: "web"},
+		{Username: "ops", Host: "db.internal", Alias: "db"},
+	})
+
+	updateTestConnection(t, connPath, keyPath, "web", func(conn *model.SSHConnection) { conn.ProxyJump = "" })
+	updateTestConnection(t, connPath, keyPath, "db", func(conn *model.SSHConnection) { conn.Port = 5432 })
+
+	var out bytes.Buffer
+	if err := handleUndo(connPath, keyPath, []string{"web"}, &out); err != nil {
+		t.Fatalf("handleUndo(web) failed: %v", err)
+	}
+	loaded := loadTransferConnections(t, connPath, keyPath)
+	if loaded.GetConnectionByAlias("web").ProxyJump != "bastion" || loaded.GetConnectionByAlias("db").Port != 5432 {
+		t.Fatalf("expected only web to be reverted, got %+v", loaded.Connections)
+	}
+
+	// Restore a removed connection by its old alias.
+	if err := handleRemoveArgs(connPath, keyPath, []string{"--yes", "db"}, ioDiscard()); err != nil {
+		t.Fatalf("remove failed: %v", err)
+	}
+	out.Reset()
+	if err := handleLog(connPath, keyPath, []string{"db"}, &out); err != nil {
+		t.Fatalf("handleLog(db) failed: %v", err)
+	}
+	if !strings.Contains(out.String(), "removed connection db") || !strings.Contains(out.String(), "port: 0 -> 5432") {
+		t.Fatalf("unexpected log: %q", out.String())
+	}
+	if err := handleUndo(connPath, keyPath, []string{"db"}, ioDiscard()); err != nil {
+		t.Fatalf("handleUndo(db) failed: %v", err)
+	}
+	loaded = loadTransferConnections(t, connPath, keyPath)
+	if conn := loaded.GetConnectionByAlias("db"); conn == nil || conn.Port != 5432 {
+		t.Fatalf("expected removed connection to be restored, got %+v", conn)
+	}
+}
+
+func TestHandleUndoToRevisionAndRedo(t *testing.T) {
+	connPath, keyPath := prepareTransferFixture(t, []model.SSHConnection{{Username: "ops", Host: "web.internal", Alias: "web"}})
+	for _, port := range []int{2201, 2202, 2203} {
+		updateTestConnection(t, connPath, keyPath, "web", func(conn *model.SSHConnection) { conn.Port = port })
+	}
+
+	var out bytes.Buffer
+	if err := handleLog(connPath, keyPath, []string{"--json", "--limit", "2"}, &out); err != nil {
+		t.Fatalf("handleLog failed: %v", err)
+	}
+	var items []logOutputItem
+	if err := json.Unmarshal(out.Bytes(), &items); err != nil {
+		t.Fatalf("invalid log json: %v", err)
+	}
+	if len(items) != 2 || items[0].Revision != 4 || items[1].Changes[0].Fields[0] != "port" {
+		t.Fatalf("unexpected log items: %+v", items)
+	}
+
+	if err := handleUndo(connPath, keyPath, []string{"--to", "2", "web"}, ioDiscard()); err != nil {
+		t.Fatalf("handleUndo(--to 2) failed: %v", err)
+	}
+	loaded := loadTransferConnections(t, connPath, keyPath)
+	if port := loaded.GetConnectionByAlias("web").Port; port != 2201 {
+		t.Fatalf("expected port as of revision 2, got %d", port)
+	}
+
+	if err := handleUndo(connPath, keyPath, nil, ioDiscard()); err != nil {
+		t.Fatalf("handleUndo failed: %v", err)
+	}
+	loaded = loadTransferConnections(t, connPath, keyPath)
+	if port := loaded.GetConnectionByAlias("web").Port; port != 2203 {
+		t.Fatalf("expected undo of the restore, got %d", port)
+	}
+	out.Reset()
+	if err := handleRedo(connPath, keyPath, nil, &out); err != nil {
+		t.Fatalf("handleRedo failed: %v", err)
+	}
+	if !strings.Contains(out.String(), "Redid revision 5") {
+		t.Fatalf("unexpected redo output: %q", out.String())
+	}
+
+	cases := []struct {
+		args []string
+		want string
+	}{
+		{[]string{"--to", "3"}, "--to requires a connection"},
+		{[]string{"--to", "99", "web"}, "revision 99 did not change web"},
+		{[]string{"missing"}, "missing"},
+	}
+	for _, tc := range cases {
+		err := handleUndo(connPath, keyPath, tc.args, ioDiscard())
+		if err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Fatalf("handleUndo(%v) error = %v, want %q", tc.args, err, tc.want)
+		}
+	}
+	if err := handleRedo(connPath, keyPath, []string{"x"}, ioDiscard()); err == nil {
+		t.Fatal("expected redo to reject arguments")
+	}
+}
+
+func TestJournalRecordsCommandName(t *testing.T) {
+	connPath, keyPath := prepareTransferFixture(t, []model.SSHConnection{{Username: "ops", Host: "web.internal", Alias: "web"}})
+	if err := handleRenameArgs(connPath, keyPath, []string{"--to", "www", "web"}, ioDiscard()); err != nil {
+		t.Fatalf("rename failed: %v", err)
+	}
+	if err := handleUndo(connPath, keyPath, []string{"www"}, ioDiscard()); err != nil {
+		t.Fatalf("handleUndo failed: %v", err)
+	}
+
+	var out bytes.Buffer
+	if err := handleLog(connPath, keyPath, []string{"--json"}, &out); err != nil {
+		t.Fatalf("handleLog failed: %v", err)
+	}
+	var items []logOutputItem
+	if err := json.Unmarshal(out.Bytes(), &items); err != nil {
+		t.Fatalf("invalid log json: %v", err)
+	}
+	if len(items) < 2 || items[0].Command != "undo" || items[1].Command != "rename" {
+		t.Fatalf("expected undo and rename commands, got %+v", items)
+	}
+}
@@ -0,0 +1,358 @@
+package commands
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/emirhangumus/sshmanager/internal/model"
+	"github.com/emirhangumus/sshmanager/internal/store"
+)
+
+type knownHostsEntryOutput struct {
+	Host        string   `json:"host"`
+	Connections []string `json:"connections,omitempty"`
+	Type        string   `json:"type"`
+	Fingerprint string   `json:"fingerprint"`
+	Marker      string   `json:"marker,omitempty"`
+	Pinned      bool     `json:"pinned"`
+}
+
+// knownHostsTarget is the known_hosts host pattern of one connection.
+type knownHostsTarget struct {
+	name    string
+	pattern string
+	pinned  []string
+}
+
+func HandleKnownHosts(connectionFilePath, secretKeyFilePath string, args []string) error {
+	return handleKnownHosts(connectionFilePath, secretKeyFilePath, args, os.Stdout)
+}
+
+// handleKnownHosts manages the vault's known_hosts file that connect points
+// ssh at for connections without pinned host keys.
+func handleKnownHosts(connectionFilePath, secretKeyFilePath string, args []string, out io.Writer) error {
+	if len(args) == 0 {
+		return errors.New("known-hosts requires a subcommand: show, sync, prune or forget")
+	}
+	path := managedKnownHostsPath(filepath.Dir(connectionFilePath))
+	switch strings.ToLower(strings.TrimSpace(args[0])) {
+	case "show":
+		return handleKnownHostsShow(connectionFilePath, secretKeyFilePath, path, args[1:], out)
+	case "sync":
+		return handleKnownHostsSync(connectionFilePath, secretKeyFilePath, path, args[1:], out)
+	case "prune":
+		return handleKnownHostsPrune(connectionFilePath, secretKeyFilePath, path, args[1:], out)
+	case "forget":
+		return handleKnownHostsForget(connectionFilePath, secretKeyFilePath, path, args[1:], out)
+	default:
+		return fmt.Errorf("unknown known-hosts subcommand %q (use show, sync, prune or forget)", args[0])
+	}
+}
+
+func handleKnownHostsShow(connectionFilePath, secretKeyFilePath, path string, args []string, out io.Writer) error {
+	fs := flag.NewFlagSet("known-hosts show", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+
+	alias := fs.String("alias", "", "Connection alias")
+	id := fs.String("id", "", "Connection ID")
+	jsonOutput := fs.Bool("json", false, "Output JSON")
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	selectedAlias, selectedID, err := resolveSelector(*alias, *id, fs.Args(), "known-hosts show")
+	if err != nil {
+		return err
+	}
+
+	connFile, err := store.NewConnectionStore(connectionFilePath, secretKeyFilePath).Load()
+	if err != nil {
+		return err
+	}
+	targets := knownHostsTargets(&connFile)
+	filter := ""
+	if selectedAlias != "" || selectedID != "" {
+		conn := findConnectionBySelector(&connFile, selectedAlias, selectedID)
+		if conn == nil {
+			return errors.New(notFoundMessage(selectedAlias, selectedID))
+		}
+		filter = connectionKnownHostsPattern(&connFile, *conn)
+	}
+
+	file, err := loadKnownHosts(path)
+	if err != nil {
+		return err
+	}
+	items := make([]knownHostsEntryOutput, 0, len(file.entries))
+	for _, entry := range file.entries {
+		if !entry.isKey() || (filter != "" && !entry.matches(filter)) {
+			continue
+		}
+		item := knownHostsEntryOutput{
+			Host:        strings.Join(entry.hosts, ","),
+			Type:        model.HostKeyType(entry.key),
+			Fingerprint: model.HostKeyFingerprint(entry.key),
+			Marker:      entry.marker,
+		}
+		for _, target := range targets {
+			if !entry.matches(target.pattern) {
+				continue
+			}
+			// Hashed names are shown as the pattern they matched.
+			if strings.HasPrefix(item.Host, "|1|") {
+				item.Host = target.pattern
+			}
+			item.Connections = append(item.Connections, target.name)
+			if containsHostKey(target.pinned, entry.key) {
+				item.Pinned = true
+			}
+		}
+		items = append(items, item)
+	}
+
+	if *jsonOutput {
+		enc := json.NewEncoder(out)
+		enc.SetIndent("", "  ")
+		return enc.Encode(items)
+	}
+
+	if len(items) == 0 {
+		_, _ = fmt.Fprintf(out, "No entries in %s (run 'sshmanager known-hosts sync').\n", path)
+		return nil
+	}
+	tw := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(tw, "HOST\tCONNECTIONS\tTYPE\tFINGERPRINT\tPINNED")
+	for _, item := range items {
+		connections := strings.Join(item.Connections, ",")
+		if connections == "" {
+			connections = "- (stale)"
+		}
+		host := item.Host
+		if item.Marker != "" {
+			host = item.Marker + " " + host
+		}
+		_, _ = fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", host, connections, item.Type, item.Fingerprint, yesNo(item.Pinned))
+	}
+	return tw.Flush()
+}
+
+// handleKnownHostsSync writes every pinned key into the managed file,
+// replacing other entries for pinned hosts. With --from-user it also copies
+// the keys ~/.ssh/known_hosts has for connections the managed file does not
+// know; that file may hold stale keys, so this is never done implicitly.
+func handleKnownHostsSync(connectionFilePath, secretKeyFilePath, path string, args []string, out io.Writer) error {
+	fs := flag.NewFlagSet("known-hosts sync", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+
+	fromUser := fs.Bool("from-user", false, "Also copy keys from ~/.ssh/known_hosts")
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() > 0 {
+		return fmt.Errorf("unexpected arguments for known-hosts sync: %s", strings.Join(fs.Args(), " "))
+	}
+
+	connFile, err := store.NewConnectionStore(connectionFilePath, secretKeyFilePath).Load()
+	if err != nil {
+		return err
+	}
+
+	var user *knownHostsFile
+	if *fromUser {
+		if userPath, err := userKnownHostsPath(); err == nil {
+			if user, err = loadKnownHosts(userPath); err != nil {
+				return err
+			}
+		}
+	}
+
+	pinned, imported := 0, 0
+	if err := updateKnownHosts(path, func(file *knownHostsFile) bool {
+		for _, target := range knownHostsTargets(&connFile) {
+			if len(target.pinned) > 0 {
+				file.forget(target.pattern)
+				for _, key := range target.pinned {
+					if file.add(target.pattern, key) {
+						pinned++
+					}
+				}
+				continue
+			}
+			if user == nil || len(file.keysFor(target.pattern)) > 0 {
+				continue
+			}
+			for _, key := range user.keysFor(target.pattern) {
+				if file.add(target.pattern, key) {
+					imported++
+				}
+			}
+		}
+		return true
+	}); err != nil {
+		return err
+	}
+	_, _ = fmt.Fprintf(out, "Synced %s: %d pinned key(s) written, %d key(s) imported from ~/.ssh/known_hosts\n", path, pinned, imported)
+	return nil
+}
+
+// handleKnownHostsPrune removes entries for hosts no connection uses.
+func handleKnownHostsPrune(connectionFilePath, secretKeyFilePath, path string, args []string, out io.Writer) error {
+	fs := flag.NewFlagSet("known-hosts prune", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+
+	dryRun := fs.Bool("dry-run", false, "Only list the entries that would be removed")
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() > 0 {
+		return fmt.Errorf("unexpected arguments for known-hosts prune: %s", strings.Join(fs.Args(), " "))
+	}
+
+	connFile, err := store.NewConnectionStore(connectionFilePath, secretKeyFilePath).Load()
+	if err != nil {
+		return err
+	}
+	var removed []knownHostsEntry
+	if err := updateKnownHosts(path, func(file *knownHostsFile) bool {
+		removed = file.prune(knownHostsPatterns(knownHostsTargets(&connFile)))
+		return !*dryRun && len(removed) > 0
+	}); err != nil {
+		return err
+	}
+	for _, entry := range removed {
+		_, _ = fmt.Fprintf(out, "  %s %s %s\n", strings.Join(entry.hosts, ","), model.HostKeyType(entry.key), model.HostKeyFingerprint(entry.key))
+	}
+	if *dryRun {
+		_, _ = fmt.Fprintf(out, "Would remove %d stale entries from %s\n", len(removed), path)
+		return nil
+	}
+	_, _ = fmt.Fprintf(out, "Removed %d stale entries from %s\n", len(removed), path)
+	return nil
+}
+
+// handleKnownHostsForget drops the managed entries of one connection, for
+// example after its host was re-provisioned. Pinned keys stay pinned.
+func handleKnownHostsForget(connectionFilePath, secretKeyFilePath, path string, args []string, out io.Writer) error {
+	fs := flag.NewFlagSet("known-hosts forget", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+
+	alias := fs.String("alias", "", "Connection alias")
+	id := fs.String("id", "", "Connection ID")
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	selectedAlias, selectedID, err := resolveSelector(*alias, *id, fs.Args(), "known-hosts forget")
+	if err != nil {
+		return err
+	}
+	if selectedAlias == "" && selectedID == "" {
+		return errors.New("known-hosts forget requires a connection alias or --id")
+	}
+
+	connFile, err := store.NewConnectionStore(connectionFilePath, secretKeyFilePath).Load()
+	if err != nil {
+		return err
+	}
+	conn := findConnectionBySelector(&connFile, selectedAlias, selectedID)
+	if conn == nil {
+		return errors.New(notFoundMessage(selectedAlias, selectedID))
+	}
+	pattern := connectionKnownHostsPattern(&connFile, *conn)
+
+	removed := 0
+	if err := updateKnownHosts(path, func(file *knownHostsFile) bool {
+		removed = file.forget(pattern)
+		return removed > 0
+	}); err != nil {
+		return err
+	}
+	_, _ = fmt.Fprintf(out, "Removed %d entries for %s from %s\n", removed, pattern, path)
+	if len(conn.HostKeys) > 0 {
+		_, _ = fmt.Fprintf(out, "%s still has pinned host keys; use 'sshmanager trust --replace %s' to change them\n", connectionLabel(*conn), trustSelector(conn))
+	}
+	return nil
+}
+
+// forgetRemovedConnectionHosts drops the managed known_hosts entries and the
+// pinned known_hosts file of a removed connection. Entries for a host and
+// port another connection still uses are kept.
+func forgetRemovedConnectionHosts(vaultDir string, removed model.SSHConnection, remaining *model.ConnectionFile) error {
+	if err := os.Remove(pinnedKnownHostsPath(vaultDir, &removed)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	pattern := model.KnownHostsPattern(removed.Host, removed.EffectivePort())
+	for _, target := range knownHostsTargets(remaining) {
+		if target.pattern == pattern {
+			return nil
+		}
+	}
+	path := managedKnownHostsPath(vaultDir)
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		return nil
+	}
+	return updateKnownHosts(path, func(file *knownHostsFile) bool {
+		return file.forget(pattern) > 0
+	})
+}
+
+// updateKnownHosts loads the managed known_hosts file at path under its
+// lock, applies change and saves the file when change reports that it
+// modified it.
+func updateKnownHosts(path string, change func(file *knownHostsFile) bool) error {
+	unlock, err := store.LockFile(path)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	file, err := loadKnownHosts(path)
+	if err != nil {
+		return err
+	}
+	if !change(file) {
+		return nil
+	}
+	return file.save(path)
+}
+
+func knownHostsTargets(connFile *model.ConnectionFile) []knownHostsTarget {
+	targets := make([]knownHostsTarget, 0, len(connFile.Connections))
+	for _, conn := range connFile.Connections {
+		name := strings.TrimSpace(conn.Alias)
+		if name == "" {
+			name = conn.ID
+		}
+		targets = append(targets, knownHostsTarget{
+			name:    name,
+			pattern: connectionKnownHostsPattern(connFile, conn),
+			pinned:  model.NormalizeStringList(conn.HostKeys),
+		})
+	}
+	return targets
+}
+
+func knownHostsPatterns(targets []knownHostsTarget) []string {
+	patterns := make([]string, 0, len(targets))
+	for _, target := range targets {
+		patterns = append(patterns, target.pattern)
+	}
+	return patterns
+}
+
+func yesNo(value bool) string {
+	if value {
+		return "yes"
+	}
+	return "no"
+}
@@ -0,0 +1,273 @@
+package commands
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha1" //nolint:gosec // OpenSSH hashes known_hosts names with HMAC-SHA1
+	"encoding/base64"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/emirhangumus/sshmanager/internal/model"
+	"github.com/emirhangumus/sshmanager/internal/storage"
+)
+
+const knownHostsHeader = "# Managed by sshmanager: entries are keyed by each connection's host and port.\n" +
+	"# Edit with 'sshmanager known-hosts' and 'sshmanager trust'.\n"
+
+// knownHostsEntry is one line of a known_hosts file. Comments, blank and
+// unparsable lines keep only raw and are written back unchanged.
+type knownHostsEntry struct {
+	raw     string
+	marker  string
+	hosts   []string
+	key     string
+	comment string
+}
+
+func (e knownHostsEntry) isKey() bool {
+	return e.key != ""
+}
+
+// matches reports whether the entry applies to pattern, a host in the form
+// model.KnownHostsPattern returns. Hashed names are compared by hashing
+// pattern with the entry's salt; wildcard patterns never match.
+func (e knownHostsEntry) matches(pattern string) bool {
+	for _, host := range e.hosts {
+		if knownHostsHostMatches(host, pattern) {
+			return true
+		}
+	}
+	return false
+}
+
+// isUserAuthored reports entries that sshmanager never prunes: markers and
+// wildcard or negated patterns.
+func (e knownHostsEntry) isUserAuthored() bool {
+	if e.marker != "" {
+		return true
+	}
+	for _, host := range e.hosts {
+		if strings.ContainsAny(host, "*?!") {
+			return true
+		}
+	}
+	return false
+}
+
+func (e knownHostsEntry) String() string {
+	if !e.isKey() {
+		return e.raw
+	}
+	fields := make([]string, 0, 4)
+	if e.marker != "" {
+		fields = append(fields, e.marker)
+	}
+	fields = append(fields, strings.Join(e.hosts, ","), e.key)
+	if e.comment != "" {
+		fields = append(fields, e.comment)
+	}
+	return strings.Join(fields, " ")
+}
+
+// knownHostsFile is a parsed known_hosts file that keeps the order and
+// content of lines it does not change.
+type knownHostsFile struct {
+	entries []knownHostsEntry
+}
+
+// loadKnownHosts parses path; a missing file is empty.
+func loadKnownHosts(path string) (*knownHostsFile, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return &knownHostsFile{}, nil
+		}
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	return parseKnownHosts(data)
+}
+
+func parseKnownHosts(data []byte) (*knownHostsFile, error) {
+	file := &knownHostsFile{}
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		file.entries = append(file.entries, parseKnownHostsLine(scanner.Text()))
+	}
+	return file, scanner.Err()
+}
+
+func parseKnownHostsLine(line string) knownHostsEntry {
+	entry := knownHostsEntry{raw: line}
+	fields := strings.Fields(line)
+	if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+		return entry
+	}
+	if strings.HasPrefix(fields[0], "@") {
+		entry.marker = fields[0]
+		fields = fields[1:]
+	}
+	if len(fields) < 3 {
+		return knownHostsEntry{raw: line}
+	}
+	key, err := model.NormalizeHostKey(fields[1] + " " + fields[2])
+	if err != nil {
+		return knownHostsEntry{raw: line}
+	}
+	entry.hosts = strings.Split(fields[0], ",")
+	entry.key = key
+	entry.comment = strings.Join(fields[3:], " ")
+	return entry
+}
+
+// save writes the file atomically with mode 0600.
+func (f *knownHostsFile) save(path string) error {
+	var b strings.Builder
+	if len(f.entries) == 0 || !strings.HasPrefix(f.entries[0].raw, "# Managed by sshmanager") {
+		b.WriteString(knownHostsHeader)
+	}
+	for _, entry := range f.entries {
+		b.WriteString(entry.String() + "\n")
+	}
+	return storage.WriteFileAtomic(path, []byte(b.String()), 0o600)
+}
+
+// keysFor returns the keys of the unmarked entries for pattern.
+func (f *knownHostsFile) keysFor(pattern string) []string {
+	var keys []string
+	for _, entry := range f.entries {
+		if entry.isKey() && entry.marker == "" && entry.matches(pattern) && !containsHostKey(keys, entry.key) {
+			keys = append(keys, entry.key)
+		}
+	}
+	return keys
+}
+
+// keys returns every unmarked key in the file.
+func (f *knownHostsFile) keys() []string {
+	var keys []string
+	for _, entry := range f.entries {
+		if entry.isKey() && entry.marker == "" && !containsHostKey(keys, entry.key) {
+			keys = append(keys, entry.key)
+		}
+	}
+	return keys
+}
+
+// add appends pattern with key unless an entry already has them.
+func (f *knownHostsFile) add(pattern, key string) bool {
+	if containsHostKey(f.keysFor(pattern), key) {
+		return false
+	}
+	f.entries = append(f.entries, knownHostsEntry{hosts: []string{pattern}, key: key})
+	return true
+}
+
+// forget removes pattern from every unmarked entry, dropping entries left
+// without hosts, and returns how many entries it changed.
+func (f *knownHostsFile) forget(pattern string) int {
+	changed := 0
+	kept := f.entries[:0]
+	for _, entry := range f.entries {
+		if !entry.isKey() || entry.marker != "" || !entry.matches(pattern) {
+			kept = append(kept, entry)
+			continue
+		}
+		changed++
+		var hosts []string
+		for _, host := range entry.hosts {
+			if !knownHostsHostMatches(host, pattern) {
+				hosts = append(hosts, host)
+			}
+		}
+		if len(hosts) > 0 {
+			entry.hosts = hosts
+			kept = append(kept, entry)
+		}
+	}
+	f.entries = kept
+	return changed
+}
+
+// prune drops the entries that match none of patterns and returns them.
+// User-authored entries are kept.
+func (f *knownHostsFile) prune(patterns []string) []knownHostsEntry {
+	var removed []knownHostsEntry
+	kept := f.entries[:0]
+	for _, entry := range f.entries {
+		if !entry.isKey() || entry.isUserAuthored() || matchesAnyPattern(entry, patterns) {
+			kept = append(kept, entry)
+			continue
+		}
+		removed = append(removed, entry)
+	}
+	f.entries = kept
+	return removed
+}
+
+func matchesAnyPattern(entry knownHostsEntry, patterns []string) bool {
+	for _, pattern := range patterns {
+		if entry.matches(pattern) {
+			return true
+		}
+	}
+	return false
+}
+
+// knownHostsHostMatches compares one host field of a known_hosts line with
+// pattern, including OpenSSH's hashed "|1|salt|hmac" form.
+func knownHostsHostMatches(host, pattern string) bool {
+	if rest, ok := strings.CutPrefix(host, "|1|"); ok {
+		encodedSalt, encodedHash, ok := strings.Cut(rest, "|")
+		if !ok {
+			return false
+		}
+		salt, err := base64.StdEncoding.DecodeString(encodedSalt)
+		if err != nil {
+			return false
+		}
+		want, err := base64.StdEncoding.DecodeString(encodedHash)
+		if err != nil {
+			return false
+		}
+		mac := hmac.New(sha1.New, salt)
+		mac.Write([]byte(pattern))
+		return hmac.Equal(mac.Sum(nil), want)
+	}
+	return strings.EqualFold(host, pattern)
+}
+
+// readKnownHostsKeys returns the keys of a known_hosts file in
+// "<type> <base64>" form.
+func readKnownHostsKeys(path string) ([]string, error) {
+	if _, err := os.Stat(path); err != nil {
+		return nil, err
+	}
+	file, err := loadKnownHosts(path)
+	if err != nil {
+		return nil, err
+	}
+	return file.keys(), nil
+}
+
+// userKnownHostsPath is the user's OpenSSH known_hosts file.
+func userKnownHostsPath() (string, error) {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("could not determine home directory for known_hosts: %w", err)
+	}
+	return filepath.Join(homeDir, ".ssh", "known_hosts"), nil
+}
+
+// connectionKnownHostsPattern is the known_hosts host pattern of conn after
+// its profile chain is applied.
+func connectionKnownHostsPattern(connFile *model.ConnectionFile, conn model.SSHConnection) string {
+	if resolved, err := connFile.ResolveConnection(conn); err == nil {
+		conn = resolved
+	}
+	return model.KnownHostsPattern(conn.Host, conn.EffectivePort())
+}
@@ -0,0 +1,199 @@
+package commands
+
+import (
+	"crypto/hmac"
+	"crypto/sha1" //nolint:gosec // matches OpenSSH's hashed known_hosts names
+	"encoding/base64"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/emirhangumus/sshmanager/internal/model"
+)
+
+func hashedKnownHostsName(salt []byte, host string) string {
+	mac := hmac.New(sha1.New, salt)
+	mac.Write([]byte(host))
+	return "|1|" + base64.StdEncoding.EncodeToString(salt) + "|" + base64.StdEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func writeUserKnownHosts(t *testing.T, content string) {
+	t.Helper()
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	if err := os.MkdirAll(filepath.Join(home, ".ssh"), 0o700); err != nil {
+		t.Fatalf("MkdirAll failed: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(home, ".ssh", "known_hosts"), []byte(content), 0o600); err != nil {
+		t.Fatalf("WriteFile(known_hosts) failed: %v", err)
+	}
+}
+
+func TestKnownHostsFileEditing(t *testing.T) {
+	keyA := generateTestHostKey(t)
+	keyB := generateTestHostKey(t)
+	hashed := hashedKnownHostsName([]byte("0123456789abcdefghij"), "[web]:2222")
+
+	file, err := parseKnownHosts([]byte(strings.Join([]string{
+		"# comment",
+		"web,10.0.0.5 " + keyA + " old",
+		hashed + " " + keyB,
+		"*.lab " + keyA,
+		"@revoked gone " + keyB,
+		"gone " + keyB,
+		"not a key line",
+	}, "\n")))
+	if err != nil {
+		t.Fatalf("parseKnownHosts failed: %v", err)
+	}
+
+	if keys := file.keysFor("[web]:2222"); len(keys) != 1 || keys[0] != keyB {
+		t.Fatalf("expected the hashed entry to match, got %q", keys)
+	}
+	if keys := file.keysFor("WEB"); len(keys) != 1 || keys[0] != keyA {
+		t.Fatalf("expected a case-insensitive match, got %q", keys)
+	}
+
+	removed := file.prune([]string{"web", "[web]:2222"})
+	if len(removed) != 1 || removed[0].hosts[0] != "gone" {
+		t.Fatalf("expected only the unmarked stale entry to be pruned, got %+v", removed)
+	}
+	if changed := file.forget("web"); changed != 1 {
+		t.Fatalf("forget(web) changed %d entries", changed)
+	}
+	if !file.add("web", keyB) || file.add("web", keyB) {
+		t.Fatal("expected add to skip an existing entry")
+	}
+
+	var lines []string
+	for _, entry := range file.entries {
+		lines = append(lines, entry.String())
+	}
+	want := []string{
+		"# comment",
+		"10.0.0.5 " + keyA + " old",
+		hashed + " " + keyB,
+		"*.lab " + keyA,
+		"@revoked gone " + keyB,
+		"not a key line",
+		"web " + keyB,
+	}
+	assertStringSliceEqual(t, lines, want)
+}
+
+func TestHandleKnownHostsLifecycle(t *testing.T) {
+	webKey := generateTestHostKey(t)
+	dbKey := generateTestHostKey(t)
+	staleKey := generateTestHostKey(t)
+	writeUserKnownHosts(t, "web.internal "+webKey+"\nold.internal "+staleKey+"\n")
+
+	connPath, keyPath := prepareTransferFixture(t, []model.SSHConnection{
+		{Username: "ubuntu", Host: "web.internal", AuthMode: model.AuthModeAgent, Alias: "web"},
+		{Username: "ubuntu", Host: "db.internal", Port: 2222, AuthMode: model.AuthModeAgent, Alias: "db", HostKeys: []string{dbKey}},
+	})
+	vaultDir := filepath.Dir(connPath)
+	managedPath := managedKnownHostsPath(vaultDir)
+
+	var out strings.Builder
+	if err := handleKnownHosts(connPath, keyPath, []string{"sync"}, &out); err != nil {
+		t.Fatalf("known-hosts sync failed: %v", err)
+	}
+	if !strings.Contains(out.String(), "1 pinned key(s) written, 0 key(s) imported") {
+		t.Fatalf("expected sync not to import without --from-user, got %q", out.String())
+	}
+	out.Reset()
+	if err := handleKnownHosts(connPath, keyPath, []string{"sync", "--from-user"}, &out); err != nil {
+		t.Fatalf("known-hosts sync --from-user failed: %v", err)
+	}
+	if !strings.Contains(out.String(), "1 pinned key(s) written, 1 key(s) imported") {
+		t.Fatalf("unexpected sync output: %q", out.String())
+	}
+	data, err := os.ReadFile(managedPath)
+	if err != nil {
+		t.Fatalf("ReadFile(managed) failed: %v", err)
+	}
+	for _, line := range []string{"web.internal " + webKey, "[db.internal]:2222 " + dbKey} {
+		if !strings.Contains(string(data), line+"\n") {
+			t.Fatalf("expected %q in the managed file, got %q", line, data)
+		}
+	}
+	if strings.Contains(string(data), "old.internal") {
+		t.Fatal("sync must not import hosts no connection uses")
+	}
+
+	appendFile, err := os.OpenFile(managedPath, os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		t.Fatalf("OpenFile failed: %v", err)
+	}
+	_, _ = appendFile.WriteString("old.internal " + staleKey + "\n")
+	_ = appendFile.Close()
+
+	out.Reset()
+	if err := handleKnownHosts(connPath, keyPath, []string{"show", "--json"}, &out); err != nil {
+		t.Fatalf("known-hosts show failed: %v", err)
+	}
+	var items []knownHostsEntryOutput
+	if err := json.Unmarshal([]byte(out.String()), &items); err != nil {
+		t.Fatalf("failed to decode show output: %v\n%s", err, out.String())
+	}
+	if len(items) != 3 || items[1].Connections[0] != "db" || !items[1].Pinned || len(items[2].Connections) != 0 {
+		t.Fatalf("unexpected show output: %+v", items)
+	}
+
+	out.Reset()
+	if err := handleKnownHosts(connPath, keyPath, []string{"prune"}, &out); err != nil || !strings.Contains(out.String(), "Removed 1 stale entries") {
+		t.Fatalf("known-hosts prune = %q, %v", out.String(), err)
+	}
+	out.Reset()
+	if err := handleKnownHosts(connPath, keyPath, []string{"forget", "web"}, &out); err != nil || !strings.Contains(out.String(), "Removed 1 entries for web.internal") {
+		t.Fatalf("known-hosts forget = %q, %v", out.String(), err)
+	}
+
+	connFile := loadTransferConnections(t, connPath, keyPath)
+	db := connFile.GetConnectionByAlias("db")
+	if _, _, _, err := buildConnectInvocation(vaultDir, db); err != nil {
+		t.Fatalf("buildConnectInvocation(db) failed: %v", err)
+	}
+	if _, err := os.Stat(pinnedKnownHostsPath(vaultDir, db)); err != nil {
+		t.Fatalf("expected the pinned known_hosts file, got %v", err)
+	}
+
+	if err := handleRemoveArgs(connPath, keyPath, []string{"--yes", "db"}, ioDiscard()); err != nil {
+		t.Fatalf("remove failed: %v", err)
+	}
+	if keys, _ := readKnownHostsKeys(managedPath); len(keys) != 0 {
+		t.Fatalf("expected remove to drop the entries of db, got %q", keys)
+	}
+	if _, err := os.Stat(pinnedKnownHostsPath(vaultDir, db)); !os.IsNotExist(err) {
+		t.Fatalf("expected remove to delete the pinned known_hosts file, got %v", err)
+	}
+}
+
+func TestBuildConnectInvocationUsesManagedKnownHosts(t *testing.T) {
+	key := generateTestHostKey(t)
+	writeUserKnownHosts(t, hashedKnownHostsName([]byte("saltsaltsaltsaltsalt"), "[app.internal]:2200")+" "+key+"\n")
+	vaultDir := t.TempDir()
+
+	_, args, _, err := buildConnectInvocation(vaultDir, &model.SSHConnection{
+		Username: "ubuntu",
+		Host:     "app.internal",
+		Port:     2200,
+		AuthMode: model.AuthModeAgent,
+	})
+	if err != nil {
+		t.Fatalf("buildConnectInvocation failed: %v", err)
+	}
+	managedPath := managedKnownHostsPath(vaultDir)
+	assertStringSliceEqual(t, args, []string{
+		"-p", "2200",
+		"-o", "UserKnownHostsFile=" + managedPath,
+		"-o", "HashKnownHosts=no",
+		"ubuntu@app.internal",
+	})
+	// Keys in ~/.ssh/known_hosts may be stale; connect never copies them.
+	if _, err := os.Stat(managedPath); !os.IsNotExist(err) {
+		t.Fatalf("expected connect not to write the managed file, got %v", err)
+	}
+}
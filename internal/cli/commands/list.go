@@ -7,9 +7,11 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"sort"
 	"strconv"
 	"strings"
 	"text/tabwriter"
+	"time"
 
 	"github.com/emirhangumus/sshmanager/internal/model"
 	"github.com/emirhangumus/sshmanager/internal/store"
@@ -17,22 +19,49 @@ import (
 )
 
 type listOutputItem struct {
-	ID             string   `json:"id"`
-	Alias          string   `json:"alias,omitempty"`
-	Username       string   `json:"username"`
-	Host           string   `json:"host"`
-	Port           int      `json:"port"`
-	AuthMode       string   `json:"authMode"`
-	IdentityFile   string   `json:"identityFile,omitempty"`
-	ProxyJump      string   `json:"proxyJump,omitempty"`
-	LocalForwards  []string `json:"localForwards,omitempty"`
-	RemoteForwards []string `json:"remoteForwards,omitempty"`
-	ExtraSSHArgs   []string `json:"extraSSHArgs,omitempty"`
-	Group          string   `json:"group,omitempty"`
-	Tags           []string `json:"tags,omitempty"`
-	Description    string   `json:"description,omitempty"`
+	ID              string     `json:"id"`
+	Alias           string     `json:"alias,omitempty"`
+	Username        string     `json:"username"`
+	Host            string     `json:"host"`
+	Port            int        `json:"port"`
+	AuthMode        string     `json:"authMode"`
+	PasswordSource  string     `json:"passwordSource,omitempty"`
+	IdentityFile    string     `json:"identityFile,omitempty"`
+	ProxyJump       string     `json:"proxyJump,omitempty"`
+	LocalForwards   []string   `json:"localForwards,omitempty"`
+	RemoteForwards  []string   `json:"remoteForwards,omitempty"`
+	DynamicForwards []string   `json:"dynamicForwards,omitempty"`
+	ExtraSSHArgs    []string   `json:"extraSSHArgs,omitempty"`
+	Group           string     `json:"group,omitempty"`
+	Tags            []string   `json:"tags,omitempty"`
+	Description     string     `json:"description,omitempty"`
+	LastUsed        *time.Time `json:"lastUsed,omitempty"`
+	UseCount        int        `json:"useCount,omitempty"`
+	Extends         string     `json:"extends,omitempty"`
+	// Raw holds the stored values of a connection that extends a profile;
+	// the fields above are resolved through the profile chain.
+	Raw          *listRawFields `json:"raw,omitempty"`
+	ProfileError string         `json:"profileError,omitempty"`
 }
 
+type listRawFields struct {
+	Username        string   `json:"username,omitempty"`
+	Port            int      `json:"port,omitempty"`
+	AuthMode        string   `json:"authMode,omitempty"`
+	PasswordSource  string   `json:"passwordSource,omitempty"`
+	IdentityFile    string   `json:"identityFile,omitempty"`
+	ProxyJump       string   `json:"proxyJump,omitempty"`
+	LocalForwards   []string `json:"localForwards,omitempty"`
+	RemoteForwards  []string `json:"remoteForwards,omitempty"`
+	DynamicForwards []string `json:"dynamicForwards,omitempty"`
+	ExtraSSHArgs    []string `json:"extraSSHArgs,omitempty"`
+}
+
+const (
+	listSortLastUsed = "last-used"
+	listSortUseCount = "use-count"
+)
+
 func HandleList(connectionFilePath, secretKeyFilePath string, args []string) error {
 	return handleList(connectionFilePath, secretKeyFilePath, args, os.Stdout)
 }
@@ -41,10 +70,11 @@ func handleList(connectionFilePath, secretKeyFilePath string, args []string, out
 	fs := flag.NewFlagSet("list", flag.ContinueOnError)
 	fs.SetOutput(io.Discard)
 	jsonOutput := fs.Bool("json", false, "Output JSON")
-	field := fs.String("field", "", "Output only one field per line (id|alias|username|host|port|auth-mode|identity-file|proxy-jump|local-forwards|remote-forwards|extra-ssh-args|group|tags|description|target)")
+	field := fs.String("field", "", "Output only one field per line (id|alias|username|host|port|auth-mode|password-source|identity-file|proxy-jump|local-forwards|remote-forwards|extra-ssh-args|group|tags|description|extends|last-used|use-count|target)")
 	groupFilter := fs.String("group", "", "Filter by group")
 	var tagFilters stringListFlag
 	fs.Var(&tagFilters, "tag", "Filter by tag (repeatable)")
+	sortBy := fs.String("sort", "", "Sort by usage (last-used|use-count)")
 
 	if err := fs.Parse(args); err != nil {
 		return err
@@ -55,6 +85,12 @@ func handleList(connectionFilePath, secretKeyFilePath string, args []string, out
 	if *jsonOutput && strings.TrimSpace(*field) != "" {
 		return errors.New("--json and --field cannot be used together")
 	}
+	sortKey := strings.ToLower(strings.TrimSpace(*sortBy))
+	if sortKey != "" && sortKey != listSortLastUsed && sortKey != listSortUseCount {
+		return fmt.Errorf("unknown list sort %q, expected last-used or use-count", *sortBy)
+	}
+	fieldKey := strings.ToLower(strings.TrimSpace(*field))
+	needUsage := sortKey != "" || fieldKey == listSortLastUsed || fieldKey == listSortUseCount
 
 	connStore := store.NewConnectionStore(connectionFilePath, secretKeyFilePath)
 	connFile, err := connStore.Load()
@@ -66,28 +102,64 @@ func handleList(connectionFilePath, secretKeyFilePath string, args []string, out
 		return nil
 	}
 
+	var usage map[string]model.ConnectionUsage
+	if needUsage {
+		history, err := store.NewHistoryStore(connectionFilePath, secretKeyFilePath).Load()
+		if err != nil {
+			return err
+		}
+		usage = history.Usage()
+	}
+
 	items := make([]listOutputItem, 0, len(connFile.Connections))
-	for _, conn := range connFile.Connections {
-		if !matchesListFilters(conn, *groupFilter, tagFilters.Values()) {
+	for _, raw := range connFile.Connections {
+		if !matchesListFilters(raw, *groupFilter, tagFilters.Values()) {
 			continue
 		}
+		conn, resolveErr := connFile.ResolveConnection(raw)
 		items = append(items, listOutputItem{
-			ID:             conn.ID,
-			Alias:          strings.TrimSpace(conn.Alias),
-			Username:       conn.Username,
-			Host:           conn.Host,
-			Port:           conn.EffectivePort(),
-			AuthMode:       conn.EffectiveAuthMode(),
-			IdentityFile:   strings.TrimSpace(conn.IdentityFile),
-			ProxyJump:      strings.TrimSpace(conn.ProxyJump),
-			LocalForwards:  model.NormalizeStringList(conn.LocalForwards),
-			RemoteForwards: model.NormalizeStringList(conn.RemoteForwards),
-			ExtraSSHArgs:   model.NormalizeStringList(conn.ExtraSSHArgs),
-			Group:          strings.TrimSpace(conn.Group),
-			Tags:           model.NormalizeTags(conn.Tags),
-			Description:    conn.Description,
+			ID:              conn.ID,
+			Alias:           strings.TrimSpace(conn.Alias),
+			Username:        conn.Username,
+			Host:            conn.Host,
+			Port:            conn.EffectivePort(),
+			AuthMode:        conn.EffectiveAuthMode(),
+			PasswordSource:  conn.PasswordSource,
+			IdentityFile:    strings.TrimSpace(conn.IdentityFile),
+			ProxyJump:       strings.TrimSpace(conn.ProxyJump),
+			LocalForwards:   model.NormalizeStringList(conn.LocalForwards),
+			RemoteForwards:  model.NormalizeStringList(conn.RemoteForwards),
+			DynamicForwards: model.NormalizeStringList(conn.DynamicForwards),
+			ExtraSSHArgs:    model.NormalizeStringList(conn.ExtraSSHArgs),
+			Group:           strings.TrimSpace(conn.Group),
+			Tags:            model.NormalizeTags(conn.Tags),
+			Description:     conn.Description,
+			Extends:         strings.TrimSpace(raw.Extends),
 		})
+		if items[len(items)-1].Extends != "" {
+			items[len(items)-1].Raw = &listRawFields{
+				Username:        raw.Username,
+				Port:            raw.Port,
+				AuthMode:        raw.AuthMode,
+				PasswordSource:  raw.PasswordSource,
+				IdentityFile:    strings.TrimSpace(raw.IdentityFile),
+				ProxyJump:       strings.TrimSpace(raw.ProxyJump),
+				LocalForwards:   model.NormalizeStringList(raw.LocalForwards),
+				RemoteForwards:  model.NormalizeStringList(raw.RemoteForwards),
+				DynamicForwards: model.NormalizeStringList(raw.DynamicForwards),
+				ExtraSSHArgs:    model.NormalizeStringList(raw.ExtraSSHArgs),
+			}
+		}
+		if resolveErr != nil {
+			items[len(items)-1].ProfileError = resolveErr.Error()
+		}
+		if u, ok := usage[conn.ID]; ok {
+			lastUsed := u.LastUsed
+			items[len(items)-1].LastUsed = &lastUsed
+			items[len(items)-1].UseCount = u.UseCount
+		}
 	}
+	sortListItems(items, sortKey)
 	if len(items) == 0 {
 		_, _ = fmt.Fprintln(out, prompttext.DefaultPromptTexts.ErrorMessages.NoSSHConnectionsFound)
 		return nil
@@ -149,6 +221,8 @@ func listFieldValue(item listOutputItem, field string) (string, error) {
 		return strconv.Itoa(item.Port), nil
 	case "auth-mode", "auth_mode", "authmode":
 		return item.AuthMode, nil
+	case "password-source", "password_source", "passwordsource":
+		return item.PasswordSource, nil
 	case "identity-file", "identity_file", "identityfile":
 		return item.IdentityFile, nil
 	case "proxy-jump", "proxy_jump", "proxyjump":
@@ -157,6 +231,8 @@ func listFieldValue(item listOutputItem, field string) (string, error) {
 		return strings.Join(item.LocalForwards, ","), nil
 	case "remote-forwards", "remote_forwards", "remoteforwards":
 		return strings.Join(item.RemoteForwards, ","), nil
+	case "dynamic-forwards", "dynamic_forwards", "dynamicforwards":
+		return strings.Join(item.DynamicForwards, ","), nil
 	case "extra-ssh-args", "extra_ssh_args", "extrasshargs":
 		return strings.Join(item.ExtraSSHArgs, ","), nil
 	case "group":
@@ -165,6 +241,15 @@ func listFieldValue(item listOutputItem, field string) (string, error) {
 		return strings.Join(item.Tags, ","), nil
 	case "description":
 		return item.Description, nil
+	case "extends":
+		return item.Extends, nil
+	case listSortLastUsed, "last_used", "lastused":
+		if item.LastUsed == nil {
+			return "", nil
+		}
+		return item.LastUsed.Format(time.RFC3339), nil
+	case listSortUseCount, "use_count", "usecount":
+		return strconv.Itoa(item.UseCount), nil
 	case "target":
 		return fmt.Sprintf("%s@%s", item.Username, item.Host), nil
 	default:
@@ -172,6 +257,25 @@ func listFieldValue(item listOutputItem, field string) (string, error) {
 	}
 }
 
+// sortListItems orders items by descending usage. Never-used connections
+// keep their stored order at the end.
+func sortListItems(items []listOutputItem, sortKey string) {
+	switch sortKey {
+	case listSortLastUsed:
+		sort.SliceStable(items, func(i, j int) bool {
+			a, b := items[i].LastUsed, items[j].LastUsed
+			if a == nil || b == nil {
+				return a != nil
+			}
+			return a.After(*b)
+		})
+	case listSortUseCount:
+		sort.SliceStable(items, func(i, j int) bool {
+			return items[i].UseCount > items[j].UseCount
+		})
+	}
+}
+
 func matchesListFilters(conn model.SSHConnection, groupFilter string, tagFilters []string) bool {
 	groupNeedle := strings.ToLower(strings.TrimSpace(groupFilter))
 	if groupNeedle != "" {
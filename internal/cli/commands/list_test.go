@@ -84,6 +84,9 @@ func TestHandleListJSONOutput(t *testing.T) {
 			RemoteForwards: []string{
 				"9000:127.0.0.1:9000",
 			},
+			DynamicForwards: []string{
+				"1080",
+			},
 			ExtraSSHArgs: []string{
 				"-vv",
 			},
@@ -130,6 +133,9 @@ func TestHandleListJSONOutput(t *testing.T) {
 	if payload[0]["group"] != "production" {
 		t.Fatalf("unexpected group: %v", payload[0]["group"])
 	}
+	if dynamicForwards, ok := payload[0]["dynamicForwards"].([]any); !ok || len(dynamicForwards) != 1 || dynamicForwards[0] != "1080" {
+		t.Fatalf("unexpected dynamicForwards: %v", payload[0]["dynamicForwards"])
+	}
 	tags, ok := payload[0]["tags"].([]any)
 	if !ok || len(tags) != 2 {
 		t.Fatalf("unexpected tags payload: %v", payload[0]["tags"])
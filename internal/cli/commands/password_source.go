@@ -0,0 +1,109 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/emirhangumus/sshmanager/internal/model"
+	"github.com/emirhangumus/sshmanager/internal/secret"
+	prompttext "github.com/emirhangumus/sshmanager/internal/ui/prompt"
+	"github.com/emirhangumus/sshmanager/internal/vault"
+	"golang.org/x/term"
+)
+
+// passwordResolver looks up external password sources for the vault in
+// vaultDir. Command sources only run once they are in the per-machine
+// allowlist in the SSH Manager home of that vault; without a vault no
+// command is trusted.
+func passwordResolver(vaultDir string) *secret.Resolver {
+	return secret.DefaultResolver(trustedCommandsPath(vaultDir), confirmPasswordCommand)
+}
+
+func trustedCommandsPath(vaultDir string) string {
+	if strings.TrimSpace(vaultDir) == "" {
+		return ""
+	}
+	return secret.TrustedCommandsPath(vault.HomeOf(vaultDir))
+}
+
+// confirmPasswordCommand asks before a command source that is not yet
+// trusted on this machine runs for the first time. Without a terminal the
+// command is refused.
+func confirmPasswordCommand(command string) (bool, error) {
+	if !term.IsTerminal(int(os.Stdin.Fd())) {
+		return false, nil
+	}
+	value, err := prompttext.InputPrompt(
+		fmt.Sprintf("Password command %q is not trusted on this machine. Type 'yes' to run and trust it", command),
+		"",
+		false,
+		nil,
+	)
+	if err != nil {
+		if prompttext.IsCancelError(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	return strings.EqualFold(strings.TrimSpace(value), "yes"), nil
+}
+
+// normalizePasswordSource validates a passwordSource value and returns its
+// canonical form, or "" for inline passwords.
+func normalizePasswordSource(raw string) (string, error) {
+	src, err := secret.ParseSource(raw)
+	if err != nil {
+		return "", err
+	}
+	if src.IsInline() {
+		return "", nil
+	}
+	return src.String(), nil
+}
+
+// trustLocalPasswordSource approves a command source the local user set
+// with add, edit or profile, so it runs without asking.
+func trustLocalPasswordSource(vaultDir, raw string) error {
+	if strings.TrimSpace(raw) == "" {
+		return nil
+	}
+	if err := passwordResolver(vaultDir).TrustCommand(raw); err != nil {
+		return fmt.Errorf("failed to trust password command: %w", err)
+	}
+	return nil
+}
+
+// warnUntrustedPasswordCommands flags connections and profiles that arrived
+// from elsewhere with command sources not yet trusted on this machine.
+func warnUntrustedPasswordCommands(vaultDir string, out io.Writer, connFile *model.ConnectionFile) {
+	resolver := passwordResolver(vaultDir)
+	var labels []string
+	for _, profile := range connFile.Profiles {
+		if trusted, err := resolver.IsCommandTrusted(profile.PasswordSource); err == nil && !trusted {
+			labels = append(labels, fmt.Sprintf("profile %q", profile.Name))
+		}
+	}
+	for _, conn := range connFile.Connections {
+		if trusted, err := resolver.IsCommandTrusted(conn.PasswordSource); err == nil && !trusted {
+			labels = append(labels, connectionLabel(conn))
+		}
+	}
+	if len(labels) == 0 {
+		return
+	}
+	_, _ = fmt.Fprintf(out, "Warning: %s use(s) a command password source that is not trusted on this machine; check it with 'sshmanager list --json' before approving it at the first connect.\n", strings.Join(labels, ", "))
+}
+
+// resolveConnectionPassword returns the password for conn, running its
+// password source if it has one. It is only called right before a session
+// is opened so external secrets are never held longer than needed.
+func resolveConnectionPassword(vaultDir string, conn *model.SSHConnection) (string, error) {
+	password, err := passwordResolver(vaultDir).Resolve(conn.PasswordSource, conn.Password)
+	if errors.Is(err, secret.ErrCommandNotTrusted) {
+		return "", fmt.Errorf("%w; approve it from a terminal or set it again with 'sshmanager edit --new-password-source'", err)
+	}
+	return password, err
+}
@@ -0,0 +1,150 @@
+package commands
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/emirhangumus/sshmanager/internal/model"
+	"github.com/emirhangumus/sshmanager/internal/secret"
+	"github.com/emirhangumus/sshmanager/internal/vault"
+)
+
+func useFakeKeyring(t *testing.T, content string) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "keyring.yaml")
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+	t.Setenv(secret.FakeKeyringEnvVar, path)
+}
+
+func TestHandleAddArgsStoresPasswordSourceWithoutPassword(t *testing.T) {
+	connPath, keyPath := prepareTransferFixture(t, nil)
+
+	err := handleAddArgs(connPath, keyPath, []string{
+		"--host", "db.internal",
+		"--username", "postgres",
+		"--password", "ignored",
+		"--password-source", "KEYRING:work/db",
+		"--alias", "db",
+	}, ioDiscard())
+	if err != nil {
+		t.Fatalf("handleAddArgs failed: %v", err)
+	}
+
+	loaded := loadTransferConnections(t, connPath, keyPath)
+	conn := loaded.GetConnectionByAlias("db")
+	if conn.Password != "" || conn.PasswordSource != "keyring:work/db" || conn.AuthMode != model.AuthModePassword {
+		t.Fatalf("unexpected stored connection: %+v", conn)
+	}
+
+	err = handleAddArgs(connPath, keyPath, []string{"--host", "h", "--username", "u", "--password-source", "vault:x"}, ioDiscard())
+	if err == nil || !strings.Contains(err.Error(), "passwordSource") {
+		t.Fatalf("expected invalid passwordSource error, got %v", err)
+	}
+}
+
+func TestBuildConnectInvocationResolvesPasswordSource(t *testing.T) {
+	useFakeKeyring(t, "sshmanager:\n  prod: from-keyring\n")
+
+	vaultDir := t.TempDir()
+	trusted := secret.TrustedCommands{Path: secret.TrustedCommandsPath(vaultDir)}
+	if err := trusted.Trust("echo from-command"); err != nil {
+		t.Fatalf("Trust failed: %v", err)
+	}
+
+	conn := &model.SSHConnection{Username: "u", Host: "h", AuthMode: model.AuthModePassword, PasswordSource: "keyring:prod"}
+	bin, _, env, err := buildConnectInvocation(vaultDir, conn)
+	if err != nil {
+		t.Fatalf("buildConnectInvocation failed: %v", err)
+	}
+	if bin != "sshpass" || len(env) != 1 || env[0] != "SSHPASS=from-keyring" {
+		t.Fatalf("unexpected invocation: %s %v", bin, env)
+	}
+
+	conn.PasswordSource = "command:echo from-command"
+	if _, _, env, err = buildConnectInvocation(vaultDir, conn); err != nil || env[0] != "SSHPASS=from-command" {
+		t.Fatalf("expected command password, got %v, %v", env, err)
+	}
+
+	// Without a vault there is no allowlist, so no command is trusted.
+	if _, _, _, err := buildConnectInvocation("", conn); !errors.Is(err, secret.ErrCommandNotTrusted) {
+		t.Fatalf("expected ErrCommandNotTrusted without a vault, got %v", err)
+	}
+
+	conn.PasswordSource = "keyring:missing"
+	if _, _, _, err := buildConnectInvocation(vaultDir, conn); !errors.Is(err, secret.ErrSecretNotFound) {
+		t.Fatalf("expected ErrSecretNotFound, got %v", err)
+	}
+}
+
+func TestProfilePasswordSourceIsInheritedWithAuth(t *testing.T) {
+	useFakeKeyring(t, "sshmanager:\n  shared: pw\n")
+	connPath, keyPath := prepareTransferFixture(t, nil)
+
+	if err := handleProfile(connPath, keyPath, []string{"add", "--username", "ops", "--password-source", "keyring:shared", "pwd"}, ioDiscard()); err != nil {
+		t.Fatalf("profile add failed: %v", err)
+	}
+	if err := handleAddArgs(connPath, keyPath, []string{"--host", "h", "--extends", "pwd", "--alias", "h"}, ioDiscard()); err != nil {
+		t.Fatalf("add failed: %v", err)
+	}
+
+	loaded := loadTransferConnections(t, connPath, keyPath)
+	resolved, err := loaded.ResolveConnection(*loaded.GetConnectionByAlias("h"))
+	if err != nil {
+		t.Fatalf("ResolveConnection failed: %v", err)
+	}
+	_, _, env, err := buildConnectInvocation("", &resolved)
+	if err != nil || len(env) != 1 || env[0] != "SSHPASS=pw" {
+		t.Fatalf("expected inherited keyring password, got %v, %v", env, err)
+	}
+}
+
+// trustedCommandsOf returns the allowlist of the SSH Manager home the vault
+// of connPath belongs to.
+func trustedCommandsOf(connPath string) secret.TrustedCommands {
+	return secret.TrustedCommands{Path: secret.TrustedCommandsPath(vault.HomeOf(filepath.Dir(connPath)))}
+}
+
+func TestLocallySetCommandSourcesAreTrusted(t *testing.T) {
+	connPath, keyPath := prepareTransferFixture(t, nil)
+	trusted := trustedCommandsOf(connPath)
+
+	if err := handleAddArgs(connPath, keyPath, []string{"--host", "h", "--username", "u", "--password-source", "command:pass show db", "--alias", "db"}, ioDiscard()); err != nil {
+		t.Fatalf("add failed: %v", err)
+	}
+	if err := handleProfile(connPath, keyPath, []string{"add", "--password-source", "command:pass show shared", "shared"}, ioDiscard()); err != nil {
+		t.Fatalf("profile add failed: %v", err)
+	}
+	for _, command := range []string{"pass show db", "pass show shared"} {
+		if ok, err := trusted.IsTrusted(command); err != nil || !ok {
+			t.Fatalf("expected %q to be trusted after a local add, got %v, %v", command, ok, err)
+		}
+	}
+}
+
+func TestImportedCommandSourcesAreFlaggedAndRefused(t *testing.T) {
+	connPath, keyPath := prepareTransferFixture(t, nil)
+
+	importPath := filepath.Join(t.TempDir(), "import.yaml")
+	payload := "connections:\n  - id: c-1\n    username: u\n    host: h\n    authMode: password\n    passwordSource: \"command:touch /tmp/pwned\"\n    alias: db\n"
+	if err := os.WriteFile(importPath, []byte(payload), 0o600); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+
+	var out strings.Builder
+	if err := handleImport(connPath, keyPath, []string{"--in", importPath}, &out); err != nil {
+		t.Fatalf("import failed: %v", err)
+	}
+	if !strings.Contains(out.String(), `connection "db" use(s) a command password source that is not trusted`) {
+		t.Fatalf("expected the imported command source to be flagged, got %q", out.String())
+	}
+
+	loaded := loadTransferConnections(t, connPath, keyPath)
+	if _, _, _, err := buildConnectInvocation(filepath.Dir(connPath), loaded.GetConnectionByAlias("db")); !errors.Is(err, secret.ErrCommandNotTrusted) {
+		t.Fatalf("expected the untrusted command to be refused, got %v", err)
+	}
+}
@@ -0,0 +1,566 @@
+package commands
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/emirhangumus/sshmanager/internal/model"
+	"github.com/emirhangumus/sshmanager/internal/store"
+)
+
+type profileOutputItem struct {
+	Name            string   `json:"name"`
+	Extends         string   `json:"extends,omitempty"`
+	Description     string   `json:"description,omitempty"`
+	Username        string   `json:"username,omitempty"`
+	Port            int      `json:"port,omitempty"`
+	AuthMode        string   `json:"authMode,omitempty"`
+	PasswordSource  string   `json:"passwordSource,omitempty"`
+	IdentityFile    string   `json:"identityFile,omitempty"`
+	ProxyJump       string   `json:"proxyJump,omitempty"`
+	LocalForwards   []string `json:"localForwards,omitempty"`
+	RemoteForwards  []string `json:"remoteForwards,omitempty"`
+	DynamicForwards []string `json:"dynamicForwards,omitempty"`
+	ExtraSSHArgs    []string `json:"extraSSHArgs,omitempty"`
+	UsedBy          []string `json:"usedBy,omitempty"`
+}
+
+func HandleProfile(connectionFilePath, secretKeyFilePath string, args []string) error {
+	return handleProfile(connectionFilePath, secretKeyFilePath, args, os.Stdout)
+}
+
+func handleProfile(connectionFilePath, secretKeyFilePath string, args []string, out io.Writer) error {
+	if len(args) == 0 {
+		return errors.New("profile: missing subcommand (add|edit|remove|list)")
+	}
+
+	switch strings.ToLower(strings.TrimSpace(args[0])) {
+	case "add":
+		return handleProfileAdd(connectionFilePath, secretKeyFilePath, args[1:], out)
+	case "edit":
+		return handleProfileEdit(connectionFilePath, secretKeyFilePath, args[1:], out)
+	case "remove", "rm":
+		return handleProfileRemove(connectionFilePath, secretKeyFilePath, args[1:], out)
+	case "list", "ls":
+		return handleProfileList(connectionFilePath, secretKeyFilePath, args[1:], out)
+	default:
+		return fmt.Errorf("profile: unknown subcommand %q (use add, edit, remove or list)", args[0])
+	}
+}
+
+func handleProfileAdd(connectionFilePath, secretKeyFilePath string, args []string, out io.Writer) error {
+	fs := flag.NewFlagSet("profile add", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+
+	name := fs.String("name", "", "Profile name")
+	extends := fs.String("extends", "", "Parent profile name")
+	description := fs.String("description", "", "Profile description")
+	username := fs.String("username", "", "SSH username")
+	port := fs.Int("port", 0, "SSH port")
+	authMode := fs.String("auth-mode", "", "Auth mode: password|key|agent")
+	password := fs.String("password", "", "SSH password (password mode)")
+	passwordSource := fs.String("password-source", "", "Password source: inline|command:<cmd>|keyring:[<service>/]<account>")
+	identityFile := fs.String("identity-file", "", "Identity file path (key mode)")
+	proxyJump := fs.String("proxy-jump", "", "ProxyJump spec ([user@]host[:port][,[user@]host[:port]...])")
+	var localForwards stringListFlag
+	var remoteForwards stringListFlag
+	var dynamicForwards stringListFlag
+	var extraSSHArgs stringListFlag
+	fs.Var(&localForwards, "local-forward", "Local forwarding spec [bind_address:]port:host:hostport (repeatable)")
+	fs.Var(&remoteForwards, "remote-forward", "Remote forwarding spec [bind_address:]port:host:hostport (repeatable)")
+	fs.Var(&dynamicForwards, "dynamic-forward", "Dynamic (SOCKS) forwarding spec [bind_address:]port (repeatable)")
+	fs.Var(&extraSSHArgs, "extra-ssh-arg", "Extra ssh argument token (repeatable, controlled)")
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	profileName, err := resolveProfileName(*name, fs.Args(), "profile add")
+	if err != nil {
+		return err
+	}
+
+	profile, err := normalizeProfile(model.ConnectionProfile{
+		Name:            profileName,
+		Extends:         *extends,
+		Description:     *description,
+		Username:        *username,
+		Port:            *port,
+		AuthMode:        *authMode,
+		Password:        *password,
+		PasswordSource:  *passwordSource,
+		IdentityFile:    *identityFile,
+		ProxyJump:       *proxyJump,
+		LocalForwards:   localForwards.Values(),
+		RemoteForwards:  remoteForwards.Values(),
+		DynamicForwards: dynamicForwards.Values(),
+		ExtraSSHArgs:    extraSSHArgs.Values(),
+	})
+	if err != nil {
+		return err
+	}
+
+	connStore := store.NewConnectionStore(connectionFilePath, secretKeyFilePath, store.WithJournalCommand("profile"))
+	if err := connStore.Update(func(connFile *model.ConnectionFile) error {
+		return connFile.AddProfile(profile)
+	}); err != nil {
+		return err
+	}
+	if err := trustLocalPasswordSource(filepath.Dir(connectionFilePath), profile.PasswordSource); err != nil {
+		return err
+	}
+
+	_, _ = fmt.Fprintf(out, "Profile %s saved.\n", profile.Name)
+	return nil
+}
+
+func handleProfileEdit(connectionFilePath, secretKeyFilePath string, args []string, out io.Writer) error {
+	fs := flag.NewFlagSet("profile edit", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+
+	name := fs.String("name", "", "Profile name to edit")
+	newExtends := fs.String("new-extends", "", "New parent profile")
+	newDescription := fs.String("new-description", "", "New description")
+	newUsername := fs.String("new-username", "", "New username")
+	newPort := fs.Int("new-port", -1, "New SSH port (0 clears)")
+	newAuthMode := fs.String("new-auth-mode", "", "New auth mode: password|key|agent")
+	newPassword := fs.String("new-password", "", "New password")
+	newPasswordSource := fs.String("new-password-source", "", "New password source: inline|command:<cmd>|keyring:[<service>/]<account>")
+	newIdentityFile := fs.String("new-identity-file", "", "New identity file path")
+	newProxyJump := fs.String("new-proxy-jump", "", "New ProxyJump spec")
+	clearExtends := fs.Bool("clear-extends", false, "Clear parent profile")
+	clearDescription := fs.Bool("clear-description", false, "Clear description")
+	clearUsername := fs.Bool("clear-username", false, "Clear username")
+	clearAuth := fs.Bool("clear-auth", false, "Clear auth mode, password, password source and identity file")
+	clearProxyJump := fs.Bool("clear-proxy-jump", false, "Clear proxy jump")
+	clearLocalForwards := fs.Bool("clear-local-forwards", false, "Clear local forward specs")
+	clearRemoteForwards := fs.Bool("clear-remote-forwards", false, "Clear remote forward specs")
+	clearDynamicForwards := fs.Bool("clear-dynamic-forwards", false, "Clear dynamic forward specs")
+	clearExtraSSHArgs := fs.Bool("clear-extra-ssh-args", false, "Clear extra ssh args")
+	var newLocalForwards stringListFlag
+	var newRemoteForwards stringListFlag
+	var newDynamicForwards stringListFlag
+	var newExtraSSHArgs stringListFlag
+	fs.Var(&newLocalForwards, "new-local-forward", "Replace local forward list with provided values (repeatable)")
+	fs.Var(&newRemoteForwards, "new-remote-forward", "Replace remote forward list with provided values (repeatable)")
+	fs.Var(&newDynamicForwards, "new-dynamic-forward", "Replace dynamic forward list with provided values (repeatable)")
+	fs.Var(&newExtraSSHArgs, "new-extra-ssh-arg", "Replace extra ssh args with provided values (repeatable)")
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	profileName, err := resolveProfileName(*name, fs.Args(), "profile edit")
+	if err != nil {
+		return err
+	}
+
+	conflicts := []struct {
+		set   bool
+		clear bool
+		names string
+	}{
+		{strings.TrimSpace(*newExtends) != "", *clearExtends, "--new-extends or --clear-extends"},
+		{strings.TrimSpace(*newDescription) != "", *clearDescription, "--new-description or --clear-description"},
+		{strings.TrimSpace(*newUsername) != "", *clearUsername, "--new-username or --clear-username"},
+		{strings.TrimSpace(*newAuthMode) != "" || *newPassword != "" || strings.TrimSpace(*newPasswordSource) != "" || strings.TrimSpace(*newIdentityFile) != "", *clearAuth, "--new-auth-mode/--new-password/--new-password-source/--new-identity-file or --clear-auth"},
+		{strings.TrimSpace(*newProxyJump) != "", *clearProxyJump, "--new-proxy-jump or --clear-proxy-jump"},
+		{len(newLocalForwards) > 0, *clearLocalForwards, "--new-local-forward or --clear-local-forwards"},
+		{len(newRemoteForwards) > 0, *clearRemoteForwards, "--new-remote-forward or --clear-remote-forwards"},
+		{len(newDynamicForwards) > 0, *clearDynamicForwards, "--new-dynamic-forward or --clear-dynamic-forwards"},
+		{len(newExtraSSHArgs) > 0, *clearExtraSSHArgs, "--new-extra-ssh-arg or --clear-extra-ssh-args"},
+	}
+	hasUpdate := *newPort >= 0
+	for _, c := range conflicts {
+		if c.set && c.clear {
+			return fmt.Errorf("profile edit: use either %s, not both", c.names)
+		}
+		hasUpdate = hasUpdate || c.set || c.clear
+	}
+	if !hasUpdate {
+		return errors.New("profile edit: no update fields provided")
+	}
+
+	connStore := store.NewConnectionStore(connectionFilePath, secretKeyFilePath, store.WithJournalCommand("profile"))
+	found := false
+	if err := connStore.Update(func(connFile *model.ConnectionFile) error {
+		current := connFile.GetProfile(profileName)
+		if current == nil {
+			return nil
+		}
+		found = true
+
+		updated := *current
+		if *clearExtends {
+			updated.Extends = ""
+		} else if v := strings.TrimSpace(*newExtends); v != "" {
+			updated.Extends = v
+		}
+		if *clearDescription {
+			updated.Description = ""
+		} else if v := strings.TrimSpace(*newDescription); v != "" {
+			updated.Description = v
+		}
+		if *clearUsername {
+			updated.Username = ""
+		} else if v := strings.TrimSpace(*newUsername); v != "" {
+			updated.Username = v
+		}
+		if *newPort >= 0 {
+			updated.Port = *newPort
+		}
+		if *clearAuth {
+			updated.AuthMode, updated.Password, updated.PasswordSource, updated.IdentityFile = "", "", "", ""
+		} else {
+			if v := strings.TrimSpace(*newAuthMode); v != "" {
+				updated.AuthMode = v
+			}
+			if *newPassword != "" {
+				updated.Password = *newPassword
+				updated.PasswordSource = ""
+			}
+			if v := strings.TrimSpace(*newPasswordSource); v != "" {
+				updated.PasswordSource = v
+			}
+			if v := strings.TrimSpace(*newIdentityFile); v != "" {
+				updated.IdentityFile = v
+			}
+		}
+		if *clearProxyJump {
+			updated.ProxyJump = ""
+		} else if v := strings.TrimSpace(*newProxyJump); v != "" {
+			updated.ProxyJump = v
+		}
+		if *clearLocalForwards {
+			updated.LocalForwards = nil
+		} else if len(newLocalForwards) > 0 {
+			updated.LocalForwards = newLocalForwards.Values()
+		}
+		if *clearRemoteForwards {
+			updated.RemoteForwards = nil
+		} else if len(newRemoteForwards) > 0 {
+			updated.RemoteForwards = newRemoteForwards.Values()
+		}
+		if *clearDynamicForwards {
+			updated.DynamicForwards = nil
+		} else if len(newDynamicForwards) > 0 {
+			updated.DynamicForwards = newDynamicForwards.Values()
+		}
+		if *clearExtraSSHArgs {
+			updated.ExtraSSHArgs = nil
+		} else if len(newExtraSSHArgs) > 0 {
+			updated.ExtraSSHArgs = newExtraSSHArgs.Values()
+		}
+
+		normalized, err := normalizeProfile(updated)
+		if err != nil {
+			return err
+		}
+		if _, err := connFile.UpdateProfile(current.Name, normalized); err != nil {
+			return err
+		}
+		return validateProfileDependents(connFile, normalized.Name)
+	}); err != nil {
+		return err
+	}
+	if !found {
+		return fmt.Errorf("profile edit: %w: %s", model.ErrProfileNotFound, profileName)
+	}
+	if err := trustLocalPasswordSource(filepath.Dir(connectionFilePath), *newPasswordSource); err != nil {
+		return err
+	}
+
+	_, _ = fmt.Fprintf(out, "Profile %s updated.\n", profileName)
+	return nil
+}
+
+func handleProfileRemove(connectionFilePath, secretKeyFilePath string, args []string, out io.Writer) error {
+	fs := flag.NewFlagSet("profile remove", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	name := fs.String("name", "", "Profile name to remove")
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	profileName, err := resolveProfileName(*name, fs.Args(), "profile remove")
+	if err != nil {
+		return err
+	}
+
+	connStore := store.NewConnectionStore(connectionFilePath, secretKeyFilePath, store.WithJournalCommand("profile"))
+	if err := connStore.Update(func(connFile *model.ConnectionFile) error {
+		if connFile.GetProfile(profileName) == nil {
+			return fmt.Errorf("profile remove: %w: %s", model.ErrProfileNotFound, profileName)
+		}
+		profiles, connections := connFile.ProfileDependents(profileName)
+		if len(profiles) > 0 || len(connections) > 0 {
+			users := append(append([]string(nil), profiles...), connections...)
+			return fmt.Errorf("profile remove: %s is still extended by %s", profileName, strings.Join(users, ", "))
+		}
+		connFile.RemoveProfile(profileName)
+		return nil
+	}); err != nil {
+		return err
+	}
+
+	_, _ = fmt.Fprintf(out, "Profile %s removed.\n", profileName)
+	return nil
+}
+
+func handleProfileList(connectionFilePath, secretKeyFilePath string, args []string, out io.Writer) error {
+	fs := flag.NewFlagSet("profile list", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	jsonOutput := fs.Bool("json", false, "Output JSON")
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() > 0 {
+		return fmt.Errorf("profile list: unexpected arguments: %s", strings.Join(fs.Args(), " "))
+	}
+
+	connFile, err := store.NewConnectionStore(connectionFilePath, secretKeyFilePath).Load()
+	if err != nil {
+		return err
+	}
+
+	items := make([]profileOutputItem, 0, len(connFile.Profiles))
+	for _, profile := range connFile.Profiles {
+		profiles, connections := connFile.ProfileDependents(profile.Name)
+		item := profileOutputItem{
+			Name:            profile.Name,
+			Extends:         profile.Extends,
+			Description:     profile.Description,
+			Username:        profile.Username,
+			Port:            profile.Port,
+			PasswordSource:  profile.PasswordSource,
+			IdentityFile:    profile.IdentityFile,
+			ProxyJump:       profile.ProxyJump,
+			LocalForwards:   profile.LocalForwards,
+			RemoteForwards:  profile.RemoteForwards,
+			DynamicForwards: profile.DynamicForwards,
+			ExtraSSHArgs:    profile.ExtraSSHArgs,
+			UsedBy:          append(profiles, connections...),
+		}
+		if model.HasAuthSettings(profile.AuthMode, profile.Password, profile.PasswordSource, profile.IdentityFile) {
+			item.AuthMode = profileAuthMode(profile)
+		}
+		items = append(items, item)
+	}
+
+	if *jsonOutput {
+		enc := json.NewEncoder(out)
+		enc.SetIndent("", "  ")
+		return enc.Encode(items)
+	}
+
+	if len(items) == 0 {
+		_, _ = fmt.Fprintln(out, "No profiles found.")
+		return nil
+	}
+
+	tw := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(tw, "NAME\tEXTENDS\tUSERNAME\tPORT\tAUTH_MODE\tPROXY_JUMP\tUSED_BY\tDESCRIPTION")
+	for _, item := range items {
+		port := "-"
+		if item.Port > 0 {
+			port = strconv.Itoa(item.Port)
+		}
+		_, _ = fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\t%d\t%s\n",
+			item.Name,
+			dashIfEmpty(item.Extends),
+			dashIfEmpty(item.Username),
+			port,
+			dashIfEmpty(item.AuthMode),
+			dashIfEmpty(item.ProxyJump),
+			len(item.UsedBy),
+			item.Description,
+		)
+	}
+	return tw.Flush()
+}
+
+func profileAuthMode(profile model.ConnectionProfile) string {
+	return model.SSHConnection{
+		AuthMode:       profile.AuthMode,
+		Password:       profile.Password,
+		PasswordSource: profile.PasswordSource,
+		IdentityFile:   profile.IdentityFile,
+	}.EffectiveAuthMode()
+}
+
+func resolveProfileName(nameFlag string, positional []string, command string) (string, error) {
+	name := strings.TrimSpace(nameFlag)
+	if len(positional) > 0 {
+		if name != "" || len(positional) > 1 {
+			return "", fmt.Errorf("%s: unexpected positional arguments: %s", command, strings.Join(positional, " "))
+		}
+		name = strings.TrimSpace(positional[0])
+	}
+	if name == "" {
+		return "", fmt.Errorf("%s: missing profile name, set --name or pass it as the last argument", command)
+	}
+	return name, nil
+}
+
+// normalizeProfile applies the same field rules as normalizeImportedConnection.
+// Every field is optional; auth settings are only checked when one is set.
+func normalizeProfile(profile model.ConnectionProfile) (model.ConnectionProfile, error) {
+	profile.Name = strings.TrimSpace(profile.Name)
+	profile.Extends = strings.TrimSpace(profile.Extends)
+	profile.Description = strings.TrimSpace(profile.Description)
+	profile.Username = strings.TrimSpace(profile.Username)
+	profile.AuthMode = model.NormalizeAuthMode(profile.AuthMode)
+	profile.IdentityFile = strings.TrimSpace(profile.IdentityFile)
+	profile.ProxyJump = strings.TrimSpace(profile.ProxyJump)
+	profile.LocalForwards = model.NormalizeStringList(profile.LocalForwards)
+	profile.RemoteForwards = model.NormalizeStringList(profile.RemoteForwards)
+	profile.DynamicForwards = model.NormalizeStringList(profile.DynamicForwards)
+	profile.ExtraSSHArgs = model.NormalizeStringList(profile.ExtraSSHArgs)
+
+	if err := model.ValidateProfileName(profile.Name); err != nil {
+		return model.ConnectionProfile{}, err
+	}
+	if profile.Extends != "" {
+		if err := model.ValidateProfileName(profile.Extends); err != nil {
+			return model.ConnectionProfile{}, fmt.Errorf("profile %s has invalid extends: %w", profile.Name, err)
+		}
+	}
+	if profile.Port < 0 || profile.Port > 65535 {
+		return model.ConnectionProfile{}, fmt.Errorf("profile %s has invalid port %d", profile.Name, profile.Port)
+	}
+	if err := model.ValidateProxyJump(profile.ProxyJump); err != nil {
+		return model.ConnectionProfile{}, fmt.Errorf("profile %s has invalid proxyJump: %w", profile.Name, err)
+	}
+	if err := model.ValidateForwardSpecs(profile.LocalForwards); err != nil {
+		return model.ConnectionProfile{}, fmt.Errorf("profile %s has invalid localForwards: %w", profile.Name, err)
+	}
+	if err := model.ValidateForwardSpecs(profile.RemoteForwards); err != nil {
+		return model.ConnectionProfile{}, fmt.Errorf("profile %s has invalid remoteForwards: %w", profile.Name, err)
+	}
+	if err := model.ValidateDynamicForwardSpecs(profile.DynamicForwards); err != nil {
+		return model.ConnectionProfile{}, fmt.Errorf("profile %s has invalid dynamicForwards: %w", profile.Name, err)
+	}
+	if err := model.ValidateExtraSSHArgs(profile.ExtraSSHArgs); err != nil {
+		return model.ConnectionProfile{}, fmt.Errorf("profile %s has invalid extraSSHArgs: %w", profile.Name, err)
+	}
+
+	passwordSource, err := normalizePasswordSource(profile.PasswordSource)
+	if err != nil {
+		return model.ConnectionProfile{}, fmt.Errorf("profile %s has invalid passwordSource: %w", profile.Name, err)
+	}
+	profile.PasswordSource = passwordSource
+
+	if !model.HasAuthSettings(profile.AuthMode, profile.Password, profile.PasswordSource, profile.IdentityFile) {
+		return profile, nil
+	}
+	if profile.AuthMode != "" && !model.IsValidAuthMode(profile.AuthMode) {
+		return model.ConnectionProfile{}, fmt.Errorf("profile %s has unsupported auth mode %q", profile.Name, profile.AuthMode)
+	}
+	profile.AuthMode = profileAuthMode(profile)
+	switch profile.AuthMode {
+	case model.AuthModePassword:
+		profile.IdentityFile = ""
+		if profile.PasswordSource != "" {
+			profile.Password = ""
+		} else if strings.TrimSpace(profile.Password) == "" {
+			return model.ConnectionProfile{}, fmt.Errorf("profile %s uses password auth but has no password", profile.Name)
+		}
+	case model.AuthModeKey:
+		profile.Password = ""
+		profile.PasswordSource = ""
+		if profile.IdentityFile == "" {
+			return model.ConnectionProfile{}, fmt.Errorf("profile %s uses key auth but has no identityFile", profile.Name)
+		}
+	case model.AuthModeAgent:
+		profile.Password = ""
+		profile.PasswordSource = ""
+		profile.IdentityFile = ""
+	}
+	return profile, nil
+}
+
+// validateResolvedConnection checks that a connection extending a profile
+// is complete once its profile chain is applied.
+func validateResolvedConnection(connFile *model.ConnectionFile, conn model.SSHConnection) error {
+	if strings.TrimSpace(conn.Extends) == "" {
+		return nil
+	}
+	resolved, err := connFile.ResolveConnection(conn)
+	if err != nil {
+		return err
+	}
+	resolved.Extends = ""
+	if _, err := normalizeImportedConnection(resolved); err != nil {
+		return fmt.Errorf("%s extends %s: %w", connectionLabel(conn), conn.Extends, err)
+	}
+	return nil
+}
+
+// validateProfileDependents re-checks every connection that inherits from
+// name, directly or through other profiles.
+func validateProfileDependents(connFile *model.ConnectionFile, name string) error {
+	for _, conn := range connFile.Connections {
+		chain, err := connFile.ProfileChain(conn.Extends)
+		if err != nil {
+			return err
+		}
+		for _, profile := range chain {
+			if strings.EqualFold(profile.Name, name) {
+				if err := validateResolvedConnection(connFile, conn); err != nil {
+					return err
+				}
+				break
+			}
+		}
+	}
+	return nil
+}
+
+// validateProfiles checks every profile chain and every extending connection.
+func validateProfiles(connFile *model.ConnectionFile) error {
+	for _, profile := range connFile.Profiles {
+		if _, err := connFile.ProfileChain(profile.Name); err != nil {
+			return err
+		}
+	}
+	for _, conn := range connFile.Connections {
+		if err := validateResolvedConnection(connFile, conn); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// resolveConnections applies profile inheritance to each connection.
+func resolveConnections(connFile *model.ConnectionFile, conns []model.SSHConnection) ([]model.SSHConnection, error) {
+	resolved := make([]model.SSHConnection, 0, len(conns))
+	for _, conn := range conns {
+		r, err := connFile.ResolveConnection(conn)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", connectionLabel(conn), err)
+		}
+		resolved = append(resolved, r)
+	}
+	return resolved, nil
+}
+
+func connectionLabel(conn model.SSHConnection) string {
+	if alias := strings.TrimSpace(conn.Alias); alias != "" {
+		return fmt.Sprintf("connection %q", alias)
+	}
+	return fmt.Sprintf("connection %s", conn.ID)
+}
+
+func dashIfEmpty(value string) string {
+	if strings.TrimSpace(value) == "" {
+		return "-"
+	}
+	return value
+}
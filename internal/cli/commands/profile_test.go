@@ -0,0 +1,253 @@
+package commands
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/emirhangumus/sshmanager/internal/config"
+	"github.com/emirhangumus/sshmanager/internal/model"
+	"github.com/emirhangumus/sshmanager/internal/store"
+)
+
+func prepareProfileFixture(t *testing.T) (string, string, string) {
+	t.Helper()
+	connPath, keyPath := prepareTransferFixture(t, nil)
+	identityFile := filepath.Join(t.TempDir(), "id_ops")
+	if err := os.WriteFile(identityFile, []byte("key"), 0o600); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+
+	for _, args := range [][]string{
+		{"--username", "ops", "--port", "2222", "--identity-file", identityFile, "base"},
+		{"--extends", "base", "--proxy-jump", "bastion.internal", "--local-forward", "8080:127.0.0.1:80", "prod"},
+	} {
+		if err := handleProfile(connPath, keyPath, append([]string{"add"}, args...), ioDiscard()); err != nil {
+			t.Fatalf("profile add %v failed: %v", args, err)
+		}
+	}
+	if err := handleAddArgs(connPath, keyPath, []string{"--host", "web1.internal", "--extends", "prod", "--alias", "web1"}, ioDiscard()); err != nil {
+		t.Fatalf("add with --extends failed: %v", err)
+	}
+	return connPath, keyPath, identityFile
+}
+
+func TestHandleProfileAddAndAddConnectionWithExtends(t *testing.T) {
+	connPath, keyPath, identityFile := prepareProfileFixture(t)
+
+	loaded := loadTransferConnections(t, connPath, keyPath)
+	if len(loaded.Profiles) != 2 {
+		t.Fatalf("expected 2 profiles, got %+v", loaded.Profiles)
+	}
+	if base := loaded.GetProfile("base"); base == nil || base.AuthMode != model.AuthModeKey {
+		t.Fatalf("expected base profile with key auth, got %+v", base)
+	}
+
+	raw := loaded.GetConnectionByAlias("web1")
+	if raw == nil || raw.Username != "" || raw.AuthMode != "" || raw.Extends != "prod" {
+		t.Fatalf("expected connection to keep inherited fields unset, got %+v", raw)
+	}
+	resolved, err := loaded.ResolveConnection(*raw)
+	if err != nil {
+		t.Fatalf("ResolveConnection failed: %v", err)
+	}
+	bin, args, _, err := buildConnectInvocation("", &resolved)
+	if err != nil {
+		t.Fatalf("buildConnectInvocation failed: %v", err)
+	}
+	joined := bin + " " + strings.Join(args, " ")
+	for _, want := range []string{"-p 2222", "-i " + identityFile, "-J bastion.internal", "-L 8080:127.0.0.1:80", "ops@web1.internal"} {
+		if !strings.Contains(joined, want) {
+			t.Fatalf("expected %q in invocation %q", want, joined)
+		}
+	}
+}
+
+func TestHandleAddArgsRejectsIncompleteOrMissingProfile(t *testing.T) {
+	connPath, keyPath, _ := prepareProfileFixture(t)
+
+	err := handleAddArgs(connPath, keyPath, []string{"--host", "h", "--extends", "missing"}, ioDiscard())
+	if !errors.Is(err, model.ErrProfileNotFound) {
+		t.Fatalf("expected ErrProfileNotFound, got %v", err)
+	}
+
+	if err := handleProfile(connPath, keyPath, []string{"add", "--port", "2200", "portonly"}, ioDiscard()); err != nil {
+		t.Fatalf("profile add failed: %v", err)
+	}
+	err = handleAddArgs(connPath, keyPath, []string{"--host", "h", "--extends", "portonly"}, ioDiscard())
+	if err == nil || !strings.Contains(err.Error(), "empty username") {
+		t.Fatalf("expected resolved username error, got %v", err)
+	}
+}
+
+func TestHandleProfileEditRejectsCycleAndRevalidatesDependents(t *testing.T) {
+	connPath, keyPath, identityFile := prepareProfileFixture(t)
+
+	err := handleProfile(connPath, keyPath, []string{"edit", "--new-extends", "prod", "base"}, ioDiscard())
+	if !errors.Is(err, model.ErrProfileCycle) {
+		t.Fatalf("expected ErrProfileCycle, got %v", err)
+	}
+
+	err = handleProfile(connPath, keyPath, []string{"edit", "--clear-username", "base"}, ioDiscard())
+	if err == nil || !strings.Contains(err.Error(), "web1") {
+		t.Fatalf("expected dependent connection validation error, got %v", err)
+	}
+
+	var out strings.Builder
+	if err := handleProfile(connPath, keyPath, []string{"edit", "--new-username", "deploy", "--new-port", "0", "base"}, &out); err != nil {
+		t.Fatalf("profile edit failed: %v", err)
+	}
+	if !strings.Contains(out.String(), "Profile base updated.") {
+		t.Fatalf("unexpected output: %q", out.String())
+	}
+	edited := loadTransferConnections(t, connPath, keyPath)
+	base := edited.GetProfile("base")
+	if base.Username != "deploy" || base.Port != 0 || base.IdentityFile != identityFile {
+		t.Fatalf("unexpected edited profile: %+v", base)
+	}
+
+	err = handleProfile(connPath, keyPath, []string{"edit", "--new-username", "x", "nope"}, ioDiscard())
+	if !errors.Is(err, model.ErrProfileNotFound) {
+		t.Fatalf("expected ErrProfileNotFound, got %v", err)
+	}
+}
+
+func TestHandleProfileRemoveRefusesWhileExtended(t *testing.T) {
+	connPath, keyPath, _ := prepareProfileFixture(t)
+
+	err := handleProfile(connPath, keyPath, []string{"remove", "prod"}, ioDiscard())
+	if err == nil || !strings.Contains(err.Error(), "web1") {
+		t.Fatalf("expected dependents error, got %v", err)
+	}
+
+	if err := handleEditArgs(connPath, keyPath, []string{"--clear-extends", "--new-username", "ubuntu", "--new-auth-mode", "agent", "web1"}, ioDiscard()); err != nil {
+		t.Fatalf("edit --clear-extends failed: %v", err)
+	}
+	var out strings.Builder
+	if err := handleProfile(connPath, keyPath, []string{"remove", "prod"}, &out); err != nil {
+		t.Fatalf("profile remove failed: %v", err)
+	}
+	if !strings.Contains(out.String(), "Profile prod removed.") {
+		t.Fatalf("unexpected output: %q", out.String())
+	}
+	if loaded := loadTransferConnections(t, connPath, keyPath); loaded.GetProfile("prod") != nil {
+		t.Fatal("expected prod profile to be removed")
+	}
+}
+
+func TestHandleProfileListJSON(t *testing.T) {
+	connPath, keyPath, _ := prepareProfileFixture(t)
+
+	var out strings.Builder
+	if err := handleProfile(connPath, keyPath, []string{"list", "--json"}, &out); err != nil {
+		t.Fatalf("profile list failed: %v", err)
+	}
+	var items []profileOutputItem
+	if err := json.Unmarshal([]byte(out.String()), &items); err != nil {
+		t.Fatalf("failed to decode output: %v\nraw: %s", err, out.String())
+	}
+	if len(items) != 2 || items[0].Name != "base" || items[0].AuthMode != model.AuthModeKey {
+		t.Fatalf("unexpected profiles: %+v", items)
+	}
+	if strings.Join(items[0].UsedBy, ",") != "prod" || strings.Join(items[1].UsedBy, ",") != "web1" {
+		t.Fatalf("unexpected usedBy: %+v", items)
+	}
+}
+
+func TestHandleListJSONShowsRawAndResolvedFields(t *testing.T) {
+	connPath, keyPath, _ := prepareProfileFixture(t)
+
+	var out strings.Builder
+	if err := handleList(connPath, keyPath, []string{"--json"}, &out); err != nil {
+		t.Fatalf("handleList failed: %v", err)
+	}
+	var items []listOutputItem
+	if err := json.Unmarshal([]byte(out.String()), &items); err != nil {
+		t.Fatalf("failed to decode output: %v\nraw: %s", err, out.String())
+	}
+	if len(items) != 1 {
+		t.Fatalf("expected 1 item, got %d", len(items))
+	}
+	item := items[0]
+	if item.Username != "ops" || item.Port != 2222 || item.AuthMode != model.AuthModeKey || item.ProxyJump != "bastion.internal" {
+		t.Fatalf("expected resolved fields, got %+v", item)
+	}
+	if item.Extends != "prod" || item.Raw == nil || item.Raw.Username != "" || item.Raw.Port != 0 || item.Raw.ProxyJump != "" {
+		t.Fatalf("expected raw fields, got %+v", item.Raw)
+	}
+}
+
+func TestHandleExportKeepsProfilesOrFlattens(t *testing.T) {
+	connPath, keyPath, _ := prepareProfileFixture(t)
+	dir := t.TempDir()
+
+	rawPath := filepath.Join(dir, "raw.json")
+	if err := handleExport(connPath, keyPath, []string{"--format", "json", "--out", rawPath}, ioDiscard()); err != nil {
+		t.Fatalf("export failed: %v", err)
+	}
+	flatPath := filepath.Join(dir, "flat.json")
+	if err := handleExport(connPath, keyPath, []string{"--format", "json", "--resolved", "--out", flatPath}, ioDiscard()); err != nil {
+		t.Fatalf("export --resolved failed: %v", err)
+	}
+
+	var flat model.ConnectionFile
+	payload, err := os.ReadFile(flatPath)
+	if err != nil {
+		t.Fatalf("ReadFile failed: %v", err)
+	}
+	if err := json.Unmarshal(payload, &flat); err != nil {
+		t.Fatalf("failed to decode flattened export: %v", err)
+	}
+	if len(flat.Profiles) != 0 || flat.Connections[0].Extends != "" || flat.Connections[0].Username != "ops" {
+		t.Fatalf("expected flattened connections, got %+v", flat)
+	}
+
+	targetConn, targetKey := prepareTransferFixture(t, nil)
+	if err := handleImport(targetConn, targetKey, []string{"--in", rawPath, "--mode", "replace"}, ioDiscard()); err != nil {
+		t.Fatalf("import failed: %v", err)
+	}
+	imported := loadTransferConnections(t, targetConn, targetKey)
+	if len(imported.Profiles) != 2 || imported.GetConnectionByAlias("web1").Extends != "prod" {
+		t.Fatalf("expected profiles to round-trip, got %+v", imported)
+	}
+
+	sshConfigPath := filepath.Join(dir, "ssh_config")
+	if err := handleExport(connPath, keyPath, []string{"--format", "ssh-config", "--out", sshConfigPath}, ioDiscard()); err != nil {
+		t.Fatalf("ssh-config export failed: %v", err)
+	}
+	rendered, _ := os.ReadFile(sshConfigPath)
+	for _, want := range []string{"User ops", "Port 2222", "ProxyJump bastion.internal"} {
+		if !strings.Contains(string(rendered), want) {
+			t.Fatalf("expected %q in ssh config export:\n%s", want, rendered)
+		}
+	}
+}
+
+func TestHandleDoctorReportsProfileCycles(t *testing.T) {
+	connPath, keyPath, _ := prepareProfileFixture(t)
+	if err := store.NewConnectionStore(connPath, keyPath).Update(func(connFile *model.ConnectionFile) error {
+		connFile.GetProfile("base").Extends = "prod"
+		connFile.Connections = append(connFile.Connections, model.SSHConnection{ID: "orphan", Host: "h", Extends: "gone"})
+		return nil
+	}); err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+	cfgPath := filepath.Join(t.TempDir(), "config.yaml")
+	if err := config.SaveConfig(cfgPath, config.Default()); err != nil {
+		t.Fatalf("SaveConfig failed: %v", err)
+	}
+
+	var out strings.Builder
+	if err := handleDoctor("", connPath, keyPath, cfgPath, nil, &out); err == nil {
+		t.Fatal("expected doctor to fail on broken profiles")
+	}
+	text := out.String()
+	if !strings.Contains(text, "[ERROR] connection profiles:") ||
+		!strings.Contains(text, "profile inheritance cycle") ||
+		!strings.Contains(text, "profile not found: gone") {
+		t.Fatalf("unexpected doctor output: %q", text)
+	}
+}
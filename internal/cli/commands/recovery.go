@@ -28,6 +28,9 @@ type backupSnapshot struct {
 	CreatedAt      string                   `yaml:"createdAt" json:"createdAt"`
 	Config         *config.SSHManagerConfig `yaml:"config,omitempty" json:"config,omitempty"`
 	ConnectionFile model.ConnectionFile     `yaml:"connectionFile" json:"connectionFile"`
+	// Redacted marks backups written with --redact-secrets. Restore takes
+	// the missing passwords from the connections it replaces or merges into.
+	Redacted bool `yaml:"redacted,omitempty" json:"redacted,omitempty"`
 }
 
 func HandleBackup(connectionFilePath, secretKeyFilePath, configFilePath string, args []string) error {
@@ -35,12 +38,21 @@ func HandleBackup(connectionFilePath, secretKeyFilePath, configFilePath string,
 }
 
 func handleBackup(connectionFilePath, secretKeyFilePath, configFilePath string, args []string, out io.Writer) error {
+	if len(args) > 0 && strings.EqualFold(strings.TrimSpace(args[0]), "list") {
+		return handleBackupList(connectionFilePath, secretKeyFilePath, args[1:], out)
+	}
+
 	fs := flag.NewFlagSet("backup", flag.ContinueOnError)
 	fs.SetOutput(io.Discard)
 
 	outPath := fs.String("out", "", "Backup output path")
 	format := fs.String("format", "yaml", "Backup format: yaml|json")
 	includeConfig := fs.Bool("include-config", true, "Include config in backup")
+	encrypt := fs.Bool("encrypt", false, "Encrypt the backup with a passphrase, or for --recipient")
+	var recipients stringListFlag
+	fs.Var(&recipients, "recipient", "Encrypt the backup for an age1... public key (repeatable, implies --encrypt)")
+	passphraseEnv := fs.String("passphrase-env", backupPassphraseEnvVar, "Environment variable holding the backup passphrase")
+	redact := fs.Bool("redact-secrets", false, "Leave stored passwords out of the backup")
 
 	if err := fs.Parse(args); err != nil {
 		return err
@@ -48,6 +60,11 @@ func handleBackup(connectionFilePath, secretKeyFilePath, configFilePath string,
 	if fs.NArg() > 0 {
 		return fmt.Errorf("unexpected arguments for backup: %s", strings.Join(fs.Args(), " "))
 	}
+	for _, recipient := range recipients {
+		if _, err := cryptoutil.ParseRecipient(recipient); err != nil {
+			return err
+		}
+	}
 
 	target := strings.TrimSpace(*outPath)
 	if target == "" {
@@ -74,15 +91,34 @@ func handleBackup(connectionFilePath, secretKeyFilePath, configFilePath string,
 		snapshot.Config = &cfg
 	}
 
+	redactedCount := 0
+	if *redact {
+		redactedCount = redactSecrets(&snapshot.ConnectionFile)
+		snapshot.Redacted = true
+	}
+
 	encoded, normalizedFormat, err := marshalBackupSnapshot(snapshot, *format)
 	if err != nil {
 		return err
 	}
+	details := []string{normalizedFormat}
+	if *encrypt || len(recipients) > 0 {
+		encryption := backupEncryption{recipients: recipients, passphraseEnv: strings.TrimSpace(*passphraseEnv)}
+		sealed, description, err := encryption.seal(encoded, normalizedFormat)
+		if err != nil {
+			return err
+		}
+		encoded = sealed
+		details = append(details, description)
+	}
+	if *redact {
+		details = append(details, fmt.Sprintf("%d passwords redacted", redactedCount))
+	}
 	if err := storage.WriteFileAtomic(target, encoded, 0o600); err != nil {
 		return fmt.Errorf("failed to write backup file: %w", err)
 	}
 
-	_, _ = fmt.Fprintf(out, "Backup saved to %s (%s), %d connections\n", target, normalizedFormat, len(snapshot.ConnectionFile.Connections))
+	_, _ = fmt.Fprintf(out, "Backup saved to %s (%s), %d connections\n", target, strings.Join(details, ", "), len(snapshot.ConnectionFile.Connections))
 	return nil
 }
 
@@ -98,6 +134,9 @@ func handleRestore(connectionFilePath, secretKeyFilePath, configFilePath string,
 	format := fs.String("format", "auto", "Backup format: auto|yaml|json")
 	mode := fs.String("mode", importModeMerge, "Restore mode: merge|replace")
 	withConfig := fs.Bool("with-config", true, "Restore config if available in backup")
+	generation := fs.Int("generation", 0, "Restore an automatic backup generation (see backup list)")
+	identity := fs.String("identity", "", "age identity file for a backup encrypted for recipients (default: the vault key of a team vault)")
+	passphraseEnv := fs.String("passphrase-env", backupPassphraseEnvVar, "Environment variable holding the backup passphrase")
 
 	if err := fs.Parse(args); err != nil {
 		return err
@@ -106,9 +145,23 @@ func handleRestore(connectionFilePath, secretKeyFilePath, configFilePath string,
 		return fmt.Errorf("unexpected arguments for restore: %s", strings.Join(fs.Args(), " "))
 	}
 
+	connStore := store.NewConnectionStore(connectionFilePath, secretKeyFilePath, store.WithJournalCommand("restore"))
+	modeNorm := strings.ToLower(strings.TrimSpace(*mode))
 	source := strings.TrimSpace(*inPath)
+	if *generation != 0 {
+		if source != "" {
+			return errors.New("restore: --generation and --in cannot be combined")
+		}
+		// A generation is a full earlier state, so it replaces by default.
+		modeSet := false
+		fs.Visit(func(f *flag.Flag) { modeSet = modeSet || f.Name == "mode" })
+		if !modeSet {
+			modeNorm = importModeReplace
+		}
+		return restoreBackupGeneration(connStore, *generation, modeNorm, out)
+	}
 	if source == "" {
-		return errors.New("missing required --in path")
+		return errors.New("missing required --in path (or --generation <n>)")
 	}
 
 	payload, err := os.ReadFile(source)
@@ -116,31 +169,13 @@ func handleRestore(connectionFilePath, secretKeyFilePath, configFilePath string,
 		return fmt.Errorf("failed to read restore file: %w", err)
 	}
 
-	snapshot, err := decodeBackupSnapshot(payload, *format, source)
+	opener := newBackupArchiveOpener(secretKeyFilePath, *identity, strings.TrimSpace(*passphraseEnv))
+	snapshot, err := decodeBackupSnapshot(payload, *format, source, opener)
 	if err != nil {
 		return err
 	}
 
-	connStore := store.NewConnectionStore(connectionFilePath, secretKeyFilePath)
-	modeNorm := strings.ToLower(strings.TrimSpace(*mode))
-	switch modeNorm {
-	case importModeMerge:
-		err = connStore.Update(func(connFile *model.ConnectionFile) error {
-			return mergeImportedConnections(connFile, snapshot.ConnectionFile.Connections)
-		})
-	case importModeReplace:
-		err = connStore.Update(func(connFile *model.ConnectionFile) error {
-			replacement, buildErr := buildConnectionFile(snapshot.ConnectionFile.Connections)
-			if buildErr != nil {
-				return buildErr
-			}
-			*connFile = replacement
-			return nil
-		})
-	default:
-		return fmt.Errorf("unknown restore mode %q (use merge or replace)", modeNorm)
-	}
-	if err != nil {
+	if err := restoreConnections(connStore, modeNorm, snapshot.ConnectionFile, snapshot.Redacted); err != nil {
 		return err
 	}
 
@@ -161,6 +196,41 @@ func handleRestore(connectionFilePath, secretKeyFilePath, configFilePath string,
 	return nil
 }
 
+// restoreConnections merges or replaces the stored connections with
+// restored. Passwords missing from a redacted backup are kept from the
+// stored connections.
+func restoreConnections(connStore *store.ConnectionStore, mode string, restored model.ConnectionFile, redacted bool) error {
+	fill := func(connFile *model.ConnectionFile) error {
+		if !redacted {
+			return nil
+		}
+		return fillRedactedPasswords(connFile, &restored)
+	}
+	switch mode {
+	case importModeMerge:
+		return connStore.Update(func(connFile *model.ConnectionFile) error {
+			if err := fill(connFile); err != nil {
+				return err
+			}
+			return mergeImportedConnections(connFile, restored)
+		})
+	case importModeReplace:
+		return connStore.Update(func(connFile *model.ConnectionFile) error {
+			if err := fill(connFile); err != nil {
+				return err
+			}
+			replacement, err := buildConnectionFile(restored)
+			if err != nil {
+				return err
+			}
+			*connFile = replacement
+			return nil
+		})
+	default:
+		return fmt.Errorf("unknown restore mode %q (use merge or replace)", mode)
+	}
+}
+
 func marshalBackupSnapshot(snapshot backupSnapshot, format string) ([]byte, string, error) {
 	switch strings.ToLower(strings.TrimSpace(format)) {
 	case "", "yaml", "yml":
@@ -180,10 +250,24 @@ func marshalBackupSnapshot(snapshot backupSnapshot, format string) ([]byte, stri
 	}
 }
 
-func decodeBackupSnapshot(data []byte, formatHint, inPath string) (backupSnapshot, error) {
+// decodeBackupSnapshot decodes a backup, first decrypting it with open when
+// it is an encrypted archive.
+func decodeBackupSnapshot(data []byte, formatHint, inPath string, open backupArchiveOpener) (backupSnapshot, error) {
 	if len(bytes.TrimSpace(data)) == 0 {
 		return backupSnapshot{}, errors.New("restore file is empty")
 	}
+	if cryptoutil.IsArchive(data) {
+		if open == nil {
+			return backupSnapshot{}, errors.New("restore file is an encrypted backup")
+		}
+		plaintext, header, err := open(data)
+		if err != nil {
+			return backupSnapshot{}, fmt.Errorf("failed to decrypt backup: %w", err)
+		}
+		// The archive records the format of what it encrypts, which takes
+		// the place of the file extension for --format auto.
+		data, inPath = []byte(plaintext), "backup."+header.Format
+	}
 
 	format := normalizeImportFormat(formatHint, inPath)
 	switch format {
@@ -272,11 +356,11 @@ type doctorReport struct {
 	Checks  []doctorCheck `json:"checks" yaml:"checks"`
 }
 
-func HandleDoctor(connectionFilePath, secretKeyFilePath, configFilePath string, args []string) error {
-	return handleDoctor(connectionFilePath, secretKeyFilePath, configFilePath, args, os.Stdout)
+func HandleDoctor(vaultName, connectionFilePath, secretKeyFilePath, configFilePath string, args []string) error {
+	return handleDoctor(vaultName, connectionFilePath, secretKeyFilePath, configFilePath, args, os.Stdout)
 }
 
-func handleDoctor(connectionFilePath, secretKeyFilePath, configFilePath string, args []string, out io.Writer) error {
+func handleDoctor(vaultName, connectionFilePath, secretKeyFilePath, configFilePath string, args []string, out io.Writer) error {
 	fs := flag.NewFlagSet("doctor", flag.ContinueOnError)
 	fs.SetOutput(io.Discard)
 
@@ -328,6 +412,10 @@ func handleDoctor(connectionFilePath, secretKeyFilePath, configFilePath string,
 		return true
 	}
 
+	if vaultName != "" {
+		addCheck("vault", "ok", fmt.Sprintf("%s (%s)", vaultName, filepath.Dir(connectionFilePath)))
+	}
+
 	configExists := checkFile("config file", configFilePath)
 	connectionExists := checkFile("connection file", connectionFilePath)
 	secretKeyExists := checkFile("secret key file", secretKeyFilePath)
@@ -347,8 +435,19 @@ func handleDoctor(connectionFilePath, secretKeyFilePath, configFilePath string,
 		addCheck("ssh binary", "ok", "ssh found in PATH")
 	}
 
+	backend := config.ConnectBackendOpenSSH
+	if configExists {
+		if cfg, err := config.LoadConfig(configFilePath); err == nil {
+			backend = cfg.Connect.EffectiveBackend()
+		}
+	}
+
 	if _, err := exec.LookPath("sshpass"); err != nil {
-		addCheck("sshpass binary", "warn", "sshpass not found in PATH; password-auth connections will fail")
+		if backend == config.ConnectBackendNative {
+			addCheck("sshpass binary", "ok", "sshpass not found in PATH; not required with connect.backend=native")
+		} else {
+			addCheck("sshpass binary", "warn", "sshpass not found in PATH; password-auth connections will fail")
+		}
 	} else {
 		addCheck("sshpass binary", "ok", "sshpass found in PATH")
 	}
@@ -370,24 +469,18 @@ func handleDoctor(connectionFilePath, secretKeyFilePath, configFilePath string,
 		}
 	}
 
-	if keyData, err := os.ReadFile(secretKeyFilePath); err != nil {
-		addCheck("key file format", "error", fmt.Sprintf("failed to read key file: %v", err))
+	if info, err := cryptoutil.InspectKeyFile(secretKeyFilePath); err != nil {
+		addCheck("key file format", "error", err.Error())
+	} else if info.Mode == cryptoutil.KeyFileModeRaw {
+		addCheck("key file format", "ok", "raw AES-256 key mode")
+	} else if info.Mode == cryptoutil.KeyFileModeIdentity {
+		addCheck("key file format", "ok", fmt.Sprintf("team member identity, recipient %s", info.Recipient))
 	} else {
-		switch len(keyData) {
-		case 32:
-			addCheck("key file format", "ok", "raw AES-256 key mode")
-		default:
-			var meta map[string]any
-			if err := json.Unmarshal(keyData, &meta); err != nil {
-				addCheck("key file format", "error", "unrecognized key format")
-			} else {
-				mode, _ := meta["mode"].(string)
-				if strings.TrimSpace(mode) == "" {
-					addCheck("key file format", "warn", "key metadata mode missing")
-				} else {
-					addCheck("key file format", "ok", fmt.Sprintf("metadata mode: %s", mode))
-				}
-			}
+		detail := fmt.Sprintf("passphrase mode, key file v%d, %s", info.Version, info.KDF)
+		if weak := info.KDF.Weaknesses(); len(weak) > 0 {
+			addCheck("key file format", "warn", fmt.Sprintf("%s; below recommended floor (%s), run 'sshmanager rekey --upgrade-kdf'", detail, strings.Join(weak, ", ")))
+		} else {
+			addCheck("key file format", "ok", detail)
 		}
 	}
 
@@ -399,6 +492,38 @@ func handleDoctor(connectionFilePath, secretKeyFilePath, configFilePath string,
 		addCheck("key derivation", "ok", "encryption key can be loaded")
 	}
 
+	var rekeyLeftovers []string
+	for _, path := range []string{store.PreviousKeyFilePath(secretKeyFilePath), store.PendingKeyFilePath(secretKeyFilePath)} {
+		if _, err := os.Stat(path); err == nil {
+			rekeyLeftovers = append(rekeyLeftovers, path)
+		}
+	}
+	if len(rekeyLeftovers) > 0 {
+		addCheck("key rotation", "warn", fmt.Sprintf("interrupted rekey left key files behind: %s", strings.Join(rekeyLeftovers, ", ")))
+	} else {
+		addCheck("key rotation", "ok", "no interrupted rekey")
+	}
+
+	for _, target := range []struct{ name, path string }{
+		{"connection file", connectionFilePath},
+		{"history file", store.HistoryFilePath(connectionFilePath)},
+	} {
+		checkName := "encryption envelope (" + target.name + ")"
+		header, ok, err := store.InspectEncryptedFile(target.path)
+		switch {
+		case err != nil:
+			addCheck(checkName, "error", err.Error())
+		case !ok:
+			addCheck(checkName, "ok", "no encrypted data yet")
+		case len(header.Members) > 0:
+			addCheck(checkName, "ok", fmt.Sprintf("team envelope version %d (%d members)", header.Version, len(header.Members)))
+		case header.Version == cryptoutil.EnvelopeVersionLegacy:
+			addCheck(checkName, "warn", "legacy format without header authentication; upgraded to the current version on next save")
+		default:
+			addCheck(checkName, "ok", fmt.Sprintf("version %d (kdf %s, key id %s)", header.Version, header.KDF, header.KeyID))
+		}
+	}
+
 	if !configExists {
 		addCheck("config parse", "error", "skipped: config file is missing")
 	} else {
@@ -406,7 +531,7 @@ func handleDoctor(connectionFilePath, secretKeyFilePath, configFilePath string,
 		if cfgErr != nil {
 			addCheck("config parse", "error", cfgErr.Error())
 		} else {
-			addCheck("config parse", "ok", fmt.Sprintf("config loaded (continueAfterSSHExit=%t)", cfg.Behaviour.ContinueAfterSSHExit))
+			addCheck("config parse", "ok", fmt.Sprintf("config loaded (continueAfterSSHExit=%t, connect.backend=%s)", cfg.Behaviour.ContinueAfterSSHExit, cfg.Connect.EffectiveBackend()))
 		}
 	}
 
@@ -425,6 +550,8 @@ func handleDoctor(connectionFilePath, secretKeyFilePath, configFilePath string,
 			for _, conn := range connFile.Connections {
 				if _, err := normalizeImportedConnection(conn); err != nil {
 					invalidCount++
+				} else if err := validateResolvedConnection(&connFile, conn); err != nil && !isProfileChainError(err) {
+					invalidCount++
 				}
 
 				alias := strings.ToLower(strings.TrimSpace(conn.Alias))
@@ -442,6 +569,29 @@ func handleDoctor(connectionFilePath, secretKeyFilePath, configFilePath string,
 			} else {
 				addCheck("connection schema validation", "ok", "all connections passed validation")
 			}
+
+			if issues := profileChainIssues(&connFile); len(issues) > 0 {
+				addCheck("connection profiles", "error", strings.Join(issues, "; "))
+			} else {
+				addCheck("connection profiles", "ok", fmt.Sprintf("%d profiles, all inheritance chains resolve", len(connFile.Profiles)))
+			}
+
+			var unpinned []string
+			for _, conn := range connFile.Connections {
+				if len(model.NormalizeStringList(conn.HostKeys)) > 0 {
+					continue
+				}
+				name := strings.TrimSpace(conn.Alias)
+				if name == "" {
+					name = conn.ID
+				}
+				unpinned = append(unpinned, name)
+			}
+			if len(unpinned) > 0 {
+				addCheck("host key pins", "warn", fmt.Sprintf("%d of %d connections have no pinned host keys (pin with 'sshmanager trust <alias>'): %s", len(unpinned), len(connFile.Connections), strings.Join(unpinned, ", ")))
+			} else if len(connFile.Connections) > 0 {
+				addCheck("host key pins", "ok", fmt.Sprintf("all %d connections have pinned host keys", len(connFile.Connections)))
+			}
 		}
 	}
 
@@ -467,3 +617,33 @@ func handleDoctor(connectionFilePath, secretKeyFilePath, configFilePath string,
 	}
 	return nil
 }
+
+// profileChainIssues reports missing profiles and inheritance cycles for
+// every profile and connection, once per distinct problem.
+func profileChainIssues(connFile *model.ConnectionFile) []string {
+	var issues []string
+	seen := map[string]struct{}{}
+	report := func(owner string, err error) {
+		msg := fmt.Sprintf("%s: %v", owner, err)
+		if _, exists := seen[msg]; exists {
+			return
+		}
+		seen[msg] = struct{}{}
+		issues = append(issues, msg)
+	}
+	for _, profile := range connFile.Profiles {
+		if _, err := connFile.ProfileChain(profile.Name); err != nil {
+			report(fmt.Sprintf("profile %q", profile.Name), err)
+		}
+	}
+	for _, conn := range connFile.Connections {
+		if _, err := connFile.ProfileChain(conn.Extends); err != nil {
+			report(connectionLabel(conn), err)
+		}
+	}
+	return issues
+}
+
+func isProfileChainError(err error) bool {
+	return errors.Is(err, model.ErrProfileNotFound) || errors.Is(err, model.ErrProfileCycle)
+}
@@ -8,6 +8,7 @@ import (
 	"testing"
 
 	"github.com/emirhangumus/sshmanager/internal/config"
+	cryptoutil "github.com/emirhangumus/sshmanager/internal/crypto"
 	"github.com/emirhangumus/sshmanager/internal/model"
 	"github.com/emirhangumus/sshmanager/internal/store"
 	"gopkg.in/yaml.v3"
@@ -179,6 +180,168 @@ func TestHandleRestoreSupportsLegacyImportPayload(t *testing.T) {
 	}
 }
 
+func TestHandleBackupEncryptedRoundTrip(t *testing.T) {
+	connPath, keyPath := prepareTransferFixture(t, []model.SSHConnection{
+		{Username: "ubuntu", Host: "app.internal", AuthMode: model.AuthModePassword, Password: "secret", Alias: "prod"},
+	})
+	cfgPath := filepath.Join(t.TempDir(), "config.yaml")
+	if err := config.SaveConfig(cfgPath, config.Default()); err != nil {
+		t.Fatalf("SaveConfig failed: %v", err)
+	}
+	id, err := cryptoutil.GenerateIdentity()
+	if err != nil {
+		t.Fatalf("GenerateIdentity failed: %v", err)
+	}
+	identityPath := filepath.Join(t.TempDir(), "identity.txt")
+	if err := cryptoutil.WriteIdentityFile(identityPath, id); err != nil {
+		t.Fatalf("WriteIdentityFile failed: %v", err)
+	}
+	t.Setenv(backupPassphraseEnvVar, "backup pass")
+
+	passphrasePath := filepath.Join(t.TempDir(), "backup.yaml")
+	recipientPath := filepath.Join(t.TempDir(), "backup.json")
+	var out strings.Builder
+	if err := handleBackup(connPath, keyPath, cfgPath, []string{"--out", passphrasePath, "--encrypt"}, &out); err != nil {
+		t.Fatalf("handleBackup(--encrypt) failed: %v", err)
+	}
+	if !strings.Contains(out.String(), "(yaml, encrypted with a passphrase)") {
+		t.Fatalf("unexpected backup output: %q", out.String())
+	}
+	if err := handleBackup(connPath, keyPath, cfgPath, []string{"--out", recipientPath, "--format", "json", "--recipient", id.Recipient()}, ioDiscard()); err != nil {
+		t.Fatalf("handleBackup(--recipient) failed: %v", err)
+	}
+	for _, path := range []string{passphrasePath, recipientPath} {
+		raw, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatalf("failed to read backup: %v", err)
+		}
+		if !cryptoutil.IsArchive(raw) || strings.Contains(string(raw), "secret") || strings.Contains(string(raw), "app.internal") {
+			t.Fatalf("expected %s to be an encrypted archive", path)
+		}
+	}
+
+	if err := handleRestore(connPath, keyPath, cfgPath, []string{"--in", recipientPath}, ioDiscard()); err == nil || !strings.Contains(err.Error(), "--identity") {
+		t.Fatalf("expected restore without an identity to ask for one, got %v", err)
+	}
+	t.Setenv(backupPassphraseEnvVar, "wrong")
+	if err := handleRestore(connPath, keyPath, cfgPath, []string{"--in", passphrasePath}, ioDiscard()); err == nil || !strings.Contains(err.Error(), "failed to decrypt backup") {
+		t.Fatalf("expected a wrong passphrase to fail, got %v", err)
+	}
+	t.Setenv(backupPassphraseEnvVar, "backup pass")
+
+	for _, args := range [][]string{
+		{"--in", passphrasePath, "--mode", "replace"},
+		{"--in", recipientPath, "--mode", "replace", "--identity", identityPath},
+	} {
+		if err := handleRestore(connPath, keyPath, cfgPath, args, ioDiscard()); err != nil {
+			t.Fatalf("handleRestore(%v) failed: %v", args, err)
+		}
+		loaded := loadTransferConnections(t, connPath, keyPath)
+		if conn := loaded.GetConnectionByAlias("prod"); conn == nil || conn.Password != "secret" {
+			t.Fatalf("handleRestore(%v) restored %+v", args, loaded.Connections)
+		}
+	}
+
+	if err := handleBackup(connPath, keyPath, cfgPath, []string{"--out", recipientPath, "--recipient", "age1nope"}, ioDiscard()); err == nil {
+		t.Fatal("expected an invalid recipient to be rejected")
+	}
+}
+
+func TestHandleBackupRedactSecrets(t *testing.T) {
+	connPath, keyPath := prepareTransferFixture(t, []model.SSHConnection{
+		{Username: "ubuntu", Host: "app.internal", Password: "secret", Alias: "prod"},
+		{Username: "ubuntu", Host: "db.internal", AuthMode: model.AuthModeAgent, Alias: "db"},
+	})
+	backupPath := filepath.Join(t.TempDir(), "shared.yaml")
+	var out strings.Builder
+	if err := handleBackup(connPath, keyPath, "", []string{"--out", backupPath, "--include-config=false", "--redact-secrets"}, &out); err != nil {
+		t.Fatalf("handleBackup(--redact-secrets) failed: %v", err)
+	}
+	if !strings.Contains(out.String(), "1 passwords redacted") {
+		t.Fatalf("unexpected backup output: %q", out.String())
+	}
+	raw, err := os.ReadFile(backupPath)
+	if err != nil {
+		t.Fatalf("failed to read backup: %v", err)
+	}
+	var snapshot backupSnapshot
+	if err := yaml.Unmarshal(raw, &snapshot); err != nil {
+		t.Fatalf("failed to decode backup: %v", err)
+	}
+	if strings.Contains(string(raw), "secret") || !snapshot.Redacted || snapshot.ConnectionFile.Connections[0].AuthMode != model.AuthModePassword {
+		t.Fatalf("unexpected redacted backup:\n%s", raw)
+	}
+
+	// Restoring into the same vault keeps the stored password.
+	if err := handleRestore(connPath, keyPath, "", []string{"--in", backupPath, "--mode", "replace"}, ioDiscard()); err != nil {
+		t.Fatalf("handleRestore(redacted) failed: %v", err)
+	}
+	loaded := loadTransferConnections(t, connPath, keyPath)
+	if conn := loaded.GetConnectionByAlias("prod"); conn == nil || conn.Password != "secret" {
+		t.Fatalf("expected the stored password to be kept, got %+v", conn)
+	}
+
+	emptyConn, emptyKey := prepareTransferFixture(t, nil)
+	err = handleRestore(emptyConn, emptyKey, "", []string{"--in", backupPath}, ioDiscard())
+	if err == nil || !strings.Contains(err.Error(), `connection "prod"`) {
+		t.Fatalf("expected restore into an empty vault to name the redacted connection, got %v", err)
+	}
+}
+
+func TestHandleRestoreGenerationRollsBackAndIsListed(t *testing.T) {
+	connPath, keyPath := prepareTransferFixture(t, []model.SSHConnection{{Username: "ops", Host: "web.internal", Alias: "web"}})
+	updateTestConnection(t, connPath, keyPath, "web", func(conn *model.SSHConnection) { conn.Port = 2222 })
+	updateTestConnection(t, connPath, keyPath, "web", func(conn *model.SSHConnection) { conn.Port = 2223 })
+
+	var out strings.Builder
+	if err := handleBackup(connPath, keyPath, "", []string{"list", "--json"}, &out); err != nil {
+		t.Fatalf("handleBackup(list) failed: %v", err)
+	}
+	var items []backupGenerationOutput
+	if err := json.Unmarshal([]byte(out.String()), &items); err != nil {
+		t.Fatalf("invalid backup list json: %v", err)
+	}
+	if len(items) < 2 || items[0].Generation != 1 || items[0].Connections != 1 || items[0].Error != "" {
+		t.Fatalf("unexpected backup generations: %+v", items)
+	}
+
+	out.Reset()
+	if err := handleRestore(connPath, keyPath, "", []string{"--generation", "2"}, &out); err != nil {
+		t.Fatalf("handleRestore(--generation 2) failed: %v", err)
+	}
+	if !strings.Contains(out.String(), "Restored backup generation 2 using replace mode") {
+		t.Fatalf("unexpected restore output: %q", out.String())
+	}
+	loaded := loadTransferConnections(t, connPath, keyPath)
+	if port := loaded.GetConnectionByAlias("web").Port; port != 0 {
+		t.Fatalf("expected port before both edits, got %d", port)
+	}
+
+	// The rolled-back state is generation 1 now, and the rollback is journaled.
+	if err := handleRestore(connPath, keyPath, "", []string{"--generation", "1"}, ioDiscard()); err != nil {
+		t.Fatalf("handleRestore(--generation 1) failed: %v", err)
+	}
+	loaded = loadTransferConnections(t, connPath, keyPath)
+	if port := loaded.GetConnectionByAlias("web").Port; port != 2223 {
+		t.Fatalf("expected restoring generation 1 to undo the rollback, got %d", port)
+	}
+
+	cases := []struct {
+		args []string
+		want string
+	}{
+		{[]string{"--generation", "99"}, "backup generation 99 does not exist"},
+		{[]string{"--generation", "1", "--in", "x.yaml"}, "cannot be combined"},
+		{[]string{"--generation", "1", "--mode", "other"}, "unknown restore mode"},
+	}
+	for _, tc := range cases {
+		err := handleRestore(connPath, keyPath, "", tc.args, ioDiscard())
+		if err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Fatalf("handleRestore(%v) error = %v, want %q", tc.args, err, tc.want)
+		}
+	}
+}
+
 func TestHandleDoctorHealthyTextReport(t *testing.T) {
 	connPath, keyPath := prepareTransferFixture(t, []model.SSHConnection{
 		{
@@ -194,7 +357,7 @@ func TestHandleDoctorHealthyTextReport(t *testing.T) {
 	}
 
 	var out strings.Builder
-	if err := handleDoctor(connPath, keyPath, cfgPath, nil, &out); err != nil {
+	if err := handleDoctor("", connPath, keyPath, cfgPath, nil, &out); err != nil {
 		t.Fatalf("handleDoctor returned error for healthy fixture: %v", err)
 	}
 	if !strings.Contains(out.String(), "Doctor status: healthy") {
@@ -209,7 +372,7 @@ func TestHandleDoctorMissingFilesJSONReportWithoutCreatingKey(t *testing.T) {
 	cfgPath := filepath.Join(tmpDir, "config.yaml")
 
 	var out strings.Builder
-	err := handleDoctor(connPath, keyPath, cfgPath, []string{"--json"}, &out)
+	err := handleDoctor("", connPath, keyPath, cfgPath, []string{"--json"}, &out)
 	if err == nil {
 		t.Fatal("expected doctor to return error for missing files")
 	}
@@ -225,3 +388,41 @@ func TestHandleDoctorMissingFilesJSONReportWithoutCreatingKey(t *testing.T) {
 		t.Fatalf("doctor should not create secret.key when missing, stat err: %v", statErr)
 	}
 }
+
+func TestHandleDoctorReportsEnvelopeVersion(t *testing.T) {
+	connPath, keyPath := prepareTransferFixture(t, []model.SSHConnection{
+		{Username: "ubuntu", Host: "env.internal", AuthMode: model.AuthModeAgent, Alias: "env"},
+	})
+	cfgPath := filepath.Join(t.TempDir(), "config.yaml")
+	if err := config.SaveConfig(cfgPath, config.Default()); err != nil {
+		t.Fatalf("SaveConfig failed: %v", err)
+	}
+
+	var out strings.Builder
+	if err := handleDoctor("", connPath, keyPath, cfgPath, nil, &out); err != nil {
+		t.Fatalf("handleDoctor returned error: %v", err)
+	}
+	if !strings.Contains(out.String(), "encryption envelope (connection file)") || !strings.Contains(out.String(), "version 1 (kdf raw") {
+		t.Fatalf("expected envelope version in doctor output, got %q", out.String())
+	}
+
+	key, err := cryptoutil.LoadKey(keyPath)
+	if err != nil {
+		t.Fatalf("LoadKey failed: %v", err)
+	}
+	legacy, err := cryptoutil.EncryptData("version: \"1.0\"\nconnections: []\n", key)
+	if err != nil {
+		t.Fatalf("EncryptData failed: %v", err)
+	}
+	if err := os.WriteFile(connPath, legacy, 0o600); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+
+	out.Reset()
+	if err := handleDoctor("", connPath, keyPath, cfgPath, nil, &out); err != nil {
+		t.Fatalf("handleDoctor returned error for legacy envelope: %v", err)
+	}
+	if !strings.Contains(out.String(), "legacy format") {
+		t.Fatalf("expected legacy envelope warning, got %q", out.String())
+	}
+}
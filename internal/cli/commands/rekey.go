@@ -0,0 +1,183 @@
+package commands
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strings"
+
+	cryptoutil "github.com/emirhangumus/sshmanager/internal/crypto"
+	"github.com/emirhangumus/sshmanager/internal/store"
+	prompttext "github.com/emirhangumus/sshmanager/internal/ui/prompt"
+)
+
+const (
+	rekeyModeRaw        = "raw"
+	rekeyModePassphrase = "passphrase"
+
+	newPassphraseEnvVar = "SSHMANAGER_NEW_MASTER_PASSPHRASE" //nolint:gosec // env var name, not a credential value
+)
+
+// passphraseReader returns the new master passphrase. Tests replace it to
+// avoid terminal prompts.
+type passphraseReader func(envVar string) (string, error)
+
+func HandleRekey(connectionFilePath, secretKeyFilePath string, args []string) error {
+	return handleRekey(connectionFilePath, secretKeyFilePath, args, os.Stdout, readNewPassphrase)
+}
+
+func handleRekey(connectionFilePath, secretKeyFilePath string, args []string, out io.Writer, readPassphrase passphraseReader) error {
+	fs := flag.NewFlagSet("rekey", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+
+	argonDefaults := cryptoutil.DefaultArgon2idParams()
+	mode := fs.String("mode", "", "New key mode: raw|passphrase")
+	upgradeKDF := fs.Bool("upgrade-kdf", false, "Keep the current passphrase and re-derive the key with new KDF parameters")
+	kdf := fs.String("kdf", cryptoutil.KDFArgon2id, "Passphrase KDF: argon2id|pbkdf2")
+	iterations := fs.Int("iterations", cryptoutil.DefaultPBKDF2Params().Iterations, "PBKDF2 iterations")
+	memoryMiB := fs.Uint("memory", uint(argonDefaults.MemoryKiB/1024), "argon2id memory in MiB")
+	timeCost := fs.Uint("time", uint(argonDefaults.Time), "argon2id passes")
+	threads := fs.Uint("threads", uint(argonDefaults.Threads), "argon2id parallelism")
+	passphraseEnv := fs.String("passphrase-env", newPassphraseEnvVar, "Environment variable holding the new passphrase")
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() > 0 {
+		return fmt.Errorf("unexpected arguments for rekey: %s", strings.Join(fs.Args(), " "))
+	}
+
+	set := map[string]bool{}
+	fs.Visit(func(f *flag.Flag) { set[f.Name] = true })
+
+	modeNorm := strings.ToLower(strings.TrimSpace(*mode))
+	if *upgradeKDF {
+		if modeNorm == rekeyModeRaw {
+			return errors.New("--upgrade-kdf cannot be combined with --mode raw")
+		}
+		modeNorm = rekeyModePassphrase
+	}
+
+	var (
+		next cryptoutil.KeyFile
+		err  error
+	)
+	switch modeNorm {
+	case rekeyModeRaw:
+		for _, name := range []string{"kdf", "iterations", "memory", "time", "threads"} {
+			if set[name] {
+				return fmt.Errorf("--%s only applies to --mode passphrase", name)
+			}
+		}
+		next, err = cryptoutil.NewRawKeyFile()
+	case rekeyModePassphrase:
+		params, paramsErr := rekeyKDFParams(*kdf, *iterations, *memoryMiB, *timeCost, *threads, set)
+		if paramsErr != nil {
+			return paramsErr
+		}
+
+		var passphrase string
+		if *upgradeKDF {
+			passphrase, err = currentPassphrase(secretKeyFilePath)
+		} else {
+			passphrase, err = readPassphrase(strings.TrimSpace(*passphraseEnv))
+		}
+		if err != nil {
+			return err
+		}
+		next, err = cryptoutil.NewPassphraseKeyFile(passphrase, params)
+	case "":
+		return errors.New("missing required --mode (raw or passphrase) or --upgrade-kdf")
+	default:
+		return fmt.Errorf("unknown rekey mode %q (use raw or passphrase)", *mode)
+	}
+	if err != nil {
+		return err
+	}
+
+	connStore := store.NewConnectionStore(connectionFilePath, secretKeyFilePath)
+	if err := connStore.Rekey(next); err != nil {
+		return fmt.Errorf("rekey failed: %w", err)
+	}
+
+	switch {
+	case *upgradeKDF:
+		_, _ = fmt.Fprintf(out, "Upgraded the passphrase key to %s; the passphrase is unchanged.\n", next.Params)
+	case next.IsPassphrase():
+		_, _ = fmt.Fprintf(out, "Rekeyed with a passphrase key (%s). Set %s to the new passphrase from now on.\n", next.Params, cryptoutil.PassphraseEnvVar)
+	default:
+		_, _ = fmt.Fprintf(out, "Rekeyed with a new raw key. %s is no longer needed.\n", cryptoutil.PassphraseEnvVar)
+	}
+	return nil
+}
+
+// rekeyKDFParams builds the KDF parameters from the rekey flags, rejecting
+// flags that belong to the other KDF.
+func rekeyKDFParams(kdf string, iterations int, memoryMiB, timeCost, threads uint, set map[string]bool) (cryptoutil.KDFParams, error) {
+	name, err := cryptoutil.ParseKDF(kdf)
+	if err != nil {
+		return cryptoutil.KDFParams{}, err
+	}
+
+	var params cryptoutil.KDFParams
+	if name == cryptoutil.KDFPBKDF2 {
+		for _, flagName := range []string{"memory", "time", "threads"} {
+			if set[flagName] {
+				return params, fmt.Errorf("--%s only applies to --kdf %s", flagName, cryptoutil.KDFArgon2id)
+			}
+		}
+		params = cryptoutil.KDFParams{KDF: name, Iterations: iterations}
+	} else {
+		if set["iterations"] {
+			return params, fmt.Errorf("--iterations only applies to --kdf pbkdf2")
+		}
+		if memoryMiB > math.MaxUint32/1024 || timeCost > math.MaxUint32 || threads > math.MaxUint8 {
+			return params, errors.New("argon2id parameters out of range")
+		}
+		params = cryptoutil.KDFParams{
+			KDF:       name,
+			MemoryKiB: uint32(memoryMiB) * 1024,
+			Time:      uint32(timeCost),
+			Threads:   uint8(threads),
+		}
+	}
+	if err := params.Validate(); err != nil {
+		return params, err
+	}
+	return params, nil
+}
+
+// currentPassphrase returns the passphrase of the existing passphrase key
+// for --upgrade-kdf.
+func currentPassphrase(secretKeyFilePath string) (string, error) {
+	info, err := cryptoutil.InspectKeyFile(secretKeyFilePath)
+	if err != nil {
+		return "", err
+	}
+	if info.Mode != cryptoutil.KeyFileModePassphrase {
+		return "", errors.New("--upgrade-kdf needs a passphrase key; use --mode passphrase to switch from a raw key")
+	}
+	return cryptoutil.MasterPassphrase(secretKeyFilePath)
+}
+
+// readNewPassphrase reads the new passphrase from envVar, or asks for it
+// twice on a terminal.
+func readNewPassphrase(envVar string) (string, error) {
+	if envVar != "" {
+		if passphrase := strings.TrimSpace(os.Getenv(envVar)); passphrase != "" {
+			return passphrase, nil
+		}
+	}
+	if !cryptoutil.CanPrompt() {
+		return "", fmt.Errorf("no new passphrase: set %s or run rekey in a terminal", envVar)
+	}
+
+	passphrase, err := prompttext.NewMasterPassphrasePrompt()
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(passphrase), nil
+}
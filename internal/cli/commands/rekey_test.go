@@ -0,0 +1,125 @@
+package commands
+
+import (
+	"bytes"
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	cryptoutil "github.com/emirhangumus/sshmanager/internal/crypto"
+	"github.com/emirhangumus/sshmanager/internal/model"
+)
+
+func fixedPassphrase(passphrase string) passphraseReader {
+	return func(string) (string, error) { return passphrase, nil }
+}
+
+func TestHandleRekeySwitchesToPassphraseKey(t *testing.T) {
+	t.Setenv(cryptoutil.PassphraseEnvVar, "")
+	connPath, keyPath := prepareTransferFixture(t, []model.SSHConnection{
+		{Username: "u", Host: "h", Password: "p", Alias: "web"},
+	})
+
+	var out bytes.Buffer
+	err := handleRekey(connPath, keyPath, []string{"--mode", "passphrase", "--kdf", "pbkdf2", "--iterations", "150000"}, &out, fixedPassphrase("rotated"))
+	if err != nil {
+		t.Fatalf("handleRekey failed: %v", err)
+	}
+	if !strings.Contains(out.String(), "pbkdf2-sha256 iterations=150000") || !strings.Contains(out.String(), cryptoutil.PassphraseEnvVar) {
+		t.Fatalf("unexpected output: %q", out.String())
+	}
+
+	t.Setenv(cryptoutil.PassphraseEnvVar, "rotated")
+	loaded := loadTransferConnections(t, connPath, keyPath)
+	if loaded.GetConnectionByAlias("web") == nil {
+		t.Fatal("connection missing after rekey")
+	}
+
+	if err := handleRekey(connPath, keyPath, []string{"--mode", "raw"}, ioDiscard(), nil); err != nil {
+		t.Fatalf("handleRekey(raw) failed: %v", err)
+	}
+	t.Setenv(cryptoutil.PassphraseEnvVar, "")
+	if loaded := loadTransferConnections(t, connPath, keyPath); len(loaded.Connections) != 1 {
+		t.Fatalf("expected 1 connection after raw rekey, got %d", len(loaded.Connections))
+	}
+}
+
+func TestHandleRekeyRejectsInvalidArguments(t *testing.T) {
+	t.Setenv(cryptoutil.PassphraseEnvVar, "")
+	connPath, keyPath := prepareTransferFixture(t, nil)
+
+	cases := []struct {
+		args []string
+		want string
+	}{
+		{nil, "missing required --mode"},
+		{[]string{"--mode", "hsm"}, "unknown rekey mode"},
+		{[]string{"--mode", "raw", "--iterations", "200000"}, "only applies to --mode passphrase"},
+		{[]string{"--mode", "passphrase", "--kdf", "pbkdf2", "--iterations", "1000"}, "at least"},
+		{[]string{"--mode", "passphrase", "--iterations", "700000"}, "only applies to --kdf pbkdf2"},
+		{[]string{"--mode", "passphrase", "--kdf", "pbkdf2", "--memory", "128"}, "only applies to --kdf argon2id"},
+		{[]string{"--mode", "passphrase", "--memory", "1"}, "at least"},
+		{[]string{"--mode", "passphrase", "--kdf", "scrypt"}, "unknown kdf"},
+		{[]string{"--upgrade-kdf"}, "needs a passphrase key"},
+		{[]string{"--upgrade-kdf", "--mode", "raw"}, "cannot be combined"},
+	}
+	for _, tc := range cases {
+		err := handleRekey(connPath, keyPath, tc.args, ioDiscard(), fixedPassphrase("x"))
+		if err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Fatalf("args %v: expected error containing %q, got %v", tc.args, tc.want, err)
+		}
+	}
+
+	readFailure := func(string) (string, error) { return "", errors.New("no terminal") }
+	if err := handleRekey(connPath, keyPath, []string{"--mode", "passphrase"}, ioDiscard(), readFailure); err == nil {
+		t.Fatal("expected passphrase read error")
+	}
+}
+
+func TestReadNewPassphraseUsesEnvVar(t *testing.T) {
+	t.Setenv("TEST_REKEY_PASSPHRASE", "  from-env ")
+	got, err := readNewPassphrase("TEST_REKEY_PASSPHRASE")
+	if err != nil || got != "from-env" {
+		t.Fatalf("readNewPassphrase = %q, %v", got, err)
+	}
+}
+
+func TestHandleRekeyUpgradesPBKDF2KeyInPlace(t *testing.T) {
+	t.Setenv(cryptoutil.PassphraseEnvVar, "")
+	connPath, keyPath := prepareTransferFixture(t, []model.SSHConnection{
+		{Username: "u", Host: "h", Password: "p", Alias: "web"},
+	})
+	if err := handleRekey(connPath, keyPath, []string{"--mode", "passphrase", "--kdf", "pbkdf2", "--iterations", "100000"}, ioDiscard(), fixedPassphrase("same")); err != nil {
+		t.Fatalf("handleRekey(pbkdf2) failed: %v", err)
+	}
+	t.Setenv(cryptoutil.PassphraseEnvVar, "same")
+
+	var report bytes.Buffer
+	_ = handleDoctor("", connPath, keyPath, filepath.Join(t.TempDir(), "config.yaml"), nil, &report)
+	if !strings.Contains(report.String(), "[WARN] key file format: passphrase mode, key file v2, pbkdf2-sha256 iterations=100000; below recommended floor") {
+		t.Fatalf("expected doctor KDF warning, got:\n%s", report.String())
+	}
+
+	var out bytes.Buffer
+	if err := handleRekey(connPath, keyPath, []string{"--upgrade-kdf", "--memory", "32", "--time", "2", "--threads", "2"}, &out, nil); err != nil {
+		t.Fatalf("handleRekey(--upgrade-kdf) failed: %v", err)
+	}
+	if !strings.Contains(out.String(), "argon2id memory=32768KiB time=2 threads=2") {
+		t.Fatalf("unexpected output: %q", out.String())
+	}
+
+	info, err := cryptoutil.InspectKeyFile(keyPath)
+	if err != nil || info.KDF.KDF != cryptoutil.KDFArgon2id {
+		t.Fatalf("expected argon2id key file, got %+v, %v", info, err)
+	}
+	if loaded := loadTransferConnections(t, connPath, keyPath); loaded.GetConnectionByAlias("web") == nil {
+		t.Fatal("connection missing after KDF upgrade")
+	}
+
+	report.Reset()
+	_ = handleDoctor("", connPath, keyPath, filepath.Join(t.TempDir(), "config.yaml"), nil, &report)
+	if !strings.Contains(report.String(), "[OK] key file format: passphrase mode, key file v2, argon2id memory=32768KiB time=2 threads=2") {
+		t.Fatalf("expected doctor to report argon2id params, got:\n%s", report.String())
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"path/filepath"
 	"strings"
 
 	"github.com/emirhangumus/sshmanager/internal/model"
@@ -13,7 +14,7 @@ import (
 )
 
 func HandleRemove(connectionFilePath, secretKeyFilePath string) error {
-	connStore := store.NewConnectionStore(connectionFilePath, secretKeyFilePath)
+	connStore := store.NewConnectionStore(connectionFilePath, secretKeyFilePath, store.WithJournalCommand("remove"))
 	connFile, err := connStore.Load()
 	if err != nil {
 		return err
@@ -37,11 +38,8 @@ func HandleRemove(connectionFilePath, secretKeyFilePath string) error {
 	}
 
 	connID := items[idx].ConnectionID
-	removed := false
-	if err := connStore.Update(func(liveConnFile *model.ConnectionFile) error {
-		removed = liveConnFile.RemoveConnectionByID(connID)
-		return nil
-	}); err != nil {
+	removed, err := removeConnection(connStore, connectionFilePath, connID, os.Stderr)
+	if err != nil {
 		return err
 	}
 	if !removed {
@@ -78,7 +76,7 @@ func handleRemoveArgs(connectionFilePath, secretKeyFilePath string, args []strin
 		return HandleRemove(connectionFilePath, secretKeyFilePath)
 	}
 
-	connStore := store.NewConnectionStore(connectionFilePath, secretKeyFilePath)
+	connStore := store.NewConnectionStore(connectionFilePath, secretKeyFilePath, store.WithJournalCommand("remove"))
 	connFile, err := connStore.Load()
 	if err != nil {
 		return err
@@ -105,11 +103,8 @@ func handleRemoveArgs(connectionFilePath, secretKeyFilePath string, args []strin
 		}
 	}
 
-	removed := false
-	if err := connStore.Update(func(liveConnFile *model.ConnectionFile) error {
-		removed = liveConnFile.RemoveConnectionByID(conn.ID)
-		return nil
-	}); err != nil {
+	removed, err := removeConnection(connStore, connectionFilePath, conn.ID, os.Stderr)
+	if err != nil {
 		return err
 	}
 
@@ -122,6 +117,38 @@ func handleRemoveArgs(connectionFilePath, secretKeyFilePath string, args []strin
 	return nil
 }
 
+// removeConnection deletes the connection with connID and then its entries
+// in the vault's known_hosts files. A failed known_hosts cleanup is only
+// reported on warnOut.
+func removeConnection(connStore *store.ConnectionStore, connectionFilePath, connID string, warnOut io.Writer) (bool, error) {
+	var (
+		removedConn model.SSHConnection
+		remaining   model.ConnectionFile
+		removed     bool
+	)
+	if err := connStore.Update(func(liveConnFile *model.ConnectionFile) error {
+		if conn := liveConnFile.GetConnectionByID(connID); conn != nil {
+			removedConn = *conn
+			if resolved, err := liveConnFile.ResolveConnection(*conn); err == nil {
+				removedConn = resolved
+			}
+		}
+		removed = liveConnFile.RemoveConnectionByID(connID)
+		remaining = *liveConnFile
+		return nil
+	}); err != nil {
+		return false, err
+	}
+	if !removed {
+		return false, nil
+	}
+
+	if err := forgetRemovedConnectionHosts(filepath.Dir(connectionFilePath), removedConn, &remaining); err != nil {
+		_, _ = fmt.Fprintf(warnOut, "warning: failed to remove known_hosts entries of the removed connection: %v\n", err)
+	}
+	return true, nil
+}
+
 func confirmRemove(conn *model.SSHConnection) (bool, error) {
 	target := fmt.Sprintf("%s@%s", strings.TrimSpace(conn.Username), strings.TrimSpace(conn.Host))
 	if alias := strings.TrimSpace(conn.Alias); alias != "" {
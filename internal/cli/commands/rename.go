@@ -95,7 +95,7 @@ func renameConnectionAlias(connectionFilePath, secretKeyFilePath, selectedID, se
 		return err
 	}
 
-	connStore := store.NewConnectionStore(connectionFilePath, secretKeyFilePath)
+	connStore := store.NewConnectionStore(connectionFilePath, secretKeyFilePath, store.WithJournalCommand("rename"))
 	connFile, err := connStore.Load()
 	if err != nil {
 		return err
@@ -0,0 +1,561 @@
+package commands
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/emirhangumus/sshmanager/internal/model"
+)
+
+const (
+	importFormatSSHConfig = "ssh-config"
+	maxSSHConfigInclude   = 16
+)
+
+// supportedSSHConfigOptions lists the directives mapped onto SSHConnection
+// fields; anything else in the file is ignored on import.
+var supportedSSHConfigOptions = map[string]struct{}{
+	"hostname":       {},
+	"user":           {},
+	"port":           {},
+	"identityfile":   {},
+	"proxyjump":      {},
+	"localforward":   {},
+	"remoteforward":  {},
+	"dynamicforward": {},
+}
+
+type sshConfigOption struct {
+	Key  string
+	Args []string
+}
+
+type sshConfigBlock struct {
+	Keyword  string
+	Patterns []string
+	Options  []sshConfigOption
+	Implicit bool
+}
+
+// decodeSSHConfigConnectionFile converts OpenSSH client config content into
+// connections. Blocks that cannot be mapped to a concrete alias (wildcard-only
+// Host blocks, Match blocks) are returned as human-readable skip notices.
+func decodeSSHConfigConnectionFile(data []byte, inPath string) (model.ConnectionFile, []string, error) {
+	if len(bytes.TrimSpace(data)) == 0 {
+		return model.ConnectionFile{}, nil, errors.New("import file is empty")
+	}
+
+	blocks, err := parseSSHConfig(data, filepath.Dir(inPath), 0)
+	if err != nil {
+		return model.ConnectionFile{}, nil, err
+	}
+
+	connFile := model.NewConnectionFile()
+	var skipped []string
+	seen := map[string]struct{}{}
+	for _, block := range blocks {
+		if block.Keyword == "match" {
+			skipped = append(skipped, fmt.Sprintf("Match %s (Match blocks are not supported)", strings.Join(block.Patterns, " ")))
+			continue
+		}
+		if block.Keyword != "host" {
+			continue
+		}
+
+		concrete := 0
+		for _, pattern := range block.Patterns {
+			if isSSHConfigWildcard(pattern) {
+				continue
+			}
+			concrete++
+			key := strings.ToLower(pattern)
+			if _, exists := seen[key]; exists {
+				continue
+			}
+			seen[key] = struct{}{}
+
+			conn, err := resolveSSHConfigHost(blocks, pattern)
+			if err != nil {
+				return model.ConnectionFile{}, nil, fmt.Errorf("ssh config host %q: %w", pattern, err)
+			}
+			connFile.Connections = append(connFile.Connections, conn)
+		}
+		if concrete == 0 && !block.Implicit {
+			skipped = append(skipped, fmt.Sprintf("Host %s (wildcard-only pattern)", strings.Join(block.Patterns, " ")))
+		}
+	}
+
+	return connFile, skipped, nil
+}
+
+// parseSSHConfig splits config content into blocks. Options before the first
+// Host/Match line are placed in an implicit "Host *" block, matching ssh.
+// Include directives are expanded in place relative to baseDir.
+func parseSSHConfig(data []byte, baseDir string, depth int) ([]sshConfigBlock, error) {
+	if depth > maxSSHConfigInclude {
+		return nil, fmt.Errorf("ssh config Include nesting exceeds %d levels", maxSSHConfigInclude)
+	}
+
+	blocks := []sshConfigBlock{{Keyword: "host", Patterns: []string{"*"}, Implicit: true}}
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		key, args, err := splitSSHConfigLine(scanner.Text())
+		if err != nil {
+			return nil, fmt.Errorf("ssh config line %d: %w", lineNo, err)
+		}
+		if key == "" {
+			continue
+		}
+
+		switch key {
+		case "host", "match":
+			if len(args) == 0 {
+				return nil, fmt.Errorf("ssh config line %d: %s requires at least one pattern", lineNo, key)
+			}
+			blocks = append(blocks, sshConfigBlock{Keyword: key, Patterns: args})
+		case "include":
+			// Options at the top of an included file belong to the block
+			// that contained the Include line.
+			enclosing := len(blocks) - 1
+			for _, pattern := range args {
+				files, err := readSSHConfigInclude(pattern, baseDir, depth)
+				if err != nil {
+					return nil, fmt.Errorf("ssh config line %d: %w", lineNo, err)
+				}
+				for _, included := range files {
+					blocks[enclosing].Options = append(blocks[enclosing].Options, included[0].Options...)
+					blocks = append(blocks, included[1:]...)
+				}
+			}
+		default:
+			current := &blocks[len(blocks)-1]
+			current.Options = append(current.Options, sshConfigOption{Key: key, Args: args})
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read ssh config: %w", err)
+	}
+	return blocks, nil
+}
+
+func readSSHConfigInclude(pattern, baseDir string, depth int) ([][]sshConfigBlock, error) {
+	expanded := expandSSHConfigHome(pattern)
+	if !filepath.IsAbs(expanded) {
+		expanded = filepath.Join(baseDir, expanded)
+	}
+
+	matches, err := filepath.Glob(expanded)
+	if err != nil {
+		return nil, fmt.Errorf("invalid Include pattern %q: %w", pattern, err)
+	}
+
+	files := make([][]sshConfigBlock, 0, len(matches))
+	for _, match := range matches {
+		data, err := os.ReadFile(match)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read included file %s: %w", match, err)
+		}
+		parsed, err := parseSSHConfig(data, filepath.Dir(match), depth+1)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", match, err)
+		}
+		files = append(files, parsed)
+	}
+	return files, nil
+}
+
+// splitSSHConfigLine returns the lower-cased keyword and its arguments.
+// Both "Key value" and "Key=value" forms are accepted, as are double quotes.
+func splitSSHConfigLine(line string) (string, []string, error) {
+	trimmed := strings.TrimSpace(line)
+	if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+		return "", nil, nil
+	}
+
+	keyEnd := strings.IndexAny(trimmed, " \t=")
+	if keyEnd < 0 {
+		return strings.ToLower(trimmed), nil, nil
+	}
+	key := strings.ToLower(trimmed[:keyEnd])
+	rest := strings.TrimLeft(trimmed[keyEnd:], " \t")
+	rest = strings.TrimPrefix(rest, "=")
+
+	var args []string
+	var current strings.Builder
+	inQuotes := false
+	hasToken := false
+	for _, r := range rest {
+		switch {
+		case r == '"':
+			inQuotes = !inQuotes
+			hasToken = true
+		case !inQuotes && (r == ' ' || r == '\t'):
+			if hasToken {
+				args = append(args, current.String())
+				current.Reset()
+				hasToken = false
+			}
+		case !inQuotes && r == '#' && !hasToken:
+			return key, args, nil
+		default:
+			current.WriteRune(r)
+			hasToken = true
+		}
+	}
+	if inQuotes {
+		return "", nil, fmt.Errorf("unterminated quote in %q", trimmed)
+	}
+	if hasToken {
+		args = append(args, current.String())
+	}
+	return key, args, nil
+}
+
+// resolveSSHConfigHost applies every matching block to alias using ssh's
+// first-obtained-value-wins rule. Forwards accumulate across blocks.
+func resolveSSHConfigHost(blocks []sshConfigBlock, alias string) (model.SSHConnection, error) {
+	conn := model.SSHConnection{Alias: alias}
+	var hostName string
+	set := map[string]bool{}
+
+	for _, block := range blocks {
+		if block.Keyword != "host" || !matchesSSHConfigPatterns(block.Patterns, alias) {
+			continue
+		}
+		for _, opt := range block.Options {
+			if _, supported := supportedSSHConfigOptions[opt.Key]; !supported {
+				continue
+			}
+			if len(opt.Args) == 0 {
+				return model.SSHConnection{}, fmt.Errorf("%s requires a value", opt.Key)
+			}
+
+			switch opt.Key {
+			case "localforward":
+				spec, err := sshConfigForwardSpec(opt)
+				if err != nil {
+					return model.SSHConnection{}, err
+				}
+				conn.LocalForwards = append(conn.LocalForwards, spec)
+				continue
+			case "remoteforward":
+				spec, err := sshConfigForwardSpec(opt)
+				if err != nil {
+					return model.SSHConnection{}, err
+				}
+				conn.RemoteForwards = append(conn.RemoteForwards, spec)
+				continue
+			case "dynamicforward":
+				if len(opt.Args) != 1 {
+					return model.SSHConnection{}, fmt.Errorf("%s expects [bind_address:]port, got %q", opt.Key, strings.Join(opt.Args, " "))
+				}
+				conn.DynamicForwards = append(conn.DynamicForwards, opt.Args[0])
+				continue
+			}
+
+			if set[opt.Key] {
+				continue
+			}
+			switch opt.Key {
+			case "hostname":
+				hostName = opt.Args[0]
+			case "user":
+				conn.Username = opt.Args[0]
+			case "port":
+				port, err := strconv.Atoi(opt.Args[0])
+				if err != nil || port < 1 || port > 65535 {
+					return model.SSHConnection{}, fmt.Errorf("invalid Port %q", opt.Args[0])
+				}
+				conn.Port = port
+			case "identityfile":
+				conn.IdentityFile = expandSSHConfigHome(opt.Args[0])
+			case "proxyjump":
+				if !strings.EqualFold(opt.Args[0], "none") {
+					conn.ProxyJump = opt.Args[0]
+				}
+			}
+			set[opt.Key] = true
+		}
+	}
+
+	if hostName == "" {
+		hostName = alias
+	}
+	conn.Host = expandSSHConfigHostToken(hostName, alias)
+
+	if conn.Username == "" {
+		if current, err := user.Current(); err == nil {
+			conn.Username = current.Username
+		}
+	}
+
+	if conn.IdentityFile != "" {
+		conn.AuthMode = model.AuthModeKey
+	} else {
+		conn.AuthMode = model.AuthModeAgent
+	}
+	return conn, nil
+}
+
+// sshConfigForwardSpec converts "LocalForward <listen> <target>"
+// into the single-token form stored on SSHConnection.
+func sshConfigForwardSpec(opt sshConfigOption) (string, error) {
+	if len(opt.Args) != 2 {
+		return "", fmt.Errorf("%s expects a listen address and a target, got %q", opt.Key, strings.Join(opt.Args, " "))
+	}
+	return opt.Args[0] + ":" + opt.Args[1], nil
+}
+
+func matchesSSHConfigPatterns(patterns []string, host string) bool {
+	matched := false
+	for _, pattern := range patterns {
+		if negated, ok := strings.CutPrefix(pattern, "!"); ok {
+			if matchSSHConfigPattern(negated, host) {
+				return false
+			}
+			continue
+		}
+		if matchSSHConfigPattern(pattern, host) {
+			matched = true
+		}
+	}
+	return matched
+}
+
+// matchSSHConfigPattern implements ssh_config glob matching, where '*'
+// matches any run of characters and '?' matches exactly one.
+func matchSSHConfigPattern(pattern, value string) bool {
+	p := []rune(strings.ToLower(pattern))
+	v := []rune(strings.ToLower(value))
+
+	pi, vi := 0, 0
+	starPi, starVi := -1, 0
+	for vi < len(v) {
+		switch {
+		case pi < len(p) && (p[pi] == '?' || p[pi] == v[vi]):
+			pi++
+			vi++
+		case pi < len(p) && p[pi] == '*':
+			starPi = pi
+			starVi = vi
+			pi++
+		case starPi >= 0:
+			pi = starPi + 1
+			starVi++
+			vi = starVi
+		default:
+			return false
+		}
+	}
+	for pi < len(p) && p[pi] == '*' {
+		pi++
+	}
+	return pi == len(p)
+}
+
+func isSSHConfigWildcard(pattern string) bool {
+	return strings.HasPrefix(pattern, "!") || strings.ContainsAny(pattern, "*?")
+}
+
+func expandSSHConfigHostToken(value, alias string) string {
+	if !strings.Contains(value, "%") {
+		return value
+	}
+	replacer := strings.NewReplacer("%%", "%", "%h", alias)
+	return replacer.Replace(value)
+}
+
+func expandSSHConfigHome(path string) string {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return path
+	}
+	if path == "~" {
+		return homeDir
+	}
+	if rest, ok := strings.CutPrefix(path, "~/"); ok {
+		return filepath.Join(homeDir, rest)
+	}
+	return strings.ReplaceAll(path, "%d", homeDir)
+}
+
+const (
+	sshConfigManagedBegin = "# BEGIN sshmanager managed hosts"
+	sshConfigManagedEnd   = "# END sshmanager managed hosts"
+)
+
+// standaloneSSHArgDirectives maps the standalone flags accepted in
+// ExtraSSHArgs to their ssh_config equivalents.
+var standaloneSSHArgDirectives = map[string][]string{
+	"-4":   {"AddressFamily inet"},
+	"-6":   {"AddressFamily inet6"},
+	"-A":   {"ForwardAgent yes"},
+	"-a":   {"ForwardAgent no"},
+	"-C":   {"Compression yes"},
+	"-g":   {"GatewayPorts yes"},
+	"-K":   {"GSSAPIAuthentication yes", "GSSAPIDelegateCredentials yes"},
+	"-k":   {"GSSAPIDelegateCredentials no"},
+	"-N":   {"SessionType none"},
+	"-n":   {"StdinNull yes"},
+	"-q":   {"LogLevel QUIET"},
+	"-T":   {"RequestTTY no"},
+	"-t":   {"RequestTTY yes"},
+	"-v":   {"LogLevel DEBUG1"},
+	"-vv":  {"LogLevel DEBUG2"},
+	"-vvv": {"LogLevel DEBUG3"},
+	"-X":   {"ForwardX11 yes"},
+	"-x":   {"ForwardX11 no"},
+	"-Y":   {"ForwardX11 yes", "ForwardX11Trusted yes"},
+}
+
+// marshalSSHConfig renders connections, with profiles applied, as an OpenSSH
+// client config section wrapped in managed-section markers. Passwords are
+// never written.
+func marshalSSHConfig(connFile model.ConnectionFile) ([]byte, error) {
+	var b strings.Builder
+	b.WriteString(sshConfigManagedBegin + "\n")
+	b.WriteString("# Generated by `sshmanager export --format ssh-config`; edits inside this section are overwritten.\n")
+
+	for _, conn := range connFile.Connections {
+		resolved, err := connFile.ResolveConnection(conn)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", connectionLabel(conn), err)
+		}
+		lines, err := sshConfigHostLines(resolved)
+		if err != nil {
+			name := strings.TrimSpace(conn.Alias)
+			if name == "" {
+				name = conn.ID
+			}
+			return nil, fmt.Errorf("connection %q: %w", name, err)
+		}
+		b.WriteString("\n")
+		for _, line := range lines {
+			b.WriteString(line + "\n")
+		}
+	}
+
+	b.WriteString(sshConfigManagedEnd + "\n")
+	return []byte(b.String()), nil
+}
+
+func sshConfigHostLines(conn model.SSHConnection) ([]string, error) {
+	hostPattern := strings.TrimSpace(conn.Alias)
+	if hostPattern == "" {
+		hostPattern = conn.ID
+	}
+
+	lines := []string{"Host " + hostPattern}
+	addDirective := func(directive string) {
+		lines = append(lines, "  "+directive)
+	}
+
+	addDirective("HostName " + quoteSSHConfigArg(strings.TrimSpace(conn.Host)))
+	addDirective("User " + quoteSSHConfigArg(strings.TrimSpace(conn.Username)))
+	if conn.EffectivePort() != model.DefaultSSHPort {
+		addDirective("Port " + strconv.Itoa(conn.EffectivePort()))
+	}
+
+	switch conn.EffectiveAuthMode() {
+	case model.AuthModeKey:
+		addDirective("IdentityFile " + quoteSSHConfigArg(strings.TrimSpace(conn.IdentityFile)))
+		addDirective("IdentitiesOnly yes")
+	case model.AuthModePassword:
+		lines = append(lines, "  # password auth is stored in sshmanager only; use `sshmanager connect "+hostPattern+"`")
+		addDirective("PreferredAuthentications keyboard-interactive,password")
+	}
+
+	if proxyJump := strings.TrimSpace(conn.ProxyJump); proxyJump != "" {
+		addDirective("ProxyJump " + proxyJump)
+	}
+	for _, spec := range model.NormalizeStringList(conn.LocalForwards) {
+		listen, target, err := model.SplitForwardSpec(spec)
+		if err != nil {
+			return nil, err
+		}
+		addDirective("LocalForward " + listen + " " + target)
+	}
+	for _, spec := range model.NormalizeStringList(conn.RemoteForwards) {
+		listen, target, err := model.SplitForwardSpec(spec)
+		if err != nil {
+			return nil, err
+		}
+		addDirective("RemoteForward " + listen + " " + target)
+	}
+	for _, spec := range model.NormalizeStringList(conn.DynamicForwards) {
+		if err := model.ValidateDynamicForwardSpec(spec); err != nil {
+			return nil, err
+		}
+		addDirective("DynamicForward " + spec)
+	}
+
+	extraArgs := model.NormalizeStringList(conn.ExtraSSHArgs)
+	if err := model.ValidateExtraSSHArgs(extraArgs); err != nil {
+		return nil, err
+	}
+	for i := 0; i < len(extraArgs); i++ {
+		arg := extraArgs[i]
+		var option string
+		switch {
+		case arg == "-o":
+			i++
+			option = extraArgs[i]
+		case strings.HasPrefix(arg, "-o"):
+			option = strings.TrimPrefix(strings.TrimPrefix(arg, "-o"), "=")
+		default:
+			for _, directive := range standaloneSSHArgDirectives[arg] {
+				addDirective(directive)
+			}
+			continue
+		}
+		key, value, _ := strings.Cut(option, "=")
+		addDirective(strings.TrimSpace(key) + " " + quoteSSHConfigArg(strings.TrimSpace(value)))
+	}
+
+	return lines, nil
+}
+
+func quoteSSHConfigArg(value string) string {
+	if value == "" || strings.ContainsAny(value, " \t#") {
+		return `"` + value + `"`
+	}
+	return value
+}
+
+// spliceSSHConfigManagedSection replaces the managed section inside an
+// existing config, or appends it when no markers are present yet.
+func spliceSSHConfigManagedSection(existing, section []byte) ([]byte, error) {
+	content := string(existing)
+	begin := strings.Index(content, sshConfigManagedBegin)
+	if begin < 0 {
+		if strings.Contains(content, sshConfigManagedEnd) {
+			return nil, errors.New("existing ssh config has an end marker without a begin marker")
+		}
+		if strings.TrimSpace(content) == "" {
+			return section, nil
+		}
+		if !strings.HasSuffix(content, "\n") {
+			content += "\n"
+		}
+		return []byte(content + "\n" + string(section)), nil
+	}
+
+	endOffset := strings.Index(content[begin:], sshConfigManagedEnd)
+	if endOffset < 0 {
+		return nil, errors.New("existing ssh config has a begin marker without an end marker")
+	}
+	end := begin + endOffset + len(sshConfigManagedEnd)
+	if end < len(content) && content[end] == '\n' {
+		end++
+	}
+	return []byte(content[:begin] + string(section) + content[end:]), nil
+}
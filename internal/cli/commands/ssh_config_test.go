@@ -0,0 +1,298 @@
+package commands
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/emirhangumus/sshmanager/internal/model"
+)
+
+func TestHandleImportSSHConfig(t *testing.T) {
+	connPath, keyPath := prepareTransferFixture(t, nil)
+
+	configDir := t.TempDir()
+	identityFile := filepath.Join(configDir, "id_web")
+	includedPath := filepath.Join(configDir, "conf.d", "db.conf")
+	if err := os.MkdirAll(filepath.Dir(includedPath), 0o700); err != nil {
+		t.Fatalf("MkdirAll failed: %v", err)
+	}
+	included := "Host db\n  HostName db.internal\n  User postgres\n  Port 2200\n"
+	if err := os.WriteFile(includedPath, []byte(included), 0o600); err != nil {
+		t.Fatalf("failed to write included fixture: %v", err)
+	}
+
+	mainConfig := strings.Join([]string{
+		"# team hosts",
+		"Include conf.d/*.conf",
+		"",
+		"Host web web-alt",
+		"  HostName=%h.example.com",
+		"  IdentityFile \"" + identityFile + "\"",
+		"  ProxyJump bastion.example.com:2222",
+		"  LocalForward 8080 127.0.0.1:80",
+		"  RemoteForward 127.0.0.1:9000 localhost:9000",
+		"  DynamicForward 1080",
+		"  ForwardAgent yes",
+		"",
+		"Host *.internal !skip.internal",
+		"  User ops",
+		"",
+		"Host *",
+		"  User fallback",
+		"  ServerAliveInterval 30",
+		"",
+		"Match host foo",
+		"  User nobody",
+	}, "\n")
+	configPath := filepath.Join(configDir, "config")
+	if err := os.WriteFile(configPath, []byte(mainConfig), 0o600); err != nil {
+		t.Fatalf("failed to write ssh config fixture: %v", err)
+	}
+
+	var out strings.Builder
+	if err := handleImport(connPath, keyPath, []string{"--in", configPath, "--format", "ssh-config"}, &out); err != nil {
+		t.Fatalf("handleImport failed: %v", err)
+	}
+	for _, snippet := range []string{
+		"Skipped Host *.internal !skip.internal (wildcard-only pattern)",
+		"Skipped Host * (wildcard-only pattern)",
+		"Skipped Match host foo",
+		"Imported 3 connections",
+	} {
+		if !strings.Contains(out.String(), snippet) {
+			t.Fatalf("expected import output to include %q, got %q", snippet, out.String())
+		}
+	}
+
+	if count := strings.Count(out.String(), "Skipped Host * "); count != 1 {
+		t.Fatalf("expected exactly one Host * skip notice, got %d in %q", count, out.String())
+	}
+
+	loaded := loadTransferConnections(t, connPath, keyPath)
+	if len(loaded.Connections) != 3 {
+		t.Fatalf("expected 3 connections, got %d", len(loaded.Connections))
+	}
+
+	db := loaded.GetConnectionByAlias("db")
+	if db == nil {
+		t.Fatal("expected db connection from included file")
+	}
+	if db.Host != "db.internal" || db.Username != "postgres" || db.Port != 2200 {
+		t.Fatalf("unexpected db connection: %+v", *db)
+	}
+	if db.EffectiveAuthMode() != model.AuthModeAgent {
+		t.Fatalf("expected agent auth for db, got %q", db.EffectiveAuthMode())
+	}
+
+	web := loaded.GetConnectionByAlias("web")
+	if web == nil {
+		t.Fatal("expected web connection")
+	}
+	if web.Host != "web.example.com" {
+		t.Fatalf("expected %%h expansion in HostName, got %q", web.Host)
+	}
+	if web.Username != "fallback" {
+		t.Fatalf("expected username from Host * block, got %q", web.Username)
+	}
+	if web.AuthMode != model.AuthModeKey || web.IdentityFile != identityFile {
+		t.Fatalf("expected key auth with %q, got mode=%q identity=%q", identityFile, web.AuthMode, web.IdentityFile)
+	}
+	if web.ProxyJump != "bastion.example.com:2222" {
+		t.Fatalf("unexpected proxy jump: %q", web.ProxyJump)
+	}
+	assertStringSliceEqual(t, web.LocalForwards, []string{"8080:127.0.0.1:80"})
+	assertStringSliceEqual(t, web.RemoteForwards, []string{"127.0.0.1:9000:localhost:9000"})
+	assertStringSliceEqual(t, web.DynamicForwards, []string{"1080"})
+
+	if alt := loaded.GetConnectionByAlias("web-alt"); alt == nil || alt.Host != "web-alt.example.com" {
+		t.Fatalf("expected web-alt connection with expanded host, got %+v", alt)
+	}
+}
+
+func TestHandleImportSSHConfigRejectsInvalidDirective(t *testing.T) {
+	connPath, keyPath := prepareTransferFixture(t, nil)
+
+	configPath := filepath.Join(t.TempDir(), "config")
+	if err := os.WriteFile(configPath, []byte("Host bad\n  User u\n  Port nope\n"), 0o600); err != nil {
+		t.Fatalf("failed to write ssh config fixture: %v", err)
+	}
+
+	err := handleImport(connPath, keyPath, []string{"--in", configPath, "--format", "ssh-config"}, ioDiscard())
+	if err == nil || !strings.Contains(err.Error(), `ssh config host "bad"`) {
+		t.Fatalf("expected host-scoped error, got %v", err)
+	}
+}
+
+func TestSplitSSHConfigLine(t *testing.T) {
+	tests := []struct {
+		line     string
+		wantKey  string
+		wantArgs []string
+	}{
+		{line: "", wantKey: ""},
+		{line: "  # comment", wantKey: ""},
+		{line: "Host a b", wantKey: "host", wantArgs: []string{"a", "b"}},
+		{line: "HostName=example.com", wantKey: "hostname", wantArgs: []string{"example.com"}},
+		{line: "User = deploy", wantKey: "user", wantArgs: []string{"deploy"}},
+		{line: `IdentityFile "/path with space/id"`, wantKey: "identityfile", wantArgs: []string{"/path with space/id"}},
+		{line: "Port 22 # trailing", wantKey: "port", wantArgs: []string{"22"}},
+	}
+
+	for _, tc := range tests {
+		key, args, err := splitSSHConfigLine(tc.line)
+		if err != nil {
+			t.Fatalf("splitSSHConfigLine(%q) unexpected error: %v", tc.line, err)
+		}
+		if key != tc.wantKey {
+			t.Fatalf("splitSSHConfigLine(%q) key = %q, want %q", tc.line, key, tc.wantKey)
+		}
+		assertStringSliceEqual(t, args, tc.wantArgs)
+	}
+
+	if _, _, err := splitSSHConfigLine(`HostName "unterminated`); err == nil {
+		t.Fatal("expected error for unterminated quote")
+	}
+}
+
+func TestMatchesSSHConfigPatterns(t *testing.T) {
+	tests := []struct {
+		patterns []string
+		host     string
+		want     bool
+	}{
+		{patterns: []string{"*"}, host: "anything", want: true},
+		{patterns: []string{"web?"}, host: "web1", want: true},
+		{patterns: []string{"web?"}, host: "web10", want: false},
+		{patterns: []string{"*.internal"}, host: "DB.internal", want: true},
+		{patterns: []string{"*.internal", "!skip.internal"}, host: "skip.internal", want: false},
+		{patterns: []string{"!skip"}, host: "other", want: false},
+	}
+
+	for _, tc := range tests {
+		if got := matchesSSHConfigPatterns(tc.patterns, tc.host); got != tc.want {
+			t.Fatalf("matchesSSHConfigPatterns(%v, %q) = %t, want %t", tc.patterns, tc.host, got, tc.want)
+		}
+	}
+}
+
+func TestHandleExportSSHConfigReplacesManagedSection(t *testing.T) {
+	identityFile := filepath.Join(t.TempDir(), "id_edge")
+	connPath, keyPath := prepareTransferFixture(t, []model.SSHConnection{
+		{
+			Username: "ubuntu",
+			Host:     "app.internal",
+			AuthMode: model.AuthModePassword,
+			Password: "super-secret",
+			Alias:    "prod",
+		},
+		{
+			Username:        "deploy",
+			Host:            "edge.internal",
+			Port:            2222,
+			AuthMode:        model.AuthModeKey,
+			IdentityFile:    identityFile,
+			ProxyJump:       "bastion:2200",
+			LocalForwards:   []string{"127.0.0.1:8080:127.0.0.1:80"},
+			RemoteForwards:  []string{"9000:localhost:9000"},
+			DynamicForwards: []string{"127.0.0.1:1080"},
+			ExtraSSHArgs:    []string{"-A", "-o", "ServerAliveInterval=30", "-oStrictHostKeyChecking=yes"},
+			Alias:           "edge",
+		},
+	})
+
+	exportPath := filepath.Join(t.TempDir(), "config")
+	existing := strings.Join([]string{
+		"Host personal",
+		"  HostName personal.example.com",
+		"",
+		sshConfigManagedBegin,
+		"Host stale",
+		"  HostName stale.example.com",
+		sshConfigManagedEnd,
+		"",
+		"Host *",
+		"  ServerAliveCountMax 3",
+		"",
+	}, "\n")
+	if err := os.WriteFile(exportPath, []byte(existing), 0o600); err != nil {
+		t.Fatalf("failed to write existing config fixture: %v", err)
+	}
+
+	var out strings.Builder
+	if err := handleExport(connPath, keyPath, []string{"--format", "ssh-config", "--out", exportPath}, &out); err != nil {
+		t.Fatalf("handleExport failed: %v", err)
+	}
+	if !strings.Contains(out.String(), "(ssh-config)") {
+		t.Fatalf("unexpected export output: %q", out.String())
+	}
+
+	data, err := os.ReadFile(exportPath)
+	if err != nil {
+		t.Fatalf("failed to read export file: %v", err)
+	}
+	text := string(data)
+
+	if strings.Contains(text, "super-secret") {
+		t.Fatal("ssh-config export must not contain passwords")
+	}
+	if strings.Contains(text, "stale.example.com") {
+		t.Fatal("expected previous managed section to be replaced")
+	}
+	if strings.Count(text, sshConfigManagedBegin) != 1 || strings.Count(text, sshConfigManagedEnd) != 1 {
+		t.Fatalf("expected exactly one managed section, got %q", text)
+	}
+	for _, snippet := range []string{
+		"Host personal\n  HostName personal.example.com\n",
+		"Host *\n  ServerAliveCountMax 3\n",
+		"Host prod\n  HostName app.internal\n  User ubuntu\n",
+		"Host edge\n  HostName edge.internal\n  User deploy\n  Port 2222\n",
+		"  IdentityFile " + identityFile + "\n",
+		"  ProxyJump bastion:2200\n",
+		"  LocalForward 127.0.0.1:8080 127.0.0.1:80\n",
+		"  RemoteForward 9000 localhost:9000\n",
+		"  DynamicForward 127.0.0.1:1080\n",
+		"  ForwardAgent yes\n",
+		"  ServerAliveInterval 30\n",
+		"  StrictHostKeyChecking yes\n",
+	} {
+		if !strings.Contains(text, snippet) {
+			t.Fatalf("expected exported config to include %q, got %q", snippet, text)
+		}
+	}
+
+	// The managed section must be importable again.
+	reimportPath, reimportKey := prepareTransferFixture(t, nil)
+	if err := handleImport(reimportPath, reimportKey, []string{"--in", exportPath, "--format", "ssh-config"}, ioDiscard()); err != nil {
+		t.Fatalf("re-import of exported ssh config failed: %v", err)
+	}
+	reimported := loadTransferConnections(t, reimportPath, reimportKey)
+	edge := reimported.GetConnectionByAlias("edge")
+	if edge == nil || edge.Port != 2222 || edge.ProxyJump != "bastion:2200" {
+		t.Fatalf("unexpected re-imported edge connection: %+v", edge)
+	}
+	assertStringSliceEqual(t, edge.LocalForwards, []string{"127.0.0.1:8080:127.0.0.1:80"})
+	assertStringSliceEqual(t, edge.DynamicForwards, []string{"127.0.0.1:1080"})
+}
+
+func TestSpliceSSHConfigManagedSection(t *testing.T) {
+	section := []byte(sshConfigManagedBegin + "\nHost a\n" + sshConfigManagedEnd + "\n")
+
+	got, err := spliceSSHConfigManagedSection(nil, section)
+	if err != nil || string(got) != string(section) {
+		t.Fatalf("expected section for empty config, got %q (err=%v)", got, err)
+	}
+
+	got, err = spliceSSHConfigManagedSection([]byte("Host other"), section)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if string(got) != "Host other\n\n"+string(section) {
+		t.Fatalf("expected section to be appended, got %q", got)
+	}
+
+	if _, err := spliceSSHConfigManagedSection([]byte(sshConfigManagedBegin+"\nHost a\n"), section); err == nil {
+		t.Fatal("expected error for unterminated managed section")
+	}
+}
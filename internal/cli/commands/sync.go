@@ -0,0 +1,352 @@
+package commands
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/emirhangumus/sshmanager/internal/gitsync"
+	"github.com/emirhangumus/sshmanager/internal/model"
+	"github.com/emirhangumus/sshmanager/internal/store"
+	prompttext "github.com/emirhangumus/sshmanager/internal/ui/prompt"
+)
+
+const (
+	syncStrategyAsk    = "ask"
+	syncStrategyOurs   = "ours"
+	syncStrategyTheirs = "theirs"
+)
+
+// syncConflictResolver picks a side for every merge conflict, in order.
+// Tests replace the interactive one.
+type syncConflictResolver func(conflicts []model.MergeConflict) ([]model.MergeChoice, error)
+
+var errSyncCancelled = errors.New("sync cancelled")
+
+func HandleSync(connectionFilePath, secretKeyFilePath string, args []string) error {
+	return handleSync(connectionFilePath, secretKeyFilePath, args, os.Stdout, promptSyncConflicts)
+}
+
+func handleSync(connectionFilePath, secretKeyFilePath string, args []string, out io.Writer, resolve syncConflictResolver) error {
+	vaultDir := filepath.Dir(connectionFilePath)
+	if len(args) > 0 {
+		switch strings.ToLower(strings.TrimSpace(args[0])) {
+		case "init":
+			return handleSyncInit(vaultDir, connectionFilePath, args[1:], out)
+		case "status":
+			return handleSyncStatus(vaultDir, connectionFilePath, args[1:], out)
+		}
+	}
+
+	fs := flag.NewFlagSet("sync", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+
+	strategy := fs.String("strategy", syncStrategyAsk, "Conflict resolution: ask|ours|theirs")
+	noPush := fs.Bool("no-push", false, "Pull and merge, but do not push")
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() > 0 {
+		return fmt.Errorf("unexpected arguments for sync: %s", strings.Join(fs.Args(), " "))
+	}
+
+	switch strings.ToLower(strings.TrimSpace(*strategy)) {
+	case syncStrategyAsk:
+	case syncStrategyOurs:
+		resolve = resolveAllSyncConflicts(model.MergeKeepOurs)
+	case syncStrategyTheirs:
+		resolve = resolveAllSyncConflicts(model.MergeTakeTheirs)
+	default:
+		return fmt.Errorf("unknown sync strategy %q (use ask, ours or theirs)", *strategy)
+	}
+
+	repo, err := gitsync.Open(vaultDir)
+	if err != nil {
+		return err
+	}
+	connStore := store.NewConnectionStore(connectionFilePath, secretKeyFilePath, store.WithJournalCommand("sync"))
+	err = runSync(repo, connStore, connectionFilePath, !*noPush, out, resolve)
+	if errors.Is(err, errSyncCancelled) {
+		_, _ = fmt.Fprintln(out, prompttext.DefaultPromptTexts.SuccessMessages.OperationCancelled)
+		return nil
+	}
+	return err
+}
+
+func runSync(repo gitsync.Repo, connStore *store.ConnectionStore, connectionFilePath string, push bool, out io.Writer, resolve syncConflictResolver) error {
+	branch, err := repo.Branch()
+	if err != nil {
+		return err
+	}
+	if err := commitLocalConnections(repo, connectionFilePath); err != nil {
+		return err
+	}
+
+	remote, err := repo.Fetch(branch)
+	if err != nil {
+		return err
+	}
+	head, err := repo.Head()
+	if err != nil {
+		return err
+	}
+
+	pushed := func(message string) error {
+		if !push {
+			_, _ = fmt.Fprintln(out, "Local changes not pushed (--no-push).")
+			return nil
+		}
+		if err := repo.Push(branch); err != nil {
+			return err
+		}
+		_, _ = fmt.Fprintln(out, message)
+		return nil
+	}
+
+	if remote == "" {
+		return pushed(fmt.Sprintf("Pushed connections to new branch %s.", branch))
+	}
+	if remote == head {
+		_, _ = fmt.Fprintln(out, "Already up to date.")
+		return nil
+	}
+
+	if behind, err := repo.IsAncestor(remote, head); err != nil {
+		return err
+	} else if behind {
+		return pushed("Pushed local changes.")
+	}
+
+	if ahead, err := repo.IsAncestor(head, remote); err != nil {
+		return err
+	} else if ahead {
+		data, err := repo.Show(remote)
+		if err != nil {
+			return err
+		}
+		if err := connStore.ReplaceEncrypted(data); err != nil {
+			return remoteDecryptError(err)
+		}
+		if err := repo.FastForward(remote); err != nil {
+			return err
+		}
+		_, _ = fmt.Fprintln(out, "Pulled remote changes.")
+		if pulled, err := connStore.Load(); err == nil {
+			warnUntrustedPasswordCommands(filepath.Dir(connectionFilePath), out, &pulled)
+		}
+		return nil
+	}
+
+	return mergeSync(repo, connStore, connectionFilePath, head, remote, out, resolve, pushed)
+}
+
+// mergeSync merges diverged local and remote versions connection by
+// connection against their common ancestor, then records a git merge. The
+// members of a team vault are merged too.
+func mergeSync(repo gitsync.Repo, connStore *store.ConnectionStore, connectionFilePath, head, remote string, out io.Writer, resolve syncConflictResolver, pushed func(string) error) error {
+	base := model.NewConnectionFile()
+	var baseData []byte
+	baseRev, err := repo.MergeBase(head, remote)
+	if err != nil {
+		return err
+	}
+	if baseRev != "" {
+		if base, baseData, err = decryptRevision(repo, connStore, baseRev); err != nil {
+			return err
+		}
+	}
+	ours, _, err := decryptRevision(repo, connStore, head)
+	if err != nil {
+		return err
+	}
+	theirs, theirsData, err := decryptRevision(repo, connStore, remote)
+	if err != nil {
+		return err
+	}
+
+	result := model.MergeConnectionFiles(base, ours, theirs)
+	choices := []model.MergeChoice{}
+	if len(result.Conflicts) > 0 {
+		if choices, err = resolve(result.Conflicts); err != nil {
+			return err
+		}
+	}
+	merged, notes, err := result.Resolve(choices)
+	if err != nil {
+		return err
+	}
+
+	if err := connStore.SaveMerged(merged, baseData, theirsData); err != nil {
+		return err
+	}
+	if err := commitLocalConnections(repo, connectionFilePath); err != nil {
+		return err
+	}
+	if err := repo.RecordMerge(remote, gitsync.CommitMessage("Merge remote connections")); err != nil {
+		return err
+	}
+
+	for _, note := range notes {
+		_, _ = fmt.Fprintf(out, "Note: %s\n", note)
+	}
+	_, _ = fmt.Fprintf(out, "Merged remote changes (%d conflict(s) resolved).\n", len(result.Conflicts))
+	warnUntrustedPasswordCommands(filepath.Dir(connectionFilePath), out, &merged)
+	return pushed("Pushed merged connections.")
+}
+
+func commitLocalConnections(repo gitsync.Repo, connectionFilePath string) error {
+	data, err := os.ReadFile(connectionFilePath)
+	if err != nil {
+		return err
+	}
+	if len(data) == 0 {
+		return nil
+	}
+	_, err = repo.CommitFile(data, gitsync.CommitMessage("Update connections"))
+	return err
+}
+
+// decryptRevision returns the connection file at rev along with its
+// encrypted form.
+func decryptRevision(repo gitsync.Repo, connStore *store.ConnectionStore, rev string) (model.ConnectionFile, []byte, error) {
+	data, err := repo.Show(rev)
+	if err != nil {
+		return model.ConnectionFile{}, nil, err
+	}
+	connFile, err := connStore.DecryptConnectionData(data)
+	if err != nil {
+		return model.ConnectionFile{}, nil, remoteDecryptError(err)
+	}
+	return connFile, data, nil
+}
+
+func remoteDecryptError(err error) error {
+	return fmt.Errorf("failed to decrypt the synced connection file (every copy of a vault needs the same secret.key, or use a team vault): %w", err)
+}
+
+func resolveAllSyncConflicts(choice model.MergeChoice) syncConflictResolver {
+	return func(conflicts []model.MergeConflict) ([]model.MergeChoice, error) {
+		choices := make([]model.MergeChoice, len(conflicts))
+		for i := range choices {
+			choices[i] = choice
+		}
+		return choices, nil
+	}
+}
+
+func promptSyncConflicts(conflicts []model.MergeConflict) ([]model.MergeChoice, error) {
+	choices := make([]model.MergeChoice, 0, len(conflicts))
+	for i, conflict := range conflicts {
+		target := conflict.Label
+		if conflict.Field != "" {
+			target = fmt.Sprintf("%s, field %s", conflict.Label, conflict.Field)
+		}
+		label := fmt.Sprintf("Conflict %d/%d in %s %s", i+1, len(conflicts), conflict.Kind, target)
+		items := []string{
+			"Keep local: " + conflict.Describe(conflict.Ours),
+			"Take remote: " + conflict.Describe(conflict.Theirs),
+		}
+		index, _, err := prompttext.SelectPrompt(label, items)
+		if err != nil {
+			if prompttext.IsCancelError(err) {
+				return nil, errSyncCancelled
+			}
+			return nil, err
+		}
+		if index == 1 {
+			choices = append(choices, model.MergeTakeTheirs)
+		} else {
+			choices = append(choices, model.MergeKeepOurs)
+		}
+	}
+	return choices, nil
+}
+
+func handleSyncInit(vaultDir, connectionFilePath string, args []string, out io.Writer) error {
+	fs := flag.NewFlagSet("sync init", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+
+	branch := fs.String("branch", gitsync.DefaultBranch, "Branch to sync")
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return errors.New("usage: sshmanager sync init [--branch <name>] <repository>")
+	}
+
+	repo, err := gitsync.Init(vaultDir, fs.Arg(0), *branch)
+	if err != nil {
+		return err
+	}
+	if err := commitLocalConnections(repo, connectionFilePath); err != nil {
+		return err
+	}
+	name, _ := repo.Branch()
+	_, _ = fmt.Fprintf(out, "Sync repository created in %s (remote %s, branch %s).\nRun 'sshmanager sync' to pull and push. Every copy of this vault needs the same secret.key.\n", repo.Dir, strings.TrimSpace(fs.Arg(0)), name)
+	return nil
+}
+
+func handleSyncStatus(vaultDir, connectionFilePath string, args []string, out io.Writer) error {
+	if len(args) > 0 {
+		return fmt.Errorf("unexpected arguments for sync status: %s", strings.Join(args, " "))
+	}
+
+	repo, err := gitsync.Open(vaultDir)
+	if err != nil {
+		return err
+	}
+	url, err := repo.RemoteURL()
+	if err != nil {
+		return err
+	}
+	branch, err := repo.Branch()
+	if err != nil {
+		return err
+	}
+	_, _ = fmt.Fprintf(out, "Repository: %s\nRemote: %s\nBranch: %s\n", repo.Dir, url, branch)
+
+	current, err := os.ReadFile(connectionFilePath)
+	if err != nil {
+		return err
+	}
+	tracked, err := repo.Tracked()
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(current, tracked) {
+		_, _ = fmt.Fprintln(out, "Uncommitted local changes: yes")
+	}
+
+	remote, err := repo.Fetch(branch)
+	if err != nil {
+		return err
+	}
+	head, err := repo.Head()
+	if err != nil {
+		return err
+	}
+	switch {
+	case remote == "":
+		_, _ = fmt.Fprintln(out, "Remote branch does not exist yet; 'sshmanager sync' creates it.")
+	case head == "":
+		_, _ = fmt.Fprintln(out, "No local commits yet.")
+	default:
+		ahead, err := repo.Count(head, remote)
+		if err != nil {
+			return err
+		}
+		behind, err := repo.Count(remote, head)
+		if err != nil {
+			return err
+		}
+		_, _ = fmt.Fprintf(out, "Ahead: %d commit(s)\nBehind: %d commit(s)\n", ahead, behind)
+	}
+	return nil
+}
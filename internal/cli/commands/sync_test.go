@@ -0,0 +1,285 @@
+package commands
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	cryptoutil "github.com/emirhangumus/sshmanager/internal/crypto"
+	"github.com/emirhangumus/sshmanager/internal/gitsync"
+	"github.com/emirhangumus/sshmanager/internal/model"
+	"github.com/emirhangumus/sshmanager/internal/storage"
+	"github.com/emirhangumus/sshmanager/internal/store"
+)
+
+// newSyncRemote creates a bare repository on local disk.
+func newSyncRemote(t *testing.T) string {
+	t.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	remote := filepath.Join(t.TempDir(), "remote.git")
+	if out, err := exec.Command("git", "init", "-q", "--bare", remote).CombinedOutput(); err != nil {
+		t.Fatalf("git init --bare failed: %v: %s", err, out)
+	}
+	return remote
+}
+
+// cloneVault creates an empty vault that shares keyPath, like a second
+// machine that had secret.key copied over.
+func cloneVault(t *testing.T, keyPath string) (string, string) {
+	t.Helper()
+	dir := t.TempDir()
+	connPath := filepath.Join(dir, "conn")
+	clonedKey := filepath.Join(dir, "secret.key")
+	key, err := os.ReadFile(keyPath)
+	if err != nil {
+		t.Fatalf("ReadFile(key) failed: %v", err)
+	}
+	if err := os.WriteFile(clonedKey, key, 0o600); err != nil {
+		t.Fatalf("WriteFile(key) failed: %v", err)
+	}
+	if err := storage.CreateFileIfNotExists(connPath, 0o600); err != nil {
+		t.Fatalf("CreateFileIfNotExists failed: %v", err)
+	}
+	if err := store.NewConnectionStore(connPath, clonedKey).InitializeIfEmpty(); err != nil {
+		t.Fatalf("InitializeIfEmpty failed: %v", err)
+	}
+	return connPath, clonedKey
+}
+
+func runTestSync(t *testing.T, connPath, keyPath string, resolve syncConflictResolver, args ...string) string {
+	t.Helper()
+	var out bytes.Buffer
+	if resolve == nil {
+		resolve = func(conflicts []model.MergeConflict) ([]model.MergeChoice, error) {
+			t.Fatalf("unexpected conflicts: %v", conflicts)
+			return nil, nil
+		}
+	}
+	if err := handleSync(connPath, keyPath, args, &out, resolve); err != nil {
+		t.Fatalf("sync %v failed: %v", args, err)
+	}
+	return out.String()
+}
+
+func updateTestConnection(t *testing.T, connPath, keyPath, alias string, change func(*model.SSHConnection)) {
+	t.Helper()
+	err := store.NewConnectionStore(connPath, keyPath).Update(func(connFile *model.ConnectionFile) error {
+		change(connFile.GetConnectionByAlias(alias))
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+}
+
+func TestHandleSyncMergesTwoVaultsThroughBareRepo(t *testing.T) {
+	remote := newSyncRemote(t)
+	connA, keyA := prepareTransferFixture(t, []model.SSHConnection{
+		{Username: "ops", Host: "web.internal", Port: 22, Alias: "web"},
+		{Username: "ops", Host: "db.internal", Port: 22, Alias: "db"},
+	})
+	connB, keyB := cloneVault(t, keyA)
+
+	runTestSync(t, connA, keyA, nil, "init", remote)
+	if out := runTestSync(t, connA, keyA, nil); !strings.Contains(out, "new branch main") {
+		t.Fatalf("expected first push, got %q", out)
+	}
+
+	runTestSync(t, connB, keyB, nil, "init", remote)
+	runTestSync(t, connB, keyB, nil)
+	if got := loadTransferConnections(t, connB, keyB); got.GetConnectionByAlias("web") == nil || got.GetConnectionByAlias("db") == nil {
+		t.Fatalf("expected B to receive A's connections, got %+v", got.Connections)
+	}
+	if out := runTestSync(t, connA, keyA, nil); !strings.Contains(out, "Pulled remote changes") {
+		t.Fatalf("expected A to fast-forward, got %q", out)
+	}
+
+	// Diverge: independent edits merge silently, the same field conflicts.
+	updateTestConnection(t, connA, keyA, "web", func(conn *model.SSHConnection) {
+		conn.Port = 2222
+		conn.Description = "edited on A"
+	})
+	updateTestConnection(t, connB, keyB, "web", func(conn *model.SSHConnection) {
+		conn.Description = "edited on B"
+		conn.Tags = []string{"prod"}
+	})
+	updateTestConnection(t, connB, keyB, "db", func(conn *model.SSHConnection) { conn.Host = "db2.internal" })
+	runTestSync(t, connA, keyA, nil)
+
+	var seen []model.MergeConflict
+	out := runTestSync(t, connB, keyB, func(conflicts []model.MergeConflict) ([]model.MergeChoice, error) {
+		seen = conflicts
+		return []model.MergeChoice{model.MergeTakeTheirs}, nil
+	})
+	if len(seen) != 1 || seen[0].Field != "description" || seen[0].Ours != "edited on B" || seen[0].Theirs != "edited on A" {
+		t.Fatalf("expected one description conflict, got %v", seen)
+	}
+	if !strings.Contains(out, "1 conflict(s) resolved") {
+		t.Fatalf("unexpected merge output: %q", out)
+	}
+
+	runTestSync(t, connA, keyA, nil)
+	for _, vault := range [][2]string{{connA, keyA}, {connB, keyB}} {
+		got := loadTransferConnections(t, vault[0], vault[1])
+		web := got.GetConnectionByAlias("web")
+		if web.Port != 2222 || web.Description != "edited on A" || len(web.Tags) != 1 {
+			t.Fatalf("unexpected merged web in %s: %+v", vault[0], web)
+		}
+		if got.GetConnectionByAlias("db").Host != "db2.internal" {
+			t.Fatalf("expected db change from B in %s", vault[0])
+		}
+	}
+	if out := runTestSync(t, connB, keyB, nil); !strings.Contains(out, "Already up to date") {
+		t.Fatalf("expected no further changes, got %q", out)
+	}
+}
+
+func TestUpdateCommitsToSyncRepo(t *testing.T) {
+	remote := newSyncRemote(t)
+	connPath, keyPath := prepareTransferFixture(t, []model.SSHConnection{{Username: "ops", Host: "web", Alias: "web"}})
+	runTestSync(t, connPath, keyPath, nil, "init", remote)
+
+	repo, err := gitsync.Open(filepath.Dir(connPath))
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	before, _ := repo.Head()
+	updateTestConnection(t, connPath, keyPath, "web", func(conn *model.SSHConnection) { conn.Port = 2200 })
+	after, _ := repo.Head()
+	if before == after {
+		t.Fatal("expected Update to create a commit")
+	}
+	tracked, _ := repo.Tracked()
+	current, _ := os.ReadFile(connPath)
+	if !bytes.Equal(tracked, current) {
+		t.Fatal("expected the committed file to match the store")
+	}
+
+	var out bytes.Buffer
+	if err := handleSync(connPath, keyPath, []string{"status"}, &out, nil); err != nil {
+		t.Fatalf("sync status failed: %v", err)
+	}
+	if !strings.Contains(out.String(), "Remote branch does not exist yet") || strings.Contains(out.String(), "Uncommitted") {
+		t.Fatalf("unexpected status: %q", out.String())
+	}
+}
+
+// newTeamSyncMember creates a vault whose key file holds a fresh member
+// identity and whose connection file is still empty.
+func newTeamSyncMember(t *testing.T) (string, string, cryptoutil.Identity) {
+	t.Helper()
+	id, err := cryptoutil.GenerateIdentity()
+	if err != nil {
+		t.Fatalf("GenerateIdentity failed: %v", err)
+	}
+	dir := t.TempDir()
+	keyPath := filepath.Join(dir, "secret.key")
+	if err := cryptoutil.WriteIdentityFile(keyPath, id); err != nil {
+		t.Fatalf("WriteIdentityFile failed: %v", err)
+	}
+	connPath := filepath.Join(dir, "conn")
+	if err := storage.CreateFileIfNotExists(connPath, 0o600); err != nil {
+		t.Fatalf("CreateFileIfNotExists failed: %v", err)
+	}
+	return connPath, keyPath, id
+}
+
+func TestHandleSyncMergesTeamMembers(t *testing.T) {
+	remote := newSyncRemote(t)
+	connA, keyA, _ := newTeamSyncMember(t)
+	connB, keyB, bobID := newTeamSyncMember(t)
+	_, _, carolID := newTeamSyncMember(t)
+	_, _, daveID := newTeamSyncMember(t)
+
+	alice := store.NewConnectionStore(connA, keyA)
+	if err := alice.InitTeam("alice"); err != nil {
+		t.Fatalf("InitTeam failed: %v", err)
+	}
+	for name, id := range map[string]cryptoutil.Identity{"bob": bobID, "carol": carolID} {
+		if err := alice.AddTeamMember(name, id.Recipient()); err != nil {
+			t.Fatalf("AddTeamMember(%s) failed: %v", name, err)
+		}
+	}
+	runTestSync(t, connA, keyA, nil, "init", remote)
+	runTestSync(t, connA, keyA, nil)
+
+	// Bob joins with a copy of the shared file, as after 'vault member add'.
+	shared, err := os.ReadFile(connA)
+	if err != nil {
+		t.Fatalf("ReadFile failed: %v", err)
+	}
+	if err := os.WriteFile(connB, shared, 0o600); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+	runTestSync(t, connB, keyB, nil, "init", remote)
+	runTestSync(t, connB, keyB, nil)
+
+	// Diverge: alice removes carol, bob adds dave and a connection.
+	if err := alice.RemoveTeamMember("carol"); err != nil {
+		t.Fatalf("RemoveTeamMember failed: %v", err)
+	}
+	runTestSync(t, connA, keyA, nil)
+	bob := store.NewConnectionStore(connB, keyB)
+	if err := bob.AddTeamMember("dave", daveID.Recipient()); err != nil {
+		t.Fatalf("AddTeamMember(dave) failed: %v", err)
+	}
+	if err := bob.Update(func(connFile *model.ConnectionFile) error {
+		return connFile.AddConnection(model.SSHConnection{Username: "ops", Host: "db.internal", AuthMode: model.AuthModeAgent, Alias: "db"})
+	}); err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+	if out := runTestSync(t, connB, keyB, nil); !strings.Contains(out, "Merged remote changes") {
+		t.Fatalf("expected a merge, got %q", out)
+	}
+	runTestSync(t, connA, keyA, nil)
+
+	for _, connPath := range []string{connA, connB} {
+		data, err := os.ReadFile(connPath)
+		if err != nil {
+			t.Fatalf("ReadFile failed: %v", err)
+		}
+		members, err := cryptoutil.InspectTeamEnvelope(data)
+		if err != nil {
+			t.Fatalf("InspectTeamEnvelope failed: %v", err)
+		}
+		var names []string
+		for _, member := range members {
+			names = append(names, member.Name)
+		}
+		assertStringSliceEqual(t, names, []string{"alice", "bob", "dave"})
+		if _, _, _, err := cryptoutil.OpenTeamEnvelope(data, carolID); !errors.Is(err, cryptoutil.ErrNotTeamMember) {
+			t.Fatalf("expected the removed member to be locked out of %s, got %v", connPath, err)
+		}
+		content, _, _, err := cryptoutil.OpenTeamEnvelope(data, daveID)
+		if err != nil || !strings.Contains(content, "db.internal") {
+			t.Fatalf("expected the added member to read the merged connections, got %v", err)
+		}
+	}
+}
+
+func TestHandleSyncRejectsInvalidArguments(t *testing.T) {
+	connPath, keyPath := prepareTransferFixture(t, nil)
+
+	cases := []struct {
+		args []string
+		want string
+	}{
+		{nil, "sync is not set up"},
+		{[]string{"--strategy", "newest"}, "unknown sync strategy"},
+		{[]string{"extra"}, "unexpected arguments for sync"},
+		{[]string{"init"}, "usage: sshmanager sync init"},
+		{[]string{"status"}, "sync is not set up"},
+	}
+	for _, tc := range cases {
+		err := handleSync(connPath, keyPath, tc.args, ioDiscard(), nil)
+		if err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Fatalf("handleSync(%v) error = %v, want %q", tc.args, err, tc.want)
+		}
+	}
+}
@@ -29,8 +29,9 @@ func HandleExport(connectionFilePath, secretKeyFilePath string, args []string) e
 func handleExport(connectionFilePath, secretKeyFilePath string, args []string, out io.Writer) error {
 	fs := flag.NewFlagSet("export", flag.ContinueOnError)
 	fs.SetOutput(io.Discard)
-	format := fs.String("format", "yaml", "Export format: yaml|json")
+	format := fs.String("format", "yaml", "Export format: yaml|json|ssh-config")
 	outPath := fs.String("out", "", "Export file path")
+	resolved := fs.Bool("resolved", false, "Apply profiles and export flattened connections without profiles (yaml/json)")
 
 	if err := fs.Parse(args); err != nil {
 		return err
@@ -49,12 +50,29 @@ func handleExport(connectionFilePath, secretKeyFilePath string, args []string, o
 	if err != nil {
 		return err
 	}
+	if *resolved {
+		connFile, err = flattenConnectionFile(connFile)
+		if err != nil {
+			return err
+		}
+	}
 
 	serialized, normalizedFormat, err := marshalConnectionFile(connFile, *format)
 	if err != nil {
 		return err
 	}
 
+	if normalizedFormat == importFormatSSHConfig {
+		existing, readErr := os.ReadFile(target)
+		if readErr != nil && !os.IsNotExist(readErr) {
+			return fmt.Errorf("failed to read existing ssh config: %w", readErr)
+		}
+		serialized, err = spliceSSHConfigManagedSection(existing, serialized)
+		if err != nil {
+			return err
+		}
+	}
+
 	if err := storage.WriteFileAtomic(target, serialized, 0o600); err != nil {
 		return fmt.Errorf("failed to write export file: %w", err)
 	}
@@ -71,7 +89,7 @@ func handleImport(connectionFilePath, secretKeyFilePath string, args []string, o
 	fs := flag.NewFlagSet("import", flag.ContinueOnError)
 	fs.SetOutput(io.Discard)
 	inPath := fs.String("in", "", "Import file path")
-	format := fs.String("format", "auto", "Import format: auto|yaml|json")
+	format := fs.String("format", "auto", "Import format: auto|yaml|json|ssh-config")
 	mode := fs.String("mode", importModeMerge, "Import mode: merge|replace")
 
 	if err := fs.Parse(args); err != nil {
@@ -91,21 +109,27 @@ func handleImport(connectionFilePath, secretKeyFilePath string, args []string, o
 		return fmt.Errorf("failed to read import file: %w", err)
 	}
 
-	importFile, err := decodeImportedConnectionFile(payload, *format, source)
+	var importFile model.ConnectionFile
+	var skipped []string
+	if normalizeImportFormat(*format, source) == importFormatSSHConfig {
+		importFile, skipped, err = decodeSSHConfigConnectionFile(payload, source)
+	} else {
+		importFile, err = decodeImportedConnectionFile(payload, *format, source)
+	}
 	if err != nil {
 		return err
 	}
 
-	connStore := store.NewConnectionStore(connectionFilePath, secretKeyFilePath)
+	connStore := store.NewConnectionStore(connectionFilePath, secretKeyFilePath, store.WithJournalCommand("import"))
 	modeNorm := strings.ToLower(strings.TrimSpace(*mode))
 	switch modeNorm {
 	case importModeMerge:
 		err = connStore.Update(func(connFile *model.ConnectionFile) error {
-			return mergeImportedConnections(connFile, importFile.Connections)
+			return mergeImportedConnections(connFile, importFile)
 		})
 	case importModeReplace:
 		err = connStore.Update(func(connFile *model.ConnectionFile) error {
-			replacement, buildErr := buildConnectionFile(importFile.Connections)
+			replacement, buildErr := buildConnectionFile(importFile)
 			if buildErr != nil {
 				return buildErr
 			}
@@ -119,10 +143,29 @@ func handleImport(connectionFilePath, secretKeyFilePath string, args []string, o
 		return err
 	}
 
+	for _, notice := range skipped {
+		_, _ = fmt.Fprintf(out, "Skipped %s\n", notice)
+	}
 	_, _ = fmt.Fprintf(out, "Imported %d connections from %s using %s mode\n", len(importFile.Connections), source, modeNorm)
+	warnUntrustedPasswordCommands(filepath.Dir(connectionFilePath), out, &importFile)
 	return nil
 }
 
+// flattenConnectionFile applies every connection's profile chain and drops
+// the profiles, producing a file that needs no profile support to read.
+func flattenConnectionFile(connFile model.ConnectionFile) (model.ConnectionFile, error) {
+	connections, err := resolveConnections(&connFile, connFile.Connections)
+	if err != nil {
+		return model.ConnectionFile{}, err
+	}
+	for i := range connections {
+		connections[i].Extends = ""
+	}
+	connFile.Connections = connections
+	connFile.Profiles = nil
+	return connFile, nil
+}
+
 func marshalConnectionFile(connFile model.ConnectionFile, format string) ([]byte, string, error) {
 	switch strings.ToLower(strings.TrimSpace(format)) {
 	case "yaml", "yml", "":
@@ -137,8 +180,14 @@ func marshalConnectionFile(connFile model.ConnectionFile, format string) ([]byte
 			return nil, "", fmt.Errorf("failed to marshal JSON: %w", err)
 		}
 		return append(b, '\n'), "json", nil
+	case importFormatSSHConfig, "sshconfig", "ssh_config":
+		b, err := marshalSSHConfig(connFile)
+		if err != nil {
+			return nil, "", fmt.Errorf("failed to render ssh config: %w", err)
+		}
+		return b, importFormatSSHConfig, nil
 	default:
-		return nil, "", fmt.Errorf("unknown export format %q (use yaml or json)", format)
+		return nil, "", fmt.Errorf("unknown export format %q (use yaml, json, or ssh-config)", format)
 	}
 }
 
@@ -162,15 +211,18 @@ func decodeImportedConnectionFile(data []byte, formatHint, inPath string) (model
 		}
 		return model.ConnectionFile{}, errors.New("failed to decode import file as JSON or YAML")
 	default:
-		return model.ConnectionFile{}, fmt.Errorf("unknown import format %q (use auto, yaml, or json)", formatHint)
+		return model.ConnectionFile{}, fmt.Errorf("unknown import format %q (use auto, yaml, json, or ssh-config)", formatHint)
 	}
 }
 
 func normalizeImportFormat(formatHint, inPath string) string {
 	norm := strings.ToLower(strings.TrimSpace(formatHint))
 	if norm != "" && norm != "auto" {
-		if norm == "yml" {
+		switch norm {
+		case "yml":
 			return "yaml"
+		case "sshconfig", "ssh_config":
+			return importFormatSSHConfig
 		}
 		return norm
 	}
@@ -238,9 +290,12 @@ func decodeYAMLConnectionFile(data []byte) (model.ConnectionFile, error) {
 	return model.ConnectionFile{}, errors.New("failed to decode YAML content")
 }
 
-func buildConnectionFile(connections []model.SSHConnection) (model.ConnectionFile, error) {
+func buildConnectionFile(source model.ConnectionFile) (model.ConnectionFile, error) {
 	built := model.NewConnectionFile()
-	for _, raw := range connections {
+	if err := mergeImportedProfiles(&built, source.Profiles); err != nil {
+		return model.ConnectionFile{}, err
+	}
+	for _, raw := range source.Connections {
 		normalized, err := normalizeImportedConnection(raw)
 		if err != nil {
 			return model.ConnectionFile{}, err
@@ -249,11 +304,17 @@ func buildConnectionFile(connections []model.SSHConnection) (model.ConnectionFil
 			return model.ConnectionFile{}, err
 		}
 	}
+	if err := validateProfiles(&built); err != nil {
+		return model.ConnectionFile{}, err
+	}
 	return built, nil
 }
 
-func mergeImportedConnections(target *model.ConnectionFile, incoming []model.SSHConnection) error {
-	for _, raw := range incoming {
+func mergeImportedConnections(target *model.ConnectionFile, source model.ConnectionFile) error {
+	if err := mergeImportedProfiles(target, source.Profiles); err != nil {
+		return err
+	}
+	for _, raw := range source.Connections {
 		normalized, err := normalizeImportedConnection(raw)
 		if err != nil {
 			return err
@@ -281,6 +342,25 @@ func mergeImportedConnections(target *model.ConnectionFile, incoming []model.SSH
 			return err
 		}
 	}
+	return validateProfiles(target)
+}
+
+// mergeImportedProfiles replaces profiles by name and appends new ones.
+// Chains are checked by validateProfiles once every profile is in place,
+// so imported profiles may extend each other in any order.
+func mergeImportedProfiles(target *model.ConnectionFile, incoming []model.ConnectionProfile) error {
+	for _, raw := range incoming {
+		normalized, err := normalizeProfile(raw)
+		if err != nil {
+			return err
+		}
+		if existing := target.GetProfile(normalized.Name); existing != nil {
+			normalized.Name = existing.Name
+			*existing = normalized
+			continue
+		}
+		target.Profiles = append(target.Profiles, normalized)
+	}
 	return nil
 }
 
@@ -294,11 +374,14 @@ func normalizeImportedConnection(conn model.SSHConnection) (model.SSHConnection,
 	conn.IdentityFile = strings.TrimSpace(conn.IdentityFile)
 	conn.LocalForwards = model.NormalizeStringList(conn.LocalForwards)
 	conn.RemoteForwards = model.NormalizeStringList(conn.RemoteForwards)
+	conn.DynamicForwards = model.NormalizeStringList(conn.DynamicForwards)
 	conn.ExtraSSHArgs = model.NormalizeStringList(conn.ExtraSSHArgs)
 	conn.Tags = model.NormalizeTags(conn.Tags)
 	conn.AuthMode = model.NormalizeAuthMode(conn.AuthMode)
+	conn.Extends = strings.TrimSpace(conn.Extends)
+	conn.HostKeys = model.NormalizeStringList(conn.HostKeys)
 
-	if conn.Username == "" {
+	if conn.Username == "" && conn.Extends == "" {
 		return model.SSHConnection{}, errors.New("imported connection has empty username")
 	}
 	if conn.Host == "" {
@@ -316,6 +399,9 @@ func normalizeImportedConnection(conn model.SSHConnection) (model.SSHConnection,
 	if err := model.ValidateForwardSpecs(conn.RemoteForwards); err != nil {
 		return model.SSHConnection{}, fmt.Errorf("imported connection has invalid remoteForwards: %w", err)
 	}
+	if err := model.ValidateDynamicForwardSpecs(conn.DynamicForwards); err != nil {
+		return model.SSHConnection{}, fmt.Errorf("imported connection has invalid dynamicForwards: %w", err)
+	}
 	if err := model.ValidateExtraSSHArgs(conn.ExtraSSHArgs); err != nil {
 		return model.SSHConnection{}, fmt.Errorf("imported connection has invalid extraSSHArgs: %w", err)
 	}
@@ -325,27 +411,46 @@ func normalizeImportedConnection(conn model.SSHConnection) (model.SSHConnection,
 	if err := model.ValidateTags(conn.Tags); err != nil {
 		return model.SSHConnection{}, fmt.Errorf("imported connection has invalid tags: %w", err)
 	}
+	if err := model.ValidateHostKeys(conn.HostKeys); err != nil {
+		return model.SSHConnection{}, fmt.Errorf("imported connection has invalid hostKeys: %w", err)
+	}
+	passwordSource, err := normalizePasswordSource(conn.PasswordSource)
+	if err != nil {
+		return model.SSHConnection{}, fmt.Errorf("imported connection has invalid passwordSource: %w", err)
+	}
+	conn.PasswordSource = passwordSource
+
+	// Connections extending a profile inherit auth settings when they set
+	// none of their own; validateResolvedConnection checks the result.
+	if conn.Extends != "" && !model.HasAuthSettings(conn.AuthMode, conn.Password, conn.PasswordSource, conn.IdentityFile) {
+		return conn, nil
+	}
 
 	conn.AuthMode = conn.EffectiveAuthMode()
 	switch conn.AuthMode {
 	case model.AuthModePassword:
 		conn.IdentityFile = ""
-		if strings.TrimSpace(conn.Password) == "" {
+		if conn.PasswordSource != "" {
+			conn.Password = ""
+		} else if strings.TrimSpace(conn.Password) == "" {
 			return model.SSHConnection{}, errors.New("imported password auth connection is missing password")
 		}
 	case model.AuthModeKey:
 		conn.Password = ""
+		conn.PasswordSource = ""
 		if conn.IdentityFile == "" {
 			return model.SSHConnection{}, errors.New("imported key auth connection is missing identityFile")
 		}
 	case model.AuthModeAgent:
 		conn.Password = ""
+		conn.PasswordSource = ""
 		conn.IdentityFile = ""
 	default:
 		return model.SSHConnection{}, fmt.Errorf("unsupported imported auth mode %q", conn.AuthMode)
 	}
 
-	if conn.Port == model.DefaultSSHPort {
+	// An explicit port 22 must still override a profile port.
+	if conn.Port == model.DefaultSSHPort && conn.Extends == "" {
 		conn.Port = 0
 	}
 
@@ -58,6 +58,48 @@ func TestHandleExportWritesJSONFile(t *testing.T) {
 	}
 }
 
+func TestExportImportRoundTripsForwardForms(t *testing.T) {
+	localForwards := []string{
+		"[::1]:8080:[2001:db8::1]:80",
+		"2375:/var/run/docker.sock",
+		"/tmp/db.sock:db.internal:5432",
+		"/tmp/docker.sock:/var/run/docker.sock",
+	}
+	remoteForwards := []string{
+		"[::]:9000:localhost:3000",
+		"/run/app.sock:127.0.0.1:8080",
+	}
+
+	for _, format := range []string{"yaml", "json", "ssh-config"} {
+		t.Run(format, func(t *testing.T) {
+			connPath, keyPath := prepareTransferFixture(t, []model.SSHConnection{{
+				Username:       "deploy",
+				Host:           "docker.internal",
+				AuthMode:       model.AuthModeAgent,
+				Alias:          "docker",
+				LocalForwards:  localForwards,
+				RemoteForwards: remoteForwards,
+			}})
+			exportPath := filepath.Join(t.TempDir(), "export."+format)
+			if err := handleExport(connPath, keyPath, []string{"--format", format, "--out", exportPath}, ioDiscard()); err != nil {
+				t.Fatalf("handleExport failed: %v", err)
+			}
+
+			importPath, importKey := prepareTransferFixture(t, nil)
+			if err := handleImport(importPath, importKey, []string{"--in", exportPath, "--format", format}, ioDiscard()); err != nil {
+				t.Fatalf("handleImport failed: %v", err)
+			}
+			imported := loadTransferConnections(t, importPath, importKey)
+			conn := imported.GetConnectionByAlias("docker")
+			if conn == nil {
+				t.Fatal("expected the docker connection to be imported")
+			}
+			assertStringSliceEqual(t, conn.LocalForwards, localForwards)
+			assertStringSliceEqual(t, conn.RemoteForwards, remoteForwards)
+		})
+	}
+}
+
 func TestHandleImportReplaceMode(t *testing.T) {
 	connPath, keyPath := prepareTransferFixture(t, []model.SSHConnection{
 		{
@@ -0,0 +1,243 @@
+package commands
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/emirhangumus/sshmanager/internal/model"
+	"github.com/emirhangumus/sshmanager/internal/nativessh"
+	"github.com/emirhangumus/sshmanager/internal/store"
+	prompttext "github.com/emirhangumus/sshmanager/internal/ui/prompt"
+)
+
+const trustScanTimeout = 10 * time.Second
+
+// scanHostKeys and captureHostKeysOnConnect fetch the host keys trust
+// offers to pin. Tests replace them.
+var (
+	scanHostKeys = func(conn model.SSHConnection) ([]string, error) {
+		return nativessh.ScanHostKeys(conn.DialAddress(), trustScanTimeout)
+	}
+	captureHostKeysOnConnect = captureHostKeysWithSSH
+)
+
+// confirmTrust asks before host keys are pinned. Tests replace it.
+var confirmTrust = func(label string) (bool, error) {
+	value, err := prompttext.InputPrompt(
+		fmt.Sprintf("Pin these host keys for %s? Type 'yes' to continue", label),
+		"",
+		false,
+		nil,
+	)
+	if err != nil {
+		if prompttext.IsCancelError(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	return strings.EqualFold(strings.TrimSpace(value), "yes"), nil
+}
+
+func HandleTrust(connectionFilePath, secretKeyFilePath string, args []string) error {
+	return handleTrust(connectionFilePath, secretKeyFilePath, args, os.Stdout)
+}
+
+// handleTrust pins the host keys of a connection. Keys are fetched with a
+// key scan, or with --connect by letting ssh record the key of a first
+// connection (needed behind ProxyJump). Once keys are pinned, a server
+// presenting a different key fails loudly here and on every connect until
+// --replace accepts it.
+func handleTrust(connectionFilePath, secretKeyFilePath string, args []string, out io.Writer) error {
+	fs := flag.NewFlagSet("trust", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+
+	alias := fs.String("alias", "", "Connection alias")
+	id := fs.String("id", "", "Connection ID")
+	viaConnect := fs.Bool("connect", false, "Record the key of a first ssh connection instead of scanning")
+	replace := fs.Bool("replace", false, "Replace pinned keys that no longer match")
+	yes := fs.Bool("yes", false, "Skip confirmation prompt")
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	selectedAlias, selectedID, err := resolveSelector(*alias, *id, fs.Args(), "trust")
+	if err != nil {
+		return err
+	}
+	if selectedAlias == "" && selectedID == "" {
+		return errors.New("trust requires a connection alias or --id")
+	}
+
+	connStore := store.NewConnectionStore(connectionFilePath, secretKeyFilePath, store.WithJournalCommand("trust"))
+	connFile, err := connStore.Load()
+	if err != nil {
+		return err
+	}
+	conn := findConnectionBySelector(&connFile, selectedAlias, selectedID)
+	if conn == nil {
+		return errors.New(notFoundMessage(selectedAlias, selectedID))
+	}
+	resolved, err := connFile.ResolveConnection(*conn)
+	if err != nil {
+		return err
+	}
+	label := connectionLabel(*conn)
+
+	var keys []string
+	if *viaConnect {
+		keys, err = captureHostKeysOnConnect(resolved)
+	} else {
+		if strings.TrimSpace(resolved.ProxyJump) != "" {
+			return fmt.Errorf("%s is reached through ProxyJump and cannot be scanned directly; use 'sshmanager trust --connect %s'", label, trustSelector(conn))
+		}
+		keys, err = scanHostKeys(resolved)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to fetch the host keys of %s: %w", label, err)
+	}
+
+	pinned := model.NormalizeStringList(conn.HostKeys)
+	var unknown []string
+	for _, key := range keys {
+		if !containsHostKey(pinned, key) {
+			unknown = append(unknown, key)
+		}
+	}
+	if len(pinned) > 0 && len(unknown) == 0 {
+		_, _ = fmt.Fprintf(out, "Host keys of %s are already pinned.\n", label)
+		return nil
+	}
+	if len(pinned) > 0 && !*replace {
+		return fmt.Errorf("HOST KEY CHANGED for %s (%s): the server presents %s but %s pinned; someone may be intercepting the connection. If the change is expected, run 'sshmanager trust --replace %s'",
+			label, resolved.DialAddress(), describeHostKeys(unknown), describeHostKeys(pinned), trustSelector(conn))
+	}
+
+	_, _ = fmt.Fprintf(out, "Host keys of %s (%s):\n", label, resolved.DialAddress())
+	for _, key := range keys {
+		_, _ = fmt.Fprintf(out, "  %s %s\n", model.HostKeyType(key), model.HostKeyFingerprint(key))
+	}
+	if !*yes {
+		confirmed, err := confirmTrust(label)
+		if err != nil {
+			return err
+		}
+		if !confirmed {
+			_, _ = fmt.Fprintln(out, prompttext.DefaultPromptTexts.SuccessMessages.OperationCancelled)
+			return nil
+		}
+	}
+
+	if err := connStore.Update(func(liveConnFile *model.ConnectionFile) error {
+		live := liveConnFile.GetConnectionByID(conn.ID)
+		if live == nil {
+			return errors.New(notFoundMessage(selectedAlias, selectedID))
+		}
+		live.HostKeys = keys
+		return nil
+	}); err != nil {
+		return err
+	}
+
+	_, _ = fmt.Fprintf(out, "Pinned %d host key(s) for %s; connections now fail if the server presents another key.\n", len(keys), label)
+	return nil
+}
+
+// captureHostKeysWithSSH runs ssh against an empty known_hosts file with
+// StrictHostKeyChecking=accept-new and returns the key ssh recorded. The key
+// is recorded before authentication, so BatchMode keeps ssh from prompting
+// for the target login; ProxyJump hops authenticate as usual.
+func captureHostKeysWithSSH(conn model.SSHConnection) ([]string, error) {
+	username := strings.TrimSpace(conn.Username)
+	host := strings.TrimSpace(conn.Host)
+	if username == "" || host == "" {
+		return nil, fmt.Errorf("username and host are required")
+	}
+	conn.HostKeys = nil
+	conn.LocalForwards = nil
+	conn.RemoteForwards = nil
+	conn.DynamicForwards = nil
+	advancedArgs, err := buildAdvancedSSHArgs(&conn)
+	if err != nil {
+		return nil, err
+	}
+
+	dir, err := os.MkdirTemp("", "sshmanager-trust-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+	knownHostsPath := filepath.Join(dir, "known_hosts")
+
+	binPath, err := exec.LookPath("ssh")
+	if err != nil {
+		return nil, fmt.Errorf("required command %q not found in PATH", "ssh")
+	}
+	sshArgs := []string{
+		"-p", strconv.Itoa(conn.EffectivePort()),
+		"-o", sshPathOption("UserKnownHostsFile", knownHostsPath),
+		"-o", "GlobalKnownHostsFile=" + os.DevNull,
+		"-o", "StrictHostKeyChecking=accept-new",
+		"-o", "UpdateHostKeys=no",
+		"-o", "BatchMode=yes",
+	}
+	sshArgs = append(sshArgs, advancedArgs...)
+	sshArgs = append(sshArgs, username+"@"+host, "exit")
+
+	var stderr bytes.Buffer
+	cmd := exec.Command(binPath, sshArgs...)
+	cmd.Stdin = os.Stdin
+	cmd.Stderr = &stderr
+	runErr := cmd.Run()
+
+	keys, err := readKnownHostsKeys(knownHostsPath)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	if len(keys) == 0 {
+		detail := strings.TrimSpace(stderr.String())
+		if detail == "" && runErr != nil {
+			detail = runErr.Error()
+		}
+		return nil, fmt.Errorf("ssh did not record a host key: %s", detail)
+	}
+	return keys, nil
+}
+
+// trustSelector is the argument that selects conn in a trust command hint.
+func trustSelector(conn *model.SSHConnection) string {
+	if alias := strings.TrimSpace(conn.Alias); alias != "" {
+		return alias
+	}
+	return "--id " + conn.ID
+}
+
+func containsHostKey(keys []string, key string) bool {
+	normalized, err := model.NormalizeHostKey(key)
+	if err != nil {
+		return false
+	}
+	for _, existing := range keys {
+		if candidate, err := model.NormalizeHostKey(existing); err == nil && candidate == normalized {
+			return true
+		}
+	}
+	return false
+}
+
+func describeHostKeys(keys []string) string {
+	fingerprints := make([]string, 0, len(keys))
+	for _, key := range keys {
+		fingerprints = append(fingerprints, model.HostKeyFingerprint(key))
+	}
+	return strings.Join(fingerprints, ", ")
+}
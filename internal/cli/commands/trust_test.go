@@ -0,0 +1,172 @@
+package commands
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/emirhangumus/sshmanager/internal/config"
+	"github.com/emirhangumus/sshmanager/internal/model"
+	"golang.org/x/crypto/ssh"
+)
+
+func generateTestHostKey(t *testing.T) string {
+	t.Helper()
+	pub, _, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKey failed: %v", err)
+	}
+	sshPub, err := ssh.NewPublicKey(pub)
+	if err != nil {
+		t.Fatalf("NewPublicKey failed: %v", err)
+	}
+	return strings.TrimSpace(string(ssh.MarshalAuthorizedKey(sshPub)))
+}
+
+func stubHostKeyScan(t *testing.T, keys ...string) {
+	t.Helper()
+	original := scanHostKeys
+	scanHostKeys = func(model.SSHConnection) ([]string, error) { return keys, nil }
+	t.Cleanup(func() { scanHostKeys = original })
+}
+
+func TestHandleTrustPinsAndDetectsChangedKeys(t *testing.T) {
+	connPath, keyPath := prepareTransferFixture(t, []model.SSHConnection{
+		{Username: "ubuntu", Host: "web.internal", AuthMode: model.AuthModeAgent, Alias: "web"},
+	})
+	first := generateTestHostKey(t)
+	second := generateTestHostKey(t)
+
+	stubHostKeyScan(t, first)
+	var out strings.Builder
+	if err := handleTrust(connPath, keyPath, []string{"--yes", "web"}, &out); err != nil {
+		t.Fatalf("handleTrust failed: %v", err)
+	}
+	if !strings.Contains(out.String(), model.HostKeyFingerprint(first)) || !strings.Contains(out.String(), "Pinned 1 host key(s)") {
+		t.Fatalf("unexpected trust output: %q", out.String())
+	}
+	connFile := loadTransferConnections(t, connPath, keyPath)
+	if conn := connFile.GetConnectionByAlias("web"); conn == nil || len(conn.HostKeys) != 1 || conn.HostKeys[0] != first {
+		t.Fatalf("expected the scanned key to be pinned, got %+v", conn)
+	}
+
+	out.Reset()
+	if err := handleTrust(connPath, keyPath, []string{"--yes", "web"}, &out); err != nil || !strings.Contains(out.String(), "already pinned") {
+		t.Fatalf("expected an unchanged key to be reported as pinned, got %q, %v", out.String(), err)
+	}
+
+	stubHostKeyScan(t, second)
+	err := handleTrust(connPath, keyPath, []string{"--yes", "web"}, ioDiscard())
+	if err == nil || !strings.Contains(err.Error(), "HOST KEY CHANGED") || !strings.Contains(err.Error(), "trust --replace web") {
+		t.Fatalf("expected a changed key to fail loudly, got %v", err)
+	}
+	if err := handleTrust(connPath, keyPath, []string{"--yes", "--replace", "web"}, ioDiscard()); err != nil {
+		t.Fatalf("handleTrust --replace failed: %v", err)
+	}
+	connFile = loadTransferConnections(t, connPath, keyPath)
+	if conn := connFile.GetConnectionByAlias("web"); conn == nil || len(conn.HostKeys) != 1 || conn.HostKeys[0] != second {
+		t.Fatalf("expected --replace to pin the new key, got %+v", conn)
+	}
+}
+
+func TestHandleTrustRequiresConnectBehindProxyJump(t *testing.T) {
+	connPath, keyPath := prepareTransferFixture(t, []model.SSHConnection{
+		{Username: "ubuntu", Host: "db.internal", AuthMode: model.AuthModeAgent, ProxyJump: "bastion", Alias: "db"},
+	})
+	key := generateTestHostKey(t)
+	stubHostKeyScan(t, key)
+
+	err := handleTrust(connPath, keyPath, []string{"--yes", "db"}, ioDiscard())
+	if err == nil || !strings.Contains(err.Error(), "trust --connect db") {
+		t.Fatalf("expected a ProxyJump hint, got %v", err)
+	}
+
+	original := captureHostKeysOnConnect
+	captureHostKeysOnConnect = func(conn model.SSHConnection) ([]string, error) {
+		if conn.ProxyJump != "bastion" {
+			t.Fatalf("expected the resolved connection, got %+v", conn)
+		}
+		return []string{key}, nil
+	}
+	t.Cleanup(func() { captureHostKeysOnConnect = original })
+
+	originalConfirm := confirmTrust
+	confirmTrust = func(string) (bool, error) { return false, nil }
+	t.Cleanup(func() { confirmTrust = originalConfirm })
+
+	var out strings.Builder
+	if err := handleTrust(connPath, keyPath, []string{"--connect", "db"}, &out); err != nil {
+		t.Fatalf("handleTrust --connect failed: %v", err)
+	}
+	connFile := loadTransferConnections(t, connPath, keyPath)
+	if conn := connFile.GetConnectionByAlias("db"); conn == nil || len(conn.HostKeys) != 0 {
+		t.Fatalf("expected a declined confirmation to pin nothing, got %+v", conn)
+	}
+}
+
+func TestBuildConnectInvocationPinsHostKeys(t *testing.T) {
+	dir := t.TempDir()
+
+	key := generateTestHostKey(t)
+	conn := &model.SSHConnection{
+		ID:           "c-1",
+		Username:     "ubuntu",
+		Host:         "Web.Internal",
+		Port:         2222,
+		AuthMode:     model.AuthModeAgent,
+		ExtraSSHArgs: []string{"-o", "StrictHostKeyChecking=no"},
+		HostKeys:     []string{key + " root@web"},
+	}
+
+	_, args, _, err := buildConnectInvocation(dir, conn)
+	if err != nil {
+		t.Fatalf("buildConnectInvocation failed: %v", err)
+	}
+	knownHostsPath := filepath.Join(dir, "known_hosts.d", "c-1")
+	assertStringSliceEqual(t, args, []string{
+		"-p", "2222",
+		"-o", "UserKnownHostsFile=" + knownHostsPath,
+		"-o", "GlobalKnownHostsFile=" + os.DevNull,
+		"-o", "StrictHostKeyChecking=yes",
+		"-o", "UpdateHostKeys=no",
+		"-o", "StrictHostKeyChecking=no",
+		"ubuntu@Web.Internal",
+	})
+
+	data, err := os.ReadFile(knownHostsPath)
+	if err != nil {
+		t.Fatalf("ReadFile(known_hosts) failed: %v", err)
+	}
+	if string(data) != "[web.internal]:2222 "+key+"\n" {
+		t.Fatalf("unexpected generated known_hosts %q", data)
+	}
+	if keys, err := readKnownHostsKeys(knownHostsPath); err != nil || len(keys) != 1 || keys[0] != key {
+		t.Fatalf("readKnownHostsKeys = %q, %v", keys, err)
+	}
+
+	if _, _, _, err := buildConnectInvocation("", conn); err == nil {
+		t.Fatal("expected pinned keys without a known_hosts directory to fail")
+	}
+}
+
+func TestHandleDoctorListsConnectionsWithoutPins(t *testing.T) {
+	connPath, keyPath := prepareTransferFixture(t, []model.SSHConnection{
+		{Username: "ubuntu", Host: "web.internal", AuthMode: model.AuthModeAgent, Alias: "web", HostKeys: []string{generateTestHostKey(t)}},
+		{Username: "ubuntu", Host: "db.internal", AuthMode: model.AuthModeAgent, Alias: "db"},
+	})
+	cfgPath := filepath.Join(t.TempDir(), "config.yaml")
+	if err := config.SaveConfig(cfgPath, config.Default()); err != nil {
+		t.Fatalf("SaveConfig failed: %v", err)
+	}
+
+	var out strings.Builder
+	if err := handleDoctor("", connPath, keyPath, cfgPath, nil, &out); err != nil {
+		t.Fatalf("handleDoctor returned error: %v", err)
+	}
+	if !strings.Contains(out.String(), "[WARN] host key pins: 1 of 2 connections have no pinned host keys") || !strings.Contains(out.String(), "): db") {
+		t.Fatalf("expected the unpinned connection in doctor output, got %q", out.String())
+	}
+}
@@ -0,0 +1,659 @@
+package commands
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	"github.com/emirhangumus/sshmanager/internal/config"
+	"github.com/emirhangumus/sshmanager/internal/model"
+	"github.com/emirhangumus/sshmanager/internal/nativessh"
+	"github.com/emirhangumus/sshmanager/internal/store"
+	"github.com/emirhangumus/sshmanager/internal/tunnel"
+	prompttext "github.com/emirhangumus/sshmanager/internal/ui/prompt"
+)
+
+// tunnelStatusDead marks a recorded tunnel whose supervisor is gone.
+const tunnelStatusDead = "dead"
+
+// tunnelStartTimeout bounds how long tunnel up waits for the first attempt.
+const tunnelStartTimeout = 20 * time.Second
+
+// tunnelSSHOptions keep a background ssh -N honest: it exits when a forward
+// cannot be set up or the server stops answering, so the supervisor notices
+// and restarts it.
+var tunnelSSHOptions = []string{
+	"-N",
+	"-o", "ExitOnForwardFailure=yes",
+	"-o", "ServerAliveInterval=15",
+	"-o", "ServerAliveCountMax=3",
+}
+
+// Tests replace these to avoid spawning and signalling real processes.
+var (
+	startTunnelSupervisor = tunnel.StartDetached
+	tunnelProcessAlive    = tunnel.ProcessAlive
+	terminateTunnel       = tunnel.Terminate
+)
+
+type tunnelStatusOutput struct {
+	model.TunnelState
+	Uptime string `json:"uptime,omitempty"`
+}
+
+type tunnelListOutput struct {
+	ID              string   `json:"id"`
+	Alias           string   `json:"alias,omitempty"`
+	LocalForwards   []string `json:"localForwards,omitempty"`
+	RemoteForwards  []string `json:"remoteForwards,omitempty"`
+	DynamicForwards []string `json:"dynamicForwards,omitempty"`
+	Status          string   `json:"status"`
+	PID             int      `json:"pid,omitempty"`
+}
+
+func HandleTunnel(connectionFilePath, secretKeyFilePath, configFilePath string, args []string) error {
+	return handleTunnel(connectionFilePath, secretKeyFilePath, configFilePath, args, os.Stdout)
+}
+
+func handleTunnel(connectionFilePath, secretKeyFilePath, configFilePath string, args []string, out io.Writer) error {
+	if len(args) == 0 {
+		return errors.New("missing tunnel subcommand: usage: sshmanager tunnel up|down|status|list")
+	}
+
+	stateStore := store.NewTunnelStore(store.TunnelStateFilePath(connectionFilePath))
+	switch strings.ToLower(strings.TrimSpace(args[0])) {
+	case "up":
+		return handleTunnelUp(connectionFilePath, secretKeyFilePath, configFilePath, stateStore, args[1:], out)
+	case "down":
+		return handleTunnelDown(connectionFilePath, secretKeyFilePath, stateStore, args[1:], out)
+	case "status":
+		return handleTunnelStatus(stateStore, args[1:], out)
+	case "list":
+		return handleTunnelList(connectionFilePath, secretKeyFilePath, stateStore, args[1:], out)
+	case "run":
+		return handleTunnelRun(args[1:], os.Stdin)
+	default:
+		return fmt.Errorf("unknown tunnel subcommand %q (use up, down, status or list)", args[0])
+	}
+}
+
+func handleTunnelUp(connectionFilePath, secretKeyFilePath, configFilePath string, stateStore *store.TunnelStore, args []string, out io.Writer) error {
+	fs := flag.NewFlagSet("tunnel up", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+
+	alias := fs.String("alias", "", "Connection alias")
+	id := fs.String("id", "", "Connection ID")
+	backend := fs.String("backend", "", "Connect backend: openssh or native (default: the configured one)")
+	foreground := fs.Bool("foreground", false, "Supervise the tunnel in this process")
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	selectedAlias, selectedID, err := resolveSelector(*alias, *id, fs.Args(), "tunnel up")
+	if err != nil {
+		return err
+	}
+	if selectedAlias == "" && selectedID == "" {
+		return errors.New("tunnel up: connection alias or --id is required")
+	}
+
+	cfg, err := config.LoadConfig(configFilePath)
+	if err != nil {
+		return err
+	}
+	selectedBackend := cfg.Connect.EffectiveBackend()
+	if strings.TrimSpace(*backend) != "" {
+		selectedBackend = strings.ToLower(strings.TrimSpace(*backend))
+		if selectedBackend != config.ConnectBackendOpenSSH && selectedBackend != config.ConnectBackendNative {
+			return fmt.Errorf("tunnel up: unsupported --backend %q (use %s or %s)", *backend, config.ConnectBackendOpenSSH, config.ConnectBackendNative)
+		}
+	}
+
+	connFile, err := store.NewConnectionStore(connectionFilePath, secretKeyFilePath).Load()
+	if err != nil {
+		return err
+	}
+	selected := findConnectionBySelector(&connFile, selectedAlias, selectedID)
+	if selected == nil {
+		return errors.New(notFoundMessage(selectedAlias, selectedID))
+	}
+	resolved, err := resolveConnections(&connFile, []model.SSHConnection{*selected})
+	if err != nil {
+		return err
+	}
+	conn := resolved[0]
+
+	state, err := tunnelStateFor(conn, selectedBackend)
+	if err != nil {
+		return err
+	}
+
+	// A stale entry of a supervisor that died is dropped so it neither
+	// blocks this start nor is mistaken for its outcome.
+	stateFile, err := stateStore.Load()
+	if err != nil {
+		return err
+	}
+	if existing := stateFile.Get(conn.ID); existing != nil {
+		if tunnelProcessAlive(existing.PID) {
+			return fmt.Errorf("tunnel for %s is already running (pid %d); stop it with 'sshmanager tunnel down %s'", connectionLabel(conn), existing.PID, trustSelector(&conn))
+		}
+		if err := stateStore.Update(func(file *model.TunnelStateFile) error {
+			file.Remove(conn.ID)
+			return nil
+		}); err != nil {
+			return err
+		}
+		stateFile.Remove(conn.ID)
+	}
+	if err := checkLocalPortConflicts(&stateFile, state.LocalListen); err != nil {
+		return err
+	}
+
+	spec, err := tunnelSpecFor(filepath.Dir(connectionFilePath), conn, state)
+	if err != nil {
+		return err
+	}
+
+	if *foreground {
+		runner, err := spec.Runner()
+		if err != nil {
+			return err
+		}
+		_, _ = fmt.Fprintf(out, "Supervising tunnel for %s (%s); press Ctrl+C to stop.\n", connectionLabel(conn), describeTunnelListeners(state))
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+		supervisor := &tunnel.Supervisor{Runner: runner, Store: stateStore, State: spec.State, FailFast: true}
+		return supervisor.Run(ctx)
+	}
+
+	executable, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("failed to locate sshmanager executable: %w", err)
+	}
+	runArgs := []string{"tunnel", "run", "--state", stateStore.Path()}
+	if err := startTunnelSupervisor(executable, runArgs, spec); err != nil {
+		return err
+	}
+
+	started, err := waitForTunnel(stateStore, conn.ID, tunnelStartTimeout)
+	if err != nil {
+		return fmt.Errorf("tunnel for %s failed to start: %w", connectionLabel(conn), err)
+	}
+	if started.Status != model.TunnelStatusUp {
+		_, _ = fmt.Fprintf(out, "Tunnel for %s is still starting (pid %d); check 'sshmanager tunnel status'.\n", connectionLabel(conn), started.PID)
+		return nil
+	}
+	_, _ = fmt.Fprintf(out, "Tunnel for %s is up (pid %d): %s.\n", connectionLabel(conn), started.PID, describeTunnelListeners(*started))
+	return nil
+}
+
+// tunnelStateFor returns the initial state of a tunnel for conn, listing
+// the addresses its forwards listen on.
+func tunnelStateFor(conn model.SSHConnection, backend string) (model.TunnelState, error) {
+	localForwards := model.NormalizeStringList(conn.LocalForwards)
+	remoteForwards := model.NormalizeStringList(conn.RemoteForwards)
+	dynamicForwards := model.NormalizeStringList(conn.DynamicForwards)
+	if len(localForwards) == 0 && len(remoteForwards) == 0 && len(dynamicForwards) == 0 {
+		return model.TunnelState{}, fmt.Errorf("%s has no forwards to keep open", connectionLabel(conn))
+	}
+	if err := model.ValidateForwardSpecs(localForwards); err != nil {
+		return model.TunnelState{}, fmt.Errorf("invalid local forwards: %w", err)
+	}
+	if err := model.ValidateForwardSpecs(remoteForwards); err != nil {
+		return model.TunnelState{}, fmt.Errorf("invalid remote forwards: %w", err)
+	}
+	if err := model.ValidateDynamicForwardSpecs(dynamicForwards); err != nil {
+		return model.TunnelState{}, fmt.Errorf("invalid dynamic forwards: %w", err)
+	}
+
+	state := model.TunnelState{
+		ConnectionID: conn.ID,
+		Alias:        strings.TrimSpace(conn.Alias),
+		Backend:      backend,
+	}
+	for _, spec := range localForwards {
+		listen, err := nativessh.LocalListenAddress(spec)
+		if err != nil {
+			return model.TunnelState{}, fmt.Errorf("invalid local forward %q: %w", spec, err)
+		}
+		state.LocalListen = append(state.LocalListen, listen)
+	}
+	for _, spec := range remoteForwards {
+		listen, _, err := model.SplitForwardSpec(spec)
+		if err != nil {
+			return model.TunnelState{}, fmt.Errorf("invalid remote forward %q: %w", spec, err)
+		}
+		state.RemoteListen = append(state.RemoteListen, listen)
+	}
+	for _, spec := range dynamicForwards {
+		listen, err := nativessh.DynamicListenAddress(spec)
+		if err != nil {
+			return model.TunnelState{}, fmt.Errorf("invalid dynamic forward %q: %w", spec, err)
+		}
+		state.LocalListen = append(state.LocalListen, listen)
+	}
+	return state, nil
+}
+
+// tunnelSpecFor builds what the supervisor runs for state.Backend.
+func tunnelSpecFor(vaultDir string, conn model.SSHConnection, state model.TunnelState) (tunnel.Spec, error) {
+	spec := tunnel.Spec{State: state}
+	if state.Backend == config.ConnectBackendNative {
+		if extraArgs := model.NormalizeStringList(conn.ExtraSSHArgs); len(extraArgs) > 0 {
+			return spec, fmt.Errorf("%s has extra ssh args, which the native backend cannot honour; use --backend %s", connectionLabel(conn), config.ConnectBackendOpenSSH)
+		}
+		if dynamicForwards := model.NormalizeStringList(conn.DynamicForwards); len(dynamicForwards) > 0 {
+			return spec, fmt.Errorf("%s has dynamic forwards, which the native backend cannot honour; use --backend %s", connectionLabel(conn), config.ConnectBackendOpenSSH)
+		}
+		if conn.EffectiveAuthMode() == model.AuthModePassword {
+			password, err := resolveConnectionPassword(vaultDir, &conn)
+			if err != nil {
+				return spec, err
+			}
+			spec.Password = password
+		}
+		spec.Connection = &conn
+		spec.KnownHostsFile = nativeKnownHostsFile(vaultDir)
+		return spec, nil
+	}
+
+	bin, args, envAdd, err := buildConnectInvocation(vaultDir, &conn)
+	if err != nil {
+		return spec, err
+	}
+	binPath, err := exec.LookPath(bin)
+	if err != nil {
+		if bin == "sshpass" {
+			return spec, errors.New(prompttext.DefaultPromptTexts.ErrorMessages.SSHPassNotFound)
+		}
+		return spec, fmt.Errorf("required command %q not found in PATH", bin)
+	}
+
+	// The target is always the last argument; the tunnel options go
+	// right before it.
+	target := args[len(args)-1]
+	command := append([]string{binPath}, args[:len(args)-1]...)
+	command = append(command, tunnelSSHOptions...)
+	spec.Command = append(command, target)
+	spec.Env = envAdd
+	return spec, nil
+}
+
+// checkLocalPortConflicts fails when a local forward address is taken,
+// naming the tunnel that holds it when it is one of ours.
+func checkLocalPortConflicts(stateFile *model.TunnelStateFile, addrs []string) error {
+	for i, addr := range addrs {
+		network := nativessh.ListenNetwork(addr)
+		kind := "port"
+		if network == "unix" {
+			kind = "socket"
+		}
+		for _, earlier := range addrs[:i] {
+			if strings.EqualFold(earlier, addr) {
+				return fmt.Errorf("local %s %s is forwarded twice", kind, addr)
+			}
+		}
+		if owner := stateFile.FindLocalListener(addr); owner != nil && tunnelProcessAlive(owner.PID) {
+			return fmt.Errorf("local %s %s is already used by the tunnel of %s", kind, addr, tunnelStateLabel(*owner))
+		}
+		listener, err := net.Listen(network, addr)
+		if err != nil {
+			return fmt.Errorf("local %s %s is already in use: %w", kind, addr, err)
+		}
+		_ = listener.Close()
+	}
+	return nil
+}
+
+// waitForTunnel polls the state file until the supervisor of connectionID
+// reports up or failed, or timeout passes. A failed start is removed from
+// the state file.
+func waitForTunnel(stateStore *store.TunnelStore, connectionID string, timeout time.Duration) (*model.TunnelState, error) {
+	deadline := time.Now().Add(timeout)
+	var last *model.TunnelState
+	for {
+		stateFile, err := stateStore.Load()
+		if err != nil {
+			return nil, err
+		}
+		if current := stateFile.Get(connectionID); current != nil {
+			last = current
+			switch {
+			case current.Status == model.TunnelStatusUp:
+				return current, nil
+			case current.Status == model.TunnelStatusFailed || !tunnelProcessAlive(current.PID):
+				_ = stateStore.Update(func(file *model.TunnelStateFile) error {
+					file.Remove(connectionID)
+					return nil
+				})
+				return nil, errors.New(dashIfEmpty(current.LastError))
+			}
+		}
+		if time.Now().After(deadline) {
+			if last == nil {
+				return nil, errors.New("the supervisor did not report in")
+			}
+			return last, nil
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+}
+
+// handleTunnelRun is the detached supervisor started by tunnel up. It reads
+// its spec from stdin.
+func handleTunnelRun(args []string, in io.Reader) error {
+	fs := flag.NewFlagSet("tunnel run", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+
+	statePath := fs.String("state", "", "Tunnel state file path")
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if strings.TrimSpace(*statePath) == "" {
+		return errors.New("missing required --state")
+	}
+
+	spec, err := tunnel.ReadSpec(in)
+	if err != nil {
+		return err
+	}
+	runner, err := spec.Runner()
+	if err != nil {
+		return err
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	supervisor := &tunnel.Supervisor{
+		Runner:   runner,
+		Store:    store.NewTunnelStore(*statePath),
+		State:    spec.State,
+		FailFast: true,
+	}
+	return supervisor.Run(ctx)
+}
+
+func handleTunnelDown(connectionFilePath, secretKeyFilePath string, stateStore *store.TunnelStore, args []string, out io.Writer) error {
+	fs := flag.NewFlagSet("tunnel down", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+
+	alias := fs.String("alias", "", "Connection alias")
+	id := fs.String("id", "", "Connection ID")
+	all := fs.Bool("all", false, "Stop every tunnel")
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	selectedAlias, selectedID, err := resolveSelector(*alias, *id, fs.Args(), "tunnel down")
+	if err != nil {
+		return err
+	}
+	if *all && (selectedAlias != "" || selectedID != "") {
+		return errors.New("tunnel down: use either a connection selector or --all, not both")
+	}
+	if !*all && selectedAlias == "" && selectedID == "" {
+		return errors.New("tunnel down: connection alias, --id or --all is required")
+	}
+
+	stateFile, err := stateStore.Load()
+	if err != nil {
+		return err
+	}
+	var connectionIDs []string
+	if *all {
+		for _, state := range stateFile.Tunnels {
+			connectionIDs = append(connectionIDs, state.ConnectionID)
+		}
+	} else {
+		connectionID, err := tunnelConnectionID(connectionFilePath, secretKeyFilePath, &stateFile, selectedAlias, selectedID)
+		if err != nil {
+			return err
+		}
+		if stateFile.Get(connectionID) == nil {
+			_, _ = fmt.Fprintf(out, "No tunnel running for %s.\n", tunnelName(selectedAlias, selectedID))
+			return nil
+		}
+		connectionIDs = []string{connectionID}
+	}
+	if len(connectionIDs) == 0 {
+		_, _ = fmt.Fprintln(out, "No tunnels running.")
+		return nil
+	}
+
+	// Removing the entries first tells the supervisors not to restart
+	// anything while they are being stopped.
+	var stopped []model.TunnelState
+	if err := stateStore.Update(func(file *model.TunnelStateFile) error {
+		for _, connectionID := range connectionIDs {
+			if state := file.Get(connectionID); state != nil {
+				stopped = append(stopped, *state)
+				file.Remove(connectionID)
+			}
+		}
+		return nil
+	}); err != nil {
+		return err
+	}
+
+	var errs []error
+	for _, state := range stopped {
+		for _, pid := range []int{state.PID, state.SSHPID} {
+			if tunnelProcessAlive(pid) {
+				if err := terminateTunnel(pid); err != nil {
+					errs = append(errs, fmt.Errorf("failed to stop process %d of %s: %w", pid, tunnelStateLabel(state), err))
+				}
+			}
+		}
+		_, _ = fmt.Fprintf(out, "Stopped tunnel for %s.\n", tunnelStateLabel(state))
+	}
+	return errors.Join(errs...)
+}
+
+// tunnelConnectionID maps a selector to a connection ID. Recorded aliases
+// are tried first so stopping a tunnel does not need the vault key; a
+// connection renamed since tunnel up is found through the connection file.
+func tunnelConnectionID(connectionFilePath, secretKeyFilePath string, stateFile *model.TunnelStateFile, alias, id string) (string, error) {
+	if id != "" {
+		return id, nil
+	}
+	for _, state := range stateFile.Tunnels {
+		if strings.EqualFold(state.Alias, alias) {
+			return state.ConnectionID, nil
+		}
+	}
+	connFile, err := store.NewConnectionStore(connectionFilePath, secretKeyFilePath).Load()
+	if err != nil {
+		return "", err
+	}
+	conn := findConnectionBySelector(&connFile, alias, "")
+	if conn == nil {
+		return "", errors.New(notFoundMessage(alias, ""))
+	}
+	return conn.ID, nil
+}
+
+func handleTunnelStatus(stateStore *store.TunnelStore, args []string, out io.Writer) error {
+	fs := flag.NewFlagSet("tunnel status", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+
+	alias := fs.String("alias", "", "Connection alias")
+	id := fs.String("id", "", "Connection ID")
+	jsonOutput := fs.Bool("json", false, "Output JSON")
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	selectedAlias, selectedID, err := resolveSelector(*alias, *id, fs.Args(), "tunnel status")
+	if err != nil {
+		return err
+	}
+
+	stateFile, err := stateStore.Load()
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	outputs := make([]tunnelStatusOutput, 0, len(stateFile.Tunnels))
+	for _, state := range stateFile.Tunnels {
+		if selectedID != "" && state.ConnectionID != selectedID {
+			continue
+		}
+		if selectedAlias != "" && !strings.EqualFold(state.Alias, selectedAlias) {
+			continue
+		}
+		output := tunnelStatusOutput{TunnelState: state}
+		if state.Status != model.TunnelStatusFailed && !tunnelProcessAlive(state.PID) {
+			output.Status = tunnelStatusDead
+		}
+		if output.Status == model.TunnelStatusUp && !state.ConnectedAt.IsZero() {
+			output.Uptime = now.Sub(state.ConnectedAt).Round(time.Second).String()
+		}
+		outputs = append(outputs, output)
+	}
+
+	if *jsonOutput {
+		enc := json.NewEncoder(out)
+		enc.SetIndent("", "  ")
+		return enc.Encode(outputs)
+	}
+	if len(outputs) == 0 {
+		if selectedAlias != "" || selectedID != "" {
+			_, _ = fmt.Fprintf(out, "No tunnel running for %s.\n", tunnelName(selectedAlias, selectedID))
+			return nil
+		}
+		_, _ = fmt.Fprintln(out, "No tunnels running.")
+		return nil
+	}
+
+	tw := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(tw, "ALIAS\tSTATUS\tBACKEND\tPID\tLOCAL\tREMOTE\tUPTIME\tRESTARTS\tLAST ERROR")
+	for _, output := range outputs {
+		pid := "-"
+		if output.PID > 0 {
+			pid = fmt.Sprintf("%d", output.PID)
+		}
+		_, _ = fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%d\t%s\n",
+			tunnelName(output.Alias, output.ConnectionID),
+			output.Status,
+			output.Backend,
+			pid,
+			dashIfEmpty(strings.Join(output.LocalListen, ",")),
+			dashIfEmpty(strings.Join(output.RemoteListen, ",")),
+			dashIfEmpty(output.Uptime),
+			output.Restarts,
+			dashIfEmpty(output.LastError),
+		)
+	}
+	return tw.Flush()
+}
+
+func handleTunnelList(connectionFilePath, secretKeyFilePath string, stateStore *store.TunnelStore, args []string, out io.Writer) error {
+	fs := flag.NewFlagSet("tunnel list", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+
+	jsonOutput := fs.Bool("json", false, "Output JSON")
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() > 0 {
+		return fmt.Errorf("unexpected arguments for tunnel list: %s", strings.Join(fs.Args(), " "))
+	}
+
+	connFile, err := store.NewConnectionStore(connectionFilePath, secretKeyFilePath).Load()
+	if err != nil {
+		return err
+	}
+	resolved, err := resolveConnections(&connFile, connFile.Connections)
+	if err != nil {
+		return err
+	}
+	stateFile, err := stateStore.Load()
+	if err != nil {
+		return err
+	}
+
+	outputs := make([]tunnelListOutput, 0)
+	for _, conn := range resolved {
+		output := tunnelListOutput{
+			ID:              conn.ID,
+			Alias:           strings.TrimSpace(conn.Alias),
+			LocalForwards:   model.NormalizeStringList(conn.LocalForwards),
+			RemoteForwards:  model.NormalizeStringList(conn.RemoteForwards),
+			DynamicForwards: model.NormalizeStringList(conn.DynamicForwards),
+			Status:          "down",
+		}
+		if len(output.LocalForwards) == 0 && len(output.RemoteForwards) == 0 && len(output.DynamicForwards) == 0 {
+			continue
+		}
+		if state := stateFile.Get(conn.ID); state != nil {
+			output.Status = state.Status
+			output.PID = state.PID
+			if state.Status != model.TunnelStatusFailed && !tunnelProcessAlive(state.PID) {
+				output.Status = tunnelStatusDead
+			}
+		}
+		outputs = append(outputs, output)
+	}
+
+	if *jsonOutput {
+		enc := json.NewEncoder(out)
+		enc.SetIndent("", "  ")
+		return enc.Encode(outputs)
+	}
+	if len(outputs) == 0 {
+		_, _ = fmt.Fprintln(out, "No connections have forwards.")
+		return nil
+	}
+
+	tw := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(tw, "ALIAS\tSTATUS\tLOCAL FORWARDS\tREMOTE FORWARDS\tDYNAMIC FORWARDS")
+	for _, output := range outputs {
+		_, _ = fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n",
+			tunnelName(output.Alias, output.ID),
+			output.Status,
+			dashIfEmpty(strings.Join(output.LocalForwards, ",")),
+			dashIfEmpty(strings.Join(output.RemoteForwards, ",")),
+			dashIfEmpty(strings.Join(output.DynamicForwards, ",")),
+		)
+	}
+	return tw.Flush()
+}
+
+func tunnelStateLabel(state model.TunnelState) string {
+	return connectionLabel(model.SSHConnection{ID: state.ConnectionID, Alias: state.Alias})
+}
+
+func describeTunnelListeners(state model.TunnelState) string {
+	var parts []string
+	if len(state.LocalListen) > 0 {
+		parts = append(parts, "local "+strings.Join(state.LocalListen, ", "))
+	}
+	if len(state.RemoteListen) > 0 {
+		parts = append(parts, "remote "+strings.Join(state.RemoteListen, ", "))
+	}
+	return strings.Join(parts, "; ")
+}
+
+// tunnelName is how tables and messages refer to a tunnel: its alias, or
+// the connection ID when there is none.
+func tunnelName(alias, id string) string {
+	if alias = strings.TrimSpace(alias); alias != "" {
+		return alias
+	}
+	return id
+}
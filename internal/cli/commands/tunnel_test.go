@@ -0,0 +1,290 @@
+package commands
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/emirhangumus/sshmanager/internal/model"
+	"github.com/emirhangumus/sshmanager/internal/store"
+	"github.com/emirhangumus/sshmanager/internal/tunnel"
+)
+
+// stubTunnelProcesses treats the PIDs in alive as running and records the
+// PIDs tunnel down terminates.
+func stubTunnelProcesses(t *testing.T, alive ...int) *[]int {
+	t.Helper()
+	var terminated []int
+	oldAlive, oldTerminate, oldStart := tunnelProcessAlive, terminateTunnel, startTunnelSupervisor
+	tunnelProcessAlive = func(pid int) bool { return slices.Contains(alive, pid) }
+	terminateTunnel = func(pid int) error {
+		terminated = append(terminated, pid)
+		return nil
+	}
+	startTunnelSupervisor = func(string, []string, tunnel.Spec) error {
+		t.Fatal("unexpected supervisor start")
+		return nil
+	}
+	t.Cleanup(func() {
+		tunnelProcessAlive, terminateTunnel, startTunnelSupervisor = oldAlive, oldTerminate, oldStart
+	})
+	return &terminated
+}
+
+func seedTunnelState(t *testing.T, connPath string, states ...model.TunnelState) *store.TunnelStore {
+	t.Helper()
+	stateStore := store.NewTunnelStore(store.TunnelStateFilePath(connPath))
+	if err := stateStore.Update(func(file *model.TunnelStateFile) error {
+		for _, state := range states {
+			file.Put(state)
+		}
+		return nil
+	}); err != nil {
+		t.Fatalf("seed tunnel state: %v", err)
+	}
+	return stateStore
+}
+
+func freeLocalPort(t *testing.T) int {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	defer listener.Close()
+	return listener.Addr().(*net.TCPAddr).Port
+}
+
+func TestTunnelUpRequiresForwards(t *testing.T) {
+	stubTunnelProcesses(t)
+	connPath, keyPath := prepareTransferFixture(t, []model.SSHConnection{
+		{Username: "u", Host: "h", AuthMode: model.AuthModeAgent, Alias: "plain"},
+	})
+
+	err := handleTunnel(connPath, keyPath, filepath.Join(t.TempDir(), "config.yaml"), []string{"up", "plain"}, ioDiscard())
+	if err == nil || !strings.Contains(err.Error(), "has no forwards to keep open") {
+		t.Fatalf("expected a missing forwards error, got %v", err)
+	}
+}
+
+func TestTunnelUpDetectsLocalPortConflicts(t *testing.T) {
+	stubTunnelProcesses(t, 500)
+
+	busy, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	defer busy.Close()
+	busyPort := busy.Addr().(*net.TCPAddr).Port
+	ownedPort := freeLocalPort(t)
+
+	connPath, keyPath := prepareTransferFixture(t, []model.SSHConnection{
+		{Username: "u", Host: "h", AuthMode: model.AuthModeAgent, Alias: "web", LocalForwards: []string{fmt.Sprintf("127.0.0.1:%d:localhost:80", busyPort)}},
+		{Username: "u", Host: "h", AuthMode: model.AuthModeAgent, Alias: "api", LocalForwards: []string{fmt.Sprintf("%d:localhost:80", ownedPort)}},
+	})
+	seedTunnelState(t, connPath, model.TunnelState{
+		ConnectionID: "other",
+		Alias:        "db",
+		PID:          500,
+		LocalListen:  []string{fmt.Sprintf("localhost:%d", ownedPort)},
+		Status:       model.TunnelStatusUp,
+	})
+	configPath := filepath.Join(t.TempDir(), "config.yaml")
+
+	err = handleTunnel(connPath, keyPath, configPath, []string{"up", "web"}, ioDiscard())
+	if err == nil || !strings.Contains(err.Error(), fmt.Sprintf("local port 127.0.0.1:%d is already in use", busyPort)) {
+		t.Fatalf("expected a port in use error, got %v", err)
+	}
+
+	err = handleTunnel(connPath, keyPath, configPath, []string{"up", "api"}, ioDiscard())
+	if err == nil || !strings.Contains(err.Error(), fmt.Sprintf(`local port localhost:%d is already used by the tunnel of connection "db"`, ownedPort)) {
+		t.Fatalf("expected the owning tunnel to be named, got %v", err)
+	}
+}
+
+func TestTunnelUpDetectsLocalSocketConflicts(t *testing.T) {
+	stubTunnelProcesses(t)
+
+	socketPath := filepath.Join(t.TempDir(), "web.sock")
+	if err := os.WriteFile(socketPath, nil, 0o600); err != nil {
+		t.Fatalf("failed to create socket placeholder: %v", err)
+	}
+	connPath, keyPath := prepareTransferFixture(t, []model.SSHConnection{
+		{Username: "u", Host: "h", AuthMode: model.AuthModeAgent, Alias: "web", LocalForwards: []string{socketPath + ":localhost:80"}},
+	})
+
+	err := handleTunnel(connPath, keyPath, filepath.Join(t.TempDir(), "config.yaml"), []string{"up", "web"}, ioDiscard())
+	if err == nil || !strings.Contains(err.Error(), fmt.Sprintf("local socket %s is already in use", socketPath)) {
+		t.Fatalf("expected a socket in use error, got %v", err)
+	}
+}
+
+func TestTunnelUpStartsSupervisor(t *testing.T) {
+	if _, err := exec.LookPath("ssh"); err != nil {
+		t.Skip("ssh not available")
+	}
+	stubTunnelProcesses(t, 4242)
+	localPort := freeLocalPort(t)
+	connPath, keyPath := prepareTransferFixture(t, []model.SSHConnection{
+		{Username: "u", Host: "h", AuthMode: model.AuthModeAgent, Alias: "web", LocalForwards: []string{fmt.Sprintf("%d:localhost:80", localPort)}, RemoteForwards: []string{"9000:localhost:3000"}},
+	})
+	connFile := loadTransferConnections(t, connPath, keyPath)
+	conn := connFile.GetConnectionByAlias("web")
+	// A stale entry from a supervisor that died must not block the start.
+	stateStore := seedTunnelState(t, connPath, model.TunnelState{ConnectionID: conn.ID, Alias: "web", PID: 1, Status: model.TunnelStatusUp})
+
+	var gotArgs []string
+	var gotSpec tunnel.Spec
+	startTunnelSupervisor = func(executable string, args []string, spec tunnel.Spec) error {
+		gotArgs, gotSpec = args, spec
+		state := spec.State
+		state.PID = 4242
+		state.Status = model.TunnelStatusUp
+		return stateStore.Update(func(file *model.TunnelStateFile) error {
+			file.Put(state)
+			return nil
+		})
+	}
+
+	var out bytes.Buffer
+	if err := handleTunnel(connPath, keyPath, filepath.Join(t.TempDir(), "config.yaml"), []string{"up", "web"}, &out); err != nil {
+		t.Fatalf("tunnel up failed: %v", err)
+	}
+
+	assertStringSliceEqual(t, gotArgs, []string{"tunnel", "run", "--state", stateStore.Path()})
+	command := gotSpec.Command
+	if len(command) == 0 || filepath.Base(command[0]) != "ssh" || command[len(command)-1] != "u@h" {
+		t.Fatalf("unexpected tunnel command %v", command)
+	}
+	joined := strings.Join(command, " ")
+	for _, want := range []string{"-N", "-o ExitOnForwardFailure=yes", fmt.Sprintf("-L %d:localhost:80", localPort), "-R 9000:localhost:3000"} {
+		if !strings.Contains(joined, want) {
+			t.Fatalf("expected %q in tunnel command %v", want, command)
+		}
+	}
+	assertStringSliceEqual(t, gotSpec.State.LocalListen, []string{fmt.Sprintf("localhost:%d", localPort)})
+	assertStringSliceEqual(t, gotSpec.State.RemoteListen, []string{"9000"})
+	if !strings.Contains(out.String(), `Tunnel for connection "web" is up (pid 4242)`) {
+		t.Fatalf("unexpected output %q", out.String())
+	}
+
+	err := handleTunnel(connPath, keyPath, filepath.Join(t.TempDir(), "config.yaml"), []string{"up", "web"}, ioDiscard())
+	if err == nil || !strings.Contains(err.Error(), "already running (pid 4242)") {
+		t.Fatalf("expected an already running error, got %v", err)
+	}
+}
+
+func TestTunnelStatusJSONAndDown(t *testing.T) {
+	terminated := stubTunnelProcesses(t, 100, 101, 300)
+	connPath, keyPath := prepareTransferFixture(t, []model.SSHConnection{
+		{Username: "u", Host: "h", AuthMode: model.AuthModeAgent, Alias: "web"},
+	})
+	connectedAt := time.Now().Add(-90 * time.Second)
+	stateStore := seedTunnelState(t, connPath,
+		model.TunnelState{ConnectionID: "c-1", Alias: "web", Backend: "openssh", PID: 100, SSHPID: 101, LocalListen: []string{"localhost:8080"}, Status: model.TunnelStatusUp, ConnectedAt: connectedAt, Restarts: 2},
+		model.TunnelState{ConnectionID: "c-2", Alias: "db", Backend: "native", PID: 200, Status: model.TunnelStatusRestarting, LastError: "connection refused"},
+		model.TunnelState{ConnectionID: "c-3", Alias: "cache", Backend: "openssh", PID: 300, Status: model.TunnelStatusUp},
+	)
+
+	var out bytes.Buffer
+	if err := handleTunnel(connPath, keyPath, "", []string{"status", "--json"}, &out); err != nil {
+		t.Fatalf("tunnel status failed: %v", err)
+	}
+	var statuses []map[string]any
+	if err := json.Unmarshal(out.Bytes(), &statuses); err != nil {
+		t.Fatalf("invalid status JSON: %v\n%s", err, out.String())
+	}
+	if len(statuses) != 3 {
+		t.Fatalf("expected 3 tunnels, got %v", statuses)
+	}
+	if statuses[0]["status"] != "up" || statuses[0]["uptime"] != "1m30s" || statuses[0]["restarts"] != float64(2) || statuses[0]["sshPid"] != float64(101) {
+		t.Fatalf("unexpected web status %v", statuses[0])
+	}
+	if statuses[1]["status"] != tunnelStatusDead || statuses[1]["lastError"] != "connection refused" {
+		t.Fatalf("expected db to be reported dead, got %v", statuses[1])
+	}
+	if _, ok := statuses[1]["connectedAt"]; ok {
+		t.Fatalf("expected no connectedAt for a tunnel that never came up, got %v", statuses[1])
+	}
+
+	out.Reset()
+	if err := handleTunnel(connPath, keyPath, "", []string{"down", "web"}, &out); err != nil {
+		t.Fatalf("tunnel down failed: %v", err)
+	}
+	assertIntSliceEqual(t, *terminated, []int{100, 101})
+	if !strings.Contains(out.String(), `Stopped tunnel for connection "web".`) {
+		t.Fatalf("unexpected output %q", out.String())
+	}
+
+	out.Reset()
+	if err := handleTunnel(connPath, keyPath, "", []string{"down", "web"}, &out); err != nil {
+		t.Fatalf("repeated tunnel down failed: %v", err)
+	}
+	if !strings.Contains(out.String(), "No tunnel running for web.") {
+		t.Fatalf("unexpected output %q", out.String())
+	}
+
+	if err := handleTunnel(connPath, keyPath, "", []string{"down", "--all"}, ioDiscard()); err != nil {
+		t.Fatalf("tunnel down --all failed: %v", err)
+	}
+	assertIntSliceEqual(t, *terminated, []int{100, 101, 300})
+	stateFile, err := stateStore.Load()
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if len(stateFile.Tunnels) != 0 {
+		t.Fatalf("expected every tunnel to be removed, got %+v", stateFile.Tunnels)
+	}
+}
+
+func TestTunnelListShowsConnectionsWithForwards(t *testing.T) {
+	stubTunnelProcesses(t, 100)
+	connPath, keyPath := prepareTransferFixture(t, []model.SSHConnection{
+		{Username: "u", Host: "h", AuthMode: model.AuthModeAgent, Alias: "web", LocalForwards: []string{"8080:localhost:80"}},
+		{Username: "u", Host: "h", AuthMode: model.AuthModeAgent, Alias: "db", RemoteForwards: []string{"9000:localhost:3000"}},
+		{Username: "u", Host: "h", AuthMode: model.AuthModeAgent, Alias: "socks", DynamicForwards: []string{"1080"}},
+		{Username: "u", Host: "h", AuthMode: model.AuthModeAgent, Alias: "plain"},
+	})
+	connFile := loadTransferConnections(t, connPath, keyPath)
+	web := connFile.GetConnectionByAlias("web")
+	seedTunnelState(t, connPath, model.TunnelState{ConnectionID: web.ID, Alias: "web", PID: 100, Status: model.TunnelStatusUp})
+
+	var out bytes.Buffer
+	if err := handleTunnel(connPath, keyPath, "", []string{"list", "--json"}, &out); err != nil {
+		t.Fatalf("tunnel list failed: %v", err)
+	}
+	var listed []tunnelListOutput
+	if err := json.Unmarshal(out.Bytes(), &listed); err != nil {
+		t.Fatalf("invalid list JSON: %v", err)
+	}
+	if len(listed) != 3 || listed[0].Alias != "web" || listed[0].Status != "up" || listed[0].PID != 100 || listed[1].Alias != "db" || listed[1].Status != "down" {
+		t.Fatalf("unexpected tunnel list %+v", listed)
+	}
+	assertStringSliceEqual(t, listed[2].DynamicForwards, []string{"1080"})
+}
+
+func TestTunnelStateForListsDynamicForwards(t *testing.T) {
+	state, err := tunnelStateFor(model.SSHConnection{
+		ID:              "c-1",
+		Alias:           "socks",
+		LocalForwards:   []string{"8080:localhost:80"},
+		DynamicForwards: []string{"1080", "*:1081", "[::1]:1082"},
+	}, "openssh")
+	if err != nil {
+		t.Fatalf("tunnelStateFor failed: %v", err)
+	}
+	assertStringSliceEqual(t, state.LocalListen, []string{"localhost:8080", "localhost:1080", ":1081", "[::1]:1082"})
+
+	_, err = tunnelSpecFor("", model.SSHConnection{Alias: "socks", DynamicForwards: []string{"1080"}}, model.TunnelState{Backend: "native"})
+	if err == nil || !strings.Contains(err.Error(), "has dynamic forwards") {
+		t.Fatalf("expected the native backend to refuse dynamic forwards, got %v", err)
+	}
+}
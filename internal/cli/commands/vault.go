@@ -0,0 +1,337 @@
+package commands
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/user"
+	"strings"
+
+	cryptoutil "github.com/emirhangumus/sshmanager/internal/crypto"
+	"github.com/emirhangumus/sshmanager/internal/store"
+	prompttext "github.com/emirhangumus/sshmanager/internal/ui/prompt"
+	"github.com/emirhangumus/sshmanager/internal/vault"
+)
+
+// confirmVaultRemoval asks before a vault is deleted. Tests replace it.
+var confirmVaultRemoval = func(name string) (bool, error) {
+	value, err := prompttext.InputPrompt(
+		fmt.Sprintf("Remove vault %q with all its connections and its key? Type 'yes' to continue", name),
+		"",
+		false,
+		nil,
+	)
+	if err != nil {
+		if prompttext.IsCancelError(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	return strings.EqualFold(strings.TrimSpace(value), "yes"), nil
+}
+
+// HandleVault manages vaults. vaultFlag is the global --vault value; it
+// selects the vault for "vault member".
+func HandleVault(homeDir, vaultFlag string, args []string) error {
+	return handleVault(homeDir, vaultFlag, args, os.Stdout)
+}
+
+func handleVault(homeDir, vaultFlag string, args []string, out io.Writer) error {
+	if len(args) == 0 {
+		return errors.New("missing vault subcommand: usage: sshmanager vault list|create|remove|default|member")
+	}
+
+	switch strings.ToLower(strings.TrimSpace(args[0])) {
+	case "list":
+		return handleVaultList(homeDir, args[1:], out)
+	case "create":
+		return handleVaultCreate(homeDir, args[1:], out)
+	case "remove":
+		return handleVaultRemove(homeDir, args[1:], out)
+	case "default":
+		return handleVaultDefault(homeDir, args[1:], out)
+	case "member":
+		paths, err := vault.Resolve(homeDir, vaultFlag)
+		if err != nil {
+			return err
+		}
+		return handleVaultMember(paths, args[1:], out)
+	default:
+		return fmt.Errorf("unknown vault subcommand %q (use list, create, remove, default or member)", args[0])
+	}
+}
+
+func handleVaultList(homeDir string, args []string, out io.Writer) error {
+	fs := flag.NewFlagSet("vault list", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+
+	jsonOutput := fs.Bool("json", false, "Output JSON")
+	namesOnly := fs.Bool("names", false, "Print vault names only (used by shell completion)")
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() > 0 {
+		return fmt.Errorf("unexpected arguments for vault list: %s", strings.Join(fs.Args(), " "))
+	}
+
+	infos, err := vault.List(homeDir)
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case *jsonOutput:
+		encoded, err := json.MarshalIndent(infos, "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to encode vault list: %w", err)
+		}
+		_, _ = fmt.Fprintln(out, string(encoded))
+	case *namesOnly:
+		for _, info := range infos {
+			_, _ = fmt.Fprintln(out, info.Name)
+		}
+	default:
+		for _, info := range infos {
+			marker := " "
+			if info.Default {
+				marker = "*"
+			}
+			state := ""
+			if !info.Initialized {
+				state = " (not initialized)"
+			}
+			_, _ = fmt.Fprintf(out, "%s %s\t%s%s\n", marker, info.Name, info.Dir, state)
+		}
+	}
+	return nil
+}
+
+func handleVaultCreate(homeDir string, args []string, out io.Writer) error {
+	fs := flag.NewFlagSet("vault create", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+
+	makeDefault := fs.Bool("default", false, "Make the new vault the default")
+	team := fs.Bool("team", false, "Share the vault with team members through their age public keys")
+	memberName := fs.String("member", defaultMemberName(), "Your member name in a new team vault")
+	identityPath := fs.String("identity", "", "Use an existing age identity file instead of generating one")
+	join := fs.Bool("join", false, "Join an existing team: do not create the shared connection file")
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return errors.New("usage: sshmanager vault create [--default] [--team [--member <name>] [--identity <file>] [--join]] <name>")
+	}
+	set := map[string]bool{}
+	fs.Visit(func(f *flag.Flag) { set[f.Name] = true })
+	if !*team {
+		for _, flagName := range []string{"member", "identity", "join"} {
+			if set[flagName] {
+				return fmt.Errorf("--%s only applies to --team", flagName)
+			}
+		}
+	}
+
+	var id cryptoutil.Identity
+	if *team {
+		var err error
+		if path := strings.TrimSpace(*identityPath); path != "" {
+			id, err = cryptoutil.LoadIdentity(path)
+		} else {
+			id, err = cryptoutil.GenerateIdentity()
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	name := strings.TrimSpace(fs.Arg(0))
+	paths, err := vault.Create(homeDir, name)
+	if err != nil {
+		return err
+	}
+
+	if *team {
+		if err := cryptoutil.WriteIdentityFile(paths.SecretKeyFile, id); err != nil {
+			_ = vault.Remove(homeDir, name)
+			return err
+		}
+		if !*join {
+			if err := store.NewConnectionStore(paths.ConnectionFile, paths.SecretKeyFile).InitTeam(*memberName); err != nil {
+				_ = vault.Remove(homeDir, name)
+				return err
+			}
+		}
+	}
+	if *makeDefault {
+		if err := vault.SetDefault(homeDir, name); err != nil {
+			return err
+		}
+	}
+
+	switch {
+	case *team && *join:
+		_, _ = fmt.Fprintf(out, "Created team vault %q in %s.\nSend your recipient to a member so they can run 'sshmanager --vault <vault> vault member add <name> %s',\nthen copy the shared conn file into %s.\n", name, paths.Dir, id.Recipient(), paths.Dir)
+	case *team:
+		_, _ = fmt.Fprintf(out, "Created team vault %q in %s with you (%s) as the only member.\nYour recipient: %s\nShare %s (e.g. through git); keep %s private.\n", name, paths.Dir, strings.TrimSpace(*memberName), id.Recipient(), paths.ConnectionFile, paths.SecretKeyFile)
+	default:
+		_, _ = fmt.Fprintf(out, "Created vault %q in %s. Its key is created the first time it is used (sshmanager --vault %s ...).\n", name, paths.Dir, name)
+	}
+	return nil
+}
+
+// defaultMemberName is the OS user name, used as the member name of the
+// person creating a team vault.
+func defaultMemberName() string {
+	if current, err := user.Current(); err == nil && current.Username != "" {
+		return current.Username
+	}
+	return "me"
+}
+
+func handleVaultMember(paths vault.Paths, args []string, out io.Writer) error {
+	if len(args) == 0 {
+		return errors.New("missing vault member subcommand: usage: sshmanager vault member add|remove|list")
+	}
+
+	connStore := store.NewConnectionStore(paths.ConnectionFile, paths.SecretKeyFile)
+	switch strings.ToLower(strings.TrimSpace(args[0])) {
+	case "add":
+		if len(args) != 3 {
+			return errors.New("usage: sshmanager vault member add <name> <age1...recipient>")
+		}
+		if err := connStore.AddTeamMember(args[1], args[2]); err != nil {
+			return err
+		}
+		_, _ = fmt.Fprintf(out, "Added %s to vault %q. Share the updated %s.\n", strings.TrimSpace(args[1]), paths.Name, paths.ConnectionFile)
+		return nil
+	case "remove":
+		return handleVaultMemberRemove(connStore, paths, args[1:], out)
+	case "list":
+		return handleVaultMemberList(connStore, args[1:], out)
+	default:
+		return fmt.Errorf("unknown vault member subcommand %q (use add, remove or list)", args[0])
+	}
+}
+
+func handleVaultMemberRemove(connStore *store.ConnectionStore, paths vault.Paths, args []string, out io.Writer) error {
+	if len(args) != 1 {
+		return errors.New("usage: sshmanager vault member remove <name>")
+	}
+
+	name := strings.TrimSpace(args[0])
+	if err := connStore.RemoveTeamMember(name); err != nil {
+		return err
+	}
+	_, _ = fmt.Fprintf(out, "Removed %s from vault %q and rotated the data key. Share the updated %s, and change any passwords %s knew.\n", name, paths.Name, paths.ConnectionFile, name)
+	return nil
+}
+
+type vaultMemberOutput struct {
+	Name      string `json:"name"`
+	Recipient string `json:"recipient"`
+	You       bool   `json:"you"`
+}
+
+func handleVaultMemberList(connStore *store.ConnectionStore, args []string, out io.Writer) error {
+	fs := flag.NewFlagSet("vault member list", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+
+	jsonOutput := fs.Bool("json", false, "Output JSON")
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() > 0 {
+		return fmt.Errorf("unexpected arguments for vault member list: %s", strings.Join(fs.Args(), " "))
+	}
+
+	members, self, err := connStore.TeamMembers()
+	if err != nil {
+		return err
+	}
+	listed := make([]vaultMemberOutput, 0, len(members))
+	for _, member := range members {
+		listed = append(listed, vaultMemberOutput{Name: member.Name, Recipient: member.Recipient, You: member.Recipient == self})
+	}
+
+	if *jsonOutput {
+		encoded, err := json.MarshalIndent(listed, "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to encode member list: %w", err)
+		}
+		_, _ = fmt.Fprintln(out, string(encoded))
+		return nil
+	}
+	for _, member := range listed {
+		suffix := ""
+		if member.You {
+			suffix = " (you)"
+		}
+		_, _ = fmt.Fprintf(out, "%s\t%s%s\n", member.Name, member.Recipient, suffix)
+	}
+	return nil
+}
+
+func handleVaultRemove(homeDir string, args []string, out io.Writer) error {
+	fs := flag.NewFlagSet("vault remove", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+
+	yes := fs.Bool("yes", false, "Skip confirmation prompt")
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return errors.New("usage: sshmanager vault remove [--yes] <name>")
+	}
+
+	name := strings.TrimSpace(fs.Arg(0))
+	if err := vault.ValidateName(name); err != nil {
+		return err
+	}
+	if !vault.Exists(homeDir, name) {
+		return fmt.Errorf("vault %q does not exist", name)
+	}
+	if !*yes {
+		confirmed, err := confirmVaultRemoval(name)
+		if err != nil {
+			return err
+		}
+		if !confirmed {
+			_, _ = fmt.Fprintln(out, prompttext.DefaultPromptTexts.SuccessMessages.OperationCancelled)
+			return nil
+		}
+	}
+
+	if err := vault.Remove(homeDir, name); err != nil {
+		return err
+	}
+	_, _ = fmt.Fprintf(out, "Removed vault %q.\n", name)
+	return nil
+}
+
+func handleVaultDefault(homeDir string, args []string, out io.Writer) error {
+	switch len(args) {
+	case 0:
+		name, err := vault.DefaultVault(homeDir)
+		if err != nil {
+			return err
+		}
+		_, _ = fmt.Fprintln(out, name)
+		return nil
+	case 1:
+		name := strings.TrimSpace(args[0])
+		if err := vault.SetDefault(homeDir, name); err != nil {
+			return err
+		}
+		_, _ = fmt.Fprintf(out, "Default vault is now %q.\n", name)
+		return nil
+	default:
+		return errors.New("usage: sshmanager vault default [<name>]")
+	}
+}
@@ -0,0 +1,137 @@
+package commands
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	cryptoutil "github.com/emirhangumus/sshmanager/internal/crypto"
+	"github.com/emirhangumus/sshmanager/internal/store"
+	"github.com/emirhangumus/sshmanager/internal/vault"
+)
+
+func TestHandleVaultCreateListDefaultRemove(t *testing.T) {
+	home := t.TempDir()
+
+	var out strings.Builder
+	if err := handleVault(home, "", []string{"create", "--default", "team"}, &out); err != nil {
+		t.Fatalf("vault create failed: %v", err)
+	}
+
+	out.Reset()
+	if err := handleVault(home, "", []string{"list", "--json"}, &out); err != nil {
+		t.Fatalf("vault list failed: %v", err)
+	}
+	var infos []vault.Info
+	if err := json.Unmarshal([]byte(out.String()), &infos); err != nil {
+		t.Fatalf("failed to decode vault list: %v\nraw: %s", err, out.String())
+	}
+	if len(infos) != 2 || infos[1].Name != "team" || !infos[1].Default {
+		t.Fatalf("unexpected vault list: %+v", infos)
+	}
+
+	out.Reset()
+	if err := handleVault(home, "", []string{"default"}, &out); err != nil {
+		t.Fatalf("vault default failed: %v", err)
+	}
+	if strings.TrimSpace(out.String()) != "team" {
+		t.Fatalf("expected team as default, got %q", out.String())
+	}
+
+	if err := handleVault(home, "", []string{"remove", "--yes", "team"}, ioDiscard()); err == nil {
+		t.Fatal("expected removing the default vault to fail")
+	}
+	if err := handleVault(home, "", []string{"default", vault.DefaultName}, ioDiscard()); err != nil {
+		t.Fatalf("vault default reset failed: %v", err)
+	}
+
+	originalConfirm := confirmVaultRemoval
+	confirmVaultRemoval = func(string) (bool, error) { return false, nil }
+	t.Cleanup(func() { confirmVaultRemoval = originalConfirm })
+	out.Reset()
+	if err := handleVault(home, "", []string{"remove", "team"}, &out); err != nil {
+		t.Fatalf("cancelled vault remove failed: %v", err)
+	}
+	if !vault.Exists(home, "team") {
+		t.Fatal("vault removed despite cancelled confirmation")
+	}
+
+	if err := handleVault(home, "", []string{"remove", "--yes", "team"}, ioDiscard()); err != nil {
+		t.Fatalf("vault remove failed: %v", err)
+	}
+	if vault.Exists(home, "team") {
+		t.Fatal("expected vault to be removed")
+	}
+}
+
+func TestHandleVaultRejectsInvalidArgs(t *testing.T) {
+	home := t.TempDir()
+	for _, args := range [][]string{
+		nil,
+		{"bogus"},
+		{"create"},
+		{"create", "Bad Name"},
+		{"remove"},
+		{"remove", "--yes", "missing"},
+		{"default", "missing"},
+		{"list", "extra"},
+	} {
+		if err := handleVault(home, "", args, ioDiscard()); err == nil {
+			t.Fatalf("expected error for vault %v", args)
+		}
+	}
+}
+
+func TestHandleVaultTeamMembers(t *testing.T) {
+	home := t.TempDir()
+
+	var out strings.Builder
+	if err := handleVault(home, "", []string{"create", "--team", "--member", "alice", "team"}, &out); err != nil {
+		t.Fatalf("vault create --team failed: %v", err)
+	}
+	if !strings.Contains(out.String(), "Your recipient: age1") {
+		t.Fatalf("expected recipient in create output, got %q", out.String())
+	}
+
+	bob, err := cryptoutil.GenerateIdentity()
+	if err != nil {
+		t.Fatalf("GenerateIdentity failed: %v", err)
+	}
+	if err := handleVault(home, "team", []string{"member", "add", "bob", bob.Recipient()}, ioDiscard()); err != nil {
+		t.Fatalf("vault member add failed: %v", err)
+	}
+	if err := handleVault(home, "team", []string{"member", "add", "eve", "age1notakey"}, ioDiscard()); err == nil {
+		t.Fatal("expected invalid recipient to be rejected")
+	}
+
+	out.Reset()
+	if err := handleVault(home, "team", []string{"member", "list", "--json"}, &out); err != nil {
+		t.Fatalf("vault member list failed: %v", err)
+	}
+	var members []vaultMemberOutput
+	if err := json.Unmarshal([]byte(out.String()), &members); err != nil {
+		t.Fatalf("failed to decode member list: %v\nraw: %s", err, out.String())
+	}
+	if len(members) != 2 || members[0].Name != "alice" || !members[0].You || members[1].Recipient != bob.Recipient() {
+		t.Fatalf("unexpected members: %+v", members)
+	}
+
+	if err := handleVault(home, "team", []string{"member", "remove", "bob"}, ioDiscard()); err != nil {
+		t.Fatalf("vault member remove failed: %v", err)
+	}
+	paths := vault.PathsFor(home, "team")
+	connFile, err := store.NewConnectionStore(paths.ConnectionFile, paths.SecretKeyFile).Load()
+	if err != nil {
+		t.Fatalf("Load after member removal failed: %v", err)
+	}
+	if len(connFile.Connections) != 0 {
+		t.Fatalf("unexpected connections: %+v", connFile.Connections)
+	}
+
+	if err := handleVault(home, "", []string{"member", "list"}, ioDiscard()); err == nil {
+		t.Fatal("expected member list on a non-team vault to fail")
+	}
+	if err := handleVault(home, "", []string{"create", "--join", "plain"}, ioDiscard()); err == nil {
+		t.Fatal("expected --join without --team to fail")
+	}
+}
@@ -9,29 +9,31 @@ import (
 
 	"github.com/emirhangumus/sshmanager/internal/completion"
 	"github.com/emirhangumus/sshmanager/internal/config"
+	cryptoutil "github.com/emirhangumus/sshmanager/internal/crypto"
 	"github.com/emirhangumus/sshmanager/internal/store"
 	prompttext "github.com/emirhangumus/sshmanager/internal/ui/prompt"
 )
 
 func PrintUsage(out io.Writer) {
 	const usage = `Usage of sshmanager:
-  sshmanager [command] [arguments]
-  sshmanager <alias>
+  sshmanager [--vault <name>] [command] [arguments]
+  sshmanager [--vault <name>] <alias>
 
 Connection Commands:
   add [flags]
         Create a new SSH connection (interactive if no flags)
-        --host --username [--port] [--auth-mode password|key|agent] [--password] [--identity-file]
-        [--proxy-jump] [--local-forward ...] [--remote-forward ...] [--extra-ssh-arg ...]
-        [--group] [--tag ...] [--description] [--alias]
+        --host --username [--port] [--auth-mode password|key|agent] [--password | --password-source <src>] [--identity-file]
+        [--proxy-jump] [--local-forward ...] [--remote-forward ...] [--dynamic-forward ...] [--extra-ssh-arg ...]
+        [--group] [--tag ...] [--description] [--alias] [--extends <profile>]
+        Password sources: inline | command:<cmd> | keyring:[<service>/]<account> (resolved at connect time)
   edit [flags]
         Update an existing connection (interactive if no flags)
         Target: --alias <alias> | --id <connection-id>
-        Updates: --new-host --new-username --new-port --new-auth-mode --new-password --new-identity-file
-        --new-proxy-jump --new-local-forward ... --new-remote-forward ... --new-extra-ssh-arg ...
-        --new-group --new-tag ... --new-description --new-alias
+        Updates: --new-host --new-username --new-port --new-auth-mode --new-password --new-password-source --new-identity-file
+        --new-proxy-jump --new-local-forward ... --new-remote-forward ... --new-dynamic-forward ... --new-extra-ssh-arg ...
+        --new-group --new-tag ... --new-description --new-alias --new-extends
         Clears: --clear-alias --clear-description --clear-proxy-jump --clear-group
-        --clear-local-forwards --clear-remote-forwards --clear-extra-ssh-args --clear-tags
+        --clear-local-forwards --clear-remote-forwards --clear-dynamic-forwards --clear-extra-ssh-args --clear-tags --clear-extends
   remove [flags]
         Remove a connection
         Target: --alias <alias> | --id <connection-id>
@@ -43,27 +45,103 @@ Connection Commands:
   connect [flags]
         Connect to a saved host (interactive if no flags)
         Target: --alias <alias> | --id <connection-id>
+  trust [--connect] [--replace] [--yes] <alias> | --id <connection-id>
+        Pin the server's host keys (key scan, or --connect to record them on a first ssh connection)
+        Pinned connections only accept those keys; --replace accepts a changed key
+  known-hosts show [--json] [<alias>] | sync [--from-user] | prune [--dry-run] | forget <alias>
+        Manage the vault's known_hosts file that connect passes to ssh (UserKnownHostsFile)
+        sync writes pinned keys (--from-user also imports keys from ~/.ssh/known_hosts); prune drops hosts no connection uses
+  tunnel up [--backend openssh|native] [--foreground] <alias> | --id <connection-id>
+        Keep the connection's local/remote forwards open in the background (ssh -N), restarting with backoff
+        Fails early when a local forward port is already in use
+  tunnel down <alias> | --id <connection-id> | --all
+  tunnel status [--json] [<alias>] | list [--json]
+        Stop tunnels, or show running tunnels (pids, ports, restarts) and connections with forwards
+  exec [flags] [<alias>] -- <command>
+        Run a remote command on one or many connections
+        Target: <alias> | --alias <alias> | --id <connection-id> | --group <name> --tag <tag> (repeatable)
+        Options: --parallel <n> (default 8) --json
+  cp [flags] <alias>:<remote> <local> | <local> <alias>:<remote>
+        Copy files with scp using the connection's port, key, proxy jump and password
+        Options: --recursive|-r --sftp --group <name> --tag <tag> (repeatable, remote side as :<path>) --parallel <n>
   list [flags]
         List saved connections
         --json
-        --field id|alias|username|host|port|auth-mode|identity-file|proxy-jump|local-forwards|remote-forwards|extra-ssh-args|group|tags|description|target
+        --field id|alias|username|host|port|auth-mode|password-source|identity-file|proxy-jump|local-forwards|remote-forwards|dynamic-forwards|extra-ssh-args|group|tags|description|extends|last-used|use-count|target
         --group <name> --tag <tag> (repeatable)
+        --sort last-used|use-count
+  history [flags] [<alias>]
+        Show connection history (newest first)
+        Filters: --alias <alias> | --id <connection-id> --since <24h|YYYY-MM-DD|RFC3339> --backend openssh|native --failed
+        Options: --limit <n> (default 50, 0 = all) --json
+  log [flags] [<alias>]
+        Show recorded changes (newest first) with revision, time, user@host, command and changed fields
+        Options: --id <connection-id> --limit <n> (default 20, 0 = all) --json
+  undo [--to <revision>] [<alias> | --id <connection-id>]
+        Revert the last change; with a connection, revert only its last change (or restore it as of --to)
+  redo
+        Re-apply the last undone change
+  profile add|edit|remove|list [flags] [<name>]
+        Manage shared settings that connections inherit with --extends
+        add: [--extends] [--username] [--port] [--auth-mode] [--password | --password-source] [--identity-file] [--proxy-jump]
+        [--local-forward ...] [--remote-forward ...] [--dynamic-forward ...] [--extra-ssh-arg ...] [--description]
+        edit: --new-<field> ... --clear-extends --clear-username --clear-auth --clear-proxy-jump ...
+        list: [--json]
 
 Transfer / Recovery Commands:
-  export --out <path> [--format yaml|json]
-        Export decrypted connection data to file
-  import --in <path> [--format auto|yaml|json] [--mode merge|replace]
-        Import connection data from file
-  backup --out <path> [--format yaml|json] [--include-config=true|false]
+  export --out <path> [--format yaml|json|ssh-config] [--resolved]
+        Export decrypted connection data to file (ssh-config replaces the managed section in <path>)
+        --resolved applies profiles and omits them (yaml/json)
+  import --in <path> [--format auto|yaml|json|ssh-config] [--mode merge|replace]
+        Import connection data from file (ssh-config reads an OpenSSH client config)
+  backup --out <path> [--format yaml|json] [--include-config=true|false] [--encrypt] [--recipient <age1...>] [--redact-secrets]
         Create recovery snapshot (connections + optional config)
-  restore --in <path> [--format auto|yaml|json] [--mode merge|replace] [--with-config=true|false]
-        Restore from recovery snapshot
+        --encrypt seals it with a passphrase ($SSHMANAGER_BACKUP_PASSPHRASE or prompt); --recipient (repeatable) for age public keys
+  backup list [--json]
+        List the automatic backup generations kept on every save (backup.generations)
+  restore --in <path> [--format auto|yaml|json] [--mode merge|replace] [--with-config=true|false] [--identity <file>]
+        Restore from recovery snapshot; encrypted snapshots ask for the passphrase or use the age identity
+  restore --generation <n> [--mode merge|replace]
+        Roll back to an automatic backup generation (replaces by default)
+  sync init [--branch <name>] <repository>
+        Keep the encrypted store in a git repository (local path or URL); every change is committed
+  sync [--strategy ask|ours|theirs] [--no-push]
+        Pull, merge diverged connections by ID against the common ancestor, and push
+  sync status
+        Show the remote, branch and unpushed/unpulled commits
   doctor [--json]
         Run consistency diagnostics for config/key/connection data
 
+Key Commands:
+  rekey --mode raw|passphrase | --upgrade-kdf [flags]
+        Re-encrypt connection data and history with a new key (fresh salt for passphrase keys)
+        KDF: [--kdf argon2id|pbkdf2] [--memory <MiB>] [--time <n>] [--threads <n>] [--iterations <n>]
+        --upgrade-kdf keeps the current passphrase and re-derives the key with the given KDF
+        The new passphrase is read from $SSHMANAGER_NEW_MASTER_PASSPHRASE (--passphrase-env) or prompted for
+
+  agent start [--idle-timeout <duration>] [--foreground]
+        Cache the derived passphrase key in a background agent (Unix socket, mode 600)
+  agent stop|status [--json]
+        Stop the agent (wiping keys) or show its state
+  lock
+        Wipe cached keys from the agent; the next command asks for the passphrase again
+
+Vault Commands:
+  vault list [--json]
+        List vaults (* marks the default)
+  vault create [--default] <name>
+        Create a vault with its own key, lock and config
+        Team vault: --team [--member <name>] [--identity <age-key-file>] [--join] (data key wrapped per member)
+  vault remove [--yes] <name>
+        Securely delete a vault and its key
+  vault default [<name>]
+        Show or set the vault used when --vault and $SSHMANAGER_VAULT are not given
+  vault member add <name> <age1...> | remove <name> | list [--json]
+        Manage the members of the selected team vault (remove rotates the data key)
+
 Utility Commands:
   clean
-        Reset all saved SSH connections and key file
+        Reset all saved SSH connections and key file of the selected vault
   set <config-name> <config-value>
         Set SSHManager configuration
   version
@@ -80,6 +158,7 @@ Utility Commands:
 Notes:
   - Running without a command opens the interactive menu.
   - Using a single non-command token tries alias connect (e.g. sshmanager prod).
+  - Data lives in $SSHMANAGER_HOME (default ~/.sshmanager); --vault or $SSHMANAGER_VAULT selects a vault inside it.
   - Legacy dash commands (-clean, -set, -version, -complete, -completion) remain supported.`
 	_, _ = fmt.Fprintln(out, usage)
 }
@@ -203,9 +282,15 @@ func printCompletionCandidates(connectionFilePath, secretKeyFilePath, prefix str
 		return err
 	}
 
+	// Completion runs on every <TAB>: never prompt, and offer nothing while
+	// a passphrase store is locked.
+	cryptoutil.SetPassphrasePrompt(nil)
 	connStore := store.NewConnectionStore(connectionFilePath, secretKeyFilePath)
 	connFile, err := connStore.Load()
 	if err != nil {
+		if errors.Is(err, cryptoutil.ErrPassphraseRequired) {
+			return nil
+		}
 		return err
 	}
 
@@ -8,6 +8,7 @@ import (
 	"testing"
 
 	"github.com/emirhangumus/sshmanager/internal/config"
+	cryptoutil "github.com/emirhangumus/sshmanager/internal/crypto"
 	"github.com/emirhangumus/sshmanager/internal/model"
 	"github.com/emirhangumus/sshmanager/internal/store"
 )
@@ -23,12 +24,38 @@ func TestPrintUsageUsesSubcommandsWithoutDashes(t *testing.T) {
 		"  remove [flags]",
 		"  rename [flags]",
 		"  connect [flags]",
+		"  trust [--connect] [--replace] [--yes] <alias> | --id <connection-id>",
+		"  known-hosts show [--json] [<alias>] | sync [--from-user] | prune [--dry-run] | forget <alias>",
+		"  tunnel up [--backend openssh|native] [--foreground] <alias> | --id <connection-id>",
+		"  tunnel down <alias> | --id <connection-id> | --all",
+		"  tunnel status [--json] [<alias>] | list [--json]",
+		"  exec [flags] [<alias>] -- <command>",
+		"  cp [flags] <alias>:<remote> <local> | <local> <alias>:<remote>",
 		"  list [flags]",
-		"  export --out <path> [--format yaml|json]",
-		"  import --in <path> [--format auto|yaml|json] [--mode merge|replace]",
-		"  backup --out <path> [--format yaml|json] [--include-config=true|false]",
-		"  restore --in <path> [--format auto|yaml|json] [--mode merge|replace] [--with-config=true|false]",
+		"  history [flags] [<alias>]",
+		"  log [flags] [<alias>]",
+		"  undo [--to <revision>] [<alias> | --id <connection-id>]",
+		"  redo",
+		"  profile add|edit|remove|list [flags] [<name>]",
+		"  export --out <path> [--format yaml|json|ssh-config] [--resolved]",
+		"  import --in <path> [--format auto|yaml|json|ssh-config] [--mode merge|replace]",
+		"  backup --out <path> [--format yaml|json] [--include-config=true|false] [--encrypt] [--recipient <age1...>] [--redact-secrets]",
+		"  backup list [--json]",
+		"  restore --in <path> [--format auto|yaml|json] [--mode merge|replace] [--with-config=true|false] [--identity <file>]",
+		"  restore --generation <n> [--mode merge|replace]",
+		"  sync init [--branch <name>] <repository>",
+		"  sync [--strategy ask|ours|theirs] [--no-push]",
+		"  sync status",
 		"  doctor [--json]",
+		"  rekey --mode raw|passphrase | --upgrade-kdf [flags]",
+		"  agent start [--idle-timeout <duration>] [--foreground]",
+		"  agent stop|status [--json]",
+		"  lock",
+		"  vault list [--json]",
+		"  vault create [--default] <name>",
+		"  vault remove [--yes] <name>",
+		"  vault default [<name>]",
+		"  vault member add <name> <age1...> | remove <name> | list [--json]",
 		"  clean",
 		"  set <config-name> <config-value>",
 		"  version",
@@ -192,3 +219,36 @@ func captureStdout(t *testing.T, fn func()) string {
 	}
 	return string(b)
 }
+
+func TestHandleCompletePrintsNothingWhenLocked(t *testing.T) {
+	tmpDir := t.TempDir()
+	connPath := filepath.Join(tmpDir, "conn")
+	keyPath := filepath.Join(tmpDir, "secret.key")
+
+	t.Setenv(cryptoutil.PassphraseEnvVar, "completion-pass")
+	connStore := store.NewConnectionStore(connPath, keyPath)
+	if err := connStore.InitializeIfEmpty(); err != nil {
+		t.Fatalf("InitializeIfEmpty failed: %v", err)
+	}
+	if err := connStore.Update(func(connFile *model.ConnectionFile) error {
+		return connFile.AddConnection(model.SSHConnection{Username: "u", Host: "h", Password: "p", Alias: "prod-api"})
+	}); err != nil {
+		t.Fatalf("failed seeding alias: %v", err)
+	}
+
+	t.Setenv(cryptoutil.PassphraseEnvVar, "")
+	cryptoutil.SetPassphrasePrompt(func(string) (string, error) {
+		t.Fatal("completion must not prompt")
+		return "", nil
+	})
+	t.Cleanup(func() { cryptoutil.SetPassphrasePrompt(nil) })
+
+	output := captureStdout(t, func() {
+		if err := HandleComplete(connPath, keyPath, []string{"prod"}); err != nil {
+			t.Fatalf("HandleComplete returned error for locked store: %v", err)
+		}
+	})
+	if output != "" {
+		t.Fatalf("expected no candidates while locked, got %q", output)
+	}
+}
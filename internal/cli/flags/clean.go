@@ -5,6 +5,7 @@ import (
 	"strings"
 
 	"github.com/emirhangumus/sshmanager/internal/storage"
+	"github.com/emirhangumus/sshmanager/internal/store"
 	prompttext "github.com/emirhangumus/sshmanager/internal/ui/prompt"
 )
 
@@ -23,6 +24,17 @@ func CleanSSHFiles(connectionFilePath, secretKeyFilePath string) error {
 	if err := storage.SecureDelete(connectionFilePath); err != nil {
 		return err
 	}
+	for _, path := range store.BackupFilePaths(connectionFilePath) {
+		if err := storage.SecureDelete(path); err != nil {
+			return err
+		}
+	}
+	if err := storage.SecureDelete(store.HistoryFilePath(connectionFilePath)); err != nil {
+		return err
+	}
+	if err := storage.SecureDelete(store.JournalFilePath(secretKeyFilePath)); err != nil {
+		return err
+	}
 	if err := storage.SecureDelete(secretKeyFilePath); err != nil {
 		return err
 	}
@@ -1,9 +1,21 @@
 package scripts
 
 const BashScript = `_sshmanager() {
-  local cur candidates
+  local cur prev candidates i
+  local -a vault_args=()
   cur="${COMP_WORDS[COMP_CWORD]}"
-  candidates="$(sshmanager complete "$cur" 2>/dev/null)" || return 0
+  prev="${COMP_WORDS[COMP_CWORD-1]}"
+  if [[ "$prev" == "--vault" ]]; then
+    candidates="$(sshmanager vault list --names 2>/dev/null)" || return 0
+    COMPREPLY=( $(compgen -W "$candidates" -- "$cur") )
+    return 0
+  fi
+  for ((i = 1; i < COMP_CWORD - 1; i++)); do
+    if [[ "${COMP_WORDS[i]}" == "--vault" ]]; then
+      vault_args=(--vault "${COMP_WORDS[i+1]}")
+    fi
+  done
+  candidates="$(sshmanager "${vault_args[@]}" complete "$cur" 2>/dev/null)" || return 0
   COMPREPLY=( $(compgen -W "$candidates" -- "$cur") )
 }
 complete -o default -F _sshmanager sshmanager
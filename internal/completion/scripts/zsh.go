@@ -2,9 +2,17 @@ package scripts
 
 const ZshScript = `#compdef sshmanager
 _sshmanager() {
-  local prefix=$words[2]
-  local -a hosts
-  hosts=(${(f)"$(sshmanager complete "$prefix")"})
+  local -a hosts vault_args
+  local i
+  if [[ $words[CURRENT-1] == --vault ]]; then
+    hosts=(${(f)"$(sshmanager vault list --names 2>/dev/null)"})
+    compadd -- $hosts
+    return
+  fi
+  for (( i = 2; i < CURRENT - 1; i++ )); do
+    [[ $words[i] == --vault ]] && vault_args=(--vault $words[i+1])
+  done
+  hosts=(${(f)"$(sshmanager $vault_args complete "$words[CURRENT]" 2>/dev/null)"})
   compadd -S '' -- $hosts
 }
 `
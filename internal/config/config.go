@@ -1,12 +1,35 @@
 package config
 
+const (
+	// FileName is the config file in every vault directory.
+	FileName = "config.yaml"
+
+	ConnectBackendOpenSSH = "openssh"
+	ConnectBackendNative  = "native"
+
+	DefaultBackupGenerations = 5
+	MaxBackupGenerations     = 100
+)
+
 type BehaviourConfig struct {
 	ContinueAfterSSHExit     bool `yaml:"continueAfterSSHExit"`
 	ShowCredentialsOnConnect bool `yaml:"showCredentialsOnConnect"`
 }
 
+type ConnectConfig struct {
+	Backend string `yaml:"backend"`
+}
+
+// BackupConfig controls the encrypted generations of the connection file
+// kept on every save. Zero turns them off.
+type BackupConfig struct {
+	Generations int `yaml:"generations"`
+}
+
 type SSHManagerConfig struct {
 	Behaviour BehaviourConfig `yaml:"behaviour"`
+	Connect   ConnectConfig   `yaml:"connect"`
+	Backup    BackupConfig    `yaml:"backup"`
 }
 
 func Default() SSHManagerConfig {
@@ -15,9 +38,24 @@ func Default() SSHManagerConfig {
 			ContinueAfterSSHExit:     false,
 			ShowCredentialsOnConnect: false,
 		},
+		Connect: ConnectConfig{
+			Backend: ConnectBackendOpenSSH,
+		},
+		Backup: BackupConfig{
+			Generations: DefaultBackupGenerations,
+		},
 	}
 }
 
 func (c *SSHManagerConfig) SetDefault() {
 	*c = Default()
 }
+
+// EffectiveBackend returns the configured connect backend, falling back to
+// OpenSSH for empty or unrecognized values.
+func (c ConnectConfig) EffectiveBackend() string {
+	if backend, err := parseConnectBackend(c.Backend); err == nil {
+		return backend
+	}
+	return ConnectBackendOpenSSH
+}
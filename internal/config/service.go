@@ -52,6 +52,18 @@ func SetConfig(configFilePath, configName, configValue string) error {
 			return err
 		}
 		cfg.Behaviour.ShowCredentialsOnConnect = v
+	case "connect.backend":
+		v, err := parseConnectBackend(configValue)
+		if err != nil {
+			return err
+		}
+		cfg.Connect.Backend = v
+	case "backup.generations":
+		v, err := parseBackupGenerations(configValue)
+		if err != nil {
+			return err
+		}
+		cfg.Backup.Generations = v
 	default:
 		return errors.New("unknown configuration name: " + configName)
 	}
@@ -66,3 +78,22 @@ func parseBoolValue(configName, v string) (bool, error) {
 	}
 	return parsed, nil
 }
+
+func parseConnectBackend(v string) (string, error) {
+	switch norm := strings.ToLower(strings.TrimSpace(v)); norm {
+	case "", ConnectBackendOpenSSH:
+		return ConnectBackendOpenSSH, nil
+	case ConnectBackendNative:
+		return norm, nil
+	default:
+		return "", fmt.Errorf("invalid value for connect.backend, expected '%s' or '%s'", ConnectBackendOpenSSH, ConnectBackendNative)
+	}
+}
+
+func parseBackupGenerations(v string) (int, error) {
+	parsed, err := strconv.Atoi(strings.TrimSpace(v))
+	if err != nil || parsed < 0 || parsed > MaxBackupGenerations {
+		return 0, fmt.Errorf("invalid value for backup.generations, expected a number from 0 to %d", MaxBackupGenerations)
+	}
+	return parsed, nil
+}
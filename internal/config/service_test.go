@@ -65,3 +65,65 @@ func TestSetConfigRejectsUnknownKey(t *testing.T) {
 		t.Fatal("expected error for unknown key")
 	}
 }
+
+func TestSetConnectBackend(t *testing.T) {
+	tmpDir := t.TempDir()
+	configPath := filepath.Join(tmpDir, "config.yaml")
+
+	if err := storage.CreateFileIfNotExists(configPath, 0o600); err != nil {
+		t.Fatalf("CreateFileIfNotExists failed: %v", err)
+	}
+
+	cfg, err := LoadConfig(configPath)
+	if err != nil {
+		t.Fatalf("LoadConfig failed: %v", err)
+	}
+	if cfg.Connect.EffectiveBackend() != ConnectBackendOpenSSH {
+		t.Fatalf("expected default backend %q, got %q", ConnectBackendOpenSSH, cfg.Connect.EffectiveBackend())
+	}
+
+	if err := SetConfig(configPath, "connect.backend", "Native"); err != nil {
+		t.Fatalf("SetConfig failed: %v", err)
+	}
+	cfg, err = LoadConfig(configPath)
+	if err != nil {
+		t.Fatalf("LoadConfig failed: %v", err)
+	}
+	if cfg.Connect.Backend != ConnectBackendNative {
+		t.Fatalf("expected backend %q, got %q", ConnectBackendNative, cfg.Connect.Backend)
+	}
+
+	if err := SetConfig(configPath, "connect.backend", "putty"); err == nil {
+		t.Fatal("expected error for unknown backend")
+	}
+}
+
+func TestSetBackupGenerations(t *testing.T) {
+	tmpDir := t.TempDir()
+	configPath := filepath.Join(tmpDir, "config.yaml")
+
+	if err := storage.CreateFileIfNotExists(configPath, 0o600); err != nil {
+		t.Fatalf("CreateFileIfNotExists failed: %v", err)
+	}
+
+	cfg, err := LoadConfig(configPath)
+	if err != nil {
+		t.Fatalf("LoadConfig failed: %v", err)
+	}
+	if cfg.Backup.Generations != DefaultBackupGenerations {
+		t.Fatalf("expected default of %d generations, got %d", DefaultBackupGenerations, cfg.Backup.Generations)
+	}
+
+	if err := SetConfig(configPath, "backup.generations", "0"); err != nil {
+		t.Fatalf("SetConfig failed: %v", err)
+	}
+	if cfg, _ := LoadConfig(configPath); cfg.Backup.Generations != 0 {
+		t.Fatalf("expected generations to be turned off, got %d", cfg.Backup.Generations)
+	}
+
+	for _, value := range []string{"-1", "101", "many"} {
+		if err := SetConfig(configPath, "backup.generations", value); err == nil {
+			t.Fatalf("expected %q to be rejected", value)
+		}
+	}
+}
@@ -3,14 +3,12 @@ package cryptoutil
 import (
 	"crypto/aes"
 	"crypto/cipher"
-	"crypto/pbkdf2"
 	"crypto/rand"
-	"crypto/sha256"
 	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
-	"path/filepath"
 	"strings"
 )
 
@@ -25,9 +23,14 @@ const (
 	passphraseKeyFileMode  = "passphrase"
 	passphraseKeyFileKDF   = "pbkdf2-sha256" //nolint:gosec // KDF identifier string, not a credential value
 	passphraseKeyFileV1    = 1
+	passphraseKeyFileV2    = 2
 	passphraseIterationsV1 = 600_000
 )
 
+// ErrDecryptFailed means the payload did not authenticate with the key,
+// usually because the key (or the passphrase it came from) is wrong.
+var ErrDecryptFailed = errors.New("failed to decrypt data")
+
 func generateKey() ([]byte, error) {
 	key := make([]byte, keySize)
 	if _, err := rand.Read(key); err != nil {
@@ -36,11 +39,17 @@ func generateKey() ([]byte, error) {
 	return key, nil
 }
 
+// passphraseKeyFile is the JSON stored in secret.key for passphrase keys.
+// Version 1 only knows PBKDF2; version 2 adds argon2id and selects the
+// derivation with kdf.
 type passphraseKeyFile struct {
 	Version    int    `json:"version"`
 	Mode       string `json:"mode"`
 	KDF        string `json:"kdf"`
-	Iterations int    `json:"iterations"`
+	Iterations int    `json:"iterations,omitempty"`
+	MemoryKiB  uint32 `json:"memoryKiB,omitempty"`
+	Time       uint32 `json:"time,omitempty"`
+	Threads    uint8  `json:"threads,omitempty"`
 	Salt       string `json:"salt"`
 }
 
@@ -54,104 +63,121 @@ func LoadKey(filePath string) ([]byte, error) {
 	if err != nil {
 		return nil, fmt.Errorf("failed to read key file: %w", err)
 	}
-	if len(key) != keySize {
-		return loadPassphraseKey(key)
+	if len(key) == keySize {
+		return key, nil
 	}
-	return key, nil
+	if IsIdentityFile(key) {
+		id, err := ParseIdentity(key)
+		if err != nil {
+			return nil, err
+		}
+		return id.LocalKey()
+	}
+	return loadPassphraseKey(filePath, key)
 }
 
 func createKeyFile(filePath string) ([]byte, error) {
-	if err := os.MkdirAll(filepath.Dir(filePath), 0o700); err != nil {
-		return nil, fmt.Errorf("failed to create key directory: %w", err)
+	var (
+		keyFile KeyFile
+		err     error
+	)
+	if passphrase := strings.TrimSpace(os.Getenv(passphraseEnvVar)); passphrase != "" {
+		keyFile, err = NewPassphraseKeyFile(passphrase, DefaultPBKDF2Params())
+	} else {
+		keyFile, err = NewRawKeyFile()
 	}
-
-	passphrase := strings.TrimSpace(os.Getenv(passphraseEnvVar))
-	if passphrase == "" {
-		key, err := generateKey()
-		if err != nil {
-			return nil, fmt.Errorf("failed to generate key: %w", err)
-		}
-		if err := os.WriteFile(filePath, key, 0o600); err != nil {
-			return nil, fmt.Errorf("failed to write key file: %w", err)
-		}
-		return key, nil
+	if err != nil {
+		return nil, err
 	}
-
-	return createPassphraseKeyFile(filePath, passphrase)
+	if err := WriteKeyFile(filePath, keyFile); err != nil {
+		return nil, err
+	}
+	return keyFile.Key, nil
 }
 
-func createPassphraseKeyFile(filePath, passphrase string) ([]byte, error) {
-	salt := make([]byte, passphraseSalt)
-	if _, err := rand.Read(salt); err != nil {
-		return nil, fmt.Errorf("failed to generate passphrase salt: %w", err)
+// loadPassphraseKey derives the key for a passphrase key file, preferring
+// a key cached by the unlock agent over asking for the passphrase and a key
+// derived earlier in this process over running the KDF again.
+func loadPassphraseKey(filePath string, data []byte) ([]byte, error) {
+	if _, err := parsePassphraseKeyFile(data); err != nil {
+		return nil, err
+	}
+	if key, ok := cachedPassphraseKey(filePath, data); ok {
+		return key, nil
 	}
 
-	key, err := derivePassphraseKey(passphrase, salt, passphraseIterationsV1)
+	passphrase, err := MasterPassphrase(filePath)
 	if err != nil {
 		return nil, err
 	}
-
-	meta := passphraseKeyFile{
-		Version:    passphraseKeyFileV1,
-		Mode:       passphraseKeyFileMode,
-		KDF:        passphraseKeyFileKDF,
-		Iterations: passphraseIterationsV1,
-		Salt:       base64.StdEncoding.EncodeToString(salt),
+	if key, ok := derivedKey(filePath, data, passphrase); ok {
+		return key, nil
 	}
-	data, err := json.Marshal(meta)
+	key, err := derivePassphraseKey(data, passphrase)
 	if err != nil {
-		return nil, fmt.Errorf("failed to encode passphrase key metadata: %w", err)
-	}
-	if err := os.WriteFile(filePath, data, 0o600); err != nil {
-		return nil, fmt.Errorf("failed to write key file: %w", err)
+		return nil, err
 	}
+	rememberDerivedKey(filePath, data, passphrase, key)
+	cachePassphraseKey(filePath, data, key)
 	return key, nil
 }
 
-func loadPassphraseKey(data []byte) ([]byte, error) {
+func parsePassphraseKeyFile(data []byte) (passphraseKeyFile, error) {
 	var meta passphraseKeyFile
 	if err := json.Unmarshal(data, &meta); err != nil {
-		return nil, fmt.Errorf("invalid key file format: expected %d raw bytes or passphrase metadata", keySize)
+		return meta, fmt.Errorf("invalid key file format: expected %d raw bytes or passphrase metadata", keySize)
 	}
+	return meta, meta.validate()
+}
 
+// validate checks the mode, version and KDF of passphrase metadata, and
+// that the KDF parameters stay within the upper bounds.
+func (meta passphraseKeyFile) validate() error {
 	if meta.Mode != passphraseKeyFileMode {
-		return nil, fmt.Errorf("unsupported key file mode: %q", meta.Mode)
+		return fmt.Errorf("unsupported key file mode: %q", meta.Mode)
 	}
-	if meta.Version != passphraseKeyFileV1 {
-		return nil, fmt.Errorf("unsupported key file version: %d", meta.Version)
+	switch meta.Version {
+	case passphraseKeyFileV1:
+		if meta.KDF != "" && meta.KDF != KDFPBKDF2 {
+			return fmt.Errorf("unsupported kdf %q for key file version %d", meta.KDF, meta.Version)
+		}
+	case passphraseKeyFileV2:
+		if meta.KDF != KDFPBKDF2 && meta.KDF != KDFArgon2id {
+			return fmt.Errorf("unsupported kdf %q (use %s or %s)", meta.KDF, KDFArgon2id, KDFPBKDF2)
+		}
+	default:
+		return fmt.Errorf("unsupported key file version: %d", meta.Version)
 	}
-
-	passphrase := strings.TrimSpace(os.Getenv(passphraseEnvVar))
-	if passphrase == "" {
-		return nil, fmt.Errorf("passphrase key file detected; set %s", passphraseEnvVar)
+	if err := meta.params().checkLimits(); err != nil {
+		return fmt.Errorf("key file has out-of-range kdf parameters: %w", err)
 	}
+	return nil
+}
 
-	salt, err := base64.StdEncoding.DecodeString(meta.Salt)
-	if err != nil {
-		return nil, fmt.Errorf("invalid key file salt: %w", err)
+// params returns the derivation parameters recorded in the key file.
+// Version 1 files may omit iterations and fall back to the v1 default.
+func (meta passphraseKeyFile) params() KDFParams {
+	if meta.KDF == KDFArgon2id {
+		return KDFParams{KDF: KDFArgon2id, MemoryKiB: meta.MemoryKiB, Time: meta.Time, Threads: meta.Threads}
 	}
-
 	iterations := meta.Iterations
 	if iterations <= 0 {
 		iterations = passphraseIterationsV1
 	}
+	return KDFParams{KDF: KDFPBKDF2, Iterations: iterations}
+}
 
-	key, err := derivePassphraseKey(passphrase, salt, iterations)
+func derivePassphraseKeyFile(data []byte, passphrase string) ([]byte, error) {
+	meta, err := parsePassphraseKeyFile(data)
 	if err != nil {
 		return nil, err
 	}
-	return key, nil
-}
 
-func derivePassphraseKey(passphrase string, salt []byte, iterations int) ([]byte, error) {
-	key, err := pbkdf2.Key(sha256.New, passphrase, salt, iterations, keySize)
+	salt, err := base64.StdEncoding.DecodeString(meta.Salt)
 	if err != nil {
-		return nil, fmt.Errorf("failed to derive passphrase key: %w", err)
-	}
-	if len(key) != keySize {
-		return nil, fmt.Errorf("invalid derived key size: got %d, want %d", len(key), keySize)
+		return nil, fmt.Errorf("invalid key file salt: %w", err)
 	}
-	return key, nil
+	return meta.params().derive(passphrase, salt)
 }
 
 // EncryptData encrypts plain text with AES-GCM and prefixes nonce bytes.
@@ -200,7 +226,7 @@ func DecryptData(encryptedData, key []byte) (string, error) {
 
 	plaintext, err := aead.Open(nil, nonce, ciphertext, nil)
 	if err != nil {
-		return "", fmt.Errorf("failed to decrypt data: %w", err)
+		return "", fmt.Errorf("%w: %w", ErrDecryptFailed, err)
 	}
 	return string(plaintext), nil
 }
@@ -0,0 +1,210 @@
+package cryptoutil
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// Backup archives are encrypted files that leave the vault, so they do not
+// depend on its key: the data key is either derived from a passphrase whose
+// salt and KDF parameters are stored in the header, or wrapped for age
+// X25519 recipients like a team envelope.
+//
+//	magic "SSHB" | version (1) | header length (4, big endian) | header JSON | nonce (12) | ciphertext
+//
+// Everything before the ciphertext is bound as AES-GCM additional data.
+const (
+	ArchiveVersion1 = 1
+
+	archiveMagic     = "SSHB"
+	archiveMaxHeader = 1 << 20
+
+	// maxArchiveArgon2MemoryKiB bounds the memory a crafted header can make
+	// a restore allocate.
+	maxArchiveArgon2MemoryKiB = 4 * 1024 * 1024
+)
+
+// ArchiveHeader describes how a backup archive is encrypted. Exactly one of
+// Passphrase and Recipients is set. Format names the encoding of the
+// plaintext (yaml or json).
+type ArchiveHeader struct {
+	Format     string             `json:"format"`
+	Passphrase *passphraseKeyFile `json:"passphrase,omitempty"`
+	Recipients []TeamMember       `json:"recipients,omitempty"`
+}
+
+// IsPassphrase reports whether the archive is opened with a passphrase.
+func (h ArchiveHeader) IsPassphrase() bool {
+	return h.Passphrase != nil
+}
+
+// SealPassphraseArchive encrypts plaintext with a key derived from
+// passphrase with a fresh salt.
+func SealPassphraseArchive(plaintext, format, passphrase string, params KDFParams) ([]byte, error) {
+	keyFile, err := NewPassphraseKeyFile(passphrase, params)
+	if err != nil {
+		return nil, err
+	}
+	meta, err := parsePassphraseKeyFile(keyFile.Data)
+	if err != nil {
+		return nil, err
+	}
+	return sealArchive(plaintext, keyFile.Key, ArchiveHeader{Format: format, Passphrase: &meta})
+}
+
+// SealRecipientArchive encrypts plaintext with a random data key wrapped
+// for each age1... recipient.
+func SealRecipientArchive(plaintext, format string, recipients []string) ([]byte, error) {
+	if len(recipients) == 0 {
+		return nil, errors.New("an archive needs at least one recipient")
+	}
+	dataKey, err := generateKey()
+	if err != nil {
+		return nil, err
+	}
+	members := make([]TeamMember, 0, len(recipients))
+	for i, recipient := range recipients {
+		member, err := WrapTeamKey(dataKey, fmt.Sprintf("recipient-%d", i+1), recipient)
+		if err != nil {
+			return nil, err
+		}
+		members = append(members, member)
+	}
+	return sealArchive(plaintext, dataKey, ArchiveHeader{Format: format, Recipients: members})
+}
+
+// OpenPassphraseArchive decrypts an archive sealed with a passphrase.
+func OpenPassphraseArchive(data []byte, passphrase string) (string, ArchiveHeader, error) {
+	header, bodyOffset, err := parseArchive(data)
+	if err != nil {
+		return "", header, err
+	}
+	if !header.IsPassphrase() {
+		return "", header, errors.New("this backup is encrypted for age recipients, not with a passphrase")
+	}
+	params := header.Passphrase.params()
+	if params.KDF == KDFArgon2id && params.MemoryKiB > maxArchiveArgon2MemoryKiB {
+		return "", header, fmt.Errorf("backup archive asks for an unreasonable argon2id memory cost (%d KiB)", params.MemoryKiB)
+	}
+	salt, err := base64.StdEncoding.DecodeString(header.Passphrase.Salt)
+	if err != nil {
+		return "", header, fmt.Errorf("invalid backup archive salt: %w", err)
+	}
+	key, err := params.derive(passphrase, salt)
+	if err != nil {
+		return "", header, err
+	}
+	plaintext, err := openArchiveBody(data, bodyOffset, key)
+	if err != nil {
+		return "", header, fmt.Errorf("%w (wrong passphrase?)", err)
+	}
+	return plaintext, header, nil
+}
+
+// OpenRecipientArchive decrypts an archive with the data key wrapped for id.
+func OpenRecipientArchive(data []byte, id Identity) (string, ArchiveHeader, error) {
+	header, bodyOffset, err := parseArchive(data)
+	if err != nil {
+		return "", header, err
+	}
+	if header.IsPassphrase() {
+		return "", header, errors.New("this backup is encrypted with a passphrase, not for age recipients")
+	}
+	dataKey, err := id.UnwrapTeamKey(header.Recipients)
+	if errors.Is(err, ErrNotTeamMember) {
+		return "", header, fmt.Errorf("this backup is not encrypted for %s", id.Recipient())
+	}
+	if err != nil {
+		return "", header, err
+	}
+	plaintext, err := openArchiveBody(data, bodyOffset, dataKey)
+	if err != nil {
+		return "", header, err
+	}
+	return plaintext, header, nil
+}
+
+// InspectArchive returns the header of a backup archive without decrypting
+// it. The header is only authenticated by the Open functions.
+func InspectArchive(data []byte) (ArchiveHeader, error) {
+	header, _, err := parseArchive(data)
+	return header, err
+}
+
+// IsArchive reports whether data starts with the backup archive magic.
+func IsArchive(data []byte) bool {
+	return bytes.HasPrefix(data, []byte(archiveMagic))
+}
+
+func sealArchive(plaintext string, key []byte, header ArchiveHeader) ([]byte, error) {
+	aead, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	encodedHeader, err := json.Marshal(header)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode backup archive header: %w", err)
+	}
+	nonce := make([]byte, nonceSize)
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+
+	out := make([]byte, 0, len(archiveMagic)+5+len(encodedHeader)+nonceSize+len(plaintext)+aead.Overhead())
+	out = append(out, archiveMagic...)
+	out = append(out, ArchiveVersion1)
+	out = binary.BigEndian.AppendUint32(out, uint32(len(encodedHeader)))
+	out = append(out, encodedHeader...)
+	out = append(out, nonce...)
+	return aead.Seal(out, nonce, []byte(plaintext), out), nil
+}
+
+func openArchiveBody(data []byte, bodyOffset int, key []byte) (string, error) {
+	aead, err := newGCM(key)
+	if err != nil {
+		return "", err
+	}
+	nonce := data[bodyOffset-nonceSize : bodyOffset]
+	plaintext, err := aead.Open(nil, nonce, data[bodyOffset:], data[:bodyOffset])
+	if err != nil {
+		return "", fmt.Errorf("%w: %w", ErrDecryptFailed, err)
+	}
+	return string(plaintext), nil
+}
+
+func parseArchive(data []byte) (ArchiveHeader, int, error) {
+	if !IsArchive(data) {
+		return ArchiveHeader{}, 0, errors.New("not an encrypted backup archive")
+	}
+	offset := len(archiveMagic)
+	if len(data) < offset+5 {
+		return ArchiveHeader{}, 0, errors.New("backup archive is truncated")
+	}
+	if version := int(data[offset]); version != ArchiveVersion1 {
+		return ArchiveHeader{}, 0, fmt.Errorf("unsupported backup archive version %d", version)
+	}
+	headerLen := int(binary.BigEndian.Uint32(data[offset+1 : offset+5]))
+	offset += 5
+	if headerLen > archiveMaxHeader || len(data) < offset+headerLen+nonceSize {
+		return ArchiveHeader{}, 0, errors.New("backup archive is truncated")
+	}
+
+	var header ArchiveHeader
+	if err := json.Unmarshal(data[offset:offset+headerLen], &header); err != nil {
+		return ArchiveHeader{}, 0, fmt.Errorf("invalid backup archive header: %w", err)
+	}
+	if header.IsPassphrase() == (len(header.Recipients) > 0) {
+		return ArchiveHeader{}, 0, errors.New("invalid backup archive header: expected either a passphrase or recipients")
+	}
+	if header.IsPassphrase() {
+		if err := header.Passphrase.validate(); err != nil {
+			return ArchiveHeader{}, 0, fmt.Errorf("invalid backup archive header: %w", err)
+		}
+	}
+	return header, offset + headerLen + nonceSize, nil
+}
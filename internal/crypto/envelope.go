@@ -0,0 +1,183 @@
+package cryptoutil
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+)
+
+// Envelope layout (version 1):
+//
+//	magic "SSHM" | version (1) | kdf (1) | key id (8) | nonce (12) | ciphertext
+//
+// Everything before the ciphertext is the header and is bound to the
+// ciphertext as GCM additional data, so it cannot be altered without
+// failing authentication. Legacy files are a bare nonce||ciphertext.
+const (
+	EnvelopeVersionLegacy = 0
+	EnvelopeVersion1      = 1
+
+	envelopeMagic     = "SSHM"
+	envelopeKeyIDSize = 8
+	envelopeHeaderLen = len(envelopeMagic) + 1 + 1 + envelopeKeyIDSize + nonceSize
+
+	// KDFRaw names the "derivation" of a raw key file in envelope headers.
+	KDFRaw = "raw"
+)
+
+var envelopeKDFCodes = []string{KDFRaw, KDFPBKDF2, KDFArgon2id, KDFX25519}
+
+// EnvelopeHeader describes an encrypted file. Legacy files only have a
+// nonce; team envelopes list their members instead of a key id.
+type EnvelopeHeader struct {
+	Version int
+	KDF     string
+	KeyID   string
+	Nonce   []byte
+	Members []TeamMember
+}
+
+// KeyID returns the short identifier stored in envelope headers for key. It
+// is a truncated hash, so it does not reveal the key.
+func KeyID(key []byte) string {
+	sum := sha256.Sum256(append([]byte("sshmanager-key-id:"), key...))
+	return hex.EncodeToString(sum[:envelopeKeyIDSize])
+}
+
+// SealEnvelope encrypts plaintext into a version 1 envelope. kdf records how
+// key was obtained (raw, pbkdf2-sha256 or argon2id).
+func SealEnvelope(plaintext string, key []byte, kdf string) ([]byte, error) {
+	aead, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	kdfCode := -1
+	for i, name := range envelopeKDFCodes {
+		if name == kdf {
+			kdfCode = i
+		}
+	}
+	if kdfCode < 0 {
+		return nil, fmt.Errorf("unknown envelope kdf %q", kdf)
+	}
+
+	keyID, err := hex.DecodeString(KeyID(key))
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, nonceSize)
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+
+	header := make([]byte, 0, envelopeHeaderLen)
+	header = append(header, envelopeMagic...)
+	header = append(header, EnvelopeVersion1, byte(kdfCode))
+	header = append(header, keyID...)
+	header = append(header, nonce...)
+
+	return aead.Seal(header, nonce, []byte(plaintext), header), nil
+}
+
+// OpenEnvelope decrypts a version 1 envelope or a legacy nonce||ciphertext
+// payload and reports which one it was.
+func OpenEnvelope(data, key []byte) (string, EnvelopeHeader, error) {
+	header, err := InspectEnvelope(data)
+	if err != nil {
+		// A legacy payload whose nonce starts with the magic by chance
+		// may not parse as an envelope header.
+		if plaintext, legacyHeader, ok := openLegacyEnvelope(data, key); ok {
+			return plaintext, legacyHeader, nil
+		}
+		return "", EnvelopeHeader{}, err
+	}
+	if header.Version == EnvelopeVersionLegacy {
+		plaintext, err := DecryptData(data, key)
+		return plaintext, header, err
+	}
+	if len(header.Members) > 0 {
+		return "", header, errors.New("this is a team vault file; the key file must hold a member identity")
+	}
+
+	if currentID := KeyID(key); currentID != header.KeyID {
+		// A legacy payload may start with the magic by chance.
+		if plaintext, legacyHeader, ok := openLegacyEnvelope(data, key); ok {
+			return plaintext, legacyHeader, nil
+		}
+		return "", header, fmt.Errorf("%w: data was encrypted with key %s (%s), current key is %s", ErrDecryptFailed, header.KeyID, header.KDF, currentID)
+	}
+
+	aead, err := newGCM(key)
+	if err != nil {
+		return "", header, err
+	}
+	plaintext, err := aead.Open(nil, header.Nonce, data[envelopeHeaderLen:], data[:envelopeHeaderLen])
+	if err != nil {
+		return "", header, fmt.Errorf("%w: %w", ErrDecryptFailed, err)
+	}
+	return string(plaintext), header, nil
+}
+
+// openLegacyEnvelope decrypts data as a legacy nonce||ciphertext payload and
+// reports whether that succeeded.
+func openLegacyEnvelope(data, key []byte) (string, EnvelopeHeader, bool) {
+	plaintext, err := DecryptData(data, key)
+	if err != nil {
+		return "", EnvelopeHeader{}, false
+	}
+	return plaintext, EnvelopeHeader{Version: EnvelopeVersionLegacy, Nonce: data[:nonceSize]}, true
+}
+
+// InspectEnvelope parses the header of an encrypted payload without
+// decrypting it. Payloads without the magic are reported as legacy.
+func InspectEnvelope(data []byte) (EnvelopeHeader, error) {
+	if IsTeamEnvelope(data) {
+		members, err := InspectTeamEnvelope(data)
+		if err != nil {
+			return EnvelopeHeader{}, err
+		}
+		return EnvelopeHeader{Version: int(data[len(teamEnvelopeMagic)]), KDF: KDFX25519, Members: members}, nil
+	}
+	if !bytes.HasPrefix(data, []byte(envelopeMagic)) || len(data) < envelopeHeaderLen {
+		if len(data) < nonceSize {
+			return EnvelopeHeader{}, errors.New("invalid data format: encrypted payload too short")
+		}
+		return EnvelopeHeader{Version: EnvelopeVersionLegacy, Nonce: data[:nonceSize]}, nil
+	}
+
+	offset := len(envelopeMagic)
+	version := int(data[offset])
+	if version != EnvelopeVersion1 {
+		return EnvelopeHeader{}, fmt.Errorf("unsupported envelope version %d", version)
+	}
+	kdfCode := int(data[offset+1])
+	if kdfCode >= len(envelopeKDFCodes) {
+		return EnvelopeHeader{}, fmt.Errorf("unknown envelope kdf code %d", kdfCode)
+	}
+	offset += 2
+	keyID := hex.EncodeToString(data[offset : offset+envelopeKeyIDSize])
+	offset += envelopeKeyIDSize
+
+	return EnvelopeHeader{
+		Version: version,
+		KDF:     envelopeKDFCodes[kdfCode],
+		KeyID:   keyID,
+		Nonce:   data[offset : offset+nonceSize],
+	}, nil
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	if len(key) != keySize {
+		return nil, fmt.Errorf("invalid key size: got %d, want %d", len(key), keySize)
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
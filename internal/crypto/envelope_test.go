@@ -0,0 +1,120 @@
+package cryptoutil
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestSealOpenEnvelopeRoundTrip(t *testing.T) {
+	key := bytes.Repeat([]byte{2}, 32)
+
+	sealed, err := SealEnvelope("payload", key, KDFArgon2id)
+	if err != nil {
+		t.Fatalf("SealEnvelope returned error: %v", err)
+	}
+	if !bytes.HasPrefix(sealed, []byte(envelopeMagic)) {
+		t.Fatalf("expected envelope magic prefix, got %q", sealed[:4])
+	}
+
+	plain, header, err := OpenEnvelope(sealed, key)
+	if err != nil {
+		t.Fatalf("OpenEnvelope returned error: %v", err)
+	}
+	if plain != "payload" {
+		t.Fatalf("round trip mismatch: got %q", plain)
+	}
+	if header.Version != EnvelopeVersion1 || header.KDF != KDFArgon2id || header.KeyID != KeyID(key) {
+		t.Fatalf("unexpected header: %+v", header)
+	}
+}
+
+func TestOpenEnvelopeReadsLegacyPayload(t *testing.T) {
+	key := bytes.Repeat([]byte{3}, 32)
+	legacy, err := EncryptData("legacy", key)
+	if err != nil {
+		t.Fatalf("EncryptData returned error: %v", err)
+	}
+
+	plain, header, err := OpenEnvelope(legacy, key)
+	if err != nil {
+		t.Fatalf("OpenEnvelope returned error for legacy payload: %v", err)
+	}
+	if plain != "legacy" || header.Version != EnvelopeVersionLegacy {
+		t.Fatalf("unexpected legacy result: %q %+v", plain, header)
+	}
+}
+
+func TestOpenEnvelopeReadsLegacyPayloadWithMagicNonce(t *testing.T) {
+	key := bytes.Repeat([]byte{3}, 32)
+	nonces := map[string][]byte{
+		"unknown version":  append([]byte(envelopeMagic), 9, 0, 1, 2, 3, 4, 5, 6),
+		"unknown kdf code": append([]byte(envelopeMagic), EnvelopeVersion1, 200, 1, 2, 3, 4, 5, 6),
+		"team magic":       append([]byte(teamEnvelopeMagic), 1, 0, 1, 2, 3, 4, 5, 6),
+	}
+	for name, nonce := range nonces {
+		block, err := aes.NewCipher(key)
+		if err != nil {
+			t.Fatalf("NewCipher returned error: %v", err)
+		}
+		aead, err := cipher.NewGCM(block)
+		if err != nil {
+			t.Fatalf("NewGCM returned error: %v", err)
+		}
+		legacy := aead.Seal(append([]byte(nil), nonce...), nonce, []byte("legacy"), nil)
+
+		plain, header, err := OpenEnvelope(legacy, key)
+		if err != nil {
+			t.Fatalf("%s: OpenEnvelope returned error for legacy payload: %v", name, err)
+		}
+		if plain != "legacy" || header.Version != EnvelopeVersionLegacy {
+			t.Fatalf("%s: unexpected legacy result: %q %+v", name, plain, header)
+		}
+	}
+}
+
+func TestOpenEnvelopeAuthenticatesHeader(t *testing.T) {
+	key := bytes.Repeat([]byte{4}, 32)
+	sealed, err := SealEnvelope("payload", key, KDFRaw)
+	if err != nil {
+		t.Fatalf("SealEnvelope returned error: %v", err)
+	}
+
+	tampered := append([]byte(nil), sealed...)
+	tampered[len(envelopeMagic)+1] = 1 // raw -> pbkdf2-sha256
+	if _, _, err := OpenEnvelope(tampered, key); !errors.Is(err, ErrDecryptFailed) {
+		t.Fatalf("expected ErrDecryptFailed for tampered header, got %v", err)
+	}
+}
+
+func TestOpenEnvelopeReportsKeyMismatch(t *testing.T) {
+	sealed, err := SealEnvelope("payload", bytes.Repeat([]byte{5}, 32), KDFPBKDF2)
+	if err != nil {
+		t.Fatalf("SealEnvelope returned error: %v", err)
+	}
+
+	_, _, err = OpenEnvelope(sealed, bytes.Repeat([]byte{6}, 32))
+	if !errors.Is(err, ErrDecryptFailed) || !strings.Contains(err.Error(), "encrypted with key") {
+		t.Fatalf("expected key mismatch error, got %v", err)
+	}
+}
+
+func TestInspectEnvelopeRejectsUnknownVersion(t *testing.T) {
+	sealed, err := SealEnvelope("payload", bytes.Repeat([]byte{7}, 32), KDFRaw)
+	if err != nil {
+		t.Fatalf("SealEnvelope returned error: %v", err)
+	}
+	sealed[len(envelopeMagic)] = 9
+	if _, err := InspectEnvelope(sealed); err == nil || !strings.Contains(err.Error(), "unsupported envelope version") {
+		t.Fatalf("expected unsupported version error, got %v", err)
+	}
+}
+
+func TestSealEnvelopeRejectsUnknownKDF(t *testing.T) {
+	if _, err := SealEnvelope("payload", bytes.Repeat([]byte{8}, 32), "scrypt"); err == nil {
+		t.Fatal("expected error for unknown kdf")
+	}
+}
@@ -0,0 +1,164 @@
+package cryptoutil
+
+import (
+	"crypto/pbkdf2"
+	"crypto/sha256"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+)
+
+const (
+	KDFPBKDF2   = passphraseKeyFileKDF
+	KDFArgon2id = "argon2id"
+
+	defaultArgon2MemoryKiB = 64 * 1024
+	defaultArgon2Time      = 3
+	defaultArgon2Threads   = 4
+
+	// Recommended floors follow the OWASP password storage guidance. Key
+	// files below them still load; doctor warns about them.
+	recommendedPBKDF2Iterations = 600_000
+	recommendedArgon2MemoryKiB  = 19 * 1024
+	recommendedArgon2Time       = 2
+
+	minArgon2MemoryKiB = 8 * 1024
+
+	// Upper bounds keep a corrupted or crafted key file from demanding
+	// unbounded memory or CPU time on every unlock. Threads is bounded by
+	// its uint8 type.
+	maxPBKDF2Iterations = 10_000_000
+	maxArgon2MemoryKiB  = 4 * 1024 * 1024
+	maxArgon2Time       = 16
+)
+
+// KDFParams selects and tunes the passphrase key derivation. Iterations
+// applies to PBKDF2; MemoryKiB, Time and Threads apply to argon2id.
+type KDFParams struct {
+	KDF        string
+	Iterations int
+	MemoryKiB  uint32
+	Time       uint32
+	Threads    uint8
+}
+
+func DefaultPBKDF2Params() KDFParams {
+	return KDFParams{KDF: KDFPBKDF2, Iterations: passphraseIterationsV1}
+}
+
+func DefaultArgon2idParams() KDFParams {
+	return KDFParams{
+		KDF:       KDFArgon2id,
+		MemoryKiB: defaultArgon2MemoryKiB,
+		Time:      defaultArgon2Time,
+		Threads:   defaultArgon2Threads,
+	}
+}
+
+// Validate rejects parameters too weak or too costly to generate a new key
+// file with.
+func (p KDFParams) Validate() error {
+	switch p.KDF {
+	case KDFPBKDF2:
+		if p.Iterations < MinPassphraseIterations {
+			return fmt.Errorf("iterations must be at least %d", MinPassphraseIterations)
+		}
+	case KDFArgon2id:
+		if p.MemoryKiB < minArgon2MemoryKiB {
+			return fmt.Errorf("argon2id memory must be at least %d MiB", minArgon2MemoryKiB/1024)
+		}
+		if p.Time < 1 {
+			return fmt.Errorf("argon2id time must be at least 1")
+		}
+		if p.Threads < 1 {
+			return fmt.Errorf("argon2id threads must be at least 1")
+		}
+	default:
+		return fmt.Errorf("unknown kdf %q (use %s or %s)", p.KDF, KDFArgon2id, KDFPBKDF2)
+	}
+	return p.checkLimits()
+}
+
+// checkLimits rejects parameters above the upper bounds. Unlike Validate it
+// accepts parameters below today's minimums, so it also applies to the
+// parameters read back from existing key files.
+func (p KDFParams) checkLimits() error {
+	switch p.KDF {
+	case KDFPBKDF2:
+		if p.Iterations > maxPBKDF2Iterations {
+			return fmt.Errorf("iterations must be at most %d", maxPBKDF2Iterations)
+		}
+	case KDFArgon2id:
+		if p.MemoryKiB > maxArgon2MemoryKiB {
+			return fmt.Errorf("argon2id memory must be at most %d MiB", maxArgon2MemoryKiB/1024)
+		}
+		if p.Time > maxArgon2Time {
+			return fmt.Errorf("argon2id time must be at most %d", maxArgon2Time)
+		}
+	}
+	return nil
+}
+
+// Weaknesses lists every parameter below the recommended floor.
+func (p KDFParams) Weaknesses() []string {
+	var weak []string
+	switch p.KDF {
+	case KDFPBKDF2:
+		if p.Iterations < recommendedPBKDF2Iterations {
+			weak = append(weak, fmt.Sprintf("iterations %d < %d", p.Iterations, recommendedPBKDF2Iterations))
+		}
+	case KDFArgon2id:
+		if p.MemoryKiB < recommendedArgon2MemoryKiB {
+			weak = append(weak, fmt.Sprintf("memory %dKiB < %dKiB", p.MemoryKiB, recommendedArgon2MemoryKiB))
+		}
+		if p.Time < recommendedArgon2Time {
+			weak = append(weak, fmt.Sprintf("time %d < %d", p.Time, recommendedArgon2Time))
+		}
+	}
+	return weak
+}
+
+func (p KDFParams) String() string {
+	switch p.KDF {
+	case KDFArgon2id:
+		return fmt.Sprintf("%s memory=%dKiB time=%d threads=%d", p.KDF, p.MemoryKiB, p.Time, p.Threads)
+	default:
+		return fmt.Sprintf("%s iterations=%d", p.KDF, p.Iterations)
+	}
+}
+
+func (p KDFParams) derive(passphrase string, salt []byte) ([]byte, error) {
+	var key []byte
+	switch p.KDF {
+	case KDFPBKDF2:
+		derived, err := pbkdf2.Key(sha256.New, passphrase, salt, p.Iterations, keySize)
+		if err != nil {
+			return nil, fmt.Errorf("failed to derive passphrase key: %w", err)
+		}
+		key = derived
+	case KDFArgon2id:
+		if p.MemoryKiB == 0 || p.Time == 0 || p.Threads == 0 {
+			return nil, fmt.Errorf("invalid argon2id parameters: %s", p)
+		}
+		key = argon2.IDKey([]byte(passphrase), salt, p.Time, p.MemoryKiB, p.Threads, keySize)
+	default:
+		return nil, fmt.Errorf("unsupported kdf %q", p.KDF)
+	}
+	if len(key) != keySize {
+		return nil, fmt.Errorf("invalid derived key size: got %d, want %d", len(key), keySize)
+	}
+	return key, nil
+}
+
+// ParseKDF normalizes a user supplied KDF name.
+func ParseKDF(raw string) (string, error) {
+	switch strings.ToLower(strings.TrimSpace(raw)) {
+	case KDFArgon2id:
+		return KDFArgon2id, nil
+	case KDFPBKDF2, "pbkdf2":
+		return KDFPBKDF2, nil
+	default:
+		return "", fmt.Errorf("unknown kdf %q (use %s or pbkdf2)", raw, KDFArgon2id)
+	}
+}
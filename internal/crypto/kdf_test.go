@@ -0,0 +1,141 @@
+package cryptoutil
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeKeyFile(t *testing.T, data []byte) string {
+	t.Helper()
+	keyPath := filepath.Join(t.TempDir(), "secret.key")
+	if err := os.WriteFile(keyPath, data, 0o600); err != nil {
+		t.Fatalf("failed to write key fixture: %v", err)
+	}
+	return keyPath
+}
+
+func TestLoadKeyDispatchesOnKDF(t *testing.T) {
+	t.Setenv(passphraseEnvVar, "correct horse")
+
+	for _, params := range []KDFParams{
+		DefaultArgon2idParams(),
+		{KDF: KDFArgon2id, MemoryKiB: minArgon2MemoryKiB, Time: 1, Threads: 1},
+		DefaultPBKDF2Params(),
+	} {
+		keyFile, err := NewPassphraseKeyFile("correct horse", params)
+		if err != nil {
+			t.Fatalf("NewPassphraseKeyFile(%s) failed: %v", params, err)
+		}
+
+		var meta passphraseKeyFile
+		if err := json.Unmarshal(keyFile.Data, &meta); err != nil {
+			t.Fatalf("invalid metadata: %v", err)
+		}
+		if meta.Version != passphraseKeyFileV2 || meta.KDF != params.KDF {
+			t.Fatalf("unexpected metadata for %s: %+v", params, meta)
+		}
+
+		loaded, err := LoadKey(writeKeyFile(t, keyFile.Data))
+		if err != nil {
+			t.Fatalf("LoadKey(%s) failed: %v", params, err)
+		}
+		if !bytes.Equal(loaded, keyFile.Key) {
+			t.Fatalf("LoadKey(%s) derived a different key", params)
+		}
+	}
+}
+
+func TestLoadKeyStillReadsVersion1Files(t *testing.T) {
+	t.Setenv(passphraseEnvVar, "legacy")
+	salt := bytes.Repeat([]byte{7}, passphraseSalt)
+	data, err := json.Marshal(passphraseKeyFile{
+		Version:    passphraseKeyFileV1,
+		Mode:       passphraseKeyFileMode,
+		KDF:        KDFPBKDF2,
+		Iterations: MinPassphraseIterations,
+		Salt:       base64.StdEncoding.EncodeToString(salt),
+	})
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+
+	want, err := KDFParams{KDF: KDFPBKDF2, Iterations: MinPassphraseIterations}.derive("legacy", salt)
+	if err != nil {
+		t.Fatalf("derive failed: %v", err)
+	}
+	got, err := LoadKey(writeKeyFile(t, data))
+	if err != nil {
+		t.Fatalf("LoadKey failed: %v", err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Fatal("version 1 key derived differently")
+	}
+}
+
+func TestLoadKeyRejectsUnknownKDF(t *testing.T) {
+	t.Setenv(passphraseEnvVar, "x")
+	cases := map[string]string{
+		`{"version":1,"mode":"passphrase","kdf":"argon2id","salt":"AAAA"}`:                                                 "unsupported kdf",
+		`{"version":2,"mode":"passphrase","kdf":"scrypt","salt":"AAAA"}`:                                                   "unsupported kdf",
+		`{"version":3,"mode":"passphrase","kdf":"argon2id","salt":"AAAA"}`:                                                 "unsupported key file version",
+		`{"version":2,"mode":"passphrase","kdf":"argon2id","salt":"AAAA"}`:                                                 "invalid argon2id parameters",
+		`{"version":2,"mode":"passphrase","kdf":"argon2id","memoryKiB":4294967295,"time":3,"threads":4,"salt":"AAAA"}`:     "out-of-range kdf parameters",
+		`{"version":2,"mode":"passphrase","kdf":"argon2id","memoryKiB":65536,"time":4294967295,"threads":4,"salt":"AAAA"}`: "out-of-range kdf parameters",
+		`{"version":2,"mode":"passphrase","kdf":"pbkdf2-sha256","iterations":2000000000,"salt":"AAAA"}`:                    "out-of-range kdf parameters",
+	}
+	for data, want := range cases {
+		_, err := LoadKey(writeKeyFile(t, []byte(data)))
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Fatalf("LoadKey(%s): expected %q, got %v", data, want, err)
+		}
+	}
+}
+
+func TestKDFParamsValidateAndWeaknesses(t *testing.T) {
+	if err := (KDFParams{KDF: KDFArgon2id, MemoryKiB: 1024, Time: 3, Threads: 1}).Validate(); err == nil {
+		t.Fatal("expected low argon2id memory to be rejected")
+	}
+	if err := (KDFParams{KDF: KDFPBKDF2, Iterations: 1000}).Validate(); err == nil {
+		t.Fatal("expected low PBKDF2 iterations to be rejected")
+	}
+	if err := (KDFParams{KDF: KDFArgon2id, MemoryKiB: maxArgon2MemoryKiB + 1, Time: 3, Threads: 1}).Validate(); err == nil {
+		t.Fatal("expected excessive argon2id memory to be rejected")
+	}
+	if err := (KDFParams{KDF: KDFArgon2id, MemoryKiB: minArgon2MemoryKiB, Time: maxArgon2Time + 1, Threads: 1}).Validate(); err == nil {
+		t.Fatal("expected excessive argon2id time to be rejected")
+	}
+	if weak := DefaultArgon2idParams().Weaknesses(); len(weak) != 0 {
+		t.Fatalf("default argon2id params reported weak: %v", weak)
+	}
+	weak := KDFParams{KDF: KDFArgon2id, MemoryKiB: minArgon2MemoryKiB, Time: 1, Threads: 1}.Weaknesses()
+	if len(weak) != 2 {
+		t.Fatalf("expected memory and time weaknesses, got %v", weak)
+	}
+	if weak := (KDFParams{KDF: KDFPBKDF2, Iterations: MinPassphraseIterations}).Weaknesses(); len(weak) != 1 {
+		t.Fatalf("expected iteration weakness, got %v", weak)
+	}
+}
+
+func TestInspectKeyFile(t *testing.T) {
+	raw, err := InspectKeyFile(writeKeyFile(t, bytes.Repeat([]byte{1}, keySize)))
+	if err != nil || raw.Mode != KeyFileModeRaw {
+		t.Fatalf("unexpected raw info: %+v, %v", raw, err)
+	}
+
+	keyFile, err := NewPassphraseKeyFile("p", DefaultArgon2idParams())
+	if err != nil {
+		t.Fatalf("NewPassphraseKeyFile failed: %v", err)
+	}
+	info, err := InspectKeyFile(writeKeyFile(t, keyFile.Data))
+	if err != nil {
+		t.Fatalf("InspectKeyFile failed: %v", err)
+	}
+	if info.Mode != KeyFileModePassphrase || info.Version != passphraseKeyFileV2 || info.KDF != DefaultArgon2idParams() {
+		t.Fatalf("unexpected passphrase info: %+v", info)
+	}
+}
@@ -0,0 +1,120 @@
+package cryptoutil
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"sync"
+)
+
+// KeyCache keeps derived passphrase keys between runs, e.g. the unlock
+// agent. Keys are stored per absolute key file path together with a
+// fingerprint of the key file contents, so a rotated key file never
+// matches a stale entry.
+type KeyCache interface {
+	Get(keyFilePath, fingerprint string) ([]byte, bool)
+	Put(keyFilePath, fingerprint string, key []byte)
+	Forget(keyFilePath string)
+}
+
+var (
+	keyCacheMu sync.Mutex
+	keyCache   KeyCache
+
+	derivedKeysMu sync.Mutex
+	// derivedKeys remembers the key derived for each key file in this
+	// process, so the KDF runs at most once per key file, contents and
+	// passphrase.
+	derivedKeys = map[string]derivedKeyEntry{}
+
+	derivePassphraseKey = derivePassphraseKeyFile
+)
+
+type derivedKeyEntry struct {
+	fingerprint string
+	passphrase  string
+	key         []byte
+}
+
+// SetKeyCache installs the cache LoadKey consults before asking for a
+// passphrase. A nil cache disables caching.
+func SetKeyCache(cache KeyCache) {
+	keyCacheMu.Lock()
+	defer keyCacheMu.Unlock()
+	keyCache = cache
+}
+
+func currentKeyCache() KeyCache {
+	keyCacheMu.Lock()
+	defer keyCacheMu.Unlock()
+	return keyCache
+}
+
+// KeyFileFingerprint identifies key file contents without revealing them.
+func KeyFileFingerprint(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// InvalidateKey drops the remembered passphrase and cached key for
+// filePath, e.g. after the derived key failed to decrypt the store because
+// the passphrase was mistyped.
+func InvalidateKey(filePath string) {
+	passphraseMu.Lock()
+	delete(passphrases, passphraseCacheKey(filePath))
+	passphraseMu.Unlock()
+	forgetDerivedKey(filePath)
+
+	if cache := currentKeyCache(); cache != nil {
+		cache.Forget(passphraseCacheKey(filePath))
+	}
+}
+
+func cachedPassphraseKey(filePath string, data []byte) ([]byte, bool) {
+	cache := currentKeyCache()
+	if cache == nil {
+		return nil, false
+	}
+	key, ok := cache.Get(passphraseCacheKey(filePath), KeyFileFingerprint(data))
+	if !ok || len(key) != keySize {
+		return nil, false
+	}
+	return key, true
+}
+
+func cachePassphraseKey(filePath string, data, key []byte) {
+	if cache := currentKeyCache(); cache != nil {
+		cache.Put(passphraseCacheKey(filePath), KeyFileFingerprint(data), key)
+	}
+}
+
+func derivedKey(filePath string, data []byte, passphrase string) ([]byte, bool) {
+	derivedKeysMu.Lock()
+	defer derivedKeysMu.Unlock()
+	entry, ok := derivedKeys[passphraseCacheKey(filePath)]
+	if !ok || entry.fingerprint != KeyFileFingerprint(data) || entry.passphrase != passphrase {
+		return nil, false
+	}
+	return entry.key, true
+}
+
+func rememberDerivedKey(filePath string, data []byte, passphrase string, key []byte) {
+	derivedKeysMu.Lock()
+	defer derivedKeysMu.Unlock()
+	derivedKeys[passphraseCacheKey(filePath)] = derivedKeyEntry{
+		fingerprint: KeyFileFingerprint(data),
+		passphrase:  passphrase,
+		key:         key,
+	}
+}
+
+func forgetDerivedKey(filePath string) {
+	derivedKeysMu.Lock()
+	defer derivedKeysMu.Unlock()
+	delete(derivedKeys, passphraseCacheKey(filePath))
+}
+
+func forgetDerivedKeys() {
+	derivedKeysMu.Lock()
+	defer derivedKeysMu.Unlock()
+	derivedKeys = map[string]derivedKeyEntry{}
+}
@@ -0,0 +1,146 @@
+package cryptoutil
+
+import (
+	"bytes"
+	"testing"
+)
+
+type fakeKeyCache struct {
+	keys      map[string][]byte
+	forgotten []string
+}
+
+func (f *fakeKeyCache) Get(keyFilePath, fingerprint string) ([]byte, bool) {
+	key, ok := f.keys[keyFilePath+"|"+fingerprint]
+	return key, ok
+}
+
+func (f *fakeKeyCache) Put(keyFilePath, fingerprint string, key []byte) {
+	f.keys[keyFilePath+"|"+fingerprint] = key
+}
+
+func (f *fakeKeyCache) Forget(keyFilePath string) {
+	f.forgotten = append(f.forgotten, keyFilePath)
+}
+
+func TestLoadKeyUsesKeyCacheBeforePrompting(t *testing.T) {
+	keyFile, err := NewPassphraseKeyFile("cached", DefaultPBKDF2Params())
+	if err != nil {
+		t.Fatalf("NewPassphraseKeyFile failed: %v", err)
+	}
+	keyPath := writeKeyFile(t, keyFile.Data)
+	cache := &fakeKeyCache{keys: map[string][]byte{}}
+	SetKeyCache(cache)
+	t.Cleanup(func() { SetKeyCache(nil) })
+
+	t.Setenv(passphraseEnvVar, "cached")
+	if _, err := LoadKey(keyPath); err != nil {
+		t.Fatalf("LoadKey failed: %v", err)
+	}
+	if len(cache.keys) != 1 {
+		t.Fatalf("expected derived key to be cached, got %d entries", len(cache.keys))
+	}
+
+	// Without env var or prompt the cached key is the only way in.
+	t.Setenv(passphraseEnvVar, "")
+	calls := stubPassphrasePrompt(t, false, "")
+	key, err := LoadKey(keyPath)
+	if err != nil || !bytes.Equal(key, keyFile.Key) {
+		t.Fatalf("expected cached key, got %v", err)
+	}
+	if *calls != 0 {
+		t.Fatalf("expected no prompt, got %d", *calls)
+	}
+
+	InvalidateKey(keyPath)
+	if len(cache.forgotten) != 1 {
+		t.Fatalf("expected InvalidateKey to forget the cached key, got %v", cache.forgotten)
+	}
+}
+
+func TestKeyCacheMissesAfterKeyFileChanges(t *testing.T) {
+	first, err := NewPassphraseKeyFile("p", DefaultPBKDF2Params())
+	if err != nil {
+		t.Fatalf("NewPassphraseKeyFile failed: %v", err)
+	}
+	second, err := NewPassphraseKeyFile("p", DefaultPBKDF2Params())
+	if err != nil {
+		t.Fatalf("NewPassphraseKeyFile failed: %v", err)
+	}
+	if KeyFileFingerprint(first.Data) == KeyFileFingerprint(second.Data) {
+		t.Fatal("fresh salts must produce different fingerprints")
+	}
+}
+
+func countDerivations(t *testing.T) *int {
+	t.Helper()
+	calls := 0
+	previous := derivePassphraseKey
+	derivePassphraseKey = func(data []byte, passphrase string) ([]byte, error) {
+		calls++
+		return previous(data, passphrase)
+	}
+	t.Cleanup(func() {
+		derivePassphraseKey = previous
+		ForgetPassphrases()
+	})
+	return &calls
+}
+
+func TestLoadKeyDerivesOncePerProcess(t *testing.T) {
+	keyFile, err := NewPassphraseKeyFile("memo", DefaultPBKDF2Params())
+	if err != nil {
+		t.Fatalf("NewPassphraseKeyFile failed: %v", err)
+	}
+	keyPath := writeKeyFile(t, keyFile.Data)
+	calls := countDerivations(t)
+	t.Setenv(passphraseEnvVar, "memo")
+
+	for i := 0; i < 3; i++ {
+		key, err := LoadKey(keyPath)
+		if err != nil || !bytes.Equal(key, keyFile.Key) {
+			t.Fatalf("LoadKey #%d failed: %v", i, err)
+		}
+	}
+	if *calls != 1 {
+		t.Fatalf("expected one derivation, got %d", *calls)
+	}
+
+	// A different passphrase must not reuse the derived key.
+	t.Setenv(passphraseEnvVar, "other")
+	if key, err := LoadKey(keyPath); err != nil || bytes.Equal(key, keyFile.Key) {
+		t.Fatalf("expected a fresh derivation for another passphrase, got %v", err)
+	}
+	if *calls != 2 {
+		t.Fatalf("expected a second derivation, got %d", *calls)
+	}
+
+	InvalidateKey(keyPath)
+	t.Setenv(passphraseEnvVar, "memo")
+	if _, err := LoadKey(keyPath); err != nil {
+		t.Fatalf("LoadKey after InvalidateKey failed: %v", err)
+	}
+	if *calls != 3 {
+		t.Fatalf("expected InvalidateKey to drop the derived key, got %d derivations", *calls)
+	}
+}
+
+func TestRememberKeyFileKeepsDerivedKey(t *testing.T) {
+	keyFile, err := NewPassphraseKeyFile("fresh", DefaultPBKDF2Params())
+	if err != nil {
+		t.Fatalf("NewPassphraseKeyFile failed: %v", err)
+	}
+	keyPath := writeKeyFile(t, keyFile.Data)
+	calls := countDerivations(t)
+	t.Setenv(passphraseEnvVar, "")
+	stubPassphrasePrompt(t, false, "")
+
+	RememberKeyFile(keyPath, keyFile)
+	key, err := LoadKey(keyPath)
+	if err != nil || !bytes.Equal(key, keyFile.Key) {
+		t.Fatalf("LoadKey failed: %v", err)
+	}
+	if *calls != 0 {
+		t.Fatalf("expected no derivation after RememberKeyFile, got %d", *calls)
+	}
+}
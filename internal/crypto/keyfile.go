@@ -0,0 +1,172 @@
+package cryptoutil
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/emirhangumus/sshmanager/internal/storage"
+)
+
+const (
+	KeyFileModeRaw        = "raw"
+	KeyFileModePassphrase = passphraseKeyFileMode
+
+	// PassphraseEnvVar holds the master passphrase for passphrase key files.
+	PassphraseEnvVar = passphraseEnvVar
+
+	// MinPassphraseIterations is the lowest iteration count accepted when
+	// generating a passphrase key file.
+	MinPassphraseIterations = 100_000
+)
+
+// KeyFile is a freshly generated encryption key together with the key file
+// contents that reproduce it.
+type KeyFile struct {
+	Key  []byte
+	Data []byte
+	// Params is the derivation used for passphrase keys.
+	Params KDFParams
+
+	passphrase string
+}
+
+// NewRawKeyFile generates a random key stored as 32 raw bytes.
+func NewRawKeyFile() (KeyFile, error) {
+	key, err := generateKey()
+	if err != nil {
+		return KeyFile{}, fmt.Errorf("failed to generate key: %w", err)
+	}
+	return KeyFile{Key: key, Data: append([]byte(nil), key...)}, nil
+}
+
+// NewPassphraseKeyFile derives a key from passphrase with a fresh random
+// salt. Only the salt and KDF parameters end up in Data, which is always
+// written in the version 2 format.
+func NewPassphraseKeyFile(passphrase string, params KDFParams) (KeyFile, error) {
+	if passphrase == "" {
+		return KeyFile{}, fmt.Errorf("passphrase must not be empty")
+	}
+	if err := params.Validate(); err != nil {
+		return KeyFile{}, err
+	}
+
+	salt := make([]byte, passphraseSalt)
+	if _, err := rand.Read(salt); err != nil {
+		return KeyFile{}, fmt.Errorf("failed to generate passphrase salt: %w", err)
+	}
+
+	key, err := params.derive(passphrase, salt)
+	if err != nil {
+		return KeyFile{}, err
+	}
+
+	meta := passphraseKeyFile{
+		Version: passphraseKeyFileV2,
+		Mode:    passphraseKeyFileMode,
+		KDF:     params.KDF,
+		Salt:    base64.StdEncoding.EncodeToString(salt),
+	}
+	if params.KDF == KDFArgon2id {
+		meta.MemoryKiB, meta.Time, meta.Threads = params.MemoryKiB, params.Time, params.Threads
+	} else {
+		meta.Iterations = params.Iterations
+	}
+	data, err := json.Marshal(meta)
+	if err != nil {
+		return KeyFile{}, fmt.Errorf("failed to encode passphrase key metadata: %w", err)
+	}
+	return KeyFile{Key: key, Data: data, Params: params, passphrase: passphrase}, nil
+}
+
+// WriteKeyFile stores keyFile at filePath, creating its directory. The file
+// is replaced atomically so a failed write never truncates the vault key.
+func WriteKeyFile(filePath string, keyFile KeyFile) error {
+	if err := storage.WriteFileAtomic(filePath, keyFile.Data, 0o600); err != nil {
+		return fmt.Errorf("failed to write key file: %w", err)
+	}
+	return nil
+}
+
+// IsPassphrase reports whether the key is derived from a passphrase.
+func (k KeyFile) IsPassphrase() bool {
+	return k.passphrase != ""
+}
+
+// Derive loads the key described by key file contents data the way LoadKey
+// would, using k's passphrase for passphrase key files. It is used to check
+// that written key material reproduces k.Key.
+func (k KeyFile) Derive(data []byte) ([]byte, error) {
+	var key []byte
+	if len(data) == keySize {
+		key = append([]byte(nil), data...)
+	} else {
+		derived, err := derivePassphraseKeyFile(data, k.passphrase)
+		if err != nil {
+			return nil, err
+		}
+		key = derived
+	}
+	if !bytes.Equal(key, k.Key) {
+		return nil, fmt.Errorf("key file does not reproduce the generated key")
+	}
+	return key, nil
+}
+
+// KeyFileInfo describes a key file without deriving its key.
+type KeyFileInfo struct {
+	// Mode is "raw", "passphrase" or "identity".
+	Mode string
+	// Version is the passphrase metadata version; zero for raw keys.
+	Version int
+	KDF     KDFParams
+	// Recipient is the public key of an identity key file.
+	Recipient string
+}
+
+// InspectKeyFile reports the mode and KDF parameters of the key file at
+// filePath.
+func InspectKeyFile(filePath string) (KeyFileInfo, error) {
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return KeyFileInfo{}, fmt.Errorf("failed to read key file: %w", err)
+	}
+	if len(data) == keySize {
+		return KeyFileInfo{Mode: KeyFileModeRaw}, nil
+	}
+	if IsIdentityFile(data) {
+		id, err := ParseIdentity(data)
+		if err != nil {
+			return KeyFileInfo{}, err
+		}
+		return KeyFileInfo{Mode: KeyFileModeIdentity, Recipient: id.Recipient()}, nil
+	}
+	meta, err := parsePassphraseKeyFile(data)
+	if err != nil {
+		return KeyFileInfo{}, err
+	}
+	return KeyFileInfo{Mode: meta.Mode, Version: meta.Version, KDF: meta.params()}, nil
+}
+
+// KDFName returns how the key is obtained: "raw" or the passphrase KDF.
+func (k KeyFile) KDFName() string {
+	if !k.IsPassphrase() {
+		return KDFRaw
+	}
+	return k.Params.KDF
+}
+
+// KDFName returns how the key is obtained: "raw", "x25519" for identities
+// or the passphrase KDF.
+func (i KeyFileInfo) KDFName() string {
+	switch i.Mode {
+	case KeyFileModeRaw:
+		return KDFRaw
+	case KeyFileModeIdentity:
+		return KDFX25519
+	}
+	return i.KDF.KDF
+}
@@ -0,0 +1,121 @@
+package cryptoutil
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"golang.org/x/term"
+)
+
+// ErrPassphraseRequired is returned when a passphrase key file needs a
+// passphrase and none is available without prompting.
+var ErrPassphraseRequired = errors.New("passphrase key file detected")
+
+// PassphrasePrompt asks for the passphrase of the key file at keyFilePath
+// without echoing it.
+type PassphrasePrompt func(keyFilePath string) (string, error)
+
+var (
+	passphraseMu     sync.Mutex
+	passphrasePrompt PassphrasePrompt
+	// passphrases remembers prompted passphrases per key file so one run
+	// asks at most once.
+	passphrases = map[string]string{}
+
+	stdinIsTerminal = func() bool { return term.IsTerminal(int(os.Stdin.Fd())) }
+)
+
+// SetPassphrasePrompt installs the prompt LoadKey falls back to when
+// SSHMANAGER_MASTER_PASSPHRASE is unset and stdin is a terminal. A nil
+// prompt disables prompting.
+func SetPassphrasePrompt(prompt PassphrasePrompt) {
+	passphraseMu.Lock()
+	defer passphraseMu.Unlock()
+	passphrasePrompt = prompt
+}
+
+// CanPrompt reports whether a passphrase can be asked for interactively.
+func CanPrompt() bool {
+	passphraseMu.Lock()
+	defer passphraseMu.Unlock()
+	return passphrasePrompt != nil && stdinIsTerminal()
+}
+
+// MasterPassphrase returns the passphrase for the key file at filePath:
+// SSHMANAGER_MASTER_PASSPHRASE when set, then a passphrase entered earlier
+// in this process, then a masked prompt when stdin is a terminal.
+func MasterPassphrase(filePath string) (string, error) {
+	if passphrase := strings.TrimSpace(os.Getenv(passphraseEnvVar)); passphrase != "" {
+		return passphrase, nil
+	}
+
+	passphraseMu.Lock()
+	defer passphraseMu.Unlock()
+
+	cacheKey := passphraseCacheKey(filePath)
+	if passphrase, ok := passphrases[cacheKey]; ok {
+		return passphrase, nil
+	}
+	if passphrasePrompt == nil || !stdinIsTerminal() {
+		return "", fmt.Errorf("%w; set %s or run in a terminal to be prompted", ErrPassphraseRequired, passphraseEnvVar)
+	}
+
+	passphrase, err := passphrasePrompt(filePath)
+	if err != nil {
+		return "", err
+	}
+	passphrase = strings.TrimSpace(passphrase)
+	if passphrase == "" {
+		return "", errors.New("master passphrase must not be empty")
+	}
+	passphrases[cacheKey] = passphrase
+	return passphrase, nil
+}
+
+// RememberPassphrase makes passphrase available to later LoadKey calls for
+// filePath in this process, e.g. right after creating the key file.
+func RememberPassphrase(filePath, passphrase string) {
+	passphraseMu.Lock()
+	defer passphraseMu.Unlock()
+	passphrases[passphraseCacheKey(filePath)] = passphrase
+}
+
+// RememberKeyFile updates the remembered passphrase for filePath after
+// keyFile was written there: passphrase keys remember theirs, raw keys
+// clear any stale entry. Passphrase keys also keep their derived key, so
+// the next LoadKey does not run the KDF again.
+func RememberKeyFile(filePath string, keyFile KeyFile) {
+	if keyFile.IsPassphrase() {
+		rememberDerivedKey(filePath, keyFile.Data, keyFile.passphrase, keyFile.Key)
+	} else {
+		forgetDerivedKey(filePath)
+	}
+
+	passphraseMu.Lock()
+	defer passphraseMu.Unlock()
+	if keyFile.IsPassphrase() {
+		passphrases[passphraseCacheKey(filePath)] = keyFile.passphrase
+		return
+	}
+	delete(passphrases, passphraseCacheKey(filePath))
+}
+
+// ForgetPassphrases drops every remembered passphrase and derived key.
+func ForgetPassphrases() {
+	forgetDerivedKeys()
+
+	passphraseMu.Lock()
+	defer passphraseMu.Unlock()
+	passphrases = map[string]string{}
+}
+
+func passphraseCacheKey(filePath string) string {
+	if abs, err := filepath.Abs(filePath); err == nil {
+		return abs
+	}
+	return filePath
+}
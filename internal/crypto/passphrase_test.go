@@ -0,0 +1,84 @@
+package cryptoutil
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func stubPassphrasePrompt(t *testing.T, terminal bool, answer string) *int {
+	t.Helper()
+	calls := 0
+	previousTerminal := stdinIsTerminal
+	stdinIsTerminal = func() bool { return terminal }
+	SetPassphrasePrompt(func(string) (string, error) {
+		calls++
+		return answer, nil
+	})
+	t.Cleanup(func() {
+		stdinIsTerminal = previousTerminal
+		SetPassphrasePrompt(nil)
+		ForgetPassphrases()
+	})
+	return &calls
+}
+
+func TestLoadKeyPromptsOnceOnTerminal(t *testing.T) {
+	t.Setenv(passphraseEnvVar, "")
+	keyFile, err := NewPassphraseKeyFile("typed", DefaultPBKDF2Params())
+	if err != nil {
+		t.Fatalf("NewPassphraseKeyFile failed: %v", err)
+	}
+	keyPath := writeKeyFile(t, keyFile.Data)
+	calls := stubPassphrasePrompt(t, true, " typed ")
+
+	for i := 0; i < 2; i++ {
+		key, err := LoadKey(keyPath)
+		if err != nil {
+			t.Fatalf("LoadKey failed: %v", err)
+		}
+		if !bytes.Equal(key, keyFile.Key) {
+			t.Fatal("prompted passphrase derived a different key")
+		}
+	}
+	if *calls != 1 {
+		t.Fatalf("expected one prompt, got %d", *calls)
+	}
+}
+
+func TestLoadKeyWithoutTerminalFailsClearly(t *testing.T) {
+	t.Setenv(passphraseEnvVar, "")
+	keyFile, err := NewPassphraseKeyFile("secret", DefaultPBKDF2Params())
+	if err != nil {
+		t.Fatalf("NewPassphraseKeyFile failed: %v", err)
+	}
+	keyPath := writeKeyFile(t, keyFile.Data)
+	calls := stubPassphrasePrompt(t, false, "secret")
+
+	_, err = LoadKey(keyPath)
+	if !errors.Is(err, ErrPassphraseRequired) || !strings.Contains(err.Error(), passphraseEnvVar) {
+		t.Fatalf("expected ErrPassphraseRequired mentioning %s, got %v", passphraseEnvVar, err)
+	}
+	if *calls != 0 {
+		t.Fatalf("prompt must not run without a terminal, got %d calls", *calls)
+	}
+}
+
+func TestLoadKeyPrefersEnvVarOverPrompt(t *testing.T) {
+	keyFile, err := NewPassphraseKeyFile("from-env", DefaultPBKDF2Params())
+	if err != nil {
+		t.Fatalf("NewPassphraseKeyFile failed: %v", err)
+	}
+	keyPath := writeKeyFile(t, keyFile.Data)
+	calls := stubPassphrasePrompt(t, true, "wrong")
+	t.Setenv(passphraseEnvVar, "from-env")
+
+	key, err := LoadKey(keyPath)
+	if err != nil || !bytes.Equal(key, keyFile.Key) {
+		t.Fatalf("LoadKey = %v, want env-derived key", err)
+	}
+	if *calls != 0 {
+		t.Fatalf("expected no prompt, got %d", *calls)
+	}
+}
@@ -0,0 +1,343 @@
+package cryptoutil
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/ecdh"
+	"crypto/hkdf"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/emirhangumus/sshmanager/internal/storage"
+	"golang.org/x/crypto/chacha20poly1305"
+)
+
+// Team envelopes share one data key between several members. The data key
+// is wrapped for each member's X25519 public key the way age wraps its file
+// key, so age-keygen identities and age1... recipients can be used as is.
+//
+//	magic "SSHT" | version (1) | header length (4, big endian) | header JSON | nonce (12) | ciphertext
+//
+// Everything before the ciphertext is bound as AES-GCM additional data.
+const (
+	// KeyFileModeIdentity marks a key file holding a team member identity.
+	KeyFileModeIdentity = "identity"
+	// KDFX25519 names identity-derived keys in envelope headers.
+	KDFX25519 = "x25519"
+
+	TeamEnvelopeVersion1 = 1
+
+	teamEnvelopeMagic   = "SSHT"
+	teamMaxHeaderLength = 1 << 20
+
+	recipientHRP = "age"
+	identityHRP  = "AGE-SECRET-KEY-"
+
+	x25519Label   = "age-encryption.org/v1/X25519"
+	localKeyLabel = "sshmanager/v1/local-key"
+)
+
+var (
+	// ErrNotTeamMember means none of the wrapped keys is for the identity.
+	ErrNotTeamMember = errors.New("this identity is not a member of the team vault")
+	// ErrNotTeamEnvelope means the data is not a team envelope.
+	ErrNotTeamEnvelope = errors.New("not a team vault file")
+)
+
+// Identity is a team member's X25519 private key.
+type Identity struct {
+	key *ecdh.PrivateKey
+}
+
+// TeamMember is one member's copy of the wrapped data key.
+type TeamMember struct {
+	Name      string `json:"name"`
+	Recipient string `json:"recipient"`
+	Ephemeral string `json:"ephemeral"`
+	Wrapped   string `json:"wrappedKey"`
+}
+
+type teamHeader struct {
+	Members []TeamMember `json:"members"`
+}
+
+// GenerateIdentity creates a new random identity.
+func GenerateIdentity() (Identity, error) {
+	key, err := ecdh.X25519().GenerateKey(rand.Reader)
+	if err != nil {
+		return Identity{}, fmt.Errorf("failed to generate identity: %w", err)
+	}
+	return Identity{key: key}, nil
+}
+
+// ParseIdentity reads an AGE-SECRET-KEY-1... identity. Blank lines and
+// '#' comments, as written by age-keygen, are ignored.
+func ParseIdentity(data []byte) (Identity, error) {
+	var found []string
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		found = append(found, line)
+	}
+	if len(found) != 1 {
+		return Identity{}, fmt.Errorf("expected exactly one identity, found %d", len(found))
+	}
+
+	hrp, raw, err := bech32Decode(found[0])
+	if err != nil {
+		return Identity{}, fmt.Errorf("invalid identity: %w", err)
+	}
+	if hrp != strings.ToLower(identityHRP) {
+		return Identity{}, fmt.Errorf("invalid identity type %q", hrp)
+	}
+	key, err := ecdh.X25519().NewPrivateKey(raw)
+	if err != nil {
+		return Identity{}, fmt.Errorf("invalid identity: %w", err)
+	}
+	return Identity{key: key}, nil
+}
+
+// IsIdentityFile reports whether key file contents hold an identity.
+func IsIdentityFile(data []byte) bool {
+	return bytes.Contains(bytes.ToUpper(data), []byte(identityHRP+"1"))
+}
+
+// LoadIdentity reads the identity stored in the key file at filePath.
+func LoadIdentity(filePath string) (Identity, error) {
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return Identity{}, fmt.Errorf("failed to read key file: %w", err)
+	}
+	return ParseIdentity(data)
+}
+
+// WriteIdentityFile stores id at filePath in the age-keygen format,
+// replacing any previous file atomically.
+func WriteIdentityFile(filePath string, id Identity) error {
+	content := fmt.Sprintf("# created: %s\n# public key: %s\n%s\n", time.Now().UTC().Format(time.RFC3339), id.Recipient(), id.String())
+	if err := storage.WriteFileAtomic(filePath, []byte(content), 0o600); err != nil {
+		return fmt.Errorf("failed to write key file: %w", err)
+	}
+	return nil
+}
+
+// String returns the AGE-SECRET-KEY-1... encoding of the identity.
+func (id Identity) String() string {
+	encoded, _ := bech32Encode(identityHRP, id.key.Bytes())
+	return strings.ToUpper(encoded)
+}
+
+// Recipient returns the age1... public key of the identity.
+func (id Identity) Recipient() string {
+	encoded, _ := bech32Encode(recipientHRP, id.key.PublicKey().Bytes())
+	return encoded
+}
+
+// LocalKey derives the key for files that stay on this machine, such as
+// the connection history of a team vault.
+func (id Identity) LocalKey() ([]byte, error) {
+	return hkdf.Key(sha256.New, id.key.Bytes(), nil, localKeyLabel, keySize)
+}
+
+// ParseRecipient validates an age1... X25519 recipient.
+func ParseRecipient(recipient string) (*ecdh.PublicKey, error) {
+	hrp, raw, err := bech32Decode(strings.TrimSpace(recipient))
+	if err != nil {
+		return nil, fmt.Errorf("invalid recipient %q: %w", recipient, err)
+	}
+	if hrp != recipientHRP {
+		return nil, fmt.Errorf("invalid recipient %q: expected an age1... X25519 public key", recipient)
+	}
+	key, err := ecdh.X25519().NewPublicKey(raw)
+	if err != nil {
+		return nil, fmt.Errorf("invalid recipient %q: %w", recipient, err)
+	}
+	return key, nil
+}
+
+// NewTeamDataKey returns a random data key for a team envelope.
+func NewTeamDataKey() ([]byte, error) {
+	return generateKey()
+}
+
+// WrapTeamKey wraps dataKey for recipient.
+func WrapTeamKey(dataKey []byte, name, recipient string) (TeamMember, error) {
+	publicKey, err := ParseRecipient(recipient)
+	if err != nil {
+		return TeamMember{}, err
+	}
+	ephemeral, err := ecdh.X25519().GenerateKey(rand.Reader)
+	if err != nil {
+		return TeamMember{}, err
+	}
+	shared, err := ephemeral.ECDH(publicKey)
+	if err != nil {
+		return TeamMember{}, err
+	}
+
+	wrapKey, err := x25519WrapKey(shared, ephemeral.PublicKey().Bytes(), publicKey.Bytes())
+	if err != nil {
+		return TeamMember{}, err
+	}
+	aead, err := chacha20poly1305.New(wrapKey)
+	if err != nil {
+		return TeamMember{}, err
+	}
+	wrapped := aead.Seal(nil, make([]byte, chacha20poly1305.NonceSize), dataKey, nil)
+
+	return TeamMember{
+		Name:      name,
+		Recipient: strings.TrimSpace(recipient),
+		Ephemeral: base64.RawStdEncoding.EncodeToString(ephemeral.PublicKey().Bytes()),
+		Wrapped:   base64.RawStdEncoding.EncodeToString(wrapped),
+	}, nil
+}
+
+// UnwrapTeamKey returns the data key wrapped for id.
+func (id Identity) UnwrapTeamKey(members []TeamMember) ([]byte, error) {
+	recipient := id.Recipient()
+	for _, member := range members {
+		if member.Recipient != recipient {
+			continue
+		}
+		ephemeral, err := base64.RawStdEncoding.DecodeString(member.Ephemeral)
+		if err != nil {
+			return nil, fmt.Errorf("invalid wrapped key for %s: %w", member.Name, err)
+		}
+		wrapped, err := base64.RawStdEncoding.DecodeString(member.Wrapped)
+		if err != nil {
+			return nil, fmt.Errorf("invalid wrapped key for %s: %w", member.Name, err)
+		}
+		ephemeralKey, err := ecdh.X25519().NewPublicKey(ephemeral)
+		if err != nil {
+			return nil, fmt.Errorf("invalid wrapped key for %s: %w", member.Name, err)
+		}
+		shared, err := id.key.ECDH(ephemeralKey)
+		if err != nil {
+			return nil, err
+		}
+		wrapKey, err := x25519WrapKey(shared, ephemeral, id.key.PublicKey().Bytes())
+		if err != nil {
+			return nil, err
+		}
+		aead, err := chacha20poly1305.New(wrapKey)
+		if err != nil {
+			return nil, err
+		}
+		dataKey, err := aead.Open(nil, make([]byte, chacha20poly1305.NonceSize), wrapped, nil)
+		if err != nil {
+			return nil, fmt.Errorf("%w: wrapped key for %s", ErrDecryptFailed, member.Name)
+		}
+		return dataKey, nil
+	}
+	return nil, ErrNotTeamMember
+}
+
+func x25519WrapKey(shared, ephemeral, recipient []byte) ([]byte, error) {
+	if bytes.Equal(shared, make([]byte, len(shared))) {
+		return nil, errors.New("invalid X25519 shared secret")
+	}
+	salt := append(append([]byte(nil), ephemeral...), recipient...)
+	return hkdf.Key(sha256.New, shared, salt, x25519Label, chacha20poly1305.KeySize)
+}
+
+// SealTeamEnvelope encrypts plaintext with dataKey and stores the members'
+// wrapped copies of it in the authenticated header.
+func SealTeamEnvelope(plaintext string, dataKey []byte, members []TeamMember) ([]byte, error) {
+	if len(members) == 0 {
+		return nil, errors.New("a team vault needs at least one member")
+	}
+	aead, err := newGCM(dataKey)
+	if err != nil {
+		return nil, err
+	}
+	header, err := json.Marshal(teamHeader{Members: members})
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode team header: %w", err)
+	}
+	nonce := make([]byte, nonceSize)
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+
+	out := make([]byte, 0, len(teamEnvelopeMagic)+5+len(header)+nonceSize+len(plaintext)+aead.Overhead())
+	out = append(out, teamEnvelopeMagic...)
+	out = append(out, TeamEnvelopeVersion1)
+	out = binary.BigEndian.AppendUint32(out, uint32(len(header)))
+	out = append(out, header...)
+	out = append(out, nonce...)
+	return aead.Seal(out, nonce, []byte(plaintext), out), nil
+}
+
+// OpenTeamEnvelope decrypts a team envelope with the data key wrapped for
+// id and returns the plaintext, the members and the data key.
+func OpenTeamEnvelope(data []byte, id Identity) (string, []TeamMember, []byte, error) {
+	members, bodyOffset, err := parseTeamEnvelope(data)
+	if err != nil {
+		return "", nil, nil, err
+	}
+	dataKey, err := id.UnwrapTeamKey(members)
+	if err != nil {
+		return "", members, nil, err
+	}
+	aead, err := newGCM(dataKey)
+	if err != nil {
+		return "", members, nil, err
+	}
+	nonce := data[bodyOffset-nonceSize : bodyOffset]
+	plaintext, err := aead.Open(nil, nonce, data[bodyOffset:], data[:bodyOffset])
+	if err != nil {
+		return "", members, nil, fmt.Errorf("%w: %w", ErrDecryptFailed, err)
+	}
+	return string(plaintext), members, dataKey, nil
+}
+
+// InspectTeamEnvelope returns the members listed in a team envelope without
+// decrypting it. The list is only authenticated by OpenTeamEnvelope.
+func InspectTeamEnvelope(data []byte) ([]TeamMember, error) {
+	members, _, err := parseTeamEnvelope(data)
+	return members, err
+}
+
+// IsTeamEnvelope reports whether data starts with the team envelope magic.
+func IsTeamEnvelope(data []byte) bool {
+	return bytes.HasPrefix(data, []byte(teamEnvelopeMagic))
+}
+
+func parseTeamEnvelope(data []byte) ([]TeamMember, int, error) {
+	if !IsTeamEnvelope(data) {
+		return nil, 0, ErrNotTeamEnvelope
+	}
+	offset := len(teamEnvelopeMagic)
+	if len(data) < offset+5 {
+		return nil, 0, errors.New("team vault file is truncated")
+	}
+	if version := int(data[offset]); version != TeamEnvelopeVersion1 {
+		return nil, 0, fmt.Errorf("unsupported team vault version %d", version)
+	}
+	headerLen := int(binary.BigEndian.Uint32(data[offset+1 : offset+5]))
+	offset += 5
+	if headerLen > teamMaxHeaderLength || len(data) < offset+headerLen+nonceSize {
+		return nil, 0, errors.New("team vault file is truncated")
+	}
+
+	var header teamHeader
+	if err := json.Unmarshal(data[offset:offset+headerLen], &header); err != nil {
+		return nil, 0, fmt.Errorf("invalid team vault header: %w", err)
+	}
+	if len(header.Members) == 0 {
+		return nil, 0, errors.New("team vault header lists no members")
+	}
+	return header.Members, offset + headerLen + nonceSize, nil
+}
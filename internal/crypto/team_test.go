@@ -0,0 +1,166 @@
+package cryptoutil
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestIdentityEncodingRoundTrip(t *testing.T) {
+	id, err := GenerateIdentity()
+	if err != nil {
+		t.Fatalf("GenerateIdentity returned error: %v", err)
+	}
+	if !strings.HasPrefix(id.String(), "AGE-SECRET-KEY-1") || !strings.HasPrefix(id.Recipient(), "age1") {
+		t.Fatalf("unexpected encodings: %s / %s", id.String(), id.Recipient())
+	}
+
+	path := filepath.Join(t.TempDir(), "secret.key")
+	if err := WriteIdentityFile(path, id); err != nil {
+		t.Fatalf("WriteIdentityFile returned error: %v", err)
+	}
+	loaded, err := LoadIdentity(path)
+	if err != nil {
+		t.Fatalf("LoadIdentity returned error: %v", err)
+	}
+	if loaded.Recipient() != id.Recipient() {
+		t.Fatalf("recipient changed after reload: %s != %s", loaded.Recipient(), id.Recipient())
+	}
+
+	info, err := InspectKeyFile(path)
+	if err != nil {
+		t.Fatalf("InspectKeyFile returned error: %v", err)
+	}
+	if info.Mode != KeyFileModeIdentity || info.Recipient != id.Recipient() || info.KDFName() != KDFX25519 {
+		t.Fatalf("unexpected key file info: %+v", info)
+	}
+	localKey, err := LoadKey(path)
+	if err != nil || len(localKey) != keySize {
+		t.Fatalf("LoadKey on identity file: key len %d, err %v", len(localKey), err)
+	}
+}
+
+func TestParseIdentityRejectsInvalidInput(t *testing.T) {
+	id, err := GenerateIdentity()
+	if err != nil {
+		t.Fatalf("GenerateIdentity returned error: %v", err)
+	}
+	encoded := id.String()
+	corrupted := encoded[:len(encoded)-1] + "Q"
+	if encoded[len(encoded)-1] == 'Q' {
+		corrupted = encoded[:len(encoded)-1] + "P"
+	}
+
+	for name, input := range map[string]string{
+		"empty":     "# only a comment\n",
+		"two keys":  encoded + "\n" + encoded + "\n",
+		"checksum":  corrupted,
+		"recipient": id.Recipient(),
+	} {
+		if _, err := ParseIdentity([]byte(input)); err == nil {
+			t.Fatalf("%s: expected error", name)
+		}
+	}
+	if _, err := ParseRecipient(strings.ToUpper(id.String())); err == nil {
+		t.Fatal("expected identity to be rejected as recipient")
+	}
+}
+
+func TestTeamEnvelopeMembersCanOpen(t *testing.T) {
+	alice, _ := GenerateIdentity()
+	bob, _ := GenerateIdentity()
+	mallory, _ := GenerateIdentity()
+
+	dataKey, err := NewTeamDataKey()
+	if err != nil {
+		t.Fatalf("NewTeamDataKey returned error: %v", err)
+	}
+	var members []TeamMember
+	for name, id := range map[string]Identity{"alice": alice, "bob": bob} {
+		member, err := WrapTeamKey(dataKey, name, id.Recipient())
+		if err != nil {
+			t.Fatalf("WrapTeamKey returned error: %v", err)
+		}
+		members = append(members, member)
+	}
+
+	sealed, err := SealTeamEnvelope("shared", dataKey, members)
+	if err != nil {
+		t.Fatalf("SealTeamEnvelope returned error: %v", err)
+	}
+	for _, id := range []Identity{alice, bob} {
+		plain, got, _, err := OpenTeamEnvelope(sealed, id)
+		if err != nil || plain != "shared" || len(got) != 2 {
+			t.Fatalf("member could not open envelope: %q %d %v", plain, len(got), err)
+		}
+	}
+	if _, _, _, err := OpenTeamEnvelope(sealed, mallory); !errors.Is(err, ErrNotTeamMember) {
+		t.Fatalf("expected ErrNotTeamMember, got %v", err)
+	}
+
+	header, err := InspectEnvelope(sealed)
+	if err != nil || len(header.Members) != 2 || header.KDF != KDFX25519 {
+		t.Fatalf("unexpected inspected header: %+v %v", header, err)
+	}
+}
+
+func TestTeamEnvelopeAuthenticatesMemberList(t *testing.T) {
+	alice, _ := GenerateIdentity()
+	dataKey, _ := NewTeamDataKey()
+	member, err := WrapTeamKey(dataKey, "alice", alice.Recipient())
+	if err != nil {
+		t.Fatalf("WrapTeamKey returned error: %v", err)
+	}
+	sealed, err := SealTeamEnvelope("shared", dataKey, []TeamMember{member})
+	if err != nil {
+		t.Fatalf("SealTeamEnvelope returned error: %v", err)
+	}
+
+	tampered := []byte(strings.Replace(string(sealed), `"name":"alice"`, `"name":"alicf"`, 1))
+	if _, _, _, err := OpenTeamEnvelope(tampered, alice); !errors.Is(err, ErrDecryptFailed) {
+		t.Fatalf("expected ErrDecryptFailed for tampered header, got %v", err)
+	}
+}
+
+func TestLoadIdentityReadsAgeKeygenOutput(t *testing.T) {
+	id, _ := GenerateIdentity()
+	path := filepath.Join(t.TempDir(), "key.txt")
+	content := "# created: 2026-01-01T00:00:00Z\n# public key: " + id.Recipient() + "\n\n" + id.String() + "\n"
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+	loaded, err := LoadIdentity(path)
+	if err != nil {
+		t.Fatalf("LoadIdentity returned error: %v", err)
+	}
+	if loaded.Recipient() != id.Recipient() {
+		t.Fatal("loaded identity does not match")
+	}
+}
+
+func TestBech32MatchesBIP173Vectors(t *testing.T) {
+	for _, valid := range []string{
+		"A12UEL5L",
+		"abcdef1qpzry9x8gf2tvdw0s3jn54khce6mua7lmqqqxw",
+		"split1checkupstagehandshakeupstreamerranterredcaperred2y9e3w",
+	} {
+		hrp, data, err := bech32Decode(valid)
+		if err != nil {
+			t.Fatalf("bech32Decode(%q) returned error: %v", valid, err)
+		}
+		encoded, err := bech32Encode(hrp, data)
+		if err != nil {
+			t.Fatalf("bech32Encode returned error: %v", err)
+		}
+		if encoded != strings.ToLower(valid) {
+			t.Fatalf("re-encoding mismatch: got %q, want %q", encoded, strings.ToLower(valid))
+		}
+	}
+	for _, invalid := range []string{"A1G7SGD8", "10a06t8", "1qzzfhee", "abcdef1qpzry9x8gf2tvdw0s3jn54khce6mua7lmqqqxX"} {
+		if _, _, err := bech32Decode(invalid); err == nil {
+			t.Fatalf("expected bech32Decode(%q) to fail", invalid)
+		}
+	}
+}
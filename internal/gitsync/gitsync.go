@@ -0,0 +1,299 @@
+// Package gitsync keeps a vault's encrypted connection file in a git
+// repository so it can be pulled from and pushed to a shared remote.
+//
+// The repository lives in <vault>/sync and only ever tracks a copy of the
+// encrypted connection file; the key file never enters it. Merging happens
+// on decrypted data in the caller, git only transports the ciphertext.
+package gitsync
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/emirhangumus/sshmanager/internal/storage"
+)
+
+const (
+	DirName       = "sync"
+	FileName      = "conn"
+	RemoteName    = "origin"
+	DefaultBranch = "main"
+)
+
+// ErrNotConfigured means the vault has no sync repository.
+var ErrNotConfigured = errors.New("sync is not set up for this vault (run 'sshmanager sync init <repository>')")
+
+// Repo is the sync repository of one vault.
+type Repo struct {
+	Dir string
+}
+
+// RepoDir returns the sync repository directory of the vault in vaultDir.
+func RepoDir(vaultDir string) string {
+	return filepath.Join(vaultDir, DirName)
+}
+
+// Open returns the sync repository of the vault in vaultDir, or
+// ErrNotConfigured.
+func Open(vaultDir string) (Repo, error) {
+	repo := Repo{Dir: RepoDir(vaultDir)}
+	if _, err := os.Stat(filepath.Join(repo.Dir, ".git")); err != nil {
+		if os.IsNotExist(err) {
+			return Repo{}, ErrNotConfigured
+		}
+		return Repo{}, err
+	}
+	return repo, nil
+}
+
+// Init creates the sync repository of the vault in vaultDir with remote as
+// origin. branch is the local and remote branch to sync.
+func Init(vaultDir, remote, branch string) (Repo, error) {
+	remote = strings.TrimSpace(remote)
+	if remote == "" {
+		return Repo{}, errors.New("missing sync repository")
+	}
+	if branch = strings.TrimSpace(branch); branch == "" {
+		branch = DefaultBranch
+	}
+	if existing, err := Open(vaultDir); err == nil {
+		url, _ := existing.RemoteURL()
+		return Repo{}, fmt.Errorf("sync is already set up for this vault (remote %s)", url)
+	} else if !errors.Is(err, ErrNotConfigured) {
+		return Repo{}, err
+	}
+	if _, err := exec.LookPath("git"); err != nil {
+		return Repo{}, errors.New("git executable not found in PATH")
+	}
+
+	repo := Repo{Dir: RepoDir(vaultDir)}
+	if err := os.MkdirAll(repo.Dir, 0o700); err != nil {
+		return Repo{}, fmt.Errorf("failed to create sync repository: %w", err)
+	}
+	if _, err := repo.git("init", "-q", "-b", branch); err != nil {
+		_ = os.RemoveAll(repo.Dir)
+		return Repo{}, err
+	}
+	if _, err := repo.git("remote", "add", RemoteName, remote); err != nil {
+		_ = os.RemoveAll(repo.Dir)
+		return Repo{}, err
+	}
+	// Commits are made from the save hook, which must never stop to ask for
+	// a signing passphrase, and must work without a global git identity.
+	if _, err := repo.git("config", "commit.gpgsign", "false"); err != nil {
+		return Repo{}, err
+	}
+	if email, _ := repo.git("config", "user.email"); email == "" {
+		host, _ := os.Hostname()
+		if host == "" {
+			host = "localhost"
+		}
+		if _, err := repo.git("config", "user.name", "sshmanager"); err != nil {
+			return Repo{}, err
+		}
+		if _, err := repo.git("config", "user.email", "sshmanager@"+host); err != nil {
+			return Repo{}, err
+		}
+	}
+	return repo, nil
+}
+
+// CommitConnectionFile commits the connection file at connectionFilePath to
+// its vault's sync repository. It does nothing when sync is not set up.
+func CommitConnectionFile(connectionFilePath string) error {
+	repo, err := Open(filepath.Dir(connectionFilePath))
+	if err != nil {
+		if errors.Is(err, ErrNotConfigured) {
+			return nil
+		}
+		return err
+	}
+	data, err := os.ReadFile(connectionFilePath)
+	if err != nil {
+		return err
+	}
+	if len(data) == 0 {
+		return nil
+	}
+	_, err = repo.CommitFile(data, CommitMessage("Update connections"))
+	return err
+}
+
+// CommitMessage appends the host name to subject, so the log shows which
+// machine made a change.
+func CommitMessage(subject string) string {
+	if host, err := os.Hostname(); err == nil && host != "" {
+		return fmt.Sprintf("%s on %s", subject, host)
+	}
+	return subject
+}
+
+// RemoteURL returns the URL of origin.
+func (r Repo) RemoteURL() (string, error) {
+	return r.git("remote", "get-url", RemoteName)
+}
+
+// Branch returns the branch being synced.
+func (r Repo) Branch() (string, error) {
+	return r.git("symbolic-ref", "--short", "HEAD")
+}
+
+// Head returns the current commit, or "" before the first commit.
+func (r Repo) Head() (string, error) {
+	return r.revision("HEAD")
+}
+
+// Tracked returns the committed connection file, or nil before the first
+// commit.
+func (r Repo) Tracked() ([]byte, error) {
+	head, err := r.Head()
+	if err != nil || head == "" {
+		return nil, err
+	}
+	return r.Show(head)
+}
+
+// CommitFile writes data as the tracked connection file and commits it.
+// changed is false when data matches the last commit.
+func (r Repo) CommitFile(data []byte, message string) (changed bool, err error) {
+	if err := storage.WriteFileAtomic(filepath.Join(r.Dir, FileName), data, 0o600); err != nil {
+		return false, fmt.Errorf("failed to write sync copy: %w", err)
+	}
+	if _, err := r.git("add", FileName); err != nil {
+		return false, err
+	}
+	if _, err := r.git("diff", "--cached", "--quiet"); err == nil {
+		return false, nil
+	}
+	if _, err := r.git("commit", "-q", "-m", message); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// Fetch downloads branch from origin and returns its commit, or "" when the
+// remote does not have the branch yet.
+func (r Repo) Fetch(branch string) (string, error) {
+	if _, err := r.git("fetch", "-q", RemoteName); err != nil {
+		return "", err
+	}
+	return r.revision(r.RemoteRef(branch))
+}
+
+// RemoteRef is the remote-tracking ref of branch.
+func (r Repo) RemoteRef(branch string) string {
+	return "refs/remotes/" + RemoteName + "/" + branch
+}
+
+// MergeBase returns the common ancestor of a and b, or "" when their
+// histories are unrelated.
+func (r Repo) MergeBase(a, b string) (string, error) {
+	out, err := r.git("merge-base", a, b)
+	if err != nil {
+		var exitErr *gitExitError
+		if errors.As(err, &exitErr) && exitErr.code == 1 {
+			return "", nil
+		}
+		return "", err
+	}
+	return out, nil
+}
+
+// IsAncestor reports whether a is an ancestor of (or equal to) b.
+func (r Repo) IsAncestor(a, b string) (bool, error) {
+	if _, err := r.git("merge-base", "--is-ancestor", a, b); err != nil {
+		var exitErr *gitExitError
+		if errors.As(err, &exitErr) && exitErr.code == 1 {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
+
+// Count returns how many commits are reachable from a but not from b.
+func (r Repo) Count(a, b string) (int, error) {
+	out, err := r.git("rev-list", "--count", a, "^"+b)
+	if err != nil {
+		return 0, err
+	}
+	var n int
+	if _, err := fmt.Sscanf(out, "%d", &n); err != nil {
+		return 0, fmt.Errorf("unexpected git rev-list output %q", out)
+	}
+	return n, nil
+}
+
+// Show returns the connection file as of rev.
+func (r Repo) Show(rev string) ([]byte, error) {
+	return r.gitBytes("show", rev+":"+FileName)
+}
+
+// FastForward moves the branch and the working copy to rev.
+func (r Repo) FastForward(rev string) error {
+	_, err := r.git("merge", "-q", "--ff-only", rev)
+	return err
+}
+
+// RecordMerge creates a merge commit with rev as second parent and the
+// current commit's content, which the caller already merged.
+func (r Repo) RecordMerge(rev, message string) error {
+	_, err := r.git("merge", "-q", "--no-edit", "--allow-unrelated-histories", "-s", "ours", "-m", message, rev)
+	return err
+}
+
+// Push publishes the current commit as branch on origin.
+func (r Repo) Push(branch string) error {
+	_, err := r.git("push", "-q", RemoteName, "HEAD:refs/heads/"+branch)
+	return err
+}
+
+func (r Repo) revision(rev string) (string, error) {
+	out, err := r.git("rev-parse", "-q", "--verify", rev+"^{commit}")
+	if err != nil {
+		var exitErr *gitExitError
+		if errors.As(err, &exitErr) && exitErr.code == 1 {
+			return "", nil
+		}
+		return "", err
+	}
+	return out, nil
+}
+
+type gitExitError struct {
+	args   []string
+	code   int
+	stderr string
+}
+
+func (e *gitExitError) Error() string {
+	if e.stderr == "" {
+		return fmt.Sprintf("git %s failed (exit status %d)", strings.Join(e.args, " "), e.code)
+	}
+	return fmt.Sprintf("git %s failed: %s", strings.Join(e.args, " "), e.stderr)
+}
+
+func (r Repo) git(args ...string) (string, error) {
+	out, err := r.gitBytes(args...)
+	return strings.TrimSpace(string(out)), err
+}
+
+func (r Repo) gitBytes(args ...string) ([]byte, error) {
+	cmd := exec.Command("git", append([]string{"-C", r.Dir}, args...)...)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			return nil, &gitExitError{args: args, code: exitErr.ExitCode(), stderr: strings.TrimSpace(stderr.String())}
+		}
+		return nil, fmt.Errorf("failed to run git: %w", err)
+	}
+	return stdout.Bytes(), nil
+}
@@ -0,0 +1,293 @@
+package gitsync
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/emirhangumus/sshmanager/internal/model"
+	"gopkg.in/yaml.v3"
+)
+
+// newTestRemote creates a bare repository on local disk.
+func newTestRemote(t *testing.T) string {
+	t.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	remote := filepath.Join(t.TempDir(), "remote.git")
+	if out, err := exec.Command("git", "init", "-q", "--bare", remote).CombinedOutput(); err != nil {
+		t.Fatalf("git init --bare failed: %v: %s", err, out)
+	}
+	return remote
+}
+
+// newTestVault sets up sync for a fresh vault directory against remote.
+func newTestVault(t *testing.T, remote string) (string, Repo) {
+	t.Helper()
+	vaultDir := t.TempDir()
+	repo, err := Init(vaultDir, remote, "")
+	if err != nil {
+		t.Fatalf("Init failed: %v", err)
+	}
+	return vaultDir, repo
+}
+
+func commitTestFile(t *testing.T, repo Repo, data []byte) string {
+	t.Helper()
+	if _, err := repo.CommitFile(data, "test"); err != nil {
+		t.Fatalf("CommitFile failed: %v", err)
+	}
+	head, err := repo.Head()
+	if err != nil || head == "" {
+		t.Fatalf("Head failed: %q, %v", head, err)
+	}
+	return head
+}
+
+func fetchTestRemote(t *testing.T, repo Repo) string {
+	t.Helper()
+	remote, err := repo.Fetch(DefaultBranch)
+	if err != nil {
+		t.Fatalf("Fetch failed: %v", err)
+	}
+	return remote
+}
+
+func pushTestRepo(t *testing.T, repo Repo) {
+	t.Helper()
+	if err := repo.Push(DefaultBranch); err != nil {
+		t.Fatalf("Push failed: %v", err)
+	}
+}
+
+func assertTracked(t *testing.T, repo Repo, want []byte) {
+	t.Helper()
+	tracked, err := repo.Tracked()
+	if err != nil {
+		t.Fatalf("Tracked failed: %v", err)
+	}
+	if !bytes.Equal(tracked, want) {
+		t.Fatalf("expected tracked file %q, got %q", want, tracked)
+	}
+	onDisk, err := os.ReadFile(filepath.Join(repo.Dir, FileName))
+	if err != nil {
+		t.Fatalf("ReadFile failed: %v", err)
+	}
+	if !bytes.Equal(onDisk, want) {
+		t.Fatalf("expected working copy %q, got %q", want, onDisk)
+	}
+}
+
+func marshalTestConnections(t *testing.T, connections ...model.SSHConnection) []byte {
+	t.Helper()
+	connFile := model.NewConnectionFile()
+	connFile.Connections = connections
+	data, err := yaml.Marshal(connFile)
+	if err != nil {
+		t.Fatalf("yaml.Marshal failed: %v", err)
+	}
+	return data
+}
+
+func unmarshalTestConnections(t *testing.T, repo Repo, rev string) model.ConnectionFile {
+	t.Helper()
+	data, err := repo.Show(rev)
+	if err != nil {
+		t.Fatalf("Show(%s) failed: %v", rev, err)
+	}
+	var connFile model.ConnectionFile
+	if err := yaml.Unmarshal(data, &connFile); err != nil {
+		t.Fatalf("yaml.Unmarshal failed: %v", err)
+	}
+	return connFile
+}
+
+func TestOpenWithoutSyncRepository(t *testing.T) {
+	if _, err := Open(t.TempDir()); !errors.Is(err, ErrNotConfigured) {
+		t.Fatalf("expected ErrNotConfigured, got %v", err)
+	}
+}
+
+func TestFastForwardPullsRemoteCommits(t *testing.T) {
+	remote := newTestRemote(t)
+	_, first := newTestVault(t, remote)
+	_, second := newTestVault(t, remote)
+
+	if rev := fetchTestRemote(t, second); rev != "" {
+		t.Fatalf("expected no remote branch yet, got %s", rev)
+	}
+
+	v1 := []byte("v1")
+	commitTestFile(t, first, v1)
+	pushTestRepo(t, first)
+
+	// The second vault has no commits yet, so the remote branch is adopted.
+	rev := fetchTestRemote(t, second)
+	if err := second.FastForward(rev); err != nil {
+		t.Fatalf("FastForward onto an empty branch failed: %v", err)
+	}
+	assertTracked(t, second, v1)
+
+	v2 := []byte("v2")
+	commitTestFile(t, first, v2)
+	pushTestRepo(t, first)
+
+	head, _ := second.Head()
+	rev = fetchTestRemote(t, second)
+	ahead, err := second.IsAncestor(head, rev)
+	if err != nil || !ahead {
+		t.Fatalf("expected the local head to be an ancestor of the remote, got %v, %v", ahead, err)
+	}
+	if behind, err := second.IsAncestor(rev, head); err != nil || behind {
+		t.Fatalf("expected the remote not to be an ancestor of the local head, got %v, %v", behind, err)
+	}
+	if n, err := second.Count(rev, head); err != nil || n != 1 {
+		t.Fatalf("expected to be 1 commit behind, got %d, %v", n, err)
+	}
+	if err := second.FastForward(rev); err != nil {
+		t.Fatalf("FastForward failed: %v", err)
+	}
+	assertTracked(t, second, v2)
+	if head, _ := second.Head(); head != rev {
+		t.Fatalf("expected head %s after fast-forward, got %s", rev, head)
+	}
+}
+
+func TestRecordMergeOfConflictingConnections(t *testing.T) {
+	remote := newTestRemote(t)
+	_, first := newTestVault(t, remote)
+	_, second := newTestVault(t, remote)
+
+	web := model.SSHConnection{ID: "c1", Alias: "web", Username: "deploy", Host: "10.0.0.1"}
+	base := commitTestFile(t, first, marshalTestConnections(t, web))
+	pushTestRepo(t, first)
+	if err := second.FastForward(fetchTestRemote(t, second)); err != nil {
+		t.Fatalf("FastForward failed: %v", err)
+	}
+
+	remoteWeb := web
+	remoteWeb.Host = "10.0.0.2"
+	commitTestFile(t, first, marshalTestConnections(t, remoteWeb))
+	pushTestRepo(t, first)
+
+	localWeb := web
+	localWeb.Host = "10.0.0.3"
+	db := model.SSHConnection{ID: "c2", Alias: "db", Username: "admin", Host: "10.0.0.4"}
+	head := commitTestFile(t, second, marshalTestConnections(t, localWeb, db))
+
+	rev := fetchTestRemote(t, second)
+	if ahead, _ := second.IsAncestor(head, rev); ahead {
+		t.Fatal("expected diverged histories, local head is an ancestor of the remote")
+	}
+	if behind, _ := second.IsAncestor(rev, head); behind {
+		t.Fatal("expected diverged histories, remote is an ancestor of the local head")
+	}
+	mergeBase, err := second.MergeBase(head, rev)
+	if err != nil || mergeBase != base {
+		t.Fatalf("expected merge base %s, got %s, %v", base, mergeBase, err)
+	}
+
+	result := model.MergeConnectionFiles(
+		unmarshalTestConnections(t, second, mergeBase),
+		unmarshalTestConnections(t, second, head),
+		unmarshalTestConnections(t, second, rev),
+	)
+	if len(result.Conflicts) != 1 || result.Conflicts[0].Field != "host" {
+		t.Fatalf("expected one host conflict, got %v", result.Conflicts)
+	}
+	merged, _, err := result.Resolve([]model.MergeChoice{model.MergeTakeTheirs})
+	if err != nil {
+		t.Fatalf("Resolve failed: %v", err)
+	}
+	mergedData, err := yaml.Marshal(merged)
+	if err != nil {
+		t.Fatalf("yaml.Marshal failed: %v", err)
+	}
+
+	commitTestFile(t, second, mergedData)
+	if err := second.RecordMerge(rev, "Merge remote connections"); err != nil {
+		t.Fatalf("RecordMerge failed: %v", err)
+	}
+	assertTracked(t, second, mergedData)
+	mergeHead, _ := second.Head()
+	if isParent, _ := second.IsAncestor(rev, mergeHead); !isParent {
+		t.Fatal("expected the merge commit to include the remote commit")
+	}
+	pushTestRepo(t, second)
+
+	// The other vault can now fast-forward to the merged version.
+	rev = fetchTestRemote(t, first)
+	if err := first.FastForward(rev); err != nil {
+		t.Fatalf("FastForward to the merge failed: %v", err)
+	}
+	assertTracked(t, first, mergedData)
+	pulled := unmarshalTestConnections(t, first, rev)
+	if len(pulled.Connections) != 2 {
+		t.Fatalf("expected 2 merged connections, got %d", len(pulled.Connections))
+	}
+	if got := pulled.GetConnectionByAlias("web"); got == nil || got.Host != "10.0.0.2" {
+		t.Fatalf("expected the remote host to win the conflict, got %+v", got)
+	}
+}
+
+func TestCommitConnectionFile(t *testing.T) {
+	remote := newTestRemote(t)
+
+	// Without a sync repository the save hook does nothing.
+	plain := filepath.Join(t.TempDir(), FileName)
+	if err := os.WriteFile(plain, []byte("data"), 0o600); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+	if err := CommitConnectionFile(plain); err != nil {
+		t.Fatalf("CommitConnectionFile without sync failed: %v", err)
+	}
+
+	vaultDir, repo := newTestVault(t, remote)
+	connPath := filepath.Join(vaultDir, FileName)
+
+	// An empty connection file is never committed.
+	if err := os.WriteFile(connPath, nil, 0o600); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+	if err := CommitConnectionFile(connPath); err != nil {
+		t.Fatalf("CommitConnectionFile failed: %v", err)
+	}
+	if head, _ := repo.Head(); head != "" {
+		t.Fatalf("expected no commit for an empty file, got %s", head)
+	}
+
+	if err := os.WriteFile(connPath, []byte("v1"), 0o600); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+	if err := CommitConnectionFile(connPath); err != nil {
+		t.Fatalf("CommitConnectionFile failed: %v", err)
+	}
+	first, _ := repo.Head()
+	if first == "" {
+		t.Fatal("expected the save hook to commit the connection file")
+	}
+	assertTracked(t, repo, []byte("v1"))
+
+	// Saving unchanged data does not create an empty commit.
+	if err := CommitConnectionFile(connPath); err != nil {
+		t.Fatalf("CommitConnectionFile failed: %v", err)
+	}
+	if head, _ := repo.Head(); head != first {
+		t.Fatalf("expected no new commit for unchanged data, got %s", head)
+	}
+
+	if err := os.WriteFile(connPath, []byte("v2"), 0o600); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+	if err := CommitConnectionFile(connPath); err != nil {
+		t.Fatalf("CommitConnectionFile failed: %v", err)
+	}
+	if n, err := repo.Count("HEAD", first); err != nil || n != 1 {
+		t.Fatalf("expected one new commit, got %d, %v", n, err)
+	}
+	assertTracked(t, repo, []byte("v2"))
+}
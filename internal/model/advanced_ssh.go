@@ -8,9 +8,9 @@ import (
 )
 
 var (
-	proxyJumpHopPattern = regexp.MustCompile(`^(?:[^@\s,]+@)?(?:\[[^\]\s,]+\]|[^:@\s,]+)(?::(\d{1,5}))?$`)
-	forwardSpecPattern  = regexp.MustCompile(`^(?:([^:\s]+):)?(\d{1,5}):([^:\s]+|\[[^\]\s]+\]):(\d{1,5})$`)
-	sshOptionKeyPattern = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9]*$`)
+	proxyJumpHopPattern   = regexp.MustCompile(`^(?:[^@\s,]+@)?(?:\[[^\]\s,]+\]|[^:@\s,]+)(?::(\d{1,5}))?$`)
+	dynamicForwardPattern = regexp.MustCompile(`^(?:([^:\s\[\]]+|\[[^\]\s]+\]):)?(\d{1,5})$`)
+	sshOptionKeyPattern   = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9]*$`)
 )
 
 var allowedStandaloneExtraSSHArgs = map[string]struct{}{
@@ -36,6 +36,7 @@ var allowedStandaloneExtraSSHArgs = map[string]struct{}{
 }
 
 var blockedExtraSSHOptionKeys = map[string]struct{}{
+	"dynamicforward":     {},
 	"identityfile":       {},
 	"localcommand":       {},
 	"localforward":       {},
@@ -105,28 +106,183 @@ func ValidateForwardSpecs(specs []string) error {
 	return nil
 }
 
+// ValidateForwardSpec checks a -L/-R spec against the OpenSSH grammar:
+// [bind_address:]port:host:hostport, [bind_address:]port:socket,
+// socket:host:hostport or socket:socket.
 func ValidateForwardSpec(spec string) error {
+	_, _, err := ParseForwardSpec(spec)
+	return err
+}
+
+// ForwardEndpoint is one side of a forward spec: a TCP address, or a Unix
+// socket path when Socket is set.
+type ForwardEndpoint struct {
+	// Host is the bind address on the listen side (empty when omitted) or
+	// the destination host, as written: IPv6 addresses keep their brackets.
+	Host   string
+	Port   int
+	Socket string
+}
+
+// IsSocket reports whether the endpoint is a Unix socket path.
+func (e ForwardEndpoint) IsSocket() bool {
+	return e.Socket != ""
+}
+
+// String returns the endpoint in forward spec form.
+func (e ForwardEndpoint) String() string {
+	switch {
+	case e.IsSocket():
+		return e.Socket
+	case e.Host == "":
+		return strconv.Itoa(e.Port)
+	default:
+		return e.Host + ":" + strconv.Itoa(e.Port)
+	}
+}
+
+// IsForwardSocketPath reports whether a forward spec field names a Unix
+// socket. Like ssh, any field containing a slash is taken as a path.
+func IsForwardSocketPath(value string) bool {
+	return strings.Contains(value, "/")
+}
+
+// ParseForwardSpec splits a -L/-R spec into its listen and target
+// endpoints.
+func ParseForwardSpec(spec string) (ForwardEndpoint, ForwardEndpoint, error) {
 	trimmed := strings.TrimSpace(spec)
 	if trimmed == "" {
-		return fmt.Errorf("forward spec cannot be empty")
+		return ForwardEndpoint{}, ForwardEndpoint{}, fmt.Errorf("forward spec cannot be empty")
 	}
 	if strings.ContainsAny(trimmed, " \t\r\n") {
-		return fmt.Errorf("forward spec cannot contain whitespace: %q", trimmed)
+		return ForwardEndpoint{}, ForwardEndpoint{}, fmt.Errorf("forward spec cannot contain whitespace: %q", trimmed)
 	}
 
-	matches := forwardSpecPattern.FindStringSubmatch(trimmed)
-	if matches == nil {
-		return fmt.Errorf("invalid forward spec %q, expected [bind_address:]port:host:hostport", trimmed)
+	invalid := fmt.Errorf("invalid forward spec %q, expected [bind_address:]port:host:hostport, [bind_address:]port:socket, socket:host:hostport or socket:socket", trimmed)
+	fields, ok := splitForwardFields(trimmed)
+	if !ok {
+		return ForwardEndpoint{}, ForwardEndpoint{}, invalid
+	}
+
+	var listen, target []string
+	switch {
+	case len(fields) == 4:
+		listen, target = fields[:2], fields[2:]
+	case len(fields) == 3 && IsForwardSocketPath(fields[0]):
+		listen, target = fields[:1], fields[1:]
+	case len(fields) == 3 && IsForwardSocketPath(fields[2]):
+		listen, target = fields[:2], fields[2:]
+	case len(fields) == 3:
+		listen, target = fields[:1], fields[1:]
+	case len(fields) == 2:
+		listen, target = fields[:1], fields[1:]
+	default:
+		return ForwardEndpoint{}, ForwardEndpoint{}, invalid
+	}
+
+	listenEndpoint, err := parseForwardEndpoint(listen, true)
+	if err != nil {
+		return ForwardEndpoint{}, ForwardEndpoint{}, fmt.Errorf("invalid listen side in forward spec %q: %w", trimmed, err)
+	}
+	targetEndpoint, err := parseForwardEndpoint(target, false)
+	if err != nil {
+		return ForwardEndpoint{}, ForwardEndpoint{}, fmt.Errorf("invalid target side in forward spec %q: %w", trimmed, err)
+	}
+	return listenEndpoint, targetEndpoint, nil
+}
+
+// parseForwardEndpoint parses the fields of one side of a forward spec: a
+// socket path, host and port, or (on the listen side only) a bare port.
+func parseForwardEndpoint(fields []string, listen bool) (ForwardEndpoint, error) {
+	if len(fields) == 1 && IsForwardSocketPath(fields[0]) {
+		return ForwardEndpoint{Socket: fields[0]}, nil
+	}
+
+	var endpoint ForwardEndpoint
+	portField := fields[len(fields)-1]
+	switch {
+	case len(fields) == 2:
+		host := fields[0]
+		if host == "" || IsForwardSocketPath(host) {
+			return ForwardEndpoint{}, fmt.Errorf("invalid host %q", host)
+		}
+		if strings.HasPrefix(host, "[") && strings.Trim(host, "[]") == "" {
+			return ForwardEndpoint{}, fmt.Errorf("invalid host %q", host)
+		}
+		endpoint.Host = host
+	case !listen:
+		return ForwardEndpoint{}, fmt.Errorf("expected host:hostport or a socket path")
+	}
+
+	if len(portField) == 0 || len(portField) > 5 || strings.Trim(portField, "0123456789") != "" {
+		return ForwardEndpoint{}, fmt.Errorf("invalid port %q", portField)
 	}
+	port, err := strconv.Atoi(portField)
+	if err != nil || port < 1 || port > 65535 {
+		return ForwardEndpoint{}, fmt.Errorf("invalid port %q", portField)
+	}
+	endpoint.Port = port
+	return endpoint, nil
+}
 
-	localPort, err := strconv.Atoi(matches[2])
-	if err != nil || localPort < 1 || localPort > 65535 {
-		return fmt.Errorf("invalid local port in forward spec %q", trimmed)
+// splitForwardFields splits spec on colons outside of [brackets]. A
+// bracketed field must be bracketed as a whole.
+func splitForwardFields(spec string) ([]string, bool) {
+	var fields []string
+	start, depth := 0, 0
+	for i, r := range spec {
+		switch r {
+		case '[':
+			if depth > 0 || i != start {
+				return nil, false
+			}
+			depth++
+		case ']':
+			if depth == 0 || (i+1 < len(spec) && spec[i+1] != ':') {
+				return nil, false
+			}
+			depth--
+		case ':':
+			if depth == 0 {
+				fields = append(fields, spec[start:i])
+				start = i + 1
+			}
+		}
+	}
+	if depth != 0 {
+		return nil, false
 	}
+	return append(fields, spec[start:]), true
+}
 
-	remotePort, err := strconv.Atoi(matches[4])
-	if err != nil || remotePort < 1 || remotePort > 65535 {
-		return fmt.Errorf("invalid remote port in forward spec %q", trimmed)
+func ValidateDynamicForwardSpecs(specs []string) error {
+	for _, raw := range specs {
+		if err := ValidateDynamicForwardSpec(raw); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ValidateDynamicForwardSpec checks a -D spec: [bind_address:]port, where an
+// IPv6 bind address is written in brackets.
+func ValidateDynamicForwardSpec(spec string) error {
+	trimmed := strings.TrimSpace(spec)
+	if trimmed == "" {
+		return fmt.Errorf("dynamic forward spec cannot be empty")
+	}
+	if strings.ContainsAny(trimmed, " \t\r\n") {
+		return fmt.Errorf("dynamic forward spec cannot contain whitespace: %q", trimmed)
+	}
+
+	matches := dynamicForwardPattern.FindStringSubmatch(trimmed)
+	if matches == nil {
+		return fmt.Errorf("invalid dynamic forward spec %q, expected [bind_address:]port", trimmed)
+	}
+
+	port, err := strconv.Atoi(matches[2])
+	if err != nil || port < 1 || port > 65535 {
+		return fmt.Errorf("invalid port in dynamic forward spec %q", trimmed)
 	}
 
 	return nil
@@ -180,3 +336,14 @@ func validateSSHOptionToken(option string) error {
 
 	return nil
 }
+
+// SplitForwardSpec splits a validated forward spec into its listen side
+// ([bind_address:]port or a socket path) and its destination side
+// (host:hostport or a socket path).
+func SplitForwardSpec(spec string) (string, string, error) {
+	listen, target, err := ParseForwardSpec(spec)
+	if err != nil {
+		return "", "", err
+	}
+	return listen.String(), target.String(), nil
+}
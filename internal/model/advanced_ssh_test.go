@@ -31,27 +31,104 @@ func TestValidateProxyJump(t *testing.T) {
 }
 
 func TestValidateForwardSpec(t *testing.T) {
+	tests := []struct {
+		name    string
+		spec    string
+		wantErr bool
+	}{
+		{name: "port to host", spec: "8080:127.0.0.1:80"},
+		{name: "bind to host", spec: "127.0.0.1:8080:127.0.0.1:80"},
+		{name: "wildcard bind", spec: "*:8080:localhost:80"},
+		{name: "ipv6 target", spec: "9000:[::1]:9001"},
+		{name: "ipv6 bind", spec: "[::1]:8080:localhost:80"},
+		{name: "ipv6 bind and target", spec: "[::]:8080:[2001:db8::1]:443"},
+		{name: "port to socket", spec: "2375:/var/run/docker.sock"},
+		{name: "bind to socket", spec: "[::1]:2375:/var/run/docker.sock"},
+		{name: "socket to host", spec: "/tmp/db.sock:db.internal:5432"},
+		{name: "socket to socket", spec: "/tmp/docker.sock:/var/run/docker.sock"},
+		{name: "relative socket", spec: "run/app.sock:localhost:80"},
+		{name: "empty", spec: "", wantErr: true},
+		{name: "missing port", spec: "8080:localhost", wantErr: true},
+		{name: "non numeric port", spec: "bad:localhost:80", wantErr: true},
+		{name: "port out of range", spec: "8080:localhost:70000", wantErr: true},
+		{name: "zero port", spec: "0:localhost:80", wantErr: true},
+		{name: "whitespace", spec: "80 80:localhost:80", wantErr: true},
+		{name: "unbracketed ipv6 bind", spec: "::1:8080:localhost:80", wantErr: true},
+		{name: "unclosed bracket", spec: "[::1:8080:localhost:80", wantErr: true},
+		{name: "text after bracket", spec: "[::1]x:8080:localhost:80", wantErr: true},
+		{name: "empty brackets", spec: "[]:8080:localhost:80", wantErr: true},
+		{name: "empty bind", spec: ":8080:localhost:80", wantErr: true},
+		{name: "socket to port", spec: "/tmp/a.sock:8080", wantErr: true},
+		{name: "socket with bind", spec: "127.0.0.1:/tmp/a.sock:localhost:80", wantErr: true},
+		{name: "socket as target host", spec: "8080:/tmp/a.sock:80", wantErr: true},
+		{name: "too many fields", spec: "a:1:b:2:c", wantErr: true},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			err := ValidateForwardSpec(tc.spec)
+			if tc.wantErr && err == nil {
+				t.Fatalf("ValidateForwardSpec(%q) expected error, got nil", tc.spec)
+			}
+			if !tc.wantErr && err != nil {
+				t.Fatalf("ValidateForwardSpec(%q) unexpected error: %v", tc.spec, err)
+			}
+		})
+	}
+}
+
+func TestParseForwardSpec(t *testing.T) {
+	tests := []struct {
+		spec       string
+		wantListen ForwardEndpoint
+		wantTarget ForwardEndpoint
+	}{
+		{spec: "8080:127.0.0.1:80", wantListen: ForwardEndpoint{Port: 8080}, wantTarget: ForwardEndpoint{Host: "127.0.0.1", Port: 80}},
+		{spec: "[::1]:8080:[::1]:80", wantListen: ForwardEndpoint{Host: "[::1]", Port: 8080}, wantTarget: ForwardEndpoint{Host: "[::1]", Port: 80}},
+		{spec: "2375:/var/run/docker.sock", wantListen: ForwardEndpoint{Port: 2375}, wantTarget: ForwardEndpoint{Socket: "/var/run/docker.sock"}},
+		{spec: "localhost:2375:/var/run/docker.sock", wantListen: ForwardEndpoint{Host: "localhost", Port: 2375}, wantTarget: ForwardEndpoint{Socket: "/var/run/docker.sock"}},
+		{spec: "/tmp/db.sock:db.internal:5432", wantListen: ForwardEndpoint{Socket: "/tmp/db.sock"}, wantTarget: ForwardEndpoint{Host: "db.internal", Port: 5432}},
+		{spec: "/tmp/a.sock:/tmp/b.sock", wantListen: ForwardEndpoint{Socket: "/tmp/a.sock"}, wantTarget: ForwardEndpoint{Socket: "/tmp/b.sock"}},
+	}
+	for _, tc := range tests {
+		listen, target, err := ParseForwardSpec(tc.spec)
+		if err != nil {
+			t.Fatalf("ParseForwardSpec(%q) unexpected error: %v", tc.spec, err)
+		}
+		if listen != tc.wantListen || target != tc.wantTarget {
+			t.Fatalf("ParseForwardSpec(%q) = (%+v, %+v), want (%+v, %+v)", tc.spec, listen, target, tc.wantListen, tc.wantTarget)
+		}
+		if got := listen.String() + ":" + target.String(); got != tc.spec {
+			t.Fatalf("endpoints of %q print back as %q", tc.spec, got)
+		}
+	}
+}
+
+func TestValidateDynamicForwardSpec(t *testing.T) {
 	valid := []string{
-		"8080:127.0.0.1:80",
-		"127.0.0.1:8080:127.0.0.1:80",
-		"9000:[::1]:9001",
+		"1080",
+		"127.0.0.1:1080",
+		"localhost:1080",
+		"*:1080",
+		"[::1]:1080",
 	}
 	for _, value := range valid {
-		if err := ValidateForwardSpec(value); err != nil {
-			t.Fatalf("ValidateForwardSpec(%q) unexpected error: %v", value, err)
+		if err := ValidateDynamicForwardSpec(value); err != nil {
+			t.Fatalf("ValidateDynamicForwardSpec(%q) unexpected error: %v", value, err)
 		}
 	}
 
 	invalid := []string{
 		"",
-		"8080:localhost",
-		"bad:localhost:80",
-		"8080:localhost:70000",
-		"80 80:localhost:80",
+		"socks",
+		"0",
+		"70000",
+		"::1:1080",
+		"1080:localhost:80",
+		"10 80",
 	}
 	for _, value := range invalid {
-		if err := ValidateForwardSpec(value); err == nil {
-			t.Fatalf("ValidateForwardSpec(%q) expected error, got nil", value)
+		if err := ValidateDynamicForwardSpec(value); err == nil {
+			t.Fatalf("ValidateDynamicForwardSpec(%q) expected error, got nil", value)
 		}
 	}
 }
@@ -75,6 +152,7 @@ func TestValidateExtraSSHArgs(t *testing.T) {
 		{"-o"},
 		{"-o", "NoEquals"},
 		{"-oProxyCommand=nc %h %p"},
+		{"-o", "DynamicForward=1080"},
 		{"hostname"},
 	}
 	for _, args := range invalid {
@@ -97,3 +175,32 @@ func TestNormalizeStringList(t *testing.T) {
 		t.Fatal("NormalizeStringList should return nil for empty input")
 	}
 }
+
+func TestSplitForwardSpec(t *testing.T) {
+	tests := []struct {
+		spec       string
+		wantListen string
+		wantTarget string
+	}{
+		{spec: "8080:127.0.0.1:80", wantListen: "8080", wantTarget: "127.0.0.1:80"},
+		{spec: "127.0.0.1:8080:db.internal:5432", wantListen: "127.0.0.1:8080", wantTarget: "db.internal:5432"},
+		{spec: "9000:[::1]:9001", wantListen: "9000", wantTarget: "[::1]:9001"},
+		{spec: "[::1]:8080:localhost:80", wantListen: "[::1]:8080", wantTarget: "localhost:80"},
+		{spec: "2375:/var/run/docker.sock", wantListen: "2375", wantTarget: "/var/run/docker.sock"},
+		{spec: "/tmp/db.sock:db.internal:5432", wantListen: "/tmp/db.sock", wantTarget: "db.internal:5432"},
+		{spec: "/tmp/a.sock:/tmp/b.sock", wantListen: "/tmp/a.sock", wantTarget: "/tmp/b.sock"},
+	}
+	for _, tc := range tests {
+		listen, target, err := SplitForwardSpec(tc.spec)
+		if err != nil {
+			t.Fatalf("SplitForwardSpec(%q) unexpected error: %v", tc.spec, err)
+		}
+		if listen != tc.wantListen || target != tc.wantTarget {
+			t.Fatalf("SplitForwardSpec(%q) = (%q, %q), want (%q, %q)", tc.spec, listen, target, tc.wantListen, tc.wantTarget)
+		}
+	}
+
+	if _, _, err := SplitForwardSpec("bad-forward"); err == nil {
+		t.Fatal("expected error for invalid forward spec")
+	}
+}
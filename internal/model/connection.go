@@ -7,25 +7,31 @@ const (
 	AuthModeKey      = "key"
 	AuthModeAgent    = "agent"
 	DefaultSSHPort   = 22
+
+	PasswordSourceInline = "inline"
 )
 
 // SSHConnection stores credentials and metadata for a remote host.
 type SSHConnection struct {
-	ID             string   `yaml:"id" json:"id"`
-	Username       string   `yaml:"username" json:"username"`
-	Host           string   `yaml:"host" json:"host"`
-	Port           int      `yaml:"port,omitempty" json:"port,omitempty"`
-	AuthMode       string   `yaml:"authMode,omitempty" json:"authMode,omitempty"`
-	Password       string   `yaml:"password,omitempty" json:"password,omitempty"`
-	IdentityFile   string   `yaml:"identityFile,omitempty" json:"identityFile,omitempty"`
-	ProxyJump      string   `yaml:"proxyJump,omitempty" json:"proxyJump,omitempty"`
-	LocalForwards  []string `yaml:"localForwards,omitempty" json:"localForwards,omitempty"`
-	RemoteForwards []string `yaml:"remoteForwards,omitempty" json:"remoteForwards,omitempty"`
-	ExtraSSHArgs   []string `yaml:"extraSSHArgs,omitempty" json:"extraSSHArgs,omitempty"`
-	Group          string   `yaml:"group,omitempty" json:"group,omitempty"`
-	Tags           []string `yaml:"tags,omitempty" json:"tags,omitempty"`
-	Description    string   `yaml:"description,omitempty" json:"description,omitempty"`
-	Alias          string   `yaml:"alias,omitempty" json:"alias,omitempty"`
+	ID              string   `yaml:"id" json:"id"`
+	Username        string   `yaml:"username" json:"username"`
+	Host            string   `yaml:"host" json:"host"`
+	Port            int      `yaml:"port,omitempty" json:"port,omitempty"`
+	AuthMode        string   `yaml:"authMode,omitempty" json:"authMode,omitempty"`
+	Password        string   `yaml:"password,omitempty" json:"password,omitempty"`
+	PasswordSource  string   `yaml:"passwordSource,omitempty" json:"passwordSource,omitempty"`
+	IdentityFile    string   `yaml:"identityFile,omitempty" json:"identityFile,omitempty"`
+	ProxyJump       string   `yaml:"proxyJump,omitempty" json:"proxyJump,omitempty"`
+	LocalForwards   []string `yaml:"localForwards,omitempty" json:"localForwards,omitempty"`
+	RemoteForwards  []string `yaml:"remoteForwards,omitempty" json:"remoteForwards,omitempty"`
+	DynamicForwards []string `yaml:"dynamicForwards,omitempty" json:"dynamicForwards,omitempty"`
+	ExtraSSHArgs    []string `yaml:"extraSSHArgs,omitempty" json:"extraSSHArgs,omitempty"`
+	Group           string   `yaml:"group,omitempty" json:"group,omitempty"`
+	Tags            []string `yaml:"tags,omitempty" json:"tags,omitempty"`
+	Description     string   `yaml:"description,omitempty" json:"description,omitempty"`
+	Alias           string   `yaml:"alias,omitempty" json:"alias,omitempty"`
+	Extends         string   `yaml:"extends,omitempty" json:"extends,omitempty"`
+	HostKeys        []string `yaml:"hostKeys,omitempty" json:"hostKeys,omitempty"`
 }
 
 func (c SSHConnection) EffectivePort() int {
@@ -36,9 +42,23 @@ func (c SSHConnection) EffectivePort() int {
 }
 
 func (c SSHConnection) EffectiveAuthMode() string {
+	if !IsValidAuthMode(c.AuthMode) && c.HasExternalPasswordSource() {
+		return AuthModePassword
+	}
 	return ResolveAuthMode(c.AuthMode, c.Password, c.IdentityFile)
 }
 
+// HasExternalPasswordSource reports whether the password is looked up at
+// connect time instead of being stored in Password.
+func (c SSHConnection) HasExternalPasswordSource() bool {
+	return IsExternalPasswordSource(c.PasswordSource)
+}
+
+func IsExternalPasswordSource(source string) bool {
+	trimmed := strings.TrimSpace(source)
+	return trimmed != "" && !strings.EqualFold(trimmed, PasswordSourceInline)
+}
+
 func NormalizeAuthMode(mode string) string {
 	return strings.ToLower(strings.TrimSpace(mode))
 }
@@ -15,8 +15,9 @@ var ErrAliasAlreadyExists = errors.New("alias already exists")
 
 // ConnectionFile represents persisted SSH connections.
 type ConnectionFile struct {
-	Version     string          `yaml:"version" json:"version"`
-	Connections []SSHConnection `yaml:"connections" json:"connections"`
+	Version     string              `yaml:"version" json:"version"`
+	Profiles    []ConnectionProfile `yaml:"profiles,omitempty" json:"profiles,omitempty"`
+	Connections []SSHConnection     `yaml:"connections" json:"connections"`
 }
 
 // ConnectionSelectItem is a typed menu item for prompt selection.
@@ -113,6 +114,9 @@ func (c *ConnectionFile) SelectItems() []ConnectionSelectItem {
 	items := make([]ConnectionSelectItem, 0, len(c.Connections))
 	for i := range c.Connections {
 		conn := c.Connections[i]
+		if resolved, err := c.ResolveConnection(conn); err == nil {
+			conn = resolved
+		}
 		display := fmt.Sprintf("%d. %s@%s", i+1, conn.Username, conn.Host)
 		if strings.TrimSpace(conn.Description) != "" {
 			display += " - " + conn.Description
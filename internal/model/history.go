@@ -0,0 +1,89 @@
+package model
+
+import (
+	"sort"
+	"time"
+)
+
+const (
+	CurrentHistoryFileVersion = "1.0"
+
+	// MaxHistoryEntries bounds the history file; the oldest entries are
+	// dropped first.
+	MaxHistoryEntries = 5000
+)
+
+// HistoryFile represents the persisted connection history.
+type HistoryFile struct {
+	Version string         `yaml:"version" json:"version"`
+	Entries []HistoryEntry `yaml:"entries" json:"entries"`
+}
+
+// HistoryEntry records one connect invocation. Alias and Target are
+// snapshots so entries stay readable after a connection is renamed or removed.
+type HistoryEntry struct {
+	ConnectionID string    `yaml:"connectionId" json:"connectionId"`
+	Alias        string    `yaml:"alias,omitempty" json:"alias,omitempty"`
+	Target       string    `yaml:"target" json:"target"`
+	StartedAt    time.Time `yaml:"startedAt" json:"startedAt"`
+	EndedAt      time.Time `yaml:"endedAt" json:"endedAt"`
+	ExitCode     int       `yaml:"exitCode" json:"exitCode"`
+	Backend      string    `yaml:"backend" json:"backend"`
+	Error        string    `yaml:"error,omitempty" json:"error,omitempty"`
+}
+
+// ConnectionUsage summarises the history of a single connection.
+type ConnectionUsage struct {
+	LastUsed time.Time
+	UseCount int
+}
+
+func NewHistoryFile() HistoryFile {
+	return HistoryFile{
+		Version: CurrentHistoryFileVersion,
+		Entries: []HistoryEntry{},
+	}
+}
+
+// Append adds entry and trims the file to MaxHistoryEntries.
+func (h *HistoryFile) Append(entry HistoryEntry) {
+	h.Entries = append(h.Entries, entry)
+	if overflow := len(h.Entries) - MaxHistoryEntries; overflow > 0 {
+		h.Entries = append([]HistoryEntry(nil), h.Entries[overflow:]...)
+	}
+}
+
+// Usage returns last-used time and use count per connection ID.
+func (h *HistoryFile) Usage() map[string]ConnectionUsage {
+	usage := make(map[string]ConnectionUsage)
+	for _, entry := range h.Entries {
+		u := usage[entry.ConnectionID]
+		u.UseCount++
+		if entry.StartedAt.After(u.LastUsed) {
+			u.LastUsed = entry.StartedAt
+		}
+		usage[entry.ConnectionID] = u
+	}
+	return usage
+}
+
+// RecentConnectionIDs returns up to limit distinct connection IDs, most
+// recently used first.
+func (h *HistoryFile) RecentConnectionIDs(limit int) []string {
+	usage := h.Usage()
+	ids := make([]string, 0, len(usage))
+	for id := range usage {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool {
+		a, b := usage[ids[i]].LastUsed, usage[ids[j]].LastUsed
+		if !a.Equal(b) {
+			return a.After(b)
+		}
+		return ids[i] < ids[j]
+	})
+	if limit >= 0 && len(ids) > limit {
+		ids = ids[:limit]
+	}
+	return ids
+}
@@ -0,0 +1,38 @@
+package model
+
+import (
+	"testing"
+	"time"
+)
+
+func TestHistoryFileUsageAndRecent(t *testing.T) {
+	base := time.Date(2026, 1, 2, 10, 0, 0, 0, time.UTC)
+	history := NewHistoryFile()
+	history.Append(HistoryEntry{ConnectionID: "a", StartedAt: base})
+	history.Append(HistoryEntry{ConnectionID: "b", StartedAt: base.Add(time.Hour)})
+	history.Append(HistoryEntry{ConnectionID: "a", StartedAt: base.Add(30 * time.Minute)})
+	history.Append(HistoryEntry{ConnectionID: "c", StartedAt: base.Add(-time.Hour)})
+
+	usage := history.Usage()
+	if usage["a"].UseCount != 2 || !usage["a"].LastUsed.Equal(base.Add(30*time.Minute)) {
+		t.Fatalf("unexpected usage for a: %+v", usage["a"])
+	}
+
+	recent := history.RecentConnectionIDs(2)
+	if len(recent) != 2 || recent[0] != "b" || recent[1] != "a" {
+		t.Fatalf("unexpected recent IDs: %v", recent)
+	}
+}
+
+func TestHistoryFileAppendIsBounded(t *testing.T) {
+	history := NewHistoryFile()
+	for i := 0; i < MaxHistoryEntries+3; i++ {
+		history.Append(HistoryEntry{ConnectionID: "x", ExitCode: i})
+	}
+	if len(history.Entries) != MaxHistoryEntries {
+		t.Fatalf("expected %d entries, got %d", MaxHistoryEntries, len(history.Entries))
+	}
+	if history.Entries[0].ExitCode != 3 {
+		t.Fatalf("expected oldest entries to be dropped, first exit code = %d", history.Entries[0].ExitCode)
+	}
+}
@@ -0,0 +1,89 @@
+package model
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// Pinned host keys are stored as "<type> <base64>" in the public key form
+// of an OpenSSH known_hosts or authorized_keys line, so a known_hosts file
+// can be generated from them.
+
+// NormalizeHostKey trims a pinned host key, drops any trailing comment and
+// checks that the key blob is well formed and matches the stated type.
+func NormalizeHostKey(key string) (string, error) {
+	fields := strings.Fields(key)
+	if len(fields) < 2 {
+		return "", fmt.Errorf("host key %q must be \"<type> <base64>\"", key)
+	}
+	keyType, encoded := fields[0], fields[1]
+	blob, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return "", fmt.Errorf("host key of type %s is not valid base64: %w", keyType, err)
+	}
+	if len(blob) < 4 {
+		return "", fmt.Errorf("host key of type %s is truncated", keyType)
+	}
+	typeLen := binary.BigEndian.Uint32(blob)
+	if uint64(typeLen) > uint64(len(blob)-4) || string(blob[4:4+typeLen]) != keyType {
+		return "", fmt.Errorf("host key blob does not match its type %s", keyType)
+	}
+	return keyType + " " + encoded, nil
+}
+
+// ValidateHostKeys checks every pinned host key.
+func ValidateHostKeys(keys []string) error {
+	for _, key := range keys {
+		if _, err := NormalizeHostKey(key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// HostKeyFingerprint returns the OpenSSH SHA256 fingerprint of a pinned
+// host key, or the key itself when it cannot be parsed.
+func HostKeyFingerprint(key string) string {
+	normalized, err := NormalizeHostKey(key)
+	if err != nil {
+		return key
+	}
+	_, encoded, _ := strings.Cut(normalized, " ")
+	blob, _ := base64.StdEncoding.DecodeString(encoded)
+	sum := sha256.Sum256(blob)
+	return "SHA256:" + base64.RawStdEncoding.EncodeToString(sum[:])
+}
+
+// HostKeyType returns the key type of a pinned host key.
+func HostKeyType(key string) string {
+	keyType, _, _ := strings.Cut(strings.TrimSpace(key), " ")
+	return keyType
+}
+
+// KnownHostsPattern returns the host pattern ssh looks up in known_hosts for
+// host and port: the bare (lowercased) host on port 22, "[host]:port"
+// otherwise.
+func KnownHostsPattern(host string, port int) string {
+	host = strings.ToLower(strings.TrimSpace(host))
+	if strings.HasPrefix(host, "[") && strings.HasSuffix(host, "]") {
+		host = host[1 : len(host)-1]
+	}
+	if port <= 0 || port == DefaultSSHPort {
+		return host
+	}
+	return "[" + host + "]:" + strconv.Itoa(port)
+}
+
+// DialAddress returns the host:port address of the connection target.
+func (c SSHConnection) DialAddress() string {
+	host := strings.TrimSpace(c.Host)
+	if strings.HasPrefix(host, "[") && strings.HasSuffix(host, "]") {
+		host = host[1 : len(host)-1]
+	}
+	return net.JoinHostPort(host, strconv.Itoa(c.EffectivePort()))
+}
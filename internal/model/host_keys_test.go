@@ -0,0 +1,76 @@
+package model
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"strings"
+	"testing"
+)
+
+func testHostKey(keyType string, material byte) string {
+	blob := binary.BigEndian.AppendUint32(nil, uint32(len(keyType)))
+	blob = append(blob, keyType...)
+	blob = binary.BigEndian.AppendUint32(blob, 32)
+	for i := 0; i < 32; i++ {
+		blob = append(blob, material)
+	}
+	return keyType + " " + base64.StdEncoding.EncodeToString(blob)
+}
+
+func TestNormalizeHostKey(t *testing.T) {
+	key := testHostKey("ssh-ed25519", 7)
+
+	normalized, err := NormalizeHostKey("  " + key + " root@web\n")
+	if err != nil || normalized != key {
+		t.Fatalf("NormalizeHostKey = %q, %v", normalized, err)
+	}
+
+	_, encoded, _ := strings.Cut(key, " ")
+	for name, invalid := range map[string]string{
+		"missing blob":  "ssh-ed25519",
+		"bad base64":    "ssh-ed25519 !!!",
+		"type mismatch": "ssh-rsa " + encoded,
+		"truncated":     "ssh-ed25519 AAA=",
+	} {
+		if _, err := NormalizeHostKey(invalid); err == nil {
+			t.Fatalf("%s: expected an error", name)
+		}
+	}
+	if err := ValidateHostKeys([]string{key, "ssh-ed25519"}); err == nil {
+		t.Fatal("expected ValidateHostKeys to reject an invalid entry")
+	}
+}
+
+func TestHostKeyFingerprint(t *testing.T) {
+	first := HostKeyFingerprint(testHostKey("ssh-ed25519", 1))
+	second := HostKeyFingerprint(testHostKey("ssh-ed25519", 2))
+	if !strings.HasPrefix(first, "SHA256:") || strings.HasSuffix(first, "=") || first == second {
+		t.Fatalf("unexpected fingerprints %q and %q", first, second)
+	}
+	if got := HostKeyType(testHostKey("ssh-ed25519", 1)); got != "ssh-ed25519" {
+		t.Fatalf("HostKeyType = %q", got)
+	}
+}
+
+func TestKnownHostsPattern(t *testing.T) {
+	tests := []struct {
+		host string
+		port int
+		want string
+	}{
+		{host: "Web.Example.com", port: 0, want: "web.example.com"},
+		{host: "web", port: 22, want: "web"},
+		{host: "web", port: 2222, want: "[web]:2222"},
+		{host: "[::1]", port: 22, want: "::1"},
+		{host: "::1", port: 2222, want: "[::1]:2222"},
+	}
+	for _, tt := range tests {
+		if got := KnownHostsPattern(tt.host, tt.port); got != tt.want {
+			t.Fatalf("KnownHostsPattern(%q, %d) = %q, want %q", tt.host, tt.port, got, tt.want)
+		}
+	}
+
+	if got := (SSHConnection{Host: "[::1]", Port: 2222}).DialAddress(); got != "[::1]:2222" {
+		t.Fatalf("DialAddress = %q", got)
+	}
+}
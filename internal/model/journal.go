@@ -0,0 +1,336 @@
+package model
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+)
+
+const (
+	CurrentJournalFileVersion = "1.0"
+
+	// MaxJournalEntries bounds the change journal; the oldest revisions are
+	// dropped first.
+	MaxJournalEntries = 200
+)
+
+// JournalFile is the undo journal of a connection file. Entries[:Position]
+// are applied; the entries after Position were undone and can be redone
+// until the next change discards them.
+type JournalFile struct {
+	Version      string         `yaml:"version" json:"version"`
+	Position     int            `yaml:"position" json:"position"`
+	NextRevision int            `yaml:"nextRevision" json:"nextRevision"`
+	Entries      []JournalEntry `yaml:"entries" json:"entries"`
+}
+
+// JournalEntry records one saved change: who made it, when, with which
+// command, and a before/after snapshot of every connection or profile it
+// touched.
+type JournalEntry struct {
+	Revision int             `yaml:"revision" json:"revision"`
+	Time     time.Time       `yaml:"time" json:"time"`
+	Actor    string          `yaml:"actor" json:"actor"`
+	Command  string          `yaml:"command,omitempty" json:"command,omitempty"`
+	Changes  []JournalChange `yaml:"changes" json:"changes"`
+}
+
+// JournalChange is one connection (keyed by ID) or profile (keyed by name)
+// before and after a change. A nil snapshot means the entry did not exist
+// on that side. Fields lists the changed YAML fields of a modified
+// connection.
+type JournalChange struct {
+	Kind             string             `yaml:"kind" json:"kind"`
+	Key              string             `yaml:"key" json:"key"`
+	Label            string             `yaml:"label" json:"label"`
+	Fields           []string           `yaml:"fields,omitempty" json:"fields,omitempty"`
+	BeforeConnection *SSHConnection     `yaml:"beforeConnection,omitempty" json:"-"`
+	AfterConnection  *SSHConnection     `yaml:"afterConnection,omitempty" json:"-"`
+	BeforeProfile    *ConnectionProfile `yaml:"beforeProfile,omitempty" json:"-"`
+	AfterProfile     *ConnectionProfile `yaml:"afterProfile,omitempty" json:"-"`
+}
+
+func NewJournalFile() JournalFile {
+	return JournalFile{
+		Version:      CurrentJournalFileVersion,
+		NextRevision: 1,
+		Entries:      []JournalEntry{},
+	}
+}
+
+// Record appends entry as the next revision, discarding undone entries and
+// trimming the journal to MaxJournalEntries.
+func (j *JournalFile) Record(entry JournalEntry) JournalEntry {
+	if j.NextRevision < 1 {
+		j.NextRevision = 1
+	}
+	entry.Revision = j.NextRevision
+	j.NextRevision++
+
+	j.Entries = append(j.Entries[:j.Position], entry)
+	if overflow := len(j.Entries) - MaxJournalEntries; overflow > 0 {
+		j.Entries = append([]JournalEntry(nil), j.Entries[overflow:]...)
+	}
+	j.Position = len(j.Entries)
+	return entry
+}
+
+// Applied returns the entries that can be undone, oldest first.
+func (j *JournalFile) Applied() []JournalEntry {
+	return j.Entries[:j.Position]
+}
+
+// Undone returns the entries that can be redone, next first.
+func (j *JournalFile) Undone() []JournalEntry {
+	return j.Entries[j.Position:]
+}
+
+// Action describes the change as added, removed or changed.
+func (c JournalChange) Action() string {
+	before, after := c.BeforeConnection != nil, c.AfterConnection != nil
+	if c.Kind == EntryKindProfile {
+		before, after = c.BeforeProfile != nil, c.AfterProfile != nil
+	}
+	switch {
+	case !before:
+		return "added"
+	case !after:
+		return "removed"
+	default:
+		return "changed"
+	}
+}
+
+// Summary is a one-line description of the change. Passwords are never
+// shown, only that they changed.
+func (c JournalChange) Summary() string {
+	summary := fmt.Sprintf("%s %s %s", c.Action(), c.Kind, c.Label)
+	if c.Kind != EntryKindConnection || c.BeforeConnection == nil || c.AfterConnection == nil {
+		return summary
+	}
+
+	details := make([]string, 0, len(c.Fields))
+	before := reflect.ValueOf(*c.BeforeConnection)
+	after := reflect.ValueOf(*c.AfterConnection)
+	for _, name := range c.Fields {
+		field := connectionFieldByYAMLName(name)
+		if field < 0 {
+			continue
+		}
+		if name == "password" {
+			details = append(details, "password changed")
+			continue
+		}
+		details = append(details, fmt.Sprintf("%s: %s -> %s", name, describeMergeValue(before.Field(field).Interface()), describeMergeValue(after.Field(field).Interface())))
+	}
+	if len(details) == 0 {
+		return summary
+	}
+	return summary + " (" + strings.Join(details, "; ") + ")"
+}
+
+// DiffConnectionFiles returns the connections and profiles that differ
+// between before and after, connections first.
+func DiffConnectionFiles(before, after ConnectionFile) []JournalChange {
+	var changes []JournalChange
+
+	beforeConns := connectionsByID(before.Connections)
+	afterIDs := map[string]bool{}
+	for i := range after.Connections {
+		conn := after.Connections[i]
+		afterIDs[conn.ID] = true
+		old, existed := beforeConns[conn.ID]
+		switch {
+		case !existed:
+			changes = append(changes, JournalChange{Kind: EntryKindConnection, Key: conn.ID, Label: connectionMergeLabel(conn), AfterConnection: &conn})
+		case !reflect.DeepEqual(old, conn):
+			changes = append(changes, JournalChange{
+				Kind: EntryKindConnection, Key: conn.ID, Label: connectionMergeLabel(conn),
+				Fields: changedConnectionFields(old, conn), BeforeConnection: &old, AfterConnection: &conn,
+			})
+		}
+	}
+	for i := range before.Connections {
+		conn := before.Connections[i]
+		if !afterIDs[conn.ID] {
+			changes = append(changes, JournalChange{Kind: EntryKindConnection, Key: conn.ID, Label: connectionMergeLabel(conn), BeforeConnection: &conn})
+		}
+	}
+
+	beforeProfiles := profilesByName(before.Profiles)
+	afterNames := map[string]bool{}
+	for i := range after.Profiles {
+		profile := after.Profiles[i]
+		key := normalizeProfileName(profile.Name)
+		afterNames[key] = true
+		old, existed := beforeProfiles[key]
+		switch {
+		case !existed:
+			changes = append(changes, JournalChange{Kind: EntryKindProfile, Key: profile.Name, Label: profile.Name, AfterProfile: &profile})
+		case !reflect.DeepEqual(old, profile):
+			changes = append(changes, JournalChange{Kind: EntryKindProfile, Key: profile.Name, Label: profile.Name, BeforeProfile: &old, AfterProfile: &profile})
+		}
+	}
+	for i := range before.Profiles {
+		profile := before.Profiles[i]
+		if !afterNames[normalizeProfileName(profile.Name)] {
+			changes = append(changes, JournalChange{Kind: EntryKindProfile, Key: profile.Name, Label: profile.Name, BeforeProfile: &profile})
+		}
+	}
+	return changes
+}
+
+// ApplyJournalChanges moves every change in changes to its before
+// (undo) or after (redo) state. It fails without modifying file when an
+// entry no longer matches the state the change left it in, or when a
+// restored connection's alias is now taken by another connection.
+func ApplyJournalChanges(file *ConnectionFile, changes []JournalChange, undo bool) error {
+	next := *file
+	next.Connections = append([]SSHConnection(nil), file.Connections...)
+	next.Profiles = append([]ConnectionProfile(nil), file.Profiles...)
+
+	for _, change := range changes {
+		expectConn, targetConn := change.AfterConnection, change.BeforeConnection
+		expectProfile, targetProfile := change.AfterProfile, change.BeforeProfile
+		if !undo {
+			expectConn, targetConn = targetConn, expectConn
+			expectProfile, targetProfile = targetProfile, expectProfile
+		}
+
+		switch change.Kind {
+		case EntryKindConnection:
+			current := next.GetConnectionByID(change.Key)
+			if !sameConnectionState(current, expectConn) {
+				return fmt.Errorf("connection %s was changed since", change.Label)
+			}
+			if err := next.SetConnectionState(change.Key, targetConn); err != nil {
+				return err
+			}
+		case EntryKindProfile:
+			index := profileIndex(next.Profiles, change.Key)
+			var current *ConnectionProfile
+			if index >= 0 {
+				current = &next.Profiles[index]
+			}
+			if !sameProfileState(current, expectProfile) {
+				return fmt.Errorf("profile %s was changed since", change.Label)
+			}
+			switch {
+			case targetProfile == nil && index >= 0:
+				next.Profiles = append(next.Profiles[:index], next.Profiles[index+1:]...)
+			case targetProfile != nil && index >= 0:
+				next.Profiles[index] = *targetProfile
+			case targetProfile != nil:
+				next.Profiles = append(next.Profiles, *targetProfile)
+			}
+		}
+	}
+
+	*file = next
+	return nil
+}
+
+// SetConnectionState replaces the connection with id by state, adds it when
+// it does not exist, or removes it when state is nil.
+func (c *ConnectionFile) SetConnectionState(id string, state *SSHConnection) error {
+	if state == nil {
+		c.RemoveConnectionByID(id)
+		return nil
+	}
+	restored := *state
+	restored.ID = id
+	if c.hasAliasConflict(restored.Alias, id) {
+		return fmt.Errorf("%w: %s", ErrAliasAlreadyExists, restored.Alias)
+	}
+	if existing := c.GetConnectionByID(id); existing != nil {
+		*existing = restored
+		return nil
+	}
+	c.Connections = append(c.Connections, restored)
+	return nil
+}
+
+func changedConnectionFields(before, after SSHConnection) []string {
+	var fields []string
+	beforeValue := reflect.ValueOf(before)
+	afterValue := reflect.ValueOf(after)
+	connType := beforeValue.Type()
+	for i := 0; i < connType.NumField(); i++ {
+		if !reflect.DeepEqual(beforeValue.Field(i).Interface(), afterValue.Field(i).Interface()) {
+			fields = append(fields, yamlFieldName(connType.Field(i)))
+		}
+	}
+	return fields
+}
+
+func sameConnectionState(current, expected *SSHConnection) bool {
+	if current == nil || expected == nil {
+		return current == nil && expected == nil
+	}
+	return reflect.DeepEqual(*current, *expected)
+}
+
+func sameProfileState(current, expected *ConnectionProfile) bool {
+	if current == nil || expected == nil {
+		return current == nil && expected == nil
+	}
+	return reflect.DeepEqual(*current, *expected)
+}
+
+func profileIndex(profiles []ConnectionProfile, name string) int {
+	for i := range profiles {
+		if normalizeProfileName(profiles[i].Name) == normalizeProfileName(name) {
+			return i
+		}
+	}
+	return -1
+}
+
+// ConnectionIDByAlias finds the connection that last used alias in the
+// journal, which also covers removed and renamed connections.
+func (j *JournalFile) ConnectionIDByAlias(alias string) string {
+	needle := normalizeAlias(alias)
+	if needle == "" {
+		return ""
+	}
+	for i := len(j.Entries) - 1; i >= 0; i-- {
+		for _, change := range j.Entries[i].Changes {
+			if change.Kind != EntryKindConnection {
+				continue
+			}
+			for _, state := range []*SSHConnection{change.AfterConnection, change.BeforeConnection} {
+				if state != nil && normalizeAlias(state.Alias) == needle {
+					return change.Key
+				}
+			}
+		}
+	}
+	return ""
+}
+
+// ForConnection returns the entries that touched connection id, each
+// reduced to that connection's change, oldest first.
+func (j *JournalFile) ForConnection(id string) []JournalEntry {
+	var entries []JournalEntry
+	for _, entry := range j.Entries {
+		for _, change := range entry.Changes {
+			if change.Kind == EntryKindConnection && change.Key == id {
+				entry.Changes = []JournalChange{change}
+				entries = append(entries, entry)
+				break
+			}
+		}
+	}
+	return entries
+}
+
+// IsUndone reports whether the entry with revision was undone.
+func (j *JournalFile) IsUndone(revision int) bool {
+	for _, entry := range j.Undone() {
+		if entry.Revision == revision {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,108 @@
+package model
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestDiffConnectionFilesReportsChangedFields(t *testing.T) {
+	before := mergeFixture(
+		SSHConnection{ID: "c1", Username: "ops", Host: "web", ProxyJump: "bastion", Password: "old", Alias: "web"},
+		SSHConnection{ID: "c2", Username: "ops", Host: "db", Alias: "db"},
+	)
+	after := mergeFixture(
+		SSHConnection{ID: "c1", Username: "ops", Host: "web", Password: "new", Alias: "web"},
+		SSHConnection{ID: "c3", Username: "ops", Host: "cache", Alias: "cache"},
+	)
+
+	changes := DiffConnectionFiles(before, after)
+	if len(changes) != 3 {
+		t.Fatalf("expected changed, added and removed, got %+v", changes)
+	}
+	if changes[0].Action() != "changed" || !reflect.DeepEqual(changes[0].Fields, []string{"password", "proxyJump"}) {
+		t.Fatalf("unexpected change: %+v", changes[0])
+	}
+	summary := changes[0].Summary()
+	if !strings.Contains(summary, `proxyJump: "bastion" -> (empty)`) || strings.Contains(summary, "old") || strings.Contains(summary, "new") {
+		t.Fatalf("unexpected summary: %q", summary)
+	}
+	if changes[1].Action() != "added" || changes[1].Key != "c3" || changes[2].Action() != "removed" || changes[2].Key != "c2" {
+		t.Fatalf("unexpected add/remove: %+v %+v", changes[1], changes[2])
+	}
+}
+
+func TestApplyJournalChangesUndoAndRedo(t *testing.T) {
+	before := mergeFixture(SSHConnection{ID: "c1", Username: "ops", Host: "web", Alias: "web"}, SSHConnection{ID: "c2", Host: "db", Alias: "db"})
+	after := mergeFixture(SSHConnection{ID: "c1", Username: "root", Host: "web", Alias: "web"})
+	changes := DiffConnectionFiles(before, after)
+
+	file := after
+	if err := ApplyJournalChanges(&file, changes, true); err != nil {
+		t.Fatalf("undo failed: %v", err)
+	}
+	if file.GetConnectionByID("c1").Username != "ops" || file.GetConnectionByID("c2") == nil {
+		t.Fatalf("undo did not restore the previous state: %+v", file.Connections)
+	}
+	if err := ApplyJournalChanges(&file, changes, false); err != nil {
+		t.Fatalf("redo failed: %v", err)
+	}
+	if file.GetConnectionByID("c1").Username != "root" || file.GetConnectionByID("c2") != nil {
+		t.Fatalf("redo did not re-apply the change: %+v", file.Connections)
+	}
+
+	file.GetConnectionByID("c1").Port = 2222
+	if err := ApplyJournalChanges(&file, changes, true); err == nil || !strings.Contains(err.Error(), "was changed since") {
+		t.Fatalf("expected undo over a later change to fail, got %v", err)
+	}
+	if file.GetConnectionByID("c2") != nil {
+		t.Fatal("failed undo must not modify the file")
+	}
+}
+
+func TestJournalRecordDiscardsUndoneEntriesAndTrims(t *testing.T) {
+	journal := NewJournalFile()
+	for i := 0; i < 3; i++ {
+		journal.Record(JournalEntry{Actor: "me"})
+	}
+	journal.Position = 1
+	if len(journal.Undone()) != 2 || !journal.IsUndone(3) || journal.IsUndone(1) {
+		t.Fatalf("unexpected undone entries: %+v", journal.Undone())
+	}
+
+	entry := journal.Record(JournalEntry{Actor: "me"})
+	if entry.Revision != 4 || len(journal.Entries) != 2 || journal.Position != 2 {
+		t.Fatalf("expected redo entries to be discarded, got %+v", journal)
+	}
+
+	for i := 0; i < MaxJournalEntries+5; i++ {
+		journal.Record(JournalEntry{Actor: "me"})
+	}
+	if len(journal.Entries) != MaxJournalEntries || journal.Position != MaxJournalEntries {
+		t.Fatalf("expected journal trimmed to %d, got %d (position %d)", MaxJournalEntries, len(journal.Entries), journal.Position)
+	}
+	if last := journal.Entries[len(journal.Entries)-1].Revision; last != MaxJournalEntries+9 {
+		t.Fatalf("unexpected last revision %d", last)
+	}
+}
+
+func TestJournalConnectionLookups(t *testing.T) {
+	conn := SSHConnection{ID: "c1", Host: "web", Alias: "web"}
+	renamed := conn
+	renamed.Alias = "frontend"
+
+	journal := NewJournalFile()
+	journal.Record(JournalEntry{Changes: DiffConnectionFiles(NewConnectionFile(), mergeFixture(conn))})
+	journal.Record(JournalEntry{Changes: DiffConnectionFiles(mergeFixture(conn), mergeFixture(renamed))})
+	journal.Record(JournalEntry{Changes: DiffConnectionFiles(mergeFixture(renamed), NewConnectionFile())})
+
+	if id := journal.ConnectionIDByAlias("WEB"); id != "c1" {
+		t.Fatalf("expected old alias to resolve, got %q", id)
+	}
+	if id := journal.ConnectionIDByAlias("frontend"); id != "c1" {
+		t.Fatalf("expected removed connection to resolve, got %q", id)
+	}
+	if entries := journal.ForConnection("c1"); len(entries) != 3 || entries[2].Changes[0].Action() != "removed" {
+		t.Fatalf("unexpected connection history: %+v", entries)
+	}
+}
@@ -0,0 +1,412 @@
+package model
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// Entry kinds name what a merge conflict or journal change refers to.
+const (
+	EntryKindConnection = "connection"
+	EntryKindProfile    = "profile"
+)
+
+// MergeConflict is a change made differently on both sides of a three-way
+// merge. Field is the YAML name of the conflicting connection field; it is
+// empty when a whole entry conflicts (edited on one side, deleted on the
+// other, or a profile changed on both). A nil Ours or Theirs means the
+// entry was deleted on that side.
+type MergeConflict struct {
+	Kind   string
+	Key    string
+	Label  string
+	Field  string
+	Base   any
+	Ours   any
+	Theirs any
+}
+
+// MergeChoice picks the side of a conflict to keep.
+type MergeChoice int
+
+const (
+	MergeKeepOurs MergeChoice = iota
+	MergeTakeTheirs
+)
+
+func (c MergeConflict) String() string {
+	target := c.Label
+	if c.Field != "" {
+		target = fmt.Sprintf("%s, field %s", c.Label, c.Field)
+	}
+	if c.Field == "password" {
+		return fmt.Sprintf("%s %s: changed on both sides", c.Kind, target)
+	}
+	return fmt.Sprintf("%s %s: local %s, remote %s", c.Kind, target, describeMergeValue(c.Ours), describeMergeValue(c.Theirs))
+}
+
+// Describe returns a short description of one side of the conflict for
+// prompts. Passwords are never shown.
+func (c MergeConflict) Describe(value any) string {
+	if c.Field == "password" {
+		if value == "" {
+			return "(empty)"
+		}
+		return "(hidden)"
+	}
+	return describeMergeValue(value)
+}
+
+// MergeResult is a merged file that still holds "ours" for every conflict
+// until Resolve is called. Notes describe automatic fixes, such as aliases
+// renamed because both sides used them for different connections.
+type MergeResult struct {
+	File      ConnectionFile
+	Conflicts []MergeConflict
+	Notes     []string
+
+	theirs ConnectionFile
+}
+
+// MergeConnectionFiles merges ours and theirs against their common ancestor
+// base. Connections are matched by ID and merged field by field; profiles
+// are matched by name and merged as a whole.
+func MergeConnectionFiles(base, ours, theirs ConnectionFile) MergeResult {
+	result := MergeResult{theirs: theirs}
+	result.File.Version = ours.Version
+	if result.File.Version == "" {
+		result.File.Version = CurrentConnectionFileVersion
+	}
+
+	baseConns := connectionsByID(base.Connections)
+	theirConns := connectionsByID(theirs.Connections)
+	ourIDs := map[string]bool{}
+
+	result.File.Connections = []SSHConnection{}
+	for _, ourConn := range ours.Connections {
+		ourIDs[ourConn.ID] = true
+		baseConn, inBase := baseConns[ourConn.ID]
+		theirConn, inTheirs := theirConns[ourConn.ID]
+
+		switch {
+		case inTheirs:
+			var baseValue *SSHConnection
+			if inBase {
+				baseValue = &baseConn
+			}
+			merged, conflicts := mergeConnection(baseValue, ourConn, theirConn)
+			result.File.Connections = append(result.File.Connections, merged)
+			result.Conflicts = append(result.Conflicts, conflicts...)
+		case !inBase:
+			result.File.Connections = append(result.File.Connections, ourConn)
+		case reflect.DeepEqual(ourConn, baseConn):
+			// Deleted on their side and untouched here.
+		default:
+			result.File.Connections = append(result.File.Connections, ourConn)
+			result.Conflicts = append(result.Conflicts, MergeConflict{
+				Kind: EntryKindConnection, Key: ourConn.ID, Label: connectionMergeLabel(ourConn),
+				Base: baseConn, Ours: ourConn, Theirs: nil,
+			})
+		}
+	}
+
+	for _, theirConn := range theirs.Connections {
+		if ourIDs[theirConn.ID] {
+			continue
+		}
+		baseConn, inBase := baseConns[theirConn.ID]
+		switch {
+		case !inBase:
+			result.File.Connections = append(result.File.Connections, theirConn)
+		case reflect.DeepEqual(theirConn, baseConn):
+			// Deleted here and untouched on their side.
+		default:
+			result.Conflicts = append(result.Conflicts, MergeConflict{
+				Kind: EntryKindConnection, Key: theirConn.ID, Label: connectionMergeLabel(theirConn),
+				Base: baseConn, Ours: nil, Theirs: theirConn,
+			})
+		}
+	}
+
+	result.File.Profiles, result.Conflicts = mergeProfiles(base.Profiles, ours.Profiles, theirs.Profiles, result.Conflicts)
+	return result
+}
+
+// Resolve applies one choice per conflict (in order) and returns the final
+// file. Aliases that ended up on two connections are made unique.
+func (r MergeResult) Resolve(choices []MergeChoice) (ConnectionFile, []string, error) {
+	if len(choices) != len(r.Conflicts) {
+		return ConnectionFile{}, nil, fmt.Errorf("expected %d merge choices, got %d", len(r.Conflicts), len(choices))
+	}
+
+	file := r.File
+	file.Connections = append([]SSHConnection(nil), r.File.Connections...)
+	file.Profiles = append([]ConnectionProfile(nil), r.File.Profiles...)
+
+	for i, conflict := range r.Conflicts {
+		if choices[i] != MergeTakeTheirs {
+			continue
+		}
+		switch conflict.Kind {
+		case EntryKindConnection:
+			applyTheirConnection(&file, conflict)
+		case EntryKindProfile:
+			applyTheirProfile(&file, conflict)
+		}
+	}
+
+	notes := append([]string(nil), r.Notes...)
+	notes = append(notes, dedupeMergedAliases(&file)...)
+	return file, notes, nil
+}
+
+func applyTheirConnection(file *ConnectionFile, conflict MergeConflict) {
+	index := -1
+	for i := range file.Connections {
+		if file.Connections[i].ID == conflict.Key {
+			index = i
+			break
+		}
+	}
+
+	if conflict.Field == "" {
+		theirConn, kept := conflict.Theirs.(SSHConnection)
+		switch {
+		case !kept && index >= 0:
+			file.Connections = append(file.Connections[:index], file.Connections[index+1:]...)
+		case kept && index >= 0:
+			file.Connections[index] = theirConn
+		case kept:
+			file.Connections = append(file.Connections, theirConn)
+		}
+		return
+	}
+
+	if index < 0 {
+		return
+	}
+	field := connectionFieldByYAMLName(conflict.Field)
+	if field < 0 {
+		return
+	}
+	target := reflect.ValueOf(&file.Connections[index]).Elem().Field(field)
+	target.Set(reflect.ValueOf(conflict.Theirs))
+}
+
+func applyTheirProfile(file *ConnectionFile, conflict MergeConflict) {
+	index := -1
+	for i := range file.Profiles {
+		if normalizeProfileName(file.Profiles[i].Name) == normalizeProfileName(conflict.Key) {
+			index = i
+			break
+		}
+	}
+	theirProfile, kept := conflict.Theirs.(ConnectionProfile)
+	switch {
+	case !kept && index >= 0:
+		file.Profiles = append(file.Profiles[:index], file.Profiles[index+1:]...)
+	case kept && index >= 0:
+		file.Profiles[index] = theirProfile
+	case kept:
+		file.Profiles = append(file.Profiles, theirProfile)
+	}
+}
+
+// mergeConnection merges one connection present on both sides. base is nil
+// when both sides added the same ID independently.
+func mergeConnection(base *SSHConnection, ours, theirs SSHConnection) (SSHConnection, []MergeConflict) {
+	merged := ours
+	var conflicts []MergeConflict
+
+	ourValue := reflect.ValueOf(ours)
+	theirValue := reflect.ValueOf(theirs)
+	mergedValue := reflect.ValueOf(&merged).Elem()
+	connType := ourValue.Type()
+	for i := 0; i < connType.NumField(); i++ {
+		name := yamlFieldName(connType.Field(i))
+		if name == "id" {
+			continue
+		}
+		ourField := ourValue.Field(i).Interface()
+		theirField := theirValue.Field(i).Interface()
+		if reflect.DeepEqual(ourField, theirField) {
+			continue
+		}
+
+		var baseField any
+		if base != nil {
+			baseField = reflect.ValueOf(*base).Field(i).Interface()
+		} else {
+			baseField = reflect.Zero(connType.Field(i).Type).Interface()
+		}
+		switch {
+		case reflect.DeepEqual(ourField, baseField):
+			mergedValue.Field(i).Set(theirValue.Field(i))
+		case reflect.DeepEqual(theirField, baseField):
+			// Only we changed it; keep ours.
+		default:
+			conflicts = append(conflicts, MergeConflict{
+				Kind: EntryKindConnection, Key: ours.ID, Label: connectionMergeLabel(ours), Field: name,
+				Base: baseField, Ours: ourField, Theirs: theirField,
+			})
+		}
+	}
+	return merged, conflicts
+}
+
+func mergeProfiles(base, ours, theirs []ConnectionProfile, conflicts []MergeConflict) ([]ConnectionProfile, []MergeConflict) {
+	baseProfiles := profilesByName(base)
+	theirProfiles := profilesByName(theirs)
+	ourNames := map[string]bool{}
+
+	var merged []ConnectionProfile
+	for _, ourProfile := range ours {
+		key := normalizeProfileName(ourProfile.Name)
+		ourNames[key] = true
+		baseProfile, inBase := baseProfiles[key]
+		theirProfile, inTheirs := theirProfiles[key]
+
+		switch {
+		case inTheirs && reflect.DeepEqual(ourProfile, theirProfile):
+			merged = append(merged, ourProfile)
+		case inTheirs && inBase && reflect.DeepEqual(ourProfile, baseProfile):
+			merged = append(merged, theirProfile)
+		case inTheirs && inBase && reflect.DeepEqual(theirProfile, baseProfile):
+			merged = append(merged, ourProfile)
+		case inTheirs:
+			merged = append(merged, ourProfile)
+			conflicts = append(conflicts, profileConflict(ourProfile.Name, baseProfiles, key, ourProfile, theirProfile))
+		case !inBase:
+			merged = append(merged, ourProfile)
+		case reflect.DeepEqual(ourProfile, baseProfile):
+			// Deleted on their side and untouched here.
+		default:
+			merged = append(merged, ourProfile)
+			conflicts = append(conflicts, profileConflict(ourProfile.Name, baseProfiles, key, ourProfile, nil))
+		}
+	}
+
+	for _, theirProfile := range theirs {
+		key := normalizeProfileName(theirProfile.Name)
+		if ourNames[key] {
+			continue
+		}
+		baseProfile, inBase := baseProfiles[key]
+		switch {
+		case !inBase:
+			merged = append(merged, theirProfile)
+		case reflect.DeepEqual(theirProfile, baseProfile):
+			// Deleted here and untouched on their side.
+		default:
+			conflicts = append(conflicts, profileConflict(theirProfile.Name, baseProfiles, key, nil, theirProfile))
+		}
+	}
+	return merged, conflicts
+}
+
+func profileConflict(name string, baseProfiles map[string]ConnectionProfile, key string, ours, theirs any) MergeConflict {
+	conflict := MergeConflict{Kind: EntryKindProfile, Key: name, Label: name, Ours: ours, Theirs: theirs}
+	if baseProfile, ok := baseProfiles[key]; ok {
+		conflict.Base = baseProfile
+	}
+	return conflict
+}
+
+// dedupeMergedAliases renames later connections whose alias is already
+// used, which happens when both sides picked the same alias for different
+// connections.
+func dedupeMergedAliases(file *ConnectionFile) []string {
+	var notes []string
+	seen := map[string]bool{}
+	for i := range file.Connections {
+		alias := normalizeAlias(file.Connections[i].Alias)
+		if alias == "" {
+			continue
+		}
+		if !seen[alias] {
+			seen[alias] = true
+			continue
+		}
+		renamed := file.Connections[i].Alias + "-" + shortMergeID(file.Connections[i].ID)
+		for n := 2; seen[normalizeAlias(renamed)]; n++ {
+			renamed = fmt.Sprintf("%s-%s-%d", file.Connections[i].Alias, shortMergeID(file.Connections[i].ID), n)
+		}
+		notes = append(notes, fmt.Sprintf("alias %q was used by two connections; renamed %s to %q", file.Connections[i].Alias, file.Connections[i].ID, renamed))
+		file.Connections[i].Alias = renamed
+		seen[normalizeAlias(renamed)] = true
+	}
+	return notes
+}
+
+func shortMergeID(id string) string {
+	if len(id) > 6 {
+		return id[:6]
+	}
+	return id
+}
+
+func connectionsByID(conns []SSHConnection) map[string]SSHConnection {
+	byID := make(map[string]SSHConnection, len(conns))
+	for _, conn := range conns {
+		byID[conn.ID] = conn
+	}
+	return byID
+}
+
+func profilesByName(profiles []ConnectionProfile) map[string]ConnectionProfile {
+	byName := make(map[string]ConnectionProfile, len(profiles))
+	for _, profile := range profiles {
+		byName[normalizeProfileName(profile.Name)] = profile
+	}
+	return byName
+}
+
+func connectionMergeLabel(conn SSHConnection) string {
+	target := fmt.Sprintf("%s@%s", conn.Username, conn.Host)
+	if alias := strings.TrimSpace(conn.Alias); alias != "" {
+		return fmt.Sprintf("%s (%s)", alias, target)
+	}
+	return target
+}
+
+func connectionFieldByYAMLName(name string) int {
+	connType := reflect.TypeOf(SSHConnection{})
+	for i := 0; i < connType.NumField(); i++ {
+		if yamlFieldName(connType.Field(i)) == name {
+			return i
+		}
+	}
+	return -1
+}
+
+func yamlFieldName(field reflect.StructField) string {
+	name, _, _ := strings.Cut(field.Tag.Get("yaml"), ",")
+	if name == "" {
+		return field.Name
+	}
+	return name
+}
+
+func describeMergeValue(value any) string {
+	switch v := value.(type) {
+	case nil:
+		return "(deleted)"
+	case SSHConnection:
+		return connectionMergeLabel(v)
+	case ConnectionProfile:
+		return fmt.Sprintf("profile %s", v.Name)
+	case string:
+		if v == "" {
+			return "(empty)"
+		}
+		return fmt.Sprintf("%q", v)
+	case []string:
+		if len(v) == 0 {
+			return "(empty)"
+		}
+		return fmt.Sprintf("[%s]", strings.Join(v, ", "))
+	default:
+		return fmt.Sprintf("%v", v)
+	}
+}
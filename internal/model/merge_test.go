@@ -0,0 +1,166 @@
+package model
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func mergeFixture(conns ...SSHConnection) ConnectionFile {
+	file := NewConnectionFile()
+	file.Connections = conns
+	return file
+}
+
+func TestMergeConnectionFilesCombinesIndependentChanges(t *testing.T) {
+	web := SSHConnection{ID: "c1", Username: "ops", Host: "web", Port: 22, Alias: "web"}
+	db := SSHConnection{ID: "c2", Username: "ops", Host: "db", Port: 22, Alias: "db"}
+	old := SSHConnection{ID: "c3", Username: "ops", Host: "old", Alias: "old"}
+	base := mergeFixture(web, db, old)
+
+	oursWeb := web
+	oursWeb.Port = 2222
+	theirsWeb := web
+	theirsWeb.Tags = []string{"prod"}
+	theirsDB := db
+	theirsDB.Description = "primary"
+	added := SSHConnection{ID: "c4", Username: "ops", Host: "cache", Alias: "cache"}
+
+	ours := mergeFixture(oursWeb, db, old)
+	theirs := mergeFixture(theirsWeb, theirsDB, added)
+
+	result := MergeConnectionFiles(base, ours, theirs)
+	if len(result.Conflicts) != 0 {
+		t.Fatalf("expected no conflicts, got %v", result.Conflicts)
+	}
+	merged, _, err := result.Resolve(nil)
+	if err != nil {
+		t.Fatalf("Resolve failed: %v", err)
+	}
+
+	if len(merged.Connections) != 3 {
+		t.Fatalf("expected web, db and cache, got %+v", merged.Connections)
+	}
+	gotWeb := merged.GetConnectionByID("c1")
+	if gotWeb.Port != 2222 || !reflect.DeepEqual(gotWeb.Tags, []string{"prod"}) {
+		t.Fatalf("expected both web changes, got %+v", gotWeb)
+	}
+	if merged.GetConnectionByID("c2").Description != "primary" {
+		t.Fatal("expected remote db change")
+	}
+	if merged.GetConnectionByID("c3") != nil {
+		t.Fatal("expected connection deleted remotely to be gone")
+	}
+	if merged.GetConnectionByID("c4") == nil {
+		t.Fatal("expected connection added remotely")
+	}
+}
+
+func TestMergeConnectionFilesReportsFieldConflicts(t *testing.T) {
+	base := mergeFixture(SSHConnection{ID: "c1", Username: "ops", Host: "web", Password: "old", Alias: "web"})
+	ours := mergeFixture(SSHConnection{ID: "c1", Username: "admin", Host: "web-a", Password: "local-secret", Alias: "web"})
+	theirs := mergeFixture(SSHConnection{ID: "c1", Username: "root", Host: "web-b", Password: "remote-secret", Alias: "web"})
+
+	result := MergeConnectionFiles(base, ours, theirs)
+	fields := []string{}
+	for _, conflict := range result.Conflicts {
+		fields = append(fields, conflict.Field)
+	}
+	if !reflect.DeepEqual(fields, []string{"username", "host", "password"}) {
+		t.Fatalf("unexpected conflicts: %v", result.Conflicts)
+	}
+	for _, conflict := range result.Conflicts {
+		if strings.Contains(conflict.String(), "secret") || strings.Contains(conflict.Describe(conflict.Theirs), "secret") {
+			t.Fatalf("password leaked in conflict description: %q", conflict.String())
+		}
+	}
+
+	merged, _, err := result.Resolve([]MergeChoice{MergeKeepOurs, MergeTakeTheirs, MergeTakeTheirs})
+	if err != nil {
+		t.Fatalf("Resolve failed: %v", err)
+	}
+	got := merged.GetConnectionByID("c1")
+	if got.Username != "admin" || got.Host != "web-b" || got.Password != "remote-secret" {
+		t.Fatalf("unexpected resolution: %+v", got)
+	}
+
+	if _, _, err := result.Resolve(nil); err == nil {
+		t.Fatal("expected error for missing choices")
+	}
+}
+
+func TestMergeConnectionFilesDeleteVersusEdit(t *testing.T) {
+	conn := SSHConnection{ID: "c1", Username: "ops", Host: "web", Alias: "web"}
+	edited := conn
+	edited.Port = 2200
+
+	t.Run("deleted remotely", func(t *testing.T) {
+		result := MergeConnectionFiles(mergeFixture(conn), mergeFixture(edited), mergeFixture())
+		if len(result.Conflicts) != 1 || result.Conflicts[0].Field != "" || result.Conflicts[0].Theirs != nil {
+			t.Fatalf("expected whole-entry conflict, got %v", result.Conflicts)
+		}
+		kept, _, _ := result.Resolve([]MergeChoice{MergeKeepOurs})
+		if kept.GetConnectionByID("c1") == nil {
+			t.Fatal("keeping ours should keep the edited connection")
+		}
+		dropped, _, _ := result.Resolve([]MergeChoice{MergeTakeTheirs})
+		if dropped.GetConnectionByID("c1") != nil {
+			t.Fatal("taking theirs should delete the connection")
+		}
+	})
+
+	t.Run("deleted locally", func(t *testing.T) {
+		result := MergeConnectionFiles(mergeFixture(conn), mergeFixture(), mergeFixture(edited))
+		if len(result.Conflicts) != 1 || result.Conflicts[0].Ours != nil {
+			t.Fatalf("expected whole-entry conflict, got %v", result.Conflicts)
+		}
+		restored, _, _ := result.Resolve([]MergeChoice{MergeTakeTheirs})
+		if got := restored.GetConnectionByID("c1"); got == nil || got.Port != 2200 {
+			t.Fatalf("taking theirs should restore the remote edit, got %+v", got)
+		}
+	})
+}
+
+func TestMergeConnectionFilesRenamesClashingAliases(t *testing.T) {
+	ours := mergeFixture(SSHConnection{ID: "aaaaaaaa-1", Username: "ops", Host: "a", Alias: "app"})
+	theirs := mergeFixture(SSHConnection{ID: "bbbbbbbb-2", Username: "ops", Host: "b", Alias: "app"})
+
+	result := MergeConnectionFiles(NewConnectionFile(), ours, theirs)
+	merged, notes, err := result.Resolve(nil)
+	if err != nil {
+		t.Fatalf("Resolve failed: %v", err)
+	}
+	if merged.GetConnectionByID("aaaaaaaa-1").Alias != "app" || merged.GetConnectionByID("bbbbbbbb-2").Alias != "app-bbbbbb" {
+		t.Fatalf("unexpected aliases: %+v", merged.Connections)
+	}
+	if len(notes) != 1 {
+		t.Fatalf("expected one rename note, got %v", notes)
+	}
+}
+
+func TestMergeConnectionFilesMergesProfilesByName(t *testing.T) {
+	base := NewConnectionFile()
+	base.Profiles = []ConnectionProfile{{Name: "prod", Username: "ops"}}
+	ours := NewConnectionFile()
+	ours.Profiles = []ConnectionProfile{{Name: "prod", Username: "ops"}, {Name: "dev", Username: "me"}}
+	theirs := NewConnectionFile()
+	theirs.Profiles = []ConnectionProfile{{Name: "prod", Username: "deploy"}}
+
+	result := MergeConnectionFiles(base, ours, theirs)
+	if len(result.Conflicts) != 0 {
+		t.Fatalf("expected no conflicts, got %v", result.Conflicts)
+	}
+	if !reflect.DeepEqual(result.File.Profiles, []ConnectionProfile{{Name: "prod", Username: "deploy"}, {Name: "dev", Username: "me"}}) {
+		t.Fatalf("unexpected profiles: %+v", result.File.Profiles)
+	}
+
+	ours.Profiles[0].Username = "admin"
+	result = MergeConnectionFiles(base, ours, theirs)
+	if len(result.Conflicts) != 1 || result.Conflicts[0].Kind != EntryKindProfile {
+		t.Fatalf("expected profile conflict, got %v", result.Conflicts)
+	}
+	merged, _, _ := result.Resolve([]MergeChoice{MergeTakeTheirs})
+	if merged.Profiles[0].Username != "deploy" {
+		t.Fatalf("expected remote profile, got %+v", merged.Profiles)
+	}
+}
@@ -0,0 +1,213 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+const maxProfileNameLength = 64
+
+var (
+	ErrProfileNotFound      = errors.New("profile not found")
+	ErrProfileAlreadyExists = errors.New("profile already exists")
+	ErrProfileCycle         = errors.New("profile inheritance cycle")
+
+	profileNamePattern = regexp.MustCompile(`^[A-Za-z0-9._-]+$`)
+)
+
+// ConnectionProfile holds shared connection settings that connections (and
+// other profiles) inherit through their extends field. Values set on the
+// extending entry always win; auth settings are inherited as a unit so a
+// profile password never mixes with a connection identity file.
+type ConnectionProfile struct {
+	Name            string   `yaml:"name" json:"name"`
+	Extends         string   `yaml:"extends,omitempty" json:"extends,omitempty"`
+	Description     string   `yaml:"description,omitempty" json:"description,omitempty"`
+	Username        string   `yaml:"username,omitempty" json:"username,omitempty"`
+	Port            int      `yaml:"port,omitempty" json:"port,omitempty"`
+	AuthMode        string   `yaml:"authMode,omitempty" json:"authMode,omitempty"`
+	Password        string   `yaml:"password,omitempty" json:"password,omitempty"`
+	PasswordSource  string   `yaml:"passwordSource,omitempty" json:"passwordSource,omitempty"`
+	IdentityFile    string   `yaml:"identityFile,omitempty" json:"identityFile,omitempty"`
+	ProxyJump       string   `yaml:"proxyJump,omitempty" json:"proxyJump,omitempty"`
+	LocalForwards   []string `yaml:"localForwards,omitempty" json:"localForwards,omitempty"`
+	RemoteForwards  []string `yaml:"remoteForwards,omitempty" json:"remoteForwards,omitempty"`
+	DynamicForwards []string `yaml:"dynamicForwards,omitempty" json:"dynamicForwards,omitempty"`
+	ExtraSSHArgs    []string `yaml:"extraSSHArgs,omitempty" json:"extraSSHArgs,omitempty"`
+}
+
+func ValidateProfileName(name string) error {
+	trimmed := strings.TrimSpace(name)
+	if trimmed == "" {
+		return errors.New("profile name is required")
+	}
+	if len(trimmed) > maxProfileNameLength {
+		return fmt.Errorf("profile name must be %d characters or fewer", maxProfileNameLength)
+	}
+	if !profileNamePattern.MatchString(trimmed) {
+		return fmt.Errorf("profile name may only contain letters, numbers, '.', '_', or '-'")
+	}
+	return nil
+}
+
+func (c *ConnectionFile) GetProfile(name string) *ConnectionProfile {
+	needle := normalizeProfileName(name)
+	if needle == "" {
+		return nil
+	}
+	for i := range c.Profiles {
+		if normalizeProfileName(c.Profiles[i].Name) == needle {
+			return &c.Profiles[i]
+		}
+	}
+	return nil
+}
+
+func (c *ConnectionFile) AddProfile(profile ConnectionProfile) error {
+	profile.Name = strings.TrimSpace(profile.Name)
+	if err := ValidateProfileName(profile.Name); err != nil {
+		return err
+	}
+	if c.GetProfile(profile.Name) != nil {
+		return fmt.Errorf("%w: %s", ErrProfileAlreadyExists, profile.Name)
+	}
+	c.Profiles = append(c.Profiles, profile)
+	if _, err := c.ProfileChain(profile.Name); err != nil {
+		c.Profiles = c.Profiles[:len(c.Profiles)-1]
+		return err
+	}
+	return nil
+}
+
+// UpdateProfile replaces the named profile, keeping its name. Updates that
+// would introduce an inheritance cycle are rejected.
+func (c *ConnectionFile) UpdateProfile(name string, updated ConnectionProfile) (bool, error) {
+	current := c.GetProfile(name)
+	if current == nil {
+		return false, nil
+	}
+	previous := *current
+	updated.Name = previous.Name
+	*current = updated
+	if _, err := c.ProfileChain(updated.Name); err != nil {
+		*current = previous
+		return true, err
+	}
+	return true, nil
+}
+
+func (c *ConnectionFile) RemoveProfile(name string) bool {
+	needle := normalizeProfileName(name)
+	for i := range c.Profiles {
+		if normalizeProfileName(c.Profiles[i].Name) == needle {
+			c.Profiles = append(c.Profiles[:i], c.Profiles[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// ProfileDependents lists the profiles and connections that extend name
+// directly. Connections are reported by alias, or by ID when unaliased.
+func (c *ConnectionFile) ProfileDependents(name string) (profiles []string, connections []string) {
+	needle := normalizeProfileName(name)
+	for _, profile := range c.Profiles {
+		if normalizeProfileName(profile.Extends) == needle {
+			profiles = append(profiles, profile.Name)
+		}
+	}
+	for _, conn := range c.Connections {
+		if normalizeProfileName(conn.Extends) != needle {
+			continue
+		}
+		if alias := strings.TrimSpace(conn.Alias); alias != "" {
+			connections = append(connections, alias)
+		} else {
+			connections = append(connections, conn.ID)
+		}
+	}
+	return profiles, connections
+}
+
+// ProfileChain returns the named profile followed by every profile it
+// extends, nearest first. An empty name yields an empty chain.
+func (c *ConnectionFile) ProfileChain(name string) ([]ConnectionProfile, error) {
+	var chain []ConnectionProfile
+	var path []string
+	seen := map[string]struct{}{}
+	for next := strings.TrimSpace(name); next != ""; {
+		key := normalizeProfileName(next)
+		if _, exists := seen[key]; exists {
+			return nil, fmt.Errorf("%w: %s", ErrProfileCycle, strings.Join(append(path, next), " -> "))
+		}
+		seen[key] = struct{}{}
+		path = append(path, next)
+
+		profile := c.GetProfile(next)
+		if profile == nil {
+			return nil, fmt.Errorf("%w: %s", ErrProfileNotFound, next)
+		}
+		chain = append(chain, *profile)
+		next = strings.TrimSpace(profile.Extends)
+	}
+	return chain, nil
+}
+
+// ResolveConnection returns conn with every unset field filled in from its
+// profile chain. Connections without extends are returned unchanged.
+func (c *ConnectionFile) ResolveConnection(conn SSHConnection) (SSHConnection, error) {
+	chain, err := c.ProfileChain(conn.Extends)
+	if err != nil {
+		return conn, err
+	}
+	resolved := conn
+	for _, profile := range chain {
+		profile.applyTo(&resolved)
+	}
+	return resolved, nil
+}
+
+func (p ConnectionProfile) applyTo(conn *SSHConnection) {
+	if strings.TrimSpace(conn.Username) == "" {
+		conn.Username = strings.TrimSpace(p.Username)
+	}
+	if conn.Port <= 0 {
+		conn.Port = p.Port
+	}
+	if !HasAuthSettings(conn.AuthMode, conn.Password, conn.PasswordSource, conn.IdentityFile) {
+		conn.AuthMode = p.AuthMode
+		conn.Password = p.Password
+		conn.PasswordSource = p.PasswordSource
+		conn.IdentityFile = p.IdentityFile
+	}
+	if strings.TrimSpace(conn.ProxyJump) == "" {
+		conn.ProxyJump = p.ProxyJump
+	}
+	if len(conn.LocalForwards) == 0 {
+		conn.LocalForwards = append([]string(nil), p.LocalForwards...)
+	}
+	if len(conn.RemoteForwards) == 0 {
+		conn.RemoteForwards = append([]string(nil), p.RemoteForwards...)
+	}
+	if len(conn.DynamicForwards) == 0 {
+		conn.DynamicForwards = append([]string(nil), p.DynamicForwards...)
+	}
+	if len(conn.ExtraSSHArgs) == 0 {
+		conn.ExtraSSHArgs = append([]string(nil), p.ExtraSSHArgs...)
+	}
+}
+
+// HasAuthSettings reports whether any auth field is set. Auth fields are
+// inherited from profiles only as a unit.
+func HasAuthSettings(mode, password, passwordSource, identityFile string) bool {
+	return strings.TrimSpace(mode) != "" ||
+		strings.TrimSpace(password) != "" ||
+		IsExternalPasswordSource(passwordSource) ||
+		strings.TrimSpace(identityFile) != ""
+}
+
+func normalizeProfileName(name string) string {
+	return strings.ToLower(strings.TrimSpace(name))
+}
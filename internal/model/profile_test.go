@@ -0,0 +1,121 @@
+package model
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func profileFixture() ConnectionFile {
+	file := NewConnectionFile()
+	file.Profiles = []ConnectionProfile{
+		{Name: "base", Username: "ops", Port: 2222, AuthMode: AuthModeKey, IdentityFile: "~/.ssh/ops", ExtraSSHArgs: []string{"-A"}},
+		{Name: "prod", Extends: "base", ProxyJump: "bastion.internal", LocalForwards: []string{"8080:localhost:80"}},
+	}
+	return file
+}
+
+func TestResolveConnectionInheritsThroughChain(t *testing.T) {
+	file := profileFixture()
+	resolved, err := file.ResolveConnection(SSHConnection{Host: "web1", Extends: "PROD"})
+	if err != nil {
+		t.Fatalf("ResolveConnection failed: %v", err)
+	}
+
+	if resolved.Username != "ops" || resolved.Port != 2222 || resolved.ProxyJump != "bastion.internal" {
+		t.Fatalf("unexpected scalar fields: %+v", resolved)
+	}
+	if resolved.EffectiveAuthMode() != AuthModeKey || resolved.IdentityFile != "~/.ssh/ops" {
+		t.Fatalf("expected key auth from base profile, got %+v", resolved)
+	}
+	if !reflect.DeepEqual(resolved.LocalForwards, []string{"8080:localhost:80"}) || !reflect.DeepEqual(resolved.ExtraSSHArgs, []string{"-A"}) {
+		t.Fatalf("unexpected list fields: %+v", resolved)
+	}
+	if resolved.Extends != "PROD" {
+		t.Fatalf("expected raw extends to be kept, got %q", resolved.Extends)
+	}
+}
+
+func TestResolveConnectionPrefersConnectionValues(t *testing.T) {
+	file := profileFixture()
+	resolved, err := file.ResolveConnection(SSHConnection{
+		Host:         "web1",
+		Username:     "deploy",
+		Port:         22,
+		Password:     "secret",
+		ExtraSSHArgs: []string{"-C"},
+		Extends:      "prod",
+	})
+	if err != nil {
+		t.Fatalf("ResolveConnection failed: %v", err)
+	}
+
+	if resolved.Username != "deploy" || resolved.Port != 22 {
+		t.Fatalf("expected connection values to win, got %+v", resolved)
+	}
+	if resolved.EffectiveAuthMode() != AuthModePassword || resolved.IdentityFile != "" {
+		t.Fatalf("expected auth settings to be kept as a unit, got %+v", resolved)
+	}
+	if !reflect.DeepEqual(resolved.ExtraSSHArgs, []string{"-C"}) {
+		t.Fatalf("expected connection extra args to replace profile ones, got %v", resolved.ExtraSSHArgs)
+	}
+}
+
+func TestResolveConnectionReportsMissingAndCyclicProfiles(t *testing.T) {
+	file := profileFixture()
+	if _, err := file.ResolveConnection(SSHConnection{Host: "h", Extends: "nope"}); !errors.Is(err, ErrProfileNotFound) {
+		t.Fatalf("expected ErrProfileNotFound, got %v", err)
+	}
+
+	file.Profiles[0].Extends = "prod"
+	_, err := file.ResolveConnection(SSHConnection{Host: "h", Extends: "prod"})
+	if !errors.Is(err, ErrProfileCycle) {
+		t.Fatalf("expected ErrProfileCycle, got %v", err)
+	}
+	if !strings.Contains(err.Error(), "prod -> base -> prod") {
+		t.Fatalf("expected cycle path in error, got %v", err)
+	}
+}
+
+func TestAddAndUpdateProfileRejectCycles(t *testing.T) {
+	file := profileFixture()
+	if err := file.AddProfile(ConnectionProfile{Name: "Base"}); !errors.Is(err, ErrProfileAlreadyExists) {
+		t.Fatalf("expected ErrProfileAlreadyExists, got %v", err)
+	}
+	if err := file.AddProfile(ConnectionProfile{Name: "bad name"}); err == nil {
+		t.Fatal("expected invalid profile name error")
+	}
+	if err := file.AddProfile(ConnectionProfile{Name: "orphan", Extends: "missing"}); !errors.Is(err, ErrProfileNotFound) {
+		t.Fatalf("expected ErrProfileNotFound, got %v", err)
+	}
+	if len(file.Profiles) != 2 {
+		t.Fatalf("rejected profiles must not be stored, got %d", len(file.Profiles))
+	}
+
+	found, err := file.UpdateProfile("base", ConnectionProfile{Name: "ignored", Extends: "prod"})
+	if !found || !errors.Is(err, ErrProfileCycle) {
+		t.Fatalf("expected cycle rejection, got found=%t err=%v", found, err)
+	}
+	if file.Profiles[0].Extends != "" || file.Profiles[0].Username != "ops" {
+		t.Fatalf("rejected update must be rolled back, got %+v", file.Profiles[0])
+	}
+}
+
+func TestProfileDependents(t *testing.T) {
+	file := profileFixture()
+	file.Connections = []SSHConnection{
+		{ID: "a", Host: "h1", Alias: "web1", Extends: "prod"},
+		{ID: "b", Host: "h2", Extends: "prod"},
+		{ID: "c", Host: "h3", Username: "u"},
+	}
+
+	profiles, connections := file.ProfileDependents("base")
+	if !reflect.DeepEqual(profiles, []string{"prod"}) || len(connections) != 0 {
+		t.Fatalf("unexpected base dependents: %v %v", profiles, connections)
+	}
+	profiles, connections = file.ProfileDependents("prod")
+	if len(profiles) != 0 || !reflect.DeepEqual(connections, []string{"web1", "b"}) {
+		t.Fatalf("unexpected prod dependents: %v %v", profiles, connections)
+	}
+}
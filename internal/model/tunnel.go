@@ -0,0 +1,79 @@
+package model
+
+import (
+	"strings"
+	"time"
+)
+
+const (
+	TunnelStatusStarting   = "starting"
+	TunnelStatusUp         = "up"
+	TunnelStatusRestarting = "restarting"
+	TunnelStatusFailed     = "failed"
+)
+
+// TunnelStateFile lists the background tunnels of a vault. It is stored in
+// plaintext, so it only records what is needed to find and report the
+// processes: no forward destinations or credentials.
+type TunnelStateFile struct {
+	Tunnels []TunnelState `json:"tunnels"`
+}
+
+// TunnelState describes one tunnel supervisor and its current attempt.
+type TunnelState struct {
+	ConnectionID string    `json:"connectionId"`
+	Alias        string    `json:"alias,omitempty"`
+	Backend      string    `json:"backend"`
+	PID          int       `json:"pid"`
+	SSHPID       int       `json:"sshPid,omitempty"`
+	LocalListen  []string  `json:"localListen,omitempty"`
+	RemoteListen []string  `json:"remoteListen,omitempty"`
+	Status       string    `json:"status"`
+	StartedAt    time.Time `json:"startedAt"`
+	ConnectedAt  time.Time `json:"connectedAt,omitzero"`
+	NextRetryAt  time.Time `json:"nextRetryAt,omitzero"`
+	Restarts     int       `json:"restarts"`
+	LastError    string    `json:"lastError,omitempty"`
+}
+
+// Get returns the tunnel of connectionID, or nil.
+func (f *TunnelStateFile) Get(connectionID string) *TunnelState {
+	for i := range f.Tunnels {
+		if f.Tunnels[i].ConnectionID == connectionID {
+			return &f.Tunnels[i]
+		}
+	}
+	return nil
+}
+
+// Put adds state or replaces the tunnel of the same connection.
+func (f *TunnelStateFile) Put(state TunnelState) {
+	if existing := f.Get(state.ConnectionID); existing != nil {
+		*existing = state
+		return
+	}
+	f.Tunnels = append(f.Tunnels, state)
+}
+
+// Remove drops the tunnel of connectionID and reports whether it existed.
+func (f *TunnelStateFile) Remove(connectionID string) bool {
+	for i := range f.Tunnels {
+		if f.Tunnels[i].ConnectionID == connectionID {
+			f.Tunnels = append(f.Tunnels[:i], f.Tunnels[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// FindLocalListener returns the tunnel listening on the local address addr.
+func (f *TunnelStateFile) FindLocalListener(addr string) *TunnelState {
+	for i := range f.Tunnels {
+		for _, listen := range f.Tunnels[i].LocalListen {
+			if strings.EqualFold(listen, addr) {
+				return &f.Tunnels[i]
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,398 @@
+// Package nativessh implements an in-process SSH client used by the "native"
+// connect backend as an alternative to shelling out to ssh/sshpass.
+package nativessh
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/emirhangumus/sshmanager/internal/model"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+const defaultDialTimeout = 15 * time.Second
+
+// Options controls how a native connection is established and wired to the
+// local terminal. Zero values fall back to the process stdio and the user's
+// ~/.ssh/known_hosts file.
+type Options struct {
+	Stdin  io.Reader
+	Stdout io.Writer
+	Stderr io.Writer
+
+	// HostKeyCallback verifies server host keys for every ProxyJump hop and
+	// for targets without pinned host keys.
+	HostKeyCallback ssh.HostKeyCallback
+
+	// KnownHostsFile verifies targets without pinned host keys when
+	// HostKeyCallback is nil. Jump hops keep ~/.ssh/known_hosts, as they
+	// do with ssh -J.
+	KnownHostsFile string
+
+	// Password returns the password for password auth. It is called once
+	// per dial; conn.Password is used when it is nil.
+	Password func() (string, error)
+
+	// PassphrasePrompt is asked for the passphrase of encrypted identity
+	// files. Encrypted keys fail to load when it is nil.
+	PassphrasePrompt func(identityFile string) ([]byte, error)
+
+	DialTimeout time.Duration
+}
+
+// Client is an authenticated SSH client, possibly tunnelled through one or
+// more ProxyJump hops, plus any forwards started on it.
+type Client struct {
+	*ssh.Client
+
+	hops    []*ssh.Client
+	closers []io.Closer
+}
+
+// Dial authenticates to conn, honouring its auth mode and ProxyJump chain.
+func Dial(conn *model.SSHConnection, opts Options) (*Client, error) {
+	opts = opts.withDefaults()
+
+	username := strings.TrimSpace(conn.Username)
+	host := strings.TrimSpace(conn.Host)
+	if username == "" || host == "" {
+		return nil, fmt.Errorf("username and host are required")
+	}
+	if err := model.ValidateProxyJump(conn.ProxyJump); err != nil {
+		return nil, fmt.Errorf("invalid proxy jump: %w", err)
+	}
+
+	// A target with pinned host keys is verified against them only, other
+	// targets against KnownHostsFile when set; jump hops keep the regular
+	// callback.
+	targetHostKeyCallback := opts.HostKeyCallback
+	if len(conn.HostKeys) > 0 {
+		targetHostKeyCallback = PinnedHostKeyCallback(conn.HostKeys)
+	} else if targetHostKeyCallback == nil && strings.TrimSpace(opts.KnownHostsFile) != "" {
+		var err error
+		targetHostKeyCallback, err = knownHostsFileCallback(opts.KnownHostsFile)
+		if err != nil {
+			return nil, err
+		}
+	}
+	hostKeyCallback := opts.HostKeyCallback
+	if hostKeyCallback == nil && (targetHostKeyCallback == nil || strings.TrimSpace(conn.ProxyJump) != "") {
+		var err error
+		hostKeyCallback, err = defaultHostKeyCallback()
+		if err != nil {
+			return nil, err
+		}
+	}
+	if targetHostKeyCallback == nil {
+		targetHostKeyCallback = hostKeyCallback
+	}
+
+	auth, authCloser, err := authMethods(conn, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	client := &Client{}
+	if authCloser != nil {
+		client.closers = append(client.closers, authCloser)
+	}
+	var upstream *ssh.Client
+	if proxyJump := strings.TrimSpace(conn.ProxyJump); proxyJump != "" {
+		// Jump hops never see the target's password: like ssh, they
+		// authenticate with keys only and default to the local user.
+		hopAuth := auth
+		if conn.EffectiveAuthMode() == model.AuthModePassword {
+			var hopCloser io.Closer
+			hopAuth, hopCloser = jumpHopAuthMethods()
+			if hopCloser != nil {
+				client.closers = append(client.closers, hopCloser)
+			}
+		}
+		hopDefaultUser := localUsername(username)
+		for _, hop := range strings.Split(proxyJump, ",") {
+			hopUser, hopAddr := parseJumpHop(strings.TrimSpace(hop), hopDefaultUser)
+			hopClient, err := dialHop(upstream, hopAddr, &ssh.ClientConfig{
+				User:            hopUser,
+				Auth:            hopAuth,
+				HostKeyCallback: hostKeyCallback,
+				Timeout:         opts.DialTimeout,
+			})
+			if err != nil {
+				_ = client.Close()
+				return nil, fmt.Errorf("proxy jump %s: %w", hopAddr, err)
+			}
+			client.hops = append(client.hops, hopClient)
+			upstream = hopClient
+		}
+	}
+
+	target := net.JoinHostPort(trimIPv6Brackets(host), strconv.Itoa(conn.EffectivePort()))
+	sshClient, err := dialHop(upstream, target, &ssh.ClientConfig{
+		User:            username,
+		Auth:            auth,
+		HostKeyCallback: targetHostKeyCallback,
+		Timeout:         opts.DialTimeout,
+	})
+	if err != nil {
+		_ = client.Close()
+		return nil, err
+	}
+	client.Client = sshClient
+	return client, nil
+}
+
+// Close tears down forwards, the target connection and every jump hop.
+func (c *Client) Close() error {
+	var errs []error
+	for i := len(c.closers) - 1; i >= 0; i-- {
+		if err := c.closers[i].Close(); err != nil && !errors.Is(err, net.ErrClosed) {
+			errs = append(errs, err)
+		}
+	}
+	c.closers = nil
+
+	if c.Client != nil {
+		if err := c.Client.Close(); err != nil && !errors.Is(err, net.ErrClosed) {
+			errs = append(errs, err)
+		}
+	}
+	for i := len(c.hops) - 1; i >= 0; i-- {
+		if err := c.hops[i].Close(); err != nil && !errors.Is(err, net.ErrClosed) {
+			errs = append(errs, err)
+		}
+	}
+	c.hops = nil
+	return errors.Join(errs...)
+}
+
+func (o Options) withDefaults() Options {
+	if o.Stdin == nil {
+		o.Stdin = os.Stdin
+	}
+	if o.Stdout == nil {
+		o.Stdout = os.Stdout
+	}
+	if o.Stderr == nil {
+		o.Stderr = os.Stderr
+	}
+	if o.DialTimeout <= 0 {
+		o.DialTimeout = defaultDialTimeout
+	}
+	return o
+}
+
+func dialHop(upstream *ssh.Client, addr string, cfg *ssh.ClientConfig) (*ssh.Client, error) {
+	if upstream == nil {
+		return ssh.Dial("tcp", addr, cfg)
+	}
+
+	netConn, err := upstream.Dial("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	clientConn, chans, reqs, err := ssh.NewClientConn(netConn, addr, cfg)
+	if err != nil {
+		_ = netConn.Close()
+		return nil, err
+	}
+	return ssh.NewClient(clientConn, chans, reqs), nil
+}
+
+// authMethods builds the auth methods for conn. The returned closer, when
+// non-nil, keeps an ssh-agent connection alive and must be closed after use.
+func authMethods(conn *model.SSHConnection, opts Options) ([]ssh.AuthMethod, io.Closer, error) {
+	switch mode := conn.EffectiveAuthMode(); mode {
+	case model.AuthModePassword:
+		password := conn.Password
+		if opts.Password != nil {
+			resolved, err := opts.Password()
+			if err != nil {
+				return nil, nil, err
+			}
+			password = resolved
+		}
+		if password == "" {
+			return nil, nil, fmt.Errorf("password is required when auth mode is %q", model.AuthModePassword)
+		}
+		return []ssh.AuthMethod{
+			ssh.Password(password),
+			ssh.KeyboardInteractive(func(_, _ string, questions []string, _ []bool) ([]string, error) {
+				answers := make([]string, len(questions))
+				for i := range answers {
+					answers[i] = password
+				}
+				return answers, nil
+			}),
+		}, nil, nil
+	case model.AuthModeKey:
+		signer, err := loadIdentitySigner(strings.TrimSpace(conn.IdentityFile), opts.PassphrasePrompt)
+		if err != nil {
+			return nil, nil, err
+		}
+		return []ssh.AuthMethod{ssh.PublicKeys(signer)}, nil, nil
+	case model.AuthModeAgent:
+		agentConn, err := dialAgent()
+		if err != nil {
+			return nil, nil, err
+		}
+		return []ssh.AuthMethod{ssh.PublicKeysCallback(agent.NewClient(agentConn).Signers)}, agentConn, nil
+	default:
+		return nil, nil, fmt.Errorf("unsupported auth mode: %s", mode)
+	}
+}
+
+// jumpHopAuthMethods returns the key-based auth offered to jump hops of a
+// password connection: the ssh-agent, when reachable, and the default
+// unencrypted identities in ~/.ssh.
+func jumpHopAuthMethods() ([]ssh.AuthMethod, io.Closer) {
+	var methods []ssh.AuthMethod
+	var closer io.Closer
+	if agentConn, err := dialAgent(); err == nil {
+		methods = append(methods, ssh.PublicKeysCallback(agent.NewClient(agentConn).Signers))
+		closer = agentConn
+	}
+
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return methods, closer
+	}
+	var signers []ssh.Signer
+	for _, name := range []string{"id_ed25519", "id_ecdsa", "id_rsa"} {
+		pemBytes, err := os.ReadFile(filepath.Join(homeDir, ".ssh", name))
+		if err != nil {
+			continue
+		}
+		if signer, err := ssh.ParsePrivateKey(pemBytes); err == nil {
+			signers = append(signers, signer)
+		}
+	}
+	if len(signers) > 0 {
+		methods = append(methods, ssh.PublicKeys(signers...))
+	}
+	return methods, closer
+}
+
+// localUsername returns the name of the local user, which ssh uses for jump
+// hops without a user, or fallback when it cannot be determined.
+func localUsername(fallback string) string {
+	current, err := user.Current()
+	if err != nil {
+		return fallback
+	}
+	name := current.Username
+	if idx := strings.LastIndex(name, `\`); idx >= 0 {
+		name = name[idx+1:]
+	}
+	if name == "" {
+		return fallback
+	}
+	return name
+}
+
+func loadIdentitySigner(identityFile string, prompt func(string) ([]byte, error)) (ssh.Signer, error) {
+	if identityFile == "" {
+		return nil, fmt.Errorf("identityFile is required when auth mode is %q", model.AuthModeKey)
+	}
+	pemBytes, err := os.ReadFile(identityFile)
+	if err != nil {
+		return nil, fmt.Errorf("identityFile %q is not accessible: %w", identityFile, err)
+	}
+
+	signer, err := ssh.ParsePrivateKey(pemBytes)
+	var missing *ssh.PassphraseMissingError
+	if errors.As(err, &missing) {
+		if prompt == nil {
+			return nil, fmt.Errorf("identityFile %q is encrypted and no passphrase prompt is available", identityFile)
+		}
+		passphrase, promptErr := prompt(identityFile)
+		if promptErr != nil {
+			return nil, promptErr
+		}
+		signer, err = ssh.ParsePrivateKeyWithPassphrase(pemBytes, passphrase)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse identityFile %q: %w", identityFile, err)
+	}
+	return signer, nil
+}
+
+func dialAgent() (net.Conn, error) {
+	socket := strings.TrimSpace(os.Getenv("SSH_AUTH_SOCK"))
+	if socket == "" {
+		return nil, errors.New("agent auth requires SSH_AUTH_SOCK to point at a running ssh-agent")
+	}
+	agentConn, err := net.Dial("unix", socket)
+	if err != nil {
+		return nil, fmt.Errorf("failed to reach ssh-agent at %s: %w", socket, err)
+	}
+	return agentConn, nil
+}
+
+func defaultHostKeyCallback() (ssh.HostKeyCallback, error) {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return nil, fmt.Errorf("could not determine home directory for known_hosts: %w", err)
+	}
+	knownHostsPath := filepath.Join(homeDir, ".ssh", "known_hosts")
+	callback, err := knownhosts.New(knownHostsPath)
+	if err != nil {
+		return nil, fmt.Errorf("native backend requires a readable %s for host key verification: %w", knownHostsPath, err)
+	}
+	return callback, nil
+}
+
+// knownHostsFileCallback verifies host keys against path. A file that does
+// not exist yet knows no hosts, so every key is reported as unknown.
+func knownHostsFileCallback(path string) (ssh.HostKeyCallback, error) {
+	if _, err := os.Stat(path); errors.Is(err, os.ErrNotExist) {
+		return func(hostname string, _ net.Addr, key ssh.PublicKey) error {
+			return fmt.Errorf("host key %s %s for %s is not in %s", key.Type(), ssh.FingerprintSHA256(key), hostname, path)
+		}, nil
+	}
+	callback, err := knownhosts.New(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s for host key verification: %w", path, err)
+	}
+	return callback, nil
+}
+
+// parseJumpHop splits a validated "[user@]host[:port]" hop into the login
+// user and dial address. Hops without a user log in as defaultUser.
+func parseJumpHop(hop, defaultUser string) (string, string) {
+	user := defaultUser
+	if at := strings.LastIndex(hop, "@"); at >= 0 {
+		user = hop[:at]
+		hop = hop[at+1:]
+	}
+
+	host := hop
+	port := strconv.Itoa(model.DefaultSSHPort)
+	if strings.HasPrefix(hop, "[") {
+		if end := strings.Index(hop, "]"); end >= 0 {
+			host = hop[1:end]
+			if rest := hop[end+1:]; strings.HasPrefix(rest, ":") {
+				port = rest[1:]
+			}
+		}
+	} else if h, p, ok := strings.Cut(hop, ":"); ok {
+		host, port = h, p
+	}
+	return user, net.JoinHostPort(host, port)
+}
+
+func trimIPv6Brackets(host string) string {
+	if strings.HasPrefix(host, "[") && strings.HasSuffix(host, "]") {
+		return host[1 : len(host)-1]
+	}
+	return host
+}
@@ -0,0 +1,470 @@
+package nativessh
+
+import (
+	"bufio"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/pem"
+	"net"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/emirhangumus/sshmanager/internal/model"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+func TestRunPasswordAuth(t *testing.T) {
+	srv := startTestServer(t, "secret", nil)
+	client := dialTestClient(t, srv, &model.SSHConnection{
+		Username: "ubuntu",
+		Host:     srv.host(),
+		Port:     srv.port(),
+		AuthMode: model.AuthModePassword,
+		Password: "secret",
+	})
+
+	var stdout strings.Builder
+	code, err := client.Run("uptime", nil, &stdout, nil)
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if code != 0 {
+		t.Fatalf("expected exit code 0, got %d", code)
+	}
+	if stdout.String() != "exec: uptime\n" {
+		t.Fatalf("unexpected output: %q", stdout.String())
+	}
+
+	code, err = client.Run("exit 3", nil, &strings.Builder{}, nil)
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if code != 3 {
+		t.Fatalf("expected exit code 3, got %d", code)
+	}
+}
+
+func TestDialRejectsWrongPassword(t *testing.T) {
+	srv := startTestServer(t, "secret", nil)
+	_, err := Dial(&model.SSHConnection{
+		Username: "ubuntu",
+		Host:     srv.host(),
+		Port:     srv.port(),
+		AuthMode: model.AuthModePassword,
+		Password: "wrong",
+	}, Options{HostKeyCallback: srv.hostKeyCallback()})
+	if err == nil {
+		t.Fatal("expected authentication error, got nil")
+	}
+}
+
+func TestDialRejectsUnexpectedHostKey(t *testing.T) {
+	srv := startTestServer(t, "secret", nil)
+	other := startTestServer(t, "secret", nil)
+
+	_, err := Dial(&model.SSHConnection{
+		Username: "ubuntu",
+		Host:     srv.host(),
+		Port:     srv.port(),
+		AuthMode: model.AuthModePassword,
+		Password: "secret",
+	}, Options{HostKeyCallback: other.hostKeyCallback()})
+	if err == nil {
+		t.Fatal("expected host key mismatch error, got nil")
+	}
+}
+
+func TestDialVerifiesKnownHostsFile(t *testing.T) {
+	srv := startTestServer(t, "secret", nil)
+	other := startTestServer(t, "secret", nil)
+	conn := &model.SSHConnection{
+		Username: "ubuntu",
+		Host:     srv.host(),
+		Port:     srv.port(),
+		AuthMode: model.AuthModePassword,
+		Password: "secret",
+	}
+
+	// The user's own known_hosts trusts the server but is never consulted
+	// for the target once a known_hosts file is given.
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	writeTestKnownHosts(t, filepath.Join(home, ".ssh", "known_hosts"), srv, srv.hostSigner.PublicKey())
+
+	managed := filepath.Join(t.TempDir(), "known_hosts")
+	if _, err := Dial(conn, Options{KnownHostsFile: managed}); err == nil || !strings.Contains(err.Error(), "is not in "+managed) {
+		t.Fatalf("expected unknown host key error for a missing file, got %v", err)
+	}
+
+	writeTestKnownHosts(t, managed, srv, other.hostSigner.PublicKey())
+	if _, err := Dial(conn, Options{KnownHostsFile: managed}); err == nil {
+		t.Fatal("expected host key mismatch error, got nil")
+	}
+
+	writeTestKnownHosts(t, managed, srv, srv.hostSigner.PublicKey())
+	client, err := Dial(conn, Options{KnownHostsFile: managed})
+	if err != nil {
+		t.Fatalf("Dial failed: %v", err)
+	}
+	_ = client.Close()
+}
+
+func TestRunKeyAuth(t *testing.T) {
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate client key: %v", err)
+	}
+	sshPub, err := ssh.NewPublicKey(pub)
+	if err != nil {
+		t.Fatalf("failed to convert public key: %v", err)
+	}
+	block, err := ssh.MarshalPrivateKey(priv, "")
+	if err != nil {
+		t.Fatalf("failed to marshal private key: %v", err)
+	}
+	identityFile := filepath.Join(t.TempDir(), "id_ed25519")
+	if err := os.WriteFile(identityFile, pem.EncodeToMemory(block), 0o600); err != nil {
+		t.Fatalf("failed to write identity file: %v", err)
+	}
+
+	srv := startTestServer(t, "", sshPub)
+	client := dialTestClient(t, srv, &model.SSHConnection{
+		Username:     "deploy",
+		Host:         srv.host(),
+		Port:         srv.port(),
+		AuthMode:     model.AuthModeKey,
+		IdentityFile: identityFile,
+	})
+
+	var stdout strings.Builder
+	if _, err := client.Run("whoami", nil, &stdout, nil); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if stdout.String() != "exec: whoami\n" {
+		t.Fatalf("unexpected output: %q", stdout.String())
+	}
+}
+
+func TestRunAgentAuth(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("ssh-agent unix sockets are not available on windows")
+	}
+
+	srv := startTestServer(t, "", startTestAgent(t))
+	client := dialTestClient(t, srv, &model.SSHConnection{
+		Username: "ops",
+		Host:     srv.host(),
+		Port:     srv.port(),
+		AuthMode: model.AuthModeAgent,
+	})
+
+	if code, err := client.Run("true", nil, &strings.Builder{}, nil); err != nil || code != 0 {
+		t.Fatalf("Run failed: code=%d err=%v", code, err)
+	}
+}
+
+func TestDialThroughProxyJump(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("ssh-agent unix sockets are not available on windows")
+	}
+
+	jump := startTestServer(t, "", startTestAgent(t))
+	target := startTestServer(t, "secret", nil)
+
+	hostKeys := map[string]ssh.PublicKey{
+		net.JoinHostPort(jump.host(), strconv.Itoa(jump.port())):     jump.hostSigner.PublicKey(),
+		net.JoinHostPort(target.host(), strconv.Itoa(target.port())): target.hostSigner.PublicKey(),
+	}
+	client, err := Dial(&model.SSHConnection{
+		Username:  "ubuntu",
+		Host:      target.host(),
+		Port:      target.port(),
+		AuthMode:  model.AuthModePassword,
+		Password:  "secret",
+		ProxyJump: "jumper@" + jump.host() + ":" + strconv.Itoa(jump.port()),
+	}, Options{
+		HostKeyCallback: func(hostname string, remote net.Addr, key ssh.PublicKey) error {
+			want, ok := hostKeys[hostname]
+			if !ok {
+				t.Fatalf("unexpected host key check for %s", hostname)
+			}
+			return ssh.FixedHostKey(want)(hostname, remote, key)
+		},
+	})
+	if err != nil {
+		t.Fatalf("Dial failed: %v", err)
+	}
+	t.Cleanup(func() { _ = client.Close() })
+
+	if code, err := client.Run("hostname", nil, &strings.Builder{}, nil); err != nil || code != 0 {
+		t.Fatalf("Run failed: code=%d err=%v", code, err)
+	}
+	if logins := jump.loginsSnapshot(); len(logins) != 1 || logins[0] != "jumper" {
+		t.Fatalf("expected jump host login as jumper, got %v", logins)
+	}
+	if logins := target.loginsSnapshot(); len(logins) != 1 || logins[0] != "ubuntu" {
+		t.Fatalf("expected target login as ubuntu, got %v", logins)
+	}
+}
+
+func TestProxyJumpNeverSeesTargetPassword(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("ssh-agent unix sockets are not available on windows")
+	}
+	t.Setenv("HOME", t.TempDir())
+
+	jump := startTestServer(t, "secret", startTestAgent(t))
+	target := startTestServer(t, "secret", nil)
+	hostKeys := map[string]ssh.PublicKey{
+		net.JoinHostPort(jump.host(), strconv.Itoa(jump.port())):     jump.hostSigner.PublicKey(),
+		net.JoinHostPort(target.host(), strconv.Itoa(target.port())): target.hostSigner.PublicKey(),
+	}
+	client, err := Dial(&model.SSHConnection{
+		Username:  "ubuntu",
+		Host:      target.host(),
+		Port:      target.port(),
+		AuthMode:  model.AuthModePassword,
+		Password:  "secret",
+		ProxyJump: jump.host() + ":" + strconv.Itoa(jump.port()),
+	}, Options{
+		HostKeyCallback: func(hostname string, remote net.Addr, key ssh.PublicKey) error {
+			return ssh.FixedHostKey(hostKeys[hostname])(hostname, remote, key)
+		},
+	})
+	if err != nil {
+		t.Fatalf("Dial failed: %v", err)
+	}
+	t.Cleanup(func() { _ = client.Close() })
+
+	if passwords := jump.passwordsSnapshot(); len(passwords) != 0 {
+		t.Fatalf("expected the jump host to see no password, got %q", passwords)
+	}
+	if logins := jump.loginsSnapshot(); len(logins) != 1 || logins[0] != localUsername("ubuntu") {
+		t.Fatalf("expected jump host login as the local user, got %v", logins)
+	}
+	if logins := target.loginsSnapshot(); len(logins) != 1 || logins[0] != "ubuntu" {
+		t.Fatalf("expected target login as ubuntu, got %v", logins)
+	}
+}
+
+func TestLocalAndRemoteForwards(t *testing.T) {
+	srv := startTestServer(t, "secret", nil)
+	echoAddr := startEchoServer(t)
+	client := dialTestClient(t, srv, &model.SSHConnection{
+		Username: "ubuntu",
+		Host:     srv.host(),
+		Port:     srv.port(),
+		AuthMode: model.AuthModePassword,
+		Password: "secret",
+	})
+
+	localAddr, err := client.StartLocalForward("127.0.0.1:" + strconv.Itoa(freePort(t)) + ":" + echoAddr)
+	if err != nil {
+		t.Fatalf("StartLocalForward failed: %v", err)
+	}
+	assertEcho(t, localAddr.String(), "through local forward")
+
+	remotePort := freePort(t)
+	if _, err := client.StartRemoteForward("127.0.0.1:" + strconv.Itoa(remotePort) + ":" + echoAddr); err != nil {
+		t.Fatalf("StartRemoteForward failed: %v", err)
+	}
+	assertEcho(t, net.JoinHostPort("127.0.0.1", strconv.Itoa(remotePort)), "through remote forward")
+}
+
+func TestLocalForwardFromSocket(t *testing.T) {
+	srv := startTestServer(t, "secret", nil)
+	echoAddr := startEchoServer(t)
+	client := dialTestClient(t, srv, &model.SSHConnection{
+		Username: "ubuntu",
+		Host:     srv.host(),
+		Port:     srv.port(),
+		AuthMode: model.AuthModePassword,
+		Password: "secret",
+	})
+
+	socketPath := filepath.Join(t.TempDir(), "fwd.sock")
+	localAddr, err := client.StartLocalForward(socketPath + ":" + echoAddr)
+	if err != nil {
+		t.Fatalf("StartLocalForward failed: %v", err)
+	}
+	if localAddr.Network() != "unix" || localAddr.String() != socketPath {
+		t.Fatalf("expected a listener on %s, got %s %s", socketPath, localAddr.Network(), localAddr)
+	}
+	assertEcho(t, socketPath, "through socket forward")
+}
+
+func TestForwardAddrs(t *testing.T) {
+	tests := []struct {
+		spec       string
+		wantListen forwardAddr
+		wantTarget forwardAddr
+	}{
+		{spec: "8080:db:5432", wantListen: forwardAddr{"tcp", "localhost:8080"}, wantTarget: forwardAddr{"tcp", "db:5432"}},
+		{spec: "*:8080:[::1]:80", wantListen: forwardAddr{"tcp", ":8080"}, wantTarget: forwardAddr{"tcp", "[::1]:80"}},
+		{spec: "[::1]:2375:/var/run/docker.sock", wantListen: forwardAddr{"tcp", "[::1]:2375"}, wantTarget: forwardAddr{"unix", "/var/run/docker.sock"}},
+		{spec: "/tmp/a.sock:/tmp/b.sock", wantListen: forwardAddr{"unix", "/tmp/a.sock"}, wantTarget: forwardAddr{"unix", "/tmp/b.sock"}},
+	}
+	for _, tc := range tests {
+		listen, target, err := forwardAddrs(tc.spec)
+		if err != nil {
+			t.Fatalf("forwardAddrs(%q) unexpected error: %v", tc.spec, err)
+		}
+		if listen != tc.wantListen || target != tc.wantTarget {
+			t.Fatalf("forwardAddrs(%q) = (%+v, %+v), want (%+v, %+v)", tc.spec, listen, target, tc.wantListen, tc.wantTarget)
+		}
+	}
+}
+
+func TestShellWithoutTerminal(t *testing.T) {
+	srv := startTestServer(t, "secret", nil)
+	client := dialTestClient(t, srv, &model.SSHConnection{
+		Username: "ubuntu",
+		Host:     srv.host(),
+		Port:     srv.port(),
+		AuthMode: model.AuthModePassword,
+		Password: "secret",
+	})
+
+	var stdout strings.Builder
+	err := client.Shell(Options{
+		Stdin:  strings.NewReader("echo hello\n"),
+		Stdout: &stdout,
+		Stderr: &strings.Builder{},
+	})
+	if err != nil {
+		t.Fatalf("Shell failed: %v", err)
+	}
+	if stdout.String() != "echo hello\n" {
+		t.Fatalf("unexpected shell output: %q", stdout.String())
+	}
+}
+
+func TestParseJumpHop(t *testing.T) {
+	tests := []struct {
+		hop      string
+		wantUser string
+		wantAddr string
+	}{
+		{hop: "bastion", wantUser: "fallback", wantAddr: "bastion:22"},
+		{hop: "ops@bastion:2222", wantUser: "ops", wantAddr: "bastion:2222"},
+		{hop: "[::1]:2200", wantUser: "fallback", wantAddr: "[::1]:2200"},
+	}
+	for _, tc := range tests {
+		user, addr := parseJumpHop(tc.hop, "fallback")
+		if user != tc.wantUser || addr != tc.wantAddr {
+			t.Fatalf("parseJumpHop(%q) = (%q, %q), want (%q, %q)", tc.hop, user, addr, tc.wantUser, tc.wantAddr)
+		}
+	}
+}
+
+// startTestAgent serves an ssh-agent holding one fresh key on a temporary
+// socket, points SSH_AUTH_SOCK at it and returns the key's public half.
+func startTestAgent(t *testing.T) ssh.PublicKey {
+	t.Helper()
+
+	_, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate client key: %v", err)
+	}
+	keyring := agent.NewKeyring()
+	if err := keyring.Add(agent.AddedKey{PrivateKey: priv}); err != nil {
+		t.Fatalf("failed to add key to agent: %v", err)
+	}
+	signers, err := keyring.Signers()
+	if err != nil || len(signers) != 1 {
+		t.Fatalf("failed to read agent signers: %v", err)
+	}
+
+	socketDir, err := os.MkdirTemp("", "agent")
+	if err != nil {
+		t.Fatalf("failed to create agent dir: %v", err)
+	}
+	t.Cleanup(func() { _ = os.RemoveAll(socketDir) })
+	socket := filepath.Join(socketDir, "sock")
+	listener, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Fatalf("failed to listen on agent socket: %v", err)
+	}
+	t.Cleanup(func() { _ = listener.Close() })
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() { _ = agent.ServeAgent(keyring, conn) }()
+		}
+	}()
+	t.Setenv("SSH_AUTH_SOCK", socket)
+
+	return signers[0].PublicKey()
+}
+
+func dialTestClient(t *testing.T, srv *testServer, conn *model.SSHConnection) *Client {
+	t.Helper()
+	client, err := Dial(conn, Options{HostKeyCallback: srv.hostKeyCallback()})
+	if err != nil {
+		t.Fatalf("Dial failed: %v", err)
+	}
+	t.Cleanup(func() { _ = client.Close() })
+	return client
+}
+
+// freePort returns a loopback port that was free at the time of the call;
+// forward specs reject port 0 so tests cannot let the kernel pick one.
+// writeTestKnownHosts writes a known_hosts file trusting key at srv's
+// address.
+func writeTestKnownHosts(t *testing.T, path string, srv *testServer, key ssh.PublicKey) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		t.Fatalf("failed to create known_hosts dir: %v", err)
+	}
+	addr := knownhosts.Normalize(srv.listener.Addr().String())
+	line := knownhosts.Line([]string{addr}, key) + "\n"
+	if err := os.WriteFile(path, []byte(line), 0o600); err != nil {
+		t.Fatalf("failed to write known_hosts: %v", err)
+	}
+}
+
+func freePort(t *testing.T) int {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to reserve port: %v", err)
+	}
+	defer listener.Close()
+	return listener.Addr().(*net.TCPAddr).Port
+}
+
+func assertEcho(t *testing.T, addr, message string) {
+	t.Helper()
+
+	network := "tcp"
+	if model.IsForwardSocketPath(addr) {
+		network = "unix"
+	}
+	conn, err := net.DialTimeout(network, addr, 5*time.Second)
+	if err != nil {
+		t.Fatalf("failed to dial %s: %v", addr, err)
+	}
+	defer conn.Close()
+	_ = conn.SetDeadline(time.Now().Add(5 * time.Second))
+
+	if _, err := conn.Write([]byte(message + "\n")); err != nil {
+		t.Fatalf("failed to write to %s: %v", addr, err)
+	}
+	line, err := bufio.NewReader(conn).ReadString('\n')
+	if err != nil {
+		t.Fatalf("failed to read echo from %s: %v", addr, err)
+	}
+	if strings.TrimSpace(line) != message {
+		t.Fatalf("unexpected echo: got %q want %q", line, message)
+	}
+}
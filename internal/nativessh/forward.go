@@ -0,0 +1,169 @@
+package nativessh
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/emirhangumus/sshmanager/internal/model"
+)
+
+// StartLocalForward listens on the local side of spec and tunnels each
+// accepted connection to its destination through the SSH connection.
+func (c *Client) StartLocalForward(spec string) (net.Addr, error) {
+	listen, target, err := forwardAddrs(spec)
+	if err != nil {
+		return nil, err
+	}
+
+	listener, err := net.Listen(listen.network, listen.address)
+	if err != nil {
+		return nil, fmt.Errorf("local forward %s: %w", spec, err)
+	}
+	c.closers = append(c.closers, listener)
+
+	go acceptAndPipe(listener, func() (net.Conn, error) {
+		return c.Dial(target.network, target.address)
+	})
+	return listener.Addr(), nil
+}
+
+// StartRemoteForward asks the server to listen on the remote side of spec and
+// tunnels each accepted connection to its destination on this machine.
+func (c *Client) StartRemoteForward(spec string) (net.Addr, error) {
+	listen, target, err := forwardAddrs(spec)
+	if err != nil {
+		return nil, err
+	}
+
+	listener, err := c.Listen(listen.network, listen.address)
+	if err != nil {
+		return nil, fmt.Errorf("remote forward %s: %w", spec, err)
+	}
+	c.closers = append(c.closers, listener)
+
+	go acceptAndPipe(listener, func() (net.Conn, error) {
+		return net.Dial(target.network, target.address)
+	})
+	return listener.Addr(), nil
+}
+
+// StartForwards starts every local and remote forward configured on conn.
+func (c *Client) StartForwards(conn *model.SSHConnection) error {
+	for _, spec := range model.NormalizeStringList(conn.LocalForwards) {
+		if _, err := c.StartLocalForward(spec); err != nil {
+			return err
+		}
+	}
+	for _, spec := range model.NormalizeStringList(conn.RemoteForwards) {
+		if _, err := c.StartRemoteForward(spec); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// LocalListenAddress returns the local address a -L forward spec listens
+// on: host:port, or the socket path.
+func LocalListenAddress(spec string) (string, error) {
+	listen, _, err := forwardAddrs(spec)
+	return listen.address, err
+}
+
+// DynamicListenAddress returns the local address a -D forward spec listens
+// on.
+func DynamicListenAddress(spec string) (string, error) {
+	trimmed := strings.TrimSpace(spec)
+	if err := model.ValidateDynamicForwardSpec(trimmed); err != nil {
+		return "", err
+	}
+	return listenAddr(trimmed), nil
+}
+
+// ListenNetwork returns the network of an address returned by
+// LocalListenAddress or DynamicListenAddress.
+func ListenNetwork(addr string) string {
+	if model.IsForwardSocketPath(addr) {
+		return "unix"
+	}
+	return "tcp"
+}
+
+// forwardAddr is a dialable side of a forward.
+type forwardAddr struct {
+	network string
+	address string
+}
+
+// forwardAddrs converts a forward spec into dialable listen/target addresses.
+// A missing bind address means loopback, and "*" means all interfaces, as
+// with ssh -L/-R. Socket paths become unix addresses.
+func forwardAddrs(spec string) (forwardAddr, forwardAddr, error) {
+	listen, target, err := model.ParseForwardSpec(spec)
+	if err != nil {
+		return forwardAddr{}, forwardAddr{}, err
+	}
+
+	listenAddress := forwardAddr{network: "unix", address: listen.Socket}
+	if !listen.IsSocket() {
+		listenAddress = forwardAddr{network: "tcp", address: bindAddr(listen.Host, strconv.Itoa(listen.Port))}
+	}
+	targetAddress := forwardAddr{network: "unix", address: target.Socket}
+	if !target.IsSocket() {
+		targetAddress = forwardAddr{network: "tcp", address: net.JoinHostPort(trimIPv6Brackets(target.Host), strconv.Itoa(target.Port))}
+	}
+	return listenAddress, targetAddress, nil
+}
+
+// listenAddr converts the [bind_address:]port side of a forward spec into
+// a dialable address.
+func listenAddr(listen string) string {
+	bind, port := "", listen
+	if idx := strings.LastIndex(listen, ":"); idx >= 0 {
+		bind, port = listen[:idx], listen[idx+1:]
+	}
+	return bindAddr(bind, port)
+}
+
+func bindAddr(bind, port string) string {
+	switch bind {
+	case "*":
+		bind = ""
+	case "":
+		bind = "localhost"
+	}
+	return net.JoinHostPort(trimIPv6Brackets(bind), port)
+}
+
+func acceptAndPipe(listener net.Listener, dial func() (net.Conn, error)) {
+	for {
+		local, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		go func() {
+			remote, err := dial()
+			if err != nil {
+				_ = local.Close()
+				return
+			}
+			pipe(local, remote)
+		}()
+	}
+}
+
+func pipe(a, b net.Conn) {
+	var wg sync.WaitGroup
+	wg.Add(2)
+	copyAndClose := func(dst, src net.Conn) {
+		defer wg.Done()
+		_, _ = io.Copy(dst, src)
+		_ = dst.Close()
+	}
+	go copyAndClose(a, b)
+	go copyAndClose(b, a)
+	wg.Wait()
+}
@@ -0,0 +1,109 @@
+package nativessh
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/emirhangumus/sshmanager/internal/model"
+	"golang.org/x/crypto/ssh"
+)
+
+// ErrHostKeyMismatch is returned when a server presents a host key that is
+// not among the pinned keys of the connection.
+var ErrHostKeyMismatch = errors.New("host key does not match the pinned keys")
+
+// scanKeyAlgorithms are offered one at a time by ScanHostKeys so that the
+// server reveals each of its host keys.
+var scanKeyAlgorithms = []string{
+	ssh.KeyAlgoED25519,
+	ssh.KeyAlgoECDSA256,
+	ssh.KeyAlgoECDSA384,
+	ssh.KeyAlgoECDSA521,
+	ssh.KeyAlgoRSASHA512,
+}
+
+// errHostKeyCaptured aborts a scan handshake once the key has been seen.
+var errHostKeyCaptured = errors.New("host key captured")
+
+// ScanHostKeys connects to addr once per host key algorithm and returns the
+// distinct host keys the server presents, in "<type> <base64>" form. No
+// authentication is attempted.
+func ScanHostKeys(addr string, timeout time.Duration) ([]string, error) {
+	if timeout <= 0 {
+		timeout = defaultDialTimeout
+	}
+
+	var (
+		keys    []string
+		lastErr error
+	)
+	for _, algorithm := range scanKeyAlgorithms {
+		var captured ssh.PublicKey
+		netConn, err := net.DialTimeout("tcp", addr, timeout)
+		if err != nil {
+			return nil, err
+		}
+		_ = netConn.SetDeadline(time.Now().Add(timeout))
+		_, _, _, err = ssh.NewClientConn(netConn, addr, &ssh.ClientConfig{
+			User:              "sshmanager-scan",
+			HostKeyAlgorithms: []string{algorithm},
+			HostKeyCallback: func(_ string, _ net.Addr, key ssh.PublicKey) error {
+				captured = key
+				return errHostKeyCaptured
+			},
+		})
+		_ = netConn.Close()
+
+		if captured == nil {
+			lastErr = err
+			continue
+		}
+		key := marshalHostKey(captured)
+		if !containsString(keys, key) {
+			keys = append(keys, key)
+		}
+	}
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("no host key received from %s: %w", addr, lastErr)
+	}
+	return keys, nil
+}
+
+// PinnedHostKeyCallback accepts only servers presenting one of the pinned
+// keys. A different key fails with ErrHostKeyMismatch naming both sides'
+// fingerprints.
+func PinnedHostKeyCallback(pinned []string) ssh.HostKeyCallback {
+	return func(hostname string, _ net.Addr, key ssh.PublicKey) error {
+		presented := marshalHostKey(key)
+		for _, pin := range pinned {
+			normalized, err := model.NormalizeHostKey(pin)
+			if err == nil && normalized == presented {
+				return nil
+			}
+		}
+
+		fingerprints := make([]string, 0, len(pinned))
+		for _, pin := range pinned {
+			fingerprints = append(fingerprints, model.HostKeyFingerprint(pin))
+		}
+		return fmt.Errorf("%w: %s presented %s %s, pinned %s",
+			ErrHostKeyMismatch, hostname, key.Type(), ssh.FingerprintSHA256(key), strings.Join(fingerprints, ", "))
+	}
+}
+
+func marshalHostKey(key ssh.PublicKey) string {
+	return string(bytes.TrimSpace(ssh.MarshalAuthorizedKey(key)))
+}
+
+func containsString(values []string, value string) bool {
+	for _, existing := range values {
+		if existing == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,56 @@
+package nativessh
+
+import (
+	"errors"
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/emirhangumus/sshmanager/internal/model"
+)
+
+func TestScanHostKeysAndPinnedDial(t *testing.T) {
+	srv := startTestServer(t, "secret", nil)
+	other := startTestServer(t, "secret", nil)
+
+	addr := net.JoinHostPort(srv.host(), strconv.Itoa(srv.port()))
+	keys, err := ScanHostKeys(addr, 5*time.Second)
+	if err != nil {
+		t.Fatalf("ScanHostKeys failed: %v", err)
+	}
+	if len(keys) != 1 || keys[0] != marshalHostKey(srv.hostSigner.PublicKey()) {
+		t.Fatalf("unexpected scanned keys %q", keys)
+	}
+
+	conn := &model.SSHConnection{
+		Username: "ubuntu",
+		Host:     srv.host(),
+		Port:     srv.port(),
+		AuthMode: model.AuthModePassword,
+		Password: "secret",
+		HostKeys: keys,
+	}
+	// The pinned keys win over the callback used for jump hops.
+	client, err := Dial(conn, Options{HostKeyCallback: other.hostKeyCallback()})
+	if err != nil {
+		t.Fatalf("Dial with pinned key failed: %v", err)
+	}
+	_ = client.Close()
+
+	conn.HostKeys = []string{marshalHostKey(other.hostSigner.PublicKey())}
+	_, err = Dial(conn, Options{HostKeyCallback: srv.hostKeyCallback()})
+	if !errors.Is(err, ErrHostKeyMismatch) {
+		t.Fatalf("expected ErrHostKeyMismatch, got %v", err)
+	}
+	if !strings.Contains(err.Error(), model.HostKeyFingerprint(conn.HostKeys[0])) {
+		t.Fatalf("expected the pinned fingerprint in %q", err)
+	}
+}
+
+func TestScanHostKeysUnreachable(t *testing.T) {
+	if _, err := ScanHostKeys(net.JoinHostPort("127.0.0.1", strconv.Itoa(freePort(t))), time.Second); err == nil {
+		t.Fatal("expected an error for a closed port")
+	}
+}
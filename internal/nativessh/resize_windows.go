@@ -0,0 +1,10 @@
+//go:build windows
+
+package nativessh
+
+import "golang.org/x/crypto/ssh"
+
+// watchWindowSize is a no-op on Windows, which has no SIGWINCH.
+func watchWindowSize(_ int, _ *ssh.Session) func() {
+	return func() {}
+}
@@ -0,0 +1,302 @@
+package nativessh
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// testServer is a minimal in-process SSH server supporting password and
+// public-key auth, exec/shell sessions, direct-tcpip (for ProxyJump and -L)
+// and tcpip-forward (for -R).
+type testServer struct {
+	t          *testing.T
+	listener   net.Listener
+	hostSigner ssh.Signer
+
+	mu        sync.Mutex
+	logins    []string
+	passwords []string
+}
+
+func startTestServer(t *testing.T, password string, authorizedKey ssh.PublicKey) *testServer {
+	t.Helper()
+
+	_, hostKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate host key: %v", err)
+	}
+	hostSigner, err := ssh.NewSignerFromKey(hostKey)
+	if err != nil {
+		t.Fatalf("failed to create host signer: %v", err)
+	}
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+
+	srv := &testServer{t: t, listener: listener, hostSigner: hostSigner}
+	cfg := &ssh.ServerConfig{
+		PasswordCallback: func(meta ssh.ConnMetadata, pass []byte) (*ssh.Permissions, error) {
+			srv.recordPassword(string(pass))
+			if password != "" && string(pass) == password {
+				srv.recordLogin(meta.User())
+				return nil, nil
+			}
+			return nil, fmt.Errorf("password rejected for %s", meta.User())
+		},
+		PublicKeyCallback: func(meta ssh.ConnMetadata, key ssh.PublicKey) (*ssh.Permissions, error) {
+			if authorizedKey != nil && bytes.Equal(key.Marshal(), authorizedKey.Marshal()) {
+				srv.recordLogin(meta.User())
+				return nil, nil
+			}
+			return nil, fmt.Errorf("public key rejected for %s", meta.User())
+		},
+	}
+	cfg.AddHostKey(hostSigner)
+
+	go func() {
+		for {
+			netConn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go srv.serveConn(netConn, cfg)
+		}
+	}()
+	t.Cleanup(func() { _ = listener.Close() })
+	return srv
+}
+
+func (s *testServer) host() string {
+	host, _, _ := net.SplitHostPort(s.listener.Addr().String())
+	return host
+}
+
+func (s *testServer) port() int {
+	_, port, _ := net.SplitHostPort(s.listener.Addr().String())
+	p, _ := strconv.Atoi(port)
+	return p
+}
+
+func (s *testServer) hostKeyCallback() ssh.HostKeyCallback {
+	return ssh.FixedHostKey(s.hostSigner.PublicKey())
+}
+
+func (s *testServer) recordLogin(user string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.logins = append(s.logins, user)
+}
+
+func (s *testServer) recordPassword(password string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.passwords = append(s.passwords, password)
+}
+
+func (s *testServer) passwordsSnapshot() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.passwords...)
+}
+
+func (s *testServer) loginsSnapshot() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.logins...)
+}
+
+func (s *testServer) serveConn(netConn net.Conn, cfg *ssh.ServerConfig) {
+	serverConn, chans, reqs, err := ssh.NewServerConn(netConn, cfg)
+	if err != nil {
+		_ = netConn.Close()
+		return
+	}
+	defer serverConn.Close()
+
+	go s.handleGlobalRequests(serverConn, reqs)
+	for newChannel := range chans {
+		switch newChannel.ChannelType() {
+		case "session":
+			go s.handleSession(newChannel)
+		case "direct-tcpip":
+			go s.handleDirectTCPIP(newChannel)
+		default:
+			_ = newChannel.Reject(ssh.UnknownChannelType, "unsupported channel type")
+		}
+	}
+}
+
+func (s *testServer) handleSession(newChannel ssh.NewChannel) {
+	channel, requests, err := newChannel.Accept()
+	if err != nil {
+		return
+	}
+	defer channel.Close()
+
+	for req := range requests {
+		switch req.Type {
+		case "pty-req", "env", "window-change":
+			_ = req.Reply(true, nil)
+		case "exec":
+			var payload struct{ Command string }
+			if err := ssh.Unmarshal(req.Payload, &payload); err != nil {
+				_ = req.Reply(false, nil)
+				continue
+			}
+			_ = req.Reply(true, nil)
+
+			status := 0
+			if code, ok := strings.CutPrefix(payload.Command, "exit "); ok {
+				status, _ = strconv.Atoi(code)
+			}
+			_, _ = fmt.Fprintf(channel, "exec: %s\n", payload.Command)
+			sendExitStatus(channel, status)
+			return
+		case "shell":
+			_ = req.Reply(true, nil)
+			_, _ = io.Copy(channel, channel)
+			sendExitStatus(channel, 0)
+			return
+		default:
+			_ = req.Reply(false, nil)
+		}
+	}
+}
+
+func (s *testServer) handleDirectTCPIP(newChannel ssh.NewChannel) {
+	var payload struct {
+		Host       string
+		Port       uint32
+		OriginHost string
+		OriginPort uint32
+	}
+	if err := ssh.Unmarshal(newChannel.ExtraData(), &payload); err != nil {
+		_ = newChannel.Reject(ssh.ConnectionFailed, "bad payload")
+		return
+	}
+
+	target, err := net.Dial("tcp", net.JoinHostPort(payload.Host, strconv.Itoa(int(payload.Port))))
+	if err != nil {
+		_ = newChannel.Reject(ssh.ConnectionFailed, err.Error())
+		return
+	}
+	channel, requests, err := newChannel.Accept()
+	if err != nil {
+		_ = target.Close()
+		return
+	}
+	go ssh.DiscardRequests(requests)
+	pipeChannel(channel, target)
+}
+
+func (s *testServer) handleGlobalRequests(serverConn *ssh.ServerConn, reqs <-chan *ssh.Request) {
+	for req := range reqs {
+		if req.Type != "tcpip-forward" {
+			if req.WantReply {
+				_ = req.Reply(false, nil)
+			}
+			continue
+		}
+
+		var payload struct {
+			Addr string
+			Port uint32
+		}
+		if err := ssh.Unmarshal(req.Payload, &payload); err != nil {
+			_ = req.Reply(false, nil)
+			continue
+		}
+		listener, err := net.Listen("tcp", net.JoinHostPort(payload.Addr, strconv.Itoa(int(payload.Port))))
+		if err != nil {
+			_ = req.Reply(false, nil)
+			continue
+		}
+		boundPort := uint32(listener.Addr().(*net.TCPAddr).Port)
+		_ = req.Reply(true, ssh.Marshal(struct{ Port uint32 }{boundPort}))
+
+		go func() {
+			defer listener.Close()
+			go func() {
+				_ = serverConn.Wait()
+				_ = listener.Close()
+			}()
+			for {
+				accepted, err := listener.Accept()
+				if err != nil {
+					return
+				}
+				origin := accepted.RemoteAddr().(*net.TCPAddr)
+				channel, requests, err := serverConn.OpenChannel("forwarded-tcpip", ssh.Marshal(struct {
+					Addr       string
+					Port       uint32
+					OriginAddr string
+					OriginPort uint32
+				}{payload.Addr, boundPort, origin.IP.String(), uint32(origin.Port)}))
+				if err != nil {
+					_ = accepted.Close()
+					continue
+				}
+				go ssh.DiscardRequests(requests)
+				go pipeChannel(channel, accepted)
+			}
+		}()
+	}
+}
+
+func pipeChannel(channel ssh.Channel, conn net.Conn) {
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		_, _ = io.Copy(channel, conn)
+		_ = channel.CloseWrite()
+	}()
+	go func() {
+		defer wg.Done()
+		_, _ = io.Copy(conn, channel)
+		_ = conn.Close()
+	}()
+	wg.Wait()
+	_ = channel.Close()
+}
+
+func sendExitStatus(channel ssh.Channel, status int) {
+	_, _ = channel.SendRequest("exit-status", false, ssh.Marshal(struct{ Status uint32 }{uint32(status)}))
+}
+
+// startEchoServer returns the address of a TCP server echoing every line.
+func startEchoServer(t *testing.T) string {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to start echo server: %v", err)
+	}
+	t.Cleanup(func() { _ = listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				_, _ = io.Copy(conn, conn)
+			}()
+		}
+	}()
+	return listener.Addr().String()
+}
@@ -0,0 +1,107 @@
+package nativessh
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/emirhangumus/sshmanager/internal/model"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/term"
+)
+
+const (
+	defaultTermWidth  = 80
+	defaultTermHeight = 24
+)
+
+// Connect opens an interactive session to conn, with its forwards active for
+// the lifetime of the session.
+func Connect(conn *model.SSHConnection, opts Options) error {
+	client, err := Dial(conn, opts)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	if err := client.StartForwards(conn); err != nil {
+		return err
+	}
+	return client.Shell(opts)
+}
+
+// Shell starts a login shell. When stdin is a terminal a PTY is requested,
+// the local terminal is put in raw mode and window resizes are propagated.
+func (c *Client) Shell(opts Options) error {
+	opts = opts.withDefaults()
+
+	session, err := c.NewSession()
+	if err != nil {
+		return fmt.Errorf("failed to open session: %w", err)
+	}
+	defer session.Close()
+
+	session.Stdin = opts.Stdin
+	session.Stdout = opts.Stdout
+	session.Stderr = opts.Stderr
+
+	if f, ok := opts.Stdin.(*os.File); ok && term.IsTerminal(int(f.Fd())) {
+		fd := int(f.Fd())
+		width, height, err := term.GetSize(fd)
+		if err != nil {
+			width, height = defaultTermWidth, defaultTermHeight
+		}
+		termType := os.Getenv("TERM")
+		if termType == "" {
+			termType = "xterm-256color"
+		}
+		if err := session.RequestPty(termType, height, width, ssh.TerminalModes{
+			ssh.ECHO:          1,
+			ssh.TTY_OP_ISPEED: 14400,
+			ssh.TTY_OP_OSPEED: 14400,
+		}); err != nil {
+			return fmt.Errorf("failed to request pty: %w", err)
+		}
+
+		state, err := term.MakeRaw(fd)
+		if err != nil {
+			return fmt.Errorf("failed to set terminal raw mode: %w", err)
+		}
+		defer func() { _ = term.Restore(fd, state) }()
+
+		stop := watchWindowSize(fd, session)
+		defer stop()
+	}
+
+	if err := session.Shell(); err != nil {
+		return fmt.Errorf("failed to start shell: %w", err)
+	}
+	return session.Wait()
+}
+
+// Run executes command without a PTY and returns the remote exit status.
+// The error is only set when the command could not be run or its exit
+// status is unknown.
+func (c *Client) Run(command string, stdin io.Reader, stdout, stderr io.Writer) (int, error) {
+	session, err := c.NewSession()
+	if err != nil {
+		return -1, fmt.Errorf("failed to open session: %w", err)
+	}
+	defer session.Close()
+
+	session.Stdin = stdin
+	session.Stdout = stdout
+	session.Stderr = stderr
+
+	err = session.Run(command)
+	var exitErr *ssh.ExitError
+	switch {
+	case err == nil:
+		return 0, nil
+	case errors.As(err, &exitErr):
+		return exitErr.ExitStatus(), nil
+	default:
+		return -1, err
+	}
+}
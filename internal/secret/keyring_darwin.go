@@ -0,0 +1,31 @@
+//go:build darwin
+
+package secret
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// SystemKeyring reads generic passwords from the macOS login keychain:
+//
+//	security add-generic-password -s sshmanager -a prod -w
+type SystemKeyring struct{}
+
+func (SystemKeyring) Lookup(src Source) (string, error) {
+	var stdout, stderr bytes.Buffer
+	cmd := exec.Command("/usr/bin/security", "find-generic-password", "-s", src.Service, "-a", src.Account, "-w")
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) && exitErr.ExitCode() == 44 {
+			return "", fmt.Errorf("%w: %s/%s", ErrSecretNotFound, src.Service, src.Account)
+		}
+		return "", fmt.Errorf("keychain lookup failed: %w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	return strings.TrimRight(stdout.String(), "\r\n"), nil
+}
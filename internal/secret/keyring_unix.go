@@ -0,0 +1,37 @@
+//go:build !darwin && !windows
+
+package secret
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// SystemKeyring reads from the freedesktop Secret Service (GNOME Keyring,
+// KWallet) through secret-tool. Entries are matched on the service and
+// account attributes:
+//
+//	secret-tool store --label=sshmanager service sshmanager account prod
+type SystemKeyring struct{}
+
+func (SystemKeyring) Lookup(src Source) (string, error) {
+	bin, err := exec.LookPath("secret-tool")
+	if err != nil {
+		return "", errors.New("secret-tool not found in PATH (install libsecret-tools)")
+	}
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.Command(bin, "lookup", "service", src.Service, "account", src.Account)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if stdout.Len() == 0 && stderr.Len() == 0 {
+			return "", fmt.Errorf("%w: %s/%s", ErrSecretNotFound, src.Service, src.Account)
+		}
+		return "", fmt.Errorf("secret-tool lookup failed: %w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	return strings.TrimRight(stdout.String(), "\r\n"), nil
+}
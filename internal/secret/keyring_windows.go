@@ -0,0 +1,13 @@
+//go:build windows
+
+package secret
+
+import "errors"
+
+// SystemKeyring is not available on Windows; use a command source such as
+// a PowerShell SecretManagement call instead.
+type SystemKeyring struct{}
+
+func (SystemKeyring) Lookup(Source) (string, error) {
+	return "", errors.New("keyring password sources are not supported on Windows, use command:<cmd>")
+}
@@ -0,0 +1,197 @@
+package secret
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	// FakeKeyringEnvVar points keyring lookups at a YAML file instead of the
+	// system keyring, so tests and CI can run without a Secret Service.
+	FakeKeyringEnvVar = "SSHMANAGER_KEYRING_FILE"
+
+	defaultCommandTimeout = 30 * time.Second
+)
+
+var ErrSecretNotFound = errors.New("secret not found")
+
+// Provider looks up secrets for one source kind.
+type Provider interface {
+	Lookup(src Source) (string, error)
+}
+
+// Resolver turns a passwordSource into the password to use for a session.
+type Resolver struct {
+	Command Provider
+	Keyring Provider
+
+	// Trusted, when set, limits command sources to the commands it lists.
+	Trusted *TrustedCommands
+	// Confirm is asked about a command missing from Trusted; approving it
+	// adds it. Without Confirm such commands fail with ErrCommandNotTrusted.
+	Confirm func(command string) (bool, error)
+}
+
+// DefaultResolver runs commands through the shell once they are in the
+// allowlist at trustedPath, and uses the system keyring, or the file named
+// by SSHMANAGER_KEYRING_FILE when it is set.
+func DefaultResolver(trustedPath string, confirm func(command string) (bool, error)) *Resolver {
+	var keyring Provider = SystemKeyring{}
+	if path := strings.TrimSpace(os.Getenv(FakeKeyringEnvVar)); path != "" {
+		keyring = FileKeyring{Path: path}
+	}
+	return &Resolver{
+		Command: CommandProvider{Timeout: defaultCommandTimeout},
+		Keyring: keyring,
+		Trusted: &TrustedCommands{Path: trustedPath},
+		Confirm: confirm,
+	}
+}
+
+// TrustCommand approves the command of a command source on this machine.
+// Other sources are ignored.
+func (r *Resolver) TrustCommand(rawSource string) error {
+	src, err := ParseSource(rawSource)
+	if err != nil || src.Kind != KindCommand || r.Trusted == nil {
+		return err
+	}
+	return r.Trusted.Trust(src.Command)
+}
+
+// IsCommandTrusted reports whether rawSource may run without asking: it is
+// not a command source, or its command is in the allowlist.
+func (r *Resolver) IsCommandTrusted(rawSource string) (bool, error) {
+	src, err := ParseSource(rawSource)
+	if err != nil {
+		return false, err
+	}
+	if src.Kind != KindCommand || r.Trusted == nil {
+		return true, nil
+	}
+	return r.Trusted.IsTrusted(src.Command)
+}
+
+// checkCommand refuses commands that are neither trusted nor approved.
+func (r *Resolver) checkCommand(src Source) error {
+	if r.Trusted == nil {
+		return nil
+	}
+	trusted, err := r.Trusted.IsTrusted(src.Command)
+	if err != nil || trusted {
+		return err
+	}
+	if r.Confirm == nil {
+		return ErrCommandNotTrusted
+	}
+	approved, err := r.Confirm(src.Command)
+	if err != nil {
+		return err
+	}
+	if !approved {
+		return ErrCommandNotTrusted
+	}
+	return r.Trusted.Trust(src.Command)
+}
+
+// Resolve returns the password for rawSource. Inline sources return
+// inlinePassword unchanged.
+func (r *Resolver) Resolve(rawSource, inlinePassword string) (string, error) {
+	src, err := ParseSource(rawSource)
+	if err != nil {
+		return "", err
+	}
+
+	var provider Provider
+	switch src.Kind {
+	case KindCommand:
+		if err := r.checkCommand(src); err != nil {
+			return "", fmt.Errorf("password source %s: %w", src, err)
+		}
+		provider = r.Command
+	case KindKeyring:
+		provider = r.Keyring
+	default:
+		return inlinePassword, nil
+	}
+	if provider == nil {
+		return "", fmt.Errorf("no provider configured for %s password sources", src.Kind)
+	}
+
+	password, err := provider.Lookup(src)
+	if err != nil {
+		return "", fmt.Errorf("password source %s: %w", src, err)
+	}
+	if password == "" {
+		return "", fmt.Errorf("password source %s returned an empty password", src)
+	}
+	return password, nil
+}
+
+// CommandProvider runs the source command with the user's shell and uses
+// the first line of its stdout, like `pass show <name>`.
+type CommandProvider struct {
+	Timeout time.Duration
+}
+
+func (p CommandProvider) Lookup(src Source) (string, error) {
+	ctx := context.Background()
+	if p.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, p.Timeout)
+		defer cancel()
+	}
+
+	var cmd *exec.Cmd
+	if runtime.GOOS == "windows" {
+		cmd = exec.CommandContext(ctx, "cmd", "/C", src.Command)
+	} else {
+		cmd = exec.CommandContext(ctx, "/bin/sh", "-c", src.Command)
+	}
+	var stdout, stderr bytes.Buffer
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return "", fmt.Errorf("command failed: %w: %s", err, msg)
+		}
+		return "", fmt.Errorf("command failed: %w", err)
+	}
+
+	line, _, _ := strings.Cut(stdout.String(), "\n")
+	return strings.TrimRight(line, "\r"), nil
+}
+
+// FileKeyring is a keyring backed by a plaintext YAML file mapping service
+// to account to secret. It is meant for tests and offline CI only.
+//
+//	sshmanager:
+//	  prod: s3cret
+type FileKeyring struct {
+	Path string
+}
+
+func (k FileKeyring) Lookup(src Source) (string, error) {
+	data, err := os.ReadFile(k.Path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read keyring file: %w", err)
+	}
+	var entries map[string]map[string]string
+	if err := yaml.Unmarshal(data, &entries); err != nil {
+		return "", fmt.Errorf("failed to parse keyring file: %w", err)
+	}
+	secret, ok := entries[src.Service][src.Account]
+	if !ok {
+		return "", fmt.Errorf("%w: %s/%s", ErrSecretNotFound, src.Service, src.Account)
+	}
+	return secret, nil
+}
@@ -0,0 +1,110 @@
+package secret
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func writeKeyringFile(t *testing.T) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "keyring.yaml")
+	content := "sshmanager:\n  prod: s3cret\nwork:\n  db: other\n"
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+	return path
+}
+
+func TestResolverUsesInlinePassword(t *testing.T) {
+	got, err := (&Resolver{}).Resolve("inline", "stored")
+	if err != nil || got != "stored" {
+		t.Fatalf("expected inline password, got %q, %v", got, err)
+	}
+}
+
+func TestResolverUsesFileKeyring(t *testing.T) {
+	r := &Resolver{Keyring: FileKeyring{Path: writeKeyringFile(t)}}
+
+	if got, err := r.Resolve("keyring:prod", "ignored"); err != nil || got != "s3cret" {
+		t.Fatalf("unexpected default service lookup: %q, %v", got, err)
+	}
+	if got, err := r.Resolve("keyring:work/db", ""); err != nil || got != "other" {
+		t.Fatalf("unexpected service lookup: %q, %v", got, err)
+	}
+	if _, err := r.Resolve("keyring:missing", ""); !errors.Is(err, ErrSecretNotFound) {
+		t.Fatalf("expected ErrSecretNotFound, got %v", err)
+	}
+}
+
+func TestDefaultResolverHonoursFakeKeyringEnv(t *testing.T) {
+	t.Setenv(FakeKeyringEnvVar, writeKeyringFile(t))
+	if got, err := DefaultResolver("", nil).Resolve("keyring:prod", ""); err != nil || got != "s3cret" {
+		t.Fatalf("expected fake keyring lookup, got %q, %v", got, err)
+	}
+}
+
+func TestCommandProviderUsesFirstLine(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses /bin/sh")
+	}
+	r := &Resolver{Command: CommandProvider{}}
+
+	got, err := r.Resolve("command:printf 'pw1\\nmetadata: x\\n'", "")
+	if err != nil || got != "pw1" {
+		t.Fatalf("expected first stdout line, got %q, %v", got, err)
+	}
+
+	_, err = r.Resolve("command:echo nope >&2; exit 3", "")
+	if err == nil || !strings.Contains(err.Error(), "nope") {
+		t.Fatalf("expected command failure with stderr, got %v", err)
+	}
+
+	if _, err := r.Resolve("command:true", ""); err == nil || !strings.Contains(err.Error(), "empty password") {
+		t.Fatalf("expected empty password error, got %v", err)
+	}
+}
+
+func TestResolverRunsOnlyTrustedCommands(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses /bin/sh")
+	}
+	trusted := &TrustedCommands{Path: filepath.Join(t.TempDir(), TrustedCommandsFileName)}
+	r := &Resolver{Command: CommandProvider{}, Trusted: trusted}
+
+	if _, err := r.Resolve("command:echo pw", ""); !errors.Is(err, ErrCommandNotTrusted) {
+		t.Fatalf("expected ErrCommandNotTrusted without a confirmation, got %v", err)
+	}
+
+	var asked []string
+	r.Confirm = func(command string) (bool, error) {
+		asked = append(asked, command)
+		return command == "echo pw", nil
+	}
+	if _, err := r.Resolve("command:echo other", ""); !errors.Is(err, ErrCommandNotTrusted) {
+		t.Fatalf("expected a declined command to be refused, got %v", err)
+	}
+	if got, err := r.Resolve("command:echo pw", ""); err != nil || got != "pw" {
+		t.Fatalf("expected the approved command to run, got %q, %v", got, err)
+	}
+	if got, err := r.Resolve("command: echo pw", ""); err != nil || got != "pw" {
+		t.Fatalf("expected the trusted command to run again, got %q, %v", got, err)
+	}
+	if len(asked) != 2 {
+		t.Fatalf("expected one confirmation per untrusted command, got %q", asked)
+	}
+
+	data, err := os.ReadFile(trusted.Path)
+	if err != nil {
+		t.Fatalf("ReadFile failed: %v", err)
+	}
+	if strings.Contains(string(data), "echo") || !strings.HasPrefix(string(data), "sha256:") {
+		t.Fatalf("expected only command digests in the allowlist, got %q", data)
+	}
+	if ok, err := r.IsCommandTrusted("keyring:prod"); err != nil || !ok {
+		t.Fatalf("expected non-command sources to need no approval, got %v, %v", ok, err)
+	}
+}
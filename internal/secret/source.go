@@ -0,0 +1,86 @@
+package secret
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+const (
+	KindInline  = "inline"
+	KindCommand = "command"
+	KindKeyring = "keyring"
+
+	// DefaultKeyringService is used for keyring sources that name only an
+	// account.
+	DefaultKeyringService = "sshmanager"
+)
+
+// Source describes where a connection password comes from. Inline sources
+// use the password stored in the connection file; every other kind is
+// looked up when connecting and never persisted.
+type Source struct {
+	Kind string
+	// Command is the shell command for command sources.
+	Command string
+	// Service and Account identify a keyring entry.
+	Service string
+	Account string
+}
+
+// ParseSource parses a passwordSource value:
+//
+//	""  or "inline"                     stored password
+//	"command:<cmd>"                     stdout of <cmd> run by the shell
+//	"keyring:<account>"                 keyring entry in the sshmanager service
+//	"keyring:<service>/<account>"       keyring entry in <service>
+func ParseSource(raw string) (Source, error) {
+	trimmed := strings.TrimSpace(raw)
+	if trimmed == "" || strings.EqualFold(trimmed, KindInline) {
+		return Source{Kind: KindInline}, nil
+	}
+
+	kind, ref, ok := strings.Cut(trimmed, ":")
+	if !ok {
+		return Source{}, fmt.Errorf("invalid password source %q, expected inline, command:<cmd> or keyring:[<service>/]<account>", raw)
+	}
+	ref = strings.TrimSpace(ref)
+	switch strings.ToLower(strings.TrimSpace(kind)) {
+	case KindCommand:
+		if ref == "" {
+			return Source{}, errors.New("command password source needs a command (command:<cmd>)")
+		}
+		return Source{Kind: KindCommand, Command: ref}, nil
+	case KindKeyring:
+		service, account := DefaultKeyringService, ref
+		if before, after, found := strings.Cut(ref, "/"); found {
+			service, account = strings.TrimSpace(before), strings.TrimSpace(after)
+		}
+		if service == "" || account == "" {
+			return Source{}, errors.New("keyring password source needs an account (keyring:[<service>/]<account>)")
+		}
+		return Source{Kind: KindKeyring, Service: service, Account: account}, nil
+	default:
+		return Source{}, fmt.Errorf("unknown password source kind %q (use inline, command or keyring)", kind)
+	}
+}
+
+// IsInline reports whether the password is stored in the connection file.
+func (s Source) IsInline() bool {
+	return s.Kind == "" || s.Kind == KindInline
+}
+
+// String returns the canonical passwordSource value.
+func (s Source) String() string {
+	switch s.Kind {
+	case KindCommand:
+		return KindCommand + ":" + s.Command
+	case KindKeyring:
+		if s.Service == DefaultKeyringService {
+			return KindKeyring + ":" + s.Account
+		}
+		return KindKeyring + ":" + s.Service + "/" + s.Account
+	default:
+		return KindInline
+	}
+}
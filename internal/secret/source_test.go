@@ -0,0 +1,37 @@
+package secret
+
+import "testing"
+
+func TestParseSource(t *testing.T) {
+	cases := []struct {
+		raw  string
+		want Source
+		str  string
+	}{
+		{"", Source{Kind: KindInline}, "inline"},
+		{" Inline ", Source{Kind: KindInline}, "inline"},
+		{"command:pass show prod", Source{Kind: KindCommand, Command: "pass show prod"}, "command:pass show prod"},
+		{"keyring:prod", Source{Kind: KindKeyring, Service: DefaultKeyringService, Account: "prod"}, "keyring:prod"},
+		{"KEYRING: work/db-admin ", Source{Kind: KindKeyring, Service: "work", Account: "db-admin"}, "keyring:work/db-admin"},
+	}
+	for _, tc := range cases {
+		got, err := ParseSource(tc.raw)
+		if err != nil {
+			t.Fatalf("ParseSource(%q) failed: %v", tc.raw, err)
+		}
+		if got != tc.want {
+			t.Fatalf("ParseSource(%q) = %+v, want %+v", tc.raw, got, tc.want)
+		}
+		if got.String() != tc.str {
+			t.Fatalf("String() = %q, want %q", got.String(), tc.str)
+		}
+	}
+}
+
+func TestParseSourceRejectsInvalidValues(t *testing.T) {
+	for _, raw := range []string{"command:", "keyring:", "keyring:svc/", "vault:prod", "plain"} {
+		if _, err := ParseSource(raw); err == nil {
+			t.Fatalf("expected ParseSource(%q) to fail", raw)
+		}
+	}
+}
@@ -0,0 +1,107 @@
+package secret
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/emirhangumus/sshmanager/internal/storage"
+)
+
+// TrustedCommandsFileName is the per-machine allowlist of password
+// commands in the SSH Manager home. It is never part of a vault's shared
+// connection file, so a source that arrives by import, sync or a team vault
+// cannot approve itself.
+const TrustedCommandsFileName = "trusted-commands"
+
+var ErrCommandNotTrusted = errors.New("password command is not trusted on this machine")
+
+// TrustedCommands is an allowlist of command password sources. It stores
+// one SHA-256 digest per line, so the file does not reveal the commands.
+type TrustedCommands struct {
+	Path string
+}
+
+// TrustedCommandsPath returns the allowlist file in homeDir.
+func TrustedCommandsPath(homeDir string) string {
+	return filepath.Join(homeDir, TrustedCommandsFileName)
+}
+
+// IsTrusted reports whether command was approved on this machine.
+func (t TrustedCommands) IsTrusted(command string) (bool, error) {
+	digests, err := t.load()
+	if err != nil {
+		return false, err
+	}
+	_, ok := digests[commandDigest(command)]
+	return ok, nil
+}
+
+// Trust adds command to the allowlist.
+func (t TrustedCommands) Trust(command string) error {
+	if strings.TrimSpace(t.Path) == "" {
+		return errors.New("no trusted commands file configured")
+	}
+	data, err := os.ReadFile(t.Path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to read trusted commands: %w", err)
+	}
+	digest := commandDigest(command)
+	digests, err := parseTrustedCommands(data)
+	if err != nil {
+		return err
+	}
+	if _, ok := digests[digest]; ok {
+		return nil
+	}
+
+	if len(data) > 0 && !bytes.HasSuffix(data, []byte("\n")) {
+		data = append(data, '\n')
+	}
+	data = append(data, digest+"\n"...)
+	if err := storage.WriteFileAtomic(t.Path, data, 0o600); err != nil {
+		return fmt.Errorf("failed to write trusted commands: %w", err)
+	}
+	return nil
+}
+
+func (t TrustedCommands) load() (map[string]struct{}, error) {
+	if strings.TrimSpace(t.Path) == "" {
+		return nil, nil
+	}
+	data, err := os.ReadFile(t.Path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read trusted commands: %w", err)
+	}
+	return parseTrustedCommands(data)
+}
+
+func parseTrustedCommands(data []byte) (map[string]struct{}, error) {
+	digests := make(map[string]struct{})
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		digests[line] = struct{}{}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to parse trusted commands: %w", err)
+	}
+	return digests, nil
+}
+
+func commandDigest(command string) string {
+	sum := sha256.Sum256([]byte(strings.TrimSpace(command)))
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,54 @@
+package startup
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	cryptoutil "github.com/emirhangumus/sshmanager/internal/crypto"
+	prompttext "github.com/emirhangumus/sshmanager/internal/ui/prompt"
+)
+
+var (
+	// canPrompt and keyProtectionPrompt are replaced in tests.
+	canPrompt           = cryptoutil.CanPrompt
+	keyProtectionPrompt = prompttext.KeyProtectionPrompt
+)
+
+// ensureKeyFile offers a passphrase-protected key when the key file is
+// created interactively. Without a terminal, or when
+// SSHMANAGER_MASTER_PASSPHRASE already decides the mode, LoadKey creates
+// the key file as before.
+func ensureKeyFile(secretKeyFilePath string) error {
+	if _, err := os.Stat(secretKeyFilePath); err == nil {
+		return nil
+	} else if !os.IsNotExist(err) {
+		return fmt.Errorf("failed to stat key file: %w", err)
+	}
+	if strings.TrimSpace(os.Getenv(cryptoutil.PassphraseEnvVar)) != "" || !canPrompt() {
+		return nil
+	}
+
+	passphrase, err := keyProtectionPrompt()
+	if err != nil {
+		if prompttext.IsCancelError(err) {
+			return errors.New("key setup cancelled; no key file was created")
+		}
+		return err
+	}
+	passphrase = strings.TrimSpace(passphrase)
+	if passphrase == "" {
+		return nil
+	}
+
+	keyFile, err := cryptoutil.NewPassphraseKeyFile(passphrase, cryptoutil.DefaultArgon2idParams())
+	if err != nil {
+		return err
+	}
+	if err := cryptoutil.WriteKeyFile(secretKeyFilePath, keyFile); err != nil {
+		return err
+	}
+	cryptoutil.RememberKeyFile(secretKeyFilePath, keyFile)
+	return nil
+}
@@ -18,6 +18,9 @@ func Setup(connectionFilePath, configFilePath, secretKeyFilePath string) error {
 		return err
 	}
 
+	if err := ensureKeyFile(secretKeyFilePath); err != nil {
+		return err
+	}
 	if _, err := cryptoutil.LoadKey(secretKeyFilePath); err != nil {
 		return err
 	}
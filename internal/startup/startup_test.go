@@ -7,6 +7,7 @@ import (
 	"testing"
 
 	"github.com/emirhangumus/sshmanager/internal/config"
+	cryptoutil "github.com/emirhangumus/sshmanager/internal/crypto"
 	"github.com/emirhangumus/sshmanager/internal/store"
 )
 
@@ -112,3 +113,58 @@ func assertFileExists(t *testing.T, filePath string) {
 		t.Fatalf("expected file %s, got directory", filePath)
 	}
 }
+
+func stubKeyProtection(t *testing.T, passphrase string) {
+	t.Helper()
+	t.Setenv(cryptoutil.PassphraseEnvVar, "")
+	previousCanPrompt, previousPrompt := canPrompt, keyProtectionPrompt
+	canPrompt = func() bool { return true }
+	keyProtectionPrompt = func() (string, error) { return passphrase, nil }
+	t.Cleanup(func() {
+		canPrompt, keyProtectionPrompt = previousCanPrompt, previousPrompt
+		cryptoutil.ForgetPassphrases()
+	})
+}
+
+func TestSetupOffersPassphraseKeyOnFirstRun(t *testing.T) {
+	stubKeyProtection(t, "first-run passphrase")
+	tmpDir := t.TempDir()
+	connPath := filepath.Join(tmpDir, ".sshmanager", "conn")
+	configPath := filepath.Join(tmpDir, ".sshmanager", "config.yaml")
+	keyPath := filepath.Join(tmpDir, ".sshmanager", "secret.key")
+
+	if err := Setup(connPath, configPath, keyPath); err != nil {
+		t.Fatalf("Setup failed: %v", err)
+	}
+
+	info, err := cryptoutil.InspectKeyFile(keyPath)
+	if err != nil {
+		t.Fatalf("InspectKeyFile failed: %v", err)
+	}
+	if info.Mode != cryptoutil.KeyFileModePassphrase || info.KDF.KDF != cryptoutil.KDFArgon2id {
+		t.Fatalf("expected argon2id passphrase key, got %+v", info)
+	}
+
+	cryptoutil.ForgetPassphrases()
+	t.Setenv(cryptoutil.PassphraseEnvVar, "first-run passphrase")
+	if _, err := store.NewConnectionStore(connPath, keyPath).Load(); err != nil {
+		t.Fatalf("Load with the chosen passphrase failed: %v", err)
+	}
+}
+
+func TestSetupKeepsRawKeyWhenDeclined(t *testing.T) {
+	stubKeyProtection(t, "")
+	tmpDir := t.TempDir()
+	keyPath := filepath.Join(tmpDir, ".sshmanager", "secret.key")
+
+	if err := Setup(filepath.Join(tmpDir, ".sshmanager", "conn"), filepath.Join(tmpDir, ".sshmanager", "config.yaml"), keyPath); err != nil {
+		t.Fatalf("Setup failed: %v", err)
+	}
+	keyData, err := os.ReadFile(keyPath)
+	if err != nil {
+		t.Fatalf("failed to read key file: %v", err)
+	}
+	if len(keyData) != 32 {
+		t.Fatalf("expected 32-byte raw key, got %d bytes", len(keyData))
+	}
+}
@@ -0,0 +1,175 @@
+package store
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/emirhangumus/sshmanager/internal/config"
+	"github.com/emirhangumus/sshmanager/internal/model"
+	"github.com/emirhangumus/sshmanager/internal/storage"
+)
+
+// Every save keeps the previous connection file as generation 1 (conn.1),
+// shifting older generations up to the configured retention. Generations
+// are copies of the encrypted file, so they need the same key.
+
+// warningOutput receives notices about recovered data. Tests replace it.
+var warningOutput io.Writer = os.Stderr
+
+// WithBackupGenerations sets how many previous versions of the connection
+// file saves keep. Zero turns it off. Without it the store uses
+// backup.generations from the config file in the vault directory.
+func WithBackupGenerations(n int) Option {
+	return func(s *ConnectionStore) {
+		n = max(n, 0)
+		s.backupGenerations = &n
+	}
+}
+
+// backupRetention returns the number of generations to keep. An
+// unreadable config is reported by the commands that need it, so it falls
+// back to the default here.
+func (s *ConnectionStore) backupRetention() int {
+	if s.backupGenerations != nil {
+		return *s.backupGenerations
+	}
+	cfg, err := config.LoadConfig(filepath.Join(filepath.Dir(s.connectionFilePath), config.FileName))
+	if err != nil {
+		return config.DefaultBackupGenerations
+	}
+	return max(cfg.Backup.Generations, 0)
+}
+
+// BackupFilePath returns the path of generation n of a connection file.
+func BackupFilePath(connectionFilePath string, generation int) string {
+	return connectionFilePath + "." + strconv.Itoa(generation)
+}
+
+// BackupFilePaths returns the existing generations of a connection file,
+// newest first.
+func BackupFilePaths(connectionFilePath string) []string {
+	var paths []string
+	for generation := 1; ; generation++ {
+		path := BackupFilePath(connectionFilePath, generation)
+		if _, err := os.Stat(path); err != nil {
+			return paths
+		}
+		paths = append(paths, path)
+	}
+}
+
+// BackupGeneration describes one kept version of the connection file.
+// Err is set when the generation cannot be decrypted with the current key.
+type BackupGeneration struct {
+	Generation  int
+	Path        string
+	SavedAt     time.Time
+	Size        int64
+	Connections int
+	Err         error
+}
+
+// BackupGenerations lists the kept generations, newest first.
+func (s *ConnectionStore) BackupGenerations() ([]BackupGeneration, error) {
+	var generations []BackupGeneration
+	for i, path := range BackupFilePaths(s.connectionFilePath) {
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, err
+		}
+		generation := BackupGeneration{Generation: i + 1, Path: path, SavedAt: info.ModTime(), Size: info.Size()}
+		if connFile, err := s.LoadBackupGeneration(i + 1); err != nil {
+			generation.Err = err
+		} else {
+			generation.Connections = len(connFile.Connections)
+		}
+		generations = append(generations, generation)
+	}
+	return generations, nil
+}
+
+// LoadBackupGeneration decrypts generation n.
+func (s *ConnectionStore) LoadBackupGeneration(generation int) (model.ConnectionFile, error) {
+	if generation < 1 {
+		return model.ConnectionFile{}, fmt.Errorf("invalid backup generation %d", generation)
+	}
+	data, err := os.ReadFile(BackupFilePath(s.connectionFilePath, generation))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return model.ConnectionFile{}, fmt.Errorf("backup generation %d does not exist (see 'sshmanager backup list')", generation)
+		}
+		return model.ConnectionFile{}, err
+	}
+	if len(data) == 0 {
+		return model.ConnectionFile{}, fmt.Errorf("backup generation %d is empty", generation)
+	}
+	return s.DecryptConnectionData(data)
+}
+
+// rotateBackupsWithoutLock keeps the current connection file as generation
+// 1 before it is replaced by content. Files that cannot be decrypted are
+// never kept, and saves that do not change anything do not rotate.
+func (s *ConnectionStore) rotateBackupsWithoutLock(content string) error {
+	retention := s.backupRetention()
+	if retention == 0 {
+		return nil
+	}
+
+	data, err := os.ReadFile(s.connectionFilePath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	if len(data) == 0 {
+		return nil
+	}
+	current, err := s.decryptConnectionData(data)
+	if err != nil || current == content {
+		return nil
+	}
+
+	// Drop generations beyond the retention, including those left over
+	// from a higher setting.
+	if existing := BackupFilePaths(s.connectionFilePath); len(existing) >= retention {
+		for _, path := range existing[retention-1:] {
+			if err := os.Remove(path); err != nil {
+				return fmt.Errorf("failed to drop old backup generation: %w", err)
+			}
+		}
+	}
+	for generation := retention - 1; generation >= 1; generation-- {
+		err := os.Rename(BackupFilePath(s.connectionFilePath, generation), BackupFilePath(s.connectionFilePath, generation+1))
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("failed to rotate backup generations: %w", err)
+		}
+	}
+	if err := storage.WriteFileAtomic(BackupFilePath(s.connectionFilePath, 1), data, 0o600); err != nil {
+		return fmt.Errorf("failed to write backup generation: %w", err)
+	}
+	return nil
+}
+
+// readBackupFallback returns the newest generation that decrypts, after the
+// connection file itself could not be read because of cause.
+func (s *ConnectionStore) readBackupFallback(cause error) (string, error) {
+	for i, path := range BackupFilePaths(s.connectionFilePath) {
+		data, err := os.ReadFile(path)
+		if err != nil || len(data) == 0 {
+			continue
+		}
+		content, err := s.decryptConnectionData(data)
+		if err != nil {
+			continue
+		}
+		_, _ = fmt.Fprintf(warningOutput, "warning: %s could not be read (%v); using backup generation %d from %s. The next change rewrites the file; 'sshmanager restore --generation %d' restores it now.\n", s.connectionFilePath, cause, i+1, path, i+1)
+		return content, nil
+	}
+	return "", cause
+}
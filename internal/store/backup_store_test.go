@@ -0,0 +1,117 @@
+package store
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/emirhangumus/sshmanager/internal/config"
+	"github.com/emirhangumus/sshmanager/internal/model"
+)
+
+func addBackupTestConnection(t *testing.T, connStore *ConnectionStore, alias string) {
+	t.Helper()
+	if err := connStore.Update(func(connFile *model.ConnectionFile) error {
+		return connFile.AddConnection(model.SSHConnection{Username: "ops", Host: alias + ".internal", Alias: alias})
+	}); err != nil {
+		t.Fatalf("Update(add %s) failed: %v", alias, err)
+	}
+}
+
+func TestSaveRotatesBackupGenerations(t *testing.T) {
+	connStore := newJournalTestStore(t, WithBackupGenerations(2))
+
+	for _, alias := range []string{"a", "b", "c"} {
+		addBackupTestConnection(t, connStore, alias)
+	}
+	// A save that changes nothing keeps the generations as they are.
+	if err := connStore.Update(func(*model.ConnectionFile) error { return nil }); err != nil {
+		t.Fatalf("Update(noop) failed: %v", err)
+	}
+
+	generations, err := connStore.BackupGenerations()
+	if err != nil {
+		t.Fatalf("BackupGenerations failed: %v", err)
+	}
+	if len(generations) != 2 || generations[0].Connections != 2 || generations[1].Connections != 1 {
+		t.Fatalf("unexpected generations: %+v", generations)
+	}
+	if _, err := os.Stat(BackupFilePath(connStore.connectionFilePath, 3)); !os.IsNotExist(err) {
+		t.Fatalf("expected no generation beyond the retention, got %v", err)
+	}
+	raw, err := os.ReadFile(generations[0].Path)
+	if err != nil {
+		t.Fatalf("ReadFile(generation) failed: %v", err)
+	}
+	if bytes.Contains(raw, []byte("b.internal")) {
+		t.Fatal("backup generations must stay encrypted")
+	}
+
+	loaded, err := connStore.LoadBackupGeneration(1)
+	if err != nil || loaded.GetConnectionByAlias("b") == nil || loaded.GetConnectionByAlias("c") != nil {
+		t.Fatalf("LoadBackupGeneration(1) = %+v, %v", loaded.Connections, err)
+	}
+	if _, err := connStore.LoadBackupGeneration(3); err == nil || !strings.Contains(err.Error(), "does not exist") {
+		t.Fatalf("expected a missing generation error, got %v", err)
+	}
+
+	// Without the option the store reads backup.generations from the
+	// vault's config file.
+	cfg := config.Default()
+	cfg.Backup.Generations = 0
+	if err := config.SaveConfig(filepath.Join(filepath.Dir(connStore.connectionFilePath), config.FileName), cfg); err != nil {
+		t.Fatalf("SaveConfig failed: %v", err)
+	}
+	unconfigured := NewConnectionStore(connStore.connectionFilePath, connStore.secretKeyFilePath)
+	addBackupTestConnection(t, unconfigured, "d")
+	if generations, _ := connStore.BackupGenerations(); len(generations) != 2 || generations[0].Connections != 2 {
+		t.Fatalf("expected retention 0 to leave generations alone, got %+v", generations)
+	}
+}
+
+func TestLoadFallsBackToPreviousGenerationOnCorruptFile(t *testing.T) {
+	connStore := newJournalTestStore(t)
+	var warnings bytes.Buffer
+	warningOutput = &warnings
+	t.Cleanup(func() { warningOutput = os.Stderr })
+
+	addBackupTestConnection(t, connStore, "web")
+	addBackupTestConnection(t, connStore, "db")
+
+	raw, err := os.ReadFile(connStore.connectionFilePath)
+	if err != nil {
+		t.Fatalf("ReadFile(conn) failed: %v", err)
+	}
+	for name, corrupted := range map[string][]byte{
+		"truncated": raw[:len(raw)/2],
+		"empty":     {},
+	} {
+		if err := os.WriteFile(connStore.connectionFilePath, corrupted, 0o600); err != nil {
+			t.Fatalf("WriteFile(%s) failed: %v", name, err)
+		}
+		warnings.Reset()
+
+		loaded, err := connStore.Load()
+		if err != nil {
+			t.Fatalf("Load(%s) failed: %v", name, err)
+		}
+		if loaded.GetConnectionByAlias("web") == nil || loaded.GetConnectionByAlias("db") != nil {
+			t.Fatalf("Load(%s) expected generation 1, got %+v", name, loaded.Connections)
+		}
+		if !strings.Contains(warnings.String(), "using backup generation 1") {
+			t.Fatalf("Load(%s) expected a warning, got %q", name, warnings.String())
+		}
+	}
+
+	// The next change rewrites conn without rotating the corrupt file in.
+	addBackupTestConnection(t, connStore, "cache")
+	loaded, err := connStore.Load()
+	if err != nil || len(loaded.Connections) != 2 {
+		t.Fatalf("expected web and cache after repair, got %+v, %v", loaded.Connections, err)
+	}
+	if generation, err := connStore.LoadBackupGeneration(1); err != nil || generation.GetConnectionByAlias("web") == nil {
+		t.Fatalf("expected generation 1 to stay intact, got %+v, %v", generation.Connections, err)
+	}
+}
@@ -1,6 +1,7 @@
 package store
 
 import (
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -15,19 +16,30 @@ import (
 type ConnectionStore struct {
 	connectionFilePath string
 	secretKeyFilePath  string
+	saveHook           func(connectionFilePath string)
+	journalCommand     string
+	backupGenerations  *int
 }
 
+// Option configures a ConnectionStore.
+type Option func(*ConnectionStore)
+
 var (
 	connectionLockTimeout       = 5 * time.Second
 	connectionLockRetryInterval = 50 * time.Millisecond
 	connectionLockStaleAfter    = 2 * time.Minute
 )
 
-func NewConnectionStore(connectionFilePath, secretKeyFilePath string) *ConnectionStore {
-	return &ConnectionStore{
+func NewConnectionStore(connectionFilePath, secretKeyFilePath string, options ...Option) *ConnectionStore {
+	s := &ConnectionStore{
 		connectionFilePath: connectionFilePath,
 		secretKeyFilePath:  secretKeyFilePath,
+		saveHook:           commitToSyncRepo,
+	}
+	for _, option := range options {
+		option(s)
 	}
+	return s
 }
 
 func (s *ConnectionStore) InitializeIfEmpty() error {
@@ -38,6 +50,14 @@ func (s *ConnectionStore) InitializeIfEmpty() error {
 	if !isEmpty {
 		return nil
 	}
+	// An emptied file with backups is read from the newest generation.
+	if len(BackupFilePaths(s.connectionFilePath)) > 0 {
+		return nil
+	}
+	// A team vault's connection file comes from the team (or InitTeam).
+	if team, err := s.IsTeamVault(); err != nil || team {
+		return err
+	}
 	return s.Save(model.NewConnectionFile())
 }
 
@@ -46,6 +66,14 @@ func (s *ConnectionStore) Load() (model.ConnectionFile, error) {
 }
 
 func (s *ConnectionStore) Save(connFile model.ConnectionFile) error {
+	if err := s.save(connFile); err != nil {
+		return err
+	}
+	s.notifySaved()
+	return nil
+}
+
+func (s *ConnectionStore) save(connFile model.ConnectionFile) error {
 	unlock, err := s.acquireMutationLock()
 	if err != nil {
 		return err
@@ -57,21 +85,43 @@ func (s *ConnectionStore) Save(connFile model.ConnectionFile) error {
 
 // Update executes an in-place mutation under a process lock and persists it.
 func (s *ConnectionStore) Update(mutator func(*model.ConnectionFile) error) error {
+	if err := s.update(mutator); err != nil {
+		return err
+	}
+	s.notifySaved()
+	return nil
+}
+
+func (s *ConnectionStore) update(mutator func(*model.ConnectionFile) error) error {
 	unlock, err := s.acquireMutationLock()
 	if err != nil {
 		return err
 	}
 	defer unlock()
+	return s.updateWithoutLock(mutator)
+}
 
+func (s *ConnectionStore) updateWithoutLock(mutator func(*model.ConnectionFile) error) error {
 	connFile, err := s.loadWithoutLock()
 	if err != nil {
 		return err
 	}
+	before, err := cloneConnectionFile(connFile)
+	if err != nil {
+		return err
+	}
 
 	if err := mutator(&connFile); err != nil {
 		return err
 	}
-	return s.saveWithoutLock(connFile)
+	persisted, err := s.persistWithoutLock(connFile)
+	if err != nil {
+		return err
+	}
+	if err := s.recordJournal(before, persisted); err != nil {
+		return fmt.Errorf("connections saved, but the change could not be recorded for undo: %w", err)
+	}
+	return nil
 }
 
 func (s *ConnectionStore) loadWithoutLock() (model.ConnectionFile, error) {
@@ -79,12 +129,7 @@ func (s *ConnectionStore) loadWithoutLock() (model.ConnectionFile, error) {
 }
 
 func (s *ConnectionStore) load(lockHeld bool) (model.ConnectionFile, error) {
-	key, err := cryptoutil.LoadKey(s.secretKeyFilePath)
-	if err != nil {
-		return model.ConnectionFile{}, err
-	}
-
-	content, err := decryptAndReadFile(s.connectionFilePath, key)
+	content, err := s.readConnectionFile()
 	if err != nil {
 		return model.ConnectionFile{}, err
 	}
@@ -110,30 +155,129 @@ func (s *ConnectionStore) load(lockHeld bool) (model.ConnectionFile, error) {
 	return connFile, nil
 }
 
+// readConnectionFile decrypts the connection file with the vault's shared
+// key, or with the member identity for team vaults.
+func (s *ConnectionStore) readConnectionFile() (string, error) {
+	id, team, err := s.teamIdentity()
+	if err != nil {
+		return "", err
+	}
+	if team {
+		content, _, _, err := s.openTeamFile(id)
+		if err != nil && !errors.Is(err, ErrTeamFileMissing) && !errors.Is(err, cryptoutil.ErrNotTeamMember) && !isFileError(err) {
+			return s.readBackupFallback(err)
+		}
+		return content, err
+	}
+
+	key, err := cryptoutil.LoadKey(s.secretKeyFilePath)
+	if err != nil {
+		return "", err
+	}
+
+	content, err := decryptAndReadFile(s.connectionFilePath, key)
+	if err != nil {
+		if isFileError(err) {
+			return "", err
+		}
+		// A truncated or corrupted file is read from the newest intact
+		// backup generation instead.
+		content, err = s.readBackupFallback(err)
+		if errors.Is(err, cryptoutil.ErrDecryptFailed) {
+			// Do not keep offering a key derived from a mistyped passphrase.
+			cryptoutil.InvalidateKey(s.secretKeyFilePath)
+		}
+		return content, err
+	}
+	if content == "" && len(BackupFilePaths(s.connectionFilePath)) > 0 {
+		return s.readBackupFallback(errors.New("the file is empty"))
+	}
+	return content, nil
+}
+
+// isFileError reports whether err came from reading the file rather than
+// from its contents.
+func isFileError(err error) bool {
+	var pathErr *os.PathError
+	return errors.As(err, &pathErr)
+}
+
 func (s *ConnectionStore) saveWithoutLock(connFile model.ConnectionFile) error {
+	_, err := s.persistWithoutLock(connFile)
+	return err
+}
+
+// persistWithoutLock saves connFile and returns it as it was written, so
+// callers can compare it with what a later Load returns.
+func (s *ConnectionStore) persistWithoutLock(connFile model.ConnectionFile) (model.ConnectionFile, error) {
 	if strings.TrimSpace(connFile.Version) == "" {
 		connFile.Version = model.CurrentConnectionFileVersion
 	}
 	connFile.EnsureIDs()
 
+	contentStr, err := toYAMLString(connFile)
+	if err != nil {
+		return model.ConnectionFile{}, err
+	}
+	persisted, err := parseConnectionFile(contentStr)
+	if err != nil {
+		return model.ConnectionFile{}, err
+	}
+
+	if err := s.rotateBackupsWithoutLock(contentStr); err != nil {
+		return model.ConnectionFile{}, err
+	}
+
+	id, team, err := s.teamIdentity()
+	if err != nil {
+		return model.ConnectionFile{}, err
+	}
+	if team {
+		return persisted, s.saveTeamWithoutLock(id, contentStr)
+	}
+
 	key, err := cryptoutil.LoadKey(s.secretKeyFilePath)
 	if err != nil {
-		return err
+		return model.ConnectionFile{}, err
 	}
 
-	contentStr, err := toYAMLString(connFile)
+	kdf, err := keyKDF(s.secretKeyFilePath)
 	if err != nil {
-		return err
+		return model.ConnectionFile{}, err
 	}
 
-	if err := encryptAndStoreFile(contentStr, s.connectionFilePath, key); err != nil {
-		return err
+	// Always writes the current envelope version, which upgrades legacy
+	// files on the first save after an update.
+	if err := encryptAndStoreFile(contentStr, s.connectionFilePath, key, kdf); err != nil {
+		return model.ConnectionFile{}, err
 	}
-	return nil
+	return persisted, nil
+}
+
+// cloneConnectionFile deep-copies connFile through its YAML form.
+func cloneConnectionFile(connFile model.ConnectionFile) (model.ConnectionFile, error) {
+	contentStr, err := toYAMLString(connFile)
+	if err != nil {
+		return model.ConnectionFile{}, err
+	}
+	return parseConnectionFile(contentStr)
 }
 
 func (s *ConnectionStore) acquireMutationLock() (func(), error) {
-	lockPath := s.connectionFilePath + ".lock"
+	return acquireFileLock(s.connectionFilePath + ".lock")
+}
+
+// LockFile takes the lock of another file in the vault that is updated in
+// place, such as the managed known_hosts file, the same way the store locks
+// its own files. The returned func releases the lock.
+func LockFile(path string) (func(), error) {
+	return acquireFileLock(path + ".lock")
+}
+
+// acquireFileLock creates lockPath exclusively, retrying until
+// connectionLockTimeout and breaking locks older than
+// connectionLockStaleAfter. The returned func releases the lock.
+func acquireFileLock(lockPath string) (func(), error) {
 	lockDir := filepath.Dir(lockPath)
 	if err := os.MkdirAll(lockDir, 0o700); err != nil {
 		return nil, fmt.Errorf("failed to create lock directory: %w", err)
@@ -152,7 +296,7 @@ func (s *ConnectionStore) acquireMutationLock() (func(), error) {
 			return nil, fmt.Errorf("failed to acquire mutation lock: %w", err)
 		}
 
-		if s.shouldBreakStaleLock(lockPath) {
+		if shouldBreakStaleLock(lockPath) {
 			_ = os.Remove(lockPath)
 			continue
 		}
@@ -164,7 +308,7 @@ func (s *ConnectionStore) acquireMutationLock() (func(), error) {
 	}
 }
 
-func (s *ConnectionStore) shouldBreakStaleLock(lockPath string) bool {
+func shouldBreakStaleLock(lockPath string) bool {
 	info, err := os.Stat(lockPath)
 	if err != nil {
 		return false
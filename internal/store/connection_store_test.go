@@ -6,6 +6,7 @@ import (
 	"testing"
 
 	cryptoutil "github.com/emirhangumus/sshmanager/internal/crypto"
+	"github.com/emirhangumus/sshmanager/internal/model"
 	"github.com/emirhangumus/sshmanager/internal/storage"
 )
 
@@ -24,7 +25,7 @@ func TestLoadMigratesLegacyListAndAddsIDs(t *testing.T) {
 	}
 
 	legacy := "- username: u\n  host: h\n  password: p\n"
-	if err := encryptAndStoreFile(legacy, connPath, key); err != nil {
+	if err := encryptAndStoreFile(legacy, connPath, key, cryptoutil.KDFRaw); err != nil {
 		t.Fatalf("encryptAndStoreFile failed: %v", err)
 	}
 
@@ -52,3 +53,46 @@ func TestLoadMigratesLegacyListAndAddsIDs(t *testing.T) {
 		t.Fatal("expected migrated schema to include version field")
 	}
 }
+
+func TestSaveUpgradesLegacyEnvelope(t *testing.T) {
+	tmpDir := t.TempDir()
+	connPath := filepath.Join(tmpDir, "conn")
+	keyPath := filepath.Join(tmpDir, "secret.key")
+
+	key, err := cryptoutil.LoadKey(keyPath)
+	if err != nil {
+		t.Fatalf("LoadKey failed: %v", err)
+	}
+	legacy, err := cryptoutil.EncryptData("version: \"1.0\"\nconnections:\n- id: c1\n  username: u\n  host: h\n  password: p\n", key)
+	if err != nil {
+		t.Fatalf("EncryptData failed: %v", err)
+	}
+	if err := storage.WriteFileAtomic(connPath, legacy, 0o600); err != nil {
+		t.Fatalf("WriteFileAtomic failed: %v", err)
+	}
+
+	header, ok, err := InspectEncryptedFile(connPath)
+	if err != nil || !ok || header.Version != cryptoutil.EnvelopeVersionLegacy {
+		t.Fatalf("expected legacy envelope before save, got %+v ok=%v err=%v", header, ok, err)
+	}
+
+	connStore := NewConnectionStore(connPath, keyPath)
+	if err := connStore.Update(func(*model.ConnectionFile) error { return nil }); err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+
+	header, ok, err = InspectEncryptedFile(connPath)
+	if err != nil || !ok {
+		t.Fatalf("InspectEncryptedFile failed: ok=%v err=%v", ok, err)
+	}
+	if header.Version != cryptoutil.EnvelopeVersion1 || header.KDF != cryptoutil.KDFRaw || header.KeyID != cryptoutil.KeyID(key) {
+		t.Fatalf("expected upgraded v1 envelope, got %+v", header)
+	}
+	loaded, err := connStore.Load()
+	if err != nil {
+		t.Fatalf("Load after upgrade failed: %v", err)
+	}
+	if len(loaded.Connections) != 1 || loaded.Connections[0].Host != "h" {
+		t.Fatalf("unexpected connections after upgrade: %+v", loaded.Connections)
+	}
+}
@@ -8,8 +8,10 @@ import (
 	"github.com/emirhangumus/sshmanager/internal/storage"
 )
 
-func encryptAndStoreFile(data, filePath string, key []byte) error {
-	encryptedData, err := cryptoutil.EncryptData(data, key)
+// encryptAndStoreFile writes data as a versioned envelope. kdf is recorded in
+// the envelope header; see keyKDF.
+func encryptAndStoreFile(data, filePath string, key []byte, kdf string) error {
+	encryptedData, err := cryptoutil.SealEnvelope(data, key, kdf)
 	if err != nil {
 		return err
 	}
@@ -20,6 +22,8 @@ func encryptAndStoreFile(data, filePath string, key []byte) error {
 	return nil
 }
 
+// decryptAndReadFile reads a versioned envelope or a legacy nonce-prefixed
+// payload.
 func decryptAndReadFile(filePath string, key []byte) (string, error) {
 	encryptedData, err := os.ReadFile(filePath)
 	if err != nil {
@@ -29,9 +33,37 @@ func decryptAndReadFile(filePath string, key []byte) (string, error) {
 		return "", nil
 	}
 
-	content, err := cryptoutil.DecryptData(encryptedData, key)
+	content, _, err := cryptoutil.OpenEnvelope(encryptedData, key)
 	if err != nil {
 		return "", err
 	}
 	return content, nil
 }
+
+// keyKDF names the derivation of the key file at secretKeyFilePath for
+// envelope headers. It is only called after the key was loaded, so the file
+// is known to be readable.
+func keyKDF(secretKeyFilePath string) (string, error) {
+	info, err := cryptoutil.InspectKeyFile(secretKeyFilePath)
+	if err != nil {
+		return "", err
+	}
+	return info.KDFName(), nil
+}
+
+// InspectEncryptedFile reports the envelope header of the encrypted file at
+// filePath without decrypting it. ok is false for missing or empty files.
+func InspectEncryptedFile(filePath string) (header cryptoutil.EnvelopeHeader, ok bool, err error) {
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return header, false, nil
+		}
+		return header, false, err
+	}
+	if len(data) == 0 {
+		return header, false, nil
+	}
+	header, err = cryptoutil.InspectEnvelope(data)
+	return header, err == nil, err
+}
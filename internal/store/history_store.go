@@ -0,0 +1,98 @@
+package store
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	cryptoutil "github.com/emirhangumus/sshmanager/internal/crypto"
+	"github.com/emirhangumus/sshmanager/internal/model"
+)
+
+const historyFileName = "history"
+
+// HistoryStore persists connection history encrypted with the same key as
+// the ConnectionStore it sits next to.
+type HistoryStore struct {
+	historyFilePath   string
+	secretKeyFilePath string
+}
+
+// HistoryFilePath returns the history file location for a connection file.
+func HistoryFilePath(connectionFilePath string) string {
+	return filepath.Join(filepath.Dir(connectionFilePath), historyFileName)
+}
+
+func NewHistoryStore(connectionFilePath, secretKeyFilePath string) *HistoryStore {
+	return &HistoryStore{
+		historyFilePath:   HistoryFilePath(connectionFilePath),
+		secretKeyFilePath: secretKeyFilePath,
+	}
+}
+
+// Load returns the stored history, or an empty history when none was
+// recorded yet.
+func (s *HistoryStore) Load() (model.HistoryFile, error) {
+	key, err := cryptoutil.LoadKey(s.secretKeyFilePath)
+	if err != nil {
+		return model.HistoryFile{}, err
+	}
+	return s.loadWithKey(key)
+}
+
+// Append records entry under the history lock.
+func (s *HistoryStore) Append(entry model.HistoryEntry) error {
+	unlock, err := acquireFileLock(s.historyFilePath + ".lock")
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	key, err := cryptoutil.LoadKey(s.secretKeyFilePath)
+	if err != nil {
+		return err
+	}
+
+	kdf, err := keyKDF(s.secretKeyFilePath)
+	if err != nil {
+		return err
+	}
+
+	history, err := s.loadWithKey(key)
+	if err != nil {
+		return err
+	}
+	history.Append(entry)
+
+	contentStr, err := toYAMLString(history)
+	if err != nil {
+		return err
+	}
+	return encryptAndStoreFile(contentStr, s.historyFilePath, key, kdf)
+}
+
+func (s *HistoryStore) loadWithKey(key []byte) (model.HistoryFile, error) {
+	content, err := decryptAndReadFile(s.historyFilePath, key)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return model.NewHistoryFile(), nil
+		}
+		return model.HistoryFile{}, fmt.Errorf("failed to read history file: %w", err)
+	}
+	if strings.TrimSpace(content) == "" {
+		return model.NewHistoryFile(), nil
+	}
+
+	var history model.HistoryFile
+	if err := fromYAMLString(content, &history); err != nil {
+		return model.HistoryFile{}, fmt.Errorf("failed to parse history file: %w", err)
+	}
+	if strings.TrimSpace(history.Version) == "" {
+		history.Version = model.CurrentHistoryFileVersion
+	}
+	if history.Entries == nil {
+		history.Entries = []model.HistoryEntry{}
+	}
+	return history, nil
+}
@@ -0,0 +1,62 @@
+package store
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/emirhangumus/sshmanager/internal/model"
+)
+
+func TestHistoryStoreAppendAndLoad(t *testing.T) {
+	tmpDir := t.TempDir()
+	connPath := filepath.Join(tmpDir, "conn")
+	keyPath := filepath.Join(tmpDir, "secret.key")
+
+	historyStore := NewHistoryStore(connPath, keyPath)
+	empty, err := historyStore.Load()
+	if err != nil {
+		t.Fatalf("Load on missing history failed: %v", err)
+	}
+	if len(empty.Entries) != 0 {
+		t.Fatalf("expected empty history, got %d entries", len(empty.Entries))
+	}
+
+	started := time.Date(2026, 3, 4, 5, 6, 7, 0, time.UTC)
+	for i, id := range []string{"one", "two"} {
+		if err := historyStore.Append(model.HistoryEntry{
+			ConnectionID: id,
+			Target:       "u@h",
+			StartedAt:    started.Add(time.Duration(i) * time.Minute),
+			EndedAt:      started.Add(time.Duration(i)*time.Minute + time.Second),
+			ExitCode:     i,
+			Backend:      "openssh",
+		}); err != nil {
+			t.Fatalf("Append failed: %v", err)
+		}
+	}
+
+	loaded, err := historyStore.Load()
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if len(loaded.Entries) != 2 || loaded.Entries[1].ConnectionID != "two" || loaded.Entries[1].ExitCode != 1 {
+		t.Fatalf("unexpected history: %+v", loaded.Entries)
+	}
+	if !loaded.Entries[0].StartedAt.Equal(started) {
+		t.Fatalf("unexpected start time: %v", loaded.Entries[0].StartedAt)
+	}
+
+	raw, err := os.ReadFile(HistoryFilePath(connPath))
+	if err != nil {
+		t.Fatalf("failed to read history file: %v", err)
+	}
+	if strings.Contains(string(raw), "connectionId") {
+		t.Fatal("history file must be encrypted at rest")
+	}
+	if _, err := os.Stat(HistoryFilePath(connPath) + ".lock"); !os.IsNotExist(err) {
+		t.Fatalf("expected history lock to be released, stat err=%v", err)
+	}
+}
@@ -0,0 +1,200 @@
+package store
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strings"
+	"time"
+
+	cryptoutil "github.com/emirhangumus/sshmanager/internal/crypto"
+	"github.com/emirhangumus/sshmanager/internal/model"
+)
+
+const journalFileName = "journal"
+
+var (
+	ErrNothingToUndo = errors.New("nothing to undo")
+	ErrNothingToRedo = errors.New("nothing to redo")
+)
+
+// JournalFilePath returns the change journal location for a key file. The
+// journal is encrypted with that key and lives next to it, so members of a
+// team vault that share one connection file keep separate journals.
+func JournalFilePath(secretKeyFilePath string) string {
+	return filepath.Join(filepath.Dir(secretKeyFilePath), journalFileName)
+}
+
+// WithJournalCommand names the command recorded with the changes the store
+// makes (e.g. "edit"). Only the command name is recorded, never its
+// arguments, which may hold passwords.
+func WithJournalCommand(command string) Option {
+	return func(s *ConnectionStore) {
+		s.journalCommand = command
+	}
+}
+
+// journalActor identifies who made a change as user@host.
+func journalActor() string {
+	name := "unknown"
+	if current, err := user.Current(); err == nil && current.Username != "" {
+		name = current.Username
+	}
+	if host, err := os.Hostname(); err == nil && host != "" {
+		return name + "@" + host
+	}
+	return name
+}
+
+// Journal returns the change journal, or an empty journal when nothing was
+// recorded yet.
+func (s *ConnectionStore) Journal() (model.JournalFile, error) {
+	key, err := cryptoutil.LoadKey(s.secretKeyFilePath)
+	if err != nil {
+		return model.JournalFile{}, err
+	}
+	return s.loadJournal(key)
+}
+
+// recordJournal appends the difference between before and after to the
+// journal. The mutation lock must be held. after must be the persisted
+// form of the file so the snapshots compare equal to what Load returns.
+func (s *ConnectionStore) recordJournal(before, after model.ConnectionFile) error {
+	changes := model.DiffConnectionFiles(before, after)
+	if len(changes) == 0 {
+		return nil
+	}
+
+	key, err := cryptoutil.LoadKey(s.secretKeyFilePath)
+	if err != nil {
+		return err
+	}
+	journal, err := s.loadJournal(key)
+	if err != nil {
+		return err
+	}
+	journal.Record(model.JournalEntry{
+		Time:    time.Now().UTC(),
+		Actor:   journalActor(),
+		Command: s.journalCommand,
+		Changes: changes,
+	})
+	return s.saveJournal(key, journal)
+}
+
+// Undo reverts the most recent applied journal entry. The undone entry can
+// be redone until the next change.
+func (s *ConnectionStore) Undo() (model.JournalEntry, error) {
+	return s.moveJournal(true)
+}
+
+// Redo re-applies the most recently undone journal entry.
+func (s *ConnectionStore) Redo() (model.JournalEntry, error) {
+	return s.moveJournal(false)
+}
+
+func (s *ConnectionStore) moveJournal(undo bool) (model.JournalEntry, error) {
+	entry, err := s.applyJournalEntry(undo)
+	if err != nil {
+		return model.JournalEntry{}, err
+	}
+	s.notifySaved()
+	return entry, nil
+}
+
+func (s *ConnectionStore) applyJournalEntry(undo bool) (model.JournalEntry, error) {
+	unlock, err := s.acquireMutationLock()
+	if err != nil {
+		return model.JournalEntry{}, err
+	}
+	defer unlock()
+
+	key, err := cryptoutil.LoadKey(s.secretKeyFilePath)
+	if err != nil {
+		return model.JournalEntry{}, err
+	}
+	journal, err := s.loadJournal(key)
+	if err != nil {
+		return model.JournalEntry{}, err
+	}
+
+	var entry model.JournalEntry
+	if undo {
+		if journal.Position == 0 {
+			return model.JournalEntry{}, ErrNothingToUndo
+		}
+		entry = journal.Entries[journal.Position-1]
+	} else {
+		if journal.Position >= len(journal.Entries) {
+			return model.JournalEntry{}, ErrNothingToRedo
+		}
+		entry = journal.Entries[journal.Position]
+	}
+
+	connFile, err := s.loadWithoutLock()
+	if err != nil {
+		return model.JournalEntry{}, err
+	}
+	if err := model.ApplyJournalChanges(&connFile, entry.Changes, undo); err != nil {
+		verb := "redo"
+		if undo {
+			verb = "undo"
+		}
+		return model.JournalEntry{}, fmt.Errorf("cannot %s revision %d: %w (restore single connections with 'undo <alias>')", verb, entry.Revision, err)
+	}
+	if _, err := s.persistWithoutLock(connFile); err != nil {
+		return model.JournalEntry{}, err
+	}
+
+	if undo {
+		journal.Position--
+	} else {
+		journal.Position++
+	}
+	return entry, s.saveJournal(key, journal)
+}
+
+func (s *ConnectionStore) loadJournal(key []byte) (model.JournalFile, error) {
+	content, err := decryptAndReadFile(JournalFilePath(s.secretKeyFilePath), key)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return model.NewJournalFile(), nil
+		}
+		return model.JournalFile{}, fmt.Errorf("failed to read change journal: %w", err)
+	}
+	if strings.TrimSpace(content) == "" {
+		return model.NewJournalFile(), nil
+	}
+
+	var journal model.JournalFile
+	if err := fromYAMLString(content, &journal); err != nil {
+		return model.JournalFile{}, fmt.Errorf("failed to parse change journal: %w", err)
+	}
+	if strings.TrimSpace(journal.Version) == "" {
+		journal.Version = model.CurrentJournalFileVersion
+	}
+	if journal.Entries == nil {
+		journal.Entries = []model.JournalEntry{}
+	}
+	if journal.Position < 0 || journal.Position > len(journal.Entries) {
+		journal.Position = len(journal.Entries)
+	}
+	return journal, nil
+}
+
+func (s *ConnectionStore) saveJournal(key []byte, journal model.JournalFile) error {
+	kdf, err := keyKDF(s.secretKeyFilePath)
+	if err != nil {
+		return err
+	}
+	contentStr, err := toYAMLString(journal)
+	if err != nil {
+		return err
+	}
+	if err := encryptAndStoreFile(contentStr, JournalFilePath(s.secretKeyFilePath), key, kdf); err != nil {
+		return fmt.Errorf("failed to write change journal: %w", err)
+	}
+	return nil
+}
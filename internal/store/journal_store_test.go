@@ -0,0 +1,93 @@
+package store
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/emirhangumus/sshmanager/internal/model"
+	"github.com/emirhangumus/sshmanager/internal/storage"
+)
+
+func newJournalTestStore(t *testing.T, options ...Option) *ConnectionStore {
+	t.Helper()
+	tmpDir := t.TempDir()
+	connPath := filepath.Join(tmpDir, "conn")
+	if err := storage.CreateFileIfNotExists(connPath, 0o600); err != nil {
+		t.Fatalf("CreateFileIfNotExists(conn) failed: %v", err)
+	}
+	connStore := NewConnectionStore(connPath, filepath.Join(tmpDir, "secret.key"), options...)
+	if err := connStore.InitializeIfEmpty(); err != nil {
+		t.Fatalf("InitializeIfEmpty failed: %v", err)
+	}
+	return connStore
+}
+
+func TestUpdateRecordsEncryptedJournalForUndoAndRedo(t *testing.T) {
+	connStore := newJournalTestStore(t, WithJournalCommand("add"))
+
+	if err := connStore.Update(func(connFile *model.ConnectionFile) error {
+		return connFile.AddConnection(model.SSHConnection{Username: "ops", Host: "web.internal", ProxyJump: "bastion", Alias: "web"})
+	}); err != nil {
+		t.Fatalf("Update(add) failed: %v", err)
+	}
+	if err := connStore.Update(func(connFile *model.ConnectionFile) error {
+		connFile.GetConnectionByAlias("web").ProxyJump = ""
+		return nil
+	}); err != nil {
+		t.Fatalf("Update(edit) failed: %v", err)
+	}
+	// A mutation that changes nothing is not a revision.
+	if err := connStore.Update(func(*model.ConnectionFile) error { return nil }); err != nil {
+		t.Fatalf("Update(noop) failed: %v", err)
+	}
+
+	raw, err := os.ReadFile(JournalFilePath(connStore.secretKeyFilePath))
+	if err != nil {
+		t.Fatalf("ReadFile(journal) failed: %v", err)
+	}
+	if bytes.Contains(raw, []byte("bastion")) {
+		t.Fatal("journal must be encrypted")
+	}
+
+	journal, err := connStore.Journal()
+	if err != nil {
+		t.Fatalf("Journal failed: %v", err)
+	}
+	if len(journal.Entries) != 2 || journal.Entries[0].Command != "add" || journal.Entries[0].Actor == "" {
+		t.Fatalf("unexpected journal: %+v", journal.Entries)
+	}
+
+	entry, err := connStore.Undo()
+	if err != nil || entry.Revision != 2 {
+		t.Fatalf("Undo = %+v, %v", entry, err)
+	}
+	loaded, _ := connStore.Load()
+	if loaded.GetConnectionByAlias("web").ProxyJump != "bastion" {
+		t.Fatal("expected undo to restore the proxy jump")
+	}
+	if _, err := connStore.Redo(); err != nil {
+		t.Fatalf("Redo failed: %v", err)
+	}
+	if _, err := connStore.Redo(); !errors.Is(err, ErrNothingToRedo) {
+		t.Fatalf("expected ErrNothingToRedo, got %v", err)
+	}
+	loaded, _ = connStore.Load()
+	if loaded.GetConnectionByAlias("web").ProxyJump != "" {
+		t.Fatal("expected redo to clear the proxy jump again")
+	}
+
+	for i := 0; i < 2; i++ {
+		if _, err := connStore.Undo(); err != nil {
+			t.Fatalf("Undo %d failed: %v", i, err)
+		}
+	}
+	if _, err := connStore.Undo(); !errors.Is(err, ErrNothingToUndo) {
+		t.Fatalf("expected ErrNothingToUndo, got %v", err)
+	}
+	if loaded, _ := connStore.Load(); len(loaded.Connections) != 0 {
+		t.Fatalf("expected the added connection to be undone, got %+v", loaded.Connections)
+	}
+}
@@ -0,0 +1,193 @@
+package store
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	cryptoutil "github.com/emirhangumus/sshmanager/internal/crypto"
+	"github.com/emirhangumus/sshmanager/internal/storage"
+)
+
+const (
+	previousKeySuffix = ".old"
+	pendingKeySuffix  = ".new"
+)
+
+// PreviousKeyFilePath is where Rekey keeps the replaced key until the new
+// one has been verified. It only outlives Rekey if the process is killed
+// mid-rotation.
+func PreviousKeyFilePath(secretKeyFilePath string) string {
+	return secretKeyFilePath + previousKeySuffix
+}
+
+// PendingKeyFilePath is where Rekey stages the new key before it replaces
+// the key file.
+func PendingKeyFilePath(secretKeyFilePath string) string {
+	return secretKeyFilePath + pendingKeySuffix
+}
+
+type rekeyTarget struct {
+	path     string
+	previous []byte
+	next     []byte
+}
+
+// Rekey re-encrypts the connection file, its backup generations, its
+// history and its change journal with next and replaces the key file. It
+// holds the mutation lock (and the history lock) for the whole rotation so
+// no concurrent write is encrypted with the old key after it was read. The
+// old key file is kept next to the new one until a test decrypt of the
+// written files with the written key succeeds; any failure before that
+// restores the previous files.
+func (s *ConnectionStore) Rekey(next cryptoutil.KeyFile) error {
+	unlock, err := s.acquireMutationLock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	if team, err := s.IsTeamVault(); err != nil {
+		return err
+	} else if team {
+		return errors.New("team vaults have no shared key to rotate; removing a member rotates the data key")
+	}
+
+	historyPath := HistoryFilePath(s.connectionFilePath)
+	unlockHistory, err := acquireFileLock(historyPath + ".lock")
+	if err != nil {
+		return err
+	}
+	defer unlockHistory()
+
+	previousKeyData, err := os.ReadFile(s.secretKeyFilePath)
+	if err != nil {
+		return fmt.Errorf("failed to read key file: %w", err)
+	}
+	previousKey, err := cryptoutil.LoadKey(s.secretKeyFilePath)
+	if err != nil {
+		return err
+	}
+
+	paths := []string{s.connectionFilePath, historyPath, JournalFilePath(s.secretKeyFilePath)}
+	for _, path := range BackupFilePaths(s.connectionFilePath) {
+		// Generations that are already unreadable stay as they are.
+		if data, err := os.ReadFile(path); err == nil && len(data) > 0 {
+			if _, _, err := cryptoutil.OpenEnvelope(data, previousKey); err == nil {
+				paths = append(paths, path)
+			}
+		}
+	}
+	targets, err := prepareRekeyTargets(previousKey, next, paths...)
+	if err != nil {
+		return err
+	}
+
+	previousKeyPath := PreviousKeyFilePath(s.secretKeyFilePath)
+	pendingKeyPath := PendingKeyFilePath(s.secretKeyFilePath)
+	if err := storage.WriteFileAtomic(previousKeyPath, previousKeyData, 0o600); err != nil {
+		return fmt.Errorf("failed to keep previous key: %w", err)
+	}
+	if err := storage.WriteFileAtomic(pendingKeyPath, next.Data, 0o600); err != nil {
+		_ = storage.SecureDelete(previousKeyPath)
+		return fmt.Errorf("failed to stage new key: %w", err)
+	}
+
+	written := 0
+	rollback := func(cause error) error {
+		for _, target := range targets[:written] {
+			if err := storage.WriteFileAtomic(target.path, target.previous, 0o600); err != nil {
+				return fmt.Errorf("%w (rollback of %s failed: %v; previous key kept at %s)", cause, target.path, err, previousKeyPath)
+			}
+		}
+		if err := storage.WriteFileAtomic(s.secretKeyFilePath, previousKeyData, 0o600); err != nil {
+			return fmt.Errorf("%w (restoring key file failed: %v; previous key kept at %s)", cause, err, previousKeyPath)
+		}
+		_ = os.Remove(pendingKeyPath)
+		_ = storage.SecureDelete(previousKeyPath)
+		return cause
+	}
+
+	for _, target := range targets {
+		if err := storage.WriteFileAtomic(target.path, target.next, 0o600); err != nil {
+			return rollback(fmt.Errorf("failed to write re-encrypted %s: %w", target.path, err))
+		}
+		written++
+	}
+	if err := os.Rename(pendingKeyPath, s.secretKeyFilePath); err != nil {
+		return rollback(fmt.Errorf("failed to replace key file: %w", err))
+	}
+
+	if err := verifyRekey(next, s.secretKeyFilePath, targets); err != nil {
+		return rollback(err)
+	}
+
+	cryptoutil.RememberKeyFile(s.secretKeyFilePath, next)
+
+	if err := storage.SecureDelete(previousKeyPath); err != nil {
+		return fmt.Errorf("rekey succeeded but the previous key could not be removed from %s: %w", previousKeyPath, err)
+	}
+	return nil
+}
+
+// prepareRekeyTargets decrypts every existing file with previousKey,
+// re-encrypts it with next and test decrypts the result with the key
+// derived from next's key file contents. Missing and empty files are
+// skipped.
+func prepareRekeyTargets(previousKey []byte, next cryptoutil.KeyFile, paths ...string) ([]rekeyTarget, error) {
+	verifyKey, err := next.Derive(next.Data)
+	if err != nil {
+		return nil, fmt.Errorf("new key failed verification: %w", err)
+	}
+
+	targets := make([]rekeyTarget, 0, len(paths))
+	for _, path := range paths {
+		encrypted, err := os.ReadFile(path)
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				continue
+			}
+			return nil, fmt.Errorf("failed to read %s: %w", path, err)
+		}
+		if len(encrypted) == 0 {
+			continue
+		}
+
+		plain, _, err := cryptoutil.OpenEnvelope(encrypted, previousKey)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decrypt %s with the current key: %w", path, err)
+		}
+		reencrypted, err := cryptoutil.SealEnvelope(plain, next.Key, next.KDFName())
+		if err != nil {
+			return nil, fmt.Errorf("failed to re-encrypt %s: %w", path, err)
+		}
+		if check, _, err := cryptoutil.OpenEnvelope(reencrypted, verifyKey); err != nil || check != plain {
+			return nil, fmt.Errorf("test decrypt of re-encrypted %s failed", path)
+		}
+		targets = append(targets, rekeyTarget{path: path, previous: encrypted, next: reencrypted})
+	}
+	return targets, nil
+}
+
+// verifyRekey reloads the written key file and checks that every written
+// file decrypts with it.
+func verifyRekey(next cryptoutil.KeyFile, secretKeyFilePath string, targets []rekeyTarget) error {
+	keyData, err := os.ReadFile(secretKeyFilePath)
+	if err != nil {
+		return fmt.Errorf("failed to read new key file: %w", err)
+	}
+	key, err := next.Derive(keyData)
+	if err != nil {
+		return fmt.Errorf("new key file failed verification: %w", err)
+	}
+	for _, target := range targets {
+		encrypted, err := os.ReadFile(target.path)
+		if err != nil {
+			return fmt.Errorf("failed to read re-encrypted %s: %w", target.path, err)
+		}
+		if _, _, err := cryptoutil.OpenEnvelope(encrypted, key); err != nil {
+			return fmt.Errorf("test decrypt of %s with the new key failed: %w", target.path, err)
+		}
+	}
+	return nil
+}
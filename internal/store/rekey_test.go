@@ -0,0 +1,162 @@
+package store
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	cryptoutil "github.com/emirhangumus/sshmanager/internal/crypto"
+	"github.com/emirhangumus/sshmanager/internal/model"
+	"github.com/emirhangumus/sshmanager/internal/storage"
+)
+
+func newRekeyFixture(t *testing.T) (*ConnectionStore, string, string) {
+	t.Helper()
+	tmpDir := t.TempDir()
+	connPath := filepath.Join(tmpDir, "conn")
+	keyPath := filepath.Join(tmpDir, "secret.key")
+	t.Setenv(cryptoutil.PassphraseEnvVar, "")
+
+	if err := storage.CreateFileIfNotExists(connPath, 0o600); err != nil {
+		t.Fatalf("CreateFileIfNotExists(conn) failed: %v", err)
+	}
+	connStore := NewConnectionStore(connPath, keyPath)
+	if err := connStore.Update(func(connFile *model.ConnectionFile) error {
+		return connFile.AddConnection(model.SSHConnection{Username: "u", Host: "h", Password: "p", Alias: "web"})
+	}); err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+	if err := NewHistoryStore(connPath, keyPath).Append(model.HistoryEntry{ConnectionID: "web", StartedAt: time.Now()}); err != nil {
+		t.Fatalf("Append failed: %v", err)
+	}
+	return connStore, connPath, keyPath
+}
+
+func TestRekeyRawToPassphraseAndBack(t *testing.T) {
+	connStore, connPath, keyPath := newRekeyFixture(t)
+
+	next, err := cryptoutil.NewPassphraseKeyFile("new passphrase", cryptoutil.DefaultArgon2idParams())
+	if err != nil {
+		t.Fatalf("NewPassphraseKeyFile failed: %v", err)
+	}
+	if err := connStore.Rekey(next); err != nil {
+		t.Fatalf("Rekey(passphrase) failed: %v", err)
+	}
+
+	if _, err := connStore.Load(); err != nil {
+		t.Fatalf("Load right after rekey should reuse the new passphrase: %v", err)
+	}
+	cryptoutil.ForgetPassphrases()
+	if _, err := connStore.Load(); !errors.Is(err, cryptoutil.ErrPassphraseRequired) {
+		t.Fatalf("expected ErrPassphraseRequired, got %v", err)
+	}
+	t.Setenv(cryptoutil.PassphraseEnvVar, "new passphrase")
+	loaded, err := connStore.Load()
+	if err != nil {
+		t.Fatalf("Load after rekey failed: %v", err)
+	}
+	if loaded.GetConnectionByAlias("web") == nil {
+		t.Fatal("connection lost during rekey")
+	}
+	history, err := NewHistoryStore(connPath, keyPath).Load()
+	if err != nil || len(history.Entries) != 1 {
+		t.Fatalf("history not re-encrypted: %v, %+v", err, history)
+	}
+	for _, leftover := range []string{PreviousKeyFilePath(keyPath), PendingKeyFilePath(keyPath)} {
+		if _, err := os.Stat(leftover); !os.IsNotExist(err) {
+			t.Fatalf("expected %s to be removed, stat err=%v", leftover, err)
+		}
+	}
+
+	raw, err := cryptoutil.NewRawKeyFile()
+	if err != nil {
+		t.Fatalf("NewRawKeyFile failed: %v", err)
+	}
+	if err := connStore.Rekey(raw); err != nil {
+		t.Fatalf("Rekey(raw) failed: %v", err)
+	}
+	t.Setenv(cryptoutil.PassphraseEnvVar, "")
+	if _, err := connStore.Load(); err != nil {
+		t.Fatalf("Load after raw rekey failed: %v", err)
+	}
+}
+
+func TestRekeyFailsWithoutTouchingFilesWhenCurrentKeyIsWrong(t *testing.T) {
+	connStore, connPath, keyPath := newRekeyFixture(t)
+	before, err := os.ReadFile(connPath)
+	if err != nil {
+		t.Fatalf("ReadFile failed: %v", err)
+	}
+	if err := os.WriteFile(keyPath, make([]byte, 32), 0o600); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+
+	next, err := cryptoutil.NewRawKeyFile()
+	if err != nil {
+		t.Fatalf("NewRawKeyFile failed: %v", err)
+	}
+	if err := connStore.Rekey(next); err == nil {
+		t.Fatal("expected Rekey to fail with the wrong current key")
+	}
+	after, err := os.ReadFile(connPath)
+	if err != nil {
+		t.Fatalf("ReadFile failed: %v", err)
+	}
+	if string(before) != string(after) {
+		t.Fatal("connection file changed after failed rekey")
+	}
+	if _, err := os.Stat(PreviousKeyFilePath(keyPath)); !os.IsNotExist(err) {
+		t.Fatalf("expected no previous key file, stat err=%v", err)
+	}
+}
+
+func TestRekeyDoesNotLoseConcurrentUpdates(t *testing.T) {
+	connStore, _, _ := newRekeyFixture(t)
+
+	const workers = 8
+	var wg sync.WaitGroup
+	errs := make(chan error, workers+1)
+	for i := 0; i < workers; i++ {
+		i := i
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs <- connStore.Update(func(connFile *model.ConnectionFile) error {
+				time.Sleep(2 * time.Millisecond)
+				return connFile.AddConnection(model.SSHConnection{
+					Username: "u",
+					Host:     fmt.Sprintf("host-%d.example", i),
+					Password: "p",
+				})
+			})
+		}()
+	}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		next, err := cryptoutil.NewRawKeyFile()
+		if err == nil {
+			err = connStore.Rekey(next)
+		}
+		errs <- err
+	}()
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Fatalf("concurrent operation failed: %v", err)
+		}
+	}
+
+	loaded, err := connStore.Load()
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if len(loaded.Connections) != workers+1 {
+		t.Fatalf("expected %d connections, got %d", workers+1, len(loaded.Connections))
+	}
+}
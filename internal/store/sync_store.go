@@ -0,0 +1,146 @@
+package store
+
+import (
+	"errors"
+	"fmt"
+
+	cryptoutil "github.com/emirhangumus/sshmanager/internal/crypto"
+	"github.com/emirhangumus/sshmanager/internal/gitsync"
+	"github.com/emirhangumus/sshmanager/internal/model"
+	"github.com/emirhangumus/sshmanager/internal/storage"
+)
+
+// WithSaveHook replaces the function that runs after every successful Save
+// or Update, once the mutation lock is released. By default each change is
+// committed to the vault's sync repository, if it has one. A nil hook
+// turns it off.
+func WithSaveHook(hook func(connectionFilePath string)) Option {
+	return func(s *ConnectionStore) {
+		s.saveHook = hook
+	}
+}
+
+func (s *ConnectionStore) notifySaved() {
+	if s.saveHook != nil {
+		s.saveHook(s.connectionFilePath)
+	}
+}
+
+// commitToSyncRepo is the default save hook. A failed commit does not fail
+// the change; the next sync commits it.
+func commitToSyncRepo(connectionFilePath string) {
+	if err := gitsync.CommitConnectionFile(connectionFilePath); err != nil {
+		_, _ = fmt.Fprintf(warningOutput, "warning: failed to commit to the sync repository: %v\n", err)
+	}
+}
+
+// DecryptConnectionData decrypts another copy of this store's connection
+// file, such as a version from the sync repository, with the vault's key or
+// member identity. Empty data is an empty connection file.
+func (s *ConnectionStore) DecryptConnectionData(data []byte) (model.ConnectionFile, error) {
+	if len(data) == 0 {
+		return model.NewConnectionFile(), nil
+	}
+
+	content, err := s.decryptConnectionData(data)
+	if err != nil {
+		return model.ConnectionFile{}, err
+	}
+	connFile, err := parseConnectionFile(content)
+	if err != nil {
+		return model.ConnectionFile{}, err
+	}
+	connFile.EnsureIDs()
+	return connFile, nil
+}
+
+func (s *ConnectionStore) decryptConnectionData(data []byte) (string, error) {
+	id, team, err := s.teamIdentity()
+	if err != nil {
+		return "", err
+	}
+	if team {
+		content, _, _, err := cryptoutil.OpenTeamEnvelope(data, id)
+		return content, err
+	}
+
+	key, err := cryptoutil.LoadKey(s.secretKeyFilePath)
+	if err != nil {
+		return "", err
+	}
+	content, _, err := cryptoutil.OpenEnvelope(data, key)
+	return content, err
+}
+
+// ReplaceEncrypted replaces the connection file with data, another encrypted
+// copy of it, after checking that data decrypts with this vault's key. The
+// change is journaled like an Update, but the save hook does not run.
+func (s *ConnectionStore) ReplaceEncrypted(data []byte) error {
+	if len(data) == 0 {
+		return errors.New("refusing to replace the connection file with an empty file")
+	}
+
+	unlock, err := s.acquireMutationLock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	content, err := s.decryptConnectionData(data)
+	if err != nil {
+		return err
+	}
+	replacement, err := parseConnectionFile(content)
+	if err != nil {
+		return err
+	}
+	before, err := s.loadWithoutLock()
+	if err != nil {
+		return err
+	}
+	if err := s.rotateBackupsWithoutLock(content); err != nil {
+		return err
+	}
+	if err := storage.WriteFileAtomic(s.connectionFilePath, data, 0o600); err != nil {
+		return fmt.Errorf("failed to write encrypted file: %w", err)
+	}
+	if err := s.recordJournal(before, replacement); err != nil {
+		return fmt.Errorf("connections replaced, but the change could not be recorded for undo: %w", err)
+	}
+	return nil
+}
+
+// SaveMerged saves merged, the result of a sync merge of the local
+// connection file with remote, an encrypted copy from elsewhere, against
+// base, their encrypted common ancestor (empty when there is none). In a
+// team vault the member lists are merged first, see mergeTeamMembers. The
+// change is journaled like an Update and the save hook runs.
+func (s *ConnectionStore) SaveMerged(merged model.ConnectionFile, base, remote []byte) error {
+	if err := s.saveMerged(merged, base, remote); err != nil {
+		return err
+	}
+	s.notifySaved()
+	return nil
+}
+
+func (s *ConnectionStore) saveMerged(merged model.ConnectionFile, base, remote []byte) error {
+	unlock, err := s.acquireMutationLock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	id, team, err := s.teamIdentity()
+	if err != nil {
+		return err
+	}
+	if team {
+		if err := s.mergeTeamEnvelopeWithoutLock(id, base, remote); err != nil {
+			return err
+		}
+	}
+	return s.updateWithoutLock(func(connFile *model.ConnectionFile) error {
+		*connFile = merged
+		return nil
+	})
+}
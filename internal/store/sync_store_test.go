@@ -0,0 +1,33 @@
+package store
+
+import (
+	"testing"
+
+	"github.com/emirhangumus/sshmanager/internal/model"
+)
+
+func TestSaveHookRunsAfterEveryChange(t *testing.T) {
+	var saved []string
+	connStore := newJournalTestStore(t, WithSaveHook(func(path string) {
+		saved = append(saved, path)
+	}))
+	// InitializeIfEmpty saved the empty file.
+	saved = nil
+
+	addBackupTestConnection(t, connStore, "web")
+	if _, err := connStore.Undo(); err != nil {
+		t.Fatalf("Undo failed: %v", err)
+	}
+	if err := connStore.Save(model.NewConnectionFile()); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	if len(saved) != 3 || saved[0] != connStore.connectionFilePath {
+		t.Fatalf("expected the hook to run for Update, Undo and Save, got %v", saved)
+	}
+
+	quiet := NewConnectionStore(connStore.connectionFilePath, connStore.secretKeyFilePath, WithSaveHook(nil))
+	addBackupTestConnection(t, quiet, "db")
+	if len(saved) != 3 {
+		t.Fatalf("expected a nil hook to turn it off, got %v", saved)
+	}
+}
@@ -0,0 +1,385 @@
+package store
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	cryptoutil "github.com/emirhangumus/sshmanager/internal/crypto"
+	"github.com/emirhangumus/sshmanager/internal/model"
+	"github.com/emirhangumus/sshmanager/internal/storage"
+)
+
+// A team vault keeps a member identity in secret.key instead of a shared
+// key. The connection file is a team envelope whose data key is wrapped for
+// every member, so the file itself can be shared (e.g. through git) while
+// each member keeps their own identity. Load and Update work the same for
+// both kinds of vault; the functions here only add member management.
+
+// ErrTeamFileMissing means a team vault has an identity but no shared
+// connection file yet.
+var ErrTeamFileMissing = errors.New("team vault has no shared connection file yet; copy it from your team (after a member ran 'sshmanager vault member add') or run 'sshmanager vault create --team' to start a new team")
+
+// teamIdentity returns the member identity when the store is a team vault.
+func (s *ConnectionStore) teamIdentity() (cryptoutil.Identity, bool, error) {
+	data, err := os.ReadFile(s.secretKeyFilePath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return cryptoutil.Identity{}, false, nil
+		}
+		return cryptoutil.Identity{}, false, fmt.Errorf("failed to read key file: %w", err)
+	}
+	if !cryptoutil.IsIdentityFile(data) {
+		return cryptoutil.Identity{}, false, nil
+	}
+	id, err := cryptoutil.ParseIdentity(data)
+	if err != nil {
+		return cryptoutil.Identity{}, false, err
+	}
+	return id, true, nil
+}
+
+// IsTeamVault reports whether the store's key file holds a member identity.
+func (s *ConnectionStore) IsTeamVault() (bool, error) {
+	_, team, err := s.teamIdentity()
+	return team, err
+}
+
+// openTeamFile decrypts the shared connection file with id.
+func (s *ConnectionStore) openTeamFile(id cryptoutil.Identity) (string, []cryptoutil.TeamMember, []byte, error) {
+	data, err := os.ReadFile(s.connectionFilePath)
+	if err != nil && !os.IsNotExist(err) {
+		return "", nil, nil, err
+	}
+	if len(data) == 0 {
+		return "", nil, nil, ErrTeamFileMissing
+	}
+	content, members, dataKey, err := cryptoutil.OpenTeamEnvelope(data, id)
+	if errors.Is(err, cryptoutil.ErrNotTeamMember) {
+		return "", nil, nil, fmt.Errorf("%w (your recipient is %s)", err, id.Recipient())
+	}
+	return content, members, dataKey, err
+}
+
+// writeTeamFile seals content for members and replaces the connection file
+// in one atomic rename, after checking that id can still open the result.
+func (s *ConnectionStore) writeTeamFile(id cryptoutil.Identity, content string, dataKey []byte, members []cryptoutil.TeamMember) error {
+	sealed, err := cryptoutil.SealTeamEnvelope(content, dataKey, members)
+	if err != nil {
+		return err
+	}
+	check, _, _, err := cryptoutil.OpenTeamEnvelope(sealed, id)
+	if err != nil {
+		return fmt.Errorf("test decrypt of the re-wrapped team vault failed: %w", err)
+	}
+	if check != content {
+		return errors.New("test decrypt of the re-wrapped team vault returned different data")
+	}
+	if err := storage.WriteFileAtomic(s.connectionFilePath, sealed, 0o600); err != nil {
+		return fmt.Errorf("failed to write encrypted file: %w", err)
+	}
+	return nil
+}
+
+// saveTeamWithoutLock re-encrypts content with the existing data key and
+// member list.
+func (s *ConnectionStore) saveTeamWithoutLock(id cryptoutil.Identity, content string) error {
+	_, members, dataKey, err := s.openTeamFile(id)
+	if err != nil {
+		return err
+	}
+	return s.writeTeamFile(id, content, dataKey, members)
+}
+
+// mergeTeamEnvelopeWithoutLock re-seals the local connection file for the
+// members merged from it and remote against base. A fresh data key is used
+// when a member was dropped or the two sides use different data keys, so a
+// 'vault member remove' on either side is never undone by a sync.
+func (s *ConnectionStore) mergeTeamEnvelopeWithoutLock(id cryptoutil.Identity, base, remote []byte) error {
+	content, ours, ourKey, err := s.openTeamFile(id)
+	if err != nil {
+		return err
+	}
+	_, theirs, theirKey, err := cryptoutil.OpenTeamEnvelope(remote, id)
+	if err != nil {
+		return err
+	}
+	if bytes.Equal(ourKey, theirKey) && sameTeamMembers(ours, theirs) {
+		return nil
+	}
+
+	var baseMembers []cryptoutil.TeamMember
+	if len(base) > 0 {
+		_, baseMembers, _, err = cryptoutil.OpenTeamEnvelope(base, id)
+	}
+	if len(base) == 0 || err != nil {
+		return errors.New("the team members differ from the remote and there is no common version to merge them against; make them match with 'sshmanager vault member' and sync again")
+	}
+
+	members, removed, err := mergeTeamMembers(baseMembers, ours, theirs)
+	if err != nil {
+		return err
+	}
+	dataKey := ourKey
+	if removed || !bytes.Equal(ourKey, theirKey) {
+		if dataKey, err = cryptoutil.NewTeamDataKey(); err != nil {
+			return err
+		}
+	}
+	wrapped := make([]cryptoutil.TeamMember, 0, len(members))
+	for _, member := range members {
+		member, err := cryptoutil.WrapTeamKey(dataKey, member.Name, member.Recipient)
+		if err != nil {
+			return err
+		}
+		wrapped = append(wrapped, member)
+	}
+	return s.writeTeamFile(id, content, dataKey, wrapped)
+}
+
+// mergeTeamMembers merges two member lists against their common ancestor
+// the way connections are merged: members added on either side are kept and
+// members removed on either side are dropped. removed reports whether a
+// member of base was dropped.
+func mergeTeamMembers(base, ours, theirs []cryptoutil.TeamMember) ([]cryptoutil.TeamMember, bool, error) {
+	inBase, inOurs, inTheirs := teamRecipients(base), teamRecipients(ours), teamRecipients(theirs)
+
+	var merged []cryptoutil.TeamMember
+	names := make(map[string]string)
+	keep := func(member cryptoutil.TeamMember) error {
+		if recipient, ok := names[member.Name]; ok && recipient != member.Recipient {
+			return fmt.Errorf("team member %q was added with a different recipient here and on the remote; remove one of them and sync again", member.Name)
+		}
+		names[member.Name] = member.Recipient
+		merged = append(merged, cryptoutil.TeamMember{Name: member.Name, Recipient: member.Recipient})
+		return nil
+	}
+	for _, member := range ours {
+		if inTheirs[member.Recipient] || !inBase[member.Recipient] {
+			if err := keep(member); err != nil {
+				return nil, false, err
+			}
+		}
+	}
+	for _, member := range theirs {
+		if !inOurs[member.Recipient] && !inBase[member.Recipient] {
+			if err := keep(member); err != nil {
+				return nil, false, err
+			}
+		}
+	}
+
+	inMerged := teamRecipients(merged)
+	for recipient := range inBase {
+		if !inMerged[recipient] {
+			return merged, true, nil
+		}
+	}
+	return merged, false, nil
+}
+
+func teamRecipients(members []cryptoutil.TeamMember) map[string]bool {
+	recipients := make(map[string]bool, len(members))
+	for _, member := range members {
+		recipients[member.Recipient] = true
+	}
+	return recipients
+}
+
+// sameTeamMembers reports whether a and b list the same names and
+// recipients, in any order.
+func sameTeamMembers(a, b []cryptoutil.TeamMember) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	names := make(map[string]string, len(a))
+	for _, member := range a {
+		names[member.Recipient] = member.Name
+	}
+	for _, member := range b {
+		if name, ok := names[member.Recipient]; !ok || name != member.Name {
+			return false
+		}
+	}
+	return true
+}
+
+// InitTeam starts a new team vault with the identity in the key file as its
+// only member.
+func (s *ConnectionStore) InitTeam(memberName string) error {
+	if err := s.initTeam(memberName); err != nil {
+		return err
+	}
+	s.notifySaved()
+	return nil
+}
+
+func (s *ConnectionStore) initTeam(memberName string) error {
+	unlock, err := s.acquireMutationLock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	id, team, err := s.teamIdentity()
+	if err != nil {
+		return err
+	}
+	if !team {
+		return errors.New("the key file does not hold a member identity")
+	}
+	if isEmpty, err := storage.IsFileEmpty(s.connectionFilePath); err != nil {
+		return err
+	} else if !isEmpty {
+		return errors.New("the vault already has a connection file")
+	}
+
+	member, err := newTeamMember(nil, memberName, id.Recipient())
+	if err != nil {
+		return err
+	}
+	dataKey, err := cryptoutil.NewTeamDataKey()
+	if err != nil {
+		return err
+	}
+	member, err = cryptoutil.WrapTeamKey(dataKey, member.Name, member.Recipient)
+	if err != nil {
+		return err
+	}
+	content, err := toYAMLString(model.NewConnectionFile())
+	if err != nil {
+		return err
+	}
+	return s.writeTeamFile(id, content, dataKey, []cryptoutil.TeamMember{member})
+}
+
+// TeamMembers lists the members of a team vault. The list is authenticated
+// by decrypting the file with this member's identity.
+func (s *ConnectionStore) TeamMembers() ([]cryptoutil.TeamMember, string, error) {
+	id, err := s.requireTeamIdentity()
+	if err != nil {
+		return nil, "", err
+	}
+	_, members, _, err := s.openTeamFile(id)
+	if err != nil {
+		return nil, "", err
+	}
+	return members, id.Recipient(), nil
+}
+
+// AddTeamMember wraps the current data key for recipient.
+func (s *ConnectionStore) AddTeamMember(name, recipient string) error {
+	return s.updateTeamMembers(func(id cryptoutil.Identity, members []cryptoutil.TeamMember, dataKey []byte) ([]cryptoutil.TeamMember, []byte, error) {
+		member, err := newTeamMember(members, name, recipient)
+		if err != nil {
+			return nil, nil, err
+		}
+		member, err = cryptoutil.WrapTeamKey(dataKey, member.Name, member.Recipient)
+		if err != nil {
+			return nil, nil, err
+		}
+		return append(members, member), dataKey, nil
+	})
+}
+
+// RemoveTeamMember drops a member and rotates the data key, re-wrapping it
+// for everyone else. The connection file is replaced in a single atomic
+// rename, so readers see either the old or the new member set. A removed
+// member keeps whatever they already decrypted, so rotate secrets they knew.
+func (s *ConnectionStore) RemoveTeamMember(name string) error {
+	return s.updateTeamMembers(func(id cryptoutil.Identity, members []cryptoutil.TeamMember, _ []byte) ([]cryptoutil.TeamMember, []byte, error) {
+		name = strings.TrimSpace(name)
+		remaining := make([]cryptoutil.TeamMember, 0, len(members))
+		for _, member := range members {
+			if member.Name == name {
+				if member.Recipient == id.Recipient() {
+					return nil, nil, errors.New("you cannot remove yourself; ask another member to remove you")
+				}
+				continue
+			}
+			remaining = append(remaining, member)
+		}
+		if len(remaining) == len(members) {
+			return nil, nil, fmt.Errorf("no team member named %q", name)
+		}
+
+		dataKey, err := cryptoutil.NewTeamDataKey()
+		if err != nil {
+			return nil, nil, err
+		}
+		rewrapped := make([]cryptoutil.TeamMember, 0, len(remaining))
+		for _, member := range remaining {
+			wrapped, err := cryptoutil.WrapTeamKey(dataKey, member.Name, member.Recipient)
+			if err != nil {
+				return nil, nil, err
+			}
+			rewrapped = append(rewrapped, wrapped)
+		}
+		return rewrapped, dataKey, nil
+	})
+}
+
+func (s *ConnectionStore) updateTeamMembers(change func(cryptoutil.Identity, []cryptoutil.TeamMember, []byte) ([]cryptoutil.TeamMember, []byte, error)) error {
+	if err := s.changeTeamMembers(change); err != nil {
+		return err
+	}
+	s.notifySaved()
+	return nil
+}
+
+func (s *ConnectionStore) changeTeamMembers(change func(cryptoutil.Identity, []cryptoutil.TeamMember, []byte) ([]cryptoutil.TeamMember, []byte, error)) error {
+	unlock, err := s.acquireMutationLock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	id, err := s.requireTeamIdentity()
+	if err != nil {
+		return err
+	}
+	content, members, dataKey, err := s.openTeamFile(id)
+	if err != nil {
+		return err
+	}
+	members, dataKey, err = change(id, members, dataKey)
+	if err != nil {
+		return err
+	}
+	return s.writeTeamFile(id, content, dataKey, members)
+}
+
+func (s *ConnectionStore) requireTeamIdentity() (cryptoutil.Identity, error) {
+	id, team, err := s.teamIdentity()
+	if err != nil {
+		return cryptoutil.Identity{}, err
+	}
+	if !team {
+		return cryptoutil.Identity{}, errors.New("not a team vault (create one with 'sshmanager vault create --team <name>')")
+	}
+	return id, nil
+}
+
+// newTeamMember validates a new member's name and recipient against the
+// existing members.
+func newTeamMember(members []cryptoutil.TeamMember, name, recipient string) (cryptoutil.TeamMember, error) {
+	name = strings.TrimSpace(name)
+	recipient = strings.TrimSpace(recipient)
+	if name == "" {
+		return cryptoutil.TeamMember{}, errors.New("member name must not be empty")
+	}
+	if _, err := cryptoutil.ParseRecipient(recipient); err != nil {
+		return cryptoutil.TeamMember{}, err
+	}
+	for _, member := range members {
+		if member.Name == name {
+			return cryptoutil.TeamMember{}, fmt.Errorf("team member %q already exists", name)
+		}
+		if member.Recipient == recipient {
+			return cryptoutil.TeamMember{}, fmt.Errorf("recipient is already a member as %q", member.Name)
+		}
+	}
+	return cryptoutil.TeamMember{Name: name, Recipient: recipient}, nil
+}
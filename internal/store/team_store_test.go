@@ -0,0 +1,182 @@
+package store
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	cryptoutil "github.com/emirhangumus/sshmanager/internal/crypto"
+	"github.com/emirhangumus/sshmanager/internal/model"
+)
+
+// newTeamMemberStore gives a member their own identity file and a copy of
+// the shared connection file at sharedPath.
+func newTeamMemberStore(t *testing.T, sharedPath string) (*ConnectionStore, cryptoutil.Identity) {
+	t.Helper()
+
+	id, err := cryptoutil.GenerateIdentity()
+	if err != nil {
+		t.Fatalf("GenerateIdentity failed: %v", err)
+	}
+	keyPath := filepath.Join(t.TempDir(), "secret.key")
+	if err := cryptoutil.WriteIdentityFile(keyPath, id); err != nil {
+		t.Fatalf("WriteIdentityFile failed: %v", err)
+	}
+	return NewConnectionStore(sharedPath, keyPath), id
+}
+
+func TestTeamVaultSharesConnectionsBetweenMembers(t *testing.T) {
+	sharedPath := filepath.Join(t.TempDir(), "conn")
+	alice, _ := newTeamMemberStore(t, sharedPath)
+	bob, bobID := newTeamMemberStore(t, sharedPath)
+
+	if err := alice.InitializeIfEmpty(); err != nil {
+		t.Fatalf("InitializeIfEmpty failed: %v", err)
+	}
+	if _, err := alice.Load(); !errors.Is(err, ErrTeamFileMissing) {
+		t.Fatalf("expected ErrTeamFileMissing before InitTeam, got %v", err)
+	}
+	if err := alice.InitTeam("alice"); err != nil {
+		t.Fatalf("InitTeam failed: %v", err)
+	}
+	if err := alice.Update(func(connFile *model.ConnectionFile) error {
+		return connFile.AddConnection(model.SSHConnection{Username: "ops", Host: "db.internal", AuthMode: model.AuthModeAgent, Alias: "db"})
+	}); err != nil {
+		t.Fatalf("alice Update failed: %v", err)
+	}
+
+	if _, err := bob.Load(); !errors.Is(err, cryptoutil.ErrNotTeamMember) {
+		t.Fatalf("expected ErrNotTeamMember before bob is added, got %v", err)
+	}
+	if err := alice.AddTeamMember("bob", bobID.Recipient()); err != nil {
+		t.Fatalf("AddTeamMember failed: %v", err)
+	}
+	if err := alice.AddTeamMember("bob", bobID.Recipient()); err == nil {
+		t.Fatal("expected duplicate member to be rejected")
+	}
+
+	if err := bob.Update(func(connFile *model.ConnectionFile) error {
+		return connFile.AddConnection(model.SSHConnection{Username: "ops", Host: "web.internal", AuthMode: model.AuthModeAgent, Alias: "web"})
+	}); err != nil {
+		t.Fatalf("bob Update failed: %v", err)
+	}
+	loaded, err := alice.Load()
+	if err != nil {
+		t.Fatalf("alice Load failed: %v", err)
+	}
+	if len(loaded.Connections) != 2 {
+		t.Fatalf("expected both members' connections, got %+v", loaded.Connections)
+	}
+
+	members, self, err := bob.TeamMembers()
+	if err != nil || len(members) != 2 || self != bobID.Recipient() {
+		t.Fatalf("unexpected members: %+v self=%s err=%v", members, self, err)
+	}
+}
+
+func TestRemoveTeamMemberRotatesDataKey(t *testing.T) {
+	sharedPath := filepath.Join(t.TempDir(), "conn")
+	alice, aliceID := newTeamMemberStore(t, sharedPath)
+	bob, bobID := newTeamMemberStore(t, sharedPath)
+
+	if err := alice.InitTeam("alice"); err != nil {
+		t.Fatalf("InitTeam failed: %v", err)
+	}
+	if err := alice.AddTeamMember("bob", bobID.Recipient()); err != nil {
+		t.Fatalf("AddTeamMember failed: %v", err)
+	}
+	before, err := os.ReadFile(sharedPath)
+	if err != nil {
+		t.Fatalf("ReadFile failed: %v", err)
+	}
+
+	if err := alice.RemoveTeamMember("alice"); err == nil {
+		t.Fatal("expected removing yourself to fail")
+	}
+	if err := alice.RemoveTeamMember("carol"); err == nil {
+		t.Fatal("expected removing an unknown member to fail")
+	}
+	if err := alice.RemoveTeamMember("bob"); err != nil {
+		t.Fatalf("RemoveTeamMember failed: %v", err)
+	}
+
+	if _, err := bob.Load(); !errors.Is(err, cryptoutil.ErrNotTeamMember) {
+		t.Fatalf("expected bob to be locked out, got %v", err)
+	}
+	if _, err := alice.Load(); err != nil {
+		t.Fatalf("alice Load after removal failed: %v", err)
+	}
+
+	// The data key Bob knew must not be the one the file uses now.
+	_, _, oldKey, err := cryptoutil.OpenTeamEnvelope(before, bobID)
+	if err != nil {
+		t.Fatalf("OpenTeamEnvelope(before) failed: %v", err)
+	}
+	after, err := os.ReadFile(sharedPath)
+	if err != nil {
+		t.Fatalf("ReadFile failed: %v", err)
+	}
+	_, members, newKey, err := cryptoutil.OpenTeamEnvelope(after, aliceID)
+	if err != nil {
+		t.Fatalf("OpenTeamEnvelope(after) failed: %v", err)
+	}
+	if bytes.Equal(oldKey, newKey) {
+		t.Fatal("expected the data key to be rotated")
+	}
+	if len(members) != 1 || members[0].Name != "alice" {
+		t.Fatalf("unexpected members after removal: %+v", members)
+	}
+}
+
+func TestTeamVaultHistoryAndRekey(t *testing.T) {
+	sharedPath := filepath.Join(t.TempDir(), "conn")
+	alice, _ := newTeamMemberStore(t, sharedPath)
+	if err := alice.InitTeam("alice"); err != nil {
+		t.Fatalf("InitTeam failed: %v", err)
+	}
+
+	history := NewHistoryStore(sharedPath, alice.secretKeyFilePath)
+	if err := history.Append(model.HistoryEntry{Alias: "db"}); err != nil {
+		t.Fatalf("history Append failed: %v", err)
+	}
+	if loaded, err := history.Load(); err != nil || len(loaded.Entries) != 1 {
+		t.Fatalf("history Load: %+v %v", loaded, err)
+	}
+
+	next, err := cryptoutil.NewRawKeyFile()
+	if err != nil {
+		t.Fatalf("NewRawKeyFile failed: %v", err)
+	}
+	if err := alice.Rekey(next); err == nil {
+		t.Fatal("expected rekey of a team vault to fail")
+	}
+}
+
+func TestMergeTeamMembers(t *testing.T) {
+	member := func(name, recipient string) cryptoutil.TeamMember {
+		return cryptoutil.TeamMember{Name: name, Recipient: recipient}
+	}
+	base := []cryptoutil.TeamMember{member("alice", "r-alice"), member("bob", "r-bob"), member("carol", "r-carol")}
+
+	ours := []cryptoutil.TeamMember{member("alice", "r-alice"), member("bob", "r-bob"), member("carol", "r-carol"), member("dave", "r-dave")}
+	theirs := []cryptoutil.TeamMember{member("alice", "r-alice"), member("bob", "r-bob")}
+	merged, removed, err := mergeTeamMembers(base, ours, theirs)
+	if err != nil {
+		t.Fatalf("mergeTeamMembers failed: %v", err)
+	}
+	if !removed || !sameTeamMembers(merged, []cryptoutil.TeamMember{member("alice", "r-alice"), member("bob", "r-bob"), member("dave", "r-dave")}) {
+		t.Fatalf("unexpected merge: %+v removed=%v", merged, removed)
+	}
+
+	merged, removed, err = mergeTeamMembers(base, base, append(append([]cryptoutil.TeamMember(nil), base...), member("erin", "r-erin")))
+	if err != nil || removed || len(merged) != 4 {
+		t.Fatalf("expected a remote addition to be kept without a removal, got %+v removed=%v err=%v", merged, removed, err)
+	}
+
+	_, _, err = mergeTeamMembers(base, append(append([]cryptoutil.TeamMember(nil), base...), member("erin", "r-erin")), append(append([]cryptoutil.TeamMember(nil), base...), member("erin", "r-other")))
+	if err == nil {
+		t.Fatal("expected the same name added with different recipients to be refused")
+	}
+}
@@ -0,0 +1,77 @@
+package store
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/emirhangumus/sshmanager/internal/model"
+	"github.com/emirhangumus/sshmanager/internal/storage"
+)
+
+const tunnelStateFileName = "tunnels.json"
+
+// TunnelStore persists the state of background tunnels. Unlike the other
+// stores it is plaintext: tunnel supervisors update it without the vault
+// key, and it holds no secrets.
+type TunnelStore struct {
+	stateFilePath string
+}
+
+// TunnelStateFilePath returns the tunnel state file location for a
+// connection file.
+func TunnelStateFilePath(connectionFilePath string) string {
+	return filepath.Join(filepath.Dir(connectionFilePath), tunnelStateFileName)
+}
+
+func NewTunnelStore(stateFilePath string) *TunnelStore {
+	return &TunnelStore{stateFilePath: stateFilePath}
+}
+
+// Path returns the state file location.
+func (s *TunnelStore) Path() string {
+	return s.stateFilePath
+}
+
+// Load returns the recorded tunnels, or none when the file does not exist.
+func (s *TunnelStore) Load() (model.TunnelStateFile, error) {
+	var state model.TunnelStateFile
+	data, err := os.ReadFile(s.stateFilePath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return state, nil
+		}
+		return state, fmt.Errorf("failed to read tunnel state: %w", err)
+	}
+	if len(data) == 0 {
+		return state, nil
+	}
+	if err := json.Unmarshal(data, &state); err != nil {
+		return state, fmt.Errorf("failed to parse tunnel state %s: %w", s.stateFilePath, err)
+	}
+	return state, nil
+}
+
+// Update applies mutate under the state lock and writes the result.
+func (s *TunnelStore) Update(mutate func(state *model.TunnelStateFile) error) error {
+	unlock, err := acquireFileLock(s.stateFilePath + ".lock")
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	state, err := s.Load()
+	if err != nil {
+		return err
+	}
+	if err := mutate(&state); err != nil {
+		return err
+	}
+
+	data, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode tunnel state: %w", err)
+	}
+	return storage.WriteFileAtomic(s.stateFilePath, append(data, '\n'), 0o600)
+}
@@ -0,0 +1,89 @@
+package store
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/emirhangumus/sshmanager/internal/model"
+)
+
+func TestTunnelStoreUpdateAndLoad(t *testing.T) {
+	tmpDir := t.TempDir()
+	statePath := TunnelStateFilePath(filepath.Join(tmpDir, "conn"))
+	if statePath != filepath.Join(tmpDir, "tunnels.json") {
+		t.Fatalf("unexpected state path %q", statePath)
+	}
+
+	tunnelStore := NewTunnelStore(statePath)
+	empty, err := tunnelStore.Load()
+	if err != nil {
+		t.Fatalf("Load on missing state failed: %v", err)
+	}
+	if len(empty.Tunnels) != 0 {
+		t.Fatalf("expected no tunnels, got %d", len(empty.Tunnels))
+	}
+
+	for _, state := range []model.TunnelState{
+		{ConnectionID: "c-1", Alias: "web", PID: 10, LocalListen: []string{"localhost:8080"}, Status: model.TunnelStatusStarting},
+		{ConnectionID: "c-2", Alias: "db", PID: 20, Status: model.TunnelStatusUp},
+		{ConnectionID: "c-1", Alias: "web", PID: 11, LocalListen: []string{"localhost:8080"}, Status: model.TunnelStatusUp},
+	} {
+		if err := tunnelStore.Update(func(file *model.TunnelStateFile) error {
+			file.Put(state)
+			return nil
+		}); err != nil {
+			t.Fatalf("Update failed: %v", err)
+		}
+	}
+
+	loaded, err := tunnelStore.Load()
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if len(loaded.Tunnels) != 2 {
+		t.Fatalf("expected 2 tunnels, got %+v", loaded.Tunnels)
+	}
+	if web := loaded.Get("c-1"); web == nil || web.PID != 11 || web.Status != model.TunnelStatusUp {
+		t.Fatalf("expected replaced c-1 entry, got %+v", web)
+	}
+	if owner := loaded.FindLocalListener("LOCALHOST:8080"); owner == nil || owner.ConnectionID != "c-1" {
+		t.Fatalf("expected c-1 to own localhost:8080, got %+v", owner)
+	}
+
+	info, err := os.Stat(statePath)
+	if err != nil {
+		t.Fatalf("stat state file: %v", err)
+	}
+	if info.Mode().Perm() != 0o600 {
+		t.Fatalf("expected mode 0600, got %v", info.Mode().Perm())
+	}
+}
+
+func TestTunnelStoreUpdateErrorLeavesStateUntouched(t *testing.T) {
+	tunnelStore := NewTunnelStore(filepath.Join(t.TempDir(), "tunnels.json"))
+	if err := tunnelStore.Update(func(file *model.TunnelStateFile) error {
+		file.Put(model.TunnelState{ConnectionID: "c-1", PID: 1})
+		return nil
+	}); err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+
+	errBoom := errors.New("boom")
+	err := tunnelStore.Update(func(file *model.TunnelStateFile) error {
+		file.Remove("c-1")
+		return errBoom
+	})
+	if !errors.Is(err, errBoom) {
+		t.Fatalf("expected mutate error, got %v", err)
+	}
+
+	loaded, err := tunnelStore.Load()
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if loaded.Get("c-1") == nil {
+		t.Fatal("expected failed update to keep c-1")
+	}
+}
@@ -0,0 +1,43 @@
+//go:build windows
+
+package tunnel
+
+import (
+	"errors"
+	"os"
+	"syscall"
+)
+
+const detachedProcess = 0x00000008
+
+// detachedProcAttr starts the supervisor without a console so it outlives
+// the terminal that started it.
+func detachedProcAttr() *syscall.SysProcAttr {
+	return &syscall.SysProcAttr{CreationFlags: detachedProcess | syscall.CREATE_NEW_PROCESS_GROUP}
+}
+
+// ProcessAlive reports whether a process with pid exists.
+func ProcessAlive(pid int) bool {
+	if pid <= 0 {
+		return false
+	}
+	proc, err := os.FindProcess(pid)
+	if err != nil {
+		return false
+	}
+	_ = proc.Release()
+	return true
+}
+
+// Terminate stops the process with pid. Windows has no SIGTERM, so the
+// process is killed; callers also stop its ssh child.
+func Terminate(pid int) error {
+	proc, err := os.FindProcess(pid)
+	if err != nil {
+		return err
+	}
+	if err := proc.Kill(); err != nil && !errors.Is(err, os.ErrProcessDone) {
+		return err
+	}
+	return nil
+}
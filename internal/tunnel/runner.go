@@ -0,0 +1,190 @@
+package tunnel
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/emirhangumus/sshmanager/internal/model"
+	"github.com/emirhangumus/sshmanager/internal/nativessh"
+)
+
+const (
+	// readyTimeout bounds how long an attempt waits for its local
+	// listeners before it is reported as up anyway.
+	readyTimeout = 10 * time.Second
+
+	// readyGrace is how long an ssh process without local forwards must
+	// stay alive to count as up.
+	readyGrace = 2 * time.Second
+
+	nativeKeepAliveInterval = 15 * time.Second
+	maxStderrTail           = 2048
+)
+
+// CommandRunner runs an ssh -N (or sshpass) command line. It is up once
+// every address in LocalListen accepts connections.
+type CommandRunner struct {
+	Command     []string
+	Env         []string
+	LocalListen []string
+}
+
+func (r CommandRunner) Run(ctx context.Context, ready func(sshPID int)) error {
+	if len(r.Command) == 0 {
+		return errors.New("tunnel command is empty")
+	}
+	var stderr tailBuffer
+	cmd := exec.CommandContext(ctx, r.Command[0], r.Command[1:]...)
+	cmd.Env = append(os.Environ(), r.Env...)
+	cmd.Stderr = &stderr
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+
+	exited := make(chan struct{})
+	probed := make(chan struct{})
+	go func() {
+		defer close(probed)
+		if waitForListeners(r.LocalListen, exited) {
+			ready(cmd.Process.Pid)
+		}
+	}()
+	err := cmd.Wait()
+	close(exited)
+	// ready must not run after Run returns, or a late call could report
+	// the attempt as up after the supervisor recorded its exit.
+	<-probed
+
+	if detail := stderr.lastLine(); detail != "" {
+		return fmt.Errorf("%s exited: %s", filepath.Base(r.Command[0]), detail)
+	}
+	if err != nil {
+		return fmt.Errorf("%s exited: %w", filepath.Base(r.Command[0]), err)
+	}
+	return nil
+}
+
+// waitForListeners reports true once every addr accepts a connection (or
+// readyTimeout passes), or after readyGrace when there are none. It reports
+// false when exited is closed first.
+func waitForListeners(addrs []string, exited <-chan struct{}) bool {
+	if len(addrs) == 0 {
+		select {
+		case <-exited:
+			return false
+		case <-time.After(readyGrace):
+			return true
+		}
+	}
+
+	deadline := time.Now().Add(readyTimeout)
+	pending := append([]string(nil), addrs...)
+	for len(pending) > 0 && time.Now().Before(deadline) {
+		var still []string
+		for _, addr := range pending {
+			conn, err := net.DialTimeout(nativessh.ListenNetwork(addr), addr, 200*time.Millisecond)
+			if err != nil {
+				still = append(still, addr)
+				continue
+			}
+			_ = conn.Close()
+		}
+		pending = still
+		if len(pending) == 0 {
+			break
+		}
+		select {
+		case <-exited:
+			return false
+		case <-time.After(100 * time.Millisecond):
+		}
+	}
+	select {
+	case <-exited:
+		return false
+	default:
+		return true
+	}
+}
+
+// NativeRunner keeps the forwards of Conn open with the in-process client,
+// sending keepalives to notice a dead connection.
+type NativeRunner struct {
+	Conn           model.SSHConnection
+	Password       string
+	KnownHostsFile string
+}
+
+func (r NativeRunner) Run(ctx context.Context, ready func(sshPID int)) error {
+	conn := r.Conn
+	client, err := nativessh.Dial(&conn, nativessh.Options{
+		Password:       func() (string, error) { return r.Password, nil },
+		KnownHostsFile: r.KnownHostsFile,
+	})
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	if err := client.StartForwards(&conn); err != nil {
+		return err
+	}
+	ready(0)
+
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		ticker := time.NewTicker(nativeKeepAliveInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				_ = client.Close()
+				return
+			case <-done:
+				return
+			case <-ticker.C:
+				if _, _, err := client.SendRequest("keepalive@openssh.com", true, nil); err != nil {
+					_ = client.Close()
+					return
+				}
+			}
+		}
+	}()
+
+	if err := client.Wait(); err != nil && ctx.Err() == nil {
+		return fmt.Errorf("connection lost: %w", err)
+	}
+	return nil
+}
+
+// tailBuffer keeps the last maxStderrTail bytes written to it.
+type tailBuffer struct {
+	mu   sync.Mutex
+	data []byte
+}
+
+func (b *tailBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.data = append(b.data, p...)
+	if len(b.data) > maxStderrTail {
+		b.data = b.data[len(b.data)-maxStderrTail:]
+	}
+	return len(p), nil
+}
+
+func (b *tailBuffer) lastLine() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	lines := strings.Split(strings.TrimSpace(string(b.data)), "\n")
+	return strings.TrimSpace(lines[len(lines)-1])
+}
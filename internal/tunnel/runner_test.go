@@ -0,0 +1,102 @@
+package tunnel
+
+import (
+	"context"
+	"net"
+	"os/exec"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestCommandRunnerReportsLastStderrLine(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("sh not available")
+	}
+	runner := CommandRunner{Command: []string{"sh", "-c", "echo warming up >&2; echo 'bind [127.0.0.1]:8080: Address already in use' >&2; exit 255"}}
+
+	readyCalled := false
+	err := runner.Run(context.Background(), func(int) { readyCalled = true })
+	if err == nil || !strings.Contains(err.Error(), "sh exited: bind [127.0.0.1]:8080: Address already in use") {
+		t.Fatalf("expected the last stderr line in the error, got %v", err)
+	}
+	if readyCalled {
+		t.Fatal("expected ready not to be called for a process that exited at once")
+	}
+}
+
+func TestCommandRunnerWaitsForReadyBeforeReturning(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("sh not available")
+	}
+	// The process exits while its listener is still being probed, so the
+	// attempt never counts as up.
+	closed := startAcceptingListener(t)
+	closedAddr := closed.Addr().String()
+	_ = closed.Close()
+	var readyCalled atomic.Bool
+	runner := CommandRunner{
+		Command:     []string{"sh", "-c", "exit 0"},
+		LocalListen: []string{closedAddr},
+	}
+	if err := runner.Run(context.Background(), func(int) { readyCalled.Store(true) }); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if readyCalled.Load() {
+		t.Fatal("expected ready not to be called once the process exited")
+	}
+
+	// The process exits while ready is still reporting the attempt as up.
+	listener := startAcceptingListener(t)
+	runner = CommandRunner{
+		Command:     []string{"sh", "-c", "sleep 0.1"},
+		LocalListen: []string{listener.Addr().String()},
+	}
+	var readyReturned atomic.Bool
+	err := runner.Run(context.Background(), func(int) {
+		time.Sleep(300 * time.Millisecond)
+		readyReturned.Store(true)
+	})
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if !readyReturned.Load() {
+		t.Fatal("expected Run to return only after ready returned")
+	}
+}
+
+func TestWaitForListenersWaitsForEveryAddress(t *testing.T) {
+	listener := startAcceptingListener(t)
+
+	if !waitForListeners([]string{listener.Addr().String()}, make(chan struct{})) {
+		t.Fatal("expected a listening address to count as ready")
+	}
+
+	exited := make(chan struct{})
+	close(exited)
+	if waitForListeners([]string{"127.0.0.1:1"}, exited) {
+		t.Fatal("expected an exited process not to count as ready")
+	}
+}
+
+// startAcceptingListener listens on a free local port and accepts and
+// closes connections until the test ends.
+func startAcceptingListener(t *testing.T) net.Listener {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	t.Cleanup(func() { _ = listener.Close() })
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			_ = conn.Close()
+		}
+	}()
+	return listener
+}
@@ -0,0 +1,88 @@
+package tunnel
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+
+	"github.com/emirhangumus/sshmanager/internal/config"
+	"github.com/emirhangumus/sshmanager/internal/model"
+)
+
+// Spec is everything a supervisor needs to run a tunnel. tunnel up hands it
+// to the detached process on stdin, so resolved passwords never show up in
+// its arguments.
+type Spec struct {
+	State model.TunnelState `json:"state"`
+
+	// Command and Env run an openssh tunnel.
+	Command []string `json:"command,omitempty"`
+	Env     []string `json:"env,omitempty"`
+
+	// Connection, Password and KnownHostsFile run a native tunnel.
+	Connection     *model.SSHConnection `json:"connection,omitempty"`
+	Password       string               `json:"password,omitempty"`
+	KnownHostsFile string               `json:"knownHostsFile,omitempty"`
+}
+
+// Runner returns the Runner for the spec's backend.
+func (s Spec) Runner() (Runner, error) {
+	switch s.State.Backend {
+	case config.ConnectBackendOpenSSH:
+		if len(s.Command) == 0 {
+			return nil, errors.New("tunnel spec has no command")
+		}
+		return CommandRunner{Command: s.Command, Env: s.Env, LocalListen: s.State.LocalListen}, nil
+	case config.ConnectBackendNative:
+		if s.Connection == nil {
+			return nil, errors.New("tunnel spec has no connection")
+		}
+		return NativeRunner{Conn: *s.Connection, Password: s.Password, KnownHostsFile: s.KnownHostsFile}, nil
+	default:
+		return nil, fmt.Errorf("unsupported tunnel backend %q", s.State.Backend)
+	}
+}
+
+// ReadSpec decodes the spec a supervisor receives on stdin.
+func ReadSpec(r io.Reader) (Spec, error) {
+	var spec Spec
+	if err := json.NewDecoder(r).Decode(&spec); err != nil {
+		return spec, fmt.Errorf("failed to read tunnel spec: %w", err)
+	}
+	if spec.State.ConnectionID == "" {
+		return spec, errors.New("tunnel spec has no connection ID")
+	}
+	return spec, nil
+}
+
+// StartDetached runs executable with args as a detached background process
+// and writes spec to its stdin.
+func StartDetached(executable string, args []string, spec Spec) error {
+	devNull, err := os.OpenFile(os.DevNull, os.O_RDWR, 0)
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %w", os.DevNull, err)
+	}
+	defer devNull.Close()
+
+	cmd := exec.Command(executable, args...)
+	cmd.Stdout = devNull
+	cmd.Stderr = devNull
+	cmd.SysProcAttr = detachedProcAttr()
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return err
+	}
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("failed to start tunnel supervisor: %w", err)
+	}
+	encodeErr := json.NewEncoder(stdin).Encode(spec)
+	_ = stdin.Close()
+	if encodeErr != nil {
+		_ = cmd.Process.Kill()
+		return fmt.Errorf("failed to hand the tunnel spec to the supervisor: %w", encodeErr)
+	}
+	return cmd.Process.Release()
+}
@@ -0,0 +1,184 @@
+// Package tunnel keeps the forwards of a connection open in the background:
+// a detached supervisor process runs ssh -N (or the native client) and
+// restarts it with exponential backoff when it drops, recording its state
+// in the vault's tunnel state file.
+package tunnel
+
+import (
+	"context"
+	"errors"
+	"os"
+	"time"
+
+	"github.com/emirhangumus/sshmanager/internal/model"
+	"github.com/emirhangumus/sshmanager/internal/store"
+)
+
+const (
+	DefaultMinBackoff = time.Second
+	DefaultMaxBackoff = time.Minute
+
+	// DefaultStableAfter is how long an attempt must stay up for the
+	// backoff to start over at DefaultMinBackoff.
+	DefaultStableAfter = time.Minute
+)
+
+// errTunnelClosed is reported when an attempt ends without an error.
+var errTunnelClosed = errors.New("tunnel closed")
+
+// Runner runs one attempt of a tunnel. It calls ready once the forwards are
+// up, with the PID of the ssh process (0 for in-process tunnels), and
+// returns when the tunnel drops or ctx is cancelled.
+type Runner interface {
+	Run(ctx context.Context, ready func(sshPID int)) error
+}
+
+// Supervisor keeps a Runner going and mirrors its progress into the state
+// file. When FailFast is set, a first attempt that never comes up ends the
+// supervisor with status failed instead of being retried.
+type Supervisor struct {
+	Runner   Runner
+	Store    *store.TunnelStore
+	State    model.TunnelState
+	FailFast bool
+
+	MinBackoff  time.Duration
+	MaxBackoff  time.Duration
+	StableAfter time.Duration
+
+	now   func() time.Time
+	sleep func(ctx context.Context, d time.Duration) bool
+}
+
+// Run supervises until ctx is cancelled, the tunnel is removed from the
+// state file (tunnel down), or a FailFast first attempt fails.
+func (s *Supervisor) Run(ctx context.Context) error {
+	s.withDefaults()
+
+	state := s.State
+	state.PID = os.Getpid()
+	state.Status = model.TunnelStatusStarting
+	state.StartedAt = s.now()
+	if err := s.Store.Update(func(file *model.TunnelStateFile) error {
+		file.Put(state)
+		return nil
+	}); err != nil {
+		return err
+	}
+
+	backoff := s.MinBackoff
+	everUp := false
+	for {
+		attemptStarted := s.now()
+		wasUp := false
+		err := s.Runner.Run(ctx, func(sshPID int) {
+			wasUp = true
+			_ = s.update(func(current *model.TunnelState) {
+				current.Status = model.TunnelStatusUp
+				current.SSHPID = sshPID
+				current.ConnectedAt = s.now()
+				current.NextRetryAt = time.Time{}
+			})
+		})
+		if ctx.Err() != nil {
+			return s.remove()
+		}
+		if err == nil {
+			err = errTunnelClosed
+		}
+		everUp = everUp || wasUp
+
+		if s.FailFast && !everUp {
+			_ = s.update(func(current *model.TunnelState) {
+				current.Status = model.TunnelStatusFailed
+				current.SSHPID = 0
+				current.LastError = err.Error()
+			})
+			return err
+		}
+
+		if wasUp && s.now().Sub(attemptStarted) >= s.StableAfter {
+			backoff = s.MinBackoff
+		}
+		stillWanted := s.update(func(current *model.TunnelState) {
+			current.Status = model.TunnelStatusRestarting
+			current.SSHPID = 0
+			current.Restarts++
+			current.LastError = err.Error()
+			current.NextRetryAt = s.now().Add(backoff)
+		})
+		if !stillWanted || !s.sleep(ctx, backoff) {
+			return s.remove()
+		}
+		if !s.stillWanted() {
+			return nil
+		}
+		backoff *= 2
+		if backoff > s.MaxBackoff {
+			backoff = s.MaxBackoff
+		}
+	}
+}
+
+func (s *Supervisor) withDefaults() {
+	if s.MinBackoff <= 0 {
+		s.MinBackoff = DefaultMinBackoff
+	}
+	if s.MaxBackoff < s.MinBackoff {
+		s.MaxBackoff = DefaultMaxBackoff
+	}
+	if s.StableAfter <= 0 {
+		s.StableAfter = DefaultStableAfter
+	}
+	if s.now == nil {
+		s.now = time.Now
+	}
+	if s.sleep == nil {
+		s.sleep = sleepContext
+	}
+}
+
+// update changes this supervisor's entry and reports whether it still
+// exists; tunnel down removes the entry before stopping the process.
+func (s *Supervisor) update(change func(current *model.TunnelState)) bool {
+	found := false
+	err := s.Store.Update(func(file *model.TunnelStateFile) error {
+		current := file.Get(s.State.ConnectionID)
+		if current == nil || current.PID != os.Getpid() {
+			return nil
+		}
+		found = true
+		change(current)
+		return nil
+	})
+	return err != nil || found
+}
+
+func (s *Supervisor) stillWanted() bool {
+	file, err := s.Store.Load()
+	if err != nil {
+		return true
+	}
+	current := file.Get(s.State.ConnectionID)
+	return current != nil && current.PID == os.Getpid()
+}
+
+func (s *Supervisor) remove() error {
+	return s.Store.Update(func(file *model.TunnelStateFile) error {
+		if current := file.Get(s.State.ConnectionID); current != nil && current.PID == os.Getpid() {
+			file.Remove(s.State.ConnectionID)
+		}
+		return nil
+	})
+}
+
+func sleepContext(ctx context.Context, d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
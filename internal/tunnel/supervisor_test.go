@@ -0,0 +1,198 @@
+package tunnel
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/emirhangumus/sshmanager/internal/model"
+	"github.com/emirhangumus/sshmanager/internal/store"
+)
+
+// scriptedRunner plays one attempt per call; attempts past the script
+// block until ctx is cancelled.
+type scriptedRunner struct {
+	attempts []func(ready func(sshPID int)) error
+	calls    int
+}
+
+func (r *scriptedRunner) Run(ctx context.Context, ready func(sshPID int)) error {
+	r.calls++
+	if r.calls > len(r.attempts) {
+		<-ctx.Done()
+		return ctx.Err()
+	}
+	return r.attempts[r.calls-1](ready)
+}
+
+func newTestSupervisor(t *testing.T, runner Runner) (*Supervisor, *store.TunnelStore, *[]time.Duration) {
+	t.Helper()
+	tunnelStore := store.NewTunnelStore(filepath.Join(t.TempDir(), "tunnels.json"))
+	var sleeps []time.Duration
+	supervisor := &Supervisor{
+		Runner:     runner,
+		Store:      tunnelStore,
+		State:      model.TunnelState{ConnectionID: "c-1", Alias: "web", Backend: "openssh"},
+		MinBackoff: time.Second,
+		MaxBackoff: 4 * time.Second,
+		now:        func() time.Time { return time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC) },
+		sleep: func(ctx context.Context, d time.Duration) bool {
+			sleeps = append(sleeps, d)
+			return true
+		},
+	}
+	return supervisor, tunnelStore, &sleeps
+}
+
+func loadTunnel(t *testing.T, tunnelStore *store.TunnelStore) *model.TunnelState {
+	t.Helper()
+	file, err := tunnelStore.Load()
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	return file.Get("c-1")
+}
+
+func TestSupervisorRestartsWithBackoff(t *testing.T) {
+	errDropped := errors.New("connection reset")
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var seen []model.TunnelState
+	var tunnelStore *store.TunnelStore
+	record := func() {
+		seen = append(seen, *loadTunnel(t, tunnelStore))
+	}
+	runner := &scriptedRunner{attempts: []func(func(int)) error{
+		func(ready func(int)) error { ready(42); record(); return errDropped },
+		func(ready func(int)) error { record(); return errDropped },
+		func(ready func(int)) error { return errDropped },
+		func(ready func(int)) error { return nil },
+		func(ready func(int)) error { record(); cancel(); return nil },
+	}}
+	supervisor, tunnelStore, sleeps := newTestSupervisor(t, runner)
+
+	if err := supervisor.Run(ctx); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+
+	want := []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 4 * time.Second}
+	if len(*sleeps) != len(want) {
+		t.Fatalf("expected backoffs %v, got %v", want, *sleeps)
+	}
+	for i := range want {
+		if (*sleeps)[i] != want[i] {
+			t.Fatalf("expected backoffs %v, got %v", want, *sleeps)
+		}
+	}
+
+	if seen[0].Status != model.TunnelStatusUp || seen[0].SSHPID != 42 || seen[0].PID != os.Getpid() {
+		t.Fatalf("unexpected state while up: %+v", seen[0])
+	}
+	if seen[1].Status != model.TunnelStatusRestarting || seen[1].Restarts != 1 || seen[1].LastError != "connection reset" || seen[1].SSHPID != 0 {
+		t.Fatalf("unexpected state while restarting: %+v", seen[1])
+	}
+	if seen[2].Restarts != 4 || seen[2].LastError != errTunnelClosed.Error() {
+		t.Fatalf("unexpected state after clean exit: %+v", seen[2])
+	}
+	if loadTunnel(t, tunnelStore) != nil {
+		t.Fatal("expected a cancelled supervisor to remove its entry")
+	}
+}
+
+func TestSupervisorResetsBackoffAfterStableAttempt(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	clock := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	runner := &scriptedRunner{attempts: []func(func(int)) error{
+		func(ready func(int)) error { return errors.New("refused") },
+		func(ready func(int)) error { return errors.New("refused") },
+		func(ready func(int)) error {
+			ready(1)
+			clock = clock.Add(2 * time.Minute)
+			return errors.New("dropped")
+		},
+		func(ready func(int)) error { cancel(); return nil },
+	}}
+	supervisor, _, sleeps := newTestSupervisor(t, runner)
+	supervisor.now = func() time.Time { return clock }
+
+	if err := supervisor.Run(ctx); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	want := []time.Duration{time.Second, 2 * time.Second, time.Second}
+	if len(*sleeps) != len(want) || (*sleeps)[2] != want[2] {
+		t.Fatalf("expected backoffs %v, got %v", want, *sleeps)
+	}
+}
+
+func TestSupervisorFailFastReportsFirstAttemptError(t *testing.T) {
+	runner := &scriptedRunner{attempts: []func(func(int)) error{
+		func(ready func(int)) error { return errors.New("Permission denied (publickey)") },
+	}}
+	supervisor, tunnelStore, sleeps := newTestSupervisor(t, runner)
+	supervisor.FailFast = true
+
+	err := supervisor.Run(context.Background())
+	if err == nil || err.Error() != "Permission denied (publickey)" {
+		t.Fatalf("expected the first attempt error, got %v", err)
+	}
+	if len(*sleeps) != 0 {
+		t.Fatalf("expected no retries, got %v", *sleeps)
+	}
+	state := loadTunnel(t, tunnelStore)
+	if state == nil || state.Status != model.TunnelStatusFailed || state.LastError != "Permission denied (publickey)" {
+		t.Fatalf("expected a failed entry, got %+v", state)
+	}
+}
+
+func TestSupervisorFailFastRestartsOnceUp(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	runner := &scriptedRunner{attempts: []func(func(int)) error{
+		func(ready func(int)) error { ready(7); return errors.New("dropped") },
+		func(ready func(int)) error { return errors.New("refused") },
+		func(ready func(int)) error { cancel(); return nil },
+	}}
+	supervisor, _, sleeps := newTestSupervisor(t, runner)
+	supervisor.FailFast = true
+
+	if err := supervisor.Run(ctx); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if len(*sleeps) != 2 {
+		t.Fatalf("expected two restarts, got %v", *sleeps)
+	}
+}
+
+func TestSupervisorStopsWhenEntryIsRemoved(t *testing.T) {
+	var tunnelStore *store.TunnelStore
+	runner := &scriptedRunner{attempts: []func(func(int)) error{
+		func(ready func(int)) error {
+			ready(9)
+			if err := tunnelStore.Update(func(file *model.TunnelStateFile) error {
+				file.Remove("c-1")
+				return nil
+			}); err != nil {
+				t.Fatalf("Update failed: %v", err)
+			}
+			return errors.New("terminated")
+		},
+	}}
+	supervisor, tunnelStore, sleeps := newTestSupervisor(t, runner)
+
+	if err := supervisor.Run(context.Background()); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if runner.calls != 1 || len(*sleeps) != 0 {
+		t.Fatalf("expected no restart after removal, got %d calls and sleeps %v", runner.calls, *sleeps)
+	}
+	if loadTunnel(t, tunnelStore) != nil {
+		t.Fatal("expected the entry to stay removed")
+	}
+}
@@ -95,6 +95,10 @@ func validateForwardList(input string) error {
 	return model.ValidateForwardSpecs(parseCommaSeparatedValues(input))
 }
 
+func validateDynamicForwardList(input string) error {
+	return model.ValidateDynamicForwardSpecs(parseCommaSeparatedValues(input))
+}
+
 func validateExtraSSHArgs(input string) error {
 	return model.ValidateExtraSSHArgs(parseCommaSeparatedValues(input))
 }
@@ -154,6 +158,11 @@ func AddSSHConnectionPrompt() (model.SSHConnection, error) {
 		return model.SSHConnection{}, err
 	}
 
+	dynamicForwardsRaw, err := runDynamicForwardListPrompt(DefaultPromptTexts.EnterDynamicForwards, "")
+	if err != nil {
+		return model.SSHConnection{}, err
+	}
+
 	extraSSHArgsRaw, err := runExtraSSHArgsPrompt(DefaultPromptTexts.EnterExtraSSHArgs, "")
 	if err != nil {
 		return model.SSHConnection{}, err
@@ -180,20 +189,21 @@ func AddSSHConnectionPrompt() (model.SSHConnection, error) {
 	}
 
 	conn := normalizeConnection(model.SSHConnection{
-		Username:       username,
-		Host:           host,
-		Port:           parsePort(portRaw),
-		AuthMode:       authMode,
-		Password:       password,
-		IdentityFile:   identityFile,
-		ProxyJump:      proxyJump,
-		LocalForwards:  parseCommaSeparatedValues(localForwardsRaw),
-		RemoteForwards: parseCommaSeparatedValues(remoteForwardsRaw),
-		ExtraSSHArgs:   parseCommaSeparatedValues(extraSSHArgsRaw),
-		Group:          group,
-		Tags:           parseCommaSeparatedValues(tagsRaw),
-		Description:    description,
-		Alias:          alias,
+		Username:        username,
+		Host:            host,
+		Port:            parsePort(portRaw),
+		AuthMode:        authMode,
+		Password:        password,
+		IdentityFile:    identityFile,
+		ProxyJump:       proxyJump,
+		LocalForwards:   parseCommaSeparatedValues(localForwardsRaw),
+		RemoteForwards:  parseCommaSeparatedValues(remoteForwardsRaw),
+		DynamicForwards: parseCommaSeparatedValues(dynamicForwardsRaw),
+		ExtraSSHArgs:    parseCommaSeparatedValues(extraSSHArgsRaw),
+		Group:           group,
+		Tags:            parseCommaSeparatedValues(tagsRaw),
+		Description:     description,
+		Alias:           alias,
 	})
 	conn = normalizeAuthSensitiveFields(conn)
 	return conn, nil
@@ -225,7 +235,9 @@ func EditSSHConnectionPrompt(conn *model.SSHConnection) (model.SSHConnection, er
 	}
 	authMode := model.NormalizeAuthMode(authModeRaw)
 
-	password, err := runPasswordPrompt(DefaultPromptTexts.EditPassword, conn.Password, authMode == model.AuthModePassword)
+	// Passwords from an external source are not edited here; the prompt only
+	// becomes required when switching to password auth without one.
+	password, err := runPasswordPrompt(DefaultPromptTexts.EditPassword, conn.Password, authMode == model.AuthModePassword && !conn.HasExternalPasswordSource())
 	if err != nil {
 		return model.SSHConnection{}, err
 	}
@@ -250,6 +262,11 @@ func EditSSHConnectionPrompt(conn *model.SSHConnection) (model.SSHConnection, er
 		return model.SSHConnection{}, err
 	}
 
+	dynamicForwardsRaw, err := runDynamicForwardListPrompt(DefaultPromptTexts.EditDynamicForwards, joinListForPrompt(conn.DynamicForwards))
+	if err != nil {
+		return model.SSHConnection{}, err
+	}
+
 	extraSSHArgsRaw, err := runExtraSSHArgsPrompt(DefaultPromptTexts.EditExtraSSHArgs, joinListForPrompt(conn.ExtraSSHArgs))
 	if err != nil {
 		return model.SSHConnection{}, err
@@ -276,21 +293,25 @@ func EditSSHConnectionPrompt(conn *model.SSHConnection) (model.SSHConnection, er
 	}
 
 	updated := normalizeConnection(model.SSHConnection{
-		ID:             conn.ID,
-		Username:       username,
-		Host:           host,
-		Port:           parsePort(portRaw),
-		AuthMode:       authMode,
-		Password:       password,
-		IdentityFile:   identityFile,
-		ProxyJump:      proxyJump,
-		LocalForwards:  parseCommaSeparatedValues(localForwardsRaw),
-		RemoteForwards: parseCommaSeparatedValues(remoteForwardsRaw),
-		ExtraSSHArgs:   parseCommaSeparatedValues(extraSSHArgsRaw),
-		Group:          group,
-		Tags:           parseCommaSeparatedValues(tagsRaw),
-		Description:    description,
-		Alias:          alias,
+		ID:              conn.ID,
+		Username:        username,
+		Host:            host,
+		Port:            parsePort(portRaw),
+		AuthMode:        authMode,
+		Password:        password,
+		IdentityFile:    identityFile,
+		ProxyJump:       proxyJump,
+		LocalForwards:   parseCommaSeparatedValues(localForwardsRaw),
+		RemoteForwards:  parseCommaSeparatedValues(remoteForwardsRaw),
+		DynamicForwards: parseCommaSeparatedValues(dynamicForwardsRaw),
+		ExtraSSHArgs:    parseCommaSeparatedValues(extraSSHArgsRaw),
+		Group:           group,
+		Tags:            parseCommaSeparatedValues(tagsRaw),
+		Description:     description,
+		Alias:           alias,
+		Extends:         conn.Extends,
+		PasswordSource:  conn.PasswordSource,
+		HostKeys:        conn.HostKeys,
 	})
 	updated = normalizeAuthSensitiveFields(updated)
 	return updated, nil
@@ -344,6 +365,10 @@ func runForwardListPrompt(label, defaultValue string) (string, error) {
 	return InputPrompt(label, defaultValue, false, validateForwardList)
 }
 
+func runDynamicForwardListPrompt(label, defaultValue string) (string, error) {
+	return InputPrompt(label, defaultValue, false, validateDynamicForwardList)
+}
+
 func runExtraSSHArgsPrompt(label, defaultValue string) (string, error) {
 	return InputPrompt(label, defaultValue, false, validateExtraSSHArgs)
 }
@@ -364,6 +389,7 @@ func normalizeConnection(conn model.SSHConnection) model.SSHConnection {
 	conn.ProxyJump = strings.TrimSpace(conn.ProxyJump)
 	conn.LocalForwards = model.NormalizeStringList(conn.LocalForwards)
 	conn.RemoteForwards = model.NormalizeStringList(conn.RemoteForwards)
+	conn.DynamicForwards = model.NormalizeStringList(conn.DynamicForwards)
 	conn.ExtraSSHArgs = model.NormalizeStringList(conn.ExtraSSHArgs)
 	conn.Group = strings.TrimSpace(conn.Group)
 	conn.Tags = model.NormalizeTags(conn.Tags)
@@ -376,10 +402,15 @@ func normalizeAuthSensitiveFields(conn model.SSHConnection) model.SSHConnection
 	switch conn.AuthMode {
 	case model.AuthModePassword:
 		conn.IdentityFile = ""
+		if conn.Password != "" {
+			conn.PasswordSource = ""
+		}
 	case model.AuthModeKey:
 		conn.Password = ""
+		conn.PasswordSource = ""
 	case model.AuthModeAgent:
 		conn.Password = ""
+		conn.PasswordSource = ""
 		conn.IdentityFile = ""
 	}
 	return conn
@@ -176,6 +176,32 @@ func TestValidateForwardList(t *testing.T) {
 	}
 }
 
+func TestValidateDynamicForwardList(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		wantErr bool
+	}{
+		{name: "empty allowed", input: ""},
+		{name: "port only", input: "1080"},
+		{name: "multiple", input: "1080,127.0.0.1:1081"},
+		{name: "invalid", input: "1080:host:80", wantErr: true},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			err := validateDynamicForwardList(tc.input)
+			if tc.wantErr && err == nil {
+				t.Fatal("expected error, got nil")
+			}
+			if !tc.wantErr && err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+		})
+	}
+}
+
 func TestValidateExtraSSHArgs(t *testing.T) {
 	tests := []struct {
 		name    string
@@ -0,0 +1,177 @@
+package prompt
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+)
+
+const (
+	fuzzyMatchBonus       = 16
+	fuzzyConsecutiveBonus = 12
+	fuzzyBoundaryBonus    = 10
+	fuzzyGapPenalty       = 4
+	fuzzyLeadingPenalty   = 1
+	fuzzyMaxLeadingCost   = 12
+)
+
+// fuzzyMatch is one item that satisfies a filter query.
+type fuzzyMatch struct {
+	// Index is the position of the item in the unfiltered list.
+	Index int
+	// Positions are the rune offsets in the item that matched, ascending.
+	Positions []int
+	Score     int
+}
+
+// fuzzyFilter returns every item matching query, best first. Each
+// whitespace-separated query term must match the item as a case-insensitive
+// subsequence. Equal scores are ordered by recency (higher first) and then by
+// original position. An empty query keeps every item in its original order.
+func fuzzyFilter(query string, items []string, recency []int) []fuzzyMatch {
+	terms := strings.Fields(strings.ToLower(query))
+	matches := make([]fuzzyMatch, 0, len(items))
+	for i, item := range items {
+		if len(terms) == 0 {
+			matches = append(matches, fuzzyMatch{Index: i})
+			continue
+		}
+
+		haystack := []rune(strings.ToLower(item))
+		total := 0
+		var positions []int
+		matched := true
+		for _, term := range terms {
+			score, termPositions, ok := fuzzyScore([]rune(term), haystack)
+			if !ok {
+				matched = false
+				break
+			}
+			total += score
+			positions = append(positions, termPositions...)
+		}
+		if !matched {
+			continue
+		}
+		sort.Ints(positions)
+		matches = append(matches, fuzzyMatch{Index: i, Positions: dedupeSortedInts(positions), Score: total})
+	}
+	if len(terms) == 0 {
+		return matches
+	}
+
+	recencyOf := func(idx int) int {
+		if idx < len(recency) {
+			return recency[idx]
+		}
+		return 0
+	}
+	sort.SliceStable(matches, func(a, b int) bool {
+		if matches[a].Score != matches[b].Score {
+			return matches[a].Score > matches[b].Score
+		}
+		return recencyOf(matches[a].Index) > recencyOf(matches[b].Index)
+	})
+	return matches
+}
+
+// fuzzyScore finds the best-scoring placement of needle as a subsequence of
+// haystack. Matches at word boundaries and consecutive runs score higher;
+// gaps and a late first match cost points.
+func fuzzyScore(needle, haystack []rune) (int, []int, bool) {
+	n, h := len(needle), len(haystack)
+	if n == 0 {
+		return 0, nil, true
+	}
+	if n > h {
+		return 0, nil, false
+	}
+
+	const unset = -1 << 30
+	// best[i][j]: best score with needle[:i+1] matched and needle[i] at haystack[j].
+	best := make([][]int, n)
+	from := make([][]int, n)
+	for i := range best {
+		best[i] = make([]int, h)
+		from[i] = make([]int, h)
+		for j := range best[i] {
+			best[i][j] = unset
+			from[i][j] = -1
+		}
+	}
+
+	for i := 0; i < n; i++ {
+		// gapBest tracks max(best[i-1][k] + k*fuzzyGapPenalty) over k < j-1 so
+		// the gap cost (j-k-1)*fuzzyGapPenalty is applied in O(1) per cell.
+		gapBest, gapFrom := unset, -1
+		for j := i; j < h; j++ {
+			if i > 0 && j >= 2 {
+				if k := j - 2; best[i-1][k] != unset && best[i-1][k]+k*fuzzyGapPenalty > gapBest {
+					gapBest = best[i-1][k] + k*fuzzyGapPenalty
+					gapFrom = k
+				}
+			}
+			if haystack[j] != needle[i] {
+				continue
+			}
+			charScore := fuzzyMatchBonus
+			if isFuzzyBoundary(haystack, j) {
+				charScore += fuzzyBoundaryBonus
+			}
+
+			if i == 0 {
+				best[i][j] = charScore - min(j*fuzzyLeadingPenalty, fuzzyMaxLeadingCost)
+				continue
+			}
+			if prev := best[i-1][j-1]; prev != unset {
+				best[i][j] = prev + charScore + fuzzyConsecutiveBonus
+				from[i][j] = j - 1
+			}
+			if gapBest != unset {
+				if score := gapBest - (j-1)*fuzzyGapPenalty + charScore; score > best[i][j] {
+					best[i][j] = score
+					from[i][j] = gapFrom
+				}
+			}
+		}
+	}
+
+	end, top := -1, unset
+	for j := n - 1; j < h; j++ {
+		if best[n-1][j] > top {
+			top = best[n-1][j]
+			end = j
+		}
+	}
+	if end < 0 {
+		return 0, nil, false
+	}
+
+	positions := make([]int, n)
+	for i, j := n-1, end; i >= 0; i-- {
+		positions[i] = j
+		j = from[i][j]
+	}
+	return top, positions, true
+}
+
+func isFuzzyBoundary(text []rune, idx int) bool {
+	if idx == 0 {
+		return true
+	}
+	prev := text[idx-1]
+	return !unicode.IsLetter(prev) && !unicode.IsDigit(prev)
+}
+
+func dedupeSortedInts(values []int) []int {
+	if len(values) < 2 {
+		return values
+	}
+	out := values[:1]
+	for _, v := range values[1:] {
+		if v != out[len(out)-1] {
+			out = append(out, v)
+		}
+	}
+	return out
+}
@@ -0,0 +1,154 @@
+package prompt
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+func TestFuzzyFilterMatchesSubsequenceCaseInsensitively(t *testing.T) {
+	items := []string{
+		"1. ubuntu@web1.internal (web1) [group:production]",
+		"2. postgres@db.internal - primary database (db) [group:production]",
+		"3. dev@dev.internal (dev) [group:staging] [tags:web]",
+	}
+
+	matches := fuzzyFilter("PGdb", items, nil)
+	if len(matches) != 1 || matches[0].Index != 1 {
+		t.Fatalf("expected only the postgres item, got %+v", matches)
+	}
+
+	matches = fuzzyFilter("staging web", items, nil)
+	if len(matches) != 1 || matches[0].Index != 2 {
+		t.Fatalf("expected every term to match, got %+v", matches)
+	}
+
+	if matches := fuzzyFilter("zzz", items, nil); len(matches) != 0 {
+		t.Fatalf("expected no matches, got %+v", matches)
+	}
+}
+
+func TestFuzzyFilterEmptyQueryKeepsOrder(t *testing.T) {
+	items := []string{"b", "a", "c"}
+	matches := fuzzyFilter("  ", items, []int{0, 5, 9})
+	for i, match := range matches {
+		if match.Index != i || len(match.Positions) != 0 {
+			t.Fatalf("expected unfiltered original order, got %+v", matches)
+		}
+	}
+}
+
+func TestFuzzyFilterRanksBoundaryAndConsecutiveMatchesFirst(t *testing.T) {
+	items := []string{
+		"a-p-r-o-d scattered",
+		"reproduce",
+		"prod server",
+	}
+
+	matches := fuzzyFilter("prod", items, nil)
+	if len(matches) != 3 {
+		t.Fatalf("expected all items to match, got %+v", matches)
+	}
+	got := []int{matches[0].Index, matches[1].Index, matches[2].Index}
+	if fmt.Sprint(got) != "[2 1 0]" {
+		t.Fatalf("unexpected ranking %v", got)
+	}
+	if fmt.Sprint(matches[0].Positions) != "[0 1 2 3]" {
+		t.Fatalf("unexpected positions %v", matches[0].Positions)
+	}
+}
+
+func TestFuzzyFilterBreaksTiesByRecency(t *testing.T) {
+	items := []string{"web-a", "web-b", "web-c"}
+	matches := fuzzyFilter("web", items, []int{1, 0, 7})
+	got := []int{matches[0].Index, matches[1].Index, matches[2].Index}
+	if fmt.Sprint(got) != "[2 0 1]" {
+		t.Fatalf("expected recency tie-break, got %v", got)
+	}
+}
+
+func TestFuzzyScorePrefersConsecutiveRun(t *testing.T) {
+	_, positions, ok := fuzzyScore([]rune("abc"), []rune("a_b_c abc"))
+	if !ok {
+		t.Fatal("expected match")
+	}
+	if fmt.Sprint(positions) != "[6 7 8]" {
+		t.Fatalf("expected consecutive run to win, got %v", positions)
+	}
+}
+
+func TestSelectModelFiltersAndReturnsOriginalIndex(t *testing.T) {
+	items := []string{"alpha", "beta", "gamma", "delta"}
+	m := newSelectModel("Pick", items, nil)
+
+	typeQuery(m, "lt")
+	if len(m.matches) != 1 || m.selected() != 3 {
+		t.Fatalf("expected delta to be selected, got matches=%+v", m.matches)
+	}
+
+	m.Update(tea.KeyMsg{Type: tea.KeyBackspace})
+	m.Update(tea.KeyMsg{Type: tea.KeyBackspace})
+	if len(m.matches) != len(items) {
+		t.Fatalf("expected filter to be cleared, got %d matches", len(m.matches))
+	}
+
+	typeQuery(m, "zz")
+	if view := m.View(); !strings.Contains(view, "No matches") {
+		t.Fatalf("expected no matches notice, got %q", view)
+	}
+	if _, cmd := m.Update(tea.KeyMsg{Type: tea.KeyEnter}); cmd != nil {
+		t.Fatal("enter must not select when nothing matches")
+	}
+}
+
+func TestSelectModelScrollsWithinViewport(t *testing.T) {
+	items := make([]string, 25)
+	for i := range items {
+		items[i] = fmt.Sprintf("host-%02d", i)
+	}
+	m := newSelectModel("Pick", items, nil)
+
+	for i := 0; i < 12; i++ {
+		m.Update(tea.KeyMsg{Type: tea.KeyDown})
+	}
+	if m.cursor != 12 || m.offset != 12-selectViewportHeight+1 {
+		t.Fatalf("unexpected cursor/offset: %d/%d", m.cursor, m.offset)
+	}
+
+	view := m.View()
+	if strings.Contains(view, "host-02") || !strings.Contains(view, "host-12") {
+		t.Fatalf("viewport shows wrong window: %q", view)
+	}
+	if !strings.Contains(view, "↑ 3 more") || !strings.Contains(view, "↓ 12 more") {
+		t.Fatalf("expected scroll indicators, got %q", view)
+	}
+
+	m.Update(tea.KeyMsg{Type: tea.KeyUp})
+	for i := 0; i < 12; i++ {
+		m.Update(tea.KeyMsg{Type: tea.KeyUp})
+	}
+	if m.cursor != 24 || m.offset != 24-selectViewportHeight+1 {
+		t.Fatalf("expected wrap to the last item, got cursor/offset %d/%d", m.cursor, m.offset)
+	}
+}
+
+func TestHighlightMatchesKeepsText(t *testing.T) {
+	rendered := highlightMatches("web-prod", []int{4, 5}, selectItemStyle)
+	for _, part := range []string{"web-", "pr", "od"} {
+		if !strings.Contains(rendered, part) {
+			t.Fatalf("expected %q in rendered item %q", part, rendered)
+		}
+	}
+}
+
+func typeQuery(m *selectModel, query string) {
+	for _, r := range query {
+		key := tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{r}}
+		if r == ' ' {
+			key.Type = tea.KeySpace
+		}
+		m.Update(key)
+	}
+}
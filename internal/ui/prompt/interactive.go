@@ -95,19 +95,29 @@ func (m *inputModel) View() string {
 	return strings.Join(out, "\n")
 }
 
+// selectViewportHeight is the number of items visible at once; longer lists
+// scroll within it.
+const selectViewportHeight = 10
+
 type selectModel struct {
 	label     string
 	items     []string
+	recency   []int
+	query     []rune
+	matches   []fuzzyMatch
 	cursor    int
+	offset    int
 	cancelled bool
 }
 
-func newSelectModel(label string, items []string) *selectModel {
-	return &selectModel{
-		label:  strings.TrimSpace(label),
-		items:  items,
-		cursor: 0,
+func newSelectModel(label string, items []string, recency []int) *selectModel {
+	m := &selectModel{
+		label:   strings.TrimSpace(label),
+		items:   items,
+		recency: recency,
 	}
+	m.refilter()
+	return m
 }
 
 func (m *selectModel) Init() tea.Cmd {
@@ -121,35 +131,136 @@ func (m *selectModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		case "ctrl+c", "ctrl+d", "esc":
 			m.cancelled = true
 			return m, tea.Quit
-		case "up", "k":
-			if len(m.items) > 0 {
-				m.cursor = (m.cursor - 1 + len(m.items)) % len(m.items)
+		case "up", "ctrl+p":
+			if len(m.matches) > 0 {
+				m.cursor = (m.cursor - 1 + len(m.matches)) % len(m.matches)
+			}
+		case "down", "ctrl+n", "tab":
+			if len(m.matches) > 0 {
+				m.cursor = (m.cursor + 1) % len(m.matches)
 			}
-		case "down", "j":
-			if len(m.items) > 0 {
-				m.cursor = (m.cursor + 1) % len(m.items)
+		case "pgup":
+			m.cursor = max(m.cursor-selectViewportHeight, 0)
+		case "pgdown":
+			if len(m.matches) > 0 {
+				m.cursor = min(m.cursor+selectViewportHeight, len(m.matches)-1)
 			}
 		case "enter":
+			if len(m.matches) == 0 {
+				return m, nil
+			}
 			return m, tea.Quit
+		case "backspace", "ctrl+h":
+			if len(m.query) > 0 {
+				m.query = m.query[:len(m.query)-1]
+				m.refilter()
+			}
+		case "ctrl+u":
+			m.query = nil
+			m.refilter()
+		default:
+			if msg.Type == tea.KeyRunes || msg.Type == tea.KeySpace {
+				m.query = append(m.query, msg.Runes...)
+				m.refilter()
+			}
 		}
+		m.scrollToCursor()
 	}
 	return m, nil
 }
 
+// refilter recomputes the matches for the current query and moves the
+// cursor to the best match.
+func (m *selectModel) refilter() {
+	m.matches = fuzzyFilter(string(m.query), m.items, m.recency)
+	m.cursor = 0
+	m.offset = 0
+}
+
+func (m *selectModel) scrollToCursor() {
+	if m.cursor < m.offset {
+		m.offset = m.cursor
+	}
+	if m.cursor >= m.offset+selectViewportHeight {
+		m.offset = m.cursor - selectViewportHeight + 1
+	}
+}
+
+// selected returns the original index of the highlighted item, or -1.
+func (m *selectModel) selected() int {
+	if m.cursor < 0 || m.cursor >= len(m.matches) {
+		return -1
+	}
+	return m.matches[m.cursor].Index
+}
+
 func (m *selectModel) View() string {
-	lines := make([]string, 0, len(m.items)+2)
+	lines := make([]string, 0, selectViewportHeight+5)
 	lines = append(lines, promptTitleStyle.Render(m.label))
-	for i, item := range m.items {
+	lines = append(lines, promptValueStyle.Render("Filter: "+string(m.query)+promptCursor))
+
+	end := min(m.offset+selectViewportHeight, len(m.matches))
+	if m.offset > 0 {
+		lines = append(lines, promptHelpStyle.Render(fmt.Sprintf("  ↑ %d more", m.offset)))
+	}
+	for i := m.offset; i < end; i++ {
+		match := m.matches[i]
 		if i == m.cursor {
-			lines = append(lines, selectCursor+selectActiveItem.Render(item))
+			lines = append(lines, selectCursor+highlightMatches(m.items[match.Index], match.Positions, selectActiveItem))
 			continue
 		}
-		lines = append(lines, "  "+selectItemStyle.Render(item))
+		lines = append(lines, "  "+highlightMatches(m.items[match.Index], match.Positions, selectItemStyle))
 	}
-	lines = append(lines, promptHelpStyle.Render("Up/Down or j/k | Enter: select | Esc/Ctrl+C: cancel"))
+	if len(m.matches) == 0 {
+		lines = append(lines, promptHelpStyle.Render("  No matches"))
+	}
+	if rest := len(m.matches) - end; rest > 0 {
+		lines = append(lines, promptHelpStyle.Render(fmt.Sprintf("  ↓ %d more", rest)))
+	}
+
+	lines = append(lines, promptHelpStyle.Render(fmt.Sprintf("%d/%d | Type to filter | Up/Down | Enter: select | Esc/Ctrl+C: cancel", len(m.matches), len(m.items))))
 	return strings.Join(lines, "\n")
 }
 
+// highlightMatches renders item with base, emphasising the runes at the
+// given (ascending) positions.
+func highlightMatches(item string, positions []int, base lipgloss.Style) string {
+	if len(positions) == 0 {
+		return base.Render(item)
+	}
+
+	highlight := base.Foreground(lipgloss.Color("214")).Underline(true)
+	var b strings.Builder
+	var run []rune
+	runHighlighted := false
+	flush := func() {
+		if len(run) == 0 {
+			return
+		}
+		if runHighlighted {
+			b.WriteString(highlight.Render(string(run)))
+		} else {
+			b.WriteString(base.Render(string(run)))
+		}
+		run = run[:0]
+	}
+
+	next := 0
+	for i, r := range []rune(item) {
+		isMatch := next < len(positions) && positions[next] == i
+		if isMatch {
+			next++
+		}
+		if isMatch != runHighlighted {
+			flush()
+			runHighlighted = isMatch
+		}
+		run = append(run, r)
+	}
+	flush()
+	return b.String()
+}
+
 func runTea(model tea.Model) (tea.Model, error) {
 	p := tea.NewProgram(model, tea.WithInput(os.Stdin), tea.WithOutput(os.Stdout))
 	finalModel, err := p.Run()
@@ -176,11 +287,18 @@ func InputPrompt(label, defaultValue string, mask bool, validate func(string) er
 }
 
 func SelectPrompt(label string, items []string) (int, string, error) {
+	return SelectPromptRanked(label, items, nil)
+}
+
+// SelectPromptRanked is SelectPrompt with a usage rank per item (higher means
+// more recently or frequently used). Ranks break ties between equally good
+// filter matches; missing entries count as zero.
+func SelectPromptRanked(label string, items []string, recency []int) (int, string, error) {
 	if len(items) == 0 {
 		return -1, "", fmt.Errorf("no items to select")
 	}
 
-	finalModel, err := runTea(newSelectModel(label, items))
+	finalModel, err := runTea(newSelectModel(label, items, recency))
 	if err != nil {
 		return -1, "", err
 	}
@@ -192,5 +310,9 @@ func SelectPrompt(label string, items []string) (int, string, error) {
 	if m.cancelled {
 		return -1, "", ErrCancelled
 	}
-	return m.cursor, m.items[m.cursor], nil
+	idx := m.selected()
+	if idx < 0 {
+		return -1, "", ErrCancelled
+	}
+	return idx, m.items[idx], nil
 }
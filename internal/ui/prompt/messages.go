@@ -11,6 +11,7 @@ type DefaultPromptTextError struct {
 	FailedToLoadConnectionsX         string
 	FailedToLoadConfigX              string
 	FailedToInstallCompletionX       string
+	PassphrasesDoNotMatch            string
 }
 
 type DefaultPromptTextSuccess struct {
@@ -34,6 +35,7 @@ type DefaultPromptText struct {
 	EnterProxyJump            string
 	EnterLocalForwards        string
 	EnterRemoteForwards       string
+	EnterDynamicForwards      string
 	EnterExtraSSHArgs         string
 	EnterGroup                string
 	EnterTags                 string
@@ -48,6 +50,7 @@ type DefaultPromptText struct {
 	EditProxyJump             string
 	EditLocalForwards         string
 	EditRemoteForwards        string
+	EditDynamicForwards       string
 	EditExtraSSHArgs          string
 	EditGroup                 string
 	EditTags                  string
@@ -62,6 +65,14 @@ type DefaultPromptText struct {
 	EditSSHConnection         string
 	RemoveSSHConnection       string
 	RenameSSHConnection       string
+	EnterMasterPassphrase     string
+	EnterNewMasterPassphrase  string
+	RepeatMasterPassphrase    string
+	EnterBackupPassphrase     string
+	RepeatBackupPassphrase    string
+	ChooseKeyProtection       string
+	KeyProtectionPassphrase   string
+	KeyProtectionRawKey       string
 	ErrorMessages             DefaultPromptTextError
 	SuccessMessages           DefaultPromptTextSuccess
 }
@@ -74,8 +85,9 @@ var DefaultPromptTexts = DefaultPromptText{
 	EnterPassword:             "Enter Password",
 	EnterIdentityFile:         "Enter Identity File (required for key mode)",
 	EnterProxyJump:            "Enter ProxyJump (optional)",
-	EnterLocalForwards:        "Enter Local Forwards (optional, comma-separated [bind_address:]port:host:hostport)",
-	EnterRemoteForwards:       "Enter Remote Forwards (optional, comma-separated [bind_address:]port:host:hostport)",
+	EnterLocalForwards:        "Enter Local Forwards (optional, comma-separated [bind_address:]port:host:hostport; socket paths allowed on either side)",
+	EnterRemoteForwards:       "Enter Remote Forwards (optional, comma-separated [bind_address:]port:host:hostport; socket paths allowed on either side)",
+	EnterDynamicForwards:      "Enter Dynamic (SOCKS) Forwards (optional, comma-separated [bind_address:]port)",
 	EnterExtraSSHArgs:         "Enter Extra SSH Args (optional, comma-separated tokens)",
 	EnterGroup:                "Enter Group (optional)",
 	EnterTags:                 "Enter Tags (optional, comma-separated)",
@@ -88,8 +100,9 @@ var DefaultPromptTexts = DefaultPromptText{
 	EditPassword:              "Edit Password",
 	EditIdentityFile:          "Edit Identity File (required for key mode)",
 	EditProxyJump:             "Edit ProxyJump (optional)",
-	EditLocalForwards:         "Edit Local Forwards (optional, comma-separated [bind_address:]port:host:hostport)",
-	EditRemoteForwards:        "Edit Remote Forwards (optional, comma-separated [bind_address:]port:host:hostport)",
+	EditLocalForwards:         "Edit Local Forwards (optional, comma-separated [bind_address:]port:host:hostport; socket paths allowed on either side)",
+	EditRemoteForwards:        "Edit Remote Forwards (optional, comma-separated [bind_address:]port:host:hostport; socket paths allowed on either side)",
+	EditDynamicForwards:       "Edit Dynamic (SOCKS) Forwards (optional, comma-separated [bind_address:]port)",
 	EditExtraSSHArgs:          "Edit Extra SSH Args (optional, comma-separated tokens)",
 	EditGroup:                 "Edit Group (optional)",
 	EditTags:                  "Edit Tags (optional, comma-separated)",
@@ -104,6 +117,14 @@ var DefaultPromptTexts = DefaultPromptText{
 	EditSSHConnection:         "Edit SSH Connection",
 	RemoveSSHConnection:       "Remove SSH Connection",
 	RenameSSHConnection:       "Rename SSH Alias",
+	EnterMasterPassphrase:     "Enter Master Passphrase",
+	EnterNewMasterPassphrase:  "Enter New Master Passphrase",
+	RepeatMasterPassphrase:    "Repeat Master Passphrase",
+	EnterBackupPassphrase:     "Enter Backup Passphrase",
+	RepeatBackupPassphrase:    "Repeat Backup Passphrase",
+	ChooseKeyProtection:       "How should the encryption key be stored?",
+	KeyProtectionPassphrase:   "Derive it from a master passphrase (asked for on every run)",
+	KeyProtectionRawKey:       "Store a random key file (no passphrase)",
 	ErrorMessages: DefaultPromptTextError{
 		NoSSHConnectionsFound:            "No SSH connections found.",
 		AliasNotFoundX:                   "No SSH connection found for alias: %s",
@@ -115,6 +136,7 @@ var DefaultPromptTexts = DefaultPromptText{
 		FailedToLoadConnectionsX:         "Failed to load connections: %v",
 		FailedToLoadConfigX:              "Failed to load config: %v",
 		FailedToInstallCompletionX:       "Failed to install completion: %v",
+		PassphrasesDoNotMatch:            "passphrases do not match",
 	},
 	SuccessMessages: DefaultPromptTextSuccess{
 		SSHConnectionSaved:   "SSH connection saved.",
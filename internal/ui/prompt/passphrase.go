@@ -0,0 +1,51 @@
+package prompt
+
+import "errors"
+
+// MasterPassphrasePrompt asks for the master passphrase with masked input.
+func MasterPassphrasePrompt(label string) (string, error) {
+	return runPasswordPrompt(label, "", true)
+}
+
+// NewMasterPassphrasePrompt asks for a new master passphrase twice and
+// fails when the entries differ.
+func NewMasterPassphrasePrompt() (string, error) {
+	return newPassphrasePrompt(DefaultPromptTexts.EnterNewMasterPassphrase, DefaultPromptTexts.RepeatMasterPassphrase)
+}
+
+// NewBackupPassphrasePrompt asks for the passphrase of an encrypted backup
+// twice and fails when the entries differ.
+func NewBackupPassphrasePrompt() (string, error) {
+	return newPassphrasePrompt(DefaultPromptTexts.EnterBackupPassphrase, DefaultPromptTexts.RepeatBackupPassphrase)
+}
+
+func newPassphrasePrompt(label, repeatLabel string) (string, error) {
+	passphrase, err := runPasswordPrompt(label, "", true)
+	if err != nil {
+		return "", err
+	}
+	confirm, err := runPasswordPrompt(repeatLabel, "", true)
+	if err != nil {
+		return "", err
+	}
+	if passphrase != confirm {
+		return "", errors.New(DefaultPromptTexts.ErrorMessages.PassphrasesDoNotMatch)
+	}
+	return passphrase, nil
+}
+
+// KeyProtectionPrompt asks whether a new encryption key should be derived
+// from a passphrase. It returns the new passphrase, or "" for a raw key.
+func KeyProtectionPrompt() (string, error) {
+	idx, _, err := SelectPrompt(DefaultPromptTexts.ChooseKeyProtection, []string{
+		DefaultPromptTexts.KeyProtectionPassphrase,
+		DefaultPromptTexts.KeyProtectionRawKey,
+	})
+	if err != nil {
+		return "", err
+	}
+	if idx != 0 {
+		return "", nil
+	}
+	return NewMasterPassphrasePrompt()
+}
@@ -0,0 +1,270 @@
+// Package vault resolves the data directory of a named store. Each vault has
+// its own connection file, history, key file, lock and config.
+//
+// The built-in "default" vault lives directly in the SSH Manager home
+// (~/.sshmanager unless SSHMANAGER_HOME is set), which keeps installations
+// from before vaults existed working unchanged. Named vaults live in
+// <home>/vaults/<name>.
+package vault
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/emirhangumus/sshmanager/internal/config"
+	"github.com/emirhangumus/sshmanager/internal/storage"
+)
+
+const (
+	HomeEnvVar  = "SSHMANAGER_HOME"
+	VaultEnvVar = "SSHMANAGER_VAULT"
+
+	// DefaultName is the vault stored in the home directory itself.
+	DefaultName = "default"
+
+	vaultsDirName    = "vaults"
+	defaultVaultFile = "default-vault"
+	maxNameLength    = 64
+)
+
+var namePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9._-]*$`)
+
+// Paths are the files of one vault.
+type Paths struct {
+	Name           string
+	Dir            string
+	ConnectionFile string
+	SecretKeyFile  string
+	ConfigFile     string
+}
+
+// Info describes a vault for listing.
+type Info struct {
+	Name        string `json:"name"`
+	Dir         string `json:"dir"`
+	Default     bool   `json:"default"`
+	Initialized bool   `json:"initialized"`
+}
+
+// HomeDir returns SSHMANAGER_HOME, or ~/.sshmanager when it is unset.
+func HomeDir() (string, error) {
+	if home := strings.TrimSpace(os.Getenv(HomeEnvVar)); home != "" {
+		return filepath.Abs(home)
+	}
+	userHome, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("could not determine home directory: %w", err)
+	}
+	return filepath.Join(userHome, ".sshmanager"), nil
+}
+
+// HomeOf returns the SSH Manager home the vault in vaultDir belongs to: the
+// directory itself for the default vault, <home> for <home>/vaults/<name>.
+func HomeOf(vaultDir string) string {
+	parent := filepath.Dir(vaultDir)
+	if filepath.Base(parent) == vaultsDirName {
+		return filepath.Dir(parent)
+	}
+	return vaultDir
+}
+
+// ValidateName checks that name is usable as a vault directory name.
+func ValidateName(name string) error {
+	if name == "" {
+		return errors.New("vault name must not be empty")
+	}
+	if len(name) > maxNameLength {
+		return fmt.Errorf("vault name %q is longer than %d characters", name, maxNameLength)
+	}
+	if !namePattern.MatchString(name) {
+		return fmt.Errorf("invalid vault name %q (use lowercase letters, digits, '.', '_' and '-')", name)
+	}
+	return nil
+}
+
+// PathsFor returns the files of vault name under home without checking that
+// it exists.
+func PathsFor(home, name string) Paths {
+	dir := home
+	if name != DefaultName {
+		dir = filepath.Join(home, vaultsDirName, name)
+	}
+	return Paths{
+		Name:           name,
+		Dir:            dir,
+		ConnectionFile: filepath.Join(dir, "conn"),
+		SecretKeyFile:  filepath.Join(dir, "secret.key"),
+		ConfigFile:     filepath.Join(dir, config.FileName),
+	}
+}
+
+// Resolve selects the vault for this invocation: the --vault flag value,
+// then SSHMANAGER_VAULT, then the vault set with SetDefault, then "default".
+// Named vaults must have been created first.
+func Resolve(home, flagValue string) (Paths, error) {
+	name := strings.TrimSpace(flagValue)
+	if name == "" {
+		name = strings.TrimSpace(os.Getenv(VaultEnvVar))
+	}
+	if name == "" {
+		defaultName, err := DefaultVault(home)
+		if err != nil {
+			return Paths{}, err
+		}
+		name = defaultName
+	}
+
+	if err := ValidateName(name); err != nil {
+		return Paths{}, err
+	}
+	if !Exists(home, name) {
+		return Paths{}, fmt.Errorf("vault %q does not exist (create it with 'sshmanager vault create %s')", name, name)
+	}
+	return PathsFor(home, name), nil
+}
+
+// Exists reports whether vault name has been created. The default vault
+// always exists.
+func Exists(home, name string) bool {
+	if name == DefaultName {
+		return true
+	}
+	info, err := os.Stat(PathsFor(home, name).Dir)
+	return err == nil && info.IsDir()
+}
+
+// DefaultVault returns the vault used when neither --vault nor
+// SSHMANAGER_VAULT is given.
+func DefaultVault(home string) (string, error) {
+	data, err := os.ReadFile(filepath.Join(home, defaultVaultFile))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return DefaultName, nil
+		}
+		return "", fmt.Errorf("failed to read default vault: %w", err)
+	}
+	name := strings.TrimSpace(string(data))
+	if name == "" {
+		return DefaultName, nil
+	}
+	return name, nil
+}
+
+// SetDefault makes name the vault used when none is selected.
+func SetDefault(home, name string) error {
+	if err := ValidateName(name); err != nil {
+		return err
+	}
+	if !Exists(home, name) {
+		return fmt.Errorf("vault %q does not exist", name)
+	}
+
+	path := filepath.Join(home, defaultVaultFile)
+	if name == DefaultName {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to reset default vault: %w", err)
+		}
+		return nil
+	}
+	if err := os.MkdirAll(home, 0o700); err != nil {
+		return fmt.Errorf("failed to create %s: %w", home, err)
+	}
+	return storage.WriteFileAtomic(path, []byte(name+"\n"), 0o600)
+}
+
+// Create makes the directory for a new named vault. Its key and config are
+// created by the first command that uses it.
+func Create(home, name string) (Paths, error) {
+	if err := ValidateName(name); err != nil {
+		return Paths{}, err
+	}
+	if name == DefaultName {
+		return Paths{}, fmt.Errorf("vault %q always exists", DefaultName)
+	}
+	if Exists(home, name) {
+		return Paths{}, fmt.Errorf("vault %q already exists", name)
+	}
+
+	paths := PathsFor(home, name)
+	if err := os.MkdirAll(paths.Dir, 0o700); err != nil {
+		return Paths{}, fmt.Errorf("failed to create vault directory: %w", err)
+	}
+	return paths, nil
+}
+
+// Remove securely deletes the data files of a named vault and its
+// directory. The built-in vault and the current default cannot be removed.
+func Remove(home, name string) error {
+	if err := ValidateName(name); err != nil {
+		return err
+	}
+	if name == DefaultName {
+		return fmt.Errorf("the %q vault cannot be removed; use 'sshmanager clean' to reset it", DefaultName)
+	}
+	if !Exists(home, name) {
+		return fmt.Errorf("vault %q does not exist", name)
+	}
+	defaultName, err := DefaultVault(home)
+	if err != nil {
+		return err
+	}
+	if defaultName == name {
+		return fmt.Errorf("vault %q is the default; choose another default with 'sshmanager vault default <name>' first", name)
+	}
+
+	dir := PathsFor(home, name).Dir
+	err = filepath.WalkDir(dir, func(path string, entry os.DirEntry, walkErr error) error {
+		if walkErr != nil || entry.IsDir() {
+			return walkErr
+		}
+		return storage.SecureDelete(path)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to delete vault files: %w", err)
+	}
+	if err := os.RemoveAll(dir); err != nil {
+		return fmt.Errorf("failed to remove vault directory: %w", err)
+	}
+	return nil
+}
+
+// List returns the default vault followed by the named vaults in
+// alphabetical order.
+func List(home string) ([]Info, error) {
+	defaultName, err := DefaultVault(home)
+	if err != nil {
+		return nil, err
+	}
+
+	names := []string{DefaultName}
+	entries, err := os.ReadDir(filepath.Join(home, vaultsDirName))
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to list vaults: %w", err)
+	}
+	named := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		if entry.IsDir() && ValidateName(entry.Name()) == nil {
+			named = append(named, entry.Name())
+		}
+	}
+	sort.Strings(named)
+	names = append(names, named...)
+
+	infos := make([]Info, 0, len(names))
+	for _, name := range names {
+		paths := PathsFor(home, name)
+		_, statErr := os.Stat(paths.SecretKeyFile)
+		infos = append(infos, Info{
+			Name:        name,
+			Dir:         paths.Dir,
+			Default:     name == defaultName,
+			Initialized: statErr == nil,
+		})
+	}
+	return infos, nil
+}
@@ -0,0 +1,156 @@
+package vault
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestResolvePrecedence(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv(VaultEnvVar, "")
+	for _, name := range []string{"team", "customer-a"} {
+		if _, err := Create(home, name); err != nil {
+			t.Fatalf("Create(%s) failed: %v", name, err)
+		}
+	}
+
+	paths, err := Resolve(home, "")
+	if err != nil {
+		t.Fatalf("Resolve failed: %v", err)
+	}
+	if paths.Name != DefaultName || paths.ConnectionFile != filepath.Join(home, "conn") {
+		t.Fatalf("expected default vault in home root, got %+v", paths)
+	}
+
+	if err := SetDefault(home, "team"); err != nil {
+		t.Fatalf("SetDefault failed: %v", err)
+	}
+	if paths, _ = Resolve(home, ""); paths.Name != "team" {
+		t.Fatalf("expected configured default, got %q", paths.Name)
+	}
+
+	t.Setenv(VaultEnvVar, "customer-a")
+	if paths, _ = Resolve(home, ""); paths.Name != "customer-a" {
+		t.Fatalf("expected env vault, got %q", paths.Name)
+	}
+
+	paths, err = Resolve(home, DefaultName)
+	if err != nil {
+		t.Fatalf("Resolve(flag) failed: %v", err)
+	}
+	if paths.Name != DefaultName {
+		t.Fatalf("expected flag to win, got %q", paths.Name)
+	}
+}
+
+func TestResolveRejectsMissingAndInvalidVaults(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv(VaultEnvVar, "")
+
+	if _, err := Resolve(home, "nope"); err == nil || !strings.Contains(err.Error(), "vault create nope") {
+		t.Fatalf("expected missing vault error, got %v", err)
+	}
+	for _, name := range []string{"../escape", "Upper", ".hidden", strings.Repeat("a", 65)} {
+		if _, err := Resolve(home, name); err == nil {
+			t.Fatalf("expected invalid name error for %q", name)
+		}
+	}
+}
+
+func TestPathsAreSeparatePerVault(t *testing.T) {
+	home := t.TempDir()
+	team := PathsFor(home, "team")
+	personal := PathsFor(home, DefaultName)
+
+	for _, pair := range [][2]string{
+		{team.ConnectionFile, personal.ConnectionFile},
+		{team.SecretKeyFile, personal.SecretKeyFile},
+		{team.ConfigFile, personal.ConfigFile},
+	} {
+		if pair[0] == pair[1] {
+			t.Fatalf("vaults share %s", pair[0])
+		}
+	}
+	if team.Dir != filepath.Join(home, "vaults", "team") {
+		t.Fatalf("unexpected vault dir: %s", team.Dir)
+	}
+	for _, dir := range []string{team.Dir, personal.Dir} {
+		if got := HomeOf(dir); got != home {
+			t.Fatalf("HomeOf(%s) = %s, want %s", dir, got, home)
+		}
+	}
+}
+
+func TestRemoveDeletesVaultButNotDefaults(t *testing.T) {
+	home := t.TempDir()
+	paths, err := Create(home, "old")
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	if err := os.WriteFile(paths.SecretKeyFile, []byte("k"), 0o600); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+
+	if err := Remove(home, DefaultName); err == nil {
+		t.Fatal("expected built-in vault removal to fail")
+	}
+	if err := SetDefault(home, "old"); err != nil {
+		t.Fatalf("SetDefault failed: %v", err)
+	}
+	if err := Remove(home, "old"); err == nil {
+		t.Fatal("expected removal of the default vault to fail")
+	}
+	if err := SetDefault(home, DefaultName); err != nil {
+		t.Fatalf("SetDefault(default) failed: %v", err)
+	}
+	if err := Remove(home, "old"); err != nil {
+		t.Fatalf("Remove failed: %v", err)
+	}
+	if _, err := os.Stat(paths.Dir); !os.IsNotExist(err) {
+		t.Fatalf("expected vault directory to be gone, stat err: %v", err)
+	}
+}
+
+func TestListMarksDefaultAndInitialized(t *testing.T) {
+	home := t.TempDir()
+	for _, name := range []string{"zeta", "alpha"} {
+		if _, err := Create(home, name); err != nil {
+			t.Fatalf("Create(%s) failed: %v", name, err)
+		}
+	}
+	if err := os.WriteFile(PathsFor(home, "alpha").SecretKeyFile, []byte("k"), 0o600); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+	if err := SetDefault(home, "zeta"); err != nil {
+		t.Fatalf("SetDefault failed: %v", err)
+	}
+
+	infos, err := List(home)
+	if err != nil {
+		t.Fatalf("List failed: %v", err)
+	}
+	var names []string
+	for _, info := range infos {
+		names = append(names, info.Name)
+	}
+	if strings.Join(names, ",") != "default,alpha,zeta" {
+		t.Fatalf("unexpected vault order: %v", names)
+	}
+	if !infos[1].Initialized || infos[2].Initialized || !infos[2].Default || infos[0].Default {
+		t.Fatalf("unexpected vault info: %+v", infos)
+	}
+}
+
+func TestHomeDirHonoursEnv(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv(HomeEnvVar, home)
+	got, err := HomeDir()
+	if err != nil {
+		t.Fatalf("HomeDir failed: %v", err)
+	}
+	if got != home {
+		t.Fatalf("expected %s, got %s", home, got)
+	}
+}